@@ -1,7 +1,75 @@
 package main
 
-import "log"
+import (
+	"flag"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/api"
+	"github.com/0xb10c/bademeister-go/src/service"
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+var address = flag.String("address", "127.0.0.1:8080", "listen address of the API server")
+var dataDir = flag.String("datadir", "", "directory for relative -db and -job-dir paths; auto for the directory of the platform, e.g. ~/.config/bademeister (default: the working directory)")
+var dbPath = flag.String("db", "transactions.db", "path to transactions database")
+var readOnly = flag.Bool("read-only", false, "open the database read-only without migrating it, e.g. a replica (-db may be a file: URI)")
+var allowNewerSchema = flag.Bool("allow-newer-schema", false, "with -read-only, also open a database written by a newer release (some queries may fail)")
+var histogramInterval = flag.Duration("histogram-interval", api.DefaultHistogramInterval, "interval of the feerate histogram stream")
+var slowQueryThreshold = flag.Duration("slow-query-threshold", 0, "log storage statements that take longer, with the query plan at log level debug, 0 to disable")
+var corsOrigins = flag.String("cors-origins", "", "comma-separated origins allowed to query the API from browsers, e.g. https://example.com, or * for any (default: none)")
+var corsMethods = flag.String("cors-methods", "GET", "comma-separated methods allowed in cross-origin requests")
+var jobDir = flag.String("job-dir", "", "directory of the jobs of /v1/jobs and their results, relative to -datadir; jobs are disabled if empty")
+var jobWorkers = flag.Int("job-workers", 1, "number of jobs of /v1/jobs run at the same time")
+var logLevel = flag.String("log", "info", "log level (info,debug,trace)")
 
 func main() {
-	log.Println("Hello bademeister-api")
+	flag.Parse()
+
+	log.SetFormatter(&log.TextFormatter{
+		TimestampFormat: time.RFC3339,
+		FullTimestamp:   true,
+	})
+	level, err := log.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("invalid log level %q", *logLevel)
+	}
+	log.SetLevel(level)
+
+	log.Println("Starting Bademeister API")
+
+	dir, err := service.DataDir(*dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	*dbPath = service.ResolvePath(dir, *dbPath)
+	*jobDir = service.ResolvePath(dir, *jobDir)
+
+	open := storage.NewStorage
+	if *readOnly && *allowNewerSchema {
+		open = storage.OpenReadOnlyAllowNewer
+	} else if *readOnly {
+		open = storage.OpenReadOnly
+	} else if *allowNewerSchema {
+		log.Fatal("-allow-newer-schema requires -read-only")
+	}
+	st, err := open(*dbPath)
+	if err != nil {
+		log.Fatalf("could not open storage: %s", err)
+	}
+	defer st.Close()
+	st.SetSlowQueryThreshold(*slowQueryThreshold)
+
+	server := api.NewServer(st)
+	server.SetHistogramInterval(*histogramInterval)
+	server.SetCORS(api.ParseCORSConfig(*corsOrigins, *corsMethods))
+	if *jobDir != "" {
+		if err := server.EnableJobs(*jobDir, *jobWorkers); err != nil {
+			log.Fatalf("could not enable jobs: %s", err)
+		}
+	}
+	if err := server.ListenAndServe(*address); err != nil {
+		log.Errorf("API server stopped: %s", err)
+	}
 }
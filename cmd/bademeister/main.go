@@ -0,0 +1,790 @@
+// Command bademeister contains offline tools for bademeister databases.
+//
+// Usage:
+//
+//	bademeister compare [-at <time>] [-json] [-list] a.db b.db
+//	bademeister compare [-at <time>] [-json] [-list] -source <label> a.db
+//	bademeister import -source <label> [-format csv|json|johoe] [-privacy-salt-file <path>] a.db dataset
+//	bademeister estimate [-from <time>] [-to <time>] [-json] a.db
+//	bademeister export [-from <time>] [-to <time>] a.db out.csv|out.csv.gz|-
+//	bademeister export -format johoe -from <time> -to <time> [-interval <duration>] [-workers <n>] a.db out.js|out.json|-
+//	bademeister export -format sources [-from <time>] [-to <time>] [-node-key <path>] a.db out.csv|out.csv.gz|-
+//	bademeister pin -name <name> [-at <time>] a.db
+//	bademeister pin -name <name> -out out.json|- a.db
+//	bademeister pin -list a.db
+//	bademeister views -at <time> [-cte]
+//	bademeister check a.db
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/compare"
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/importer"
+	"github.com/0xb10c/bademeister-go/src/privacy"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// commands maps subcommand names to their implementation
+var commands = map[string]func(args []string) error{
+	"compare":  runCompare,
+	"import":   runImport,
+	"estimate": runEstimate,
+	"export":   runExport,
+	"pin":      runPin,
+	"views":    runViews,
+	"check":    runCheck,
+}
+
+// importBatchSize is the number of records stored per database transaction
+const importBatchSize = 1000
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [arguments]\n\ncommands:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  compare   compare the reconstructed mempools of two databases\n")
+	fmt.Fprintf(os.Stderr, "  import    import first-seen times or mempool histograms of an external dataset\n")
+	fmt.Fprintf(os.Stderr, "  estimate  estimate the network first-seen times from all sources\n")
+	fmt.Fprintf(os.Stderr, "  export    write the transactions as CSV or the mempool as feerate buckets\n")
+	fmt.Fprintf(os.Stderr, "  pin       store the reconstructed mempool under a name, list or write pinned snapshots\n")
+	fmt.Fprintf(os.Stderr, "  views     print SQL for temporary views of the mempool and chain at a time\n")
+	fmt.Fprintf(os.Stderr, "  check     check a database for corruption\n")
+}
+
+func main() {
+	log.SetLevel(log.WarnLevel)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// parseArgs parses flags that appear before, between or after the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseTime parses a time given as unix seconds or RFC3339 string
+func parseTime(v string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, expected unix seconds or an RFC3339 time like 2020-01-02T15:04:05Z", v)
+	}
+	return t.UTC(), nil
+}
+
+// openStorage opens the existing database at `path`
+func openStorage(path string) (*storage.Storage, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return storage.NewStorage(path)
+}
+
+// openStorageForQuery opens the existing database at `path` read-only, without migrating it.
+// A database with an older schema version is refused, one written by a newer release is opened
+// with a warning.
+func openStorageForQuery(path string) (*storage.Storage, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return storage.OpenReadOnlyAllowNewer(path)
+}
+
+// mempoolAt returns the reconstructed mempool of the database at `path` at time `at`
+func mempoolAt(path string, at time.Time) ([]types.Transaction, error) {
+	st, err := openStorageForQuery(path)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	mempool, err := storage.NewMempoolAtTime(st, at)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not reconstruct mempool of %s", path)
+	}
+	return mempool.Transactions(), nil
+}
+
+// externalFirstSeen returns the transactions of `mempool` that are in the dataset `source`
+// of the database at `path`, with the first-seen time of the dataset
+func externalFirstSeen(path, source string, mempool []types.Transaction) ([]types.Transaction, error) {
+	st, err := openStorageForQuery(path)
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	txids := make([]types.Hash32, len(mempool))
+	for i, tx := range mempool {
+		txids[i] = tx.TxID
+	}
+	firstSeen, err := st.ExternalFirstSeen(source, txids)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []types.Transaction
+	for _, tx := range mempool {
+		if t, ok := firstSeen[tx.TxID]; ok {
+			tx.FirstSeen = t
+			res = append(res, tx)
+		}
+	}
+	return res, nil
+}
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	atFlag := fs.String("at", "", "time of the snapshots as unix seconds or RFC3339 (default: now)")
+	jsonOutput := fs.Bool("json", false, "print the result as JSON")
+	list := fs.Bool("list", false, "list the txids seen by only one database")
+	source := fs.String("source", "", "compare the database with the imported dataset with this label")
+
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if *source != "" && len(paths) != 1 {
+		return errors.New("expected one database path with -source")
+	}
+	if *source == "" && len(paths) != 2 {
+		return errors.New("expected two database paths")
+	}
+
+	at := time.Now().UTC()
+	if *atFlag != "" {
+		if at, err = parseTime(*atFlag); err != nil {
+			return err
+		}
+	}
+
+	a, err := mempoolAt(paths[0], at)
+	if err != nil {
+		return err
+	}
+	var b []types.Transaction
+	if *source != "" {
+		paths = append(paths, "source "+*source)
+		b, err = externalFirstSeen(paths[0], *source, a)
+	} else {
+		b, err = mempoolAt(paths[1], at)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := compare.Mempools(at, a, b)
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	fmt.Printf("time:          %s\n", res.Time.Format(time.RFC3339))
+	fmt.Printf("transactions:  A=%d B=%d common=%d\n", res.CountA, res.CountB, res.Common)
+	fmt.Printf("only in A:     %d (%s)\n", len(res.OnlyA), paths[0])
+	fmt.Printf("only in B:     %d (%s)\n", len(res.OnlyB), paths[1])
+	d := res.Deltas
+	fmt.Printf("first seen:    A first=%d B first=%d equal=%d\n", d.FirstA, d.FirstB, d.Equal)
+	fmt.Printf("delta (B - A): min=%s median=%s mean=%s max=%s\n", d.Min, d.Median, d.Mean, d.Max)
+
+	if *list {
+		for _, txid := range res.OnlyA {
+			fmt.Printf("A %s\n", txid)
+		}
+		for _, txid := range res.OnlyB {
+			fmt.Printf("B %s\n", txid)
+		}
+	}
+
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	source := fs.String("source", "", "label of the dataset, used for records without source column")
+	format := fs.String("format", "", "format of the dataset, csv or json for first-seen times, johoe for mempool "+
+		"histograms (default: from the file extension)")
+	saltFile := fs.String("privacy-salt-file", "", "salt of a database in privacy mode, txids are hashed before they are stored")
+
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 2 {
+		return errors.New("expected a database path and a dataset path")
+	}
+
+	f := importer.Format(*format)
+	if f == "" {
+		if f, err = importer.FormatFromPath(paths[1]); err != nil {
+			return err
+		}
+	}
+
+	var hasher *privacy.TxIDHasher
+	if *saltFile != "" {
+		// a new salt would make the imported txids unrelated to the stored ones
+		if _, err := os.Stat(*saltFile); err != nil {
+			return err
+		}
+		salt, err := privacy.LoadOrCreateSalt(*saltFile)
+		if err != nil {
+			return err
+		}
+		hasher = privacy.NewTxIDHasher(salt)
+	}
+
+	st, err := openStorage(paths[0])
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	in, err := os.Open(paths[1])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if f == importer.FormatJohoe {
+		return importJohoe(st, in, *source)
+	}
+
+	r, err := importer.NewReader(in, f, *source)
+	if err != nil {
+		return err
+	}
+
+	var imported, changed int64
+	batch := make([]types.ExternalFirstSeen, 0, importBatchSize)
+	flush := func() error {
+		n, err := st.InsertExternalFirstSeen(batch)
+		if err != nil {
+			return err
+		}
+		imported += int64(len(batch))
+		changed += n
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hasher != nil {
+			record.TxID = hasher.Hash(record.TxID)
+		}
+		batch = append(batch, *record)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d records, %d new or earlier first-seen times\n", imported, changed)
+	return nil
+}
+
+// importJohoe stores the mempool histograms of the data file `in` of the johoe mempool statistics
+func importJohoe(st *storage.Storage, in io.Reader, source string) error {
+	r, err := importer.NewJohoeReader(in, source)
+	if err != nil {
+		return err
+	}
+
+	var imported int64
+	batch := make([]types.ImportedHistogram, 0, importBatchSize)
+	flush := func() error {
+		if err := st.InsertImportedHistograms(batch); err != nil {
+			return err
+		}
+		imported += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		h, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		batch = append(batch, *h)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d histograms\n", imported)
+	return nil
+}
+
+func runEstimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "start of the first-seen range as unix seconds or RFC3339 (default: all)")
+	toFlag := fs.String("to", "", "end of the first-seen range as unix seconds or RFC3339 (default: now)")
+	jsonOutput := fs.Bool("json", false, "print the lags as JSON")
+
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		return errors.New("expected one database path")
+	}
+
+	from, to := time.Unix(0, 0).UTC(), time.Now().UTC()
+	if *fromFlag != "" {
+		if from, err = parseTime(*fromFlag); err != nil {
+			return err
+		}
+	}
+	if *toFlag != "" {
+		if to, err = parseTime(*toFlag); err != nil {
+			return err
+		}
+	}
+	if from.After(to) {
+		return errors.Errorf("-from %s is after -to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	st, err := openStorage(paths[0])
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	observations, err := st.FirstSeenObservations(from, to)
+	if err != nil {
+		return err
+	}
+	all := make([][]types.ExternalFirstSeen, 0, len(observations))
+	for _, obs := range observations {
+		all = append(all, obs)
+	}
+	lags := compare.SourceLags(all)
+
+	estimates := map[int64]types.FirstSeenEstimate{}
+	for dbid, obs := range observations {
+		if e, ok := compare.EstimateFirstSeen(obs, lags); ok {
+			estimates[dbid] = e
+		}
+	}
+	n, err := st.InsertFirstSeenEstimates(estimates)
+	if err != nil {
+		return err
+	}
+
+	sources := make([]string, 0, len(lags))
+	for source := range lags {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	if *jsonOutput {
+		res := make([]compare.SourceLag, len(sources))
+		for i, source := range sources {
+			res[i] = lags[source]
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	for _, source := range sources {
+		lag := lags[source]
+		fmt.Printf("%-20s common=%d median=%s p90=%s first=%.1f%%\n",
+			source, lag.Common, lag.Median, lag.P90, 100*lag.FirstRate)
+	}
+	fmt.Printf("estimated %d of %d transactions\n", n, len(observations))
+	return nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "start of the first-seen range as unix seconds or RFC3339 (default: all)")
+	toFlag := fs.String("to", "", "end of the first-seen range as unix seconds or RFC3339 (default: all)")
+	format := fs.String("format", "csv", "csv for the transactions, johoe for the mempool in the feerate buckets of "+
+		"the statistics of Jochen Hoenicke, sources for the first-seen times of all sources")
+	interval := fs.Duration("interval", time.Minute, "time between two mempool samples of -format johoe")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of goroutines reconstructing the samples of -format johoe")
+	nodeKey := fs.String("node-key", "", "replace the source labels of -format sources by pseudonyms keyed with "+
+		"this file (created if missing), e.g. to share data of several nodes without their addresses")
+	excludeDataCarrier := fs.Bool("exclude-data-carrier", false, "omit transactions with OP_RETURN outputs "+
+		"from -format csv and johoe, e.g. to keep data-embedding waves out of fee analysis")
+
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 2 {
+		return errors.New("expected a database path and an output path, - for stdout")
+	}
+	if *format != "csv" && *format != "johoe" && *format != "sources" {
+		return errors.Errorf("unknown format %q", *format)
+	}
+	if *nodeKey != "" && *format != "sources" {
+		return errors.New("-node-key requires -format sources")
+	}
+	if *excludeDataCarrier && *format == "sources" {
+		return errors.New("-exclude-data-carrier does not apply to -format sources")
+	}
+	if *format == "johoe" && (*fromFlag == "" || *toFlag == "") {
+		return errors.New("-format johoe requires -from and -to")
+	}
+	if *interval <= 0 {
+		return errors.Errorf("invalid interval %s", *interval)
+	}
+
+	q := storage.TransactionQuery{OrderBy: storage.TxOrderFirstSeen}
+	if *excludeDataCarrier {
+		q.DataCarrier = storage.FilterFalse
+	}
+	if *fromFlag != "" {
+		from, err := parseTime(*fromFlag)
+		if err != nil {
+			return err
+		}
+		q.FirstSeenFrom = &from
+	}
+	if *toFlag != "" {
+		to, err := parseTime(*toFlag)
+		if err != nil {
+			return err
+		}
+		q.FirstSeenTo = &to
+	}
+	if q.FirstSeenFrom != nil && q.FirstSeenTo != nil && q.FirstSeenFrom.After(*q.FirstSeenTo) {
+		return errors.Errorf("-from %s is after -to %s",
+			q.FirstSeenFrom.Format(time.RFC3339), q.FirstSeenTo.Format(time.RFC3339))
+	}
+
+	st, err := openStorageForQuery(paths[0])
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	out := os.Stdout
+	if paths[1] != "-" {
+		if out, err = os.Create(paths[1]); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if strings.HasSuffix(paths[1], ".gz") {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+
+	start := time.Now()
+	unit := "transactions"
+	var n int64
+	switch *format {
+	case "johoe":
+		unit = "samples"
+		callback := strings.HasSuffix(strings.TrimSuffix(paths[1], ".gz"), ".js")
+		n, err = exportJohoe(st, w, *q.FirstSeenFrom, *q.FirstSeenTo, *interval, *workers, callback, *excludeDataCarrier)
+	case "sources":
+		unit = "first-seen times"
+		var pseudonyms *privacy.NodePseudonymizer
+		if *nodeKey != "" {
+			key, err := privacy.LoadOrCreateSalt(*nodeKey)
+			if err != nil {
+				return err
+			}
+			pseudonyms = privacy.NewNodePseudonymizer(key)
+		}
+		n, err = exportObservations(st, w, q, pseudonyms)
+	default:
+		n, err = exportCSV(st, w, q)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "export failed after %d %s", n, unit)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+
+	elapsed := time.Since(start)
+	fmt.Fprintf(os.Stderr, "exported %d %s in %s (%.0f/s)\n",
+		n, unit, elapsed.Round(time.Millisecond), float64(n)/elapsed.Seconds())
+	return nil
+}
+
+// exportCSV writes the transactions matching `q` as CSV and returns their number
+func exportCSV(st *storage.Storage, w io.Writer, q storage.TransactionQuery) (int64, error) {
+	txIter, err := st.QueryTransactions(q)
+	if err != nil {
+		return 0, err
+	}
+	defer txIter.Close()
+	return exporter.WriteCSV(w, txIter)
+}
+
+// exportObservations writes the first-seen times of all sources of the transactions first seen by the
+// collector in the range of `q` and returns their number
+func exportObservations(st *storage.Storage, w io.Writer, q storage.TransactionQuery, pseudonyms *privacy.NodePseudonymizer) (int64, error) {
+	from, to := time.Unix(0, 0).UTC(), time.Now().UTC()
+	if q.FirstSeenFrom != nil {
+		from = *q.FirstSeenFrom
+	}
+	if q.FirstSeenTo != nil {
+		// FirstSeenObservations excludes the end of the range
+		to = q.FirstSeenTo.Add(time.Second)
+	}
+	observations, err := st.FirstSeenObservations(from, to)
+	if err != nil {
+		return 0, err
+	}
+	dbids := make([]int64, 0, len(observations))
+	for dbid := range observations {
+		dbids = append(dbids, dbid)
+	}
+	sort.Slice(dbids, func(i, j int) bool { return dbids[i] < dbids[j] })
+
+	ow, err := exporter.NewObservationWriter(w, pseudonyms)
+	if err != nil {
+		return 0, err
+	}
+	for _, dbid := range dbids {
+		for i := range observations[dbid] {
+			if err := ow.Write(&observations[dbid][i]); err != nil {
+				return ow.Count(), err
+			}
+		}
+	}
+	return ow.Count(), ow.Flush()
+}
+
+// exportJohoe writes the reconstructed mempool every `interval` from `from` to `to` in the feerate
+// buckets of exporter.JohoeFeeLevels with `workers` goroutines and returns the number of samples.
+// With `excludeDataCarrier`, transactions with OP_RETURN outputs are not counted.
+func exportJohoe(
+	st *storage.Storage, w io.Writer, from, to time.Time, interval time.Duration, workers int, callback bool,
+	excludeDataCarrier bool,
+) (int64, error) {
+	jw, err := exporter.NewJohoeWriter(w, callback)
+	if err != nil {
+		return 0, err
+	}
+	var times []time.Time
+	for t := from; !t.After(to); t = t.Add(interval) {
+		times = append(times, t)
+	}
+	newAccumulator := func() storage.Accumulator {
+		if excludeDataCarrier {
+			return storage.WithoutDataCarriers(exporter.NewJohoeAccumulator())
+		}
+		return exporter.NewJohoeAccumulator()
+	}
+	err = storage.ReconstructSeriesIncremental(st, times, workers, newAccumulator, func(s interface{}) error {
+		return jw.Write(s.(*exporter.JohoeSample))
+	})
+	if err != nil {
+		return jw.Count(), err
+	}
+	return jw.Count(), jw.Close()
+}
+
+func runPin(args []string) error {
+	fs := flag.NewFlagSet("pin", flag.ContinueOnError)
+	name := fs.String("name", "", "name of the snapshot")
+	atFlag := fs.String("at", "", "time of the mempool as unix seconds or RFC3339 (default: now)")
+	outPath := fs.String("out", "", "write the pinned snapshot -name to this file, - for stdout, instead of pinning")
+	list := fs.Bool("list", false, "list the pinned snapshots")
+
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		return errors.New("expected one database path")
+	}
+	if !*list && *name == "" {
+		return errors.New("expected -name or -list")
+	}
+	if *outPath != "" && *atFlag != "" {
+		return errors.New("-at cannot be combined with -out")
+	}
+
+	if *list || *outPath != "" {
+		st, err := openStorageForQuery(paths[0])
+		if err != nil {
+			return err
+		}
+		defer st.Close()
+		if *list {
+			return listPinned(st)
+		}
+		return writePinned(st, *name, *outPath)
+	}
+
+	at := time.Now().UTC()
+	if *atFlag != "" {
+		if at, err = parseTime(*atFlag); err != nil {
+			return err
+		}
+	}
+
+	st, err := openStorage(paths[0])
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	pinned, err := st.PinSnapshot(*name, at)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("pinned %s: %d transactions at %s, sha256 %s\n",
+		pinned.Name, pinned.Transactions, pinned.Time.Format(time.RFC3339), pinned.Checksum)
+	return nil
+}
+
+// listPinned prints the pinned snapshots of `st`
+func listPinned(st *storage.Storage) error {
+	snapshots, err := st.PinnedSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, p := range snapshots {
+		fmt.Printf("%-24s time=%s transactions=%d created=%s sha256=%s\n",
+			p.Name, p.Time.Format(time.RFC3339), p.Transactions, p.Created.Format(time.RFC3339), p.Checksum)
+	}
+	return nil
+}
+
+// writePinned writes the stored mempool of the pinned snapshot `name` to `path`, - for stdout
+func writePinned(st *storage.Storage, name, path string) error {
+	pinned, data, err := st.PinnedSnapshot(name)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s to %s, sha256 %s\n", pinned.Name, path, pinned.Checksum)
+	return nil
+}
+
+func runViews(args []string) error {
+	fs := flag.NewFlagSet("views", flag.ContinueOnError)
+	atFlag := fs.String("at", "", "time of the views as unix seconds or RFC3339 (default: now)")
+	cte := fs.Bool("cte", false, "print a WITH clause to prefix a query instead of CREATE TEMP VIEW statements")
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errors.New("unexpected arguments")
+	}
+
+	at := time.Now().UTC()
+	if *atFlag != "" {
+		if at, err = parseTime(*atFlag); err != nil {
+			return err
+		}
+	}
+
+	if *cte {
+		fmt.Println(storage.TimeTravelCTE(at))
+		return nil
+	}
+	for _, statement := range storage.TimeTravelViews(at) {
+		fmt.Printf("%s;\n", statement)
+	}
+	return nil
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	paths, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		return errors.New("expected one database path")
+	}
+
+	start := time.Now()
+	if err := storage.QuickCheck(paths[0]); err != nil {
+		return err
+	}
+	fmt.Printf("ok, checked in %s\n", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -2,21 +2,67 @@ package main
 
 import (
 	"flag"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/0xb10c/bademeister-go/src/analysis"
+	"github.com/0xb10c/bademeister-go/src/api"
+	"github.com/0xb10c/bademeister-go/src/bitcoinrest"
 	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
 	"github.com/0xb10c/bademeister-go/src/daemon"
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/privacy"
+	"github.com/0xb10c/bademeister-go/src/service"
+	"github.com/0xb10c/bademeister-go/src/storage"
 	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
 	log "github.com/sirupsen/logrus"
 )
 
-var zmqAddress = flag.String("zmq-address", "tcp://127.0.0.1:28332", "zmq adddress")
+var zmqAddress = flag.String("zmq-address", "tcp://127.0.0.1:28332", "zmq adddress, or auto to connect to the endpoints listed by getzmqnotifications")
 var rpcAddress = flag.String("rpc-address", "http://127.0.0.1:18443", "rpc address")
 var initBlocksRPC = flag.Bool("init-blocks-rpc", true, "backfill missed blocks via rpc")
+var restAddress = flag.String("rest-address", "", "address of the bitcoind REST interface (-rest), used instead of rpc for the initial mempool and block backfills if set")
+var ingestion = flag.String("ingestion", string(daemon.IngestionAuto), "how transactions are received: rawtxwithfee (patched node), rawtx (fees via -rpc-address), poll (poll the node mempool, blocks via rawblock) or auto (detect via getzmqnotifications)")
+var mempoolPollInterval = flag.Duration("mempool-poll-interval", daemon.DefaultMempoolPollInterval, "interval for polling the node mempool with -ingestion=poll")
+var headerFastPath = flag.Bool("header-fast-path", false, "subscribe to hashblock instead of rawblock: fetch the header of new blocks right away and the blocks in the background (requires -rpc-address or -rest-address)")
 var initMempoolRPC = flag.Bool("init-mempool-rpc", true, "fetch initial mempool via getrawmempool")
+var mempoolExpiry = flag.Duration("mempool-expiry", daemon.DefaultMempoolExpiry, "mempool expiry of the node (-mempoolexpiry), 0 to disable")
+var mempoolInfoInterval = flag.Duration("mempool-info-interval", daemon.DefaultMempoolInfoInterval, "interval for recording getmempoolinfo, 0 to disable")
+var classify = flag.Bool("heuristics", false, "classify incoming transactions (batch, consolidation, coinjoin-like, dust)")
+var trackDust = flag.Bool("dust", false, "record the dust outputs of incoming transactions per script type, thresholds are set in the config file")
+var storeDetails = flag.Bool("store-details", false, "store inputs and outputs of incoming transactions")
+var storeRaw = flag.Bool("store-raw", false, "store incoming transactions serialized, e.g. for rebroadcasting them")
+var hashOnly = flag.Bool("hash-only", false, "do not deserialize incoming transactions, only read txid, fee and sizes from the raw bytes (cannot be combined with -heuristics, -dust or -store-details)")
+var analyzers = flag.String("analyzers", "", "comma-separated analyzers run on incoming transactions and blocks, results are served by /v1/analysis (available: "+strings.Join(analysis.Names(), ", ")+")")
+var scriptHook = flag.String("script-hook", "", "Starlark script that can drop, tag and measure incoming transactions and blocks, see docs")
+var sampleRate = flag.Float64("sample-rate", 1, "fraction of the transactions to store, selected deterministically by txid, e.g. 0.1 for constrained devices; all blocks are stored")
+var packageWindow = flag.Duration("package-window", 0, "record child transactions whose unknown parent arrives within this window after them (package relay observation), 0 to disable")
+var orphanPoolSize = flag.Int("orphan-pool-size", daemon.DefaultOrphanPoolSize, "number of transactions with unknown parents kept until the parents arrive, with -store-details, 0 to disable")
+var privacySaltFile = flag.String("privacy-salt-file", "", "enable privacy mode: store salted short hashes of txids, salt is read from (or created at) this path")
+var dedupCapacity = flag.Int("dedup-capacity", daemon.DefaultDedupCapacity, "number of recent txids in the duplicate filter for incoming transactions, 0 to disable")
+var checkpointInterval = flag.Duration("checkpoint-interval", 0, "enable continuous replication (e.g. Litestream): switch to WAL mode and checkpoint the WAL at this interval, 0 to disable")
+var idCacheSize = flag.Int("id-cache-size", storage.DefaultIDCacheSize, "number of txids whose database ids are cached for confirming blocks, 0 to disable")
+var dataDir = flag.String("datadir", "", "directory for relative paths of -db, -config, -privacy-salt-file, -api-db, -api-job-dir, -snapshot-dir and -pidfile, created if missing; auto for the directory of the platform, e.g. ~/.config/bademeister (default: the working directory)")
 var dbPath = flag.String("db", "transactions.db", "path to transactions database")
+var downgradeTo = flag.Int("downgrade-to", 0, "downgrade the database schema to this version and exit")
+var quickCheck = flag.Bool("quick-check", false, "check an existing database for corruption before opening it, which reads the whole file")
+var enableIncrementalVacuum = flag.Bool("enable-incremental-vacuum", false, "enable online compaction for a database created by an older version and exit (rewrites the database)")
+var configPath = flag.String("config", "", "JSON file with settings that are reloaded on SIGHUP (logLevel, feerateFloor, retentionWindow, alerts, watch, dashboard, upload, slowQueryThreshold, dust, whales)")
+var apiAddress = flag.String("api-address", "", "address of the API server, disabled if empty")
+var apiDB = flag.String("api-db", "", "database of the API server, opened read-only: the -db file for separate connections or a replica (default: the connections of the daemon)")
+var apiJobDir = flag.String("api-job-dir", "", "directory of the jobs of /v1/jobs of the API server and their results; jobs are disabled if empty")
+var apiCORSOrigins = flag.String("api-cors-origins", "", "comma-separated origins allowed to query the API server from browsers, e.g. https://example.com, or * for any (default: none)")
+var apiCORSMethods = flag.String("api-cors-methods", "GET", "comma-separated methods allowed in cross-origin requests to the API server")
+var useMirror = flag.Bool("mirror", false, "keep the current mempool in memory and serve live API queries from it")
+var adminAddress = flag.String("admin-address", "", "address of the admin API, disabled if empty")
+var adminToken = flag.String("admin-token", os.Getenv("BADEMEISTER_ADMIN_TOKEN"), "bearer token for the admin API (default $BADEMEISTER_ADMIN_TOKEN)")
+var snapshotDir = flag.String("snapshot-dir", ".", "directory for snapshots written via the admin API")
+var pidFile = flag.String("pidfile", "", "write the process id to this file while running, disabled if empty")
+var allInOne = flag.Bool("all-in-one", false, "container mode: read the node connection from BITCOIND_RPC_* and BITCOIND_ZMQ_* environment variables, use -datadir auto and serve the API on "+daemon.AllInOneAPIAddress+" and, with an admin token, the admin API with metrics on "+daemon.AllInOneAdminAddress+"; flags that are set explicitly take precedence")
 var logLevel = flag.String("log", "info", "log level (info,debug,trace)")
 
 func main() {
@@ -26,23 +72,56 @@ func main() {
 		TimestampFormat: time.RFC3339,
 		FullTimestamp:   true,
 	})
-	switch *logLevel {
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "trace":
-		log.SetLevel(log.TraceLevel)
-	default:
-		log.Fatalf("invalid log level %q", *logLevel)
+	level, err := daemon.ParseLogLevel(*logLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetLevel(level)
+
+	if *allInOne {
+		applyAllInOne()
+	}
+
+	dir, err := service.DataDir(*dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range []*string{dbPath, configPath, privacySaltFile, apiDB, apiJobDir, snapshotDir, pidFile, scriptHook} {
+		*path = service.ResolvePath(dir, *path)
+	}
+
+	if *downgradeTo > 0 {
+		downgrade(*dbPath, *downgradeTo)
+		return
+	}
+
+	if *enableIncrementalVacuum {
+		enableCompaction(*dbPath)
+		return
+	}
+
+	if *quickCheck {
+		if _, err := os.Stat(*dbPath); err == nil {
+			log.Printf("Checking the database %s", *dbPath)
+			if err := storage.QuickCheck(*dbPath); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if *hashOnly && (*classify || *trackDust || *storeDetails) {
+		log.Fatal("-hash-only cannot be combined with -heuristics, -dust or -store-details")
 	}
 
 	log.Println("Starting Bademeister Daemon")
 	log.Printf("log level %s", *logLevel)
 
-	zmqSub, err := zmqsubscriber.NewZMQSubscriber(*zmqAddress)
-	if err != nil {
-		log.Fatalf("Could not setup ZMQ subscriber: %s", err)
+	removePidFile := func() {}
+	if *pidFile != "" {
+		removePidFile, err = service.WritePidFile(*pidFile)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	var rpcClient *bitcoinrpcclient.BitcoinRPCClient
@@ -55,22 +134,120 @@ func main() {
 		log.Debugf("connected to %s", *rpcAddress)
 	}
 
+	var restClient *bitcoinrest.Client
+	if *restAddress != "" {
+		restClient, err = bitcoinrest.NewClient(*restAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	mode, zmqSub := newZMQSubscriber(rpcClient)
+	var pollInterval time.Duration
+	if mode == daemon.IngestionPoll {
+		pollInterval = *mempoolPollInterval
+	}
+
+	var privacySalt []byte
+	if *privacySaltFile != "" {
+		privacySalt, err = privacy.LoadOrCreateSalt(*privacySaltFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	d, err := daemon.NewBademeisterDaemon(zmqSub, rpcClient, *dbPath)
 	if err != nil {
 		log.Fatal(err)
 	}
+	d.Storage().SetIDCacheSize(*idCacheSize)
 
 	go func() {
 		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
+		// SIGTERM is sent by service managers
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		s := <-c
 		log.Errorf("Received signal %s, shutting down", s)
 		d.Stop()
 	}()
 
+	if *adminAddress != "" {
+		admin, err := daemon.NewAdminServer(d, *adminToken, *snapshotDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			if err := admin.ListenAndServe(*adminAddress); err != nil {
+				log.Errorf("Admin API stopped: %s", err)
+			}
+		}()
+	}
+
+	var m *mirror.Mirror
+	if *useMirror {
+		m = mirror.New()
+	}
+
+	if *apiAddress != "" {
+		st := d.Storage()
+		if *apiDB != "" {
+			st, err = storage.OpenReadOnly(*apiDB)
+			if err != nil {
+				log.Fatalf("could not open the API database: %s", err)
+			}
+		}
+		server := api.NewServer(st)
+		server.SetCORS(api.ParseCORSConfig(*apiCORSOrigins, *apiCORSMethods))
+		server.SetStatusProvider(d)
+		if *apiJobDir != "" {
+			if err := server.EnableJobs(*apiJobDir, 1); err != nil {
+				log.Fatalf("could not enable API jobs: %s", err)
+			}
+		}
+		if m != nil {
+			server.SetMirror(m)
+		}
+		go func() {
+			if err := server.ListenAndServe(*apiAddress); err != nil {
+				log.Errorf("API stopped: %s", err)
+			}
+		}()
+	}
+
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+		for range c {
+			log.Infof("Received SIGHUP, reloading config")
+			if err := d.ReloadConfig(); err != nil {
+				log.Errorf("Could not reload config: %s", err)
+			}
+		}
+	}()
+
 	errRun := d.Run(daemon.RunParams{
-		InitMempoolRPC: *initMempoolRPC,
-		InitBlocksRPC:  *initBlocksRPC,
+		InitMempoolRPC:      *initMempoolRPC,
+		InitBlocksRPC:       *initBlocksRPC,
+		MempoolExpiry:       *mempoolExpiry,
+		MempoolInfoInterval: *mempoolInfoInterval,
+		Heuristics:          *classify,
+		TrackDust:           *trackDust,
+		StoreDetails:        *storeDetails,
+		StoreRaw:            *storeRaw,
+		PrivacySalt:         privacySalt,
+		ConfigPath:          *configPath,
+		LogLevel:            *logLevel,
+		DedupCapacity:       *dedupCapacity,
+		Mirror:              m,
+		REST:                restClient,
+		MempoolPollInterval: pollInterval,
+		PackageWindow:       *packageWindow,
+		OrphanPoolSize:      *orphanPoolSize,
+		CheckpointInterval:  *checkpointInterval,
+		HeaderFastPath:      *headerFastPath,
+		Analyzers:           splitList(*analyzers),
+		ScriptHook:          *scriptHook,
+		SampleRate:          *sampleRate,
 	})
 	if errRun != nil {
 		log.Errorf("Error during operation, shutting down: %s", errRun)
@@ -80,6 +257,7 @@ func main() {
 	if errClose != nil {
 		log.Errorf("Error during shutdown: %s", errClose)
 	}
+	removePidFile()
 
 	if errRun != nil || errClose != nil {
 		os.Exit(1)
@@ -87,3 +265,149 @@ func main() {
 
 	os.Exit(0)
 }
+
+// newZMQSubscriber selects the ingestion mode and connects to the ZMQ publisher of the node.
+// With `-zmq-address=auto`, the endpoints are discovered via `getzmqnotifications`.
+func newZMQSubscriber(rpcClient *bitcoinrpcclient.BitcoinRPCClient) (daemon.IngestionMode, *zmqsubscriber.ZMQSubscriber) {
+	mode, err := daemon.ParseIngestionMode(*ingestion)
+	if err != nil {
+		log.Fatal(err)
+	}
+	discover := *zmqAddress == "auto"
+	if mode == daemon.IngestionAuto && rpcClient == nil {
+		log.Infof("No rpc address, cannot detect the ingestion mode")
+		mode = daemon.IngestionRawTxWithFee
+	}
+	if discover && rpcClient == nil {
+		log.Fatal("-zmq-address=auto requires -rpc-address")
+	}
+
+	var notifications []bitcoinrpcclient.ZMQNotification
+	if mode == daemon.IngestionAuto || discover {
+		notifications, err = rpcClient.GetZMQNotifications()
+		if err != nil {
+			log.Fatalf("could not get ZMQ notifications of the node: %s", err)
+		}
+	}
+	if mode == daemon.IngestionAuto {
+		mode, err = daemon.DetectIngestionMode(notifications)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if mode == daemon.IngestionRawTx && rpcClient == nil {
+		log.Fatal("-ingestion=rawtx requires -rpc-address")
+	}
+	log.Infof("Ingestion mode: %s", mode)
+
+	endpoints := []string{*zmqAddress}
+	if discover {
+		rpcURL, _ := url.Parse(*rpcAddress)
+		blockTopic := zmqsubscriber.TopicRawBlock
+		if *headerFastPath {
+			blockTopic = zmqsubscriber.TopicHashBlock
+		}
+		endpoints, err = daemon.DiscoverZMQEndpoints(notifications, mode, blockTopic, rpcURL.Hostname())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Discovered ZMQ endpoints %v", endpoints)
+	}
+
+	options := append(mode.SubscriberOptions(rpcClient), zmqsubscriber.WithEndpoints(endpoints[1:]...))
+	if *headerFastPath {
+		options = append(options, zmqsubscriber.WithBlockHashes())
+	}
+	if *hashOnly {
+		options = append(options, zmqsubscriber.WithHashOnly())
+	}
+	zmqSub, err := zmqsubscriber.NewZMQSubscriber(endpoints[0], options...)
+	if err != nil {
+		log.Fatalf("Could not setup ZMQ subscriber: %s", err)
+	}
+	return mode, zmqSub
+}
+
+// downgrade reverts the schema migrations of the database at `path` to `version`
+// applyAllInOne sets the defaults of the all-in-one mode for the flags that are not set explicitly
+func applyAllInOne() {
+	env, err := daemon.ParseNodeEnv(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defaults := map[string]string{
+		"datadir":     service.DataDirAuto,
+		"api-address": daemon.AllInOneAPIAddress,
+	}
+	if env.RPCAddress != "" {
+		defaults["rpc-address"] = env.RPCAddress
+	}
+	if env.ZMQAddress != "" {
+		defaults["zmq-address"] = env.ZMQAddress
+	}
+	if *adminToken != "" {
+		defaults["admin-address"] = daemon.AllInOneAdminAddress
+	}
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for name, value := range defaults {
+		if !set[name] {
+			if err := flag.Set(name, value); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	log.Infof("All-in-one mode: rpc %s, zmq %s, API %s", redactURL(*rpcAddress), *zmqAddress, *apiAddress)
+}
+
+// redactURL returns `address` without the password
+func redactURL(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.User == nil {
+		return address
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// splitList returns the non-empty elements of a comma-separated list
+func splitList(v string) []string {
+	var res []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func downgrade(path string, version int) {
+	st, err := storage.NewStorage(path)
+	if err != nil {
+		log.Fatalf("could not open storage: %s", err)
+	}
+	defer st.Close()
+
+	if err := st.Downgrade(version); err != nil {
+		log.Fatalf("could not downgrade database: %s", err)
+	}
+	log.Printf("Downgraded database to version %d", version)
+}
+
+// enableCompaction enables incremental vacuum for the database at `path`
+func enableCompaction(path string) {
+	st, err := storage.NewStorage(path)
+	if err != nil {
+		log.Fatalf("could not open storage: %s", err)
+	}
+	defer st.Close()
+
+	log.Printf("Enabling incremental vacuum, this rewrites the database")
+	if err := st.EnableIncrementalVacuum(); err != nil {
+		log.Fatalf("could not enable incremental vacuum: %s", err)
+	}
+	log.Printf("Enabled incremental vacuum")
+}
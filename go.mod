@@ -5,10 +5,12 @@ go 1.13
 require (
 	github.com/btcsuite/btcd v0.20.1-beta
 	github.com/btcsuite/btcutil v0.0.0-20190425235716-9e5f4b9a998d
+	github.com/btcsuite/websocket v0.0.0-20150119174127-31079b680792
 	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/pebbe/zmq4 v1.0.0
 	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/stretchr/testify v1.4.0
+	go.starlark.net v0.0.0-20190702223751-32f345186213
 	golang.org/x/tools v0.0.0-20191219041853-979b82bfef62 // indirect
 )
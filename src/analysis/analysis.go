@@ -0,0 +1,126 @@
+// Package analysis runs custom metrics on incoming transactions and blocks.
+//
+// An Analyzer is registered with Register, usually in an init function of the file that defines it,
+// and enabled by name with the `-analyzers` flag of the daemon. Its results are stored with the
+// transaction or block in the `analysis_result` table and served by `/v1/analysis`.
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Mempool is read access to the current mempool, implemented by mirror.Mirror
+type Mempool interface {
+	// Get returns the transaction with `txid`
+	Get(txid types.Hash32) (types.Transaction, bool)
+	// Len returns the number of transactions
+	Len() int
+}
+
+// Analyzer computes results for incoming transactions and blocks.
+// The methods are called from a single goroutine and should return quickly, they delay ingestion.
+type Analyzer interface {
+	// AnalyzeTransaction is called for each new transaction before it is stored and added to the mempool.
+	// The inputs and outputs are only known for transactions received via ZMQ (see types.TxDetails).
+	AnalyzeTransaction(tx *types.Transaction, mempool Mempool) ([]types.AnalysisResult, error)
+	// AnalyzeBlock is called for each new block before its transactions are removed from the mempool
+	AnalyzeBlock(block *types.Block, mempool Mempool) ([]types.AnalysisResult, error)
+}
+
+// registry holds the constructors of the registered analyzers by name
+var registry = map[string]func() Analyzer{}
+
+// Register makes an analyzer available under `name`. Panics if the name is taken.
+func Register(name string, factory func() Analyzer) {
+	if _, ok := registry[name]; ok {
+		panic("analysis: duplicate analyzer " + name)
+	}
+	registry[name] = factory
+}
+
+// Names returns the names of the registered analyzers in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedAnalyzer is an enabled analyzer
+type namedAnalyzer struct {
+	name     string
+	analyzer Analyzer
+}
+
+// Runner runs the enabled analyzers and attaches their results to transactions and blocks
+type Runner struct {
+	analyzers []namedAnalyzer
+	// nil if the current mempool is not kept in memory
+	mempool Mempool
+}
+
+// NewRunner returns a Runner of the registered analyzers with `names`.
+// `mempool` is passed to the analyzers, it can be nil.
+func NewRunner(names []string, mempool Mempool) (*Runner, error) {
+	r := &Runner{mempool: mempool}
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, errors.Errorf("unknown analyzer %q, available: %s", name, strings.Join(Names(), ", "))
+		}
+		r.analyzers = append(r.analyzers, namedAnalyzer{name: name, analyzer: factory()})
+	}
+	return r, nil
+}
+
+// run calls `f` and sets the analyzer of its results. Errors and panics are logged,
+// an analyzer does not stop ingestion.
+func (a *namedAnalyzer) run(f func() ([]types.AnalysisResult, error)) (res []types.AnalysisResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Analyzer %s panicked: %v", a.name, r)
+			res = nil
+		}
+	}()
+
+	res, err := f()
+	if err != nil {
+		log.Errorf("Analyzer %s: %s", a.name, err)
+		return nil
+	}
+	for i := range res {
+		res[i].Analyzer = a.name
+	}
+	return res
+}
+
+// Transactions sets the Analysis of `txs`
+func (r *Runner) Transactions(txs []types.Transaction) {
+	for i := range txs {
+		tx := &txs[i]
+		for j := range r.analyzers {
+			a := &r.analyzers[j]
+			tx.Analysis = append(tx.Analysis, a.run(func() ([]types.AnalysisResult, error) {
+				return a.analyzer.AnalyzeTransaction(tx, r.mempool)
+			})...)
+		}
+	}
+}
+
+// Block sets the Analysis of `block`
+func (r *Runner) Block(block *types.Block) {
+	for j := range r.analyzers {
+		a := &r.analyzers[j]
+		block.Analysis = append(block.Analysis, a.run(func() ([]types.AnalysisResult, error) {
+			return a.analyzer.AnalyzeBlock(block, r.mempool)
+		})...)
+	}
+}
@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// failing returns an error for transactions and panics for blocks
+type failing struct{}
+
+func (failing) AnalyzeTransaction(*types.Transaction, Mempool) ([]types.AnalysisResult, error) {
+	return nil, errors.New("failed")
+}
+
+func (failing) AnalyzeBlock(*types.Block, Mempool) ([]types.AnalysisResult, error) {
+	panic("failed")
+}
+
+func TestRunner(t *testing.T) {
+	Register("test-failing", func() Analyzer { return failing{} })
+	assert.Panics(t, func() { Register("test-failing", func() Analyzer { return failing{} }) })
+	assert.Contains(t, Names(), "mempool-parents")
+
+	_, err := NewRunner([]string{"unknown"}, nil)
+	assert.Error(t, err)
+
+	parent := types.Transaction{TxID: test.GenerateHash32("parent"), Weight: 400}
+	mempool := mirror.New()
+	mempool.Add(parent)
+
+	r, err := NewRunner([]string{"test-failing", "mempool-parents", "mempool-coverage"}, mempool)
+	require.NoError(t, err)
+
+	txs := []types.Transaction{
+		{
+			TxID: test.GenerateHash32("child"),
+			Details: &types.TxDetails{Inputs: []types.TxInput{
+				{PrevTxID: parent.TxID}, {PrevTxID: test.GenerateHash32("confirmed")},
+			}},
+		},
+		// inputs unknown
+		{TxID: test.GenerateHash32("hash-only")},
+	}
+	r.Transactions(txs)
+	assert.Equal(t, []types.AnalysisResult{{Analyzer: "mempool-parents", Key: "parents", Value: 1}}, txs[0].Analysis)
+	assert.Nil(t, txs[1].Analysis)
+
+	block := types.Block{TxIDs: []types.Hash32{
+		test.GenerateHash32("coinbase"), parent.TxID, test.GenerateHash32("unknown"),
+	}}
+	r.Block(&block)
+	assert.Equal(t, []types.AnalysisResult{
+		{Analyzer: "mempool-coverage", Key: "mempool", Value: 1},
+		{Analyzer: "mempool-coverage", Key: "coverage", Value: 0.5},
+	}, block.Analysis)
+
+	// without mempool
+	r, err = NewRunner([]string{"mempool-parents", "mempool-coverage"}, nil)
+	require.NoError(t, err)
+	block.Analysis = nil
+	r.Block(&block)
+	assert.Nil(t, block.Analysis)
+}
@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func init() {
+	Register("mempool-parents", func() Analyzer { return mempoolParents{} })
+	Register("mempool-coverage", func() Analyzer { return mempoolCoverage{} })
+}
+
+// mempoolParents counts the inputs of a transaction that spend unconfirmed outputs of the mempool
+// (key `parents`). Requires the inputs of the transaction and the mempool.
+type mempoolParents struct{}
+
+func (mempoolParents) AnalyzeTransaction(tx *types.Transaction, mempool Mempool) ([]types.AnalysisResult, error) {
+	if tx.Details == nil || mempool == nil {
+		return nil, nil
+	}
+	parents := 0
+	for _, in := range tx.Details.Inputs {
+		if _, ok := mempool.Get(in.PrevTxID); ok {
+			parents++
+		}
+	}
+	return []types.AnalysisResult{{Key: "parents", Value: float64(parents)}}, nil
+}
+
+func (mempoolParents) AnalyzeBlock(*types.Block, Mempool) ([]types.AnalysisResult, error) {
+	return nil, nil
+}
+
+// mempoolCoverage records the size of the mempool when a block arrives (key `mempool`) and the share of
+// the transactions of the block, except the coinbase, that were in it (key `coverage`). Requires the mempool.
+type mempoolCoverage struct{}
+
+func (mempoolCoverage) AnalyzeTransaction(*types.Transaction, Mempool) ([]types.AnalysisResult, error) {
+	return nil, nil
+}
+
+func (mempoolCoverage) AnalyzeBlock(block *types.Block, mempool Mempool) ([]types.AnalysisResult, error) {
+	if mempool == nil {
+		return nil, nil
+	}
+	res := []types.AnalysisResult{{Key: "mempool", Value: float64(mempool.Len())}}
+	if len(block.TxIDs) > 1 {
+		known := 0
+		for _, txid := range block.TxIDs[1:] {
+			if _, ok := mempool.Get(txid); ok {
+				known++
+			}
+		}
+		res = append(res, types.AnalysisResult{Key: "coverage", Value: float64(known) / float64(len(block.TxIDs)-1)})
+	}
+	return res, nil
+}
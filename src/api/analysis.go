@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleAnalysis implements `GET /v1/analysis`. Returns the stored results of the analyzers
+// of the daemon, most recent first. Supported query parameters:
+//
+//	analyzer:   name of the analyzer
+//	key:        name of the result
+//	txid:       results of the transaction
+//	block:      results of the block with the hash
+//	from, to:   range of the first-seen time of the transaction or block
+//	limit:      maximum number of results
+func (s *Server) handleAnalysis(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseAnalysisQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	results, err := s.storage.AnalysisResults(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// parseAnalysisQuery returns the storage query for the parameters of `GET /v1/analysis`
+func parseAnalysisQuery(r *http.Request) (storage.AnalysisQuery, error) {
+	q := storage.AnalysisQuery{
+		Analyzer: r.URL.Query().Get("analyzer"),
+		Key:      r.URL.Query().Get("key"),
+	}
+	var err error
+
+	for _, p := range []struct {
+		name string
+		hash **types.Hash32
+	}{{"txid", &q.TxID}, {"block", &q.Block}} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		hash, err := types.NewHashFromString(v)
+		if err != nil {
+			return q, errInvalidParam(p.name, v)
+		}
+		*p.hash = &hash
+	}
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.From = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.To = &to
+	}
+	if q.From != nil && q.To != nil && q.From.After(*q.To) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
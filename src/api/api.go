@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/jobs"
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+const (
+	defaultLimit = 25
+	maxLimit     = 1000
+)
+
+// Server serves the bademeister HTTP API
+type Server struct {
+	storage *storage.Storage
+	// serves the current mempool if set
+	mirror *mirror.Mirror
+	// reports the daemon in `/v1/status` if set
+	status StatusProvider
+	// runs the jobs of `/v1/jobs` if set
+	jobs      *jobs.Queue
+	histogram *histogramBroadcaster
+	// histogram of `/v1/mempool/histogram?exclude-data-carrier=true`
+	histogramNoDataCarriers *histogramBroadcaster
+	cors                    CORSConfig
+	upgrader                websocket.Upgrader
+	mux                     *http.ServeMux
+}
+
+// NewServer returns a Server that reads data from `st`
+func NewServer(st *storage.Storage) *Server {
+	s := &Server{
+		storage:  st,
+		upgrader: upgrader,
+		mux:      http.NewServeMux(),
+	}
+	s.histogram = newHistogramBroadcaster(DefaultHistogramInterval, func() (*types.FeerateHistogram, error) {
+		return s.currentHistogram(false)
+	})
+	s.histogramNoDataCarriers = newHistogramBroadcaster(DefaultHistogramInterval, func() (*types.FeerateHistogram, error) {
+		return s.currentHistogram(true)
+	})
+
+	s.mux.HandleFunc("/v1/status", s.handleStatus)
+	s.mux.HandleFunc("/v1/search", s.handleSearch)
+	s.mux.HandleFunc("/v1/tx/", s.handleTransaction)
+	s.mux.HandleFunc("/v1/transactions", s.handleTransactions)
+	s.mux.HandleFunc("/v1/whales", s.handleWhales)
+	s.mux.HandleFunc("/v1/analysis", s.handleAnalysis)
+	s.mux.HandleFunc("/v1/witness-replacements", s.handleWitnessReplacements)
+	s.mux.HandleFunc("/v1/fee-updates", s.handleFeeUpdates)
+	s.mux.HandleFunc("/v1/reorgs", s.handleReorgs)
+	s.mux.HandleFunc("/v1/double-spends", s.handleDoubleSpends)
+	s.mux.HandleFunc("/v1/snapshots", s.handleSnapshots)
+	s.mux.HandleFunc("/v1/snapshots/", s.handleSnapshots)
+	s.mux.HandleFunc("/v1/blocks/", s.handleBlocks)
+	s.mux.HandleFunc("/v1/mempool", s.handleMempool)
+	s.mux.HandleFunc("/v1/mempool/summary", s.handleMempoolSummary)
+	s.mux.HandleFunc("/v1/mempool/top", s.handleMempoolTop)
+	s.mux.HandleFunc("/v1/mempool/stuck", s.handleMempoolStuck)
+	s.mux.HandleFunc("/v1/mempool/histogram", s.handleHistogram)
+	s.mux.HandleFunc("/v1/events", s.handleEvents)
+	s.mux.HandleFunc("/v1/stats/heuristics", s.handleHeuristicStats)
+	s.mux.HandleFunc("/v1/stats/block-share", s.handleBlockShareStats)
+	s.mux.HandleFunc("/v1/stats/consolidations", s.handleConsolidationReport)
+	s.mux.HandleFunc("/v1/stats/opreturn", s.handleOpReturnTrend)
+	s.mux.HandleFunc("/v1/stats/dust", s.handleDustReport)
+	s.mux.HandleFunc("/v1/stats/transactions", s.handleTransactionAggregates)
+	s.mux.HandleFunc("/v1/stats/value-flow", s.handleValueFlow)
+	s.mux.HandleFunc("/v1/stats/witness-heavy", s.handleWitnessHeavySeries)
+	s.mux.HandleFunc("/v1/stats/blocks", s.handleMinerBlockStats)
+	s.mux.HandleFunc("/v1/stats/overpayment", s.handleOverpaymentStats)
+	s.mux.HandleFunc("/v1/stats/cpfp", s.handleCPFPStats)
+	s.mux.HandleFunc("/v1/stats/block-intervals", s.handleBlockIntervals)
+	s.mux.HandleFunc("/v1/stats/drain", s.handleBlockDrains)
+	s.mux.HandleFunc("/v1/stats/utxo", s.handleUTXODeltas)
+	s.mux.HandleFunc("/v1/stats/packages", s.handlePackageEvents)
+	s.mux.HandleFunc("/v1/stats/orphans", s.handleOrphanResolutions)
+	s.mux.HandleFunc("/v1/stats/difficulty", s.handleDifficultyEpochs)
+	s.mux.HandleFunc("/v1/stats/imported-mempool", s.handleImportedHistograms)
+	s.mux.HandleFunc("/v1/grafana/", s.handleGrafana)
+	s.mux.HandleFunc("/", s.handleUI)
+
+	return s
+}
+
+// SetMirror serves queries of the current mempool from `m` instead of reconstructing it from storage.
+// Must be called before serving requests.
+func (s *Server) SetMirror(m *mirror.Mirror) {
+	s.mirror = m
+}
+
+// SetHistogramInterval sets the interval between two feerate histograms sent by `/v1/mempool/histogram`.
+// Must be called before serving requests.
+func (s *Server) SetHistogramInterval(interval time.Duration) {
+	s.histogram.interval = interval
+	s.histogramNoDataCarriers.interval = interval
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("%s %s", r.Method, r.URL)
+	if s.serveCORS(w, r) {
+		return
+	}
+	serveCompressed(s.mux, w, r)
+}
+
+// ListenAndServe serves the API on `address`
+func (s *Server) ListenAndServe(address string) error {
+	log.Infof("API listening on %s", address)
+	return http.ListenAndServe(address, s)
+}
+
+// errorResponse is the body of responses with non-2xx status
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("error writing response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.Errorf("API error: %s", err)
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// requireGET writes an error response and returns false if the request method is not GET
+func requireGET(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return false
+	}
+	return true
+}
+
+// requirePOST writes an error response and returns false if the request method is not POST
+func requirePOST(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return false
+	}
+	return true
+}
+
+// parseLimit returns the `limit` query parameter, bounded by `maxLimit`
+func parseLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, errInvalidParam("limit", v)
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit, nil
+}
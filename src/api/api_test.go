@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func storagePath() string {
+	// The environment variable `TEST_INTEGRATION_DIR` is set to a temporary
+	// directory created by the Makefile in the target `test-integration`.
+	return os.Getenv("TEST_INTEGRATION_DIR") + "/api.db"
+}
+
+// newTestServer returns a Server backed by an empty storage
+func newTestServer(t *testing.T) (*Server, *storage.Storage) {
+	test.SkipIfShort(t)
+
+	if err := os.Remove(storagePath()); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("could not reset api.db: %s", err)
+	}
+	st, err := storage.NewStorage(storagePath())
+	require.NoError(t, err)
+	return NewServer(st), st
+}
+
+// get performs a GET request and decodes the JSON response into `v`
+func get(t *testing.T, handler http.Handler, url string, v interface{}) int {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(v), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestErrorStatus(t *testing.T) {
+	assert.Equal(t, http.StatusBadRequest, errorStatus(errInvalidParam("limit", "x")))
+	assert.Equal(t, http.StatusBadRequest, errorStatus(errors.Wrap(types.ErrParse, "invalid hash")))
+	assert.Equal(t, http.StatusNotFound, errorStatus(errors.Wrap(types.ErrNotFound, "transaction")))
+	assert.Equal(t, http.StatusServiceUnavailable, errorStatus(errors.Wrap(types.ErrStorageBusy, "insert")))
+	assert.Equal(t, http.StatusInternalServerError, errorStatus(errors.New("disk full")))
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleBlocks implements the endpoints below `/v1/blocks/`:
+//
+//	GET /v1/blocks/tip
+//	GET /v1/blocks/height/{height}
+//	GET /v1/blocks/{hash}
+//	GET /v1/blocks/{hash}/ancestors
+//	GET /v1/blocks/{hash}/common-ancestor/{hash}
+//	GET /v1/blocks/{hash}/transactions
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "tip":
+		block, err := s.storage.GetBestBlock()
+		s.writeBlock(w, block, err, "best block")
+	case len(parts) == 2 && parts[0] == "height":
+		height, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errInvalidParam("height", parts[1]))
+			return
+		}
+		block, err := s.storage.GetBlockByHeight(uint32(height))
+		s.writeBlock(w, block, err, "block at height "+parts[1])
+	case len(parts) == 1:
+		hash, ok := parseBlockHash(w, parts[0])
+		if !ok {
+			return
+		}
+		block, err := s.storage.BlockByHash(hash)
+		s.writeBlock(w, block, err, "block "+parts[0])
+	case len(parts) == 2 && parts[1] == "ancestors":
+		s.handleBlockAncestors(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "common-ancestor":
+		s.handleCommonAncestor(w, parts[0], parts[2])
+	case len(parts) == 2 && parts[1] == "transactions":
+		s.handleBlockTransactions(w, r, parts[0])
+	default:
+		err := errors.Wrapf(types.ErrNotFound, "path %s", r.URL.Path)
+		writeError(w, errorStatus(err), err)
+	}
+}
+
+// parseBlockHash parses the block hash `v` of a path or writes an error response
+func parseBlockHash(w http.ResponseWriter, v string) (types.Hash32, bool) {
+	hash, err := types.NewHashFromString(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidParam("hash", v))
+		return hash, false
+	}
+	return hash, true
+}
+
+// writeBlock writes `block`, or an error response if `err` is set or the block `name` was not found
+func (s *Server) writeBlock(w http.ResponseWriter, block *types.StoredBlock, err error, name string) {
+	if err == nil && block == nil {
+		err = errors.Wrap(types.ErrNotFound, name)
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	blocks := []types.StoredBlock{*block}
+	if err := s.setBlockConfirmations(blocks); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, blocks[0].Block)
+}
+
+// handleBlockAncestors implements `GET /v1/blocks/{hash}/ancestors`. Returns the block and its
+// ancestors, newest first, at most `limit`.
+func (s *Server) handleBlockAncestors(w http.ResponseWriter, r *http.Request, v string) {
+	hash, ok := parseBlockHash(w, v)
+	if !ok {
+		return
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	chain, err := s.storage.WalkChain(hash, limit)
+	if err == nil {
+		err = s.setBlockConfirmations(chain)
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	res := make([]types.Block, len(chain))
+	for i, block := range chain {
+		res[i] = block.Block
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleCommonAncestor implements `GET /v1/blocks/{hash}/common-ancestor/{hash}`
+func (s *Server) handleCommonAncestor(w http.ResponseWriter, va, vb string) {
+	var blocks [2]*types.StoredBlock
+	for i, v := range []string{va, vb} {
+		hash, ok := parseBlockHash(w, v)
+		if !ok {
+			return
+		}
+		block, err := s.storage.BlockByHash(hash)
+		if err == nil && block == nil {
+			err = errors.Wrapf(types.ErrNotFound, "block %s", v)
+		}
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		blocks[i] = block
+	}
+
+	ancestor, err := s.storage.CommonAncestor(blocks[0], blocks[1])
+	s.writeBlock(w, ancestor, err, "common ancestor")
+}
+
+// handleBlockTransactions implements `GET /v1/blocks/{hash}/transactions`. Returns the stored
+// transactions of the block in block order. Supported query parameters:
+//
+//	after: the `next` value of the previous page
+//	limit: maximum number of transactions, all with `Accept: text/csv`
+func (s *Server) handleBlockTransactions(w http.ResponseWriter, r *http.Request, v string) {
+	hash, ok := parseBlockHash(w, v)
+	if !ok {
+		return
+	}
+
+	fromIndex := 0
+	if after := r.URL.Query().Get("after"); after != "" {
+		index, err := strconv.Atoi(after)
+		if err != nil || index < 0 {
+			writeError(w, http.StatusBadRequest, errInvalidParam("after", after))
+			return
+		}
+		fromIndex = index + 1
+	}
+	parse := parseLimit
+	if acceptsCSV(r) {
+		parse = parseCSVLimit
+	}
+	limit, err := parse(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	txs, err := s.storage.GetBlockTransactions(hash, fromIndex, limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if err := s.setConfirmations(txs); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	res := types.TransactionList{Transactions: make([]types.Transaction, len(txs))}
+	for i, tx := range txs {
+		res.Transactions[i] = tx.Transaction
+	}
+	if limit > 0 && len(txs) == limit {
+		res.Next = strconv.Itoa(int(txs[len(txs)-1].IndexInBlock))
+	}
+	writeTransactionsJSON(w, r, http.StatusOK, res)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_BlockTransactions(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	var txs []types.Transaction
+	for i := 1; i <= 3; i++ {
+		txs = append(txs, types.Transaction{
+			TxID:      test.GenerateHash32(fmt.Sprintf("tx-block-%d", i)),
+			FirstSeen: time.Unix(int64(100*i), 0).UTC(),
+		})
+	}
+	_, err := st.InsertTransactions(txs)
+	require.NoError(t, err)
+	block := types.Block{
+		Hash:      test.GenerateHash32("block-txs"),
+		FirstSeen: time.Unix(1000, 0).UTC(),
+		Height:    5,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{test.GenerateHash32("coinbase"), txs[0].TxID, txs[1].TxID, txs[2].TxID},
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	url := "/v1/blocks/" + block.Hash.String() + "/transactions"
+	var res types.TransactionList
+	require.Equal(t, http.StatusOK, get(t, server, url, &res))
+	require.Len(t, res.Transactions, 3)
+	for i, tx := range res.Transactions {
+		assert.Equal(t, txs[i].TxID, tx.TxID)
+		assert.Equal(t, int32(i+1), tx.IndexInBlock)
+		assert.Equal(t, int32(5), tx.BlockHeight)
+	}
+	assert.Empty(t, res.Next)
+
+	// pages of two transactions
+	res = types.TransactionList{}
+	require.Equal(t, http.StatusOK, get(t, server, url+"?limit=2", &res))
+	require.Len(t, res.Transactions, 2)
+	assert.Equal(t, "2", res.Next)
+	res = types.TransactionList{}
+	require.Equal(t, http.StatusOK, get(t, server, url+"?limit=2&after=2", &res))
+	require.Len(t, res.Transactions, 1)
+	assert.Equal(t, txs[2].TxID, res.Transactions[0].TxID)
+	assert.Empty(t, res.Next)
+
+	unknown := test.GenerateHash32("unknown-block").String()
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/blocks/"+unknown+"/transactions", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/blocks/xyz/transactions", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, server, url+"?after=x", nil))
+}
+
+func TestServer_ChainView(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	// chain 0 <- 1 <- 2 and the competing tip 2b, received last
+	hash := func(name string) types.Hash32 {
+		return test.GenerateHash32("chain-" + name)
+	}
+	blocks := []types.Block{
+		{Hash: hash("0"), Height: 0},
+		{Hash: hash("1"), Parent: hash("0"), Height: 1},
+		{Hash: hash("2"), Parent: hash("1"), Height: 2},
+		{Hash: hash("2b"), Parent: hash("1"), Height: 2},
+	}
+	for i, block := range blocks {
+		block.FirstSeen = time.Unix(int64(100*(i+1)), 0).UTC()
+		block.IsBest = true
+		_, err := st.InsertBlock(&block)
+		require.NoError(t, err)
+	}
+
+	var block types.Block
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/blocks/tip", &block))
+	assert.Equal(t, hash("2b"), block.Hash)
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/blocks/height/1", &block))
+	assert.Equal(t, hash("1"), block.Hash)
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/blocks/"+hash("2").String(), &block))
+	assert.Equal(t, uint32(2), block.Height)
+	require.Equal(t, http.StatusOK, get(t, server,
+		"/v1/blocks/"+hash("2").String()+"/common-ancestor/"+hash("2b").String(), &block))
+	assert.Equal(t, hash("1"), block.Hash)
+
+	var chain []types.Block
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/blocks/"+hash("2b").String()+"/ancestors?limit=2", &chain))
+	require.Len(t, chain, 2)
+	assert.Equal(t, hash("2b"), chain[0].Hash)
+	assert.Equal(t, hash("1"), chain[1].Hash)
+
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/blocks/height/3", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/blocks/"+hash("unknown").String(), nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/blocks/height/x", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/blocks/"+hash("2").String()+"/unknown", nil))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipWriters are reused, a gzip.Writer allocates about 800KB
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(ioutil.Discard)
+	},
+}
+
+// gzipResponseWriter compresses the body written to the embedded http.ResponseWriter
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.gz.Write(b)
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	// the length of the uncompressed body
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// acceptsGzip returns true if the client accepts gzip-encoded responses
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(coding, ";")
+		name := strings.TrimSpace(parts[0])
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		// `gzip;q=0` rejects gzip
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// serveCompressed serves the request with `h` and compresses the response with gzip if the
+// client accepts it. Websocket upgrades are not compressed.
+func serveCompressed(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) || r.Header.Get("Upgrade") != "" {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	gz := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(gz)
+	gz.Reset(w)
+	defer gz.Close()
+
+	w.Header().Set("Content-Encoding", "gzip")
+	h.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, gz: gz}, r)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                       false,
+		"identity":               false,
+		"gzip":                   true,
+		"deflate, gzip;q=0.5":    true,
+		"gzip;q=0, deflate":      false,
+		"*":                      true,
+		"br ,  gzip , deflate":   true,
+		"x-gzip;q=1.0, identity": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		assert.Equal(t, expected, acceptsGzip(req), header)
+	}
+}
+
+func TestServer_Compression(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	// uncompressed without Accept-Encoding
+	var summary types.MempoolSummary
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/summary?at=100", &summary))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/mempool/summary?at=100", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	var decompressed types.MempoolSummary
+	require.NoError(t, json.NewDecoder(gz).Decode(&decompressed))
+	assert.Equal(t, summary, decompressed)
+
+	// errors are compressed as well
+	req = httptest.NewRequest(http.MethodGet, "/v1/mempool/summary?at=x", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	gz, err = gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	var apiErr map[string]string
+	require.NoError(t, json.NewDecoder(gz).Decode(&apiErr))
+	assert.Contains(t, apiErr["error"], "at")
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// confirmations returns the number of best-chain blocks from the one at `height` to `tip`
+func confirmations(tip *types.StoredBlock, height uint32) int {
+	if tip == nil || height > tip.Height {
+		return 0
+	}
+	return int(tip.Height-height) + 1
+}
+
+// setConfirmations sets the confirmations of `txs` for the current best chain
+func (s *Server) setConfirmations(txs []types.StoredTransaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	tip, err := s.storage.GetBestBlock()
+	if err != nil {
+		return err
+	}
+	dbids := make([]int64, len(txs))
+	for i, tx := range txs {
+		dbids[i] = tx.DBID
+	}
+	heights, err := s.storage.BestChainHeights(dbids)
+	if err != nil {
+		return err
+	}
+
+	for i := range txs {
+		n := 0
+		if height, ok := heights[txs[i].DBID]; ok {
+			n = confirmations(tip, height)
+		}
+		txs[i].Confirmations = &n
+	}
+	return nil
+}
+
+// setBlockConfirmations sets the confirmations of `blocks` for the current best chain
+func (s *Server) setBlockConfirmations(blocks []types.StoredBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	tip, err := s.storage.GetBestBlock()
+	if err != nil {
+		return err
+	}
+
+	for i := range blocks {
+		n := -1
+		if blocks[i].InBestChain {
+			n = confirmations(tip, blocks[i].Height)
+		}
+		blocks[i].Confirmations = &n
+	}
+	return nil
+}
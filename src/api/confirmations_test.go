@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_Confirmations(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	tx := types.Transaction{TxID: test.GenerateHash32("tx-conf"), FirstSeen: time.Unix(10, 0).UTC()}
+	_, err := st.InsertTransaction(&tx)
+	require.NoError(t, err)
+
+	hash := func(name string) types.Hash32 {
+		return test.GenerateHash32("conf-" + name)
+	}
+	insert := func(blocks ...types.Block) {
+		for _, block := range blocks {
+			block.FirstSeen = time.Unix(int64(100*(block.Height+1)), 0).UTC()
+			_, err := st.InsertBlock(&block)
+			require.NoError(t, err)
+		}
+	}
+	getTx := func() (res types.Transaction) {
+		require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+tx.TxID.String(), &res))
+		require.NotNil(t, res.Confirmations)
+		return res
+	}
+	getBlock := func(name string) (res types.Block) {
+		require.Equal(t, http.StatusOK, get(t, server, "/v1/blocks/"+hash(name).String(), &res))
+		require.NotNil(t, res.Confirmations)
+		return res
+	}
+
+	assert.Equal(t, 0, *getTx().Confirmations)
+
+	insert(
+		types.Block{Hash: hash("a"), Height: 0, IsBest: true},
+		types.Block{Hash: hash("b"), Parent: hash("a"), Height: 1, IsBest: true, TxIDs: []types.Hash32{tx.TxID}},
+		types.Block{Hash: hash("c"), Parent: hash("b"), Height: 2, IsBest: true},
+	)
+	assert.Equal(t, 2, *getTx().Confirmations)
+	assert.Equal(t, 3, *getBlock("a").Confirmations)
+
+	var status types.TxStatus
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+tx.TxID.String()+"/status", &status))
+	require.NotNil(t, status.Confirmations)
+	assert.Equal(t, 2, *status.Confirmations)
+
+	var list types.TransactionList
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/transactions", &list))
+	require.Len(t, list.Transactions, 1)
+	assert.Equal(t, 2, *list.Transactions[0].Confirmations)
+
+	// a longer chain without the transaction replaces "b" and "c"
+	insert(
+		types.Block{Hash: hash("b2"), Parent: hash("a"), Height: 1},
+		types.Block{Hash: hash("c2"), Parent: hash("b2"), Height: 2},
+		types.Block{Hash: hash("d2"), Parent: hash("c2"), Height: 3, IsBest: true},
+	)
+	assert.Equal(t, 0, *getTx().Confirmations)
+	assert.Equal(t, -1, *getBlock("b").Confirmations)
+	assert.Equal(t, 4, *getBlock("a").Confirmations)
+	assert.Equal(t, 1, *getBlock("d2").Confirmations)
+}
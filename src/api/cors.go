@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsMaxAge is how long browsers may cache the result of a preflight request
+const corsMaxAge = 10 * time.Minute
+
+// CORSConfig configures Cross-Origin Resource Sharing, which allows scripts on other origins
+// (e.g. a dashboard at https://example.com) to read API responses
+type CORSConfig struct {
+	// Origins allowed to read responses, e.g. `https://example.com`, or `*` for any origin.
+	// Cross-origin requests are not allowed if empty.
+	AllowedOrigins []string
+	// Methods allowed in cross-origin requests, GET if empty
+	AllowedMethods []string
+}
+
+// ParseCORSConfig returns a CORSConfig from comma-separated lists of origins and methods
+func ParseCORSConfig(origins, methods string) CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: splitList(origins),
+		AllowedMethods: splitList(methods),
+	}
+}
+
+// splitList returns the non-empty elements of a comma-separated list
+func splitList(v string) []string {
+	var res []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// enabled returns true if cross-origin requests are allowed from any origin
+func (c CORSConfig) enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// allowsOrigin returns true if scripts on `origin` may read responses
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (c CORSConfig) methods() []string {
+	if len(c.AllowedMethods) == 0 {
+		return []string{http.MethodGet}
+	}
+	return c.AllowedMethods
+}
+
+// SetCORS allows the cross-origin requests configured by `c`, including WebSocket connections.
+// Must be called before serving requests.
+func (s *Server) SetCORS(c CORSConfig) {
+	s.cors = c
+	s.upgrader.CheckOrigin = s.checkWebsocketOrigin
+}
+
+// serveCORS sets the CORS response headers for a request from an allowed origin.
+// Returns true if the request was a preflight request, which is answered completely.
+func (s *Server) serveCORS(w http.ResponseWriter, r *http.Request) bool {
+	if !s.cors.enabled() {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" || !s.cors.allowsOrigin(origin) {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Expose-Headers", "X-Resolution")
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(s.cors.methods(), ", "))
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// checkWebsocketOrigin accepts WebSocket connections from the same origin and from allowed origins.
+// Browsers do not apply CORS to WebSockets, the server must check the origin itself.
+func (s *Server) checkWebsocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return s.cors.allowsOrigin(origin)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCORSConfig(t *testing.T) {
+	c := ParseCORSConfig(" https://a.example, https://b.example,", "")
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, c.AllowedOrigins)
+	assert.Equal(t, []string{http.MethodGet}, c.methods())
+	assert.True(t, c.allowsOrigin("https://b.example"))
+	assert.False(t, c.allowsOrigin("https://c.example"))
+	assert.False(t, ParseCORSConfig("", "GET").enabled())
+	assert.True(t, ParseCORSConfig("*", "").allowsOrigin("https://c.example"))
+}
+
+func TestServer_CORS(t *testing.T) {
+	// the requests are answered before reaching the storage
+	server := NewServer(nil)
+
+	request := func(method, origin string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/mempool/summary?at=x", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// disabled by default
+	rec := request(http.MethodGet, "https://a.example", nil)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	server.SetCORS(ParseCORSConfig("https://a.example", "GET,HEAD"))
+
+	rec = request(http.MethodGet, "https://a.example", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "https://a.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header()["Vary"], "Origin")
+
+	rec = request(http.MethodGet, "https://b.example", nil)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// preflight requests
+	rec = request(http.MethodOptions, "https://a.example", map[string]string{
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "Accept",
+	})
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://a.example", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Accept", rec.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = request(http.MethodOptions, "https://b.example", map[string]string{
+		"Access-Control-Request-Method": "GET",
+	})
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// WebSocket connections from the same origin and allowed origins
+	for origin, expected := range map[string]bool{
+		"":                   true,
+		"http://example.com": true,
+		"https://a.example":  true,
+		"https://b.example":  false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/v1/events", nil)
+		req.Header.Set("Origin", origin)
+		assert.Equal(t, expected, server.checkWebsocketOrigin(req), origin)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// acceptsCSV returns true if the request asks for `text/csv` with the Accept header
+func acceptsCSV(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		parts := strings.Split(mediaRange, ";")
+		if strings.ToLower(strings.TrimSpace(parts[0])) != "text/csv" {
+			continue
+		}
+		// `text/csv;q=0` rejects CSV
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// parseCSVLimit returns the `limit` query parameter of a CSV response, 0 for no limit.
+// Unlike parseLimit, there is no default and no maximum, since CSV responses are streamed.
+func parseCSVLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, errInvalidParam("limit", v)
+	}
+	return limit, nil
+}
+
+// writeTransactionsCSV streams `txs` with the columns of exporter.CSVHeader.
+// Errors after the header was sent can only be logged, the response then ends early.
+func writeTransactionsCSV(w http.ResponseWriter, txs exporter.Transactions) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if n, err := exporter.WriteCSV(w, txs); err != nil {
+		log.Errorf("error writing CSV response after %d transactions: %s", n, err)
+	}
+}
+
+// transactionSlice implements exporter.Transactions for transactions in memory
+type transactionSlice struct {
+	txs []types.Transaction
+	tx  types.StoredTransaction
+}
+
+func (s *transactionSlice) Next() *types.StoredTransaction {
+	if len(s.txs) == 0 {
+		return nil
+	}
+	s.tx = types.StoredTransaction{Transaction: s.txs[0]}
+	s.txs = s.txs[1:]
+	return &s.tx
+}
+
+func (s *transactionSlice) Err() error {
+	return nil
+}
+
+// listedTransactions returns the transactions of the list responses that can be written as CSV
+func listedTransactions(v interface{}) ([]types.Transaction, bool) {
+	switch v := v.(type) {
+	case types.TransactionList:
+		return v.Transactions, true
+	case types.MempoolSnapshot:
+		return v.Transactions, true
+	case *types.MempoolSnapshot:
+		return v.Transactions, true
+	case *types.StuckReport:
+		return v.Transactions, true
+	}
+	return nil, false
+}
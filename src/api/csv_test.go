@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestAcceptsCSV(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                                  false,
+		"application/json":                  false,
+		"*/*":                               false,
+		"text/csv":                          true,
+		"Text/CSV":                          true,
+		"application/json;q=0.9, text/csv":  true,
+		"text/csv;q=0, application/json":    false,
+		"text/csv; charset=utf-8; q=0.5":    true,
+		"text/csv-schema, application/json": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/transactions", nil)
+		r.Header.Set("Accept", header)
+		assert.Equal(t, expected, acceptsCSV(r), header)
+	}
+}
+
+func TestServer_TransactionsCSV(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	// more transactions than the default limit of JSON responses
+	var txs []types.Transaction
+	for i := 1; i <= defaultLimit+5; i++ {
+		txs = append(txs, types.Transaction{
+			TxID:      test.GenerateHash32(fmt.Sprintf("tx-csv-%d", i)),
+			FirstSeen: time.Unix(int64(100*i), 0).UTC(),
+			Fee:       uint64(100 * i),
+			Weight:    400,
+		})
+	}
+	_, err := st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	getCSV := func(url string) []string {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept", "text/csv")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		assert.Equal(t, strings.TrimSuffix(exporter.CSVHeader, "\n"), lines[0])
+		return lines[1:]
+	}
+
+	lines := getCSV("/v1/transactions")
+	require.Len(t, lines, len(txs))
+	assert.Equal(t, txs[0].TxID.String()+",100,,,100,400", lines[0])
+
+	assert.Len(t, getCSV("/v1/transactions?limit=2"), 2)
+	assert.Len(t, getCSV("/v1/transactions?from=200&to=300"), 2)
+	assert.Len(t, getCSV(fmt.Sprintf("/v1/mempool?at=%d", 100*len(txs))), len(txs))
+
+	// invalid parameters are reported as JSON before streaming
+	req := httptest.NewRequest(http.MethodGet, "/v1/transactions?limit=0", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+// handleDoubleSpends implements `GET /v1/double-spends`. Returns the counts of the conflicts
+// resolved by a block in the range and their outcomes, most recent first. Supported query parameters:
+//
+//	from, to:   range of the first-seen time of the resolving block
+//	limit:      maximum number of listed outcomes
+func (s *Server) handleDoubleSpends(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseDoubleSpendQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	report, err := s.storage.DoubleSpendReport(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseDoubleSpendQuery returns the storage query for the parameters of `GET /v1/double-spends`
+func parseDoubleSpendQuery(r *http.Request) (storage.DoubleSpendQuery, error) {
+	var q storage.DoubleSpendQuery
+	var err error
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.From = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.To = &to
+	}
+	if q.From != nil && q.To != nil && q.From.After(*q.To) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDoubleSpendQuery(t *testing.T) {
+	q, err := parseDoubleSpendQuery(httptest.NewRequest("GET", "/v1/double-spends?from=100&limit=5", nil))
+	require.NoError(t, err)
+	require.NotNil(t, q.From)
+	assert.Equal(t, time.Unix(100, 0).UTC(), q.From.UTC())
+	assert.Nil(t, q.To)
+	assert.Equal(t, 5, q.MaxResults)
+
+	for _, query := range []string{"from=x", "from=2&to=1", "limit=-1"} {
+		_, err := parseDoubleSpendQuery(httptest.NewRequest("GET", "/v1/double-spends?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// paramError is returned for invalid query parameters
+type paramError struct {
+	name  string
+	value string
+	// describes valid values, optional
+	expected string
+}
+
+func (e paramError) Error() string {
+	if e.expected != "" {
+		return fmt.Sprintf("invalid value %q for parameter %q, expected %s", e.value, e.name, e.expected)
+	}
+	return fmt.Sprintf("invalid value %q for parameter %q", e.value, e.name)
+}
+
+func errInvalidParam(name, value string) error {
+	return paramError{name: name, value: value}
+}
+
+func errInvalidParamExpected(name, value, expected string) error {
+	return paramError{name: name, value: value, expected: expected}
+}
+
+// errorStatus returns the HTTP status code for the error category of `err`
+func errorStatus(err error) int {
+	cause := errors.Cause(err)
+	if _, ok := cause.(paramError); ok {
+		return http.StatusBadRequest
+	}
+	switch cause {
+	case types.ErrParse:
+		return http.StatusBadRequest
+	case types.ErrNotFound:
+		return http.StatusNotFound
+	case types.ErrStorageBusy:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
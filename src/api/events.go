@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// eventPollInterval is the wait time for new events once the stream caught up
+const eventPollInterval = time.Second
+
+// websocketWriteTimeout is the maximum duration for writing a single message
+const websocketWriteTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// newMempoolEvent converts a storage.Event to a types.MempoolEvent.
+// `txs` must be the mempool transactions before applying the event.
+func newMempoolEvent(e *storage.Event, txs map[int64]types.StoredTransaction) types.MempoolEvent {
+	res := types.MempoolEvent{
+		Time:               e.Time,
+		Type:               string(e.Type),
+		AddTransactions:    []types.Transaction{},
+		RemoveTransactions: []types.Hash32{},
+	}
+	if e.NewBlock != nil {
+		block := e.NewBlock.Block
+		res.Block = &block
+	}
+	for _, tx := range e.AddTransactions {
+		res.AddTransactions = append(res.AddTransactions, tx.Transaction)
+	}
+	for _, dbid := range e.RemoveTransactions {
+		if tx, ok := txs[dbid]; ok {
+			res.RemoveTransactions = append(res.RemoveTransactions, tx.TxID)
+		}
+	}
+	return res
+}
+
+// handleEvents implements the WebSocket endpoint `/v1/events?since=<time>`.
+// Sends the mempool events after `since` (default: now) as JSON messages
+// and keeps sending new events as they are stored.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	since, err := parseTimeParam(r, "since", time.Now())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	mempool, err := storage.NewMempoolAtTime(s.storage, since)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote the error response
+		log.Debugf("websocket upgrade failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	// the client does not send messages, reading detects a closed connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		event, err := mempool.NextEvent()
+		if err != nil {
+			log.Errorf("error in event stream: %s", err)
+			return
+		}
+
+		if event == nil {
+			select {
+			case <-closed:
+				return
+			case <-time.After(eventPollInterval):
+				mempool.Refresh()
+				continue
+			}
+		}
+
+		msg := newMempoolEvent(event, mempool.TransactionMap())
+		if err := mempool.ApplyEvent(event); err != nil {
+			log.Errorf("error in event stream: %s", err)
+			return
+		}
+
+		if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+			return
+		}
+		if err := conn.WriteJSON(msg); err != nil {
+			log.Debugf("event stream closed: %s", err)
+			return
+		}
+	}
+}
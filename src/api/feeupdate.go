@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleFeeUpdates implements `GET /v1/fee-updates`. Returns the transactions that were
+// received again with a different fee, most recent first. Supported query parameters:
+//
+//	txid:       updates of the transaction
+//	from, to:   range of the time of the update
+//	limit:      maximum number of results
+func (s *Server) handleFeeUpdates(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseFeeUpdateQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	updates, err := s.storage.FeeUpdates(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updates)
+}
+
+// parseFeeUpdateQuery returns the storage query for the parameters of `GET /v1/fee-updates`
+func parseFeeUpdateQuery(r *http.Request) (storage.FeeUpdateQuery, error) {
+	var q storage.FeeUpdateQuery
+	var err error
+
+	if v := r.URL.Query().Get("txid"); v != "" {
+		txid, err := types.NewHashFromString(v)
+		if err != nil {
+			return q, errInvalidParam("txid", v)
+		}
+		q.TxID = &txid
+	}
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.From = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.To = &to
+	}
+	if q.From != nil && q.To != nil && q.From.After(*q.To) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestParseFeeUpdateQuery(t *testing.T) {
+	txid := test.GenerateHash32("tx")
+	q, err := parseFeeUpdateQuery(httptest.NewRequest("GET", "/v1/fee-updates?txid="+txid.String()+"&limit=5", nil))
+	require.NoError(t, err)
+	assert.Equal(t, storage.FeeUpdateQuery{TxID: &txid, MaxResults: 5}, q)
+
+	for _, query := range []string{"txid=x", "from=2&to=1", "limit=-1"} {
+		_, err := parseFeeUpdateQuery(httptest.NewRequest("GET", "/v1/fee-updates?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
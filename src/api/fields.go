@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// feerateField is the derived field `feerate` in sat/vB, which is not part of types.Transaction
+const feerateField = "feerate"
+
+// transactionFields are the names that can be selected with the `fields` parameter:
+// the JSON fields of types.Transaction and feerateField
+var transactionFields = func() map[string]bool {
+	res := map[string]bool{feerateField: true}
+	t := reflect.TypeOf(types.Transaction{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			res[name] = true
+		}
+	}
+	return res
+}()
+
+// parseFields returns the transaction fields of the comma-separated `fields` parameter,
+// nil if it is not set
+func parseFields(r *http.Request) (map[string]bool, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil
+	}
+	res := map[string]bool{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if !transactionFields[name] {
+			return nil, errInvalidParam("fields", v)
+		}
+		res[name] = true
+	}
+	return res, nil
+}
+
+// writeTransactionsJSON writes `v` like writeJSON. If the request has the `fields` parameter,
+// the transactions in `v` are reduced to the selected fields. `v` is either a transaction or an
+// object with transactions in the array `transactions`.
+// If the client accepts CSV and `v` is a list (see listedTransactions), the transactions are
+// written as CSV instead.
+func writeTransactionsJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Add("Vary", "Accept")
+	if acceptsCSV(r) {
+		if txs, ok := listedTransactions(v); ok {
+			writeTransactionsCSV(w, &transactionSlice{txs: txs})
+			return
+		}
+	}
+
+	fields, err := parseFields(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if fields == nil {
+		writeJSON(w, status, v)
+		return
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var generic map[string]interface{}
+	if err := dec.Decode(&generic); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if _, isTransaction := v.(types.Transaction); isTransaction {
+		writeJSON(w, status, selectFields(generic, fields))
+		return
+	}
+	if txs, ok := generic["transactions"].([]interface{}); ok {
+		for i, tx := range txs {
+			if tx, ok := tx.(map[string]interface{}); ok {
+				txs[i] = selectFields(tx, fields)
+			}
+		}
+	}
+	writeJSON(w, status, generic)
+}
+
+// selectFields returns the fields of the encoded transaction `tx` that are in `fields`
+func selectFields(tx map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+	for name := range fields {
+		if value, ok := tx[name]; ok {
+			res[name] = value
+		}
+	}
+	if fields[feerateField] {
+		var feerate float64
+		encodedFee, _ := tx["fee"].(json.Number)
+		encodedWeight, _ := tx["weight"].(json.Number)
+		fee, errFee := encodedFee.Float64()
+		weight, errWeight := encodedWeight.Float64()
+		if errFee == nil && errWeight == nil && weight > 0 {
+			feerate = fee / (weight / 4)
+		}
+		res[feerateField] = feerate
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestSelectFields(t *testing.T) {
+	tx := map[string]interface{}{
+		"txid":   "00",
+		"fee":    json.Number("1000"),
+		"weight": json.Number("800"),
+	}
+	assert.Equal(t,
+		map[string]interface{}{"txid": "00", "feerate": 5.0},
+		selectFields(tx, map[string]bool{"txid": true, "feerate": true}),
+	)
+	// missing fields are omitted, the feerate without weight is zero
+	assert.Equal(t,
+		map[string]interface{}{"feerate": 0.0},
+		selectFields(map[string]interface{}{}, map[string]bool{"details": true, "feerate": true}),
+	)
+}
+
+func TestServer_Fields(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	tx := types.Transaction{
+		TxID:      test.GenerateHash32("tx-fields"),
+		FirstSeen: time.Unix(100, 0).UTC(),
+		Fee:       1000,
+		Weight:    800,
+	}
+	_, err := st.InsertTransaction(&tx)
+	require.NoError(t, err)
+
+	var list struct {
+		Transactions []map[string]interface{} `json:"transactions"`
+	}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/transactions?fields=txid,feerate,firstSeen", &list))
+	require.Len(t, list.Transactions, 1)
+	assert.Equal(t, map[string]interface{}{
+		"txid":      tx.TxID.String(),
+		"feerate":   5.0,
+		"firstSeen": "1970-01-01T00:01:40Z",
+	}, list.Transactions[0])
+
+	var single map[string]interface{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+tx.TxID.String()+"?fields=fee", &single))
+	assert.Equal(t, map[string]interface{}{"fee": 1000.0}, single)
+
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/transactions?fields=txid,unknown", nil))
+}
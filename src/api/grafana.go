@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// maxGrafanaRequestSize limits the size of request bodies of the Grafana endpoints
+const maxGrafanaRequestSize = 1 << 20
+
+// maxGrafanaAnnotations limits the number of annotations of a response
+const maxGrafanaAnnotations = 1000
+
+// grafanaMetrics are the series of the transaction aggregates (see `/v1/stats/transactions`) by name
+var grafanaMetrics = map[string]func(a *types.TxAggregate) *float64{
+	"transactions":   func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.Transactions)) },
+	"vsize":          func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.VSize)) },
+	"fees":           func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.Fees)) },
+	"median-feerate": func(a *types.TxAggregate) *float64 { return a.MedianFeerate },
+	"signals-known":  func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.SignalsKnown)) },
+	"version2":       func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.Version2)) },
+	"locktime":       func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.LockTime)) },
+	"rbf":            func(a *types.TxAggregate) *float64 { return floatPtr(float64(a.RBF)) },
+	"rbf-share":      func(a *types.TxAggregate) *float64 { return a.RBFShare },
+}
+
+// grafanaAnnotations are the queries of annotations, selecting blocks of the best chain or of stale chains
+var grafanaAnnotations = map[string]storage.BoolFilter{
+	"blocks":       storage.FilterTrue,
+	"stale-blocks": storage.FilterFalse,
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// grafanaRange is the time range of a Grafana request
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange    `json:"range"`
+	IntervalMs    int64           `json:"intervalMs"`
+	MaxDataPoints int             `json:"maxDataPoints"`
+	Targets       []grafanaTarget `json:"targets"`
+}
+
+// grafanaSeries is a time series of a query response, `datapoints` are pairs of value and unix milliseconds
+type grafanaSeries struct {
+	Target     string           `json:"target"`
+	Datapoints [][2]interface{} `json:"datapoints"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	// Unix milliseconds
+	Time  int64    `json:"time"`
+	Title string   `json:"title"`
+	Text  string   `json:"text"`
+	Tags  []string `json:"tags"`
+}
+
+// handleGrafana implements the endpoints of the Grafana Simple JSON data source at `/v1/grafana/`:
+// `/` tests the connection, `/search` lists the metrics, `/query` returns their series
+// and `/annotations` returns blocks.
+func (s *Server) handleGrafana(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/v1/grafana/") {
+	case "":
+		// tested with GET by the data source settings
+		writeJSON(w, http.StatusOK, struct{}{})
+	case "search":
+		s.handleGrafanaSearch(w, r)
+	case "query":
+		s.handleGrafanaQuery(w, r)
+	case "annotations":
+		s.handleGrafanaAnnotations(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("unknown path %s", r.URL.Path))
+	}
+}
+
+// decodeGrafanaRequest decodes the JSON body of the POST request `r` into `v`.
+// Writes an error response and returns false if it fails.
+func decodeGrafanaRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if !requirePOST(w, r) {
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGrafanaRequestSize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid request body"))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	if !decodeGrafanaRequest(w, r, &req) {
+		return
+	}
+
+	names := []string{}
+	for name := range grafanaMetrics {
+		if strings.Contains(name, req.Target) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+// grafanaResolution returns the finest aggregate resolution of at least `interval`
+// with at most `maxPoints` points between `from` and `to`
+func grafanaResolution(from, to time.Time, interval time.Duration, maxPoints int) (time.Duration, error) {
+	if maxPoints <= 0 || maxPoints > storage.MaxSeriesPoints {
+		maxPoints = storage.MaxSeriesPoints
+	}
+	for _, c := range storage.AggregateResolutions {
+		if c >= interval && seriesPoints(from, to, c) <= int64(maxPoints) {
+			return c, nil
+		}
+	}
+	return 0, errInvalidParam("range", from.Format(time.RFC3339))
+}
+
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	if !decodeGrafanaRequest(w, r, &req) {
+		return
+	}
+
+	for _, t := range req.Targets {
+		if _, ok := grafanaMetrics[t.Target]; !ok {
+			err := errInvalidParam("target", t.Target)
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		if t.Type != "" && t.Type != "timeserie" {
+			err := errInvalidParam("type", t.Type)
+			writeError(w, errorStatus(err), err)
+			return
+		}
+	}
+
+	from, to := req.Range.From.UTC(), req.Range.To.UTC()
+	resolution, err := grafanaResolution(from, to, time.Duration(req.IntervalMs)*time.Millisecond, req.MaxDataPoints)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, resolution.String())
+
+	var aggregates []types.TxAggregate
+	if len(req.Targets) > 0 {
+		if aggregates, err = s.storage.Aggregates(resolution, from, to); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+	}
+
+	res := make([]grafanaSeries, len(req.Targets))
+	for i, t := range req.Targets {
+		metric := grafanaMetrics[t.Target]
+		res[i] = grafanaSeries{Target: t.Target, Datapoints: make([][2]interface{}, len(aggregates))}
+		for j := range aggregates {
+			ms := aggregates[j].Time.UnixNano() / int64(time.Millisecond)
+			res[i].Datapoints[j] = [2]interface{}{metric(&aggregates[j]), ms}
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	var req grafanaAnnotationRequest
+	if !decodeGrafanaRequest(w, r, &req) {
+		return
+	}
+
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	if len(req.Annotation) > 0 {
+		if err := json.Unmarshal(req.Annotation, &annotation); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid annotation"))
+			return
+		}
+	}
+	if annotation.Query == "" {
+		annotation.Query = "blocks"
+	}
+	bestChain, ok := grafanaAnnotations[annotation.Query]
+	if !ok {
+		err := errInvalidParam("query", annotation.Query)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	blocks, err := s.storage.BlocksFirstSeen(req.Range.From.UTC(), req.Range.To.UTC(), bestChain, maxGrafanaAnnotations)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	res := make([]grafanaAnnotation, len(blocks))
+	for i, b := range blocks {
+		tags := []string{annotation.Query}
+		if b.Miner != "" {
+			tags = append(tags, b.Miner)
+		}
+		res[i] = grafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       b.FirstSeen.UnixNano() / int64(time.Millisecond),
+			Title:      fmt.Sprintf("Block %d", b.Height),
+			Text:       b.Hash.String(),
+			Tags:       tags,
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
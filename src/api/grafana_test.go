@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// post performs a POST request with the JSON body `body` and decodes the JSON response into `v`
+func post(t *testing.T, handler http.Handler, url, body string, v interface{}) int {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil {
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(v), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestGrafanaResolution(t *testing.T) {
+	from := time.Unix(0, 0)
+	for _, tc := range []struct {
+		to        time.Time
+		interval  time.Duration
+		maxPoints int
+		expected  time.Duration
+	}{
+		{from.Add(time.Hour), 0, 0, time.Minute},
+		{from.Add(time.Hour), 10 * time.Second, 0, time.Minute},
+		{from.Add(time.Hour), 5 * time.Minute, 0, time.Hour},
+		{from.Add(time.Hour), 0, 10, time.Hour},
+		{from.Add(48 * time.Hour), 0, 0, time.Hour},
+		{from.Add(48 * time.Hour), 0, 24, 24 * time.Hour},
+	} {
+		res, err := grafanaResolution(from, tc.to, tc.interval, tc.maxPoints)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, res)
+	}
+
+	_, err := grafanaResolution(from, from.Add(10000*24*time.Hour), 0, 0)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+}
+
+func TestServer_Grafana(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	_, err := st.InsertTransactions([]types.Transaction{
+		{TxID: test.GenerateHash32("grafana-1"), FirstSeen: time.Unix(3600, 0).UTC(), Fee: 1000, Weight: 800},
+		{TxID: test.GenerateHash32("grafana-2"), FirstSeen: time.Unix(3700, 0).UTC(), Fee: 3000, Weight: 800},
+		{TxID: test.GenerateHash32("grafana-3"), FirstSeen: time.Unix(7300, 0).UTC(), Fee: 500, Weight: 400},
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.RefreshAggregates(time.Hour, time.Unix(0, 0), time.Unix(4*3600, 0)))
+
+	block := types.Block{
+		Hash:      test.GenerateHash32("grafana-block"),
+		FirstSeen: time.Unix(5000, 0).UTC(),
+		Height:    7,
+		IsBest:    true,
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/grafana/", nil))
+
+	var names []string
+	require.Equal(t, http.StatusOK, post(t, server, "/v1/grafana/search", `{"target": "fee"}`, &names))
+	assert.Equal(t, []string{"fees", "median-feerate"}, names)
+
+	var series []struct {
+		Target     string       `json:"target"`
+		Datapoints [][2]float64 `json:"datapoints"`
+	}
+	query := `{"range": {"from": "1970-01-01T00:00:00Z", "to": "1970-01-01T03:00:00Z"}, "intervalMs": 3600000,
+		"targets": [{"target": "transactions", "refId": "A"}, {"target": "fees", "refId": "B"}]}`
+	require.Equal(t, http.StatusOK, post(t, server, "/v1/grafana/query", query, &series))
+	require.Len(t, series, 2)
+	assert.Equal(t, "transactions", series[0].Target)
+	assert.Equal(t, [][2]float64{{2, 3600000}, {1, 7200000}}, series[0].Datapoints)
+	assert.Equal(t, "fees", series[1].Target)
+	assert.Equal(t, [][2]float64{{4000, 3600000}, {500, 7200000}}, series[1].Datapoints)
+
+	unknown := `{"range": {"from": "1970-01-01T00:00:00Z", "to": "1970-01-01T03:00:00Z"}, "targets": [{"target": "x"}]}`
+	assert.Equal(t, http.StatusBadRequest, post(t, server, "/v1/grafana/query", unknown, nil))
+	assert.Equal(t, http.StatusBadRequest, post(t, server, "/v1/grafana/query", "{", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, get(t, server, "/v1/grafana/query", nil))
+
+	var annotations []grafanaAnnotation
+	annotationQuery := `{"range": {"from": "1970-01-01T00:00:00Z", "to": "1970-01-01T03:00:00Z"},
+		"annotation": {"name": "blocks", "query": "blocks"}}`
+	require.Equal(t, http.StatusOK, post(t, server, "/v1/grafana/annotations", annotationQuery, &annotations))
+	require.Len(t, annotations, 1)
+	assert.Equal(t, int64(5000000), annotations[0].Time)
+	assert.Equal(t, "Block 7", annotations[0].Title)
+	assert.Equal(t, block.Hash.String(), annotations[0].Text)
+	assert.JSONEq(t, `{"name": "blocks", "query": "blocks"}`, string(annotations[0].Annotation))
+
+	annotations = nil
+	staleQuery := `{"range": {"from": "1970-01-01T00:00:00Z", "to": "1970-01-01T03:00:00Z"},
+		"annotation": {"query": "stale-blocks"}}`
+	require.Equal(t, http.StatusOK, post(t, server, "/v1/grafana/annotations", staleQuery, &annotations))
+	assert.Empty(t, annotations)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DefaultHistogramInterval is the default interval between two feerate histograms
+const DefaultHistogramInterval = 5 * time.Second
+
+// histogramBroadcaster computes the feerate histogram once per interval and sends it
+// to all subscribers, so that the cost does not grow with the number of clients.
+// It only runs while there are subscribers.
+type histogramBroadcaster struct {
+	interval time.Duration
+	compute  func() (*types.FeerateHistogram, error)
+
+	mu          sync.Mutex
+	subscribers map[chan []byte]struct{}
+	running     bool
+	// last encoded histogram, sent to new subscribers
+	last []byte
+}
+
+func newHistogramBroadcaster(interval time.Duration, compute func() (*types.FeerateHistogram, error)) *histogramBroadcaster {
+	return &histogramBroadcaster{
+		interval:    interval,
+		compute:     compute,
+		subscribers: map[chan []byte]struct{}{},
+	}
+}
+
+// subscribe returns a channel that receives the encoded histograms.
+// A slow subscriber only receives the latest histogram.
+func (h *histogramBroadcaster) subscribe() chan []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan []byte, 1)
+	h.subscribers[ch] = struct{}{}
+	if h.last != nil {
+		ch <- h.last
+	}
+	if !h.running {
+		h.running = true
+		go h.run()
+	}
+	return ch
+}
+
+func (h *histogramBroadcaster) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, ch)
+}
+
+func (h *histogramBroadcaster) run() {
+	for {
+		var msg []byte
+		histogram, err := h.compute()
+		if err == nil {
+			msg, err = json.Marshal(histogram)
+		}
+		if err != nil {
+			log.Errorf("error computing feerate histogram: %s", err)
+		}
+
+		h.mu.Lock()
+		if len(h.subscribers) == 0 {
+			h.running = false
+			h.last = nil
+			h.mu.Unlock()
+			return
+		}
+		if msg != nil {
+			h.last = msg
+			for ch := range h.subscribers {
+				// replace an unsent histogram
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- msg
+			}
+		}
+		h.mu.Unlock()
+
+		time.Sleep(h.interval)
+	}
+}
+
+// currentHistogram returns the feerate histogram of the current mempool, from the mirror if set.
+// With `excludeDataCarrier`, transactions with OP_RETURN outputs are omitted.
+func (s *Server) currentHistogram(excludeDataCarrier bool) (*types.FeerateHistogram, error) {
+	if s.mirror != nil && !excludeDataCarrier {
+		return s.mirror.Summary().Histogram(), nil
+	}
+	now := time.Now()
+	var txs []types.Transaction
+	if s.mirror != nil {
+		txs = s.mirror.Transactions()
+	} else {
+		mempool, err := storage.NewMempoolAtTime(s.storage, now)
+		if err != nil {
+			return nil, err
+		}
+		txs = mempool.Transactions()
+	}
+	if excludeDataCarrier {
+		txs = types.WithoutDataCarriers(txs)
+	}
+	return types.NewMempoolSummary(now.UTC(), txs).Histogram(), nil
+}
+
+// handleHistogram implements the WebSocket endpoint `/v1/mempool/histogram?exclude-data-carrier=<bool>`.
+// Sends the feerate histogram of the current mempool as JSON message in a fixed interval.
+// The histogram is computed once per interval for all clients.
+func (s *Server) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	excludeDataCarrier, err := parseBoolParam(r, "exclude-data-carrier")
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	histogram := s.histogram
+	if excludeDataCarrier {
+		histogram = s.histogramNoDataCarriers
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote the error response
+		log.Debugf("websocket upgrade failed: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	// the client does not send messages, reading detects a closed connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ch := histogram.subscribe()
+	defer histogram.unsubscribe(ch)
+
+	for {
+		select {
+		case <-closed:
+			return
+		case msg := <-ch:
+			if err := conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Debugf("histogram stream closed: %s", err)
+				return
+			}
+		}
+	}
+}
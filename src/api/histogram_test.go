@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestHistogramBroadcaster(t *testing.T) {
+	var computed int32
+	h := newHistogramBroadcaster(10*time.Millisecond, func() (*types.FeerateHistogram, error) {
+		n := atomic.AddInt32(&computed, 1)
+		summary := types.NewMempoolSummary(time.Unix(int64(n), 0).UTC(), []types.Transaction{
+			{Fee: 1000, Weight: 800},
+		})
+		return summary.Histogram(), nil
+	})
+
+	a := h.subscribe()
+	b := h.subscribe()
+
+	// both clients receive the same computed histogram
+	msgA := <-a
+	msgB := <-b
+	assert.Equal(t, msgA, msgB)
+
+	var histogram types.FeerateHistogram
+	require.NoError(t, json.Unmarshal(msgA, &histogram))
+	assert.Equal(t, 1, histogram.Transactions)
+	assert.Equal(t, []int{5}, histogram.Feerates)
+	assert.Equal(t, []int{1}, histogram.Counts)
+	assert.Equal(t, []int64{800}, histogram.Weights)
+
+	// a slow client only keeps the latest histogram
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, a, 1)
+
+	h.unsubscribe(a)
+	h.unsubscribe(b)
+
+	// the broadcaster stops without subscribers
+	time.Sleep(50 * time.Millisecond)
+	stopped := atomic.LoadInt32(&computed)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&computed))
+}
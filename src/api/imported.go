@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxImportedHistogramRange is the longest time range of `GET /v1/stats/imported-mempool`
+const maxImportedHistogramRange = 31 * 24 * time.Hour
+
+// handleImportedHistograms implements `GET /v1/stats/imported-mempool?source=<label>&from=<time>&to=<time>`.
+// Returns the mempool histograms imported from the dataset `source` in the range (default: last 24 hours,
+// at most 31 days), ordered by time.
+func (s *Server) handleImportedHistograms(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if to.Sub(from) > maxImportedHistogramRange {
+		err := errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time at most 31 days before `to`")
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		sources, err := s.storage.ImportedHistogramSources()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		err = errInvalidParamExpected("source", source, "one of ["+strings.Join(sources, ", ")+"]")
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	histograms, err := s.storage.ImportedHistograms(source, from, to)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, histograms)
+}
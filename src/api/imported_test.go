@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestHandleImportedHistograms(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	at := time.Unix(1600000000, 0).UTC()
+	require.NoError(t, st.InsertImportedHistograms([]types.ImportedHistogram{{
+		Source: "johoe", Time: at, Feerates: []float64{1}, Counts: []int64{2}, VSizes: []int64{300}, Fees: []int64{400},
+	}}))
+
+	var histograms []types.ImportedHistogram
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/stats/imported-mempool?source=johoe&from=1599999000&to=1600001000", &histograms))
+	require.Len(t, histograms, 1)
+	assert.Equal(t, at, histograms[0].Time)
+	assert.Equal(t, []int64{300}, histograms[0].VSizes)
+
+	for _, query := range []string{"from=1599999000&to=1600001000", "source=johoe&from=1500000000&to=1600001000"} {
+		assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/stats/imported-mempool?"+query, nil), query)
+	}
+}
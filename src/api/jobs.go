@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/jobs"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+const (
+	defaultJohoeRange    = 24 * time.Hour
+	defaultJohoeInterval = time.Minute
+	// maxJohoeSamples bounds the samples of a `johoe` job
+	maxJohoeSamples = 1000000
+)
+
+// EnableJobs serves the job endpoints `/v1/jobs` and runs submitted jobs with `workers`
+// goroutines. Jobs and their results are kept in `dir`.
+// Must be called before serving requests.
+func (s *Server) EnableJobs(dir string, workers int) error {
+	q, err := jobs.NewQueue(dir, s.jobKinds(), workers)
+	if err != nil {
+		return err
+	}
+	s.jobs = q
+	s.mux.HandleFunc("/v1/jobs", s.handleJobs)
+	s.mux.HandleFunc("/v1/jobs/", s.handleJobs)
+	return nil
+}
+
+// jobKinds returns the types of jobs. Their parameters are those of the corresponding endpoints.
+func (s *Server) jobKinds() map[string]jobs.Kind {
+	return map[string]jobs.Kind{
+		// the transactions of `GET /v1/transactions` as CSV, without limit
+		"transactions": {
+			ContentType: "text/csv; charset=utf-8",
+			Validate: func(params url.Values) error {
+				_, err := parseTransactionQuery(paramRequest(params))
+				return err
+			},
+			Run: func(ctx context.Context, params url.Values, w io.Writer, progress jobs.Progress) error {
+				q, err := parseTransactionQuery(paramRequest(params))
+				if err != nil {
+					return err
+				}
+				txs := &progressTransactions{ctx: ctx, progress: progress, to: time.Now()}
+				if q.FirstSeenFrom != nil {
+					txs.from = *q.FirstSeenFrom
+				} else if first, err := s.storage.FirstTransactionTime(); err != nil {
+					return err
+				} else if first != nil {
+					txs.from = *first
+				}
+				if q.FirstSeenTo != nil {
+					txs.to = *q.FirstSeenTo
+				}
+				txIter, err := s.storage.QueryTransactions(q)
+				if err != nil {
+					return err
+				}
+				defer txIter.Close()
+				txs.Transactions = txIter
+				_, err = exporter.WriteCSV(w, txs)
+				return err
+			},
+		},
+		// the mempool at `at` (default: submission) as CSV
+		"mempool": {
+			ContentType: "text/csv; charset=utf-8",
+			Validate: func(params url.Values) error {
+				if params.Get("at") == "" {
+					params.Set("at", time.Now().UTC().Format(time.RFC3339))
+				}
+				if _, err := parseBoolParam(paramRequest(params), "exclude-data-carrier"); err != nil {
+					return err
+				}
+				_, err := parseTimeParam(paramRequest(params), "at", time.Time{})
+				return err
+			},
+			Run: func(ctx context.Context, params url.Values, w io.Writer, progress jobs.Progress) error {
+				at, err := parseTimeParam(paramRequest(params), "at", time.Time{})
+				if err != nil {
+					return err
+				}
+				excludeDataCarrier, err := parseBoolParam(paramRequest(params), "exclude-data-carrier")
+				if err != nil {
+					return err
+				}
+				m, err := storage.NewMempoolAtTimeContext(ctx, s.storage, at, progress)
+				if err != nil {
+					return err
+				}
+				txs := m.Transactions()
+				if excludeDataCarrier {
+					txs = types.WithoutDataCarriers(txs)
+				}
+				_, err = exporter.WriteCSV(w, &transactionSlice{txs: txs})
+				return err
+			},
+		},
+		// the mempool every `interval` (default 1m) from `from` to `to` (default: the day before
+		// submission) in the format of exporter.JohoeWriter
+		"johoe": {
+			ContentType: "application/json",
+			Validate: func(params url.Values) error {
+				if params.Get("to") == "" {
+					params.Set("to", time.Now().UTC().Format(time.RFC3339))
+				}
+				_, _, err := parseJohoeParams(params)
+				return err
+			},
+			Run: func(ctx context.Context, params url.Values, w io.Writer, progress jobs.Progress) error {
+				times, excludeDataCarrier, err := parseJohoeParams(params)
+				if err != nil {
+					return err
+				}
+				jw, err := exporter.NewJohoeWriter(w, false)
+				if err != nil {
+					return err
+				}
+				newAccumulator := func() storage.Accumulator {
+					if excludeDataCarrier {
+						return storage.WithoutDataCarriers(exporter.NewJohoeAccumulator())
+					}
+					return exporter.NewJohoeAccumulator()
+				}
+				err = storage.ReconstructSeriesIncremental(s.storage, times, 1, newAccumulator, func(v interface{}) error {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					progress(float64(jw.Count()) / float64(len(times)))
+					return jw.Write(v.(*exporter.JohoeSample))
+				})
+				if err != nil {
+					return err
+				}
+				return jw.Close()
+			},
+		},
+	}
+}
+
+// progressTransactions reports the progress of transactions ordered by first-seen time from `from`
+// to `to` and ends them with ctx.Err() once `ctx` is canceled
+type progressTransactions struct {
+	exporter.Transactions
+	ctx      context.Context
+	progress jobs.Progress
+	from, to time.Time
+	n        int
+	err      error
+}
+
+func (p *progressTransactions) Next() *types.StoredTransaction {
+	if p.err = p.ctx.Err(); p.err != nil {
+		return nil
+	}
+	tx := p.Transactions.Next()
+	p.n++
+	if tx != nil && p.n%1000 == 0 && p.to.After(p.from) {
+		p.progress(float64(tx.FirstSeen.Sub(p.from)) / float64(p.to.Sub(p.from)))
+	}
+	return tx
+}
+
+func (p *progressTransactions) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.Transactions.Err()
+}
+
+// parseJohoeParams returns the times of a `johoe` job and whether data carriers are excluded
+func parseJohoeParams(params url.Values) ([]time.Time, bool, error) {
+	r := paramRequest(params)
+	from, to, err := parseTimeRange(r, defaultJohoeRange)
+	if err != nil {
+		return nil, false, err
+	}
+	interval, err := parseDurationParam(r, "interval", defaultJohoeInterval)
+	if err != nil {
+		return nil, false, err
+	}
+	if to.Sub(from)/interval >= maxJohoeSamples {
+		return nil, false, errInvalidParamExpected("interval", params.Get("interval"),
+			"a duration that splits the range into fewer than 1000000 samples")
+	}
+	excludeDataCarrier, err := parseBoolParam(r, "exclude-data-carrier")
+	if err != nil {
+		return nil, false, err
+	}
+	var times []time.Time
+	for t := from; !t.After(to); t = t.Add(interval) {
+		times = append(times, t)
+	}
+	return times, excludeDataCarrier, nil
+}
+
+// paramRequest returns a request with the query `params`, for the parsers of query parameters
+func paramRequest(params url.Values) *http.Request {
+	return &http.Request{URL: &url.URL{RawQuery: params.Encode()}}
+}
+
+// handleJobs implements the job endpoints:
+//
+//	POST /v1/jobs?type=<type>&<params>: submits a job, returns 202 with the job
+//	GET /v1/jobs:                      all jobs, most recently submitted first
+//	GET /v1/jobs/{id}:                 the job `id`
+//	GET /v1/jobs/{id}/result:          the result of the done job `id`
+//	POST /v1/jobs/{id}/cancel:         cancels the queued or running job `id`
+//	DELETE /v1/jobs/{id}:              removes the finished job `id` and its result
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/jobs"), "/")
+	parts := strings.Split(path, "/")
+	if path == "" && r.Method == http.MethodPost {
+		s.handleSubmitJob(w, r)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "cancel" {
+		if requirePOST(w, r) {
+			s.handleCancelJob(w, parts[0])
+		}
+		return
+	}
+	if len(parts) == 1 && path != "" && r.Method == http.MethodDelete {
+		s.handleDeleteJob(w, parts[0])
+		return
+	}
+	if !requireGET(w, r) {
+		return
+	}
+
+	switch {
+	case path == "":
+		writeJSON(w, http.StatusOK, s.jobs.Jobs())
+	case len(parts) == 1:
+		job, err := s.jobs.Job(parts[0])
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case len(parts) == 2 && parts[1] == "result":
+		s.handleJobResult(w, parts[0])
+	default:
+		err := errors.Wrapf(types.ErrNotFound, "path %s", r.URL.Path)
+		writeError(w, errorStatus(err), err)
+	}
+}
+
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	kind := params.Get("type")
+	params.Del("type")
+
+	job, err := s.jobs.Submit(kind, params)
+	if err == jobs.ErrQueueFull {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set("Location", "/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleCancelJob cancels job `id` and writes it, or 409 if it is finished
+func (s *Server) handleCancelJob(w http.ResponseWriter, id string) {
+	job, err := s.jobs.Cancel(id)
+	if errors.Cause(err) == jobs.ErrFinished {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleDeleteJob removes job `id`, or writes 409 if it is not finished
+func (s *Server) handleDeleteJob(w http.ResponseWriter, id string) {
+	err := s.jobs.Delete(id)
+	if errors.Cause(err) == jobs.ErrNotFinished {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleJobResult writes the result of job `id`, or 409 if the job is not done
+func (s *Server) handleJobResult(w http.ResponseWriter, id string) {
+	job, err := s.jobs.Job(id)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if job.Status != jobs.StatusDone {
+		writeError(w, http.StatusConflict, errors.Errorf("job %s has no result, its status is %s", id, job.Status))
+		return
+	}
+	f, contentType, err := s.jobs.Result(id)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Errorf("error writing result of job %s: %s", id, err)
+	}
+}
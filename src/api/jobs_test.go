@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/jobs"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_Jobs(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	dir, err := ioutil.TempDir("", "jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, server.EnableJobs(dir, 1))
+
+	for i := 1; i <= 3; i++ {
+		_, err := st.InsertTransaction(&types.Transaction{
+			TxID:      test.GenerateHash32(string(rune('a' + i))),
+			FirstSeen: time.Unix(int64(1000*i), 0).UTC(),
+			Fee:       200,
+			Weight:    400,
+		})
+		require.NoError(t, err)
+	}
+
+	var job jobs.Job
+	assert.Equal(t, http.StatusBadRequest, post(t, server, "/v1/jobs?type=unknown", "", nil))
+	assert.Equal(t, http.StatusBadRequest, post(t, server, "/v1/jobs?type=transactions&from=x", "", nil))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/jobs?type=transactions&from=1500", nil))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&job))
+	assert.Equal(t, "/v1/jobs/"+job.ID, rec.Header().Get("Location"))
+	assert.Equal(t, "transactions", job.Type)
+	assert.Equal(t, "from=1500", job.Params)
+
+	for i := 0; i < 500 && (job.Status == jobs.StatusQueued || job.Status == jobs.StatusRunning); i++ {
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, get(t, server, "/v1/jobs/"+job.ID, &job))
+	}
+	require.Equal(t, jobs.StatusDone, job.Status, job.Error)
+	assert.Equal(t, float64(100), job.Progress)
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/jobs/"+job.ID+"/result", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	require.NoError(t, err)
+	// header and the transactions first seen at 2000 and 3000
+	assert.Len(t, records, 3)
+
+	var list []jobs.Job
+	assert.Equal(t, http.StatusOK, get(t, server, "/v1/jobs", &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, job.ID, list[0].ID)
+
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/jobs/0000000000000000", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/jobs/0000000000000000/result", nil))
+
+	// finished jobs cannot be canceled
+	assert.Equal(t, http.StatusConflict, post(t, server, "/v1/jobs/"+job.ID+"/cancel", "", nil))
+	assert.Equal(t, http.StatusNotFound, post(t, server, "/v1/jobs/0000000000000000/cancel", "", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, get(t, server, "/v1/jobs/"+job.ID+"/cancel", nil))
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/jobs/"+job.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/jobs/"+job.ID, nil))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
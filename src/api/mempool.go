@@ -0,0 +1,218 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// defaultEvictionWarmup is the default time before `at` from which the mempool of a node with
+// `max-mempool` is simulated
+const defaultEvictionWarmup = 6 * time.Hour
+
+// handleMempool implements `GET /v1/mempool?at=<time>&exclude-data-carrier=<bool>&max-mempool=<MB>`.
+// Returns the reconstructed mempool at time `at` (default: now).
+// Without `at`, the current mempool is served from the mirror if set.
+// With `exclude-data-carrier`, transactions with OP_RETURN outputs are omitted like in the other
+// mempool endpoints, e.g. to keep data-embedding waves out of fee analysis.
+// With `max-mempool`, returns the mempool of a node with that limit, see storage.SimulateEviction.
+func (s *Server) handleMempool(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	at, err := parseTimeParam(r, "at", time.Now())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	if r.URL.Query().Get("max-mempool") != "" {
+		s.handleMempoolEviction(w, r, at)
+		return
+	}
+
+	txs, err := s.mempoolAt(r, at)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if txs == nil {
+		txs = []types.Transaction{}
+	}
+
+	writeTransactionsJSON(w, r, http.StatusOK, types.MempoolSnapshot{
+		Time:         at,
+		Transactions: txs,
+	})
+}
+
+// handleMempoolEviction implements `GET /v1/mempool` with `max-mempool=<MB>` and optional
+// `warmup=<duration>`, the time before `at` from which the node is simulated (default 6h)
+func (s *Server) handleMempoolEviction(w http.ResponseWriter, r *http.Request, at time.Time) {
+	excludeDataCarrier, err := parseBoolParam(r, "exclude-data-carrier")
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	v := r.URL.Query().Get("max-mempool")
+	maxMempool, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || maxMempool <= 0 {
+		err := errInvalidParam("max-mempool", v)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	warmup, err := parseDurationParam(r, "warmup", defaultEvictionWarmup)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	policy := types.DefaultEvictionPolicy
+	policy.MaxMempool = maxMempool * 1000000
+	snapshot, err := storage.SimulateEviction(s.storage, at.Add(-warmup), at, policy)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if excludeDataCarrier {
+		snapshot.Transactions = types.WithoutDataCarriers(snapshot.Transactions)
+	}
+	writeTransactionsJSON(w, r, http.StatusOK, snapshot)
+}
+
+// mempoolAt returns the mempool at `at`, from the mirror if the request has no `at` parameter.
+// With `exclude-data-carrier`, transactions with OP_RETURN outputs are omitted.
+func (s *Server) mempoolAt(r *http.Request, at time.Time) ([]types.Transaction, error) {
+	excludeDataCarrier, err := parseBoolParam(r, "exclude-data-carrier")
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []types.Transaction
+	if s.mirror != nil && r.URL.Query().Get("at") == "" {
+		txs = s.mirror.Transactions()
+	} else {
+		mempool, err := storage.NewMempoolAtTime(s.storage, at)
+		if err != nil {
+			return nil, err
+		}
+		txs = mempool.Transactions()
+	}
+	if excludeDataCarrier {
+		txs = types.WithoutDataCarriers(txs)
+	}
+	return txs, nil
+}
+
+// fromMirror returns true if the request for the current mempool can be served by the summary
+// or the sorted transactions of the mirror, which include data carriers
+func (s *Server) fromMirror(r *http.Request) bool {
+	return s.mirror != nil && r.URL.Query().Get("at") == "" && r.URL.Query().Get("exclude-data-carrier") == ""
+}
+
+// handleMempoolSummary implements `GET /v1/mempool/summary?at=<time>&exclude-data-carrier=<bool>`.
+// Returns the number, weight and fees of the transactions in the mempool at `at` (default: now)
+// and their distribution over feerate buckets of 1 sat/vB.
+func (s *Server) handleMempoolSummary(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	at, err := parseTimeParam(r, "at", time.Now())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	if s.fromMirror(r) {
+		writeJSON(w, http.StatusOK, s.mirror.Summary())
+		return
+	}
+
+	txs, err := s.mempoolAt(r, at)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, types.NewMempoolSummary(at.UTC(), txs))
+}
+
+// handleMempoolTop implements `GET /v1/mempool/top?weight=<weight>&at=<time>&exclude-data-carrier=<bool>`.
+// Returns the transactions with the highest feerate that fit into `weight` (default: the
+// maximum block weight), in descending feerate order, e.g. to approximate the next block template.
+func (s *Server) handleMempoolTop(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	at, err := parseTimeParam(r, "at", time.Now())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	maxWeight := int64(types.MaxBlockWeight)
+	if v := r.URL.Query().Get("weight"); v != "" {
+		maxWeight, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || maxWeight <= 0 {
+			err := errInvalidParam("weight", v)
+			writeError(w, errorStatus(err), err)
+			return
+		}
+	}
+
+	var txs []types.Transaction
+	if s.fromMirror(r) {
+		txs = s.mirror.Top(maxWeight)
+	} else {
+		mempool, err := s.mempoolAt(r, at)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		txs = types.TopByFeerate(mempool, maxWeight)
+	}
+
+	writeTransactionsJSON(w, r, http.StatusOK, types.MempoolSnapshot{
+		Time:         at.UTC(),
+		Transactions: txs,
+	})
+}
+
+// handleMempoolStuck implements
+// `GET /v1/mempool/stuck?min-age=<duration>&limit=<n>&at=<time>&exclude-data-carrier=<bool>`.
+// Lists the transactions of the mempool at `at` (default: now) older than `min-age` (default: 24h)
+// with a feerate below the lowest feerate of the next block, with totals by age.
+func (s *Server) handleMempoolStuck(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	at, err := parseTimeParam(r, "at", time.Now())
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	minAge, err := parseDurationParam(r, "min-age", 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	txs, err := s.mempoolAt(r, at)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeTransactionsJSON(w, r, http.StatusOK, types.NewStuckReport(at.UTC(), txs, minAge, limit))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_MempoolMirror(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	stored := types.Transaction{
+		TxID:      test.GenerateHash32("tx-stored"),
+		FirstSeen: time.Unix(100, 0).UTC(),
+		Fee:       1000,
+		Weight:    800,
+	}
+	_, err := st.InsertTransaction(&stored)
+	require.NoError(t, err)
+
+	// the mirror has a transaction that is not written yet
+	live := stored
+	live.TxID = test.GenerateHash32("tx-live")
+	live.OpReturn = &types.OpReturnStats{Outputs: 1, PayloadSize: 80}
+	m := mirror.New()
+	m.Add(stored, live)
+	server.SetMirror(m)
+
+	var snapshot types.MempoolSnapshot
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool", &snapshot))
+	assert.Len(t, snapshot.Transactions, 2)
+
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool?at=200", &snapshot))
+	require.Len(t, snapshot.Transactions, 1)
+	assert.Equal(t, stored.TxID, snapshot.Transactions[0].TxID)
+
+	// the simulated node with a limit is reconstructed from the database as well
+	snapshot = types.MempoolSnapshot{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool?max-mempool=300", &snapshot))
+	assert.Len(t, snapshot.Transactions, 1)
+	assert.Empty(t, snapshot.Evicted)
+	assert.Equal(t, 0.0, *snapshot.MinFeerate)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/mempool?max-mempool=0", nil))
+
+	var summary types.MempoolSummary
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/summary", &summary))
+	assert.Equal(t, 2, summary.Transactions)
+	assert.Equal(t, []types.FeerateBucket{{Feerate: 5, Transactions: 2, Weight: 1600}}, summary.Feerates)
+
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/summary?at=200", &summary))
+	assert.Equal(t, 1, summary.Transactions)
+	assert.Equal(t, uint64(1000), summary.Fees)
+
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/top?weight=1000", &snapshot))
+	assert.Equal(t, types.TopByFeerate([]types.Transaction{stored, live}, 1000), snapshot.Transactions)
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/top?at=200", &snapshot))
+	assert.Len(t, snapshot.Transactions, 1)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/mempool/top?weight=0", nil))
+
+	// both fit into the next block
+	var stuck types.StuckReport
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/stuck?min-age=1h", &stuck))
+	assert.Equal(t, 0.0, stuck.Threshold)
+	assert.Len(t, stuck.Transactions, 0)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/mempool/stuck?min-age=x", nil))
+
+	// the data carrier of the mirror is omitted from all mempool endpoints
+	snapshot = types.MempoolSnapshot{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool?exclude-data-carrier=true", &snapshot))
+	require.Len(t, snapshot.Transactions, 1)
+	assert.Equal(t, stored.TxID, snapshot.Transactions[0].TxID)
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/summary?exclude-data-carrier=true", &summary))
+	assert.Equal(t, 1, summary.Transactions)
+	snapshot = types.MempoolSnapshot{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/mempool/top?exclude-data-carrier=true", &snapshot))
+	assert.Equal(t, []types.Transaction{stored}, snapshot.Transactions)
+	histogram, err := server.currentHistogram(true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, histogram.Transactions)
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/mempool/summary?exclude-data-carrier=x", nil))
+}
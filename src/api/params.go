@@ -0,0 +1,121 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timeFormats describes the accepted formats of time parameters in error messages
+const timeFormats = "unix seconds or an RFC3339 time like 2020-01-02T15:04:05Z"
+
+// parseTime parses a time given as unix seconds or RFC3339 string and returns it in UTC
+func parseTime(v string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// parseTimeParam returns the query parameter `name` as time in UTC or `def` if it is not set
+func parseTimeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def.UTC(), nil
+	}
+	t, err := parseTime(v)
+	if err != nil {
+		return time.Time{}, errInvalidParamExpected(name, v, timeFormats)
+	}
+	return t, nil
+}
+
+// parseTimeRange returns the query parameters `from` and `to`, by default the duration `def` until now.
+// `from` must not be after `to`.
+func parseTimeRange(r *http.Request, def time.Duration) (from, to time.Time, err error) {
+	if to, err = parseTimeParam(r, "to", time.Now()); err != nil {
+		return
+	}
+	if from, err = parseTimeParam(r, "from", to.Add(-def)); err != nil {
+		return
+	}
+	if from.After(to) {
+		err = errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+	return
+}
+
+// parseDurationParam returns the query parameter `name` as duration or `def` if it is not set.
+// The value is either a number of seconds or a Go duration string like "1h".
+func parseDurationParam(r *http.Request, name string, def time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < time.Second {
+		return 0, errInvalidParam(name, v)
+	}
+	return d, nil
+}
+
+// parseFloatParam returns the query parameter `name` as non-negative number or nil if it is not set
+func parseFloatParam(r *http.Request, name string) (*float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errInvalidParam(name, v)
+	}
+	return &f, nil
+}
+
+// parseHeightParam returns the query parameter `name` as block height or nil if it is not set
+func parseHeightParam(r *http.Request, name string) (*uint32, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	h, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, errInvalidParam(name, v)
+	}
+	height := uint32(h)
+	return &height, nil
+}
+
+// parseCountParam returns the query parameter `name` as non-negative int or nil if it is not set
+func parseCountParam(r *http.Request, name string) (*int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return nil, errInvalidParam(name, v)
+	}
+	return &n, nil
+}
+
+// parseBoolParam returns the query parameter `name` as bool or false if it is not set
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errInvalidParam(name, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	// times are returned in UTC regardless of the local time zone
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*3600)
+	defer func() { time.Local = local }()
+
+	parse := func(query string) (time.Time, error) {
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		return parseTimeParam(req, "at", time.Unix(100, 0))
+	}
+
+	at, err := parse("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(100, 0).UTC(), at)
+	assert.Equal(t, time.UTC, at.Location())
+
+	at, err = parse("at=1600000000")
+	require.NoError(t, err)
+	assert.Equal(t, "2020-09-13T12:26:40Z", at.Format(time.RFC3339))
+
+	at, err = parse("at=2020-09-13T14:26:40%2B02:00")
+	require.NoError(t, err)
+	assert.Equal(t, "2020-09-13T12:26:40Z", at.Format(time.RFC3339))
+
+	_, err = parse("at=2020-09-13")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.Contains(t, err.Error(), "expected unix seconds or an RFC3339 time")
+}
+
+func TestParseTimeRange(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*3600)
+	defer func() { time.Local = local }()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	from, to, err := parseTimeRange(req, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, to.Location())
+	assert.Equal(t, time.Hour, to.Sub(from))
+
+	req = httptest.NewRequest(http.MethodGet, "/?from=200&to=300", nil)
+	from, to, err = parseTimeRange(req, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(200, 0).UTC(), from)
+	assert.Equal(t, time.Unix(300, 0).UTC(), to)
+
+	req = httptest.NewRequest(http.MethodGet, "/?from=300&to=200", nil)
+	_, _, err = parseTimeRange(req, time.Hour)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	assert.Contains(t, err.Error(), `invalid value "300" for parameter "from"`)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+// handleReorgs implements `GET /v1/reorgs`. Returns the detected reorgs, most recent first.
+// Supported query parameters:
+//
+//	from, to:   range of the time of the reorg
+//	min-depth:  minimum number of orphaned blocks
+//	limit:      maximum number of reorgs
+func (s *Server) handleReorgs(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseReorgQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	reorgs, err := s.storage.Reorgs(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reorgs)
+}
+
+// parseReorgQuery returns the storage query for the parameters of `GET /v1/reorgs`
+func parseReorgQuery(r *http.Request) (storage.ReorgQuery, error) {
+	var q storage.ReorgQuery
+	var err error
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.From = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.To = &to
+	}
+	if q.From != nil && q.To != nil && q.From.After(*q.To) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	minDepth, err := parseCountParam(r, "min-depth")
+	if err != nil {
+		return q, err
+	}
+	if minDepth != nil {
+		q.MinDepth = *minDepth
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+func TestParseReorgQuery(t *testing.T) {
+	q, err := parseReorgQuery(httptest.NewRequest("GET", "/v1/reorgs?min-depth=2&limit=5", nil))
+	require.NoError(t, err)
+	assert.Equal(t, storage.ReorgQuery{MinDepth: 2, MaxResults: 5}, q)
+
+	for _, query := range []string{"min-depth=-1", "min-depth=x", "from=2&to=1", "limit=-1"} {
+		_, err := parseReorgQuery(httptest.NewRequest("GET", "/v1/reorgs?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+// resolutionHeader reports the interval of the points of a time series response
+const resolutionHeader = "X-Resolution"
+
+// seriesResolutions are the intervals chosen for time series without explicit interval
+// if the default interval would exceed the maximum number of points
+var seriesResolutions = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// seriesPoints returns the maximum number of points of a series over `from..to` per `interval`
+func seriesPoints(from, to time.Time, interval time.Duration) int64 {
+	if !to.After(from) {
+		return 1
+	}
+	return int64(to.Sub(from)/interval) + 1
+}
+
+// parseMaxPointsParam returns the query parameter `max-points`, at most storage.MaxSeriesPoints
+func parseMaxPointsParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("max-points")
+	if v == "" {
+		return storage.MaxSeriesPoints, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > storage.MaxSeriesPoints {
+		return 0, errInvalidParam("max-points", v)
+	}
+	return n, nil
+}
+
+// seriesResolution returns the interval of a time series over `from..to`.
+// An explicit query parameter `name` must not exceed `max-points` points. Without parameter,
+// `def` is used, or the finest of `candidates` at least as coarse as `def` that fits into `max-points`.
+// A zero `def` selects the finest fitting candidate.
+func seriesResolution(r *http.Request, name string, from, to time.Time, def time.Duration, candidates []time.Duration) (time.Duration, error) {
+	maxPoints, err := parseMaxPointsParam(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if v := r.URL.Query().Get(name); v != "" && v != "auto" {
+		interval, err := parseDurationParam(r, name, def)
+		if err != nil {
+			return 0, err
+		}
+		if seriesPoints(from, to, interval) > int64(maxPoints) {
+			return 0, errInvalidParam(name, v)
+		}
+		return interval, nil
+	}
+
+	if def > 0 && seriesPoints(from, to, def) <= int64(maxPoints) {
+		return def, nil
+	}
+	for _, c := range candidates {
+		if c >= def && seriesPoints(from, to, c) <= int64(maxPoints) {
+			return c, nil
+		}
+	}
+	// the range is too long for the coarsest resolution
+	return 0, errInvalidParam("from", from.Format(time.RFC3339))
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+func TestSeriesResolution(t *testing.T) {
+	to := time.Unix(1600000000, 0).UTC()
+	resolution := func(query string, from time.Time, def time.Duration) (time.Duration, error) {
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		return seriesResolution(r, "interval", from, to, def, seriesResolutions)
+	}
+
+	// the default is kept if it fits
+	d, err := resolution("", to.Add(-24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+
+	// long ranges select a coarser resolution
+	d, err = resolution("", to.Add(-365*24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, d)
+	d, err = resolution("max-points=100", to.Add(-365*24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, d)
+
+	// without default, the finest fitting resolution
+	d, err = resolution("interval=auto", to.Add(-6*time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, d)
+
+	// explicit intervals are not changed
+	d, err = resolution("interval=30m", to.Add(-24*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, d)
+	_, err = resolution("interval=1m", to.Add(-30*24*time.Hour), time.Hour)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+
+	_, err = resolution("", to.Add(-100*365*24*time.Hour), time.Hour)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+	_, err = resolution("max-points=0", to.Add(-time.Hour), time.Hour)
+	assert.Equal(t, http.StatusBadRequest, errorStatus(err))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	d, err = seriesResolution(r, "resolution", to.Add(-30*24*time.Hour), to, 0, storage.AggregateResolutions)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, d)
+}
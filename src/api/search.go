@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleSearch implements `GET /v1/search`. Supported query parameters:
+//
+//	txid-prefix: transactions with txid starting with the hex prefix
+//	hash:        block or transaction with the hash
+//	height:      blocks at the height, including blocks of competing chains
+//	limit:       maximum number of transactions for txid-prefix
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	var txs []types.StoredTransaction
+	var blocks []types.StoredBlock
+
+	if prefix := query.Get("txid-prefix"); prefix != "" {
+		limit, err := parseLimit(r)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		txIter, err := s.storage.TransactionsByTxIDPrefix(prefix, limit)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		byPrefix, err := txIter.Collect()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		txs = append(txs, byPrefix...)
+	}
+
+	if v := query.Get("hash"); v != "" {
+		h, err := types.NewHashFromString(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errInvalidParam("hash", v))
+			return
+		}
+		tx, err := s.storage.TransactionByID(h)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		if tx != nil {
+			txs = append(txs, *tx)
+		}
+		block, err := s.storage.BlockByHash(h)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		if block != nil {
+			blocks = append(blocks, *block)
+		}
+	}
+
+	if v := query.Get("height"); v != "" {
+		height, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errInvalidParam("height", v))
+			return
+		}
+		blockIter, err := s.storage.BlocksByHeight(uint32(height))
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		atHeight, err := blockIter.Collect()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		blocks = append(blocks, atHeight...)
+	}
+
+	if err := s.setConfirmations(txs); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if err := s.setBlockConfirmations(blocks); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	res := types.SearchResult{
+		Transactions: make([]types.Transaction, len(txs)),
+		Blocks:       make([]types.Block, len(blocks)),
+	}
+	for i, tx := range txs {
+		res.Transactions[i] = tx.Transaction
+	}
+	for i, block := range blocks {
+		res.Blocks[i] = block.Block
+	}
+	writeTransactionsJSON(w, r, http.StatusOK, res)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_Search(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	tx := types.Transaction{
+		TxID:      test.GenerateHash32("tx-search"),
+		FirstSeen: time.Unix(100, 0).UTC(),
+		Fee:       1000,
+		Weight:    800,
+	}
+	_, err := st.InsertTransaction(&tx)
+	require.NoError(t, err)
+
+	block := types.Block{
+		Hash:      test.GenerateHash32("block-search"),
+		FirstSeen: time.Unix(200, 0).UTC(),
+		Height:    7,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{tx.TxID},
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	var res types.SearchResult
+	code := get(t, server, "/v1/search?txid-prefix="+tx.TxID.String()[:5], &res)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Transactions, 1)
+	assert.Equal(t, tx.TxID, res.Transactions[0].TxID)
+	assert.Len(t, res.Blocks, 0)
+
+	res = types.SearchResult{}
+	code = get(t, server, "/v1/search?hash="+block.Hash.String(), &res)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Blocks, 1)
+	assert.Equal(t, block.Hash, res.Blocks[0].Hash)
+
+	res = types.SearchResult{}
+	code = get(t, server, fmt.Sprintf("/v1/search?height=%d", block.Height), &res)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, res.Blocks, 1)
+	assert.Equal(t, block.Hash, res.Blocks[0].Hash)
+
+	var errRes errorResponse
+	code = get(t, server, "/v1/search?txid-prefix=xyz", &errRes)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NotEmpty(t, errRes.Error)
+}
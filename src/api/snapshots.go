@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// checksumHeader carries the SHA-256 of the body of `GET /v1/snapshots/{name}`
+const checksumHeader = "X-Checksum-Sha256"
+
+// handleSnapshots implements the endpoints of pinned snapshots:
+//
+//	GET /v1/snapshots:        the pinned snapshots ordered by name, without transactions
+//	GET /v1/snapshots/{name}: the stored mempool of snapshot `name`
+//
+// The body of a snapshot is returned as stored, so its SHA-256 is the checksum of the list.
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/snapshots"), "/")
+	if name == "" {
+		snapshots, err := s.storage.PinnedSnapshots()
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, snapshots)
+		return
+	}
+	if strings.Contains(name, "/") {
+		err := errors.Wrapf(types.ErrNotFound, "path %s", r.URL.Path)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	pinned, data, err := s.storage.PinnedSnapshot(name)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(checksumHeader, pinned.Checksum)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		log.Errorf("error writing response: %s", err)
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_Snapshots(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	_, err := st.InsertTransaction(&types.Transaction{
+		TxID:      test.GenerateHash32("tx"),
+		FirstSeen: time.Unix(1000, 0).UTC(),
+		Fee:       200,
+		Weight:    400,
+	})
+	require.NoError(t, err)
+	pinned, err := st.PinSnapshot("report", time.Unix(2000, 0))
+	require.NoError(t, err)
+
+	var list []types.PinnedSnapshot
+	assert.Equal(t, http.StatusOK, get(t, server, "/v1/snapshots", &list))
+	assert.Equal(t, []types.PinnedSnapshot{*pinned}, list)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/snapshots/report", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	sum := sha256.Sum256(rec.Body.Bytes())
+	assert.Equal(t, pinned.Checksum, hex.EncodeToString(sum[:]))
+	assert.Equal(t, pinned.Checksum, rec.Header().Get(checksumHeader))
+
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/snapshots/missing", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/snapshots/report/x", nil))
+}
@@ -0,0 +1,510 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleHeuristicStats implements `GET /v1/stats/heuristics?from=<time>&to=<time>`.
+// Counts the classified transactions first seen in the range (default: last 24 hours) per heuristic.
+func (s *Server) handleHeuristicStats(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	stats, err := s.storage.HeuristicStats(from, to)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleBlockShareStats implements
+// `GET /v1/stats/block-share?heuristic=<name>&from=<time>&to=<time>&interval=<duration>`.
+// Reports the share of block space and fees of the transactions with the heuristic (default: batch)
+// in the best-chain blocks over the range (default: last 30 days) per interval (default: 24h).
+func (s *Server) handleBlockShareStats(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("heuristic")
+	if name == "" {
+		name = types.HeuristicNames[types.HeuristicBatch]
+	}
+	flag, ok := types.HeuristicFlagByName(name)
+	if !ok {
+		names := make([]string, 0, len(types.HeuristicNames))
+		for _, n := range types.HeuristicNames {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		err := errInvalidParamExpected("heuristic", name, "one of ["+strings.Join(names, ", ")+"]")
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, 24*time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	stats, err := s.storage.BlockShareStats(flag, from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleConsolidationReport implements `GET /v1/stats/consolidations?from=<time>&to=<time>`.
+// Relates the consolidations first seen in the range (default: last 30 days) to the market feerate levels.
+func (s *Server) handleConsolidationReport(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	report, err := s.storage.ConsolidationReport(from, to)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleOpReturnTrend implements `GET /v1/stats/opreturn?from=<time>&to=<time>&interval=<duration>`.
+// Aggregates the OP_RETURN usage of transactions first seen in the range (default: last 24 hours)
+// per interval (default: 1h).
+func (s *Server) handleOpReturnTrend(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	trend, err := s.storage.OpReturnTrend(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, trend)
+}
+
+// handleDustReport implements `GET /v1/stats/dust?from=<time>&to=<time>&interval=<duration>`.
+// Aggregates the dust outputs by script type of transactions first seen in the range (default: last 24 hours)
+// per interval (default: 1h). Requires the daemon to run with `-dust`.
+func (s *Server) handleDustReport(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	report, err := s.storage.DustReport(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleValueFlow implements `GET /v1/stats/value-flow?from=<time>&to=<time>&interval=<duration>`.
+// Aggregates the output value entering the mempool and being confirmed over the range
+// (default: last 24 hours) per interval (default: 1h).
+func (s *Server) handleValueFlow(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	flow, err := s.storage.ValueFlow(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, flow)
+}
+
+// handleWitnessHeavySeries implements `GET /v1/stats/witness-heavy?from=<time>&to=<time>&interval=<duration>`.
+// Returns the share of witness-heavy transactions in the mempool over the range (default: last 24 hours)
+// sampled every interval (default: 1h).
+func (s *Server) handleWitnessHeavySeries(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	series, err := s.storage.WitnessHeavySeries(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// handleMinerBlockStats implements `GET /v1/stats/blocks?from=<time>&to=<time>&interval=<duration>`.
+// Reports block counts, near-empty block counts and weight utilization per miner
+// over the range (default: last 30 days) per interval (default: 24h).
+func (s *Server) handleMinerBlockStats(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := parseDurationParam(r, "interval", 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	stats, err := s.storage.MinerBlockStats(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleUTXODeltas implements `GET /v1/stats/utxo?from=<time>&to=<time>&interval=<duration>`.
+// Aggregates the UTXO set deltas of the best-chain blocks first seen in the range (default: last 24 hours)
+// per interval (default: 1h).
+func (s *Server) handleUTXODeltas(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	deltas, err := s.storage.UTXODeltas(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deltas)
+}
+
+// handleDifficultyEpochs implements `GET /v1/stats/difficulty?limit=<n>`.
+// Returns difficulty, retarget estimate and hashrate estimate of the latest epochs, newest first.
+func (s *Server) handleDifficultyEpochs(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	epochs, err := s.storage.DifficultyEpochs(limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, epochs)
+}
+
+// handleTransactionAggregates implements
+// `GET /v1/stats/transactions?from=<time>&to=<time>&resolution=<duration>&max-points=<n>`.
+// Returns the materialized aggregates of the transactions first seen in the range (default: last 24 hours)
+// per bucket of `resolution` (1m, 1h or 24h, default: the finest with at most `max-points` buckets).
+func (s *Server) handleTransactionAggregates(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	resolution, err := seriesResolution(r, "resolution", from, to, 0, storage.AggregateResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if !isAggregateResolution(resolution) {
+		err := errInvalidParam("resolution", r.URL.Query().Get("resolution"))
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, resolution.String())
+
+	aggregates, err := s.storage.Aggregates(resolution, from, to)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.TxAggregateSeries{
+		Resolution: int64(resolution.Seconds()),
+		From:       from,
+		To:         to,
+		Points:     aggregates,
+	})
+}
+
+func isAggregateResolution(d time.Duration) bool {
+	for _, r := range storage.AggregateResolutions {
+		if r == d {
+			return true
+		}
+	}
+	return false
+}
+
+// handleOverpaymentStats implements `GET /v1/stats/overpayment?from=<time>&to=<time>&interval=<duration>`.
+// Reports the fees paid above the lowest feerate of the block for the transactions of best-chain blocks
+// over the range (default: last 30 days) per interval (default: 24h).
+func (s *Server) handleOverpaymentStats(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, 24*time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	stats, err := s.storage.OverpaymentStats(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleCPFPStats implements `GET /v1/stats/cpfp?from=<time>&to=<time>&interval=<duration>`.
+// Reports the child-pays-for-parent packages of best-chain blocks over the range (default: last 30 days)
+// per interval (default: 24h).
+func (s *Server) handleCPFPStats(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	interval, err := seriesResolution(r, "interval", from, to, 24*time.Hour, seriesResolutions)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set(resolutionHeader, interval.String())
+
+	stats, err := s.storage.CPFPStats(from, to, interval)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleBlockDrains implements `GET /v1/stats/drain?from=<time>&to=<time>`.
+// Returns per best-chain block first seen in the range (default: last 7 days) the transactions it removed
+// from the tracked mempool and those that entered the mempool since the previous block.
+func (s *Server) handleBlockDrains(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	drains, err := s.storage.BlockDrains(from, to)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, drains)
+}
+
+// handlePackageEvents implements `GET /v1/stats/packages?from=<time>&to=<time>&limit=<n>`.
+// Returns the recorded children that arrived before their parents, with a child first seen in the range
+// (default: last 24 hours), oldest first.
+func (s *Server) handlePackageEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	events, err := s.storage.PackageEvents(from, to, limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// handleOrphanResolutions implements `GET /v1/stats/orphans?from=<time>&to=<time>&limit=<n>`.
+// Returns the transactions resolved by the orphan pool of the daemon that were first seen in the range
+// (default: last 24 hours), oldest first.
+func (s *Server) handleOrphanResolutions(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	resolutions, err := s.storage.OrphanResolutions(from, to, limit)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resolutions)
+}
+
+// handleBlockIntervals implements `GET /v1/stats/block-intervals?from=<time>&to=<time>&long-gap=<duration>`.
+// Returns the distribution of the intervals between the best-chain blocks first seen in the range
+// (default: last 30 days) and their parents, and lists the gaps of at least `long-gap` (default: 1h).
+func (s *Server) handleBlockIntervals(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	longGap, err := parseDurationParam(r, "long-gap", time.Hour)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	stats, err := s.storage.BlockIntervals(from, to, longGap)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
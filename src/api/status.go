@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// StatusProvider reports the runtime state of the daemon, implemented by daemon.BademeisterDaemon
+type StatusProvider interface {
+	Status() *types.DaemonStatus
+}
+
+// SetStatusProvider sets the daemon reported by `/v1/status`. Must be called before serving requests.
+func (s *Server) SetStatusProvider(p StatusProvider) {
+	s.status = p
+}
+
+// handleStatus implements `GET /v1/status`. Returns the schema version, the recent ingest rates
+// and, if the API server runs in the daemon, its enabled features.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	var status types.Status
+	var err error
+	if status.SchemaVersion, err = s.storage.SchemaVersion(); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	best, err := s.storage.BestBlockNow()
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if best != nil {
+		status.BestBlockHeight = &best.Height
+	}
+	if status.IngestRates, err = s.storage.IngestRates(time.Now()); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if status.SamplingRates, err = s.storage.SamplingRates(); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if s.status != nil {
+		status.Daemon = s.status.Status()
+	}
+	writeJSON(w, http.StatusOK, status)
+}
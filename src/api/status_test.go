@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+type fixedStatus types.DaemonStatus
+
+func (s *fixedStatus) Status() *types.DaemonStatus {
+	return (*types.DaemonStatus)(s)
+}
+
+func TestHandleStatus(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	var status types.Status
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/status", &status))
+	assert.NotZero(t, status.SchemaVersion)
+	assert.Nil(t, status.BestBlockHeight)
+	assert.Len(t, status.IngestRates, 3)
+	assert.Nil(t, status.Daemon)
+
+	server.SetStatusProvider(&fixedStatus{
+		Sources:  []string{"zmq", "rpc"},
+		Features: types.DaemonFeatures{FullTransactions: true, Analyzers: []string{"mempool-parents"}},
+	})
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/status", &status))
+	require.NotNil(t, status.Daemon)
+	assert.Equal(t, []string{"zmq", "rpc"}, status.Daemon.Sources)
+	assert.True(t, status.Daemon.Features.FullTransactions)
+	assert.Equal(t, []string{"mempool-parents"}, status.Daemon.Features.Analyzers)
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleTransaction implements `GET /v1/tx/{txid}`, `GET /v1/tx/{txid}/status`, `GET /v1/tx/{txid}/raw`
+// and `GET /v1/tx/{txid}/lineage`
+func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	v := strings.TrimPrefix(r.URL.Path, "/v1/tx/")
+	if strings.HasSuffix(v, "/status") {
+		s.handleTxStatus(w, strings.TrimSuffix(v, "/status"))
+		return
+	}
+	if strings.HasSuffix(v, "/raw") {
+		s.handleTxRaw(w, r, strings.TrimSuffix(v, "/raw"))
+		return
+	}
+	if strings.HasSuffix(v, "/lineage") {
+		s.handleTxLineage(w, strings.TrimSuffix(v, "/lineage"))
+		return
+	}
+	txid, err := types.NewHashFromString(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidParam("txid", v))
+		return
+	}
+
+	tx, err := s.storage.TransactionByID(txid)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if tx == nil {
+		err := errors.Wrapf(types.ErrNotFound, "transaction %s", txid)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	details, err := s.storage.TransactionDetails(tx.DBID)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	tx.Details = details
+
+	estimate, err := s.storage.FirstSeenEstimate(tx.DBID)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	tx.FirstSeenEstimate = estimate
+
+	txs := []types.StoredTransaction{*tx}
+	if err := s.setConfirmations(txs); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeTransactionsJSON(w, r, http.StatusOK, txs[0].Transaction)
+}
+
+// handleTxStatus implements `GET /v1/tx/{txid}/status`.
+// Combines the mirror, which has transactions that are not written yet, with storage.
+func (s *Server) handleTxStatus(w http.ResponseWriter, v string) {
+	txid, err := types.NewHashFromString(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidParam("txid", v))
+		return
+	}
+
+	status, err := s.txStatus(txid)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleTxRaw implements `GET /v1/tx/{txid}/raw`.
+// Returns the serialized transaction as hex, or binary with `format=binary`.
+func (s *Server) handleTxRaw(w http.ResponseWriter, r *http.Request, v string) {
+	txid, err := types.NewHashFromString(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidParam("txid", v))
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "hex" && format != "binary" {
+		writeError(w, http.StatusBadRequest, errInvalidParam("format", format))
+		return
+	}
+
+	raw, err := s.txRaw(txid)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	if format == "binary" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(raw)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, hex.EncodeToString(raw))
+}
+
+// handleTxLineage implements `GET /v1/tx/{txid}/lineage`.
+// Returns the chain of replacements the transaction is part of.
+func (s *Server) handleTxLineage(w http.ResponseWriter, v string) {
+	txid, err := types.NewHashFromString(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidParam("txid", v))
+		return
+	}
+
+	tx, err := s.storage.TransactionByID(txid)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if tx == nil {
+		err := errors.Wrapf(types.ErrNotFound, "transaction %s", txid)
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	lineage, err := s.storage.RBFLineage(tx)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lineage)
+}
+
+// txRaw returns the stored serialized transaction with `txid`, or an ErrNotFound error
+func (s *Server) txRaw(txid types.Hash32) ([]byte, error) {
+	tx, err := s.storage.TransactionByID(txid)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, errors.Wrapf(types.ErrNotFound, "transaction %s", txid)
+	}
+	raw, err := s.storage.TransactionRaw(tx.DBID)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.Wrapf(types.ErrNotFound, "raw transaction %s (requires -store-raw)", txid)
+	}
+	return raw, nil
+}
+
+func (s *Server) txStatus(txid types.Hash32) (*types.TxStatus, error) {
+	res := &types.TxStatus{TxID: txid, Status: types.TxUnseen}
+
+	var mirrored *types.Transaction
+	if s.mirror != nil {
+		if tx, ok := s.mirror.Get(txid); ok {
+			mirrored = &tx
+		}
+	}
+
+	stored, err := s.storage.TransactionByID(txid)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		if mirrored != nil {
+			setMempoolStatus(res, mirrored)
+		}
+		return res, nil
+	}
+
+	block, index, err := s.storage.TransactionBlock(stored.DBID)
+	if err != nil {
+		return nil, err
+	}
+	if block != nil && stored.LastRemoved != nil {
+		tip, err := s.storage.GetBestBlock()
+		if err != nil {
+			return nil, err
+		}
+		height := int(block.Height)
+		n := confirmations(tip, block.Height)
+		res.Confirmations = &n
+		res.Status = types.TxConfirmed
+		res.FirstSeen = &stored.FirstSeen
+		res.BlockHash = &block.Hash
+		res.BlockHeight = &height
+		res.BlockIndex = &index
+		return res, nil
+	}
+
+	replacedBy, err := s.storage.ReplacedBy(stored.DBID)
+	if err != nil {
+		return nil, err
+	}
+	if replacedBy != nil {
+		res.Status = types.TxReplaced
+		res.FirstSeen = &stored.FirstSeen
+		res.ReplacedBy = replacedBy
+		return res, nil
+	}
+
+	// the mirror is the current mempool if set, the stored transaction can be outdated
+	if mirrored != nil {
+		setMempoolStatus(res, mirrored)
+		return res, nil
+	}
+	if s.mirror == nil && stored.LastRemoved == nil && stored.Expired == nil {
+		setMempoolStatus(res, &stored.Transaction)
+		return res, nil
+	}
+
+	res.Status = types.TxRemoved
+	res.FirstSeen = &stored.FirstSeen
+	res.Removed = stored.LastRemoved
+	if res.Removed == nil {
+		res.Removed = stored.Expired
+		reason, err := s.storage.DropReason(stored.DBID)
+		if err != nil {
+			return nil, err
+		}
+		res.Reason = reason
+	}
+	return res, nil
+}
+
+func setMempoolStatus(res *types.TxStatus, tx *types.Transaction) {
+	feerate := tx.Feerate()
+	res.Status = types.TxInMempool
+	res.FirstSeen = &tx.FirstSeen
+	res.Feerate = &feerate
+}
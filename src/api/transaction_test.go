@@ -0,0 +1,185 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestServer_TxStatus(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	newTx := func(id string, firstSeen int64, prevIndex uint32) types.Transaction {
+		return types.Transaction{
+			TxID:      test.GenerateHash32(id),
+			FirstSeen: time.Unix(firstSeen, 0).UTC(),
+			Fee:       1000,
+			Weight:    800,
+			Details: &types.TxDetails{
+				Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32("prev"), PrevIndex: prevIndex}},
+			},
+		}
+	}
+	confirmed := newTx("confirmed", 100, 0)
+	original := newTx("original", 100, 1)
+	replacement := newTx("replacement", 150, 1)
+	pending := newTx("pending", 120, 2)
+	_, err := st.InsertTransactions([]types.Transaction{confirmed, original, replacement, pending})
+	require.NoError(t, err)
+
+	block := types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: time.Unix(200, 0).UTC(),
+		Height:    7,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{test.GenerateHash32("coinbase"), confirmed.TxID},
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	var status types.TxStatus
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+confirmed.TxID.String()+"/status", &status))
+	assert.Equal(t, types.TxConfirmed, status.Status)
+	assert.Equal(t, block.Hash, *status.BlockHash)
+	assert.Equal(t, 7, *status.BlockHeight)
+	assert.Equal(t, 1, *status.BlockIndex)
+
+	status = types.TxStatus{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+original.TxID.String()+"/status", &status))
+	assert.Equal(t, types.TxReplaced, status.Status)
+	assert.Equal(t, replacement.TxID, *status.ReplacedBy)
+
+	status = types.TxStatus{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+pending.TxID.String()+"/status", &status))
+	assert.Equal(t, types.TxInMempool, status.Status)
+	assert.Equal(t, pending.FirstSeen, *status.FirstSeen)
+	assert.Equal(t, 5.0, *status.Feerate)
+
+	unseen := test.GenerateHash32("unseen")
+	status = types.TxStatus{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+unseen.String()+"/status", &status))
+	assert.Equal(t, types.TxStatus{TxID: unseen, Status: types.TxUnseen}, status)
+
+	// with a mirror, transactions that are not written yet are in the mempool
+	// and stored transactions that are not in the mirror were removed
+	m := mirror.New()
+	m.Add(newTx("unseen", 300, 3))
+	server.SetMirror(m)
+
+	status = types.TxStatus{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+unseen.String()+"/status", &status))
+	assert.Equal(t, types.TxInMempool, status.Status)
+
+	status = types.TxStatus{}
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+pending.TxID.String()+"/status", &status))
+	assert.Equal(t, types.TxRemoved, status.Status)
+
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/tx/xyz/status", nil))
+
+	var lineage types.RBFLineage
+	require.Equal(t, http.StatusOK, get(t, server, "/v1/tx/"+original.TxID.String()+"/lineage", &lineage))
+	require.Len(t, lineage.Transactions, 2)
+	assert.Equal(t, replacement.TxID, lineage.Transactions[1].TxID)
+	assert.Equal(t, int64(50), lineage.DurationSeconds)
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/tx/"+test.GenerateHash32("unseen").String()+"/lineage", nil))
+}
+
+func TestServer_TxRaw(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	withRaw := types.Transaction{
+		TxID:      test.GenerateHash32("raw"),
+		FirstSeen: time.Unix(100, 0).UTC(),
+		Raw:       []byte{0x02, 0x00, 0x00, 0x00},
+	}
+	withoutRaw := types.Transaction{TxID: test.GenerateHash32("no-raw"), FirstSeen: time.Unix(100, 0).UTC()}
+	_, err := st.InsertTransactions([]types.Transaction{withRaw, withoutRaw})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tx/"+withRaw.TxID.String()+"/raw", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "02000000", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tx/"+withRaw.TxID.String()+"/raw?format=binary", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, withRaw.Raw, rec.Body.Bytes())
+
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/tx/"+withoutRaw.TxID.String()+"/raw", nil))
+	assert.Equal(t, http.StatusNotFound, get(t, server, "/v1/tx/"+test.GenerateHash32("unseen").String()+"/raw", nil))
+	assert.Equal(t, http.StatusBadRequest, get(t, server, "/v1/tx/"+withRaw.TxID.String()+"/raw?format=json", nil))
+}
+
+func TestServer_Transactions(t *testing.T) {
+	server, st := newTestServer(t)
+	defer st.Close()
+
+	// feerates 1, 2 and 3 sat/vB
+	var txs []types.Transaction
+	for i := 1; i <= 3; i++ {
+		txs = append(txs, types.Transaction{
+			TxID:      test.GenerateHash32(fmt.Sprintf("tx-list-%d", i)),
+			FirstSeen: time.Unix(int64(100*i), 0).UTC(),
+			Fee:       uint64(100 * i),
+			Weight:    400,
+		})
+	}
+	_, err := st.InsertTransactions(txs)
+	require.NoError(t, err)
+	_, err = st.InsertBlock(&types.Block{
+		Hash:      test.GenerateHash32("block-list"),
+		FirstSeen: time.Unix(1000, 0).UTC(),
+		Height:    5,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{txs[0].TxID},
+	})
+	require.NoError(t, err)
+
+	list := func(url string) (res types.TransactionList) {
+		code := get(t, server, url, &res)
+		require.Equal(t, http.StatusOK, code)
+		return res
+	}
+	txids := func(res types.TransactionList) (ids []types.Hash32) {
+		for _, tx := range res.Transactions {
+			ids = append(ids, tx.TxID)
+		}
+		return ids
+	}
+
+	res := list("/v1/transactions")
+	assert.Equal(t, []types.Hash32{txs[0].TxID, txs[1].TxID, txs[2].TxID}, txids(res))
+	assert.Empty(t, res.Next)
+
+	assert.Equal(t, []types.Hash32{txs[1].TxID}, txids(list("/v1/transactions?min-feerate=1.5&max-feerate=2.5")))
+	assert.Equal(t, []types.Hash32{txs[1].TxID, txs[2].TxID}, txids(list("/v1/transactions?from=150")))
+	assert.Equal(t, []types.Hash32{txs[0].TxID}, txids(list("/v1/transactions?confirmed=true")))
+	assert.Equal(t, []types.Hash32{txs[0].TxID}, txids(list("/v1/transactions?min-height=5&max-height=5")))
+	assert.Empty(t, txids(list("/v1/transactions?min-height=6")))
+
+	// pages of one transaction
+	res = list("/v1/transactions?confirmed=false&limit=1")
+	assert.Equal(t, []types.Hash32{txs[1].TxID}, txids(res))
+	require.NotEmpty(t, res.Next)
+	res = list("/v1/transactions?confirmed=false&limit=1&after=" + res.Next)
+	assert.Equal(t, []types.Hash32{txs[2].TxID}, txids(res))
+
+	for _, invalid := range []string{"min-feerate=x", "max-height=-1", "confirmed=maybe", "after=1", "from=yesterday"} {
+		var errRes errorResponse
+		code := get(t, server, "/v1/transactions?"+invalid, &errRes)
+		assert.Equal(t, http.StatusBadRequest, code, invalid)
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleTransactions implements `GET /v1/transactions`. Returns stored transactions ordered by
+// first-seen time. Supported query parameters:
+//
+//	from, to:                 range of the first-seen time
+//	min-feerate, max-feerate: range of the feerate in sat/vB
+//	confirmed:                true for transactions in a best-chain block, false for the others
+//	min-height, max-height:   range of the heights of the best-chain blocks with the transaction
+//	after:                    the `next` value of the previous page
+//	limit:                    maximum number of transactions
+//
+// With `Accept: text/csv`, the transactions are streamed from the database as CSV without
+// a default limit, see writeTransactionsCSV.
+func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseTransactionQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	csv := acceptsCSV(r)
+	if csv {
+		if q.MaxResults, err = parseCSVLimit(r); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+	}
+
+	txIter, err := s.storage.QueryTransactions(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if csv {
+		defer txIter.Close()
+		w.Header().Add("Vary", "Accept")
+		writeTransactionsCSV(w, txIter)
+		return
+	}
+	txs, err := txIter.Collect()
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if err := s.setConfirmations(txs); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	res := types.TransactionList{Transactions: make([]types.Transaction, len(txs))}
+	for i, tx := range txs {
+		res.Transactions[i] = tx.Transaction
+	}
+	if len(txs) == q.MaxResults {
+		last := txs[len(txs)-1]
+		res.Next = fmt.Sprintf("%d-%d", last.FirstSeen.Unix(), last.DBID)
+	}
+	writeTransactionsJSON(w, r, http.StatusOK, res)
+}
+
+// parseTransactionQuery returns the storage query for the parameters of `GET /v1/transactions`
+func parseTransactionQuery(r *http.Request) (storage.TransactionQuery, error) {
+	q := storage.TransactionQuery{OrderBy: storage.TxOrderFirstSeen}
+	var err error
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.FirstSeenFrom = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.FirstSeenTo = &to
+	}
+	if q.FirstSeenFrom != nil && q.FirstSeenTo != nil && q.FirstSeenFrom.After(*q.FirstSeenTo) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	if q.MinFeerate, err = parseFloatParam(r, "min-feerate"); err != nil {
+		return q, err
+	}
+	if q.MaxFeerate, err = parseFloatParam(r, "max-feerate"); err != nil {
+		return q, err
+	}
+	if q.MinHeight, err = parseHeightParam(r, "min-height"); err != nil {
+		return q, err
+	}
+	if q.MaxHeight, err = parseHeightParam(r, "max-height"); err != nil {
+		return q, err
+	}
+	if q.MinInputs, err = parseCountParam(r, "min-inputs"); err != nil {
+		return q, err
+	}
+	if q.MinOutputs, err = parseCountParam(r, "min-outputs"); err != nil {
+		return q, err
+	}
+	if q.MinSigOpCost, err = parseCountParam(r, "min-sigop-cost"); err != nil {
+		return q, err
+	}
+
+	if v := r.URL.Query().Get("confirmed"); v != "" {
+		confirmed, err := parseBoolParam(r, "confirmed")
+		if err != nil {
+			return q, err
+		}
+		q.Confirmed = storage.FilterFalse
+		if confirmed {
+			q.Confirmed = storage.FilterTrue
+		}
+	}
+
+	excludeDataCarrier, err := parseBoolParam(r, "exclude-data-carrier")
+	if err != nil {
+		return q, err
+	}
+	if excludeDataCarrier {
+		q.DataCarrier = storage.FilterFalse
+	}
+
+	if v := r.URL.Query().Get("after"); v != "" {
+		if q.FirstSeenAfter, err = parseCursor(v); err != nil {
+			return q, errInvalidParam("after", v)
+		}
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
+
+// parseCursor parses a cursor formatted as `<first-seen unix seconds>-<database id>`
+func parseCursor(v string) (*storage.Cursor, error) {
+	parts := strings.Split(v, "-")
+	if len(parts) != 2 {
+		return nil, errInvalidParam("after", v)
+	}
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	dbid, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Cursor{Time: time.Unix(seconds, 0).UTC(), DBID: dbid}, nil
+}
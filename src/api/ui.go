@@ -0,0 +1,167 @@
+package api
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handleUI serves the web UI at `/`, a single page that renders data of the API in the browser.
+// Other paths without handler are not found.
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if !requireGET(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if _, err := w.Write([]byte(uiHTML)); err != nil {
+		log.Debugf("error writing response: %s", err)
+	}
+}
+
+// uiHTML is the web UI. It is kept in the source rather than embedded from a file,
+// as go:embed requires Go 1.16. It has no external dependencies.
+const uiHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Bademeister</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 960px; padding: 1em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+svg { width: 100%; height: 200px; background: #f6f6f6; }
+svg text { font-size: 10px; fill: #555; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+td, th { text-align: left; padding: 0.2em 0.5em; border-bottom: 1px solid #ddd; }
+.mono { font-family: monospace; }
+.error { color: #a00; }
+input { width: 40em; max-width: 100%; font-family: monospace; }
+pre { background: #f6f6f6; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Bademeister</h1>
+
+<h2>Mempool size (last 24 hours)</h2>
+<svg id="size"></svg>
+
+<h2>Feerate histogram (live)</h2>
+<div id="histogram-info"></div>
+<svg id="histogram"></svg>
+
+<h2>Recent blocks</h2>
+<table>
+<thead><tr><th>Height</th><th>Hash</th><th>First seen</th><th>Transactions</th><th>Weight</th><th>Miner</th></tr></thead>
+<tbody id="blocks"></tbody>
+</table>
+
+<h2>Transaction lookup</h2>
+<form id="lookup"><input id="txid" placeholder="txid" autocomplete="off"> <button>Look up</button></form>
+<pre id="tx" hidden></pre>
+
+<script>
+"use strict";
+
+function getJSON(path) {
+  return fetch(path).then(function (res) {
+    return res.json().then(function (body) {
+      if (!res.ok) { throw new Error(body.error || res.statusText); }
+      return body;
+    });
+  });
+}
+
+function showError(id, err) {
+  var p = document.createElement("p");
+  p.className = "error";
+  p.textContent = String(err.message || err);
+  document.getElementById(id).replaceWith(p);
+}
+
+// bars draws the values as bar chart into the svg element with the id
+function bars(id, values, label) {
+  var svg = document.getElementById(id);
+  var w = svg.clientWidth, h = svg.clientHeight, pad = 14;
+  var max = Math.max.apply(null, values.concat([1]));
+  var bw = w / Math.max(values.length, 1);
+  var out = "";
+  values.forEach(function (v, i) {
+    var bh = (h - pad) * v / max;
+    out += '<rect x="' + (i * bw) + '" y="' + (h - pad - bh) + '" width="' + Math.max(bw - 1, 1) +
+      '" height="' + bh + '" fill="#4a7ab5"><title>' + label(i) + '</title></rect>';
+  });
+  out += '<text x="2" y="10">' + max.toLocaleString() + '</text>';
+  if (values.length > 0) {
+    out += '<text x="2" y="' + (h - 2) + '">' + label(0) + '</text>';
+    out += '<text x="' + (w - 2) + '" y="' + (h - 2) + '" text-anchor="end">' + label(values.length - 1) + '</text>';
+  }
+  svg.innerHTML = out;
+}
+
+function loadSize() {
+  getJSON("/v1/stats/witness-heavy").then(function (points) {
+    points = points || [];
+    bars("size", points.map(function (p) { return p.vsize; }), function (i) {
+      var p = points[i];
+      return new Date(p.time).toLocaleString() + ": " + p.transactions + " tx, " + p.vsize + " vB";
+    });
+  }).catch(function (err) { showError("size", err); });
+}
+
+function connectHistogram() {
+  var scheme = location.protocol === "https:" ? "wss://" : "ws://";
+  var ws = new WebSocket(scheme + location.host + "/v1/mempool/histogram");
+  ws.onmessage = function (msg) {
+    var hist = JSON.parse(msg.data);
+    document.getElementById("histogram-info").textContent = hist.transactions + " transactions, " +
+      (hist.weight / 4).toLocaleString() + " vB, " + (hist.fees / 1e8) + " BTC fees at " +
+      new Date(hist.time).toLocaleTimeString();
+    var weights = hist.weights || [];
+    bars("histogram", weights.map(function (w) { return w / 4; }), function (i) {
+      return hist.feerates[i] + " sat/vB: " + hist.counts[i] + " tx, " + (weights[i] / 4) + " vB";
+    });
+  };
+  ws.onclose = function () { setTimeout(connectHistogram, 5000); };
+}
+
+function loadBlocks() {
+  getJSON("/v1/blocks/tip").then(function (tip) {
+    return getJSON("/v1/blocks/" + tip.hash + "/ancestors?limit=10");
+  }).then(function (blocks) {
+    document.getElementById("blocks").innerHTML = blocks.map(function (b) {
+      return "<tr><td>" + b.height + '</td><td class="mono">' + b.hash.substr(0, 16) + "…</td><td>" +
+        new Date(b.firstSeen).toLocaleString() + "</td><td>" + b.txCount + "</td><td>" + b.weight +
+        "</td><td>" + (b.miner || "") + "</td></tr>";
+    }).join("");
+  }).catch(function (err) { showError("blocks", err); });
+}
+
+document.getElementById("lookup").onsubmit = function (e) {
+  e.preventDefault();
+  var txid = document.getElementById("txid").value.trim();
+  var out = document.getElementById("tx");
+  out.hidden = false;
+  getJSON("/v1/tx/" + encodeURIComponent(txid)).then(function (tx) {
+    out.className = "";
+    out.textContent = JSON.stringify(tx, null, 2);
+  }).catch(function (err) {
+    out.className = "error";
+    out.textContent = err.message;
+  });
+};
+
+loadSize();
+connectHistogram();
+loadBlocks();
+setInterval(loadBlocks, 60000);
+setInterval(loadSize, 600000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+// handleWhales implements `GET /v1/whales`. Returns the transactions above the whale threshold
+// of the daemon, most recent first. Supported query parameters:
+//
+//	from, to:   range of the first-seen time
+//	min-value:  minimum total output value in BTC
+//	confirmed:  true for transactions in a best-chain block, false for the others
+//	limit:      maximum number of transactions
+func (s *Server) handleWhales(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseWhaleQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	whales, err := s.storage.WhaleTransactions(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, whales)
+}
+
+// parseWhaleQuery returns the storage query for the parameters of `GET /v1/whales`
+func parseWhaleQuery(r *http.Request) (storage.WhaleQuery, error) {
+	var q storage.WhaleQuery
+	var err error
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.FirstSeenFrom = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.FirstSeenTo = &to
+	}
+	if q.FirstSeenFrom != nil && q.FirstSeenTo != nil && q.FirstSeenFrom.After(*q.FirstSeenTo) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	minValue, err := parseFloatParam(r, "min-value")
+	if err != nil {
+		return q, err
+	}
+	if minValue != nil {
+		amount, err := btcutil.NewAmount(*minValue)
+		if err != nil || amount > btcutil.MaxSatoshi {
+			return q, errInvalidParam("min-value", r.URL.Query().Get("min-value"))
+		}
+		q.MinValue = int64(amount)
+	}
+
+	if v := r.URL.Query().Get("confirmed"); v != "" {
+		confirmed, err := parseBoolParam(r, "confirmed")
+		if err != nil {
+			return q, err
+		}
+		q.Confirmed = storage.FilterFalse
+		if confirmed {
+			q.Confirmed = storage.FilterTrue
+		}
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
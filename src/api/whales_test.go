@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+func TestParseWhaleQuery(t *testing.T) {
+	q, err := parseWhaleQuery(httptest.NewRequest("GET", "/v1/whales?min-value=12.5&confirmed=false&limit=10", nil))
+	require.NoError(t, err)
+	assert.Equal(t, storage.WhaleQuery{MinValue: 1250000000, Confirmed: storage.FilterFalse, MaxResults: 10}, q)
+
+	for _, query := range []string{"min-value=-1", "min-value=30000000", "confirmed=x", "from=2&to=1"} {
+		_, err := parseWhaleQuery(httptest.NewRequest("GET", "/v1/whales?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// handleWitnessReplacements implements `GET /v1/witness-replacements`. Returns the transactions
+// that were received again with a different witness, most recent first. Supported query parameters:
+//
+//	txid:       replacements of the transaction
+//	from, to:   range of the time of the replacement
+//	limit:      maximum number of results
+func (s *Server) handleWitnessReplacements(w http.ResponseWriter, r *http.Request) {
+	if !requireGET(w, r) {
+		return
+	}
+
+	q, err := parseWitnessReplacementQuery(r)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	replacements, err := s.storage.WitnessReplacements(q)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, replacements)
+}
+
+// parseWitnessReplacementQuery returns the storage query for the parameters of `GET /v1/witness-replacements`
+func parseWitnessReplacementQuery(r *http.Request) (storage.WitnessReplacementQuery, error) {
+	var q storage.WitnessReplacementQuery
+	var err error
+
+	if v := r.URL.Query().Get("txid"); v != "" {
+		txid, err := types.NewHashFromString(v)
+		if err != nil {
+			return q, errInvalidParam("txid", v)
+		}
+		q.TxID = &txid
+	}
+
+	if r.URL.Query().Get("from") != "" {
+		from, err := parseTimeParam(r, "from", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.From = &from
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := parseTimeParam(r, "to", time.Time{})
+		if err != nil {
+			return q, err
+		}
+		q.To = &to
+	}
+	if q.From != nil && q.To != nil && q.From.After(*q.To) {
+		return q, errInvalidParamExpected("from", r.URL.Query().Get("from"), "a time not after `to`")
+	}
+
+	q.MaxResults, err = parseLimit(r)
+	return q, err
+}
@@ -0,0 +1,126 @@
+// Package bitcoinrest reads the mempool and blocks from the REST interface of Bitcoin Core (`-rest`).
+// The REST interface needs no authentication and has less overhead than RPC for bulk reads.
+package bitcoinrest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
+)
+
+// requestTimeout limits the time of a single request. The mempool contents can take a while.
+const requestTimeout = 5 * time.Minute
+
+// Client reads from the REST interface at Address
+type Client struct {
+	Address string
+	http    *http.Client
+}
+
+// NewClient returns a Client for the REST interface at `address`, e.g. http://127.0.0.1:8332
+func NewClient(address string) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid rest address %q", address)
+	}
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		http:    &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// get returns the response body of `/rest/<path>`
+func (c *Client) get(path string) ([]byte, error) {
+	resp, err := c.http.Get(c.Address + "/rest/" + path)
+	if err != nil {
+		return nil, errors.Wrap(err, "rest")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, errors.Errorf("rest: %s: status %d: %s", path, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rest: %s", path)
+	}
+	return body, nil
+}
+
+// GetRawMempoolVerbose returns the transactions in the mempool, like the RPC `getrawmempool true`
+func (c *Client) GetRawMempoolVerbose() (map[string]bitcoinrpcclient.GetRawMempoolVerboseResult, error) {
+	body, err := c.get("mempool/contents.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var mempoolItems map[string]bitcoinrpcclient.GetRawMempoolVerboseResult
+	if err := json.Unmarshal(body, &mempoolItems); err != nil {
+		return nil, errors.Wrap(err, "rest: could not parse mempool contents")
+	}
+	return mempoolItems, nil
+}
+
+// GetBestBlockHash returns the hash of the best block
+func (c *Client) GetBestBlockHash() (*chainhash.Hash, error) {
+	body, err := c.get("chaininfo.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		BestBlockHash string `json:"bestblockhash"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, errors.Wrap(err, "rest: could not parse chaininfo")
+	}
+	hash, err := chainhash.NewHashFromStr(info.BestBlockHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "rest: invalid bestblockhash")
+	}
+	return hash, nil
+}
+
+// GetBlock returns the block with `hash`
+func (c *Client) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	body, err := c.get("block/" + hash.String() + ".bin")
+	if err != nil {
+		return nil, err
+	}
+
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(body)); err != nil {
+		return nil, errors.Wrapf(err, "rest: could not decode block %s", hash)
+	}
+	return &block, nil
+}
+
+// GetBlockHeader returns the header of the block with `hash`
+func (c *Client) GetBlockHeader(hash *chainhash.Hash) (*wire.BlockHeader, error) {
+	body, err := c.get("headers/1/" + hash.String() + ".bin")
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, errors.Errorf("rest: block %s not found", hash)
+	}
+
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(body)); err != nil {
+		return nil, errors.Wrapf(err, "rest: could not decode header %s", hash)
+	}
+	return &header, nil
+}
@@ -0,0 +1,71 @@
+package bitcoinrest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var zeroHash chainhash.Hash
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &zeroHash, &zeroHash, 0x207fffff, 0))
+	block.Header.Timestamp = time.Unix(1231006505, 0)
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{SignatureScript: []byte{0x01}, Sequence: wire.MaxTxInSequenceNum})
+	coinbase.AddTxOut(&wire.TxOut{Value: 5000000000, PkScript: []byte{0x51}})
+	require.NoError(t, block.AddTransaction(coinbase))
+	var blockData bytes.Buffer
+	require.NoError(t, block.Serialize(&blockData))
+	hash := block.BlockHash()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/mempool/contents.json":
+			_, _ = w.Write([]byte(`{"00ff": {"weight": 400, "time": 1600000000, "fees": {"base": 0.00001}}}`))
+		case "/rest/chaininfo.json":
+			_, _ = w.Write([]byte(`{"chain": "regtest", "bestblockhash": "` + hash.String() + `"}`))
+		case "/rest/block/" + hash.String() + ".bin":
+			_, _ = w.Write(blockData.Bytes())
+		case "/rest/headers/1/" + hash.String() + ".bin":
+			_, _ = w.Write(blockData.Bytes()[:wire.MaxBlockHeaderPayload])
+		default:
+			http.Error(w, "Block not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewClient("localhost:8332")
+	assert.Error(t, err)
+	c, err := NewClient(server.URL + "/")
+	require.NoError(t, err)
+
+	mempool, err := c.GetRawMempoolVerbose()
+	require.NoError(t, err)
+	require.Contains(t, mempool, "00ff")
+	assert.Equal(t, int32(400), mempool["00ff"].Weight)
+	assert.Equal(t, 0.00001, mempool["00ff"].Fees.Base)
+
+	best, err := c.GetBestBlockHash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, *best)
+
+	got, err := c.GetBlock(best)
+	require.NoError(t, err)
+	assert.Equal(t, hash, got.BlockHash())
+	assert.Len(t, got.Transactions, 1)
+
+	header, err := c.GetBlockHeader(best)
+	require.NoError(t, err)
+	assert.Equal(t, hash, header.BlockHash())
+
+	_, err = c.GetBlock(&coinbase.TxIn[0].PreviousOutPoint.Hash)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 404")
+}
@@ -2,7 +2,6 @@ package bitcoinrpcclient
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/url"
 	"os"
 	"time"
@@ -103,7 +102,7 @@ func (rpcClient *BitcoinRPCClient) waitTillRPCServerReady(timeout time.Duration)
 			return nil
 		}
 	}
-	return fmt.Errorf("timed out after %s while waiting for the Bitcoin Core RPC Server to be ready", timeout)
+	return errors.Errorf("timed out after %s while waiting for the Bitcoin Core RPC Server to be ready", timeout)
 }
 
 func (rpcClient *BitcoinRPCClient) generateToHeight(targetHeight int) ([]*chainhash.Hash, error) {
@@ -120,7 +119,7 @@ func (rpcClient *BitcoinRPCClient) generateToHeight(targetHeight int) ([]*chainh
 
 // Generate is deprecated, see error message
 func (rpcClient *BitcoinRPCClient) Generate(nBlocks int) ([]*chainhash.Hash, error) {
-	return nil, fmt.Errorf("deprecated, use GenerateToAddress(int, Address) or GenerateToFixedAddress(int)")
+	return nil, errors.Errorf("deprecated, use GenerateToAddress(int, Address) or GenerateToFixedAddress(int)")
 }
 
 // GenerateToAddress mines `nBlocks` to the passed address and returns the block
@@ -133,23 +132,23 @@ func (rpcClient *BitcoinRPCClient) GenerateToAddress(nBlocks int, address btcuti
 
 	jsonNBlocks, err := json.Marshal(nBlocks)
 	if err != nil {
-		return nil, fmt.Errorf("could not JSON marshal nBlocks (%d): %s", nBlocks, err)
+		return nil, errors.Errorf("could not JSON marshal nBlocks (%d): %s", nBlocks, err)
 	}
 
 	jsonAddress, err := json.Marshal(address.String())
 	if err != nil {
-		return nil, fmt.Errorf("could not JSON marshal address (%s): %s", address.String(), err)
+		return nil, errors.Errorf("could not JSON marshal address (%s): %s", address.String(), err)
 	}
 
 	res, err := rpcClient.RawRequest("generatetoaddress", []json.RawMessage{jsonNBlocks, jsonAddress})
 	if err != nil {
-		return nil, fmt.Errorf("the rawRequest '%s' failed: %s", "generatetoaddress", err)
+		return nil, errors.Errorf("the rawRequest '%s' failed: %s", "generatetoaddress", err)
 	}
 
 	var result []string
 	err = json.Unmarshal(res, &result)
 	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal the response as JSON: %s", err)
+		return nil, errors.Errorf("could not unmarshal the response as JSON: %s", err)
 	}
 
 	// Convert each block hash to a chainhash.Hash and store a pointer to each.
@@ -157,7 +156,7 @@ func (rpcClient *BitcoinRPCClient) GenerateToAddress(nBlocks int, address btcuti
 	for i, hashString := range result {
 		chainhashes[i], err = chainhash.NewHashFromStr(hashString)
 		if err != nil {
-			return nil, fmt.Errorf("could not create a new chainhash from '%s': %s", hashString, err)
+			return nil, errors.Errorf("could not create a new chainhash from '%s': %s", hashString, err)
 		}
 	}
 
@@ -175,7 +174,7 @@ func (rpcClient *BitcoinRPCClient) GenerateToFixedAddress(nBlocks int) ([]*chain
 func (rpcClient *BitcoinRPCClient) SendSimpleTransaction(address btcutil.Address) (*chainhash.Hash, error) {
 	amount, err := btcutil.NewAmount(0.1)
 	if err != nil {
-		return nil, fmt.Errorf("could not create a new amount from %f: %s", 0.1, err)
+		return nil, errors.Errorf("could not create a new amount from %f: %s", 0.1, err)
 	}
 
 	txid, err := rpcClient.SendToAddress(address, amount)
@@ -193,7 +192,7 @@ func (c *BitcoinRPCClient) SendCustomTransaction(address btcutil.Address) (*chai
 	unspend, err := e.rpc.ListUnspentMin(100)
 
 	if len(unspend) == 0 {
-		return nil, fmt.Errorf("no spendable inputs avaliable: %s", err)
+		return nil, errors.Errorf("no spendable inputs avaliable: %s", err)
 	}
 
 	first := unspend[1]
@@ -204,7 +203,7 @@ func (c *BitcoinRPCClient) SendCustomTransaction(address btcutil.Address) (*chai
 
 	amount, err := btcutil.NewAmount(first.Amount)
 	if err != nil {
-		return nil, fmt.Errorf("could not create a new amount from %f: %s", first.Amount, err)
+		return nil, errors.Errorf("could not create a new amount from %f: %s", first.Amount, err)
 	}
 	recipients := make(map[btcutil.Address]btcutil.Amount)
 
@@ -213,17 +212,17 @@ func (c *BitcoinRPCClient) SendCustomTransaction(address btcutil.Address) (*chai
 
 	unsignedTx, err := e.rpc.CreateRawTransaction([]btcjson.TransactionInput{input}, recipients, &locktime)
 	if err != nil {
-		return nil, fmt.Errorf("could not create a raw transaction: %s", err)
+		return nil, errors.Errorf("could not create a raw transaction: %s", err)
 	}
 
 	signedTx, _, err := e.rpc.SignRawTransaction(unsignedTx)
 	if err != nil {
-		return nil, fmt.Errorf("could not sign the raw transaction: %s", err)
+		return nil, errors.Errorf("could not sign the raw transaction: %s", err)
 	}
 
 	txid, err := e.rpc.SendRawTransaction(signedTx, true)
 	if err != nil {
-		return nil, fmt.Errorf("could not send the raw transaction: %s", err)
+		return nil, errors.Errorf("could not send the raw transaction: %s", err)
 	}
 	return txid, nil
 }
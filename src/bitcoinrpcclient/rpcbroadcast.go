@@ -0,0 +1,29 @@
+package bitcoinrpcclient
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// SendRawTransactionBytes broadcasts the serialized transaction `raw` with `sendrawtransaction`
+// and returns its txid in RPC byte order.
+// The method of btcsuite sends parameters that newer node versions reject.
+func (rpcClient *BitcoinRPCClient) SendRawTransactionBytes(raw []byte) (string, error) {
+	jsonHex, err := json.Marshal(hex.EncodeToString(raw))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	rawResult, err := rpcClient.RawRequest("sendrawtransaction", []json.RawMessage{jsonHex})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var txid string
+	if err := json.Unmarshal(rawResult, &txid); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return txid, nil
+}
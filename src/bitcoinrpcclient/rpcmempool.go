@@ -1,8 +1,8 @@
 package bitcoinrpcclient
 
 import (
-	"encoding/hex"
 	"encoding/json"
+	"math"
 	"time"
 
 	"github.com/pkg/errors"
@@ -50,22 +50,20 @@ func RawMempoolToTransactions(
 	rpcMempool map[string]GetRawMempoolVerboseResult,
 ) (res []types.Transaction, err error) {
 	for txHashStr, txInfo := range rpcMempool {
-		bytes, err := hex.DecodeString(txHashStr)
+		txid, err := types.NewHashFromString(txHashStr)
 		if err != nil {
-			return nil, errors.Wrapf(err, "error decoding tx hash %s", txHashStr)
+			return nil, err
 		}
 
-		if len(bytes) != 32 {
-			return nil, errors.Wrapf(err, "invalid txhash size %d", len(bytes))
-		}
-
-		firstSeen := time.Unix(txInfo.Time, 0)
+		firstSeen := time.Unix(txInfo.Time, 0).UTC()
+		// rounded, truncating the amount in BTC can be a satoshi less than the fee sent via ZMQ
+		fee := uint64(math.Round(txInfo.Fees.Base * 1e8))
 
 		tx := types.Transaction{
-			TxID:        types.NewHashFromBytes(bytes),
+			TxID:        txid,
 			FirstSeen:   firstSeen,
 			LastRemoved: nil,
-			Fee:         uint64(txInfo.Fees.Base * 1e8),
+			Fee:         fee,
 			Weight:      int(txInfo.Weight),
 		}
 
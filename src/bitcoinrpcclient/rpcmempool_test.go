@@ -36,7 +36,7 @@ func TestBitcoinRPCClient_GetRawMempoolVerbose(t *testing.T) {
 	for i := 0; i < nTransactions; i++ {
 		txid, err := rpcClient.SendSimpleTransaction(addressSendTo)
 		require.NoError(t, err)
-		generatedTxIDs[types.NewHashFromArray(*txid).Reversed()] = struct{}{}
+		generatedTxIDs[types.NewHashFromArray(*txid)] = struct{}{}
 	}
 
 	end := time.Now().Add(time.Second)
@@ -73,3 +73,18 @@ func TestBitcoinRPCClient_GetRawMempoolVerbose(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, mempool, 0)
 }
+
+func TestRawMempoolToTransactions(t *testing.T) {
+	// txids are shown in reversed byte order by the RPC
+	const rpcTxID = "00000000000000000000000000000000000000000000000000000000000000ff"
+	txs, err := RawMempoolToTransactions(map[string]GetRawMempoolVerboseResult{
+		rpcTxID: {Weight: 400, Time: 100},
+	})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+
+	var txid types.Hash32
+	txid[0] = 0xff
+	assert.Equal(t, txid, txs[0].TxID)
+	assert.Equal(t, 400, txs[0].Weight)
+}
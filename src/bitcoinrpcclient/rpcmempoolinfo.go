@@ -0,0 +1,57 @@
+package bitcoinrpcclient
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// GetMempoolInfoResult implements the result of `getmempoolinfo`.
+// https://bitcoin.org/en/developer-reference#getmempoolinfo
+// The version provided by btcsuite lacks the fee fields.
+type GetMempoolInfoResult struct {
+	Loaded        bool    `json:"loaded"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	Usage         int64   `json:"usage"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
+}
+
+// GetMempoolInfo returns the state of the node mempool
+func (rpcClient *BitcoinRPCClient) GetMempoolInfo() (*GetMempoolInfoResult, error) {
+	rawResult, err := rpcClient.RawRequest("getmempoolinfo", nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result GetMempoolInfoResult
+	if err := json.Unmarshal(rawResult, &result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &result, nil
+}
+
+// btcPerKvBToSatPerKvB converts a feerate from BTC/kvB to sat/kvB
+func btcPerKvBToSatPerKvB(feerate float64) int64 {
+	return int64(math.Round(feerate * 1e8))
+}
+
+// MempoolInfoToTypes converts the result of GetMempoolInfo to types.MempoolInfo
+func MempoolInfoToTypes(t time.Time, info *GetMempoolInfoResult) types.MempoolInfo {
+	return types.MempoolInfo{
+		Time:          t.UTC(),
+		Size:          info.Size,
+		Bytes:         info.Bytes,
+		Usage:         info.Usage,
+		MaxMempool:    info.MaxMempool,
+		MempoolMinFee: btcPerKvBToSatPerKvB(info.MempoolMinFee),
+		MinRelayTxFee: btcPerKvBToSatPerKvB(info.MinRelayTxFee),
+	}
+}
@@ -0,0 +1,42 @@
+package bitcoinrpcclient
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestMempoolInfoToTypes(t *testing.T) {
+	raw := `{
+		"loaded": true, "size": 12, "bytes": 3400, "usage": 15000,
+		"maxmempool": 300000000, "mempoolminfee": 0.00001000, "minrelaytxfee": 0.00001000
+	}`
+	var result GetMempoolInfoResult
+	require.NoError(t, json.Unmarshal([]byte(raw), &result))
+
+	now := time.Unix(1577836800, 0)
+	info := MempoolInfoToTypes(now, &result)
+	assert.Equal(t, now.UTC(), info.Time)
+	assert.Equal(t, int64(12), info.Size)
+	assert.Equal(t, int64(3400), info.Bytes)
+	assert.Equal(t, int64(300000000), info.MaxMempool)
+	assert.Equal(t, int64(1000), info.MempoolMinFee)
+	assert.Equal(t, int64(1000), info.MinRelayTxFee)
+}
+
+func TestBitcoinRPCClient_GetMempoolInfo(t *testing.T) {
+	test.SkipIfShort(t)
+
+	rpcClient, err := NewBitcoinRPCClientForIntegrationTest()
+	require.NoError(t, err)
+
+	info, err := rpcClient.GetMempoolInfo()
+	require.NoError(t, err)
+	assert.True(t, info.Loaded)
+	assert.Greater(t, info.MinRelayTxFee, 0.0)
+}
@@ -0,0 +1,39 @@
+package bitcoinrpcclient
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// GetNodeConfig collects the node settings from `getnetworkinfo`, `getblockchaininfo` and `getmempoolinfo`
+func (rpcClient *BitcoinRPCClient) GetNodeConfig() (*types.NodeConfig, error) {
+	networkInfo, err := rpcClient.GetNetworkInfo()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getnetworkinfo")
+	}
+
+	chainInfo, err := rpcClient.GetBlockChainInfo()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getblockchaininfo")
+	}
+
+	mempoolInfo, err := rpcClient.GetMempoolInfo()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getmempoolinfo")
+	}
+
+	return &types.NodeConfig{
+		Time:            time.Now().UTC(),
+		Version:         networkInfo.Version,
+		SubVersion:      networkInfo.SubVersion,
+		ProtocolVersion: networkInfo.ProtocolVersion,
+		Chain:           chainInfo.Chain,
+		MaxMempool:      mempoolInfo.MaxMempool,
+		MinRelayTxFee:   btcPerKvBToSatPerKvB(networkInfo.RelayFee),
+		IncrementalFee:  btcPerKvBToSatPerKvB(networkInfo.IncrementalFee),
+		LocalRelay:      networkInfo.LocalRelay,
+	}, nil
+}
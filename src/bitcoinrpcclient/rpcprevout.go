@@ -0,0 +1,131 @@
+package bitcoinrpcclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/pkg/errors"
+)
+
+// getTxOutResult is the part of the result of `gettxout` used by PrevoutValues
+type getTxOutResult struct {
+	// in BTC
+	Value float64 `json:"value"`
+}
+
+// PrevoutValues returns the values in satoshis of the outputs `outpoints` spent by a transaction
+// in the mempool of the node. Outputs of confirmed transactions are read from the UTXO set with
+// `gettxout`, those of transactions in the mempool with `getrawtransaction`, so no `-txindex` is
+// needed. Fails if an output is neither, e.g. because the spending transaction was confirmed since.
+func (rpcClient *BitcoinRPCClient) PrevoutValues(outpoints []wire.OutPoint) ([]int64, error) {
+	values := make([]int64, len(outpoints))
+	// outputs of the unconfirmed parents
+	parents := map[chainhash.Hash][]*wire.TxOut{}
+	for i, outpoint := range outpoints {
+		outputs, ok := parents[outpoint.Hash]
+		if !ok {
+			value, found, err := rpcClient.utxoValue(outpoint)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				values[i] = value
+				continue
+			}
+			if outputs, err = rpcClient.mempoolTxOutputs(outpoint.Hash); err != nil {
+				return nil, errors.Wrapf(err, "prevout %s not found", outpoint)
+			}
+			parents[outpoint.Hash] = outputs
+		}
+		if int(outpoint.Index) >= len(outputs) {
+			return nil, errors.Errorf("prevout %s does not exist", outpoint)
+		}
+		values[i] = outputs[outpoint.Index].Value
+	}
+	return values, nil
+}
+
+// InMempool returns true if the transaction `txid` is in the mempool of the node, read with
+// `getmempoolentry`. The node removes the transactions of a connected block from its mempool
+// before it publishes them via `rawtx`.
+func (rpcClient *BitcoinRPCClient) InMempool(txid chainhash.Hash) (bool, error) {
+	params, err := marshalParams(txid.String())
+	if err != nil {
+		return false, err
+	}
+	_, err = rpcClient.RawRequest("getmempoolentry", params)
+	if rpcErr, ok := err.(*btcjson.RPCError); ok && rpcErr.Code == btcjson.ErrRPCInvalidAddressOrKey {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
+
+// utxoValue returns the value of the confirmed unspent output `outpoint`, ignoring the mempool.
+// Returns false if the output is not in the UTXO set.
+func (rpcClient *BitcoinRPCClient) utxoValue(outpoint wire.OutPoint) (int64, bool, error) {
+	params, err := marshalParams(outpoint.Hash.String(), outpoint.Index, false)
+	if err != nil {
+		return 0, false, err
+	}
+	rawResult, err := rpcClient.RawRequest("gettxout", params)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	var result *getTxOutResult
+	if err := json.Unmarshal(rawResult, &result); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if result == nil {
+		return 0, false, nil
+	}
+	return int64(math.Round(result.Value * 1e8)), true, nil
+}
+
+// mempoolTxOutputs returns the outputs of the transaction `txid`, which must be in the mempool
+// unless the node has `-txindex`
+func (rpcClient *BitcoinRPCClient) mempoolTxOutputs(txid chainhash.Hash) ([]*wire.TxOut, error) {
+	params, err := marshalParams(txid.String())
+	if err != nil {
+		return nil, err
+	}
+	rawResult, err := rpcClient.RawRequest("getrawtransaction", params)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var rawHex string
+	if err := json.Unmarshal(rawResult, &rawHex); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	raw, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return tx.TxOut, nil
+}
+
+// marshalParams returns `params` as parameters of RawRequest
+func marshalParams(params ...interface{}) ([]json.RawMessage, error) {
+	res := make([]json.RawMessage, len(params))
+	for i, p := range params {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res[i] = data
+	}
+	return res, nil
+}
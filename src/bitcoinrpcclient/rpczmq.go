@@ -0,0 +1,32 @@
+package bitcoinrpcclient
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// ZMQNotification is an entry of the result of `getzmqnotifications`
+type ZMQNotification struct {
+	// Notification type, e.g. "pubrawblock"
+	Type string `json:"type"`
+	// Address of the publisher, e.g. "tcp://127.0.0.1:28332"
+	Address string `json:"address"`
+	// Outbound message high water mark
+	HWM int `json:"hwm"`
+}
+
+// GetZMQNotifications returns the active ZMQ notifications of the node
+func (rpcClient *BitcoinRPCClient) GetZMQNotifications() ([]ZMQNotification, error) {
+	rawResult, err := rpcClient.RawRequest("getzmqnotifications", nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var result []ZMQNotification
+	if err := json.Unmarshal(rawResult, &result); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
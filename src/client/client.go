@@ -0,0 +1,143 @@
+// Package client implements a Go client for the bademeister API.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ErrNotFound is returned if the requested resource does not exist
+var ErrNotFound = types.ErrNotFound
+
+// Client wraps the HTTP and WebSocket API of a bademeister API server
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for the API server at `baseURL` (e.g. "http://127.0.0.1:8080")
+func NewClient(baseURL string) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// url returns the absolute url for `path` and `query`
+func (c *Client) url(path string, query url.Values) string {
+	u := *c.baseURL
+	u.Path = u.Path + path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// apiError is the body of responses with non-2xx status
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// get performs a GET request and decodes the JSON response into `v`
+func (c *Client) get(path string, query url.Values, v interface{}) error {
+	resp, err := c.httpClient.Get(c.url(path, query))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e apiError
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return errors.Errorf("unexpected status %s", resp.Status)
+		}
+		return errors.Errorf("api error (%s): %s", resp.Status, e.Error)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func formatTime(t time.Time) string {
+	return fmt.Sprintf("%d", t.Unix())
+}
+
+// GetTransaction returns the transaction with `txid`.
+// Returns ErrNotFound if the transaction is unknown.
+func (c *Client) GetTransaction(txid types.Hash32) (*types.Transaction, error) {
+	var tx types.Transaction
+	if err := c.get("/v1/tx/"+txid.String(), nil, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// GetMempoolAt returns the reconstructed mempool at time `t`
+func (c *Client) GetMempoolAt(t time.Time) (*types.MempoolSnapshot, error) {
+	var snapshot types.MempoolSnapshot
+	query := url.Values{"at": {formatTime(t)}}
+	if err := c.get("/v1/mempool", query, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SearchTxIDPrefix returns up to `limit` transactions with a txid starting with `prefix`
+func (c *Client) SearchTxIDPrefix(prefix string, limit int) (*types.SearchResult, error) {
+	var res types.SearchResult
+	query := url.Values{
+		"txid-prefix": {prefix},
+		"limit":       {fmt.Sprintf("%d", limit)},
+	}
+	if err := c.get("/v1/search", query, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// StreamEvents calls `handler` for every mempool event after `since`, including events
+// that happen while streaming. Blocks until the connection is closed or `handler` returns
+// an error, which is then returned.
+func (c *Client) StreamEvents(since time.Time, handler func(*types.MempoolEvent) error) error {
+	u := *c.baseURL
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = u.Path + "/v1/events"
+	u.RawQuery = url.Values{"since": {formatTime(since)}}.Encode()
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to event stream")
+	}
+	defer conn.Close()
+
+	for {
+		var event types.MempoolEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		if err := handler(&event); err != nil {
+			return err
+		}
+	}
+}
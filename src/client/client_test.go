@@ -0,0 +1,94 @@
+package client
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/api"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+var errStop = errors.New("stop")
+
+func newTestTransaction(name string, firstSeen int64) *types.Transaction {
+	return &types.Transaction{
+		TxID:      test.GenerateHash32(name),
+		FirstSeen: time.Unix(firstSeen, 0).UTC(),
+		Fee:       1000,
+		Weight:    800,
+	}
+}
+
+func TestClient(t *testing.T) {
+	test.SkipIfShort(t)
+
+	path := os.Getenv("TEST_INTEGRATION_DIR") + "/client.db"
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	st, err := storage.NewStorage(path)
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx1 := newTestTransaction("tx-1", 100)
+	_, err = st.InsertTransaction(tx1)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(api.NewServer(st))
+	defer server.Close()
+
+	c, err := NewClient(server.URL)
+	require.NoError(t, err)
+
+	tx, err := c.GetTransaction(tx1.TxID)
+	require.NoError(t, err)
+	// the API adds the confirmations of the unconfirmed transaction
+	confirmations := 0
+	tx1.Confirmations = &confirmations
+	assert.Equal(t, tx1, tx)
+
+	_, err = c.GetTransaction(test.GenerateHash32("unknown"))
+	assert.Equal(t, ErrNotFound, err)
+
+	snapshot, err := c.GetMempoolAt(time.Unix(99, 0))
+	require.NoError(t, err)
+	assert.Len(t, snapshot.Transactions, 0)
+
+	snapshot, err = c.GetMempoolAt(time.Unix(100, 0))
+	require.NoError(t, err)
+	require.Len(t, snapshot.Transactions, 1)
+	assert.Equal(t, tx1.TxID, snapshot.Transactions[0].TxID)
+
+	res, err := c.SearchTxIDPrefix(tx1.TxID.String()[:4], 10)
+	require.NoError(t, err)
+	require.Len(t, res.Transactions, 1)
+
+	// transactions inserted while streaming are received
+	tx2 := newTestTransaction("tx-2", 200)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_, err := st.InsertTransaction(tx2)
+		assert.NoError(t, err)
+	}()
+
+	events := []types.MempoolEvent{}
+	err = c.StreamEvents(time.Unix(0, 0), func(e *types.MempoolEvent) error {
+		events = append(events, *e)
+		if len(events) == 2 {
+			return errStop
+		}
+		return nil
+	})
+	require.Equal(t, errStop, err)
+	assert.Equal(t, string(storage.EnterMempool), events[0].Type)
+	assert.Equal(t, tx1.TxID, events[0].AddTransactions[0].TxID)
+	assert.Equal(t, tx2.TxID, events[1].AddTransactions[0].TxID)
+}
@@ -0,0 +1,109 @@
+// Package compare reports the differences between mempools recorded by two collectors,
+// e.g. to validate the network placement of a collector, and estimates the network first-seen
+// times of transactions from several collectors.
+package compare
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// FirstSeenDeltas summarizes `firstSeen(B) - firstSeen(A)` of the transactions seen by both collectors.
+// Positive values mean that collector A saw the transaction first.
+type FirstSeenDeltas struct {
+	Min    time.Duration `json:"min"`
+	Median time.Duration `json:"median"`
+	Mean   time.Duration `json:"mean"`
+	Max    time.Duration `json:"max"`
+	// Number of transactions seen first by A, by B, or at the same second
+	FirstA int `json:"firstA"`
+	FirstB int `json:"firstB"`
+	Equal  int `json:"equal"`
+}
+
+// Result is the comparison of two mempool snapshots
+type Result struct {
+	Time   time.Time       `json:"time"`
+	CountA int             `json:"countA"`
+	CountB int             `json:"countB"`
+	Common int             `json:"common"`
+	OnlyA  []types.Hash32  `json:"onlyA"`
+	OnlyB  []types.Hash32  `json:"onlyB"`
+	Deltas FirstSeenDeltas `json:"firstSeenDeltas"`
+}
+
+// Mempools compares the mempools `a` and `b` at time `at`.
+// The txids in OnlyA and OnlyB are sorted.
+func Mempools(at time.Time, a, b []types.Transaction) *Result {
+	res := &Result{
+		Time:   at,
+		CountA: len(a),
+		CountB: len(b),
+		OnlyA:  []types.Hash32{},
+		OnlyB:  []types.Hash32{},
+	}
+
+	firstSeenA := make(map[types.Hash32]time.Time, len(a))
+	for _, tx := range a {
+		firstSeenA[tx.TxID] = tx.FirstSeen
+	}
+
+	var deltas []time.Duration
+	seenB := make(map[types.Hash32]bool, len(b))
+	for _, tx := range b {
+		seenB[tx.TxID] = true
+		firstSeen, ok := firstSeenA[tx.TxID]
+		if !ok {
+			res.OnlyB = append(res.OnlyB, tx.TxID)
+			continue
+		}
+		deltas = append(deltas, tx.FirstSeen.Sub(firstSeen))
+	}
+
+	for _, tx := range a {
+		if !seenB[tx.TxID] {
+			res.OnlyA = append(res.OnlyA, tx.TxID)
+		}
+	}
+
+	sortHashes(res.OnlyA)
+	sortHashes(res.OnlyB)
+	res.Common = len(deltas)
+	res.Deltas = summarize(deltas)
+	return res
+}
+
+func sortHashes(hashes []types.Hash32) {
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+}
+
+func summarize(deltas []time.Duration) (res FirstSeenDeltas) {
+	if len(deltas) == 0 {
+		return res
+	}
+
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i] < deltas[j] })
+
+	var sum time.Duration
+	for _, d := range deltas {
+		sum += d
+		switch {
+		case d > 0:
+			res.FirstA++
+		case d < 0:
+			res.FirstB++
+		default:
+			res.Equal++
+		}
+	}
+
+	res.Min = deltas[0]
+	res.Max = deltas[len(deltas)-1]
+	res.Median = deltas[len(deltas)/2]
+	res.Mean = sum / time.Duration(len(deltas))
+	return res
+}
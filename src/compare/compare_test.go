@@ -0,0 +1,44 @@
+package compare
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestMempools(t *testing.T) {
+	at := time.Unix(1000, 0).UTC()
+	tx := func(id string, offset int) types.Transaction {
+		return types.Transaction{
+			TxID:      test.GenerateHash32(id),
+			FirstSeen: at.Add(time.Duration(offset) * time.Second),
+		}
+	}
+
+	a := []types.Transaction{tx("1", 0), tx("2", 0), tx("3", 0), tx("4", 0)}
+	b := []types.Transaction{tx("1", 2), tx("2", -1), tx("3", 0), tx("5", 0)}
+
+	res := Mempools(at, a, b)
+	assert.Equal(t, 4, res.CountA)
+	assert.Equal(t, 4, res.CountB)
+	assert.Equal(t, 3, res.Common)
+	assert.Equal(t, []types.Hash32{test.GenerateHash32("4")}, res.OnlyA)
+	assert.Equal(t, []types.Hash32{test.GenerateHash32("5")}, res.OnlyB)
+	assert.Equal(t, FirstSeenDeltas{
+		Min:    -time.Second,
+		Median: 0,
+		Mean:   time.Second / 3,
+		Max:    2 * time.Second,
+		FirstA: 1,
+		FirstB: 1,
+		Equal:  1,
+	}, res.Deltas)
+
+	empty := Mempools(at, nil, nil)
+	assert.Equal(t, 0, empty.Common)
+	assert.Equal(t, FirstSeenDeltas{}, empty.Deltas)
+}
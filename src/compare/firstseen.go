@@ -0,0 +1,103 @@
+package compare
+
+import (
+	"sort"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// MinCommon is the minimum number of transactions a source must share with other sources
+// for its lag to be estimated
+const MinCommon = 10
+
+// SourceLag is the delay of a source behind the earliest source,
+// over the transactions it shares with other sources
+type SourceLag struct {
+	Source string `json:"source"`
+	// Number of transactions seen by the source and at least one other source
+	Common int           `json:"common"`
+	Median time.Duration `json:"median"`
+	// 90th percentile of the lag
+	P90 time.Duration `json:"p90"`
+	// Number of the common transactions the source saw first, ties count for every tied source
+	First int `json:"first"`
+	// First / Common, the share of the transactions the source was the first announcer of
+	FirstRate float64 `json:"firstRate"`
+}
+
+// SourceLags estimates the lag of each source and how often it saw a transaction first.
+// Each element of `observations` holds the first-seen times of one transaction, at most one per
+// source.
+// Sources that share fewer than MinCommon transactions with other sources are omitted.
+func SourceLags(observations [][]types.ExternalFirstSeen) map[string]SourceLag {
+	lags := map[string][]time.Duration{}
+	for _, obs := range observations {
+		if len(obs) < 2 {
+			continue
+		}
+		first := earliest(obs)
+		for _, o := range obs {
+			lags[o.Source] = append(lags[o.Source], o.FirstSeen.Sub(first))
+		}
+	}
+
+	res := map[string]SourceLag{}
+	for source, l := range lags {
+		if len(l) < MinCommon {
+			continue
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		// the lag is zero iff the source was first
+		first := sort.Search(len(l), func(i int) bool { return l[i] > 0 })
+		res[source] = SourceLag{
+			Source:    source,
+			Common:    len(l),
+			Median:    l[len(l)/2],
+			P90:       l[len(l)*9/10],
+			First:     first,
+			FirstRate: float64(first) / float64(len(l)),
+		}
+	}
+	return res
+}
+
+// EstimateFirstSeen returns an interval of the network first-seen time of a transaction with the
+// first-seen times `obs`. Each source saw the transaction after it appeared in the network and,
+// with 90% confidence, at most its P90 lag after it, so the interval is the intersection of
+// [firstSeen - P90, firstSeen] over all sources. Sources without a lag only bound the upper end.
+// Returns false if no source of `obs` has a lag.
+func EstimateFirstSeen(obs []types.ExternalFirstSeen, lags map[string]SourceLag) (types.FirstSeenEstimate, bool) {
+	res := types.FirstSeenEstimate{Sources: len(obs)}
+	if len(obs) == 0 {
+		return res, false
+	}
+	res.Upper = earliest(obs)
+
+	known := false
+	for _, o := range obs {
+		lag, ok := lags[o.Source]
+		if !ok {
+			continue
+		}
+		if lower := o.FirstSeen.Add(-lag.P90); !known || lower.After(res.Lower) {
+			res.Lower = lower
+		}
+		known = true
+	}
+	// the sources disagree with their usual lags, the earliest observation is the best guess
+	if res.Lower.After(res.Upper) {
+		res.Lower = res.Upper
+	}
+	return res, known
+}
+
+func earliest(obs []types.ExternalFirstSeen) time.Time {
+	res := obs[0].FirstSeen
+	for _, o := range obs[1:] {
+		if o.FirstSeen.Before(res) {
+			res = o.FirstSeen
+		}
+	}
+	return res
+}
@@ -0,0 +1,55 @@
+package compare
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestEstimateFirstSeen(t *testing.T) {
+	at := time.Unix(1000, 0).UTC()
+	obs := func(source string, offset int) types.ExternalFirstSeen {
+		return types.ExternalFirstSeen{Source: source, FirstSeen: at.Add(time.Duration(offset) * time.Second)}
+	}
+
+	// "fast" is always first, "slow" follows after 1s to 10s, "rare" shares too few transactions
+	var observations [][]types.ExternalFirstSeen
+	for i := 1; i <= 10; i++ {
+		txid := test.GenerateHash32(fmt.Sprintf("tx-%d", i))
+		tx := []types.ExternalFirstSeen{obs("fast", 0), obs("slow", i)}
+		if i == 1 {
+			tx = append(tx, obs("rare", 5))
+		}
+		for j := range tx {
+			tx[j].TxID = txid
+		}
+		observations = append(observations, tx)
+	}
+	// transactions seen by a single source do not count
+	observations = append(observations, []types.ExternalFirstSeen{obs("slow", 100)})
+
+	lags := SourceLags(observations)
+	require.Len(t, lags, 2)
+	assert.Equal(t, SourceLag{Source: "fast", Common: 10, First: 10, FirstRate: 1}, lags["fast"])
+	assert.Equal(t, SourceLag{Source: "slow", Common: 10, Median: 6 * time.Second, P90: 10 * time.Second}, lags["slow"])
+
+	// the upper end is the earliest observation, the lower end the latest observation minus its P90 lag
+	e, ok := EstimateFirstSeen([]types.ExternalFirstSeen{obs("slow", 20), obs("rare", 15)}, lags)
+	require.True(t, ok)
+	assert.Equal(t, types.FirstSeenEstimate{Lower: at.Add(10 * time.Second), Upper: at.Add(15 * time.Second), Sources: 2}, e)
+
+	// inconsistent observations collapse to the earliest one
+	e, ok = EstimateFirstSeen([]types.ExternalFirstSeen{obs("fast", 20), obs("slow", 5)}, lags)
+	require.True(t, ok)
+	assert.Equal(t, at.Add(5*time.Second), e.Lower)
+	assert.Equal(t, at.Add(5*time.Second), e.Upper)
+
+	_, ok = EstimateFirstSeen([]types.ExternalFirstSeen{obs("rare", 5)}, lags)
+	assert.False(t, ok)
+}
@@ -0,0 +1,361 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/metrics"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Pause makes the daemon discard incoming transactions and blocks until Resume is called.
+// Use Resync after Resume to catch up with the node.
+func (b *BademeisterDaemon) Pause() {
+	atomic.StoreInt32(&b.paused, 1)
+	log.Infof("Ingestion paused")
+}
+
+// Resume ends a Pause
+func (b *BademeisterDaemon) Resume() {
+	atomic.StoreInt32(&b.paused, 0)
+	log.Infof("Ingestion resumed")
+}
+
+// Paused returns true if ingestion is paused
+func (b *BademeisterDaemon) Paused() bool {
+	return atomic.LoadInt32(&b.paused) == 1
+}
+
+// Resync fetches the node mempool and missing blocks via RPC or the REST interface. The resync is
+// run by Run between incoming transactions and blocks, Resync waits for its result. Fails while
+// ingestion is paused or if Run is not running.
+func (b *BademeisterDaemon) Resync() error {
+	if b.backfillSource() == nil {
+		return errors.New("no rpcClient or REST client")
+	}
+	if b.Paused() {
+		return errPausedResync
+	}
+	result := make(chan error, 1)
+	select {
+	case b.resyncs <- result:
+	case <-b.stopped:
+		return errors.New("the daemon is not running")
+	}
+	return <-result
+}
+
+// errPausedResync is returned by Resync while ingestion is paused
+var errPausedResync = errors.New("ingestion is paused, resume before resyncing")
+
+// resync implements Resync, must only be called by Run
+func (b *BademeisterDaemon) resync() error {
+	if b.Paused() {
+		return errPausedResync
+	}
+	if err := b.InitMempoolRPC(); err != nil {
+		return err
+	}
+	return b.InitBlocksRPC()
+}
+
+// Snapshot writes a copy of the database to `dir` and returns its path
+func (b *BademeisterDaemon) Snapshot(dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("bademeister-%d.db", time.Now().Unix()))
+	if err := b.storage.Snapshot(path); err != nil {
+		return "", err
+	}
+	log.Infof("Wrote snapshot %s", path)
+	return path, nil
+}
+
+// PinSnapshot stores the mempool at `at` under `name`, see Storage.PinSnapshot
+func (b *BademeisterDaemon) PinSnapshot(name string, at time.Time) (*types.PinnedSnapshot, error) {
+	pinned, err := b.storage.PinSnapshot(name, at)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Pinned snapshot %s of %s with %d transactions", name, pinned.Time, pinned.Transactions)
+	return pinned, nil
+}
+
+// Diagnostics describes the runtime state of the daemon
+type Diagnostics struct {
+	Uptime              string       `json:"uptime"`
+	Paused              bool         `json:"paused"`
+	DroppedTransactions uint64       `json:"droppedTransactions"`
+	DroppedBlocks       uint64       `json:"droppedBlocks"`
+	QueuedTransactions  int          `json:"queuedTransactions"`
+	QueuedBlocks        int          `json:"queuedBlocks"`
+	TransactionCount    int          `json:"transactionCount"`
+	BestBlock           *types.Block `json:"bestBlock"`
+	Config              Config       `json:"config"`
+	PrivacyMode         bool         `json:"privacyMode"`
+	Dedup               DedupStats   `json:"dedup"`
+	// Cache of database ids by txid, see Storage.SetIDCacheSize
+	IDCache storage.IDCacheStats `json:"idCache"`
+	// Storage statements logged as slow, see Config.SlowQueryThreshold
+	SlowQueries uint64 `json:"slowQueries"`
+	// Orphan pool, nil if disabled
+	Orphans *OrphanStats `json:"orphans,omitempty"`
+	// Mempool mirror, nil if disabled
+	MirrorSize     *int            `json:"mirrorSize,omitempty"`
+	MirrorRecovery *MirrorRecovery `json:"mirrorRecovery,omitempty"`
+	// Recent blocks whose transactions the mirror keeps until they are final, see Config.FinalityDepth
+	MirrorUnfinalized *int `json:"mirrorUnfinalized,omitempty"`
+	// Latency of incoming transactions by stage, see LatencyMetrics
+	Latency    map[string]metrics.Summary `json:"latency"`
+	Goroutines int                        `json:"goroutines"`
+	HeapAlloc  uint64                     `json:"heapAlloc"`
+}
+
+// Diagnostics returns the current runtime state
+func (b *BademeisterDaemon) Diagnostics() (*Diagnostics, error) {
+	count, err := b.storage.TxCount()
+	if err != nil {
+		return nil, err
+	}
+	best, err := b.storage.BestBlockNow()
+	if err != nil {
+		return nil, err
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	d := Diagnostics{
+		Uptime:              time.Since(b.started).Round(time.Second).String(),
+		Paused:              b.Paused(),
+		DroppedTransactions: atomic.LoadUint64(&b.droppedTxs),
+		DroppedBlocks:       atomic.LoadUint64(&b.droppedBlocks),
+		QueuedTransactions:  len(b.zmqSub.IncomingTx),
+		QueuedBlocks:        len(b.zmqSub.IncomingBlocks),
+		TransactionCount:    count,
+		Config:              b.Config(),
+		PrivacyMode:         b.hasher != nil,
+		Dedup:               b.DedupStats(),
+		IDCache:             b.storage.IDCacheStats(),
+		SlowQueries:         b.storage.SlowQueries(),
+		Goroutines:          runtime.NumGoroutine(),
+		HeapAlloc:           mem.HeapAlloc,
+	}
+	if best != nil {
+		d.BestBlock = &best.Block
+	}
+	d.Latency = map[string]metrics.Summary{}
+	for stage, s := range b.LatencyMetrics() {
+		d.Latency[stage] = s.Summary()
+	}
+	if b.orphans != nil {
+		stats := b.OrphanStats()
+		d.Orphans = &stats
+	}
+	if b.mirror != nil {
+		size := b.mirror.Len()
+		d.MirrorSize = &size
+		d.MirrorRecovery = b.LastMirrorRecovery()
+		unfinalized := b.mirror.Unfinalized()
+		d.MirrorUnfinalized = &unfinalized
+	}
+	return &d, nil
+}
+
+// AdminServer serves the admin endpoints of a daemon.
+// All requests must carry the header `Authorization: Bearer <token>`.
+type AdminServer struct {
+	daemon      *BademeisterDaemon
+	token       string
+	snapshotDir string
+	mux         *http.ServeMux
+}
+
+// NewAdminServer returns an AdminServer for `d`. Snapshots are written to `snapshotDir`.
+func NewAdminServer(d *BademeisterDaemon, token, snapshotDir string) (*AdminServer, error) {
+	if token == "" {
+		return nil, errors.New("admin token must not be empty")
+	}
+
+	s := &AdminServer{
+		daemon:      d,
+		token:       token,
+		snapshotDir: snapshotDir,
+		mux:         http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/admin/pause", s.post(func() (interface{}, error) {
+		d.Pause()
+		return nil, nil
+	}))
+	s.mux.HandleFunc("/admin/resume", s.post(func() (interface{}, error) {
+		d.Resume()
+		return nil, nil
+	}))
+	s.mux.HandleFunc("/admin/resync", s.post(func() (interface{}, error) {
+		return nil, d.Resync()
+	}))
+	s.mux.HandleFunc("/admin/snapshot", s.post(func() (interface{}, error) {
+		path, err := d.Snapshot(s.snapshotDir)
+		return map[string]string{"path": path}, err
+	}))
+	s.mux.HandleFunc("/admin/pin", func(w http.ResponseWriter, r *http.Request) {
+		s.post(func() (interface{}, error) {
+			at := time.Now()
+			if v := r.URL.Query().Get("at"); v != "" {
+				var err error
+				if at, err = parseAdminTime(v); err != nil {
+					return nil, err
+				}
+			}
+			return d.PinSnapshot(r.URL.Query().Get("name"), at)
+		})(w, r)
+	})
+	s.mux.HandleFunc("/admin/prune", s.post(func() (interface{}, error) {
+		return nil, d.Prune()
+	}))
+	s.mux.HandleFunc("/admin/compact", s.post(func() (interface{}, error) {
+		return nil, d.Compact()
+	}))
+	s.mux.HandleFunc("/admin/reload", s.post(func() (interface{}, error) {
+		if err := d.ReloadConfig(); err != nil {
+			return nil, err
+		}
+		return d.Config(), nil
+	}))
+	s.mux.HandleFunc("/admin/rebroadcast", func(w http.ResponseWriter, r *http.Request) {
+		s.post(func() (interface{}, error) {
+			v := r.URL.Query().Get("txid")
+			txid, err := types.NewHashFromString(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid txid %q", v)
+			}
+			return nil, d.Rebroadcast(txid)
+		})(w, r)
+	})
+	s.mux.HandleFunc("/admin/diagnostics", s.handleDiagnostics)
+	s.mux.HandleFunc("/admin/dropped", s.handleDropped)
+	s.mux.HandleFunc("/admin/metrics", s.handleMetrics)
+
+	return s, nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		writeAdminJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	log.Infof("admin: %s %s", r.Method, r.URL)
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the admin endpoints on `address`
+func (s *AdminServer) ListenAndServe(address string) error {
+	log.Infof("Admin API listening on %s", address)
+	return http.ListenAndServe(address, s)
+}
+
+// post returns a handler that runs `action` for POST requests
+func (s *AdminServer) post(action func() (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		res, err := action()
+		if err != nil {
+			log.Errorf("admin: %s failed: %s", r.URL.Path, err)
+			writeAdminJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if res == nil {
+			res = map[string]string{"status": "ok"}
+		}
+		writeAdminJSON(w, http.StatusOK, res)
+	}
+}
+
+func (s *AdminServer) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	d, err := s.daemon.Diagnostics()
+	if err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, d)
+}
+
+// droppedLimit is the maximum number of transactions returned by `/admin/dropped`
+const droppedLimit = 1000
+
+func (s *AdminServer) handleDropped(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	txs, err := s.daemon.DroppedTransactions(droppedLimit)
+	if err != nil {
+		writeAdminJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, txs)
+}
+
+// handleMetrics serves the latency histograms in the Prometheus text format
+func (s *AdminServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeAdminJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := metrics.WritePrometheus(
+		w, "bademeister_tx_latency_seconds", "Latency of incoming transactions by pipeline stage.",
+		"stage", s.daemon.LatencyMetrics(),
+	)
+	if err != nil {
+		log.Errorf("error writing metrics: %s", err)
+	}
+}
+
+// parseAdminTime parses a time given as unix seconds or RFC3339 string
+func parseAdminTime(v string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, expected unix seconds or an RFC3339 time", v)
+	}
+	return t.UTC(), nil
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("error writing response: %s", err)
+	}
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrest"
+)
+
+func TestAdminServer_Auth(t *testing.T) {
+	_, err := NewAdminServer(&BademeisterDaemon{}, "", ".")
+	require.Error(t, err)
+
+	d := &BademeisterDaemon{}
+	s, err := NewAdminServer(d, "secret", ".")
+	require.NoError(t, err)
+
+	request := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/admin/pause", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/admin/pause", "wrong"))
+	assert.False(t, d.Paused())
+
+	assert.Equal(t, http.StatusMethodNotAllowed, request(http.MethodGet, "/admin/pause", "secret"))
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/admin/pause", "secret"))
+	assert.True(t, d.Paused())
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/admin/resume", "secret"))
+	assert.False(t, d.Paused())
+}
+
+func TestAdminServer_Metrics(t *testing.T) {
+	d := &BademeisterDaemon{latency: newTxLatency()}
+	s, err := NewAdminServer(d, "secret", ".")
+	require.NoError(t, err)
+
+	d.latency.commit.Observe(20 * time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "# TYPE bademeister_tx_latency_seconds histogram\n")
+	assert.Contains(t, rec.Body.String(), `bademeister_tx_latency_seconds_count{stage="commit"} 1`)
+	assert.Contains(t, rec.Body.String(), `bademeister_tx_latency_seconds_count{stage="queue"} 0`)
+}
+
+func TestResync(t *testing.T) {
+	rest, err := bitcoinrest.NewClient("http://127.0.0.1:1")
+	require.NoError(t, err)
+	d := &BademeisterDaemon{rest: rest, resyncs: make(chan chan error), stopped: make(chan struct{})}
+
+	d.Pause()
+	assert.Equal(t, errPausedResync, d.Resync())
+	d.Resume()
+
+	// the resync is run by Run
+	go func() {
+		result := <-d.resyncs
+		result <- errors.New("run by Run")
+	}()
+	assert.EqualError(t, d.Resync(), "run by Run")
+
+	close(d.stopped)
+	assert.EqualError(t, d.Resync(), "the daemon is not running")
+}
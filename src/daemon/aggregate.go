@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+// aggregateInterval is the interval between two RefreshAggregates calls
+const aggregateInterval = time.Minute
+
+// aggregateOverlap is the time after the end of a bucket in which transactions can still be stored
+// with a first-seen time in the bucket (e.g. via getrawmempool). Buckets are computed once when they
+// are complete and once more when they are final, i.e. their end is more than aggregateOverlap ago.
+const aggregateOverlap = time.Hour
+
+// aggregateProgress are the ends of the ranges of buckets of a resolution computed by RefreshAggregates
+type aggregateProgress struct {
+	// buckets before are computed after they were complete
+	complete time.Time
+	// buckets before are computed after they were final
+	final time.Time
+}
+
+// aggregateChunk is the time range recomputed per write transaction
+const aggregateChunk = 24 * time.Hour
+
+func (b *BademeisterDaemon) aggregateLoop() {
+	for {
+		if err := b.RefreshAggregates(); err != nil {
+			log.Errorf("Error in RefreshAggregates(): %s", err)
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(aggregateInterval):
+		}
+	}
+}
+
+// RefreshAggregates updates the materialized aggregates of all resolutions up to the last complete bucket.
+// Each bucket is computed when it is complete and again when it is final (see aggregateOverlap), so
+// a call only reads the transactions of buckets that completed or became final since the last call.
+// On the first call, the aggregates are computed for all stored transactions, or since the last stored
+// bucket minus aggregateOverlap, in slices with pauses in between, so that live writes are not blocked.
+// Must not be called concurrently.
+func (b *BademeisterDaemon) RefreshAggregates() error {
+	if b.aggregated == nil {
+		b.aggregated = map[time.Duration]aggregateProgress{}
+	}
+	now := time.Now().UTC()
+	for _, resolution := range storage.AggregateResolutions {
+		to := now.Truncate(resolution)
+		final := now.Add(-aggregateOverlap).Truncate(resolution)
+
+		progress, ok := b.aggregated[resolution]
+		if ok {
+			if err := b.refreshAggregates(resolution, progress.final, final); err != nil {
+				return err
+			}
+			from := progress.complete
+			if from.Before(final) {
+				from = final
+			}
+			if err := b.refreshAggregates(resolution, from, to); err != nil {
+				return err
+			}
+		} else {
+			last, err := b.storage.LastAggregate(resolution)
+			if err != nil {
+				return err
+			}
+			var from time.Time
+			if last != nil {
+				from = last.Add(-aggregateOverlap)
+			} else {
+				first, err := b.storage.FirstTransactionTime()
+				if err != nil {
+					return err
+				}
+				if first == nil {
+					continue
+				}
+				from = *first
+			}
+			if err := b.refreshAggregates(resolution, from, to); err != nil {
+				return err
+			}
+		}
+		b.aggregated[resolution] = aggregateProgress{complete: to, final: final}
+	}
+	return nil
+}
+
+// refreshAggregates recomputes the buckets of `resolution` in [from, to) in chunks of aggregateChunk
+func (b *BademeisterDaemon) refreshAggregates(resolution time.Duration, from, to time.Time) error {
+	for from.Before(to) {
+		end := from.Add(aggregateChunk)
+		if end.After(to) {
+			end = to
+		}
+		err := retryStorageBusy(func() error {
+			return b.storage.RefreshAggregates(resolution, from, end)
+		})
+		if err != nil {
+			return err
+		}
+		from = end
+		if from.Before(to) {
+			time.Sleep(compactPause)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// bestChainInterval is the interval between two RecomputeBestChain calls
+const bestChainInterval = time.Hour
+
+// bestChainLoop corrects the stored best chain periodically, see storage.RecomputeBestChain
+func (b *BademeisterDaemon) bestChainLoop() {
+	for {
+		err := retryStorageBusy(func() error {
+			_, err := b.storage.RecomputeBestChain()
+			return err
+		})
+		if err != nil {
+			log.Errorf("Error in RecomputeBestChain(): %s", err)
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(bestChainInterval):
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
+)
+
+// blockQueueSize is the number of announced blocks waiting to be fetched, see RunParams.HeaderFastPath
+const blockQueueSize = 64
+
+// announceBlocksLoop fetches the header of each block announced via `hashblock` right away and
+// queues the block for fetchBlocksLoop. The first-seen time is the time of the announcement, so
+// it does not depend on how long the block takes to fetch and deserialize.
+func (b *BademeisterDaemon) announceBlocksLoop(queue chan<- zmqsubscriber.BlockHash) {
+	defer close(queue)
+	for {
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case announced := <-b.zmqSub.IncomingBlockHashes:
+			if b.Paused() {
+				atomic.AddUint64(&b.droppedBlocks, 1)
+				continue
+			}
+			if err := b.announceBlock(announced); err != nil {
+				log.Warnf("Could not fetch header of announced block: %s", err)
+			}
+			select {
+			case queue <- announced:
+			default:
+				// a later block triggers a backfill of the missing parent, see Run
+				atomic.AddUint64(&b.droppedBlocks, 1)
+				log.Warnf("Block queue full, dropped announced block %s", announced.Hash)
+			}
+		}
+	}
+}
+
+// announceBlock fetches and logs the header of the `announced` block
+func (b *BademeisterDaemon) announceBlock(announced zmqsubscriber.BlockHash) error {
+	source := b.backfillSource()
+	if source == nil {
+		return errors.New("no rpcClient or REST client")
+	}
+
+	hash := chainhash.Hash(announced.Hash)
+	header, err := source.GetBlockHeader(&hash)
+	if err != nil {
+		return errors.Wrapf(err, "block %s", announced.Hash)
+	}
+	log.Infof(
+		"Block %s announced: parent=%s time=%s header received after %s",
+		announced.Hash, types.NewHashFromArray(header.PrevBlock), header.Timestamp.UTC().Format(time.RFC3339),
+		time.Since(announced.FirstSeen),
+	)
+	return nil
+}
+
+// fetchBlocksLoop fetches the queued blocks in order and sends them to Run
+func (b *BademeisterDaemon) fetchBlocksLoop(queue <-chan zmqsubscriber.BlockHash) {
+	for announced := range queue {
+		block, err := b.fetchBlock(announced)
+		if err != nil {
+			log.Errorf("Error in fetchBlock(): %s", err)
+			continue
+		}
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case b.fetchedBlocks <- *block:
+		}
+	}
+}
+
+// fetchBlock fetches and deserializes the `announced` block, with the time of the announcement as first-seen time
+func (b *BademeisterDaemon) fetchBlock(announced zmqsubscriber.BlockHash) (*types.Block, error) {
+	source := b.backfillSource()
+	if source == nil {
+		return nil, errors.New("no rpcClient or REST client")
+	}
+
+	hash := chainhash.Hash(announced.Hash)
+	wireBlock, err := source.GetBlock(&hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch block %s", announced.Hash)
+	}
+	block, err := types.NewBlockFromWireBlock(announced.FirstSeen, wireBlock)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Block %s fetched %s after announcement", block.Hash, time.Since(announced.FirstSeen))
+	return block, nil
+}
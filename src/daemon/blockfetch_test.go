@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrest"
+	"github.com/0xb10c/bademeister-go/src/types"
+	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
+)
+
+func TestBademeisterDaemon_fetchBlock(t *testing.T) {
+	var zeroHash chainhash.Hash
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, &zeroHash, &zeroHash, 0x207fffff, 0))
+	block.Header.Timestamp = time.Unix(1231006505, 0)
+	coinbase := wire.NewMsgTx(1)
+	// height 1
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x01, 0x01},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(&wire.TxOut{Value: 5000000000, PkScript: []byte{0x51}})
+	require.NoError(t, block.AddTransaction(coinbase))
+	var blockData bytes.Buffer
+	require.NoError(t, block.Serialize(&blockData))
+	hash := block.BlockHash()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/block/" + hash.String() + ".bin":
+			_, _ = w.Write(blockData.Bytes())
+		case "/rest/headers/1/" + hash.String() + ".bin":
+			_, _ = w.Write(blockData.Bytes()[:wire.MaxBlockHeaderPayload])
+		default:
+			http.Error(w, "Block not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	rest, err := bitcoinrest.NewClient(server.URL)
+	require.NoError(t, err)
+	b := &BademeisterDaemon{rest: rest}
+
+	// the first-seen time is the time of the announcement, not the header time
+	announced := zmqsubscriber.BlockHash{Hash: types.NewHashFromArray(hash), FirstSeen: time.Unix(1600000000, 0).UTC()}
+	require.NoError(t, b.announceBlock(announced))
+	fetched, err := b.fetchBlock(announced)
+	require.NoError(t, err)
+	assert.Equal(t, announced.Hash, fetched.Hash)
+	assert.Equal(t, announced.FirstSeen, fetched.FirstSeen)
+	assert.Equal(t, uint32(1), fetched.Height)
+
+	unknown := zmqsubscriber.BlockHash{Hash: types.NewHashFromArray(zeroHash)}
+	assert.Error(t, b.announceBlock(unknown))
+	_, err = b.fetchBlock(unknown)
+	assert.Error(t, err)
+
+	_, err = (&BademeisterDaemon{}).fetchBlock(announced)
+	assert.Error(t, err)
+}
@@ -0,0 +1,279 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/heuristics"
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Duration is a time.Duration that is encoded as string like "720h" in JSON
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// AlertThresholds are limits of the node mempool state above which a warning is logged.
+// Zero values disable the alert.
+type AlertThresholds struct {
+	// Number of transactions in the node mempool
+	MempoolSize int64 `json:"mempoolSize"`
+	// Minimum feerate for a transaction to be accepted, in sat/kvB
+	MempoolMinFee int64 `json:"mempoolMinFee"`
+}
+
+// WatchEntry is a watch list entry. Notifications about transactions with one of the txids or
+// paying to one of the addresses are sent to the sinks in Notify.
+type WatchEntry struct {
+	Name string `json:"name"`
+	// Txids in RPC byte order
+	TxIDs     []string            `json:"txids"`
+	Addresses []string            `json:"addresses"`
+	Notify    []notify.SinkConfig `json:"notify"`
+	// Rebroadcast the transactions with one of the txids when they expire without confirmation
+	// or replacement, see BademeisterDaemon.Rebroadcast
+	Rebroadcast bool `json:"rebroadcast"`
+}
+
+// WhaleConfig flags transactions moving large values, disabled by default
+type WhaleConfig struct {
+	// Minimum total output value in BTC of flagged transactions. Zero disables the detection.
+	MinValue float64 `json:"minValue"`
+	// Sinks notified when a flagged transaction enters the mempool and when it is confirmed, optional
+	Notify []notify.SinkConfig `json:"notify"`
+}
+
+// Config contains the settings that can be changed while the daemon is running
+type Config struct {
+	// One of info, debug, trace. Empty keeps the current level.
+	LogLevel string `json:"logLevel"`
+	// Transactions with a lower feerate (sat/vB) are not stored. Zero disables the floor.
+	FeerateFloor float64 `json:"feerateFloor"`
+	// Transactions removed from the mempool longer ago are pruned. Zero disables pruning.
+	RetentionWindow Duration `json:"retentionWindow"`
+	// Transactions outside the retention window are moved to this SQLite file instead of deleted.
+	ArchivePath string          `json:"archivePath"`
+	Alerts      AlertThresholds `json:"alerts"`
+	// Transactions and addresses to send notifications about
+	Watch []WatchEntry `json:"watch"`
+	// Export of public aggregates, disabled by default
+	Dashboard DashboardConfig `json:"dashboard"`
+	// Upload of backups and archives to object storage, disabled by default
+	Upload UploadConfig `json:"upload"`
+	// Storage statements that take longer are logged. Zero disables the log.
+	SlowQueryThreshold Duration `json:"slowQueryThreshold"`
+	// Thresholds of dust outputs recorded with RunParams.TrackDust, the limits of Bitcoin Core by default
+	Dust types.DustThresholds `json:"dust"`
+	// Detection of transactions with large output values, see WhaleConfig
+	Whales WhaleConfig `json:"whales"`
+	// Thresholds of the classifications of RunParams.Heuristics
+	Heuristics heuristics.Thresholds `json:"heuristics"`
+	// Confirmations after which confirmed transactions are final: dropped from the mempool mirror
+	// and eligible for pruning and archiving. Zero or one makes them final with the first confirmation.
+	FinalityDepth int `json:"finalityDepth"`
+}
+
+// maxFinalityDepth is the highest Config.FinalityDepth
+const maxFinalityDepth = 1000
+
+// DefaultConfig is used if no config file is given
+var DefaultConfig = Config{}
+
+// ParseLogLevel returns the logrus level for `level`
+func ParseLogLevel(level string) (log.Level, error) {
+	switch level {
+	case "info":
+		return log.InfoLevel, nil
+	case "debug":
+		return log.DebugLevel, nil
+	case "trace":
+		return log.TraceLevel, nil
+	default:
+		return 0, errors.Errorf("invalid log level %q", level)
+	}
+}
+
+// LoadConfig reads a JSON config file.
+// Unset fields have the value of DefaultConfig.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read config file")
+	}
+
+	config := DefaultConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrapf(err, "could not parse config file %s", path)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// Validate returns an error if a setting has an invalid value
+func (c *Config) Validate() error {
+	if c.LogLevel != "" {
+		if _, err := ParseLogLevel(c.LogLevel); err != nil {
+			return err
+		}
+	}
+	if c.FeerateFloor < 0 {
+		return errors.Errorf("invalid feerateFloor %f", c.FeerateFloor)
+	}
+	if c.RetentionWindow.Duration < 0 {
+		return errors.Errorf("invalid retentionWindow %s", c.RetentionWindow)
+	}
+	if c.SlowQueryThreshold.Duration < 0 {
+		return errors.Errorf("invalid slowQueryThreshold %s", c.SlowQueryThreshold)
+	}
+	if _, err := newWatchList(c.Watch); err != nil {
+		return err
+	}
+	if err := c.Dashboard.Validate(); err != nil {
+		return err
+	}
+	if err := c.Upload.Validate(); err != nil {
+		return err
+	}
+	if c.Upload.Archive && c.ArchivePath == "" {
+		return errors.New("upload of archives requires archivePath")
+	}
+	if c.Dust.RelayFeerate < 0 {
+		return errors.Errorf("invalid dust relayFeerate %f", c.Dust.RelayFeerate)
+	}
+	for scriptType, threshold := range c.Dust.ScriptTypes {
+		if threshold < 0 {
+			return errors.Errorf("invalid dust threshold %d for script type %s", threshold, scriptType)
+		}
+	}
+	if _, err := newWhaleTarget(c.Whales); err != nil {
+		return err
+	}
+	if err := c.Heuristics.Validate(); err != nil {
+		return err
+	}
+	if c.FinalityDepth < 0 || c.FinalityDepth > maxFinalityDepth {
+		return errors.Errorf("invalid finalityDepth %d", c.FinalityDepth)
+	}
+	return nil
+}
+
+// Config returns the active config
+func (b *BademeisterDaemon) Config() Config {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return b.config
+}
+
+// SetConfig validates and activates `config`
+func (b *BademeisterDaemon) SetConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	b.configMu.RLock()
+	logLevel := b.logLevel
+	b.configMu.RUnlock()
+	if config.LogLevel != "" {
+		logLevel = config.LogLevel
+	}
+	if logLevel != "" {
+		level, err := ParseLogLevel(logLevel)
+		if err != nil {
+			return err
+		}
+		log.SetLevel(level)
+	}
+
+	watch, err := newWatchList(config.Watch)
+	if err != nil {
+		return err
+	}
+	whales, err := newWhaleTarget(config.Whales)
+	if err != nil {
+		return err
+	}
+
+	b.configMu.Lock()
+	b.config = config
+	b.watch = watch
+	b.whales = whales
+	b.configMu.Unlock()
+
+	if b.storage != nil {
+		b.storage.SetSlowQueryThreshold(config.SlowQueryThreshold.Duration)
+		b.storage.SetFinalityDepth(config.FinalityDepth)
+	}
+
+	// the watch list and the stores are not logged, they contain tokens
+	log.Infof(
+		"Config: logLevel=%s feerateFloor=%.2f retentionWindow=%s alerts=%+v watch=%d entries dashboard=%s "+
+			"backupInterval=%s uploadArchive=%t slowQueryThreshold=%s whales.minValue=%g heuristics=%+v "+
+			"finalityDepth=%d",
+		config.LogLevel, config.FeerateFloor, config.RetentionWindow, config.Alerts, len(config.Watch),
+		config.Dashboard.Interval, config.Upload.BackupInterval, config.Upload.Archive, config.SlowQueryThreshold,
+		config.Whales.MinValue, config.Heuristics, config.FinalityDepth,
+	)
+	return nil
+}
+
+// ReloadConfig reads the config file given in RunParams.ConfigPath and activates it.
+// On error, the active config is kept.
+func (b *BademeisterDaemon) ReloadConfig() error {
+	if b.configPath == "" {
+		return errors.New("no config file")
+	}
+
+	config, err := LoadConfig(b.configPath)
+	if err != nil {
+		return err
+	}
+
+	return b.SetConfig(*config)
+}
+
+// belowFeerateFloor returns true if the feerate of a transaction with `fee` and `weight`
+// is below the configured floor
+func (c *Config) belowFeerateFloor(fee uint64, weight int) bool {
+	if c.FeerateFloor == 0 || weight == 0 {
+		return false
+	}
+	return float64(fee)/(float64(weight)/4) < c.FeerateFloor
+}
+
+// checkAlerts logs a warning for each exceeded threshold
+func (c *Config) checkAlerts(mempoolSize, mempoolMinFee int64) {
+	if c.Alerts.MempoolSize > 0 && mempoolSize > c.Alerts.MempoolSize {
+		log.Warnf("ALERT: mempool size %d exceeds %d", mempoolSize, c.Alerts.MempoolSize)
+	}
+	if c.Alerts.MempoolMinFee > 0 && mempoolMinFee > c.Alerts.MempoolMinFee {
+		log.Warnf("ALERT: mempoolminfee %d sat/kvB exceeds %d", mempoolMinFee, c.Alerts.MempoolMinFee)
+	}
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "config*.json")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`{
+		"logLevel": "debug",
+		"feerateFloor": 1.5,
+		"retentionWindow": "720h",
+		"alerts": {"mempoolSize": 100000}
+	}`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	config, err := LoadConfig(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		LogLevel:        "debug",
+		FeerateFloor:    1.5,
+		RetentionWindow: Duration{720 * time.Hour},
+		Alerts:          AlertThresholds{MempoolSize: 100000},
+	}, *config)
+
+	// 1000 sat for 400 WU is 10 sat/vB
+	assert.False(t, config.belowFeerateFloor(1000, 400))
+	assert.True(t, config.belowFeerateFloor(100, 400))
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"logLevel": "verbose"}`), 0600))
+	_, err = LoadConfig(f.Name())
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"dashboard": {"interval": "1m", "store": {"type": "dir"}}}`), 0600))
+	_, err = LoadConfig(f.Name())
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"dust": {"scriptTypes": {"pubkeyhash": -1}}}`), 0600))
+	_, err = LoadConfig(f.Name())
+	assert.Error(t, err)
+}
+
+func TestBademeisterDaemon_SetConfigLogLevel(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	b := &BademeisterDaemon{logLevel: "info"}
+	require.NoError(t, b.SetConfig(Config{LogLevel: "trace"}))
+	assert.Equal(t, log.TraceLevel, log.GetLevel())
+
+	// the level of the command line is restored without logLevel
+	require.NoError(t, b.SetConfig(Config{}))
+	assert.Equal(t, log.InfoLevel, log.GetLevel())
+}
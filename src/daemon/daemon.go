@@ -2,11 +2,24 @@ package daemon
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/pkg/errors"
 
+	"github.com/0xb10c/bademeister-go/src/analysis"
+	"github.com/0xb10c/bademeister-go/src/bitcoinrest"
 	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
+	"github.com/0xb10c/bademeister-go/src/dedup"
+	"github.com/0xb10c/bademeister-go/src/heuristics"
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/privacy"
+	"github.com/0xb10c/bademeister-go/src/scripthook"
+	"github.com/0xb10c/bademeister-go/src/service"
 	"github.com/0xb10c/bademeister-go/src/storage"
 	"github.com/0xb10c/bademeister-go/src/types"
 	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
@@ -17,12 +30,123 @@ import (
 // ErrMaxBackfill is returned when InitBlocksRPC needs to fetch too many blocks
 var ErrMaxBackfill = errors.New("maxBackfill exceeded")
 
+// DefaultMempoolExpiry is the default value of the bitcoind option `-mempoolexpiry`
+const DefaultMempoolExpiry = 336 * time.Hour
+
+// expiryCheckInterval is the interval between two ExpireTransactions calls
+const expiryCheckInterval = 10 * time.Minute
+
+// nodeConfigCheckInterval is the interval between two RecordNodeConfig calls
+const nodeConfigCheckInterval = 10 * time.Minute
+
+// pruneInterval is the interval between two Prune calls
+const pruneInterval = time.Hour
+
+// Prune and Compact process rows and pages in slices of these sizes
+// and pause between slices to let live writes through.
+const (
+	compactSliceSize   = 1000
+	compactVacuumPages = 1024
+	compactPause       = 100 * time.Millisecond
+)
+
+// storageBusyRetries is the number of retries of a write to a busy database.
+// The delay between retries starts at storageBusyBackoff and doubles with every retry.
+const (
+	storageBusyRetries = 5
+	storageBusyBackoff = 100 * time.Millisecond
+)
+
+// DefaultDedupCapacity is the default number of txids per generation of the duplicate filter
+const DefaultDedupCapacity = 1000000
+
+// dedupFPRate is the false-positive rate of the duplicate filter
+const dedupFPRate = 0.001
+
+// dedupVerifyInterval is the interval between two verifyDuplicates calls
+const dedupVerifyInterval = 10 * time.Second
+
+// DefaultMempoolInfoInterval is the default interval between two `getmempoolinfo` polls
+const DefaultMempoolInfoInterval = time.Minute
+
 // BademeisterDaemon reads data off ZMQSubscriber and inserts it to Storage
 type BademeisterDaemon struct {
+	// accessed atomically, first in struct for 64-bit alignment
+	droppedTxs    uint64
+	droppedBlocks uint64
+	dedupStats    DedupStats
+	orphanStats   OrphanStats
+
 	zmqSub    *zmqsubscriber.ZMQSubscriber
 	rpcClient *bitcoinrpcclient.BitcoinRPCClient
 	storage   *storage.Storage
 	quit      chan struct{}
+	// sends rebroadcast transactions to the node, nil if no rpcClient is set
+	broadcaster broadcaster
+	// replaces rpcClient for backfills if set
+	rest *bitcoinrest.Client
+	// set from RunParams
+	classify     bool
+	trackDust    bool
+	storeDetails bool
+	storeRaw     bool
+	// fraction of the transactions that is stored (see types.Sampled), 0 if all are stored
+	sampleRate float64
+	// replaces txids before storing them, nil unless privacy mode is enabled
+	hasher *privacy.TxIDHasher
+	// recently stored txids, nil if disabled. Only accessed by Run.
+	dedup *dedup.Filter
+	// transactions skipped as duplicates that were not verified yet, see verifyDuplicates
+	dedupPending []types.Transaction
+	// current mempool in memory, nil if disabled
+	mirror *mirror.Mirror
+	// enabled analyzers, nil if none. Only accessed by Run.
+	analysis *analysis.Runner
+	// user-provided script, nil if none or after it failed. Only accessed by Run.
+	script *scripthook.Hook
+	// result of the last RecoverMirror call
+	mirrorRecovery   *MirrorRecovery
+	mirrorRecoveryMu sync.Mutex
+	// serializes Prune, which is called by pruneLoop and the admin API
+	pruneMu sync.Mutex
+	// reloadable settings, see ReloadConfig
+	config     Config
+	configMu   sync.RWMutex
+	configPath string
+	// log level of the command line, see RunParams.LogLevel. Guarded by configMu.
+	logLevel string
+	// compiled Config.Watch, guarded by configMu
+	watch *watchList
+	// database ids of the expiry candidates in the node mempool at the last ExpireTransactions call.
+	// Only accessed by ExpireTransactions.
+	expiring map[int64]bool
+	// sinks of Config.Whales, nil if none. Guarded by configMu.
+	whales *watchTarget
+	// transactions matched by address or as whales, notified again when confirmed
+	watched       watchedTxs
+	notifications chan pendingNotification
+	// new transactions of the node mempool, see pollMempoolLoop
+	polledTxs chan []types.Transaction
+	// blocks announced via `hashblock`, see fetchBlocksLoop
+	fetchedBlocks chan types.Block
+	// children waiting for their parents, nil unless package observation is enabled. Only accessed by Run.
+	packages *packageTracker
+	// transactions with unknown parents, nil unless enabled. Only accessed by Run.
+	orphans *orphanPool
+	// latency of incoming transactions, see LatencyMetrics
+	latency txLatency
+	// progress of RefreshAggregates per resolution, nil before the first call. Only accessed by RefreshAggregates.
+	aggregated map[time.Duration]aggregateProgress
+	// features and sources enabled by Run, nil before. Guarded by configMu.
+	features *types.DaemonFeatures
+	sources  []string
+	// runtime state for the admin API
+	paused  int32
+	started time.Time
+	// requests of Resync, answered by Run
+	resyncs chan chan error
+	// closed when Run returns
+	stopped chan struct{}
 }
 
 // NewBademeisterDaemon initiates a new BademeisterDaemon.
@@ -32,37 +156,245 @@ func NewBademeisterDaemon(
 	dbPath string,
 ) (*BademeisterDaemon, error) {
 	if zmqSub == nil {
-		return nil, fmt.Errorf("zmqSub must not be nil")
+		return nil, errors.New("zmqSub must not be nil")
 	}
 
 	store, err := storage.NewStorage(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("could not initialize storage: %s", err)
+		return nil, errors.Wrap(err, "could not initialize storage")
 	}
 
 	quit := make(chan struct{}, 1)
-	return &BademeisterDaemon{
+	b := &BademeisterDaemon{
 		zmqSub:    zmqSub,
 		rpcClient: rpcClient,
 		storage:   store,
 		quit:      quit,
-	}, nil
+		config:    DefaultConfig,
+		started:   time.Now(),
+
+		notifications: make(chan pendingNotification, notificationQueueSize),
+		polledTxs:     make(chan []types.Transaction, 1),
+		fetchedBlocks: make(chan types.Block, 1),
+		latency:       newTxLatency(),
+		resyncs:       make(chan chan error),
+		stopped:       make(chan struct{}),
+	}
+	if rpcClient != nil {
+		b.broadcaster = rpcClient
+	}
+	return b, nil
 }
 
 func (b *BademeisterDaemon) processTransaction(tx *types.Transaction) error {
-	return b.processTransactions([]types.Transaction{*tx})
+	if b.dedup == nil {
+		return b.processTransactions([]types.Transaction{*tx})
+	}
+
+	key := dedupKey(tx)
+	atomic.AddUint64(&b.dedupStats.Checked, 1)
+	if b.dedup.Test(key) {
+		// probably stored already, verified later in a batch
+		atomic.AddUint64(&b.dedupStats.Hits, 1)
+		b.dedupPending = append(b.dedupPending, *tx)
+		return nil
+	}
+
+	if err := b.processTransactions([]types.Transaction{*tx}); err != nil {
+		return err
+	}
+	b.dedup.Add(key)
+	return nil
+}
+
+// verifyDuplicates checks that the transactions skipped by the duplicate filter are stored
+// and stores the false positives
+func (b *BademeisterDaemon) verifyDuplicates() error {
+	if len(b.dedupPending) == 0 {
+		return nil
+	}
+
+	storedTxIDs := make([]types.Hash32, len(b.dedupPending))
+	for i, tx := range b.dedupPending {
+		storedTxIDs[i] = tx.TxID
+		if b.hasher != nil {
+			storedTxIDs[i] = b.hasher.Hash(tx.TxID)
+		}
+	}
+
+	var stored map[types.Hash32]bool
+	err := retryStorageBusy(func() (err error) {
+		stored, err = b.storage.StoredTxIDs(storedTxIDs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []types.Transaction
+	for i, tx := range b.dedupPending {
+		if !stored[storedTxIDs[i]] {
+			missing = append(missing, tx)
+		}
+	}
+	b.dedupPending = nil
+
+	if len(missing) == 0 {
+		return nil
+	}
+	// transactions below the feerate floor are counted as well
+	atomic.AddUint64(&b.dedupStats.FalsePositives, uint64(len(missing)))
+	log.Debugf("Duplicate filter: storing %d false positives", len(missing))
+	return b.processTransactions(missing)
 }
 
 func (b *BademeisterDaemon) processTransactions(txs []types.Transaction) error {
+	// before the txids are hashed and the details dropped
+	b.watchTransactions(txs)
+	if b.sampleRate > 0 {
+		kept := make([]types.Transaction, 0, len(txs))
+		for _, tx := range txs {
+			if types.Sampled(tx.TxID, b.sampleRate) {
+				kept = append(kept, tx)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		txs = kept
+	}
+	if b.packages != nil || b.orphans != nil {
+		if err := b.trackParents(txs); err != nil {
+			return err
+		}
+	}
+
+	config := b.Config()
+	if config.FeerateFloor > 0 {
+		kept := make([]types.Transaction, 0, len(txs))
+		for _, tx := range txs {
+			if !config.belowFeerateFloor(tx.Fee, tx.Weight) {
+				kept = append(kept, tx)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		txs = kept
+	}
+
+	if b.analysis != nil {
+		b.analysis.Transactions(txs)
+	}
+	if b.script != nil {
+		if txs = b.runScript(txs); len(txs) == 0 {
+			return nil
+		}
+	}
+
+	for i := range txs {
+		if !b.storeRaw {
+			txs[i].Raw = nil
+		}
+		if txs[i].Details == nil {
+			continue
+		}
+		if b.classify {
+			flags := heuristics.ClassifyWith(txs[i].Details, config.Heuristics)
+			txs[i].Heuristics = &flags
+		}
+		if b.trackDust {
+			txs[i].Dust = types.FindDust(txs[i].Details, config.Dust)
+		}
+		b.detectWhale(&txs[i], &config.Whales)
+		if !b.storeDetails {
+			txs[i].Details = nil
+		}
+	}
+
+	if b.hasher != nil {
+		for i := range txs {
+			b.hasher.Transaction(&txs[i])
+		}
+	}
+
+	// the mirror serves the transactions before they are written
+	if b.mirror != nil {
+		b.mirror.Add(txs...)
+	}
+
 	log.Debugf("Inserting %d transactions", len(txs))
-	_, err := b.storage.InsertTransactions(txs)
-	return err
+	start := time.Now()
+	err := retryStorageBusy(func() error {
+		_, err := b.storage.InsertTransactions(txs)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	b.latency.committed(txs, start)
+	return nil
 }
 
 func (b *BademeisterDaemon) processBlock(block *types.Block) error {
 	log.Debugf("Received block %s height=%d, updating database", block.Hash, block.Height)
-	_, err := b.storage.InsertBlock(block)
-	return err
+	b.watchBlock(block)
+	if b.analysis != nil {
+		b.analysis.Block(block)
+	}
+	if b.script != nil {
+		b.runScriptBlock(block)
+	}
+	if b.hasher != nil {
+		b.hasher.Block(block)
+	}
+
+	var lastBest *types.StoredBlock
+	if b.mirror != nil {
+		var err error
+		if lastBest, err = b.storage.BestBlockNow(); err != nil {
+			return err
+		}
+	}
+
+	err := retryStorageBusy(func() error {
+		_, err := b.storage.InsertBlock(block)
+		return err
+	})
+	if err != nil || b.mirror == nil {
+		return err
+	}
+
+	depth := b.Config().FinalityDepth
+	if lastBest == nil || block.Parent == lastBest.Hash {
+		b.confirmInMirror(block, depth)
+		return nil
+	}
+	// transactions of disconnected blocks return to the mempool
+	ok, err := b.reorgMirror(lastBest, block, depth)
+	if err != nil {
+		log.Warnf("Could not apply the reorg to the mempool mirror: %s", err)
+	}
+	if ok {
+		return nil
+	}
+	log.Infof("Block %s does not extend %s, rebuilding mempool mirror", block.Hash, lastBest.Hash)
+	return b.rebuildMirror()
+}
+
+// retryStorageBusy calls `f` until it returns an error other than types.ErrStorageBusy
+// or the retries are exhausted
+func retryStorageBusy(f func() error) error {
+	backoff := storageBusyBackoff
+	for i := 0; ; i++ {
+		err := f()
+		if errors.Cause(err) != types.ErrStorageBusy || i == storageBusyRetries {
+			return err
+		}
+		log.Warnf("%s, retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
 }
 
 type stats struct {
@@ -107,16 +439,355 @@ func (b *BademeisterDaemon) dumpStatsLoop() {
 	}
 }
 
+func (b *BademeisterDaemon) expireTransactionsLoop(window time.Duration) {
+	for {
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(expiryCheckInterval):
+			if err := b.ExpireTransactions(window); err != nil {
+				log.Errorf("Error in ExpireTransactions(): %s", err)
+			}
+		}
+	}
+}
+
+func (b *BademeisterDaemon) pruneLoop() {
+	for {
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(pruneInterval):
+			if err := b.Prune(); err != nil {
+				log.Errorf("Error in Prune(): %s", err)
+				continue
+			}
+			if err := b.Compact(); err != nil {
+				log.Errorf("Error in Compact(): %s", err)
+			}
+		}
+	}
+}
+
+// Prune deletes transactions that were removed from the mempool before the configured retention window,
+// or moves them to the archive if an archive path is configured. The archive is uploaded afterwards
+// if configured (see UploadConfig.Archive).
+// Rows are processed in slices with pauses in between, so that live writes are not blocked.
+// Does nothing if the retention window is zero. Concurrent calls wait for each other.
+func (b *BademeisterDaemon) Prune() error {
+	b.pruneMu.Lock()
+	defer b.pruneMu.Unlock()
+
+	config := b.Config()
+	window := config.RetentionWindow.Duration
+	if window == 0 {
+		return nil
+	}
+
+	before := time.Now().Add(-window)
+	total := int64(0)
+	for {
+		var n int64
+		err := retryStorageBusy(func() (err error) {
+			if config.ArchivePath != "" {
+				n, err = b.storage.ArchiveTransactions(config.ArchivePath, before, compactSliceSize)
+			} else {
+				n, err = b.storage.PruneTransactions(before, compactSliceSize)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < compactSliceSize {
+			break
+		}
+		time.Sleep(compactPause)
+	}
+
+	if config.ArchivePath != "" {
+		log.Infof("Archived %d transactions removed more than %s ago to %s", total, window, config.ArchivePath)
+	} else {
+		log.Infof("Pruned %d transactions removed more than %s ago", total, window)
+	}
+
+	if config.ArchivePath != "" && config.Upload.Archive {
+		return uploadArchive(config.Upload, config.ArchivePath)
+	}
+	return nil
+}
+
+// Compact returns the free pages of the database to the file system in slices with pauses in between.
+// A full VACUUM would block all writes for the duration of rewriting the database.
+// Does nothing unless incremental vacuum is enabled for the database (see storage.EnableIncrementalVacuum).
+func (b *BademeisterDaemon) Compact() error {
+	enabled, err := b.storage.IncrementalVacuumEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+
+	free := int64(-1)
+	for {
+		var remaining int64
+		err := retryStorageBusy(func() (err error) {
+			remaining, err = b.storage.IncrementalVacuum(compactVacuumPages)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// stop if no progress is made, e.g. if the database is in use by another process
+		if remaining == 0 || remaining == free {
+			break
+		}
+		free = remaining
+		time.Sleep(compactPause)
+	}
+
+	log.Debugf("Compacted database")
+	return nil
+}
+
+func (b *BademeisterDaemon) pollMempoolInfoLoop(interval time.Duration) {
+	for {
+		if err := b.RecordMempoolInfo(); err != nil {
+			log.Errorf("Error in RecordMempoolInfo(): %s", err)
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RecordMempoolInfo stores the current `getmempoolinfo` result,
+// which includes the `mempoolminfee` and `minrelaytxfee` of the node.
+func (b *BademeisterDaemon) RecordMempoolInfo() error {
+	if b.rpcClient == nil {
+		return errors.New("no rpcClient")
+	}
+
+	result, err := b.rpcClient.GetMempoolInfo()
+	if err != nil {
+		return errors.Wrap(err, "error getting mempool info")
+	}
+
+	info := bitcoinrpcclient.MempoolInfoToTypes(time.Now(), result)
+	log.Debugf("mempoolminfee=%d minrelaytxfee=%d sat/kvB", info.MempoolMinFee, info.MinRelayTxFee)
+	config := b.Config()
+	config.checkAlerts(info.Size, info.MempoolMinFee)
+	return b.storage.InsertMempoolInfo(&info)
+}
+
+func (b *BademeisterDaemon) nodeConfigLoop() {
+	for {
+		if err := b.RecordNodeConfig(); err != nil {
+			log.Errorf("Error in RecordNodeConfig(): %s", err)
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(nodeConfigCheckInterval):
+		}
+	}
+}
+
+// RecordNodeConfig stores the node settings if they changed since the last snapshot
+func (b *BademeisterDaemon) RecordNodeConfig() error {
+	if b.rpcClient == nil {
+		return errors.New("no rpcClient")
+	}
+
+	config, err := b.rpcClient.GetNodeConfig()
+	if err != nil {
+		return errors.Wrap(err, "error getting node config")
+	}
+
+	changed, err := b.storage.InsertNodeConfigIfChanged(config)
+	if err != nil {
+		return err
+	}
+	if changed {
+		log.Infof(
+			"Node config: version=%d subversion=%s chain=%s maxmempool=%d minrelaytxfee=%d",
+			config.Version, config.SubVersion, config.Chain, config.MaxMempool, config.MinRelayTxFee,
+		)
+	}
+
+	return nil
+}
+
 // RunParams describes run parameters for BademeisterDaemon
 type RunParams struct {
 	InitMempoolRPC bool
 	InitBlocksRPC  bool
+	// MempoolExpiry is the `-mempoolexpiry` setting of the node.
+	// Transactions are checked for expiry if it is non-zero and an rpcClient is set.
+	MempoolExpiry time.Duration
+	// MempoolInfoInterval is the interval for recording `getmempoolinfo`.
+	// Disabled if zero or if no rpcClient is set.
+	MempoolInfoInterval time.Duration
+	// Heuristics enables the classification of incoming transactions, see package heuristics
+	Heuristics bool
+	// TrackDust enables recording the dust outputs of incoming transactions (see storage.DustReport)
+	// with the thresholds of Config.Dust
+	TrackDust bool
+	// StoreDetails enables storing inputs and outputs of incoming transactions
+	StoreDetails bool
+	// StoreRaw enables storing incoming transactions serialized, see storage.TransactionRaw
+	StoreRaw bool
+	// PrivacySalt enables the data minimization mode if set, see package privacy.
+	// Overrides StoreDetails and StoreRaw.
+	PrivacySalt []byte
+	// ConfigPath is the JSON file with reloadable settings, see Config.
+	// Optional, DefaultConfig is used if empty.
+	ConfigPath string
+	// LogLevel is the log level of the command line, which is restored when a config without
+	// logLevel is activated. Optional, the level is kept if empty.
+	LogLevel string
+	// Mirror is updated with the current mempool if set
+	Mirror *mirror.Mirror
+	// DedupCapacity is the number of recently stored txids per generation of the duplicate filter.
+	// Zero disables the filter.
+	DedupCapacity int
+	// REST is used instead of the rpcClient for the initial mempool and block backfills if set
+	REST *bitcoinrest.Client
+	// MempoolPollInterval is the interval for polling the node mempool for new transactions,
+	// for nodes that do not publish `rawtxwithfee` (see IngestionPoll). Disabled if zero.
+	MempoolPollInterval time.Duration
+	// PackageWindow enables recording children that arrive before their parents (see types.PackageEvent)
+	// if the parent arrives within the window. Disabled if zero.
+	PackageWindow time.Duration
+	// OrphanPoolSize is the number of transactions with unknown parents that are kept until
+	// the parents arrive, see types.OrphanResolution. Only used with StoreDetails, disabled if zero.
+	OrphanPoolSize int
+	// CheckpointInterval enables continuous replication (see storage.EnableReplication) with
+	// WAL checkpoints between the writes of Run at this interval. Disabled if zero.
+	CheckpointInterval time.Duration
+	// HeaderFastPath processes blocks announced via `hashblock` (see zmqsubscriber.WithBlockHashes):
+	// the header is fetched right away and the block is fetched and deserialized in the background,
+	// so the first-seen time of a block is not delayed by its size. Requires an rpcClient or REST client.
+	HeaderFastPath bool
+	// Analyzers are the names of the analyzers run on incoming transactions and blocks, see package analysis.
+	// They get the Mirror as mempool if set.
+	Analyzers []string
+	// SampleRate is the fraction of the transactions that is stored, selected by txid (see types.Sampled).
+	// Blocks are always stored. All transactions are stored if zero.
+	SampleRate float64
+	// ScriptHook is the path of a Starlark script that can drop, tag and measure incoming
+	// transactions and blocks, see package scripthook. Disabled if empty.
+	ScriptHook string
 }
 
 // Run starts the zmqSub loop which feeds zmqSub channels.
 // Wait on zmqSub channels and call `processBlock`, `processTransaction`.
 // Stop on quit signal or errors.
 func (b *BademeisterDaemon) Run(params RunParams) error {
+	defer close(b.stopped)
+	b.configMu.Lock()
+	b.logLevel = params.LogLevel
+	b.configMu.Unlock()
+	if params.ConfigPath != "" {
+		b.configPath = params.ConfigPath
+		if err := b.ReloadConfig(); err != nil {
+			return err
+		}
+	}
+
+	if params.SampleRate < 0 || params.SampleRate > 1 {
+		return errors.Errorf("invalid sample rate %f", params.SampleRate)
+	}
+	if params.SampleRate > 0 && params.SampleRate < 1 {
+		log.Infof("Sampling: storing %g of the transactions", params.SampleRate)
+		b.sampleRate = params.SampleRate
+	}
+	if err := b.storage.RecordSamplingRate(time.Now(), b.effectiveSampleRate()); err != nil {
+		return err
+	}
+	b.classify = params.Heuristics
+	b.trackDust = params.TrackDust
+	b.storeDetails = params.StoreDetails
+	b.storeRaw = params.StoreRaw
+	if params.PrivacySalt != nil {
+		log.Infof("Privacy mode: storing salted short hashes of txids")
+		b.hasher = privacy.NewTxIDHasher(params.PrivacySalt)
+	}
+	b.mirror = params.Mirror
+	if len(params.Analyzers) > 0 {
+		// no typed nil in the interface
+		var mempool analysis.Mempool
+		if b.mirror != nil {
+			mempool = b.mirror
+		}
+		runner, err := analysis.NewRunner(params.Analyzers, mempool)
+		if err != nil {
+			return err
+		}
+		log.Infof("Analyzers: %s", strings.Join(params.Analyzers, ", "))
+		b.analysis = runner
+	}
+	if err := b.startScript(params.ScriptHook); err != nil {
+		return err
+	}
+	defer func() {
+		if b.script != nil {
+			b.script.Close()
+		}
+	}()
+	if params.REST != nil {
+		log.Infof("Using the REST interface at %s for backfills", params.REST.Address)
+		b.rest = params.REST
+	}
+	if params.PackageWindow > 0 {
+		log.Infof("Package observation: waiting %s for unknown parents", params.PackageWindow)
+		b.packages = newPackageTracker(params.PackageWindow)
+	}
+	if params.StoreDetails && params.OrphanPoolSize > 0 {
+		log.Infof("Orphan pool: %d transactions", params.OrphanPoolSize)
+		b.orphans = newOrphanPool(params.OrphanPoolSize, &b.orphanStats)
+	}
+	if params.DedupCapacity > 0 {
+		b.dedup = dedup.NewFilter(params.DedupCapacity, dedupFPRate)
+		log.Infof("Duplicate filter: %d txids, %d bytes per generation", params.DedupCapacity, b.dedup.Size())
+	}
+	verifyDuplicates := time.NewTicker(dedupVerifyInterval)
+	defer verifyDuplicates.Stop()
+
+	// nil channel if disabled
+	var checkpoints <-chan time.Time
+	if params.CheckpointInterval > 0 {
+		if err := b.storage.EnableReplication(); err != nil {
+			return err
+		}
+		log.Infof("Replication: WAL checkpoints every %s", params.CheckpointInterval)
+		checkpoint := time.NewTicker(params.CheckpointInterval)
+		defer checkpoint.Stop()
+		checkpoints = checkpoint.C
+	}
+
+	// nil channel if disabled. Sent from the loop below, so a hanging loop is restarted.
+	var watchdog <-chan time.Time
+	watchdogInterval, err := service.WatchdogInterval()
+	if err != nil {
+		return err
+	}
+	if watchdogInterval > 0 {
+		log.Infof("Service watchdog: notifying every %s", watchdogInterval)
+		ticker := time.NewTicker(watchdogInterval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	b.setFeatures(&params)
+
 	var zmqSubErr error
 	go func() {
 		zmqSubErr = b.zmqSub.Run()
@@ -124,6 +795,34 @@ func (b *BademeisterDaemon) Run(params RunParams) error {
 	}()
 
 	go b.dumpStatsLoop()
+	go b.pruneLoop()
+	go b.notifyLoop()
+	go b.aggregateLoop()
+	go b.bestChainLoop()
+	go b.dashboardLoop()
+	go b.backupLoop()
+
+	if b.rpcClient != nil {
+		go b.nodeConfigLoop()
+	}
+
+	if params.MempoolExpiry > 0 && b.rpcClient != nil {
+		go b.expireTransactionsLoop(params.MempoolExpiry)
+	}
+
+	if params.MempoolInfoInterval > 0 && b.rpcClient != nil {
+		go b.pollMempoolInfoLoop(params.MempoolInfoInterval)
+	}
+
+	if params.HeaderFastPath {
+		if b.backfillSource() == nil {
+			return errors.New("the header fast path requires an rpcClient or REST client")
+		}
+		log.Infof("Header fast path: fetching announced blocks in the background")
+		queue := make(chan zmqsubscriber.BlockHash, blockQueueSize)
+		go b.announceBlocksLoop(queue)
+		go b.fetchBlocksLoop(queue)
+	}
 
 	if params.InitMempoolRPC {
 		// it is OK to block here since IncomingTx will be queued
@@ -133,6 +832,14 @@ func (b *BademeisterDaemon) Run(params RunParams) error {
 		}
 	}
 
+	if params.MempoolPollInterval > 0 {
+		if b.backfillSource() == nil {
+			return errors.New("polling the mempool requires an rpcClient or REST client")
+		}
+		// started after InitMempoolRPC, which stores the transactions of the first poll
+		go b.pollMempoolLoop(params.MempoolPollInterval, params.InitMempoolRPC)
+	}
+
 	if params.InitBlocksRPC {
 		hasBlocks, err := b.storage.HasBlocks()
 		if err != nil {
@@ -147,37 +854,127 @@ func (b *BademeisterDaemon) Run(params RunParams) error {
 			log.Printf("no blocks in database, skipping InitBlocksRPC")
 		}
 	}
+	if b.mirror != nil {
+		if err := b.RecoverMirror(); err != nil {
+			return err
+		}
+	}
+
 	b.dumpStats()
+	notifyService(service.StateReady)
 
 	for {
 		select {
 		case <-b.quit:
 			log.Printf("Received quit signal")
+			notifyService(service.StateStopping)
 			b.quit <- struct{}{}
+			if err := b.verifyDuplicates(); err != nil {
+				log.Errorf("Error in verifyDuplicates(): %s", err)
+			}
+			if checkpoints != nil {
+				b.checkpoint()
+			}
 			return zmqSubErr
+		case <-checkpoints:
+			b.checkpoint()
+		case <-watchdog:
+			notifyService(service.StateWatchdog)
+		case <-verifyDuplicates.C:
+			if err := b.verifyDuplicates(); err != nil {
+				log.Errorf("Error in verifyDuplicates(): %s", err)
+				return err
+			}
 		case tx := <-b.zmqSub.IncomingTx:
+			b.latency.dequeued(&tx)
+			if b.Paused() {
+				atomic.AddUint64(&b.droppedTxs, 1)
+				continue
+			}
 			if err := b.processTransaction(&tx); err != nil {
 				log.Errorf("Error in processTransaction(): %s", err)
 				return err
 			}
+		case txs := <-b.polledTxs:
+			if b.Paused() {
+				atomic.AddUint64(&b.droppedTxs, uint64(len(txs)))
+				continue
+			}
+			if err := b.processTransactions(txs); err != nil {
+				log.Errorf("Error in processTransactions(): %s", err)
+				return err
+			}
 		case block := <-b.zmqSub.IncomingBlocks:
-			if err := b.processBlock(&block); err != nil {
-				log.Errorf("Error in processBlock(): %s", err)
+			if err := b.handleBlock(&block); err != nil {
 				return err
 			}
-			b.dumpStats()
+		case block := <-b.fetchedBlocks:
+			if err := b.handleBlock(&block); err != nil {
+				return err
+			}
+		case result := <-b.resyncs:
+			result <- b.resync()
 		}
 	}
 }
 
-// InitMempoolRPC uses the bitcoind rpc command `getrawmemmpool` to get the current mempool snapshot
+// notifyService sends `state` to the service manager, if any
+func notifyService(state string) {
+	if _, err := service.Notify(state); err != nil {
+		log.Errorf("Could not notify the service manager: %s", err)
+	}
+}
+
+// handleBlock processes a block received by Run and backfills missing parents
+func (b *BademeisterDaemon) handleBlock(block *types.Block) error {
+	if b.Paused() {
+		atomic.AddUint64(&b.droppedBlocks, 1)
+		return nil
+	}
+	err := b.processBlock(block)
+	if errors.Cause(err) == types.ErrReorgDetected && b.backfillSource() != nil {
+		// the parent is missing, fetch it and the block from the node
+		log.Warnf("%s, backfilling blocks", err)
+		err = b.InitBlocksRPC()
+	}
+	if err != nil {
+		log.Errorf("Error in processBlock(): %s", err)
+		return err
+	}
+	b.dumpStats()
+	return nil
+}
+
+// nodeSource provides the mempool and the blocks of the node for backfills.
+// Implemented by bitcoinrpcclient.BitcoinRPCClient and bitcoinrest.Client.
+type nodeSource interface {
+	GetRawMempoolVerbose() (map[string]bitcoinrpcclient.GetRawMempoolVerboseResult, error)
+	GetBestBlockHash() (*chainhash.Hash, error)
+	GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error)
+	GetBlockHeader(hash *chainhash.Hash) (*wire.BlockHeader, error)
+}
+
+// backfillSource returns the REST client if set, otherwise the rpcClient, or nil if neither is set
+func (b *BademeisterDaemon) backfillSource() nodeSource {
+	if b.rest != nil {
+		return b.rest
+	}
+	if b.rpcClient != nil {
+		return b.rpcClient
+	}
+	return nil
+}
+
+// InitMempoolRPC uses the bitcoind rpc command `getrawmemmpool` to get the current mempool snapshot,
+// or `/rest/mempool/contents` if the REST interface is used
 func (b *BademeisterDaemon) InitMempoolRPC() error {
-	if b.rpcClient == nil {
-		return errors.New("no rpcClient")
+	source := b.backfillSource()
+	if source == nil {
+		return errors.New("no rpcClient or REST client")
 	}
 
 	log.Printf("Fetching raw mempool...")
-	mempool, err := b.rpcClient.GetRawMempoolVerbose()
+	mempool, err := source.GetRawMempoolVerbose()
 	if err != nil {
 		return errors.Wrap(err, "error getting raw mempool")
 	}
@@ -198,12 +995,108 @@ func (b *BademeisterDaemon) InitMempoolRPC() error {
 	return nil
 }
 
-func (b *BademeisterDaemon) findMissingBlocks(maxBackfill int) (res []types.Block, err error) {
+// ExpireTransactions marks transactions that are older than `window` and that are not in the
+// mempool of the node anymore as dropped, with the reason if it is known: replaced or conflicted
+// if a conflicting transaction is stored, expired if the transaction was in the node mempool at the
+// last check before its expiry, otherwise unknown (e.g. evicted).
+// Transactions that are still in the node mempool might have been re-added
+// after the node restarted, so they are not considered expired.
+func (b *BademeisterDaemon) ExpireTransactions(window time.Duration) error {
 	if b.rpcClient == nil {
-		return nil, errors.New("no rpcClient")
+		return errors.New("no rpcClient")
 	}
 
-	current, err := b.rpcClient.GetBestBlockHash()
+	// transactions expiring before the next check are included, to know whether they were in the
+	// node mempool shortly before they expired
+	now := time.Now()
+	txIter, err := b.storage.ExpiryCandidates(now.Add(expiryCheckInterval), window)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	candidates, err := txIter.Collect()
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	nodeMempool, err := b.rpcClient.GetRawMempool()
+	if err != nil {
+		return errors.Wrap(err, "error getting raw mempool")
+	}
+
+	inNodeMempool := map[types.Hash32]struct{}{}
+	for _, h := range nodeMempool {
+		txid := types.NewHashFromArray(*h)
+		if b.hasher != nil {
+			txid = b.hasher.Hash(txid)
+		}
+		inNodeMempool[txid] = struct{}{}
+	}
+
+	inNode := map[int64]bool{}
+	var dropped []types.StoredTransaction
+	var droppedIDs []int64
+	for _, tx := range candidates {
+		if _, ok := inNodeMempool[tx.TxID]; ok {
+			inNode[tx.DBID] = true
+		} else if !tx.FirstSeen.Add(window).After(now) {
+			dropped = append(dropped, tx)
+			droppedIDs = append(droppedIDs, tx.DBID)
+		}
+	}
+	inNodeBefore := b.expiring
+	b.expiring = inNode
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	drops, err := b.storage.DropCauses(droppedIDs)
+	if err != nil {
+		return err
+	}
+	conflicting := len(drops)
+	expired := []int64{}
+	droppedTxIDs := make([]types.Hash32, len(dropped))
+	for i, tx := range dropped {
+		droppedTxIDs[i] = tx.TxID
+		if _, ok := drops[tx.DBID]; ok {
+			continue
+		}
+		if inNodeBefore[tx.DBID] {
+			expired = append(expired, tx.DBID)
+		} else {
+			// left at an unknown time before it would have expired
+			drops[tx.DBID] = types.TxDrop{Time: tx.FirstSeen.Add(window), Reason: types.DropUnknown}
+		}
+	}
+
+	log.Infof(
+		"Marking %d of %d candidates as dropped: %d expired, %d with a conflicting transaction, %d unknown",
+		len(dropped), len(candidates), len(expired), conflicting, len(dropped)-len(expired)-conflicting,
+	)
+	if err := b.storage.MarkExpired(expired, window); err != nil {
+		return err
+	}
+	if err := b.storage.MarkDrops(drops); err != nil {
+		return err
+	}
+	if b.mirror != nil {
+		b.mirror.Remove(droppedTxIDs...)
+	}
+	b.watched.forget(droppedTxIDs...)
+	b.rebroadcastWatched(droppedTxIDs)
+	return nil
+}
+
+func (b *BademeisterDaemon) findMissingBlocks(maxBackfill int) (res []types.Block, err error) {
+	source := b.backfillSource()
+	if source == nil {
+		return nil, errors.New("no rpcClient or REST client")
+	}
+
+	current, err := source.GetBestBlockHash()
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -224,7 +1117,7 @@ func (b *BademeisterDaemon) findMissingBlocks(maxBackfill int) (res []types.Bloc
 			break
 		}
 
-		wireBlock, err := b.rpcClient.GetBlock(current)
+		wireBlock, err := source.GetBlock(current)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -242,7 +1135,8 @@ func (b *BademeisterDaemon) findMissingBlocks(maxBackfill int) (res []types.Bloc
 	return res, nil
 }
 
-// InitBlocksRPC fetches missing blocks that were dropped while BademeisterDaemon was not running
+// InitBlocksRPC fetches missing blocks that were dropped while BademeisterDaemon was not running,
+// via the REST interface if set
 func (b *BademeisterDaemon) InitBlocksRPC() error {
 	bestBlock, err := b.storage.BestBlockNow()
 	if err != nil {
@@ -268,6 +1162,11 @@ func (b *BademeisterDaemon) InitBlocksRPC() error {
 	return nil
 }
 
+// Storage returns the storage of the daemon
+func (b *BademeisterDaemon) Storage() *storage.Storage {
+	return b.storage
+}
+
 // Stop makes Run() return
 func (b *BademeisterDaemon) Stop() {
 	b.quit <- struct{}{}
@@ -275,16 +1174,16 @@ func (b *BademeisterDaemon) Stop() {
 
 // Close shuts down the storage
 func (b *BademeisterDaemon) Close() error {
-	errors := false
+	failed := false
 
 	errStorage := b.storage.Close()
 	if errStorage != nil {
 		log.Errorf("error closing db: %v", errStorage)
-		errors = true
+		failed = true
 	}
 
-	if errors {
-		return fmt.Errorf("there were errors, see logs for details")
+	if failed {
+		return errors.New("there were errors, see logs for details")
 	}
 
 	return nil
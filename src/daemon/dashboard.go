@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/objectstore"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DashboardFile is the name of the exported dashboard bundle
+const DashboardFile = "dashboard.json"
+
+// DefaultDashboardBlocks is the default number of blocks in the dashboard bundle
+const DefaultDashboardBlocks = 10
+
+// dashboardCheckInterval is the interval in which a disabled export checks the config again
+const dashboardCheckInterval = time.Minute
+
+// DashboardConfig configures the periodic export of public aggregates for a statically hosted dashboard
+type DashboardConfig struct {
+	// Interval between two exports, zero disables the export
+	Interval Duration `json:"interval"`
+	// Target of the dashboard bundle
+	Store objectstore.Config `json:"store"`
+	// Number of recent blocks (default 10)
+	Blocks int `json:"blocks"`
+}
+
+// Validate returns an error if a setting has an invalid value
+func (c *DashboardConfig) Validate() error {
+	if c.Interval.Duration < 0 {
+		return errors.Errorf("invalid dashboard interval %s", c.Interval)
+	}
+	if c.Blocks < 0 {
+		return errors.Errorf("invalid dashboard blocks %d", c.Blocks)
+	}
+	if c.Interval.Duration > 0 {
+		if _, err := objectstore.New(c.Store); err != nil {
+			return errors.Wrap(err, "invalid dashboard store")
+		}
+	}
+	return nil
+}
+
+func (b *BademeisterDaemon) dashboardLoop() {
+	for {
+		interval := dashboardCheckInterval
+		config := b.Config().Dashboard
+		if config.Interval.Duration > 0 {
+			interval = config.Interval.Duration
+			if err := b.ExportDashboard(config); err != nil {
+				log.Errorf("Error in ExportDashboard(): %s", err)
+			}
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Dashboard returns the public aggregates at `t`.
+// The mempool is read from the mirror if enabled.
+func (b *BademeisterDaemon) Dashboard(t time.Time, blocks int) (*types.Dashboard, error) {
+	var txs []types.Transaction
+	if b.mirror != nil {
+		txs = b.mirror.Transactions()
+	} else {
+		mempool, err := storage.NewMempoolAtTime(b.storage, t)
+		if err != nil {
+			return nil, err
+		}
+		txs = mempool.Transactions()
+	}
+
+	recent, err := b.storage.RecentBestBlocks(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	to := t.Truncate(time.Hour)
+	hourly, err := b.storage.Aggregates(time.Hour, to.Add(-24*time.Hour), to)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &types.Dashboard{
+		Time:    t.UTC(),
+		Mempool: types.NewMempoolSummary(t.UTC(), txs).Histogram(),
+		Blocks:  []types.DashboardBlock{},
+		Hourly:  hourly,
+	}
+	for _, block := range recent {
+		dashboard.Blocks = append(dashboard.Blocks, types.DashboardBlock{
+			Height:       block.Height,
+			FirstSeen:    block.FirstSeen,
+			Transactions: block.TxCount,
+			Weight:       block.Weight,
+			Miner:        block.Miner,
+			NearEmpty:    block.NearEmpty,
+		})
+	}
+	return dashboard, nil
+}
+
+// ExportDashboard writes the current public aggregates as DashboardFile to the store of `config`
+func (b *BademeisterDaemon) ExportDashboard(config DashboardConfig) error {
+	store, err := objectstore.New(config.Store)
+	if err != nil {
+		return err
+	}
+
+	blocks := config.Blocks
+	if blocks == 0 {
+		blocks = DefaultDashboardBlocks
+	}
+	dashboard, err := b.Dashboard(time.Now(), blocks)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(dashboard)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(DashboardFile, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+
+	log.Debugf("Exported dashboard: %d transactions, %d blocks", dashboard.Mempool.Transactions, len(dashboard.Blocks))
+	return nil
+}
@@ -0,0 +1,58 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/objectstore"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestBademeisterDaemon_ExportDashboard(t *testing.T) {
+	st := newTestStorage(t, "daemon_dashboard.db")
+	defer st.Close()
+
+	dir, err := ioutil.TempDir("", "dashboard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	b := &BademeisterDaemon{storage: st, mirror: mirror.New()}
+	tx := func(id string) types.Transaction {
+		return types.Transaction{TxID: test.GenerateHash32(id), FirstSeen: time.Unix(100, 0), Fee: 1000, Weight: 400}
+	}
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("confirmed"), tx("unconfirmed")}))
+	block := types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: time.Unix(200, 0),
+		Height:    1,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{tx("confirmed").TxID},
+	}
+	require.NoError(t, b.processBlock(&block))
+
+	config := DashboardConfig{
+		Interval: Duration{time.Minute},
+		Store:    objectstore.Config{Type: objectstore.TypeDir, Dir: dir},
+	}
+	require.NoError(t, config.Validate())
+	require.NoError(t, b.ExportDashboard(config))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, DashboardFile))
+	require.NoError(t, err)
+	var dashboard types.Dashboard
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+	assert.Equal(t, 1, dashboard.Mempool.Transactions)
+	assert.Equal(t, []int{10}, dashboard.Mempool.Feerates)
+	require.Len(t, dashboard.Blocks, 1)
+	assert.Equal(t, uint32(1), dashboard.Blocks[0].Height)
+	assert.NotContains(t, string(data), tx("unconfirmed").TxID.String())
+}
@@ -0,0 +1,45 @@
+package daemon
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DedupStats are counters of the duplicate filter for incoming transactions
+type DedupStats struct {
+	// Transactions tested against the filter
+	Checked uint64 `json:"checked"`
+	// Transactions skipped because the filter reported them as stored
+	Hits uint64 `json:"hits"`
+	// Skipped transactions that turned out not to be stored and were stored afterwards
+	FalsePositives uint64 `json:"falsePositives"`
+	// Hits / Checked
+	HitRate float64 `json:"hitRate"`
+}
+
+// DedupStats returns the counters of the duplicate filter
+func (b *BademeisterDaemon) DedupStats() DedupStats {
+	s := DedupStats{
+		Checked:        atomic.LoadUint64(&b.dedupStats.Checked),
+		Hits:           atomic.LoadUint64(&b.dedupStats.Hits),
+		FalsePositives: atomic.LoadUint64(&b.dedupStats.FalsePositives),
+	}
+	if s.Checked > 0 {
+		s.HitRate = float64(s.Hits) / float64(s.Checked)
+	}
+	return s
+}
+
+// dedupKey returns the key of `tx` in the duplicate filter. It is the wtxid, or the txid if the
+// transaction has no witness, with the fee mixed in, so versions with a different witness or
+// fee are stored (see storage.WitnessReplacements and storage.FeeUpdates).
+func dedupKey(tx *types.Transaction) types.Hash32 {
+	key := tx.TxID
+	if tx.WTxID != nil {
+		key = *tx.WTxID
+	}
+	binary.LittleEndian.PutUint64(key[:8], binary.LittleEndian.Uint64(key[:8])^tx.Fee)
+	return key
+}
@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/dedup"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// newTestStorage returns an empty storage `name` in the directory of the integration tests.
+// Skips the test in short mode.
+func newTestStorage(t *testing.T, name string) *storage.Storage {
+	test.SkipIfShort(t)
+
+	path := os.Getenv("TEST_INTEGRATION_DIR") + "/" + name
+	require.NoError(t, os.RemoveAll(path))
+	st, err := storage.NewStorage(path)
+	require.NoError(t, err)
+	return st
+}
+
+func TestBademeisterDaemon_Dedup(t *testing.T) {
+	st := newTestStorage(t, "daemon_dedup.db")
+	defer st.Close()
+
+	b := &BademeisterDaemon{storage: st, dedup: dedup.NewFilter(100, 0.01)}
+	tx := func(id string) *types.Transaction {
+		return &types.Transaction{TxID: test.GenerateHash32(id), FirstSeen: time.Unix(100, 0), Weight: 400}
+	}
+
+	require.NoError(t, b.processTransaction(tx("a")))
+	require.NoError(t, b.processTransaction(tx("a")))
+	assert.Len(t, b.dedupPending, 1)
+	require.NoError(t, b.verifyDuplicates())
+	assert.Empty(t, b.dedupPending)
+
+	// a false positive is stored on verification
+	b.dedup.Add(dedupKey(tx("b")))
+	require.NoError(t, b.processTransaction(tx("b")))
+	stored, err := st.StoredTxIDs([]types.Hash32{tx("b").TxID})
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+	require.NoError(t, b.verifyDuplicates())
+	stored, err = st.StoredTxIDs([]types.Hash32{tx("b").TxID})
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+
+	assert.Equal(t, DedupStats{Checked: 3, Hits: 2, FalsePositives: 1, HitRate: 2.0 / 3}, b.DedupStats())
+
+	// a different witness of a stored transaction is not a duplicate
+	witness := func(id string) *types.Transaction {
+		wtxid := test.GenerateHash32(id)
+		tx := tx("c")
+		tx.WTxID = &wtxid
+		return tx
+	}
+	require.NoError(t, b.processTransaction(witness("c-1")))
+	require.NoError(t, b.processTransaction(witness("c-1")))
+	assert.Len(t, b.dedupPending, 1)
+	require.NoError(t, b.processTransaction(witness("c-2")))
+	assert.Len(t, b.dedupPending, 1)
+	replacements, err := st.WitnessReplacements(storage.WitnessReplacementQuery{})
+	require.NoError(t, err)
+	assert.Len(t, replacements, 1)
+
+	// neither is a different fee
+	fee := tx("a")
+	fee.Fee = 1000
+	require.NoError(t, b.processTransaction(fee))
+	assert.Len(t, b.dedupPending, 1)
+	updates, err := st.FeeUpdates(storage.FeeUpdateQuery{})
+	require.NoError(t, err)
+	assert.Len(t, updates, 1)
+}
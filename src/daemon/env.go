@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Ports of the all-in-one mode, see NodeEnv
+const (
+	AllInOneAPIAddress   = ":8080"
+	AllInOneAdminAddress = ":9090"
+)
+
+// default ports of bitcoind on mainnet
+const (
+	defaultRPCPort = "8332"
+	defaultZMQPort = "28332"
+)
+
+// NodeEnv is the connection to the node configured by the environment variables
+// BITCOIND_RPC_HOST, BITCOIND_RPC_PORT, BITCOIND_RPC_USER, BITCOIND_RPC_PASSWORD, BITCOIND_RPC_COOKIE,
+// BITCOIND_ZMQ_ADDRESS, BITCOIND_ZMQ_HOST and BITCOIND_ZMQ_PORT, as used by the all-in-one mode of the daemon
+type NodeEnv struct {
+	// RPCAddress is the URL of the RPC interface with credentials, empty if no BITCOIND_RPC_* variable is set
+	RPCAddress string
+	// ZMQAddress is the ZMQ endpoint, `auto` for discovery via RPC if no BITCOIND_ZMQ_* variable is set,
+	// empty if neither is set
+	ZMQAddress string
+}
+
+// ParseNodeEnv returns the NodeEnv of the variables returned by `getenv`, e.g. os.Getenv.
+// The credentials are read from the cookie file BITCOIND_RPC_COOKIE if set.
+func ParseNodeEnv(getenv func(string) string) (NodeEnv, error) {
+	var env NodeEnv
+
+	host, port := getenv("BITCOIND_RPC_HOST"), getenv("BITCOIND_RPC_PORT")
+	user, password, cookie := getenv("BITCOIND_RPC_USER"), getenv("BITCOIND_RPC_PASSWORD"), getenv("BITCOIND_RPC_COOKIE")
+	if host != "" || port != "" || user != "" || password != "" || cookie != "" {
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		if port == "" {
+			port = defaultRPCPort
+		}
+		if cookie != "" {
+			if user != "" || password != "" {
+				return env, errors.New("BITCOIND_RPC_COOKIE cannot be combined with BITCOIND_RPC_USER and BITCOIND_RPC_PASSWORD")
+			}
+			data, err := ioutil.ReadFile(cookie)
+			if err != nil {
+				return env, errors.Wrap(err, "could not read BITCOIND_RPC_COOKIE")
+			}
+			parts := strings.SplitN(strings.TrimSpace(string(data)), ":", 2)
+			if len(parts) != 2 {
+				return env, errors.Errorf("invalid cookie file %s", cookie)
+			}
+			user, password = parts[0], parts[1]
+		}
+		u := url.URL{Scheme: "http", Host: net.JoinHostPort(host, port)}
+		if user != "" || password != "" {
+			u.User = url.UserPassword(user, password)
+		}
+		env.RPCAddress = u.String()
+	}
+
+	address, zmqHost, zmqPort := getenv("BITCOIND_ZMQ_ADDRESS"), getenv("BITCOIND_ZMQ_HOST"), getenv("BITCOIND_ZMQ_PORT")
+	switch {
+	case address != "":
+		if zmqHost != "" || zmqPort != "" {
+			return env, errors.New("BITCOIND_ZMQ_ADDRESS cannot be combined with BITCOIND_ZMQ_HOST and BITCOIND_ZMQ_PORT")
+		}
+		env.ZMQAddress = address
+	case zmqHost != "" || zmqPort != "":
+		if zmqHost == "" {
+			zmqHost = "127.0.0.1"
+		}
+		if zmqPort == "" {
+			zmqPort = defaultZMQPort
+		}
+		env.ZMQAddress = fmt.Sprintf("tcp://%s", net.JoinHostPort(zmqHost, zmqPort))
+	case env.RPCAddress != "":
+		env.ZMQAddress = "auto"
+	}
+	return env, nil
+}
@@ -0,0 +1,197 @@
+package daemon
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
+	"github.com/0xb10c/bademeister-go/src/types"
+	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
+)
+
+// IngestionMode selects how transactions are received from the node
+type IngestionMode string
+
+const (
+	// IngestionAuto selects the mode by the notifications published by the node, see DetectIngestionMode
+	IngestionAuto IngestionMode = "auto"
+	// IngestionRawTxWithFee receives transactions via the `rawtxwithfee` topic of a patched node
+	IngestionRawTxWithFee IngestionMode = "rawtxwithfee"
+	// IngestionRawTx receives transactions via the standard `rawtx` topic and computes their fees
+	// from the prevouts read via RPC, see zmqsubscriber.WithStandardTopics
+	IngestionRawTx IngestionMode = "rawtx"
+	// IngestionPoll receives blocks via `rawblock` and polls the node mempool for new transactions.
+	// Works with an unpatched node, but first-seen times are those of the node and transactions
+	// that are confirmed between two polls are missed.
+	IngestionPoll IngestionMode = "poll"
+)
+
+// DefaultMempoolPollInterval is the default interval between two mempool polls in IngestionPoll mode
+const DefaultMempoolPollInterval = 10 * time.Second
+
+// ParseIngestionMode returns the IngestionMode for `mode`
+func ParseIngestionMode(mode string) (IngestionMode, error) {
+	switch m := IngestionMode(mode); m {
+	case IngestionAuto, IngestionRawTxWithFee, IngestionRawTx, IngestionPoll:
+		return m, nil
+	default:
+		return "", errors.Errorf("invalid ingestion mode %q", mode)
+	}
+}
+
+// DetectIngestionMode selects IngestionRawTxWithFee if the node publishes `rawtxwithfee`,
+// otherwise IngestionRawTx if it publishes `rawtx` and `rawblock`, or IngestionPoll if it only
+// publishes `rawblock`. `notifications` is the result of `getzmqnotifications`.
+func DetectIngestionMode(notifications []bitcoinrpcclient.ZMQNotification) (IngestionMode, error) {
+	topics := map[string]bool{}
+	for _, n := range notifications {
+		topics[strings.TrimPrefix(n.Type, "pub")] = true
+	}
+
+	switch {
+	case topics[zmqsubscriber.TopicRawTxWithFee]:
+		return IngestionRawTxWithFee, nil
+	case topics[zmqsubscriber.TopicRawTx] && topics[zmqsubscriber.TopicRawBlock]:
+		return IngestionRawTx, nil
+	case topics[zmqsubscriber.TopicRawBlock]:
+		return IngestionPoll, nil
+	default:
+		return "", errors.New("the node publishes neither rawtxwithfee nor rawblock via ZMQ (-zmqpubrawblock)")
+	}
+}
+
+// DiscoverZMQEndpoints returns the endpoints that publish the topics needed in `mode`, which must not be
+// IngestionAuto, with blocks announced via `blockTopic` (`rawblock` or `hashblock`, see
+// RunParams.HeaderFastPath). `notifications` is the result of `getzmqnotifications`. Wildcard and
+// unspecified hosts of bound addresses (e.g. tcp://0.0.0.0:28332) are replaced by `nodeHost`.
+func DiscoverZMQEndpoints(
+	notifications []bitcoinrpcclient.ZMQNotification, mode IngestionMode, blockTopic, nodeHost string,
+) ([]string, error) {
+	topics := []string{blockTopic}
+	switch mode {
+	case IngestionRawTxWithFee:
+		topics = append(topics, zmqsubscriber.TopicRawTxWithFee)
+	case IngestionRawTx:
+		topics = append(topics, zmqsubscriber.TopicRawTx)
+	}
+
+	var endpoints []string
+	for _, topic := range topics {
+		address := ""
+		for _, n := range notifications {
+			if n.Type == "pub"+topic {
+				address = n.Address
+				break
+			}
+		}
+		if address == "" {
+			return nil, errors.Errorf("the node does not publish %s via ZMQ", topic)
+		}
+
+		address = resolveZMQAddress(address, nodeHost)
+		known := false
+		for _, e := range endpoints {
+			known = known || e == address
+		}
+		if !known {
+			endpoints = append(endpoints, address)
+		}
+	}
+	return endpoints, nil
+}
+
+// resolveZMQAddress replaces a wildcard or unspecified host in the ZMQ `address` by `host`
+func resolveZMQAddress(address, host string) string {
+	if host == "" {
+		return address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Port() == "" {
+		// ipc:// and malformed addresses are used as is
+		return address
+	}
+	switch u.Hostname() {
+	case "*", "0.0.0.0", "::":
+		u.Host = net.JoinHostPort(host, u.Port())
+		return u.String()
+	}
+	return address
+}
+
+// SubscriberOptions returns the ZMQSubscriber options for `mode`. `prevouts` computes the fees in
+// IngestionRawTx mode and is not used otherwise.
+func (m IngestionMode) SubscriberOptions(prevouts zmqsubscriber.PrevoutFetcher) []zmqsubscriber.Option {
+	switch m {
+	case IngestionPoll:
+		return []zmqsubscriber.Option{zmqsubscriber.WithBlocksOnly()}
+	case IngestionRawTx:
+		return []zmqsubscriber.Option{zmqsubscriber.WithStandardTopics(prevouts)}
+	}
+	return nil
+}
+
+// pollMempoolLoop sends the transactions that entered the node mempool since the last poll to Run.
+// If `seeded`, the transactions in the mempool on the first poll are known already.
+func (b *BademeisterDaemon) pollMempoolLoop(interval time.Duration, seeded bool) {
+	var known map[string]struct{}
+	for {
+		txs, mempool, err := b.pollMempool(known)
+		if err != nil {
+			log.Errorf("Error in pollMempool(): %s", err)
+		} else {
+			if known == nil && seeded {
+				txs = nil
+			}
+			known = mempool
+		}
+
+		if len(txs) > 0 {
+			select {
+			case <-b.quit:
+				b.quit <- struct{}{}
+				return
+			case b.polledTxs <- txs:
+			}
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollMempool returns the transactions of the node mempool whose txids are not in `known`,
+// and the txids of the node mempool
+func (b *BademeisterDaemon) pollMempool(known map[string]struct{}) ([]types.Transaction, map[string]struct{}, error) {
+	source := b.backfillSource()
+	if source == nil {
+		return nil, nil, errors.New("no rpcClient or REST client")
+	}
+
+	mempool, err := source.GetRawMempoolVerbose()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting raw mempool")
+	}
+
+	txids := make(map[string]struct{}, len(mempool))
+	for txid := range mempool {
+		txids[txid] = struct{}{}
+		if _, ok := known[txid]; ok {
+			delete(mempool, txid)
+		}
+	}
+
+	txs, err := bitcoinrpcclient.RawMempoolToTransactions(mempool)
+	if err != nil {
+		return nil, nil, err
+	}
+	return txs, txids, nil
+}
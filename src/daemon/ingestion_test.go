@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrest"
+	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
+	"github.com/0xb10c/bademeister-go/src/zmqsubscriber"
+)
+
+func TestDetectIngestionMode(t *testing.T) {
+	notification := func(topic string) bitcoinrpcclient.ZMQNotification {
+		return bitcoinrpcclient.ZMQNotification{Type: "pub" + topic, Address: "tcp://127.0.0.1:28332"}
+	}
+
+	mode, err := DetectIngestionMode([]bitcoinrpcclient.ZMQNotification{
+		notification("rawblock"), notification("rawtxwithfee"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, IngestionRawTxWithFee, mode)
+	assert.Empty(t, mode.SubscriberOptions(nil))
+
+	mode, err = DetectIngestionMode([]bitcoinrpcclient.ZMQNotification{
+		notification("hashblock"), notification("rawblock"), notification("rawtx"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, IngestionRawTx, mode)
+	assert.Len(t, mode.SubscriberOptions(nil), 1)
+
+	mode, err = DetectIngestionMode([]bitcoinrpcclient.ZMQNotification{
+		notification("hashblock"), notification("rawblock"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, IngestionPoll, mode)
+	assert.Len(t, mode.SubscriberOptions(nil), 1)
+
+	_, err = DetectIngestionMode(nil)
+	assert.Error(t, err)
+
+	mode, err = ParseIngestionMode("rawtx")
+	require.NoError(t, err)
+	assert.Equal(t, IngestionRawTx, mode)
+	_, err = ParseIngestionMode("rawtxwithfees")
+	assert.Error(t, err)
+}
+
+func TestBademeisterDaemon_pollMempool(t *testing.T) {
+	mempool := `{
+		"00000000000000000000000000000000000000000000000000000000000000aa": {"weight": 400, "time": 100, "fees": {"base": 0.00001}}
+	}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(mempool))
+	}))
+	defer server.Close()
+
+	rest, err := bitcoinrest.NewClient(server.URL)
+	require.NoError(t, err)
+	b := &BademeisterDaemon{rest: rest}
+
+	txs, known, err := b.pollMempool(nil)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, uint64(1000), txs[0].Fee)
+	assert.Len(t, known, 1)
+
+	mempool = `{
+		"00000000000000000000000000000000000000000000000000000000000000aa": {"weight": 400, "time": 100, "fees": {"base": 0.00001}},
+		"00000000000000000000000000000000000000000000000000000000000000bb": {"weight": 800, "time": 110, "fees": {"base": 0.00002}}
+	}`
+	txs, known, err = b.pollMempool(known)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, 800, txs[0].Weight)
+	assert.Len(t, known, 2)
+
+	_, _, err = (&BademeisterDaemon{}).pollMempool(nil)
+	assert.Error(t, err)
+}
+
+func TestDiscoverZMQEndpoints(t *testing.T) {
+	notifications := []bitcoinrpcclient.ZMQNotification{
+		{Type: "pubhashblock", Address: "tcp://127.0.0.1:28330"},
+		{Type: "pubrawblock", Address: "tcp://0.0.0.0:28332"},
+		{Type: "pubrawtxwithfee", Address: "tcp://0.0.0.0:28332"},
+	}
+	endpoints, err := DiscoverZMQEndpoints(notifications, IngestionRawTxWithFee, zmqsubscriber.TopicRawBlock, "node.local")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tcp://node.local:28332"}, endpoints)
+
+	notifications[2].Address = "tcp://[::]:28333"
+	endpoints, err = DiscoverZMQEndpoints(notifications, IngestionRawTxWithFee, zmqsubscriber.TopicRawBlock, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tcp://10.0.0.1:28332", "tcp://10.0.0.1:28333"}, endpoints)
+
+	endpoints, err = DiscoverZMQEndpoints(notifications[:2], IngestionPoll, zmqsubscriber.TopicRawBlock, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tcp://0.0.0.0:28332"}, endpoints)
+
+	// the node does not publish rawtx
+	_, err = DiscoverZMQEndpoints(notifications, IngestionRawTx, zmqsubscriber.TopicRawBlock, "")
+	assert.Error(t, err)
+
+	endpoints, err = DiscoverZMQEndpoints(notifications, IngestionRawTxWithFee, zmqsubscriber.TopicHashBlock, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tcp://127.0.0.1:28330", "tcp://[::]:28333"}, endpoints)
+
+	_, err = DiscoverZMQEndpoints(notifications[:2], IngestionRawTxWithFee, zmqsubscriber.TopicRawBlock, "node.local")
+	assert.Error(t, err)
+
+	assert.Equal(t, "tcp://node.local:28332", resolveZMQAddress("tcp://*:28332", "node.local"))
+	assert.Equal(t, "ipc:///tmp/zmq", resolveZMQAddress("ipc:///tmp/zmq", "node.local"))
+}
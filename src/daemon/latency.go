@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/metrics"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Stages of incoming transactions, see LatencyMetrics
+const (
+	// from receiving the ZMQ message until parsing starts
+	StageReceive = "receive"
+	// parsing the message
+	StageParse = "parse"
+	// waiting in the queue for Run
+	StageQueue = "queue"
+	// committing the batch of the transaction to the database
+	StageCommit = "commit"
+	// from receiving the ZMQ message until the transaction is committed
+	StageTotal = "total"
+)
+
+// txLatency are the histograms of the stages in Run. Nil histograms do nothing.
+type txLatency struct {
+	queue  *metrics.Histogram
+	commit *metrics.Histogram
+	total  *metrics.Histogram
+}
+
+func newTxLatency() txLatency {
+	return txLatency{queue: metrics.NewHistogram(), commit: metrics.NewHistogram(), total: metrics.NewHistogram()}
+}
+
+// dequeued records the queue wait of a transaction received via ZMQ
+func (l txLatency) dequeued(tx *types.Transaction) {
+	if !tx.Queued.IsZero() {
+		l.queue.Since(tx.Queued)
+	}
+}
+
+// committed records the commit of `txs`, which started at `start`
+func (l txLatency) committed(txs []types.Transaction, start time.Time) {
+	now := time.Now()
+	l.commit.Observe(now.Sub(start))
+	for i := range txs {
+		if !txs[i].Queued.IsZero() {
+			l.total.Observe(now.Sub(txs[i].FirstSeen))
+		}
+	}
+}
+
+// LatencyMetrics returns the latency histograms of incoming transactions by stage
+// (StageReceive, StageParse, StageQueue, StageCommit, StageTotal), to locate backpressure.
+// Commits are counted once per batch, the other stages per transaction received via ZMQ.
+func (b *BademeisterDaemon) LatencyMetrics() map[string]metrics.Snapshot {
+	res := map[string]metrics.Snapshot{
+		StageQueue:  b.latency.queue.Snapshot(),
+		StageCommit: b.latency.commit.Snapshot(),
+		StageTotal:  b.latency.total.Snapshot(),
+	}
+	if b.zmqSub != nil {
+		res[StageReceive] = b.zmqSub.ReceiveLatency.Snapshot()
+		res[StageParse] = b.zmqSub.ParseLatency.Snapshot()
+	}
+	return res
+}
@@ -0,0 +1,176 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/bitcoinrpcclient"
+	"github.com/0xb10c/bademeister-go/src/storage"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// MirrorRecovery describes the reconciliation of the mempool mirror with the node mempool
+type MirrorRecovery struct {
+	Time time.Time `json:"time"`
+	// Transactions in the mempool reconstructed from storage
+	FromStorage int `json:"fromStorage"`
+	// Transactions from storage that are not in the node mempool, e.g. replaced or evicted
+	// while the daemon was not running. They are removed from the mirror and marked as dropped
+	// in storage at Time, see Storage.MarkDropped.
+	NotInNode int `json:"notInNode"`
+	// Transactions in the node mempool that were not stored. They are stored and added to the mirror.
+	NotInStorage int `json:"notInStorage"`
+}
+
+// rebuildMirror replaces the mempool mirror with the current mempool reconstructed from storage
+func (b *BademeisterDaemon) rebuildMirror() error {
+	mempool, err := storage.NewMempoolAtTime(b.storage, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "could not reconstruct mempool")
+	}
+	b.mirror.Reset(mempool.Transactions())
+	log.Infof("Mempool mirror has %d transactions", b.mirror.Len())
+	return nil
+}
+
+// confirmInMirror removes the transactions of the new best block `block` from the mempool mirror.
+// With a finality depth above one, they are kept until the block has `depth` confirmations,
+// so that a reorg returns them without rebuilding the mirror.
+func (b *BademeisterDaemon) confirmInMirror(block *types.Block, depth int) {
+	if depth <= 1 {
+		b.mirror.Remove(block.TxIDs...)
+		return
+	}
+	b.mirror.Confirm(block.Hash, block.Height, block.TxIDs...)
+	if block.Height+1 >= uint32(depth) {
+		b.mirror.Finalize(block.Height + 1 - uint32(depth))
+	}
+}
+
+// reorgMirror applies the reorg from `lastBest` to the new best block `block` to the mempool mirror:
+// the transactions of the disconnected blocks return to the mempool and those of the connected blocks
+// are removed. Returns false if a disconnected block is already final, then the mirror must be rebuilt.
+func (b *BademeisterDaemon) reorgMirror(lastBest *types.StoredBlock, block *types.Block, depth int) (bool, error) {
+	if depth <= 1 {
+		return false, nil
+	}
+	newBest, err := b.storage.BlockByHash(block.Hash)
+	if err != nil || newBest == nil {
+		return false, err
+	}
+	ancestor, err := b.storage.CommonAncestor(newBest, lastBest)
+	if err != nil {
+		return false, err
+	}
+
+	disconnected := true
+	err = b.storage.WalkBlocks(lastBest, ancestor, func(stored *types.StoredBlock) error {
+		disconnected = disconnected && b.mirror.Disconnect(stored.Hash)
+		return nil
+	})
+	if err != nil || !disconnected {
+		return false, err
+	}
+
+	var connected []types.StoredBlock
+	err = b.storage.WalkBlocks(newBest, ancestor, func(stored *types.StoredBlock) error {
+		connected = append(connected, *stored)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for i := len(connected) - 1; i >= 0; i-- {
+		c := types.Block{Hash: connected[i].Hash, Height: connected[i].Height, TxIDs: block.TxIDs}
+		if c.Hash != block.Hash {
+			if c.TxIDs, err = b.storage.BlockTxIDs(connected[i].DBID); err != nil {
+				return false, err
+			}
+		}
+		b.confirmInMirror(&c, depth)
+	}
+	log.Infof("Applied reorg to %s to the mempool mirror: %d blocks disconnected", block.Hash, lastBest.Height-ancestor.Height)
+	return true, nil
+}
+
+// RecoverMirror rebuilds the mempool mirror from storage and, if an rpcClient is set,
+// reconciles it with the node mempool, so that the live view is correct after a restart.
+func (b *BademeisterDaemon) RecoverMirror() error {
+	if err := b.rebuildMirror(); err != nil {
+		return err
+	}
+
+	res := MirrorRecovery{
+		Time:        time.Now().UTC(),
+		FromStorage: b.mirror.Len(),
+	}
+	if b.rpcClient != nil {
+		if err := b.reconcileMirror(&res); err != nil {
+			return err
+		}
+		log.Infof(
+			"Reconciled mempool mirror: %d transactions from storage, %d not in node mempool, %d not stored",
+			res.FromStorage, res.NotInNode, res.NotInStorage,
+		)
+	}
+
+	b.mirrorRecoveryMu.Lock()
+	b.mirrorRecovery = &res
+	b.mirrorRecoveryMu.Unlock()
+	return nil
+}
+
+// reconcileMirror removes transactions that are not in the node mempool from the mirror, marks them
+// as dropped in storage and stores the node mempool transactions that are missing
+func (b *BademeisterDaemon) reconcileMirror(res *MirrorRecovery) error {
+	nodeMempool, err := b.rpcClient.GetRawMempoolVerbose()
+	if err != nil {
+		return errors.Wrap(err, "error getting raw mempool")
+	}
+	nodeTxs, err := bitcoinrpcclient.RawMempoolToTransactions(nodeMempool)
+	if err != nil {
+		return err
+	}
+
+	inNode := make(map[types.Hash32]bool, len(nodeTxs))
+	var missing []types.Transaction
+	for _, tx := range nodeTxs {
+		txid := tx.TxID
+		if b.hasher != nil {
+			txid = b.hasher.Hash(txid)
+		}
+		inNode[txid] = true
+		if _, ok := b.mirror.Get(txid); !ok {
+			missing = append(missing, tx)
+		}
+	}
+
+	var stale []types.Hash32
+	for _, tx := range b.mirror.Transactions() {
+		if !inNode[tx.TxID] {
+			stale = append(stale, tx.TxID)
+		}
+	}
+	b.mirror.Remove(stale...)
+	b.watched.forget(stale...)
+	res.NotInNode = len(stale)
+	// they left the node mempool while the daemon was not running
+	if err := b.storage.MarkDropped(stale, res.Time); err != nil {
+		return err
+	}
+
+	res.NotInStorage = len(missing)
+	if len(missing) == 0 {
+		return nil
+	}
+	return b.processTransactions(missing)
+}
+
+// LastMirrorRecovery returns the result of the last RecoverMirror call, nil if there was none
+func (b *BademeisterDaemon) LastMirrorRecovery() *MirrorRecovery {
+	b.mirrorRecoveryMu.Lock()
+	defer b.mirrorRecoveryMu.Unlock()
+	return b.mirrorRecovery
+}
@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/mirror"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestBademeisterDaemon_RecoverMirror(t *testing.T) {
+	st := newTestStorage(t, "daemon_mirror.db")
+	defer st.Close()
+
+	b := &BademeisterDaemon{storage: st, mirror: mirror.New()}
+	tx := func(id string) types.Transaction {
+		return types.Transaction{TxID: test.GenerateHash32(id), FirstSeen: time.Unix(100, 0), Fee: 1000, Weight: 400}
+	}
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("confirmed"), tx("unconfirmed")}))
+	assert.Equal(t, 2, b.mirror.Len())
+
+	block := types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: time.Unix(200, 0),
+		IsBest:    true,
+		TxIDs:     []types.Hash32{tx("confirmed").TxID},
+	}
+	require.NoError(t, b.processBlock(&block))
+	assert.Equal(t, 1, b.mirror.Len())
+
+	// a restarted daemon recovers the mirror from storage
+	restarted := &BademeisterDaemon{storage: st, mirror: mirror.New()}
+	require.NoError(t, restarted.RecoverMirror())
+	_, ok := restarted.mirror.Get(tx("unconfirmed").TxID)
+	assert.True(t, ok)
+	assert.Equal(t, 1, restarted.mirror.Len())
+	require.NotNil(t, restarted.LastMirrorRecovery())
+	assert.Equal(t, 1, restarted.LastMirrorRecovery().FromStorage)
+}
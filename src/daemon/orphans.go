@@ -0,0 +1,179 @@
+package daemon
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DefaultOrphanPoolSize is the default number of transactions in the orphan pool
+const DefaultOrphanPoolSize = 100
+
+// orphanExpiry is the time after which an unresolved orphan is dropped.
+// Parents that were confirmed before the daemon saw them never arrive.
+const orphanExpiry = 20 * time.Minute
+
+// orphanSweepInterval is the minimum interval between two sweeps of expired orphans
+const orphanSweepInterval = time.Minute
+
+// OrphanStats are counters of the orphan pool
+type OrphanStats struct {
+	// Transactions currently in the pool
+	Orphans uint64 `json:"orphans"`
+	// Orphans whose parents all arrived
+	Resolved uint64 `json:"resolved"`
+	// Orphans dropped after orphanExpiry
+	Expired uint64 `json:"expired"`
+	// Orphans dropped because the pool was full
+	Evicted uint64 `json:"evicted"`
+}
+
+// orphan is a transaction waiting for some of its parents
+type orphan struct {
+	txid      types.Hash32
+	firstSeen time.Time
+	// number of parents that were unknown when the orphan arrived
+	parents int
+	missing map[types.Hash32]bool
+	removed bool
+}
+
+// orphanPool keeps transactions that spend outputs of unknown parents until the parents arrive.
+// When the pool is full, the oldest orphan is evicted. Only accessed by Run.
+type orphanPool struct {
+	size     int
+	byTxID   map[types.Hash32]*orphan
+	byParent map[types.Hash32][]*orphan
+	// orphans in order of arrival, removed orphans are dropped when they reach the front
+	queue     []*orphan
+	lastSweep time.Time
+	// accessed atomically
+	stats *OrphanStats
+}
+
+func newOrphanPool(size int, stats *OrphanStats) *orphanPool {
+	return &orphanPool{
+		size:     size,
+		byTxID:   map[types.Hash32]*orphan{},
+		byParent: map[types.Hash32][]*orphan{},
+		stats:    stats,
+	}
+}
+
+// add adds `tx` to the pool, waiting for the unknown `parents`.
+// Does nothing if `tx` is in the pool already.
+func (p *orphanPool) add(tx *types.Transaction, parents []types.Hash32) {
+	if _, ok := p.byTxID[tx.TxID]; ok {
+		return
+	}
+	for len(p.byTxID) >= p.size && len(p.queue) > 0 {
+		oldest := p.queue[0]
+		p.queue = p.queue[1:]
+		if !oldest.removed {
+			p.remove(oldest)
+			atomic.AddUint64(&p.stats.Evicted, 1)
+		}
+	}
+
+	o := &orphan{
+		txid:      tx.TxID,
+		firstSeen: tx.FirstSeen,
+		parents:   len(parents),
+		missing:   map[types.Hash32]bool{},
+	}
+	for _, parent := range parents {
+		o.missing[parent] = true
+		p.byParent[parent] = append(p.byParent[parent], o)
+	}
+	p.byTxID[tx.TxID] = o
+	p.queue = append(p.queue, o)
+	atomic.StoreUint64(&p.stats.Orphans, uint64(len(p.byTxID)))
+}
+
+func (p *orphanPool) remove(o *orphan) {
+	o.removed = true
+	delete(p.byTxID, o.txid)
+	atomic.StoreUint64(&p.stats.Orphans, uint64(len(p.byTxID)))
+}
+
+// arrived marks `tx` as arrived for the orphans waiting for it and returns the resolutions
+// of the orphans whose parents have all arrived
+func (p *orphanPool) arrived(tx *types.Transaction) []types.OrphanResolution {
+	waiting, ok := p.byParent[tx.TxID]
+	if !ok {
+		return nil
+	}
+	delete(p.byParent, tx.TxID)
+
+	var resolutions []types.OrphanResolution
+	for _, o := range waiting {
+		if o.removed {
+			continue
+		}
+		delete(o.missing, tx.TxID)
+		if len(o.missing) > 0 {
+			continue
+		}
+		p.remove(o)
+		atomic.AddUint64(&p.stats.Resolved, 1)
+
+		// a parent from another source can have an earlier first-seen time
+		delay := tx.FirstSeen.Sub(o.firstSeen)
+		if delay < 0 {
+			delay = 0
+		}
+		resolutions = append(resolutions, types.OrphanResolution{
+			TxID:      o.txid,
+			FirstSeen: o.firstSeen,
+			Parents:   o.parents,
+			DelayMs:   int64(delay / time.Millisecond),
+		})
+	}
+	return resolutions
+}
+
+// sweep drops the orphans that are older than orphanExpiry at `now`.
+// Runs at most once per orphanSweepInterval.
+func (p *orphanPool) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < orphanSweepInterval {
+		return
+	}
+	p.lastSweep = now
+
+	for len(p.queue) > 0 {
+		o := p.queue[0]
+		if !o.removed && now.Sub(o.firstSeen) <= orphanExpiry {
+			break
+		}
+		p.queue = p.queue[1:]
+		if !o.removed {
+			p.remove(o)
+			atomic.AddUint64(&p.stats.Expired, 1)
+		}
+	}
+
+	// parents that only removed orphans wait for
+	for parent, waiting := range p.byParent {
+		live := false
+		for _, o := range waiting {
+			if !o.removed {
+				live = true
+				break
+			}
+		}
+		if !live {
+			delete(p.byParent, parent)
+		}
+	}
+}
+
+// OrphanStats returns the counters of the orphan pool
+func (b *BademeisterDaemon) OrphanStats() OrphanStats {
+	return OrphanStats{
+		Orphans:  atomic.LoadUint64(&b.orphanStats.Orphans),
+		Resolved: atomic.LoadUint64(&b.orphanStats.Resolved),
+		Expired:  atomic.LoadUint64(&b.orphanStats.Expired),
+		Evicted:  atomic.LoadUint64(&b.orphanStats.Evicted),
+	}
+}
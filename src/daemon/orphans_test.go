@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestOrphanPool(t *testing.T) {
+	var stats OrphanStats
+	p := newOrphanPool(2, &stats)
+	start := time.Unix(1000, 0)
+	tx := func(id string, offset time.Duration) *types.Transaction {
+		return &types.Transaction{TxID: test.GenerateHash32(id), FirstSeen: start.Add(offset)}
+	}
+	hash := test.GenerateHash32
+
+	p.add(tx("a", 0), []types.Hash32{hash("x"), hash("y")})
+	p.add(tx("a", 0), []types.Hash32{hash("x"), hash("y")})
+	p.add(tx("b", time.Second), []types.Hash32{hash("x")})
+	// evicts "a"
+	p.add(tx("c", 2*time.Second), []types.Hash32{hash("z")})
+
+	assert.Equal(t, []types.OrphanResolution{
+		{TxID: hash("b"), FirstSeen: start.Add(time.Second), Parents: 1, DelayMs: 2000},
+	}, p.arrived(tx("x", 3*time.Second)))
+	assert.Empty(t, p.arrived(tx("y", 3*time.Second)))
+
+	p.sweep(start.Add(time.Hour))
+	assert.Empty(t, p.byTxID)
+	assert.Empty(t, p.byParent)
+	assert.Empty(t, p.arrived(tx("z", time.Hour)))
+	assert.Equal(t, OrphanStats{Orphans: 0, Resolved: 1, Expired: 1, Evicted: 1}, stats)
+}
+
+func TestBademeisterDaemon_OrphanPool(t *testing.T) {
+	st := newTestStorage(t, "daemon_orphans.db")
+	defer st.Close()
+
+	b := &BademeisterDaemon{storage: st}
+	b.orphans = newOrphanPool(DefaultOrphanPoolSize, &b.orphanStats)
+	start := time.Unix(1000, 0).UTC()
+	tx := func(id string, offset time.Duration, parents ...string) types.Transaction {
+		details := &types.TxDetails{}
+		for _, p := range parents {
+			details.Inputs = append(details.Inputs, types.TxInput{PrevTxID: test.GenerateHash32(p)})
+		}
+		return types.Transaction{
+			TxID: test.GenerateHash32(id), FirstSeen: start.Add(offset), Weight: 400, Details: details,
+		}
+	}
+
+	// "c" spends the stored "a" and the unknown "x" and "y"
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("a", 0)}))
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("c", time.Second, "a", "x", "y")}))
+	assert.Equal(t, uint64(1), b.OrphanStats().Orphans)
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("x", 2*time.Second)}))
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("y", 4*time.Second)}))
+	assert.Equal(t, OrphanStats{Resolved: 1}, b.OrphanStats())
+
+	resolutions, err := st.OrphanResolutions(start, start.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OrphanResolution{
+		{TxID: test.GenerateHash32("c"), FirstSeen: start.Add(time.Second), Parents: 2, DelayMs: 3000},
+	}, resolutions)
+}
@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// waitingChild is a transaction that arrived before its parent
+type waitingChild struct {
+	txid      types.Hash32
+	firstSeen time.Time
+}
+
+// packageTracker detects child transactions that arrive before their unconfirmed parents.
+// A parent that is not stored when the child arrives is either unconfirmed and not received yet,
+// or confirmed before the daemon saw it. Children of the latter are dropped after `window`.
+type packageTracker struct {
+	window time.Duration
+	// children by the txid of the parent they wait for
+	waiting   map[types.Hash32][]waitingChild
+	lastSweep time.Time
+}
+
+func newPackageTracker(window time.Duration) *packageTracker {
+	return &packageTracker{
+		window:  window,
+		waiting: map[types.Hash32][]waitingChild{},
+	}
+}
+
+// arrived returns the events of the children waiting for `tx` and stops waiting for it
+func (p *packageTracker) arrived(tx *types.Transaction) []types.PackageEvent {
+	children, ok := p.waiting[tx.TxID]
+	if !ok {
+		return nil
+	}
+	delete(p.waiting, tx.TxID)
+
+	var events []types.PackageEvent
+	for _, child := range children {
+		delay := tx.FirstSeen.Sub(child.firstSeen)
+		if delay < 0 || delay > p.window {
+			continue
+		}
+		events = append(events, types.PackageEvent{
+			Child:           child.txid,
+			Parent:          tx.TxID,
+			ChildFirstSeen:  child.firstSeen,
+			ParentFirstSeen: tx.FirstSeen,
+			DelayMs:         int64(delay / time.Millisecond),
+		})
+	}
+	return events
+}
+
+// wait adds `child` to the children waiting for `parent`
+func (p *packageTracker) wait(child *types.Transaction, parent types.Hash32) {
+	p.waiting[parent] = append(p.waiting[parent], waitingChild{txid: child.TxID, firstSeen: child.FirstSeen})
+}
+
+// sweep drops the children that waited longer than the window at `now`.
+// Iterates over all children at most once per window.
+func (p *packageTracker) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.window {
+		return
+	}
+	p.lastSweep = now
+	for parent, children := range p.waiting {
+		kept := children[:0]
+		for _, child := range children {
+			if now.Sub(child.firstSeen) <= p.window {
+				kept = append(kept, child)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.waiting, parent)
+		} else {
+			p.waiting[parent] = kept
+		}
+	}
+}
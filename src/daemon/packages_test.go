@@ -0,0 +1,50 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestBademeisterDaemon_ObservePackages(t *testing.T) {
+	st := newTestStorage(t, "daemon_packages.db")
+	defer st.Close()
+
+	b := &BademeisterDaemon{storage: st, packages: newPackageTracker(10 * time.Second)}
+	start := time.Unix(1000, 0).UTC()
+	tx := func(id string, offset time.Duration, parents ...string) types.Transaction {
+		details := &types.TxDetails{}
+		for _, p := range parents {
+			details.Inputs = append(details.Inputs, types.TxInput{PrevTxID: test.GenerateHash32(p)})
+		}
+		return types.Transaction{
+			TxID: test.GenerateHash32(id), FirstSeen: start.Add(offset), Weight: 400, Details: details,
+		}
+	}
+
+	// "b" spends the stored "a" and "x", which arrives later
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("a", 0)}))
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("b", time.Second, "a", "x", "x")}))
+	// parents in the same batch are known
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("c", 2*time.Second, "d"), tx("d", 2*time.Second)}))
+	// "e" waits for "y" longer than the window
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("e", 2*time.Second, "y")}))
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("x", 3500*time.Millisecond)}))
+	require.NoError(t, b.processTransactions([]types.Transaction{tx("y", 20*time.Second)}))
+	assert.Empty(t, b.packages.waiting)
+
+	events, err := st.PackageEvents(start, start.Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []types.PackageEvent{{
+		Child:           test.GenerateHash32("b"),
+		Parent:          test.GenerateHash32("x"),
+		ChildFirstSeen:  start.Add(time.Second),
+		ParentFirstSeen: start.Add(3 * time.Second),
+		DelayMs:         2500,
+	}}, events)
+}
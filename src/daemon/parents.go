@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// trackParents passes `txs` to the package tracker and the orphan pool, if enabled,
+// and records the package events and orphan resolutions.
+// The parents of a transaction are taken from its inputs, so only transactions with details are checked.
+func (b *BademeisterDaemon) trackParents(txs []types.Transaction) error {
+	var events []types.PackageEvent
+	var resolutions []types.OrphanResolution
+	for i := range txs {
+		if b.packages != nil {
+			events = append(events, b.packages.arrived(&txs[i])...)
+		}
+		if b.orphans != nil {
+			resolutions = append(resolutions, b.orphans.arrived(&txs[i])...)
+		}
+	}
+
+	children, parents, err := b.unknownParents(txs)
+	if err != nil {
+		return err
+	}
+	for i, child := range children {
+		if b.packages != nil {
+			for _, parent := range parents[i] {
+				b.packages.wait(child, parent)
+			}
+		}
+		if b.orphans != nil {
+			b.orphans.add(child, parents[i])
+		}
+	}
+
+	if len(txs) > 0 {
+		now := txs[len(txs)-1].FirstSeen
+		if b.packages != nil {
+			b.packages.sweep(now)
+		}
+		if b.orphans != nil {
+			b.orphans.sweep(now)
+		}
+	}
+
+	if b.hasher != nil {
+		for i := range events {
+			events[i].Child = b.hasher.Hash(events[i].Child)
+			events[i].Parent = b.hasher.Hash(events[i].Parent)
+		}
+		for i := range resolutions {
+			resolutions[i].TxID = b.hasher.Hash(resolutions[i].TxID)
+		}
+	}
+	if len(events) > 0 {
+		log.Debugf("Recording %d package events", len(events))
+		err := retryStorageBusy(func() error {
+			return b.storage.InsertPackageEvents(events)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(resolutions) > 0 {
+		log.Debugf("Recording %d orphan resolutions", len(resolutions))
+		return retryStorageBusy(func() error {
+			return b.storage.InsertOrphanResolutions(resolutions)
+		})
+	}
+	return nil
+}
+
+// unknownParents returns the transactions in `txs` that spend outputs of transactions
+// that are neither stored nor in `txs`, and for each of them the txids of these parents
+func (b *BademeisterDaemon) unknownParents(txs []types.Transaction) ([]*types.Transaction, [][]types.Hash32, error) {
+	inBatch := map[types.Hash32]bool{}
+	for i := range txs {
+		inBatch[txs[i].TxID] = true
+	}
+
+	var children []*types.Transaction
+	var parents [][]types.Hash32
+	var lookup []types.Hash32
+	for i := range txs {
+		if txs[i].Details == nil {
+			continue
+		}
+		var missing []types.Hash32
+		seen := map[types.Hash32]bool{}
+		for _, in := range txs[i].Details.Inputs {
+			if !inBatch[in.PrevTxID] && !seen[in.PrevTxID] {
+				seen[in.PrevTxID] = true
+				missing = append(missing, in.PrevTxID)
+			}
+		}
+		if len(missing) > 0 {
+			children = append(children, &txs[i])
+			parents = append(parents, missing)
+			lookup = append(lookup, missing...)
+		}
+	}
+	if len(lookup) == 0 {
+		return nil, nil, nil
+	}
+
+	storedTxIDs := make([]types.Hash32, len(lookup))
+	for i, txid := range lookup {
+		storedTxIDs[i] = txid
+		if b.hasher != nil {
+			storedTxIDs[i] = b.hasher.Hash(txid)
+		}
+	}
+	var stored map[types.Hash32]bool
+	err := retryStorageBusy(func() (err error) {
+		stored, err = b.storage.StoredTxIDs(storedTxIDs)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resChildren []*types.Transaction
+	var resParents [][]types.Hash32
+	n := 0
+	for i, child := range children {
+		var unknown []types.Hash32
+		for _, parent := range parents[i] {
+			if !stored[storedTxIDs[n]] {
+				unknown = append(unknown, parent)
+			}
+			n++
+		}
+		if len(unknown) > 0 {
+			resChildren = append(resChildren, child)
+			resParents = append(resParents, unknown)
+		}
+	}
+	return resChildren, resParents, nil
+}
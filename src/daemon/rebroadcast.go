@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// broadcaster sends serialized transactions to the node.
+// Implemented by bitcoinrpcclient.BitcoinRPCClient.
+type broadcaster interface {
+	SendRawTransactionBytes(raw []byte) (string, error)
+}
+
+// DroppedTransactions returns up to `limit` transactions that expired without confirmation or
+// replacement and that can be rebroadcast, most recently expired first
+func (b *BademeisterDaemon) DroppedTransactions(limit int) ([]types.StoredTransaction, error) {
+	txIter, err := b.storage.DroppedTransactions(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := txIter.Collect()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []types.StoredTransaction{}
+	for _, tx := range dropped {
+		replacedBy, err := b.storage.ReplacedBy(tx.DBID)
+		if err != nil {
+			return nil, err
+		}
+		if replacedBy == nil {
+			res = append(res, tx)
+		}
+	}
+	return res, nil
+}
+
+// Rebroadcast sends the stored serialized transaction with `txid` to the node.
+// Fails if the transaction left the mempool in a block, was replaced or is not stored serialized,
+// see RunParams.StoreRaw.
+func (b *BademeisterDaemon) Rebroadcast(txid types.Hash32) error {
+	if b.broadcaster == nil {
+		return errors.New("no rpcClient")
+	}
+	if b.hasher != nil {
+		return errors.New("transactions are not stored serialized in privacy mode")
+	}
+
+	tx, err := b.storage.TransactionByID(txid)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return errors.Wrapf(types.ErrNotFound, "transaction %s", txid)
+	}
+	if tx.LastRemoved != nil {
+		return errors.Errorf("transaction %s left the mempool in a block", txid)
+	}
+	replacedBy, err := b.storage.ReplacedBy(tx.DBID)
+	if err != nil {
+		return err
+	}
+	if replacedBy != nil {
+		return errors.Errorf("transaction %s was replaced by %s", txid, replacedBy)
+	}
+	raw, err := b.storage.TransactionRaw(tx.DBID)
+	if err != nil {
+		return err
+	}
+	if raw == nil {
+		return errors.Errorf("transaction %s is not stored serialized", txid)
+	}
+
+	if _, err := b.broadcaster.SendRawTransactionBytes(raw); err != nil {
+		return errors.Wrapf(err, "could not rebroadcast transaction %s", txid)
+	}
+	log.Infof("Rebroadcast transaction %s", txid)
+	return nil
+}
+
+// rebroadcastWatched rebroadcasts the `expired` transactions with txids on the watch list
+// that have rebroadcasting enabled, and notifies the watch list entries
+func (b *BademeisterDaemon) rebroadcastWatched(expired []types.Hash32) {
+	b.configMu.RLock()
+	watch := b.watch
+	b.configMu.RUnlock()
+	if watch == nil || b.hasher != nil {
+		return
+	}
+
+	for _, txid := range expired {
+		targets := watch.byTxID[txid]
+		rebroadcast := false
+		for _, target := range targets {
+			rebroadcast = rebroadcast || target.rebroadcast
+		}
+		if !rebroadcast {
+			continue
+		}
+
+		text := fmt.Sprintf("transaction %s expired and was rebroadcast", txid)
+		if err := b.Rebroadcast(txid); err != nil {
+			log.Warnf("Could not rebroadcast watched transaction: %s", err)
+			text = fmt.Sprintf("transaction %s expired and could not be rebroadcast: %s", txid, err)
+		}
+		b.notify(targets, notify.EventRebroadcast, txid, text)
+	}
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// fakeBroadcaster records the transactions sent to the node
+type fakeBroadcaster struct {
+	sent [][]byte
+}
+
+func (f *fakeBroadcaster) SendRawTransactionBytes(raw []byte) (string, error) {
+	f.sent = append(f.sent, raw)
+	return "", nil
+}
+
+func TestBademeisterDaemon_Rebroadcast(t *testing.T) {
+	st := newTestStorage(t, "daemon_rebroadcast.db")
+	defer st.Close()
+
+	dropped := test.GenerateHash32("dropped")
+	sink := []notify.SinkConfig{{Type: notify.SinkWebhook, URL: "http://127.0.0.1:1/hook"}}
+	watch, err := newWatchList([]WatchEntry{
+		{Name: "rebroadcast", TxIDs: []string{dropped.String()}, Notify: sink, Rebroadcast: true},
+	})
+	require.NoError(t, err)
+
+	broadcaster := &fakeBroadcaster{}
+	b := &BademeisterDaemon{
+		storage:       st,
+		storeRaw:      true,
+		broadcaster:   broadcaster,
+		watch:         watch,
+		notifications: make(chan pendingNotification, 10),
+	}
+
+	// "replaced" and "replacement" spend the same output
+	firstSeen := time.Unix(1000, 0).UTC()
+	spending := func(prevIndex uint32) *types.TxDetails {
+		return &types.TxDetails{Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32("prev"), PrevIndex: prevIndex}}}
+	}
+	txs := []types.Transaction{
+		{TxID: dropped, FirstSeen: firstSeen, Details: spending(0), Raw: []byte{1}},
+		{TxID: test.GenerateHash32("replaced"), FirstSeen: firstSeen, Details: spending(1), Raw: []byte{2}},
+		{TxID: test.GenerateHash32("replacement"), FirstSeen: firstSeen.Add(time.Second), Details: spending(1)},
+		{TxID: test.GenerateHash32("no-raw"), FirstSeen: firstSeen},
+	}
+	b.storeDetails = true
+	require.NoError(t, b.processTransactions(txs))
+	// the mempool notification of the watched transaction
+	require.Len(t, b.notifications, 1)
+	<-b.notifications
+
+	var dbids []int64
+	for _, txid := range []types.Hash32{dropped, txs[1].TxID, txs[3].TxID} {
+		stored, err := st.TransactionByID(txid)
+		require.NoError(t, err)
+		dbids = append(dbids, stored.DBID)
+	}
+	require.NoError(t, st.MarkExpired(dbids, time.Hour))
+
+	res, err := b.DroppedTransactions(10)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, dropped, res[0].TxID)
+
+	assert.Error(t, b.Rebroadcast(txs[1].TxID))
+	assert.Error(t, b.Rebroadcast(txs[3].TxID))
+	assert.Error(t, b.Rebroadcast(test.GenerateHash32("unseen")))
+	assert.Empty(t, broadcaster.sent)
+
+	// watched transactions are rebroadcast when they expire
+	b.rebroadcastWatched([]types.Hash32{dropped, txs[3].TxID})
+	assert.Equal(t, [][]byte{{1}}, broadcaster.sent)
+	require.Len(t, b.notifications, 1)
+	n := <-b.notifications
+	assert.Equal(t, notify.EventRebroadcast, n.notification.Event)
+	assert.Contains(t, n.notification.Text, "was rebroadcast")
+}
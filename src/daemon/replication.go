@@ -0,0 +1,22 @@
+package daemon
+
+import (
+	"github.com/0xb10c/bademeister-go/src/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkpoint copies the WAL into the database file between two writes of Run,
+// so the database file reflects complete blocks and batches of transactions.
+// A failed or incomplete checkpoint is retried at the next interval.
+func (b *BademeisterDaemon) checkpoint() {
+	res, err := b.storage.Checkpoint(storage.CheckpointPassive)
+	if err != nil {
+		log.Errorf("Error in checkpoint(): %s", err)
+		return
+	}
+	if res.Busy || res.CheckpointedFrames < res.LogFrames {
+		log.Debugf("Incomplete checkpoint: %d of %d frames", res.CheckpointedFrames, res.LogFrames)
+		return
+	}
+	log.Debugf("Checkpoint: %d frames", res.CheckpointedFrames)
+}
@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestBademeisterDaemon_Sampling(t *testing.T) {
+	st := newTestStorage(t, "daemon_sampling.db")
+	defer st.Close()
+
+	b := &BademeisterDaemon{storage: st, sampleRate: 0.25}
+	var txs []types.Transaction
+	var txids, sampled []types.Hash32
+	for i := 0; i < 400; i++ {
+		tx := types.Transaction{TxID: test.GenerateHash32(fmt.Sprintf("tx-%d", i)), FirstSeen: time.Unix(100, 0), Weight: 400}
+		txs = append(txs, tx)
+		txids = append(txids, tx.TxID)
+		if types.Sampled(tx.TxID, 0.25) {
+			sampled = append(sampled, tx.TxID)
+		}
+	}
+	require.NoError(t, b.processTransactions(txs))
+
+	stored, err := st.StoredTxIDs(txids)
+	require.NoError(t, err)
+	assert.Len(t, stored, len(sampled))
+	assert.InDelta(t, 100, len(stored), 30)
+	for _, txid := range sampled {
+		assert.Contains(t, stored, txid)
+	}
+}
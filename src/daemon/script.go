@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/scripthook"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// disableScript stops the script hook after `err`, ingestion continues without it
+func (b *BademeisterDaemon) disableScript(err error) {
+	log.Errorf("Script hook failed, disabling it: %s", err)
+	if err := b.script.Close(); err != nil {
+		log.Errorf("Could not stop script hook: %s", err)
+	}
+	b.script = nil
+}
+
+// runScript passes `txs` to the script hook, adds its results and returns the transactions
+// it did not drop. Only called by Run.
+func (b *BademeisterDaemon) runScript(txs []types.Transaction) []types.Transaction {
+	verdicts, err := b.script.Transactions(txs)
+	if err != nil {
+		b.disableScript(err)
+		return txs
+	}
+	kept := txs[:0]
+	for i := range txs {
+		if verdicts[i].Drop {
+			continue
+		}
+		txs[i].Analysis = append(txs[i].Analysis, verdicts[i].Results()...)
+		kept = append(kept, txs[i])
+	}
+	return kept
+}
+
+// runScriptBlock passes `block` to the script hook and adds its results. Only called by Run.
+func (b *BademeisterDaemon) runScriptBlock(block *types.Block) {
+	verdict, err := b.script.Block(block)
+	if err != nil {
+		b.disableScript(err)
+		return
+	}
+	block.Analysis = append(block.Analysis, verdict.Results()...)
+}
+
+// startScript loads the script hook at `path` if set
+func (b *BademeisterDaemon) startScript(path string) error {
+	if path == "" {
+		return nil
+	}
+	hook, err := scripthook.Load(path, scripthook.DefaultTimeout)
+	if err != nil {
+		return err
+	}
+	log.Infof("Script hook: %s", path)
+	b.script = hook
+	return nil
+}
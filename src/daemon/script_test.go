@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/scripthook"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestRunScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "script")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// drops the transaction "a", tags the others and fails for weights above 1000
+	path := filepath.Join(dir, "hook.star")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+def transaction(tx):
+    if tx["weight"] > 1000:
+        fail("too heavy")
+    if tx["txid"] == "`+test.GenerateHash32("a").String()+`":
+        return {"drop": True}
+    return {"tags": ["x"]}
+`), 0600))
+	b := &BademeisterDaemon{}
+	require.NoError(t, b.startScript(path))
+
+	txs := b.runScript([]types.Transaction{
+		{TxID: test.GenerateHash32("a")},
+		{TxID: test.GenerateHash32("b")},
+	})
+	require.Len(t, txs, 1)
+	assert.Equal(t, test.GenerateHash32("b"), txs[0].TxID)
+	assert.Equal(t, []types.AnalysisResult{{Analyzer: scripthook.AnalyzerName, Key: "tag:x", Value: 1}}, txs[0].Analysis)
+
+	// the hook failed: disabled, transactions are kept
+	txs = b.runScript([]types.Transaction{{TxID: test.GenerateHash32("c"), Weight: 2000}})
+	assert.Len(t, txs, 1)
+	assert.Nil(t, b.script)
+}
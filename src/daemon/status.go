@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// setFeatures records the features enabled by `params` for Status
+func (b *BademeisterDaemon) setFeatures(params *RunParams) {
+	features := types.DaemonFeatures{
+		FullTransactions:   b.storeDetails && b.hasher == nil,
+		StoreRaw:           b.storeRaw && b.hasher == nil,
+		Heuristics:         b.classify,
+		Dust:               b.trackDust,
+		PrivacyMode:        b.hasher != nil,
+		Mirror:             b.mirror != nil,
+		DuplicateFilter:    b.dedup != nil,
+		PackageObservation: b.packages != nil,
+		OrphanPool:         b.orphans != nil,
+		HeaderFastPath:     params.HeaderFastPath,
+		Replication:        params.CheckpointInterval > 0,
+		Analyzers:          params.Analyzers,
+		ScriptHook:         b.script != nil,
+		SampleRate:         b.effectiveSampleRate(),
+	}
+	sources := []string{"zmq"}
+	if params.MempoolPollInterval > 0 {
+		sources = append(sources, "poll")
+	}
+	if b.rpcClient != nil {
+		sources = append(sources, "rpc")
+	}
+	if b.rest != nil {
+		sources = append(sources, "rest")
+	}
+
+	b.configMu.Lock()
+	defer b.configMu.Unlock()
+	b.features = &features
+	b.sources = sources
+}
+
+// effectiveSampleRate returns the fraction of the transactions that is stored
+func (b *BademeisterDaemon) effectiveSampleRate() float64 {
+	if b.sampleRate > 0 {
+		return b.sampleRate
+	}
+	return 1
+}
+
+// Status returns the enabled features and runtime state, see `/v1/status`
+func (b *BademeisterDaemon) Status() *types.DaemonStatus {
+	config := b.Config()
+	b.configMu.RLock()
+	features, sources := b.features, b.sources
+	b.configMu.RUnlock()
+
+	status := &types.DaemonStatus{
+		Started:       b.started.UTC(),
+		UptimeSeconds: int64(time.Since(b.started).Seconds()),
+		Paused:        b.Paused(),
+		Sources:       sources,
+		Retention: types.RetentionStatus{
+			Archive: config.RetentionWindow.Duration > 0 && config.ArchivePath != "",
+		},
+	}
+	if features != nil {
+		status.Features = *features
+	}
+	if config.RetentionWindow.Duration > 0 {
+		status.Retention.Window = config.RetentionWindow.String()
+	}
+	return status
+}
@@ -0,0 +1,214 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/objectstore"
+)
+
+// Object name prefixes of uploaded backups and archives
+const (
+	backupPrefix  = "backups/"
+	archivePrefix = "archives/"
+)
+
+// backupCheckInterval is the interval in which the backup loop checks if a backup is due
+const backupCheckInterval = 10 * time.Minute
+
+// UploadConfig configures the upload of backups and archives to object storage
+type UploadConfig struct {
+	Store objectstore.Config `json:"store"`
+	// Interval between two backups, zero disables backups
+	BackupInterval Duration `json:"backupInterval"`
+	// Number of backups to keep, zero keeps all
+	KeepBackups int `json:"keepBackups"`
+	// Backups older than this are deleted, zero keeps all
+	BackupMaxAge Duration `json:"backupMaxAge"`
+	// Upload the archive after each prune and delete the local file. Requires archivePath.
+	Archive bool `json:"archive"`
+	// Uploaded archives older than this are deleted, zero keeps all
+	ArchiveMaxAge Duration `json:"archiveMaxAge"`
+	// Directory for backups before the upload (default: the system temp dir)
+	TempDir string `json:"tempDir"`
+}
+
+// enabled returns true if backups or archives are uploaded
+func (c *UploadConfig) enabled() bool {
+	return c.BackupInterval.Duration > 0 || c.Archive
+}
+
+// Validate returns an error if a setting has an invalid value
+func (c *UploadConfig) Validate() error {
+	if c.BackupInterval.Duration < 0 || c.BackupMaxAge.Duration < 0 || c.ArchiveMaxAge.Duration < 0 {
+		return errors.New("invalid upload duration")
+	}
+	if c.KeepBackups < 0 {
+		return errors.Errorf("invalid upload keepBackups %d", c.KeepBackups)
+	}
+	if c.enabled() {
+		if _, err := objectstore.New(c.Store); err != nil {
+			return errors.Wrap(err, "invalid upload store")
+		}
+	}
+	return nil
+}
+
+func (b *BademeisterDaemon) backupLoop() {
+	for {
+		config := b.Config().Upload
+		if config.BackupInterval.Duration > 0 {
+			if err := b.backupIfDue(config); err != nil {
+				log.Errorf("Error in Backup(): %s", err)
+			}
+		}
+
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case <-time.After(backupCheckInterval):
+		}
+	}
+}
+
+// backupIfDue calls Backup if the last uploaded backup is older than the backup interval
+func (b *BademeisterDaemon) backupIfDue(config UploadConfig) error {
+	store, err := objectstore.New(config.Store)
+	if err != nil {
+		return err
+	}
+	backups, err := store.List(backupPrefix)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if time.Since(backup.Modified) < config.BackupInterval.Duration {
+			return nil
+		}
+	}
+
+	_, err = b.Backup(config)
+	return err
+}
+
+// Backup uploads a snapshot of the database to the store of `config` and applies the retention policy.
+// Returns the name of the uploaded object.
+func (b *BademeisterDaemon) Backup(config UploadConfig) (string, error) {
+	store, err := objectstore.New(config.Store)
+	if err != nil {
+		return "", err
+	}
+
+	dir := config.TempDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path, err := b.Snapshot(dir)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	name := backupPrefix + filepath.Base(path)
+	if err := uploadFile(store, name, path); err != nil {
+		return "", err
+	}
+	log.Infof("Uploaded backup %s", name)
+
+	deleted, err := applyRetention(store, backupPrefix, config.KeepBackups, config.BackupMaxAge.Duration, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d old backups", deleted)
+	}
+	return name, nil
+}
+
+// uploadArchive uploads the archive at `archivePath`, if it exists, to the store of `config`
+// and deletes the local file. The next prune starts a new archive.
+// The archive is renamed to `<archivePath>.upload` first, so no writer can use the uploaded file.
+// If an earlier upload failed, that file is uploaded and the archive stays for the next call.
+func uploadArchive(config UploadConfig, archivePath string) error {
+	pending := archivePath + ".upload"
+	if _, err := os.Stat(pending); os.IsNotExist(err) {
+		if err := os.Rename(archivePath, pending); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return errors.Wrap(err, "could not rename the archive for the upload")
+		}
+	}
+	store, err := objectstore.New(config.Store)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Base(archivePath)
+	name := fmt.Sprintf(
+		"%s%s-%d%s", archivePrefix, strings.TrimSuffix(base, filepath.Ext(base)), time.Now().Unix(), filepath.Ext(base),
+	)
+	if err := uploadFile(store, name, pending); err != nil {
+		return err
+	}
+	if err := os.Remove(pending); err != nil {
+		return errors.Wrap(err, "could not delete uploaded archive")
+	}
+	log.Infof("Uploaded archive %s", name)
+
+	deleted, err := applyRetention(store, archivePrefix, 0, config.ArchiveMaxAge.Duration, time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d old archives", deleted)
+	}
+	return nil
+}
+
+// uploadFile writes the file at `path` to the object `name`
+func uploadFile(store objectstore.Store, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.Wrapf(store.Put(name, f, info.Size()), "could not upload %s", path)
+}
+
+// applyRetention deletes the objects starting with `prefix` except the newest `keep` (zero keeps all),
+// and those modified before `now - maxAge` (zero keeps all). Returns the number of deleted objects.
+func applyRetention(store objectstore.Store, prefix string, keep int, maxAge time.Duration, now time.Time) (int, error) {
+	objects, err := store.List(prefix)
+	if err != nil {
+		return 0, err
+	}
+	// newest first
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].Modified.After(objects[j].Modified)
+	})
+
+	deleted := 0
+	for i, object := range objects {
+		if (keep > 0 && i >= keep) || (maxAge > 0 && object.Modified.Before(now.Add(-maxAge))) {
+			if err := store.Delete(object.Name); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/objectstore"
+	"github.com/0xb10c/bademeister-go/src/storage"
+)
+
+func objectNames(t *testing.T, store objectstore.Store, prefix string) (res []string) {
+	objects, err := store.List(prefix)
+	require.NoError(t, err)
+	for _, o := range objects {
+		res = append(res, o.Name)
+	}
+	return res
+}
+
+func TestApplyRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retention")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := &objectstore.Dir{Path: dir}
+	now := time.Now()
+	for i, name := range []string{"b/3", "b/1", "b/2", "other"} {
+		require.NoError(t, store.Put(name, strings.NewReader(""), 0))
+		modified := now.Add(-time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), modified, modified))
+	}
+
+	// b/3 is the newest, b/2 the oldest
+	deleted, err := applyRetention(store, "b/", 0, 90*time.Minute, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"b/1", "b/3"}, objectNames(t, store, "b/"))
+
+	deleted, err = applyRetention(store, "b/", 1, 0, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"b/3", "other"}, objectNames(t, store, ""))
+}
+
+func TestBademeisterDaemon_Backup(t *testing.T) {
+	st := newTestStorage(t, "daemon_backup.db")
+	defer st.Close()
+
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config := UploadConfig{
+		Store:          objectstore.Config{Type: objectstore.TypeDir, Dir: filepath.Join(dir, "store")},
+		BackupInterval: Duration{time.Hour},
+		KeepBackups:    1,
+		TempDir:        dir,
+	}
+	require.NoError(t, config.Validate())
+
+	b := &BademeisterDaemon{storage: st}
+	name, err := b.Backup(config)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, backupPrefix))
+
+	// the recent backup is not repeated
+	store, err := objectstore.New(config.Store)
+	require.NoError(t, err)
+	require.NoError(t, b.backupIfDue(config))
+	assert.Equal(t, []string{name}, objectNames(t, store, backupPrefix))
+
+	// the snapshot is uploaded and removed locally
+	backup, err := storage.NewStorage(filepath.Join(dir, "store", filepath.FromSlash(name)))
+	require.NoError(t, err)
+	require.NoError(t, backup.Close())
+	files, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// archives are uploaded and deleted locally
+	archive := filepath.Join(dir, "archive.db")
+	require.NoError(t, ioutil.WriteFile(archive, []byte("archive"), 0600))
+	config.Archive = true
+	require.NoError(t, uploadArchive(config, archive))
+	_, err = os.Stat(archive)
+	assert.True(t, os.IsNotExist(err))
+	assert.Len(t, objectNames(t, store, archivePrefix), 1)
+	require.NoError(t, uploadArchive(config, archive))
+
+	// an archive that failed to upload is uploaded before the next one
+	broken := config
+	broken.Store.Dir = filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(broken.Store.Dir, nil, 0600))
+	require.NoError(t, ioutil.WriteFile(archive, []byte("first"), 0600))
+	require.Error(t, uploadArchive(broken, archive))
+	require.NoError(t, ioutil.WriteFile(archive, []byte("second"), 0600))
+	require.NoError(t, uploadArchive(config, archive))
+	_, err = os.Stat(archive + ".upload")
+	assert.True(t, os.IsNotExist(err))
+	data, err := ioutil.ReadFile(archive)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+}
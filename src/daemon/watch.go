@@ -0,0 +1,315 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// notificationQueueSize is the number of notifications waiting for delivery.
+// Further notifications are dropped, so that slow sinks do not block processing.
+const notificationQueueSize = 1000
+
+// maxWatchedTxs limits the number of transactions matched by address that are tracked until confirmation
+const maxWatchedTxs = 10000
+
+// addressParams are tried in order to decode watched addresses
+var addressParams = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNet3Params,
+	&chaincfg.RegressionNetParams,
+}
+
+// watchTarget is a compiled WatchEntry
+type watchTarget struct {
+	name        string
+	notifiers   []notify.Notifier
+	rebroadcast bool
+}
+
+// watchList is the compiled form of Config.Watch
+type watchList struct {
+	byTxID   map[types.Hash32][]*watchTarget
+	byScript map[string][]*watchTarget
+}
+
+// outpoint is an output spent by a watched transaction
+type outpoint struct {
+	txid  types.Hash32
+	index uint32
+}
+
+// watchedTx is a transaction tracked by watchedTxs
+type watchedTx struct {
+	targets []*watchTarget
+	spends  []outpoint
+}
+
+// watchedTxs are the transactions matched by address or as whales, which are notified again when
+// confirmed. They are tracked until they are confirmed, replaced or leave the mempool otherwise.
+// The zero value is empty. Safe for concurrent use.
+type watchedTxs struct {
+	mu  sync.Mutex
+	txs map[types.Hash32]*watchedTx
+	// the watched transaction spending an output, to detect replacements
+	spenders map[outpoint]types.Hash32
+}
+
+// add tracks `tx` for `targets`, unless maxWatchedTxs transactions are tracked already
+func (w *watchedTxs) add(tx *types.Transaction, targets ...*watchTarget) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.txs == nil {
+		w.txs = map[types.Hash32]*watchedTx{}
+		w.spenders = map[outpoint]types.Hash32{}
+	}
+
+	if watched, ok := w.txs[tx.TxID]; ok {
+		watched.targets = append(watched.targets, targets...)
+		return
+	}
+	if len(w.txs) >= maxWatchedTxs {
+		return
+	}
+	watched := &watchedTx{targets: targets}
+	if tx.Details != nil {
+		for _, in := range tx.Details.Inputs {
+			spent := outpoint{txid: in.PrevTxID, index: in.PrevIndex}
+			watched.spends = append(watched.spends, spent)
+			w.spenders[spent] = tx.TxID
+		}
+	}
+	w.txs[tx.TxID] = watched
+}
+
+// remove stops tracking the transaction `txid` and returns its targets, nil if it is not tracked
+func (w *watchedTxs) remove(txid types.Hash32) []*watchTarget {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.removeLocked(txid)
+}
+
+func (w *watchedTxs) removeLocked(txid types.Hash32) []*watchTarget {
+	watched, ok := w.txs[txid]
+	if !ok {
+		return nil
+	}
+	for _, spent := range watched.spends {
+		if w.spenders[spent] == txid {
+			delete(w.spenders, spent)
+		}
+	}
+	delete(w.txs, txid)
+	return watched.targets
+}
+
+// forget stops tracking the transactions `txids`, e.g. because they left the mempool
+func (w *watchedTxs) forget(txids ...types.Hash32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, txid := range txids {
+		w.removeLocked(txid)
+	}
+}
+
+// replace stops tracking the transactions that spend an output also spent by one of `txs`,
+// i.e. that were replaced. Requires the details of `txs`.
+func (w *watchedTxs) replace(txs []types.Transaction) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.txs) == 0 {
+		return
+	}
+	for i := range txs {
+		if txs[i].Details == nil {
+			continue
+		}
+		for _, in := range txs[i].Details.Inputs {
+			spender, ok := w.spenders[outpoint{txid: in.PrevTxID, index: in.PrevIndex}]
+			if ok && spender != txs[i].TxID {
+				w.removeLocked(spender)
+			}
+		}
+	}
+}
+
+// len returns the number of tracked transactions
+func (w *watchedTxs) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.txs)
+}
+
+// pendingNotification is a notification with the sinks it is delivered to
+type pendingNotification struct {
+	notification notify.Notification
+	notifiers    []notify.Notifier
+}
+
+// newWatchList validates and compiles the watch list entries
+func newWatchList(entries []WatchEntry) (*watchList, error) {
+	res := &watchList{
+		byTxID:   map[types.Hash32][]*watchTarget{},
+		byScript: map[string][]*watchTarget{},
+	}
+
+	for _, entry := range entries {
+		target := &watchTarget{name: entry.Name, rebroadcast: entry.Rebroadcast}
+		for _, sink := range entry.Notify {
+			notifier, err := notify.New(sink)
+			if err != nil {
+				return nil, errors.Wrapf(err, "watch %q", entry.Name)
+			}
+			target.notifiers = append(target.notifiers, notifier)
+		}
+		if len(target.notifiers) == 0 {
+			return nil, errors.Errorf("watch %q: no notify sinks", entry.Name)
+		}
+
+		for _, v := range entry.TxIDs {
+			txid, err := types.NewHashFromString(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "watch %q", entry.Name)
+			}
+			res.byTxID[txid] = append(res.byTxID[txid], target)
+		}
+
+		for _, v := range entry.Addresses {
+			script, err := addressScript(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "watch %q", entry.Name)
+			}
+			res.byScript[string(script)] = append(res.byScript[string(script)], target)
+		}
+	}
+
+	return res, nil
+}
+
+// addressScript returns the output script paying to the address `v` of any supported network
+func addressScript(v string) ([]byte, error) {
+	for _, params := range addressParams {
+		addr, err := btcutil.DecodeAddress(v, params)
+		if err != nil || !addr.IsForNet(params) {
+			continue
+		}
+		return txscript.PayToAddrScript(addr)
+	}
+	return nil, errors.Errorf("invalid address %q", v)
+}
+
+// matchTransaction returns the targets watching the txid of `tx` or an address it pays to.
+// Addresses can only be matched if the outputs of the transaction are known.
+func (w *watchList) matchTransaction(tx *types.Transaction) (res []*watchTarget) {
+	res = append(res, w.byTxID[tx.TxID]...)
+	if tx.Details == nil || len(w.byScript) == 0 {
+		return res
+	}
+	for _, out := range tx.Details.Outputs {
+		res = append(res, w.byScript[string(out.Script)]...)
+	}
+	return res
+}
+
+// notify queues notifications for `targets` without blocking
+func (b *BademeisterDaemon) notify(targets []*watchTarget, event string, txid types.Hash32, text string) {
+	seen := map[*watchTarget]bool{}
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		n := pendingNotification{
+			notification: notify.Notification{
+				Time:  time.Now().UTC(),
+				Watch: target.name,
+				Event: event,
+				TxID:  txid.String(),
+				Text:  fmt.Sprintf("[%s] %s", target.name, text),
+			},
+			notifiers: target.notifiers,
+		}
+		select {
+		case b.notifications <- n:
+		default:
+			log.Warnf("Notification queue full, dropping notification for watch %q", target.name)
+		}
+	}
+}
+
+// watchTransactions sends notifications for watched transactions entering the mempool
+// and remembers them for the confirmation notification. Only called by Run.
+func (b *BademeisterDaemon) watchTransactions(txs []types.Transaction) {
+	b.watched.replace(txs)
+
+	b.configMu.RLock()
+	watch := b.watch
+	b.configMu.RUnlock()
+	if watch == nil {
+		return
+	}
+
+	for i := range txs {
+		tx := &txs[i]
+		targets := watch.matchTransaction(tx)
+		if len(targets) == 0 {
+			continue
+		}
+		if _, ok := watch.byTxID[tx.TxID]; !ok {
+			b.watched.add(tx, targets...)
+		}
+		b.notify(targets, notify.EventMempool, tx.TxID, fmt.Sprintf(
+			"transaction %s entered the mempool with %.1f sat/vB", tx.TxID, tx.Feerate(),
+		))
+	}
+}
+
+// watchBlock sends notifications for watched transactions in `block`. Only called by Run.
+func (b *BademeisterDaemon) watchBlock(block *types.Block) {
+	b.configMu.RLock()
+	watch := b.watch
+	b.configMu.RUnlock()
+	if watch == nil {
+		return
+	}
+
+	for _, txid := range block.TxIDs {
+		targets := watch.byTxID[txid]
+		if watched := b.watched.remove(txid); watched != nil {
+			targets = append(targets, watched...)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		b.notify(targets, notify.EventConfirmed, txid, fmt.Sprintf(
+			"transaction %s confirmed in block %s at height %d", txid, block.Hash, block.Height,
+		))
+	}
+}
+
+// notifyLoop delivers the queued notifications
+func (b *BademeisterDaemon) notifyLoop() {
+	for {
+		select {
+		case <-b.quit:
+			b.quit <- struct{}{}
+			return
+		case n := <-b.notifications:
+			for _, notifier := range n.notifiers {
+				if err := notifier.Notify(&n.notification); err != nil {
+					log.Errorf("Error sending notification for watch %q: %s", n.notification.Watch, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestWatchList(t *testing.T) {
+	addr, err := btcutil.NewAddressPubKeyHash(make([]byte, 20), &chaincfg.RegressionNetParams)
+	require.NoError(t, err)
+	script, err := txscript.PayToAddrScript(addr)
+	require.NoError(t, err)
+
+	watchedTx := test.GenerateHash32("watched")
+	sink := []notify.SinkConfig{{Type: notify.SinkWebhook, URL: "http://127.0.0.1:1/hook"}}
+	watch, err := newWatchList([]WatchEntry{
+		{Name: "tx", TxIDs: []string{watchedTx.String()}, Notify: sink},
+		{Name: "address", Addresses: []string{addr.EncodeAddress()}, Notify: sink},
+	})
+	require.NoError(t, err)
+
+	b := &BademeisterDaemon{
+		watch:         watch,
+		notifications: make(chan pendingNotification, 10),
+	}
+
+	paying := types.Transaction{
+		TxID:    test.GenerateHash32("paying"),
+		Fee:     1000,
+		Weight:  800,
+		Details: &types.TxDetails{Outputs: []types.TxOutput{{Value: 1000, Script: script}}},
+	}
+	b.watchTransactions([]types.Transaction{
+		{TxID: watchedTx},
+		paying,
+		{TxID: test.GenerateHash32("other"), Details: &types.TxDetails{}},
+	})
+	require.Len(t, b.notifications, 2)
+	n := <-b.notifications
+	assert.Equal(t, "tx", n.notification.Watch)
+	assert.Equal(t, notify.EventMempool, n.notification.Event)
+	assert.Equal(t, watchedTx.String(), n.notification.TxID)
+	n = <-b.notifications
+	assert.Equal(t, "address", n.notification.Watch)
+	assert.Contains(t, n.notification.Text, "5.0 sat/vB")
+
+	// both are confirmed, the transaction matched by address is only tracked until then
+	b.watchBlock(&types.Block{Height: 7, TxIDs: []types.Hash32{paying.TxID, watchedTx}})
+	require.Len(t, b.notifications, 2)
+	n = <-b.notifications
+	assert.Equal(t, "address", n.notification.Watch)
+	assert.Equal(t, notify.EventConfirmed, n.notification.Event)
+	<-b.notifications
+	assert.Equal(t, 0, b.watched.len())
+
+	// transactions matched by address are not tracked anymore once replaced or dropped
+	spent := types.TxInput{PrevTxID: test.GenerateHash32("funding")}
+	replaced := paying
+	replaced.TxID = test.GenerateHash32("replaced")
+	replaced.Details = &types.TxDetails{Inputs: []types.TxInput{spent}, Outputs: paying.Details.Outputs}
+	dropped := paying
+	dropped.TxID = test.GenerateHash32("dropped")
+	b.watchTransactions([]types.Transaction{replaced, dropped})
+	require.Len(t, b.notifications, 2)
+	<-b.notifications
+	<-b.notifications
+	assert.Equal(t, 2, b.watched.len())
+	b.watchTransactions([]types.Transaction{{
+		TxID:    test.GenerateHash32("replacement"),
+		Details: &types.TxDetails{Inputs: []types.TxInput{spent}},
+	}})
+	assert.Equal(t, 1, b.watched.len())
+	b.watched.forget(dropped.TxID)
+	assert.Equal(t, 0, b.watched.len())
+
+	_, err = newWatchList([]WatchEntry{{Name: "x", Addresses: []string{"invalid"}, Notify: sink}})
+	assert.Error(t, err)
+	_, err = newWatchList([]WatchEntry{{Name: "x", TxIDs: []string{watchedTx.String()}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// whaleWatchName is the name of the notifications about whale transactions
+const whaleWatchName = "whales"
+
+// newWhaleTarget validates `config` and returns the target of its notifications, nil if it has no sinks
+func newWhaleTarget(config WhaleConfig) (*watchTarget, error) {
+	if config.MinValue < 0 || config.MinValue > btcutil.MaxSatoshi/btcutil.SatoshiPerBitcoin {
+		return nil, errors.Errorf("invalid whales minValue %f", config.MinValue)
+	}
+	if len(config.Notify) == 0 {
+		return nil, nil
+	}
+	if config.MinValue == 0 {
+		return nil, errors.New("whales: notify requires minValue")
+	}
+
+	target := &watchTarget{name: whaleWatchName}
+	for _, sink := range config.Notify {
+		notifier, err := notify.New(sink)
+		if err != nil {
+			return nil, errors.Wrap(err, "whales")
+		}
+		target.notifiers = append(target.notifiers, notifier)
+	}
+	return target, nil
+}
+
+// minValueSatoshis returns the whale threshold in satoshis, 0 if disabled
+func (c *WhaleConfig) minValueSatoshis() int64 {
+	if c.MinValue == 0 {
+		return 0
+	}
+	amount, _ := btcutil.NewAmount(c.MinValue)
+	return int64(amount)
+}
+
+// detectWhale sets the WhaleValue of `tx` if its outputs are worth at least the threshold of `config`
+// and sends the notifications. Requires the outputs of `tx`. Only called by Run.
+func (b *BademeisterDaemon) detectWhale(tx *types.Transaction, config *WhaleConfig) {
+	minValue := config.minValueSatoshis()
+	if minValue == 0 || tx.Details == nil {
+		return
+	}
+	value := tx.Details.OutputValue()
+	if value < minValue {
+		return
+	}
+	tx.WhaleValue = value
+
+	b.configMu.RLock()
+	target := b.whales
+	b.configMu.RUnlock()
+	if target == nil {
+		return
+	}
+	b.watched.add(tx, target)
+	b.notify([]*watchTarget{target}, notify.EventMempool, tx.TxID, fmt.Sprintf(
+		"transaction %s moving %s entered the mempool with %.1f sat/vB",
+		tx.TxID, btcutil.Amount(value), tx.Feerate(),
+	))
+}
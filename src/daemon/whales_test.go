@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/notify"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestDetectWhale(t *testing.T) {
+	config := WhaleConfig{
+		MinValue: 100,
+		Notify:   []notify.SinkConfig{{Type: notify.SinkWebhook, URL: "http://127.0.0.1:1/hook"}},
+	}
+	target, err := newWhaleTarget(config)
+	require.NoError(t, err)
+
+	b := &BademeisterDaemon{
+		watch:         &watchList{},
+		whales:        target,
+		notifications: make(chan pendingNotification, 10),
+	}
+
+	whale := types.Transaction{
+		TxID:   test.GenerateHash32("whale"),
+		Fee:    1000,
+		Weight: 800,
+		Details: &types.TxDetails{Outputs: []types.TxOutput{
+			{Value: 60e8}, {Value: 40e8},
+		}},
+	}
+	b.detectWhale(&whale, &config)
+	assert.Equal(t, int64(100e8), whale.WhaleValue)
+
+	small := types.Transaction{
+		TxID:    test.GenerateHash32("small"),
+		Details: &types.TxDetails{Outputs: []types.TxOutput{{Value: 99e8}}},
+	}
+	b.detectWhale(&small, &config)
+	assert.Zero(t, small.WhaleValue)
+
+	require.Len(t, b.notifications, 1)
+	n := <-b.notifications
+	assert.Equal(t, whaleWatchName, n.notification.Watch)
+	assert.Equal(t, notify.EventMempool, n.notification.Event)
+	assert.Contains(t, n.notification.Text, "100 BTC")
+
+	b.watchBlock(&types.Block{Height: 7, TxIDs: []types.Hash32{whale.TxID}})
+	require.Len(t, b.notifications, 1)
+	n = <-b.notifications
+	assert.Equal(t, notify.EventConfirmed, n.notification.Event)
+
+	// flagged without notifications
+	b.whales = nil
+	whale.WhaleValue = 0
+	b.detectWhale(&whale, &config)
+	assert.Equal(t, int64(100e8), whale.WhaleValue)
+	assert.Len(t, b.notifications, 0)
+
+	_, err = newWhaleTarget(WhaleConfig{MinValue: -1})
+	assert.Error(t, err)
+	_, err = newWhaleTarget(WhaleConfig{Notify: config.Notify})
+	assert.Error(t, err)
+}
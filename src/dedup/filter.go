@@ -0,0 +1,113 @@
+// Package dedup implements a filter of recently seen txids,
+// used to skip database writes for duplicate transaction messages.
+package dedup
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// bloom is a Bloom filter for hashes
+type bloom struct {
+	bits    []uint64
+	m       uint64
+	k       uint64
+	entries int
+}
+
+func newBloom(capacity int, fpRate float64) *bloom {
+	// optimal size and number of hash functions for `capacity` entries at false-positive rate `fpRate`
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloom{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// positions calls `f` with the bit positions of `h`.
+// Hashes are uniformly distributed already, so two parts of the hash are combined
+// to k positions by double hashing instead of rehashing.
+func (b *bloom) positions(h types.Hash32, f func(pos uint64) bool) {
+	h1 := binary.LittleEndian.Uint64(h[0:8])
+	h2 := binary.LittleEndian.Uint64(h[8:16]) | 1
+	for i := uint64(0); i < b.k; i++ {
+		if !f((h1 + i*h2) % b.m) {
+			return
+		}
+	}
+}
+
+func (b *bloom) add(h types.Hash32) {
+	b.positions(h, func(pos uint64) bool {
+		b.bits[pos/64] |= 1 << (pos % 64)
+		return true
+	})
+	b.entries++
+}
+
+func (b *bloom) test(h types.Hash32) bool {
+	res := true
+	b.positions(h, func(pos uint64) bool {
+		res = b.bits[pos/64]&(1<<(pos%64)) != 0
+		return res
+	})
+	return res
+}
+
+// Filter remembers recently added hashes.
+// Test never returns false for a hash added within the last `capacity` additions and returns
+// true for other hashes with a probability of about the false-positive rate (up to twice that
+// while two generations are active).
+//
+// The filter has two generations of Bloom filters. When the current generation is full,
+// it becomes the previous generation and the old previous generation is discarded,
+// which bounds the memory usage and the false-positive rate.
+// Filter is not safe for concurrent use.
+type Filter struct {
+	capacity int
+	fpRate   float64
+	current  *bloom
+	previous *bloom
+}
+
+// NewFilter returns a Filter for `capacity` hashes per generation at false-positive rate `fpRate`
+func NewFilter(capacity int, fpRate float64) *Filter {
+	return &Filter{
+		capacity: capacity,
+		fpRate:   fpRate,
+		current:  newBloom(capacity, fpRate),
+	}
+}
+
+// Add adds `h` to the filter
+func (f *Filter) Add(h types.Hash32) {
+	if f.current.entries >= f.capacity {
+		f.previous = f.current
+		f.current = newBloom(f.capacity, f.fpRate)
+	}
+	f.current.add(h)
+}
+
+// Test returns true if `h` was probably added before and false if it was not added recently
+func (f *Filter) Test(h types.Hash32) bool {
+	return f.current.test(h) || (f.previous != nil && f.previous.test(h))
+}
+
+// Size returns the memory used by the filter in bytes
+func (f *Filter) Size() int {
+	size := len(f.current.bits) * 8
+	if f.previous != nil {
+		size += len(f.previous.bits) * 8
+	}
+	return size
+}
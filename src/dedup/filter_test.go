@@ -0,0 +1,46 @@
+package dedup
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestFilter(t *testing.T) {
+	const capacity = 1000
+	f := NewFilter(capacity, 0.01)
+
+	for i := 0; i < capacity; i++ {
+		f.Add(test.GenerateHash32(fmt.Sprintf("added-%d", i)))
+	}
+	for i := 0; i < capacity; i++ {
+		assert.True(t, f.Test(test.GenerateHash32(fmt.Sprintf("added-%d", i))))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.Test(test.GenerateHash32(fmt.Sprintf("other-%d", i))) {
+			falsePositives++
+		}
+	}
+	assert.InDelta(t, 100, falsePositives, 60)
+
+	// the first generation is kept while the second fills up, then discarded
+	for i := 0; i < capacity; i++ {
+		f.Add(test.GenerateHash32(fmt.Sprintf("second-%d", i)))
+	}
+	assert.True(t, f.Test(test.GenerateHash32("added-0")))
+	f.Add(test.GenerateHash32("third-0"))
+	assert.True(t, f.Test(test.GenerateHash32("second-0")))
+
+	discarded := 0
+	for i := 0; i < capacity; i++ {
+		if !f.Test(test.GenerateHash32(fmt.Sprintf("added-%d", i))) {
+			discarded++
+		}
+	}
+	assert.True(t, discarded > capacity*9/10)
+}
@@ -0,0 +1,113 @@
+// Package exporter writes stored transactions as datasets for other tools.
+//
+// Exports of long time ranges have tens of millions of rows, so the writers encode into a reused
+// buffer instead of allocating strings per field, and the rows are read with a single cursor
+// (see storage.TransactionQuery) instead of one query per page.
+package exporter
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// CSVHeader is the first line written by CSVWriter
+const CSVHeader = "txid,first_seen,last_removed,expired,fee,weight\n"
+
+// CSVWriter writes transactions as CSV with the columns of CSVHeader. Times are unix seconds,
+// the times of transactions that were not removed or did not expire are empty.
+// Txids have the format of types.Hash32.String.
+type CSVWriter struct {
+	w    *bufio.Writer
+	line []byte
+	// number of written transactions
+	count int64
+}
+
+// NewCSVWriter returns a CSVWriter that writes the header and the transactions to `w`.
+// Call Flush after the last transaction.
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	res := &CSVWriter{w: bufio.NewWriterSize(w, 1<<16), line: make([]byte, 0, 256)}
+	if _, err := res.w.WriteString(CSVHeader); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Write writes `tx` as one line
+func (c *CSVWriter) Write(tx *types.StoredTransaction) error {
+	line := c.line[:0]
+	line = appendHex(line, tx.TxID)
+	line = append(line, ',')
+	line = strconv.AppendInt(line, tx.FirstSeen.Unix(), 10)
+	line = append(line, ',')
+	line = appendOptionalTime(line, tx.LastRemoved)
+	line = append(line, ',')
+	line = appendOptionalTime(line, tx.Expired)
+	line = append(line, ',')
+	line = strconv.AppendUint(line, tx.Fee, 10)
+	line = append(line, ',')
+	line = strconv.AppendInt(line, int64(tx.Weight), 10)
+	line = append(line, '\n')
+	c.line = line
+
+	if _, err := c.w.Write(line); err != nil {
+		return err
+	}
+	c.count++
+	return nil
+}
+
+// Transactions is a sequence of transactions, e.g. a *storage.TxIterator
+type Transactions interface {
+	// Next returns the next transaction, nil after the last one or an error
+	Next() *types.StoredTransaction
+	// Err returns the error that ended the sequence, if any
+	Err() error
+}
+
+// WriteCSV writes `txs` to `w` with a CSVWriter and returns the number of written transactions
+func WriteCSV(w io.Writer, txs Transactions) (int64, error) {
+	c, err := NewCSVWriter(w)
+	if err != nil {
+		return 0, err
+	}
+	for tx := txs.Next(); tx != nil; tx = txs.Next() {
+		if err := c.Write(tx); err != nil {
+			return c.count, err
+		}
+	}
+	if err := txs.Err(); err != nil {
+		return c.count, err
+	}
+	return c.count, c.Flush()
+}
+
+// Count returns the number of written transactions
+func (c *CSVWriter) Count() int64 {
+	return c.count
+}
+
+// Flush writes buffered lines to the underlying writer
+func (c *CSVWriter) Flush() error {
+	return c.w.Flush()
+}
+
+// appendHex appends `h` as hex in the RPC byte order, like types.Hash32.String()
+func appendHex(dst []byte, h types.Hash32) []byte {
+	var encoded [2 * len(h)]byte
+	r := h.Reversed()
+	hex.Encode(encoded[:], r[:])
+	return append(dst, encoded[:]...)
+}
+
+func appendOptionalTime(dst []byte, t *time.Time) []byte {
+	if t == nil {
+		return dst
+	}
+	return strconv.AppendInt(dst, t.Unix(), 10)
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// sliceTransactions implements Transactions
+type sliceTransactions struct {
+	txs []types.StoredTransaction
+	err error
+}
+
+func (s *sliceTransactions) Next() *types.StoredTransaction {
+	if len(s.txs) == 0 {
+		return nil
+	}
+	tx := &s.txs[0]
+	s.txs = s.txs[1:]
+	return tx
+}
+
+func (s *sliceTransactions) Err() error {
+	return s.err
+}
+
+func newTestTransactions(n int) []types.StoredTransaction {
+	res := make([]types.StoredTransaction, n)
+	for i := range res {
+		res[i].DBID = int64(i + 1)
+		res[i].TxID = test.GenerateHash32(fmt.Sprintf("tx-%d", i))
+		res[i].FirstSeen = time.Unix(int64(1600000000+i), 0).UTC()
+		res[i].Fee = uint64(1000 + i)
+		res[i].Weight = 561 + i
+		if i%2 == 0 {
+			removed := res[i].FirstSeen.Add(time.Hour)
+			res[i].LastRemoved = &removed
+		}
+	}
+	return res
+}
+
+func TestWriteCSV(t *testing.T) {
+	txs := newTestTransactions(2)
+	expired := txs[1].FirstSeen.Add(2 * time.Hour)
+	txs[1].Expired = &expired
+
+	var buf bytes.Buffer
+	n, err := WriteCSV(&buf, &sliceTransactions{txs: txs})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t,
+		CSVHeader+
+			txs[0].TxID.String()+",1600000000,1600003600,,1000,561\n"+
+			txs[1].TxID.String()+",1600000001,,1600007201,1001,562\n",
+		buf.String(),
+	)
+
+	_, err = WriteCSV(ioutil.Discard, &sliceTransactions{txs: txs, err: errors.New("disk error")})
+	assert.Error(t, err)
+}
+
+// BenchmarkCSVWriter measures the encoding of one transaction
+func BenchmarkCSVWriter(b *testing.B) {
+	txs := newTestTransactions(1000)
+	w, err := NewCSVWriter(ioutil.Discard)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := w.Write(&txs[i%len(txs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+	require.NoError(b, w.Flush())
+}
+
+// BenchmarkCSVWriter_EncodingCSV is BenchmarkCSVWriter with encoding/csv and a string per field,
+// for comparison
+func BenchmarkCSVWriter_EncodingCSV(b *testing.B) {
+	txs := newTestTransactions(1000)
+	w := csv.NewWriter(ioutil.Discard)
+	optionalTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tx := &txs[i%len(txs)]
+		err := w.Write([]string{
+			tx.TxID.String(),
+			strconv.FormatInt(tx.FirstSeen.Unix(), 10),
+			optionalTime(tx.LastRemoved),
+			optionalTime(tx.Expired),
+			strconv.FormatUint(tx.Fee, 10),
+			strconv.Itoa(tx.Weight),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	w.Flush()
+	require.NoError(b, w.Error())
+}
@@ -0,0 +1,158 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// JohoeFeeLevels are the lower bounds in sat/vB of the feerate buckets of the mempool statistics
+// of Jochen Hoenicke (https://github.com/jhoenicke/mempool). The last bucket has no upper bound.
+var JohoeFeeLevels = []float64{
+	0, 1, 2, 3, 4, 5, 6, 8, 10, 12, 14, 17, 20, 25, 30, 40, 50, 60, 70, 80, 100,
+	120, 140, 170, 200, 250, 300, 400, 500, 600, 700, 800, 1000, 1200, 1400, 1700, 2000,
+	2500, 3000, 4000, 5000, 6000, 7000, 8000, 10000,
+}
+
+// JohoeSample is the mempool at a point in time, aggregated into the buckets of JohoeFeeLevels
+type JohoeSample struct {
+	Time time.Time
+	// Number of transactions, their size in vbytes and their fees in satoshis per bucket
+	Counts []int64
+	Sizes  []int64
+	Fees   []int64
+}
+
+// NewJohoeSample aggregates the mempool `txs` at time `t`
+func NewJohoeSample(t time.Time, txs []types.Transaction) *JohoeSample {
+	s := &JohoeSample{
+		Time:   t,
+		Counts: make([]int64, len(JohoeFeeLevels)),
+		Sizes:  make([]int64, len(JohoeFeeLevels)),
+		Fees:   make([]int64, len(JohoeFeeLevels)),
+	}
+	for i := range txs {
+		s.add(&txs[i], 1)
+	}
+	return s
+}
+
+// add adds `sign` times `tx` to its bucket, -1 removes it
+func (s *JohoeSample) add(tx *types.Transaction, sign int64) {
+	feerate := tx.Feerate()
+	// index of the last level not above the feerate
+	bucket := sort.Search(len(JohoeFeeLevels), func(j int) bool { return JohoeFeeLevels[j] > feerate }) - 1
+	if bucket < 0 {
+		bucket = 0
+	}
+	s.Counts[bucket] += sign
+	s.Sizes[bucket] += sign * int64((tx.Weight+3)/4)
+	s.Fees[bucket] += sign * int64(tx.Fee)
+}
+
+// JohoeAccumulator maintains the buckets of a JohoeSample while transactions enter and leave the
+// mempool. It implements storage.Accumulator.
+type JohoeAccumulator struct {
+	sample *JohoeSample
+}
+
+// NewJohoeAccumulator returns a JohoeAccumulator of an empty mempool
+func NewJohoeAccumulator() *JohoeAccumulator {
+	return &JohoeAccumulator{sample: NewJohoeSample(time.Time{}, nil)}
+}
+
+// Add adds `tx` to its bucket
+func (a *JohoeAccumulator) Add(tx *types.Transaction) {
+	a.sample.add(tx, 1)
+}
+
+// Remove removes `tx`, which was added before, from its bucket
+func (a *JohoeAccumulator) Remove(tx *types.Transaction) {
+	a.sample.add(tx, -1)
+}
+
+// Sample returns a copy of the buckets as *JohoeSample at `t`
+func (a *JohoeAccumulator) Sample(t time.Time) interface{} {
+	return &JohoeSample{
+		Time:   t,
+		Counts: append([]int64(nil), a.sample.Counts...),
+		Sizes:  append([]int64(nil), a.sample.Sizes...),
+		Fees:   append([]int64(nil), a.sample.Fees...),
+	}
+}
+
+// JohoeWriter writes mempool samples in the format of the data files of the mempool statistics of
+// Jochen Hoenicke, a JSON array with one line per sample:
+//
+//	[<unix time>,[<count per bucket>],[<vbytes per bucket>],[<fees per bucket>]]
+//
+// With `callback`, the array is wrapped in `call(...)` like the `.js` files loaded by its web page.
+type JohoeWriter struct {
+	w        *bufio.Writer
+	callback bool
+	line     []byte
+	// number of written samples
+	count int64
+}
+
+// NewJohoeWriter returns a JohoeWriter that writes to `w`. Call Close after the last sample.
+func NewJohoeWriter(w io.Writer, callback bool) (*JohoeWriter, error) {
+	res := &JohoeWriter{w: bufio.NewWriterSize(w, 1<<16), callback: callback, line: make([]byte, 0, 1024)}
+	head := "[\n"
+	if callback {
+		head = "call([\n"
+	}
+	if _, err := res.w.WriteString(head); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Write writes `s` as one line
+func (j *JohoeWriter) Write(s *JohoeSample) error {
+	line := j.line[:0]
+	if j.count > 0 {
+		line = append(line, ",\n"...)
+	}
+	line = append(line, '[')
+	line = strconv.AppendInt(line, s.Time.Unix(), 10)
+	for _, values := range [][]int64{s.Counts, s.Sizes, s.Fees} {
+		line = append(line, ",["...)
+		for i, v := range values {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			line = strconv.AppendInt(line, v, 10)
+		}
+		line = append(line, ']')
+	}
+	line = append(line, ']')
+	j.line = line
+
+	if _, err := j.w.Write(line); err != nil {
+		return err
+	}
+	j.count++
+	return nil
+}
+
+// Count returns the number of written samples
+func (j *JohoeWriter) Count() int64 {
+	return j.count
+}
+
+// Close ends the array and flushes the buffer to the underlying writer
+func (j *JohoeWriter) Close() error {
+	tail := "\n]\n"
+	if j.callback {
+		tail = "\n])\n"
+	}
+	if _, err := j.w.WriteString(tail); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
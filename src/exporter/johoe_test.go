@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestNewJohoeSample(t *testing.T) {
+	at := time.Unix(1600000000, 0)
+	s := NewJohoeSample(at, []types.Transaction{
+		// 0.5, 1, 7.9 and 20000 sat/vB
+		{Fee: 50, Weight: 400},
+		{Fee: 100, Weight: 400},
+		{Fee: 790, Weight: 400},
+		{Fee: 2000000, Weight: 401},
+	})
+	assert.Equal(t, at, s.Time)
+	assert.Equal(t, int64(1), s.Counts[0])
+	assert.Equal(t, int64(1), s.Counts[1])
+	assert.Equal(t, int64(1), s.Counts[6])
+	assert.Equal(t, int64(1), s.Counts[len(JohoeFeeLevels)-1])
+	assert.Equal(t, int64(101), s.Sizes[len(JohoeFeeLevels)-1])
+	assert.Equal(t, int64(790), s.Fees[6])
+}
+
+func TestJohoeAccumulator(t *testing.T) {
+	txs := []types.Transaction{
+		{Fee: 50, Weight: 400},
+		{Fee: 100, Weight: 400},
+		{Fee: 790, Weight: 400},
+		{Fee: 2000000, Weight: 401},
+	}
+	at := time.Unix(1600000000, 0)
+	a := NewJohoeAccumulator()
+	for i := range txs {
+		a.Add(&txs[i])
+	}
+	first := a.Sample(at)
+	assert.Equal(t, NewJohoeSample(at, txs), first)
+
+	a.Remove(&txs[0])
+	a.Remove(&txs[3])
+	assert.Equal(t, NewJohoeSample(at, txs[1:3]), a.Sample(at))
+	// earlier samples are not changed
+	assert.Equal(t, NewJohoeSample(at, txs), first)
+}
+
+func TestJohoeWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewJohoeWriter(&buf, true)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(NewJohoeSample(time.Unix(1600000000, 0), []types.Transaction{{Fee: 100, Weight: 400}})))
+	require.NoError(t, w.Write(NewJohoeSample(time.Unix(1600000060, 0), nil)))
+	require.NoError(t, w.Close())
+	assert.Equal(t, int64(2), w.Count())
+
+	zeros := strings.Repeat(",0", len(JohoeFeeLevels)-2)
+	assert.Equal(t,
+		"call([\n"+
+			"[1600000000,[0,1"+zeros+"],[0,100"+zeros+"],[0,100"+zeros+"]],\n"+
+			"[1600000060,[0,0"+zeros+"],[0,0"+zeros+"],[0,0"+zeros+"]]\n"+
+			"])\n",
+		buf.String(),
+	)
+}
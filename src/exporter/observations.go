@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+
+	"github.com/0xb10c/bademeister-go/src/privacy"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ObservationCSVHeader is the first line written by ObservationWriter
+const ObservationCSVHeader = "txid,source,first_seen\n"
+
+// ObservationWriter writes the first-seen times of several sources (see storage.FirstSeenObservations)
+// as CSV with the columns of ObservationCSVHeader, one line per transaction and source.
+// Times are unix seconds, txids have the format of types.Hash32.String.
+type ObservationWriter struct {
+	w    *bufio.Writer
+	line []byte
+	// replaces the source labels except types.LocalSource if set
+	pseudonyms *privacy.NodePseudonymizer
+	// number of written observations
+	count int64
+}
+
+// NewObservationWriter returns an ObservationWriter that writes the header and the observations to `w`.
+// If `pseudonyms` is not nil, the source labels other than types.LocalSource are replaced by their pseudonyms.
+// Call Flush after the last observation.
+func NewObservationWriter(w io.Writer, pseudonyms *privacy.NodePseudonymizer) (*ObservationWriter, error) {
+	res := &ObservationWriter{w: bufio.NewWriterSize(w, 1<<16), line: make([]byte, 0, 256), pseudonyms: pseudonyms}
+	if _, err := res.w.WriteString(ObservationCSVHeader); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Write writes `obs` as one line
+func (o *ObservationWriter) Write(obs *types.ExternalFirstSeen) error {
+	source := obs.Source
+	if o.pseudonyms != nil && source != types.LocalSource {
+		source = o.pseudonyms.Pseudonym(source)
+	}
+
+	line := o.line[:0]
+	line = appendHex(line, obs.TxID)
+	line = append(line, ',')
+	line = appendCSVField(line, source)
+	line = append(line, ',')
+	line = strconv.AppendInt(line, obs.FirstSeen.Unix(), 10)
+	line = append(line, '\n')
+	o.line = line
+
+	if _, err := o.w.Write(line); err != nil {
+		return err
+	}
+	o.count++
+	return nil
+}
+
+// Count returns the number of written observations
+func (o *ObservationWriter) Count() int64 {
+	return o.count
+}
+
+// Flush writes buffered lines to the underlying writer
+func (o *ObservationWriter) Flush() error {
+	return o.w.Flush()
+}
+
+// appendCSVField appends `v`, quoted if it contains a separator, quote or line break
+func appendCSVField(dst []byte, v string) []byte {
+	quote := false
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case ',', '"', '\n', '\r':
+			quote = true
+		}
+	}
+	if !quote {
+		return append(dst, v...)
+	}
+	dst = append(dst, '"')
+	for i := 0; i < len(v); i++ {
+		if v[i] == '"' {
+			dst = append(dst, '"')
+		}
+		dst = append(dst, v[i])
+	}
+	return append(dst, '"')
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/privacy"
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestObservationWriter(t *testing.T) {
+	txid := test.GenerateHash32("tx")
+	at := time.Unix(1600000000, 0)
+	observations := []types.ExternalFirstSeen{
+		{TxID: txid, FirstSeen: at, Source: types.LocalSource},
+		{TxID: txid, FirstSeen: at.Add(time.Second), Source: "203.0.113.5:8333"},
+		{TxID: txid, FirstSeen: at.Add(2 * time.Second), Source: `a "quoted", label`},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewObservationWriter(&buf, nil)
+	require.NoError(t, err)
+	for i := range observations {
+		require.NoError(t, w.Write(&observations[i]))
+	}
+	require.NoError(t, w.Flush())
+	assert.Equal(t, int64(3), w.Count())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"txid", "source", "first_seen"},
+		{txid.String(), "local", "1600000000"},
+		{txid.String(), "203.0.113.5:8333", "1600000001"},
+		{txid.String(), `a "quoted", label`, "1600000002"},
+	}, records)
+
+	// pseudonymized
+	pseudonyms := privacy.NewNodePseudonymizer([]byte("key"))
+	buf.Reset()
+	w, err = NewObservationWriter(&buf, pseudonyms)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(&observations[0]))
+	require.NoError(t, w.Write(&observations[1]))
+	require.NoError(t, w.Flush())
+	assert.Equal(t,
+		ObservationCSVHeader+
+			txid.String()+",local,1600000000\n"+
+			txid.String()+","+pseudonyms.Pseudonym("203.0.113.5:8333")+",1600000001\n",
+		buf.String(),
+	)
+	assert.NotContains(t, buf.String(), "203.0.113.5")
+}
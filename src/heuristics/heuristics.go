@@ -0,0 +1,132 @@
+// Package heuristics classifies transactions by common patterns.
+//
+// The classifications are heuristics: they are meant for aggregate statistics
+// and can be wrong for individual transactions.
+package heuristics
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+const (
+	// BatchMinOutputs is the minimum output count for HeuristicBatch
+	BatchMinOutputs = 10
+	// ConsolidationMinInputs is the minimum input count for HeuristicConsolidation
+	ConsolidationMinInputs = 3
+	// ConsolidationMaxOutputs is the maximum output count for HeuristicConsolidation
+	ConsolidationMaxOutputs = 1
+	// CoinjoinMinEqualOutputs is the minimum number of equal-value outputs for HeuristicCoinjoinLike
+	CoinjoinMinEqualOutputs = 3
+	// DustLimit is the output value in satoshis below which an output is considered dust
+	DustLimit = 546
+)
+
+// Thresholds are the limits of the classifications that can be configured.
+// Zero values select the defaults.
+type Thresholds struct {
+	// Minimum output count for HeuristicBatch, BatchMinOutputs by default
+	BatchMinOutputs int `json:"batchMinOutputs"`
+	// Minimum input count for HeuristicConsolidation, ConsolidationMinInputs by default
+	ConsolidationMinInputs int `json:"consolidationMinInputs"`
+	// Maximum output count for HeuristicConsolidation, ConsolidationMaxOutputs by default
+	ConsolidationMaxOutputs int `json:"consolidationMaxOutputs"`
+}
+
+// batchMinOutputs returns the minimum output count for HeuristicBatch
+func (t *Thresholds) batchMinOutputs() int {
+	if t.BatchMinOutputs > 0 {
+		return t.BatchMinOutputs
+	}
+	return BatchMinOutputs
+}
+
+// consolidationMinInputs returns the minimum input count for HeuristicConsolidation
+func (t *Thresholds) consolidationMinInputs() int {
+	if t.ConsolidationMinInputs > 0 {
+		return t.ConsolidationMinInputs
+	}
+	return ConsolidationMinInputs
+}
+
+// consolidationMaxOutputs returns the maximum output count for HeuristicConsolidation
+func (t *Thresholds) consolidationMaxOutputs() int {
+	if t.ConsolidationMaxOutputs > 0 {
+		return t.ConsolidationMaxOutputs
+	}
+	return ConsolidationMaxOutputs
+}
+
+// Validate returns an error if a threshold has an invalid value
+func (t *Thresholds) Validate() error {
+	if t.BatchMinOutputs < 0 {
+		return errors.Errorf("invalid batchMinOutputs %d", t.BatchMinOutputs)
+	}
+	if t.ConsolidationMinInputs < 0 {
+		return errors.Errorf("invalid consolidationMinInputs %d", t.ConsolidationMinInputs)
+	}
+	if t.ConsolidationMaxOutputs < 0 {
+		return errors.Errorf("invalid consolidationMaxOutputs %d", t.ConsolidationMaxOutputs)
+	}
+	if t.consolidationMaxOutputs() >= t.consolidationMinInputs() {
+		return errors.Errorf(
+			"consolidationMaxOutputs %d must be below consolidationMinInputs %d",
+			t.consolidationMaxOutputs(), t.consolidationMinInputs(),
+		)
+	}
+	return nil
+}
+
+// nullDataScriptType is the script type of OP_RETURN outputs, which are not dust
+const nullDataScriptType = "nulldata"
+
+// maxEqualOutputs returns the count of the most common output value
+func maxEqualOutputs(outputs []types.TxOutput) int {
+	counts := map[int64]int{}
+	max := 0
+	for _, out := range outputs {
+		if out.ScriptType == nullDataScriptType {
+			continue
+		}
+		counts[out.Value]++
+		if counts[out.Value] > max {
+			max = counts[out.Value]
+		}
+	}
+	return max
+}
+
+// Classify returns the heuristic flags for a transaction with the default thresholds
+func Classify(details *types.TxDetails) types.HeuristicFlags {
+	return ClassifyWith(details, Thresholds{})
+}
+
+// ClassifyWith returns the heuristic flags for a transaction with the thresholds `t`
+func ClassifyWith(details *types.TxDetails, t Thresholds) types.HeuristicFlags {
+	var flags types.HeuristicFlags
+
+	nInputs, nOutputs := len(details.Inputs), len(details.Outputs)
+
+	if nOutputs >= t.batchMinOutputs() && nInputs < nOutputs {
+		flags |= types.HeuristicBatch
+	}
+
+	if nInputs >= t.consolidationMinInputs() && nOutputs >= 1 && nOutputs <= t.consolidationMaxOutputs() {
+		flags |= types.HeuristicConsolidation
+	}
+
+	// coinjoins have (at least) one input per equal-value output
+	if equal := maxEqualOutputs(details.Outputs); equal >= CoinjoinMinEqualOutputs && nInputs >= equal {
+		flags |= types.HeuristicCoinjoinLike
+	}
+
+	for _, out := range details.Outputs {
+		if out.ScriptType != nullDataScriptType && out.Value < DustLimit {
+			flags |= types.HeuristicDust
+			break
+		}
+	}
+
+	return flags
+}
@@ -0,0 +1,64 @@
+package heuristics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func newDetails(nInputs int, outputValues ...int64) *types.TxDetails {
+	details := types.TxDetails{
+		Inputs: make([]types.TxInput, nInputs),
+	}
+	for _, v := range outputValues {
+		details.Outputs = append(details.Outputs, types.TxOutput{
+			Value:      v,
+			ScriptType: "witness_v0_keyhash",
+		})
+	}
+	return &details
+}
+
+func TestClassify(t *testing.T) {
+	// simple payment
+	assert.Equal(t, types.HeuristicFlags(0), Classify(newDetails(1, 10000, 20000)))
+
+	batch := newDetails(1, 1e4, 2e4, 3e4, 4e4, 5e4, 6e4, 7e4, 8e4, 9e4, 10e4, 11e4)
+	assert.Equal(t, types.HeuristicBatch, Classify(batch))
+
+	assert.Equal(t, types.HeuristicConsolidation, Classify(newDetails(5, 1e6)))
+
+	coinjoin := newDetails(5, 1e6, 1e6, 1e6, 1e6, 1e6, 12345, 23456)
+	assert.Equal(t, types.HeuristicCoinjoinLike, Classify(coinjoin))
+
+	// equal outputs without enough inputs are not coinjoin-like
+	assert.Equal(t, types.HeuristicFlags(0), Classify(newDetails(1, 1e6, 1e6, 1e6)))
+
+	dust := Classify(newDetails(3, 500))
+	assert.True(t, dust.Has(types.HeuristicDust))
+	assert.True(t, dust.Has(types.HeuristicConsolidation))
+
+	// OP_RETURN outputs with zero value are not dust
+	opReturn := newDetails(1, 1e5)
+	opReturn.Outputs = append(opReturn.Outputs, types.TxOutput{Value: 0, ScriptType: "nulldata"})
+	assert.Equal(t, types.HeuristicFlags(0), Classify(opReturn))
+}
+
+func TestClassifyWith(t *testing.T) {
+	payout := newDetails(1, 1e4, 2e4, 3e4, 4e4, 5e4)
+	assert.Equal(t, types.HeuristicFlags(0), Classify(payout))
+	assert.Equal(t, types.HeuristicBatch, ClassifyWith(payout, Thresholds{BatchMinOutputs: 5}))
+	assert.Equal(t, types.HeuristicFlags(0), ClassifyWith(payout, Thresholds{BatchMinOutputs: 6}))
+
+	sweep := newDetails(10, 1e6, 2e6)
+	assert.Equal(t, types.HeuristicFlags(0), Classify(sweep))
+	assert.Equal(t, types.HeuristicConsolidation, ClassifyWith(sweep, Thresholds{ConsolidationMaxOutputs: 2}))
+	assert.Equal(t, types.HeuristicFlags(0), ClassifyWith(sweep, Thresholds{ConsolidationMinInputs: 11, ConsolidationMaxOutputs: 2}))
+
+	assert.NoError(t, (&Thresholds{BatchMinOutputs: 5}).Validate())
+	assert.Error(t, (&Thresholds{BatchMinOutputs: -1}).Validate())
+	assert.Error(t, (&Thresholds{ConsolidationMinInputs: -1}).Validate())
+	assert.Error(t, (&Thresholds{ConsolidationMaxOutputs: 3}).Validate())
+}
@@ -0,0 +1,201 @@
+// Package importer reads first-seen datasets of other collectors, so that they can be
+// stored next to the own observations and compared with them.
+//
+// A dataset consists of records with a txid, a timestamp and an optional source label.
+// Txids are hex strings in the usual RPC byte order. Timestamps are unix seconds
+// (fractions are truncated) or RFC3339 strings.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Format of a dataset
+type Format string
+
+// Supported formats
+const (
+	// FormatCSV has the columns `txid,timestamp[,source]` and an optional header line starting with `txid`
+	FormatCSV Format = "csv"
+	// FormatJSON is an array or a sequence of objects `{"txid": ..., "timestamp": ..., "source": ...}`
+	FormatJSON Format = "json"
+)
+
+// FormatFromPath returns the format for the file extension of `path`
+func FormatFromPath(path string) (Format, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".csv"):
+		return FormatCSV, nil
+	case strings.HasSuffix(lower, ".json"), strings.HasSuffix(lower, ".jsonl"):
+		return FormatJSON, nil
+	case strings.HasSuffix(lower, ".js"):
+		return FormatJohoe, nil
+	}
+	return "", errors.Errorf("unknown format of %s, use csv, json or johoe", path)
+}
+
+// Reader reads records of a dataset
+type Reader struct {
+	// Source is the label for records without source
+	Source string
+
+	next func() (*types.ExternalFirstSeen, error)
+	line int
+}
+
+// NewReader returns a Reader for a dataset in format `format`.
+// Records without source are labeled with `source`.
+func NewReader(r io.Reader, format Format, source string) (*Reader, error) {
+	res := &Reader{Source: source}
+	switch format {
+	case FormatCSV:
+		res.next = res.csvReader(r)
+	case FormatJSON:
+		res.next = res.jsonReader(r)
+	default:
+		return nil, errors.Errorf("unknown format %q", format)
+	}
+	return res, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the dataset.
+// Invalid records are reported as error wrapping types.ErrParse.
+func (r *Reader) Read() (*types.ExternalFirstSeen, error) {
+	return r.next()
+}
+
+// ReadAll returns the remaining records
+func (r *Reader) ReadAll() (res []types.ExternalFirstSeen, err error) {
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *record)
+	}
+}
+
+func (r *Reader) csvReader(in io.Reader) func() (*types.ExternalFirstSeen, error) {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return func() (*types.ExternalFirstSeen, error) {
+		for {
+			fields, err := cr.Read()
+			if err == io.EOF {
+				return nil, err
+			}
+			r.line++
+			if err != nil {
+				return nil, errors.Wrapf(types.ErrParse, "line %d: %s", r.line, err)
+			}
+			if r.line == 1 && strings.EqualFold(fields[0], "txid") {
+				continue
+			}
+			if len(fields) < 2 || len(fields) > 3 {
+				return nil, errors.Wrapf(types.ErrParse, "line %d: expected 2 or 3 columns, got %d", r.line, len(fields))
+			}
+			source := ""
+			if len(fields) == 3 {
+				source = fields[2]
+			}
+			return r.record(fields[0], fields[1], source)
+		}
+	}
+}
+
+// jsonRecord is a record of a JSON dataset. The timestamp can be a number or a string.
+type jsonRecord struct {
+	TxID      string          `json:"txid"`
+	Timestamp json.RawMessage `json:"timestamp"`
+	Source    string          `json:"source"`
+}
+
+func (r *Reader) jsonReader(in io.Reader) func() (*types.ExternalFirstSeen, error) {
+	br := bufio.NewReader(in)
+	// an array is read element by element, so large files are not loaded at once
+	inArray := firstNonSpace(br) == '['
+	dec := json.NewDecoder(br)
+	if inArray {
+		// consume the opening bracket
+		_, _ = dec.Token()
+	}
+	return func() (*types.ExternalFirstSeen, error) {
+		if !dec.More() {
+			return nil, io.EOF
+		}
+
+		r.line++
+		var record jsonRecord
+		if err := dec.Decode(&record); err != nil {
+			return nil, errors.Wrapf(types.ErrParse, "record %d: %s", r.line, err)
+		}
+		timestamp := strings.Trim(string(record.Timestamp), `"`)
+		return r.record(record.TxID, timestamp, record.Source)
+	}
+}
+
+// firstNonSpace skips leading whitespace of `br` and returns the next byte without consuming it
+func firstNonSpace(br *bufio.Reader) byte {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+		default:
+			return b[0]
+		}
+	}
+}
+
+func (r *Reader) record(txid, timestamp, source string) (*types.ExternalFirstSeen, error) {
+	hash, err := types.NewHashFromString(strings.TrimSpace(txid))
+	if err != nil {
+		return nil, errors.Wrapf(err, "record %d", r.line)
+	}
+	firstSeen, err := ParseTimestamp(strings.TrimSpace(timestamp))
+	if err != nil {
+		return nil, errors.Wrapf(err, "record %d", r.line)
+	}
+	if source == "" {
+		source = r.Source
+	}
+	if source == "" {
+		return nil, errors.Wrapf(types.ErrParse, "record %d: no source label", r.line)
+	}
+	return &types.ExternalFirstSeen{
+		TxID:      hash,
+		FirstSeen: firstSeen,
+		Source:    source,
+	}, nil
+}
+
+// ParseTimestamp parses unix seconds, with optional fraction, or an RFC3339 string
+func ParseTimestamp(v string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		return time.Unix(int64(seconds), 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(types.ErrParse, "invalid timestamp %q", v)
+	}
+	return t.UTC(), nil
+}
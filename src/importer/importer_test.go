@@ -0,0 +1,69 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestReader(t *testing.T) {
+	a, b := test.GenerateHash32("a"), test.GenerateHash32("b")
+	expected := []types.ExternalFirstSeen{
+		{TxID: a, FirstSeen: time.Unix(100, 0).UTC(), Source: "default"},
+		{TxID: b, FirstSeen: time.Unix(200, 0).UTC(), Source: "other"},
+	}
+
+	datasets := []struct {
+		format Format
+		data   string
+	}{
+		{FormatCSV, "txid,timestamp,source\n" + a.String() + ",100\n" + b.String() + ",1970-01-01T00:03:20Z,other\n"},
+		{FormatJSON, `[{"txid": "` + a.String() + `", "timestamp": 100.5},
+			{"txid": "` + b.String() + `", "timestamp": "200", "source": "other"}]`},
+		{FormatJSON, `{"txid": "` + a.String() + `", "timestamp": 100}
+			{"txid": "` + b.String() + `", "timestamp": "1970-01-01T00:03:20Z", "source": "other"}`},
+	}
+	for _, d := range datasets {
+		r, err := NewReader(strings.NewReader(d.data), d.format, "default")
+		require.NoError(t, err)
+		records, err := r.ReadAll()
+		require.NoError(t, err, d.data)
+		assert.Equal(t, expected, records, d.data)
+	}
+
+	invalid := []string{
+		"xyz,100\n",
+		a.String() + ",yesterday\n",
+		a.String() + "\n",
+	}
+	for _, data := range invalid {
+		r, err := NewReader(strings.NewReader(data), FormatCSV, "default")
+		require.NoError(t, err)
+		_, err = r.ReadAll()
+		assert.Equal(t, types.ErrParse, errors.Cause(err), data)
+	}
+
+	// a label is required
+	r, err := NewReader(strings.NewReader(a.String()+",100\n"), FormatCSV, "")
+	require.NoError(t, err)
+	_, err = r.ReadAll()
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+}
+
+func TestFormatFromPath(t *testing.T) {
+	f, err := FormatFromPath("data/Dataset.CSV")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, f)
+	f, err = FormatFromPath("dataset.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+	_, err = FormatFromPath("dataset.txt")
+	assert.Error(t, err)
+}
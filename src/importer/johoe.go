@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// FormatJohoe are the data files of the mempool statistics of Jochen Hoenicke, see exporter.JohoeWriter.
+// They contain feerate histograms of the mempool instead of first-seen times and are read with JohoeReader.
+const FormatJohoe Format = "johoe"
+
+// JohoeReader reads the samples of a data file of the mempool statistics of Jochen Hoenicke.
+// The buckets of a sample are those of exporter.JohoeFeeLevels, in that order; a sample may have
+// fewer buckets than levels.
+type JohoeReader struct {
+	// Source is the label of the histograms
+	Source string
+
+	dec    *json.Decoder
+	sample int
+}
+
+// NewJohoeReader returns a JohoeReader of `r`, a JSON array or an array wrapped in `call(...)`
+func NewJohoeReader(r io.Reader, source string) (*JohoeReader, error) {
+	if source == "" {
+		return nil, errors.Wrap(types.ErrParse, "no source label")
+	}
+	br := bufio.NewReader(r)
+	if firstNonSpace(br) == 'c' {
+		prefix := make([]byte, len("call("))
+		if _, err := io.ReadFull(br, prefix); err != nil || string(prefix) != "call(" {
+			return nil, errors.Wrapf(types.ErrParse, "expected an array or call(...)")
+		}
+	}
+	dec := json.NewDecoder(br)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, errors.Wrapf(types.ErrParse, "expected an array")
+	}
+	return &JohoeReader{Source: source, dec: dec}, nil
+}
+
+// Read returns the next sample, or io.EOF at the end of the array.
+// Invalid samples are reported as error wrapping types.ErrParse.
+func (r *JohoeReader) Read() (*types.ImportedHistogram, error) {
+	if !r.dec.More() {
+		return nil, io.EOF
+	}
+
+	r.sample++
+	var sample []json.RawMessage
+	if err := r.dec.Decode(&sample); err != nil {
+		return nil, errors.Wrapf(types.ErrParse, "sample %d: %s", r.sample, err)
+	}
+	if len(sample) != 4 {
+		return nil, errors.Wrapf(types.ErrParse, "sample %d: expected 4 elements, got %d", r.sample, len(sample))
+	}
+	var t float64
+	if err := json.Unmarshal(sample[0], &t); err != nil {
+		return nil, errors.Wrapf(types.ErrParse, "sample %d: invalid time %s", r.sample, sample[0])
+	}
+
+	var series [3][]float64
+	for i := range series {
+		if err := json.Unmarshal(sample[i+1], &series[i]); err != nil {
+			return nil, errors.Wrapf(types.ErrParse, "sample %d: %s", r.sample, err)
+		}
+		if len(series[i]) > len(exporter.JohoeFeeLevels) || len(series[i]) != len(series[0]) {
+			return nil, errors.Wrapf(types.ErrParse, "sample %d: invalid number of buckets", r.sample)
+		}
+	}
+
+	h := &types.ImportedHistogram{
+		Source:   r.Source,
+		Time:     time.Unix(int64(t), 0).UTC(),
+		Feerates: append([]float64(nil), exporter.JohoeFeeLevels[:len(series[0])]...),
+		Counts:   make([]int64, len(series[0])),
+		VSizes:   make([]int64, len(series[0])),
+		Fees:     make([]int64, len(series[0])),
+	}
+	for i := range series[0] {
+		h.Counts[i] = int64(math.Round(series[0][i]))
+		h.VSizes[i] = int64(math.Round(series[1][i]))
+		h.Fees[i] = int64(math.Round(series[2][i]))
+	}
+	return h, nil
+}
@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/exporter"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestJohoeReader(t *testing.T) {
+	// round trip through the exporter
+	var buf bytes.Buffer
+	w, err := exporter.NewJohoeWriter(&buf, true)
+	require.NoError(t, err)
+	sample := exporter.NewJohoeSample(time.Unix(1600000000, 0), []types.Transaction{{Fee: 100, Weight: 400}})
+	require.NoError(t, w.Write(sample))
+	require.NoError(t, w.Close())
+
+	r, err := NewJohoeReader(&buf, "johoe")
+	require.NoError(t, err)
+	h, err := r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "johoe", h.Source)
+	assert.Equal(t, time.Unix(1600000000, 0).UTC(), h.Time)
+	assert.Equal(t, exporter.JohoeFeeLevels, h.Feerates)
+	assert.Equal(t, sample.Counts, h.Counts)
+	assert.Equal(t, sample.Sizes, h.VSizes)
+	assert.Equal(t, sample.Fees, h.Fees)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	// a plain array with fewer buckets
+	r, err = NewJohoeReader(strings.NewReader(` [[1600000060,[1,2],[100,200],[150,400]]]`), "johoe")
+	require.NoError(t, err)
+	h, err = r.Read()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0, 1}, h.Feerates)
+	assert.Equal(t, []int64{150, 400}, h.Fees)
+
+	for _, data := range []string{
+		`[[1600000060,[1,2],[100],[150,400]]]`,
+		`[[1600000060,[1,2],[100,200]]]`,
+		`[["x",[1],[1],[1]]]`,
+	} {
+		r, err := NewJohoeReader(strings.NewReader(data), "johoe")
+		require.NoError(t, err)
+		_, err = r.Read()
+		assert.Equal(t, types.ErrParse, errors.Cause(err), data)
+	}
+
+	_, err = NewJohoeReader(strings.NewReader(`cell([])`), "johoe")
+	assert.Error(t, err)
+	_, err = NewJohoeReader(strings.NewReader(`[]`), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,443 @@
+// Package jobs runs long analysis tasks, e.g. bulk exports, in the background.
+//
+// The state and the result of each job are files in a directory, so jobs survive restarts and do
+// not depend on write access to the database. Jobs that were queued or running when the process
+// stopped are run again on start. Running jobs report their progress and can be canceled.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Status is the state of a Job
+type Status string
+
+const (
+	// StatusQueued jobs wait for a worker
+	StatusQueued Status = "queued"
+	// StatusRunning jobs are being run by a worker
+	StatusRunning Status = "running"
+	// StatusDone jobs have a result
+	StatusDone Status = "done"
+	// StatusFailed jobs ended with an error
+	StatusFailed Status = "failed"
+	// StatusCanceled jobs were canceled with Cancel
+	StatusCanceled Status = "canceled"
+)
+
+// finished returns true if jobs with status `s` do not change anymore
+func (s Status) finished() bool {
+	return s == StatusDone || s == StatusFailed || s == StatusCanceled
+}
+
+// MaxQueued is the maximum number of queued jobs, further submissions fail
+const MaxQueued = 100
+
+// ErrQueueFull is returned by Submit if MaxQueued jobs are queued
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrFinished is returned by Cancel for jobs that are done, failed or canceled
+var ErrFinished = errors.New("job is finished")
+
+// ErrNotFinished is returned by Delete for jobs that are queued or running
+var ErrNotFinished = errors.New("job is not finished")
+
+// Job describes a submitted task
+type Job struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Parameters of the task, in the format of the query string of the corresponding endpoint
+	Params    string     `json:"params"`
+	Status    Status     `json:"status"`
+	Submitted time.Time  `json:"submitted"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	// Percentage of the task that is done, as far as the kind reports it, 100 when done
+	Progress float64 `json:"progress"`
+	// Size of the result in bytes, set when done
+	ResultSize int64 `json:"resultSize,omitempty"`
+}
+
+// Progress reports the fraction of a task that is done, from 0 to 1
+type Progress func(done float64)
+
+// Kind is a type of task
+type Kind struct {
+	// Content type of the result
+	ContentType string
+	// Validate returns an error if `params` are invalid. It is called on submission and may set
+	// defaults that depend on the time of submission in `params`.
+	Validate func(params url.Values) error
+	// Run writes the result of the task with `params` to `w` and reports its progress with
+	// `progress`. It should return ctx.Err() soon after `ctx` is canceled.
+	Run func(ctx context.Context, params url.Values, w io.Writer, progress Progress) error
+}
+
+// Queue runs submitted jobs with a fixed number of workers, oldest first
+type Queue struct {
+	dir   string
+	kinds map[string]Kind
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	// ids of the queued jobs, oldest first; workers wait on `wake` for new ones
+	pending []string
+	wake    *sync.Cond
+	// cancel functions of the running jobs
+	running map[string]context.CancelFunc
+}
+
+// NewQueue returns a Queue that keeps its jobs in `dir`, created if missing, and runs them with
+// `workers` goroutines. Jobs of an earlier Queue in `dir` are loaded; queued and running jobs
+// of known kinds are queued again.
+func NewQueue(dir string, kinds map[string]Kind, workers int) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create the job directory")
+	}
+	q := &Queue{
+		dir:     dir,
+		kinds:   kinds,
+		jobs:    map[string]*Job{},
+		running: map[string]context.CancelFunc{},
+	}
+	q.wake = sync.NewCond(&q.mu)
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q, nil
+}
+
+// load reads the jobs of `dir` and queues the unfinished ones
+func (q *Queue) load() error {
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			log.Warnf("Ignoring invalid job file %s: %s", path, err)
+			continue
+		}
+		q.jobs[job.ID] = &job
+	}
+
+	var unfinished []*Job
+	for _, job := range q.jobs {
+		if job.Status == StatusQueued || job.Status == StatusRunning {
+			unfinished = append(unfinished, job)
+		}
+	}
+	sort.Slice(unfinished, func(i, j int) bool { return unfinished[i].Submitted.Before(unfinished[j].Submitted) })
+	for _, job := range unfinished {
+		if _, ok := q.kinds[job.Type]; !ok || len(q.pending) == MaxQueued {
+			q.finish(job, errors.Errorf("job of type %q could not be resumed", job.Type))
+			continue
+		}
+		log.Infof("Resuming job %s (%s)", job.ID, job.Type)
+		job.Status, job.Started, job.Progress = StatusQueued, nil, 0
+		if err := q.save(job); err != nil {
+			return err
+		}
+		q.pending = append(q.pending, job.ID)
+	}
+	return nil
+}
+
+// queued returns the number of queued jobs
+func (q *Queue) queued() int {
+	n := 0
+	for _, job := range q.jobs {
+		if job.Status == StatusQueued {
+			n++
+		}
+	}
+	return n
+}
+
+// Submit queues a job of type `kind` with `params`.
+// Returns an error wrapping types.ErrParse for an unknown type or invalid parameters.
+func (q *Queue) Submit(kind string, params url.Values) (*Job, error) {
+	k, ok := q.kinds[kind]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrParse, "unknown job type %q, expected one of %s", kind, q.kindNames())
+	}
+	if k.Validate != nil {
+		if err := k.Validate(params); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:        id,
+		Type:      kind,
+		Params:    params.Encode(),
+		Status:    StatusQueued,
+		Submitted: time.Now().UTC(),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queued() >= MaxQueued {
+		return nil, ErrQueueFull
+	}
+	if err := q.save(job); err != nil {
+		return nil, err
+	}
+	q.jobs[id] = job
+	q.pending = append(q.pending, id)
+	q.wake.Signal()
+	log.Infof("Queued job %s (%s %s)", id, kind, job.Params)
+	res := *job
+	return &res, nil
+}
+
+// Job returns the job `id`, or types.ErrNotFound
+func (q *Queue) Job(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrNotFound, "job %s", id)
+	}
+	res := *job
+	return &res, nil
+}
+
+// Jobs returns all jobs, most recently submitted first
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		res = append(res, *job)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Submitted.After(res[j].Submitted) })
+	return res
+}
+
+// Cancel cancels the queued or running job `id` and returns it. The partial result of a running
+// job is removed once it stopped, which may take a moment: until then its status is `running`.
+// Returns ErrFinished for finished jobs.
+func (q *Queue) Cancel(id string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, errors.Wrapf(types.ErrNotFound, "job %s", id)
+	}
+	switch {
+	case job.Status.finished():
+		return nil, errors.Wrapf(ErrFinished, "job %s is %s", id, job.Status)
+	case job.Status == StatusQueued:
+		for i, pending := range q.pending {
+			if pending == id {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		q.finish(job, context.Canceled)
+	default:
+		log.Infof("Canceling job %s (%s)", id, job.Type)
+		q.running[id]()
+	}
+	res := *job
+	return &res, nil
+}
+
+// Delete removes the finished job `id` and its result. Returns ErrNotFinished for queued or
+// running jobs, which have to be canceled first.
+func (q *Queue) Delete(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return errors.Wrapf(types.ErrNotFound, "job %s", id)
+	}
+	if !job.Status.finished() {
+		return errors.Wrapf(ErrNotFinished, "job %s is %s", id, job.Status)
+	}
+	if err := os.Remove(q.resultPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(filepath.Join(q.dir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(q.jobs, id)
+	log.Infof("Deleted job %s (%s)", id, job.Type)
+	return nil
+}
+
+// Result opens the result of the done job `id` and returns its content type.
+// The caller must close the file.
+func (q *Queue) Result(id string) (*os.File, string, error) {
+	job, err := q.Job(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if job.Status != StatusDone {
+		return nil, "", errors.Errorf("job %s has no result, its status is %s", id, job.Status)
+	}
+	f, err := os.Open(q.resultPath(id))
+	if err != nil {
+		return nil, "", err
+	}
+	return f, q.kinds[job.Type].ContentType, nil
+}
+
+// work runs queued jobs until the process ends
+func (q *Queue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.wake.Wait()
+		}
+		id := q.pending[0]
+		q.pending = q.pending[1:]
+		job := q.jobs[id]
+		now := time.Now().UTC()
+		job.Status, job.Started = StatusRunning, &now
+		if err := q.save(job); err != nil {
+			q.finish(job, err)
+			q.mu.Unlock()
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		q.running[id] = cancel
+		q.mu.Unlock()
+
+		log.Infof("Running job %s (%s %s)", id, job.Type, job.Params)
+		size, err := q.run(ctx, job)
+
+		q.mu.Lock()
+		delete(q.running, id)
+		cancel()
+		job.ResultSize = size
+		q.finish(job, err)
+		q.mu.Unlock()
+	}
+}
+
+// run runs `job` and returns the size of its result
+func (q *Queue) run(ctx context.Context, job *Job) (int64, error) {
+	params, err := url.ParseQuery(job.Params)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(q.resultPath(job.ID))
+	if err != nil {
+		return 0, err
+	}
+	progress := func(done float64) {
+		q.mu.Lock()
+		job.Progress = math.Max(0, math.Min(100, 100*done))
+		q.mu.Unlock()
+	}
+	runErr := q.kinds[job.Type].Run(ctx, params, f, progress)
+	closeErr := f.Close()
+	if runErr == nil {
+		runErr = closeErr
+	}
+	if ctx.Err() != nil {
+		// canceled, whatever Run returned
+		runErr = ctx.Err()
+	}
+	if runErr != nil {
+		// partial results are removed
+		_ = os.Remove(f.Name())
+		return 0, runErr
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// finish marks `job` as done, as canceled for context.Canceled or as failed with `err`, and saves it
+func (q *Queue) finish(job *Job, err error) {
+	now := time.Now().UTC()
+	job.Finished = &now
+	if err == context.Canceled {
+		job.Status, job.ResultSize = StatusCanceled, 0
+		log.Infof("Job %s (%s) canceled", job.ID, job.Type)
+	} else if err != nil {
+		job.Status, job.Error = StatusFailed, err.Error()
+		log.Errorf("Job %s (%s) failed: %s", job.ID, job.Type, err)
+	} else {
+		job.Status, job.Progress = StatusDone, 100
+		log.Infof("Job %s (%s) done, %d bytes", job.ID, job.Type, job.ResultSize)
+	}
+	if err := q.save(job); err != nil {
+		log.Errorf("Could not save job %s: %s", job.ID, err)
+	}
+}
+
+// save writes `job` to its file, replacing it atomically
+func (q *Queue) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, job.ID+".json")
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return errors.Wrapf(err, "could not save job %s", job.ID)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (q *Queue) resultPath(id string) string {
+	return filepath.Join(q.dir, id+".result")
+}
+
+// kindNames returns the sorted names of the kinds of `q`, comma-separated
+func (q *Queue) kindNames() string {
+	var names []string
+	for name := range q.kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newID returns a random job id of 16 hex digits
+func newID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+var testKinds = map[string]Kind{
+	"echo": {
+		ContentType: "text/plain",
+		Validate: func(params url.Values) error {
+			if params.Get("text") == "" {
+				return errors.Wrap(types.ErrParse, "missing text")
+			}
+			return nil
+		},
+		Run: func(ctx context.Context, params url.Values, w io.Writer, progress Progress) error {
+			progress(0.5)
+			_, err := io.WriteString(w, params.Get("text"))
+			return err
+		},
+	},
+	"fail": {
+		Run: func(ctx context.Context, params url.Values, w io.Writer, progress Progress) error {
+			_, _ = io.WriteString(w, "partial")
+			return errors.New("broken")
+		},
+	},
+	// writes and reports progress until canceled
+	"endless": {
+		Run: func(ctx context.Context, params url.Values, w io.Writer, progress Progress) error {
+			for i := 1; ; i++ {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+				if _, err := io.WriteString(w, "partial"); err != nil {
+					return err
+				}
+				progress(1 - 1/float64(i))
+			}
+		},
+	},
+}
+
+// waitFinished returns job `id` once it is finished
+func waitFinished(t *testing.T, q *Queue, id string) *Job {
+	for i := 0; i < 500; i++ {
+		job, err := q.Job(id)
+		require.NoError(t, err)
+		if job.Status.finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := NewQueue(dir, testKinds, 2)
+	require.NoError(t, err)
+
+	_, err = q.Submit("unknown", url.Values{})
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+	_, err = q.Submit("echo", url.Values{})
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+
+	echo, err := q.Submit("echo", url.Values{"text": {"hello"}})
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, echo.Status)
+	assert.Equal(t, "text=hello", echo.Params)
+	failed, err := q.Submit("fail", url.Values{})
+	require.NoError(t, err)
+
+	job := waitFinished(t, q, echo.ID)
+	assert.Equal(t, StatusDone, job.Status)
+	assert.Equal(t, int64(5), job.ResultSize)
+	assert.Equal(t, float64(100), job.Progress)
+	f, contentType, err := q.Result(echo.ID)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, "text/plain", contentType)
+
+	job = waitFinished(t, q, failed.ID)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, "broken", job.Error)
+	_, _, err = q.Result(failed.ID)
+	assert.Error(t, err)
+	// partial results are removed
+	_, err = os.Stat(filepath.Join(dir, failed.ID+".result"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = q.Job("0000000000000000")
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+
+	list := q.Jobs()
+	require.Len(t, list, 2)
+	assert.Equal(t, failed.ID, list[0].ID)
+	assert.Equal(t, echo.ID, list[1].ID)
+
+	require.NoError(t, q.Delete(echo.ID))
+	_, err = q.Job(echo.ID)
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+	for _, ext := range []string{".json", ".result"} {
+		_, err = os.Stat(filepath.Join(dir, echo.ID+ext))
+		assert.True(t, os.IsNotExist(err), ext)
+	}
+	assert.Equal(t, types.ErrNotFound, errors.Cause(q.Delete(echo.ID)))
+}
+
+func TestQueue_Cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	q, err := NewQueue(dir, testKinds, 1)
+	require.NoError(t, err)
+
+	running, err := q.Submit("endless", url.Values{})
+	require.NoError(t, err)
+	queued, err := q.Submit("echo", url.Values{"text": {"hello"}})
+	require.NoError(t, err)
+
+	// a queued job is canceled at once and not run
+	job, err := q.Cancel(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, job.Status)
+
+	// canceled jobs do not count towards MaxQueued
+	for i := 0; i < MaxQueued+50; i++ {
+		job, err := q.Submit("echo", url.Values{"text": {"again"}})
+		require.NoError(t, err)
+		_, err = q.Cancel(job.ID)
+		require.NoError(t, err)
+	}
+
+	for job, err = q.Job(running.ID); job.Progress < 50; job, err = q.Job(running.ID) {
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, StatusRunning, job.Status)
+	assert.Equal(t, ErrNotFinished, errors.Cause(q.Delete(running.ID)))
+	_, err = q.Cancel(running.ID)
+	require.NoError(t, err)
+	job = waitFinished(t, q, running.ID)
+	assert.Equal(t, StatusCanceled, job.Status)
+	assert.Empty(t, job.Error)
+	assert.Zero(t, job.ResultSize)
+	// the partial result is removed
+	_, err = os.Stat(filepath.Join(dir, running.ID+".result"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = q.Cancel(running.ID)
+	assert.Equal(t, ErrFinished, errors.Cause(err))
+	_, err = q.Cancel("0000000000000000")
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+
+	// canceled jobs stay canceled
+	q, err = NewQueue(dir, testKinds, 1)
+	require.NoError(t, err)
+	job, err = q.Job(queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, job.Status)
+}
+
+func TestQueue_Resume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	started := time.Now().UTC()
+	for _, job := range []Job{
+		{ID: "interrupted", Type: "echo", Params: "text=again", Status: StatusRunning, Started: &started},
+		{ID: "obsolete", Type: "removed", Status: StatusQueued},
+	} {
+		data, err := json.Marshal(job)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, job.ID+".json"), data, 0600))
+	}
+
+	q, err := NewQueue(dir, testKinds, 1)
+	require.NoError(t, err)
+
+	job := waitFinished(t, q, "interrupted")
+	assert.Equal(t, StatusDone, job.Status)
+	assert.Equal(t, int64(5), job.ResultSize)
+
+	job, err = q.Job("obsolete")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+}
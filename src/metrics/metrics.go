@@ -0,0 +1,159 @@
+// Package metrics records latency histograms and exports them in the Prometheus text format.
+// It has no dependencies, so the daemon can be scraped without a metrics library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of a latency histogram
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Histogram counts durations in LatencyBuckets. It is safe for concurrent use,
+// and the methods of a nil *Histogram do nothing.
+type Histogram struct {
+	// per bucket, the last one counts durations above all bounds. Accessed atomically.
+	counts []uint64
+	// sum of the durations in nanoseconds. Accessed atomically.
+	sum int64
+}
+
+// NewHistogram returns an empty Histogram
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+// Observe counts `d`
+func (h *Histogram) Observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// Since counts the time since `t`
+func (h *Histogram) Since(t time.Time) {
+	h.Observe(time.Since(t))
+}
+
+// Bucket is the number of durations up to an upper bound
+type Bucket struct {
+	// Upper bound in seconds, +Inf for the last bucket
+	LE    float64 `json:"le"`
+	Count uint64  `json:"count"`
+}
+
+// Snapshot is the state of a Histogram. The bucket counts are cumulative, like in Prometheus.
+type Snapshot struct {
+	Count uint64 `json:"count"`
+	// Sum of the durations in seconds
+	Sum     float64  `json:"sum"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Snapshot returns the current state of the histogram.
+// Concurrent observations may be counted in some buckets but not yet in Sum.
+func (h *Histogram) Snapshot() Snapshot {
+	res := Snapshot{Buckets: make([]Bucket, len(LatencyBuckets)+1)}
+	if h == nil {
+		h = NewHistogram()
+	}
+	for i := range res.Buckets {
+		res.Count += atomic.LoadUint64(&h.counts[i])
+		res.Buckets[i].Count = res.Count
+		res.Buckets[i].LE = math.Inf(1)
+		if i < len(LatencyBuckets) {
+			res.Buckets[i].LE = LatencyBuckets[i].Seconds()
+		}
+	}
+	res.Sum = time.Duration(atomic.LoadInt64(&h.sum)).Seconds()
+	return res
+}
+
+// Quantile returns an estimate of the `q` quantile in seconds, interpolated linearly
+// within the bucket, like histogram_quantile in Prometheus. Returns 0 if the histogram is empty.
+func (s Snapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := q * float64(s.Count)
+	lower, below := 0.0, uint64(0)
+	for _, b := range s.Buckets {
+		if float64(b.Count) >= rank {
+			if math.IsInf(b.LE, 1) {
+				// above the largest bound
+				return lower
+			}
+			inBucket := b.Count - below
+			if inBucket == 0 {
+				return b.LE
+			}
+			return lower + (b.LE-lower)*(rank-float64(below))/float64(inBucket)
+		}
+		lower, below = b.LE, b.Count
+	}
+	return lower
+}
+
+// Summary is a compact description of a Snapshot, in seconds
+type Summary struct {
+	Count uint64  `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// Summary returns the mean and estimated quantiles of the snapshot
+func (s Snapshot) Summary() Summary {
+	res := Summary{Count: s.Count, P50: s.Quantile(0.5), P90: s.Quantile(0.9), P99: s.Quantile(0.99)}
+	if s.Count > 0 {
+		res.Mean = s.Sum / float64(s.Count)
+	}
+	return res
+}
+
+// WritePrometheus writes the histograms `byLabel` as one metric family `name` in the Prometheus text
+// format, with the map keys as values of the label `label`
+func WritePrometheus(w io.Writer, name, help, label string, byLabel map[string]Snapshot) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name); err != nil {
+		return err
+	}
+
+	values := make([]string, 0, len(byLabel))
+	for v := range byLabel {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		s := byLabel[v]
+		for _, b := range s.Buckets {
+			le := "+Inf"
+			if !math.IsInf(b.LE, 1) {
+				le = fmt.Sprintf("%g", b.LE)
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket{%s=%q,le=%q} %d\n", name, label, v, le, b.Count); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum{%s=%q} %g\n%s_count{%s=%q} %d\n",
+			name, label, v, s.Sum, name, label, v, s.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	h.Observe(50 * time.Microsecond)
+	h.Observe(time.Millisecond)
+	h.Observe(3 * time.Millisecond)
+	h.Observe(time.Minute)
+
+	s := h.Snapshot()
+	assert.Equal(t, uint64(4), s.Count)
+	assert.InDelta(t, 60.00405, s.Sum, 1e-9)
+	require.Len(t, s.Buckets, len(LatencyBuckets)+1)
+	assert.Equal(t, Bucket{LE: 0.0001, Count: 1}, s.Buckets[0])
+	assert.Equal(t, Bucket{LE: 0.001, Count: 2}, s.Buckets[3])
+	assert.Equal(t, Bucket{LE: 0.005, Count: 3}, s.Buckets[5])
+	assert.Equal(t, uint64(3), s.Buckets[len(LatencyBuckets)-1].Count)
+	assert.True(t, math.IsInf(s.Buckets[len(LatencyBuckets)].LE, 1))
+	assert.Equal(t, uint64(4), s.Buckets[len(LatencyBuckets)].Count)
+
+	// the median is the upper bound of the bucket of the second observation
+	assert.InDelta(t, 0.001, s.Quantile(0.5), 1e-12)
+	// interpolated within the first bucket
+	assert.InDelta(t, 0.00005, s.Quantile(0.125), 1e-12)
+	// above the largest bound
+	assert.Equal(t, 10.0, s.Quantile(0.99))
+
+	summary := s.Summary()
+	assert.Equal(t, uint64(4), summary.Count)
+	assert.InDelta(t, 15.0010125, summary.Mean, 1e-9)
+}
+
+func TestHistogram_Nil(t *testing.T) {
+	var h *Histogram
+	h.Observe(time.Second)
+	h.Since(time.Now())
+
+	s := h.Snapshot()
+	assert.Equal(t, uint64(0), s.Count)
+	assert.Len(t, s.Buckets, len(LatencyBuckets)+1)
+	assert.Equal(t, Summary{}, s.Summary())
+}
+
+func TestWritePrometheus(t *testing.T) {
+	h := NewHistogram()
+	h.Observe(2 * time.Millisecond)
+
+	var buf bytes.Buffer
+	err := WritePrometheus(&buf, "test_seconds", "Test.", "stage", map[string]Snapshot{
+		"b": h.Snapshot(),
+		"a": NewHistogram().Snapshot(),
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2+2*(len(LatencyBuckets)+3))
+	assert.Equal(t, "# HELP test_seconds Test.", lines[0])
+	assert.Equal(t, "# TYPE test_seconds histogram", lines[1])
+	assert.Equal(t, `test_seconds_bucket{stage="a",le="0.0001"} 0`, lines[2])
+	assert.Contains(t, lines, `test_seconds_bucket{stage="b",le="0.0025"} 1`)
+	assert.Contains(t, lines, `test_seconds_bucket{stage="b",le="+Inf"} 1`)
+	assert.Contains(t, lines, `test_seconds_sum{stage="b"} 0.002`)
+	assert.Equal(t, `test_seconds_count{stage="b"} 1`, lines[len(lines)-1])
+}
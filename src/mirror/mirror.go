@@ -0,0 +1,236 @@
+// Package mirror keeps the current mempool in memory,
+// so that live views are served without querying the database.
+package mirror
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Mirror is the in-memory current mempool with an index of the feerate buckets
+// and an index ordered by feerate. It is safe for concurrent use.
+type Mirror struct {
+	mu  sync.RWMutex
+	txs map[types.Hash32]*types.Transaction
+	// aggregates per feerate bucket, updated with every change
+	feerates map[int]*types.FeerateBucket
+	// transactions in descending feerate order
+	byFeerate *skiplist
+	weight    int64
+	fees      uint64
+	// transactions removed by recent blocks that are not final yet, see Confirm
+	confirmed []confirmedBlock
+}
+
+// confirmedBlock holds the transactions a block removed from the mempool until the block is final
+type confirmedBlock struct {
+	hash   types.Hash32
+	height uint32
+	txs    []types.Transaction
+}
+
+// New returns an empty Mirror
+func New() *Mirror {
+	return &Mirror{
+		txs:       map[types.Hash32]*types.Transaction{},
+		feerates:  map[int]*types.FeerateBucket{},
+		byFeerate: newSkiplist(),
+	}
+}
+
+func (m *Mirror) add(tx types.Transaction) {
+	if _, ok := m.txs[tx.TxID]; ok {
+		m.remove(tx.TxID)
+	}
+	m.txs[tx.TxID] = &tx
+	m.byFeerate.insert(&tx)
+	m.weight += int64(tx.Weight)
+	m.fees += tx.Fee
+
+	feerate := tx.FeerateBucket()
+	bucket, ok := m.feerates[feerate]
+	if !ok {
+		bucket = &types.FeerateBucket{Feerate: feerate}
+		m.feerates[feerate] = bucket
+	}
+	bucket.Transactions++
+	bucket.Weight += int64(tx.Weight)
+}
+
+func (m *Mirror) remove(txid types.Hash32) {
+	tx, ok := m.txs[txid]
+	if !ok {
+		return
+	}
+	delete(m.txs, txid)
+	m.byFeerate.remove(tx)
+	m.weight -= int64(tx.Weight)
+	m.fees -= tx.Fee
+
+	feerate := tx.FeerateBucket()
+	bucket := m.feerates[feerate]
+	bucket.Transactions--
+	bucket.Weight -= int64(tx.Weight)
+	if bucket.Transactions == 0 {
+		delete(m.feerates, feerate)
+	}
+}
+
+// Add adds or replaces transactions
+func (m *Mirror) Add(txs ...types.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tx := range txs {
+		m.add(tx)
+	}
+}
+
+// Remove removes the transactions with `txids`, e.g. after they were confirmed or expired.
+// Unknown txids are ignored.
+func (m *Mirror) Remove(txids ...types.Hash32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, txid := range txids {
+		m.remove(txid)
+	}
+}
+
+// Confirm removes the transactions with `txids` confirmed by the block `hash` at `height` and keeps
+// them until the block is final (see Finalize), so that Disconnect can return them after a reorg.
+func (m *Mirror) Confirm(hash types.Hash32, height uint32, txids ...types.Hash32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	block := confirmedBlock{hash: hash, height: height}
+	for _, txid := range txids {
+		if tx, ok := m.txs[txid]; ok {
+			block.txs = append(block.txs, *tx)
+			m.remove(txid)
+		}
+	}
+	m.confirmed = append(m.confirmed, block)
+}
+
+// Disconnect returns the transactions confirmed by the block `hash` to the mempool.
+// Returns false if the block is not kept, e.g. because it was already final.
+func (m *Mirror) Disconnect(hash types.Hash32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, block := range m.confirmed {
+		if block.hash != hash {
+			continue
+		}
+		for _, tx := range block.txs {
+			m.add(tx)
+		}
+		m.confirmed = append(m.confirmed[:i], m.confirmed[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// Finalize drops the transactions of the blocks at `height` or below, which can no longer be disconnected
+func (m *Mirror) Finalize(height uint32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.confirmed[:0]
+	for _, block := range m.confirmed {
+		if block.height > height {
+			kept = append(kept, block)
+		}
+	}
+	m.confirmed = kept
+}
+
+// Unfinalized returns the number of blocks whose transactions are kept for Disconnect
+func (m *Mirror) Unfinalized() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.confirmed)
+}
+
+// Reset replaces the content of the mirror with `txs`
+func (m *Mirror) Reset(txs []types.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txs = make(map[types.Hash32]*types.Transaction, len(txs))
+	m.feerates = map[int]*types.FeerateBucket{}
+	m.byFeerate = newSkiplist()
+	m.weight = 0
+	m.fees = 0
+	m.confirmed = nil
+	for _, tx := range txs {
+		m.add(tx)
+	}
+}
+
+// Get returns the transaction with `txid`
+func (m *Mirror) Get(txid types.Hash32) (types.Transaction, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tx, ok := m.txs[txid]
+	if !ok {
+		return types.Transaction{}, false
+	}
+	return *tx, true
+}
+
+// Len returns the number of transactions
+func (m *Mirror) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.txs)
+}
+
+// Transactions returns all transactions in no particular order
+func (m *Mirror) Transactions() []types.Transaction {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]types.Transaction, 0, len(m.txs))
+	for _, tx := range m.txs {
+		res = append(res, *tx)
+	}
+	return res
+}
+
+// Top returns the transactions with the highest feerate that fit into `maxWeight`,
+// in descending feerate order, like types.TopByFeerate.
+// Takes time proportional to the number of returned transactions.
+//
+// Transactions are ordered by their own feerate. The mirror does not track dependencies,
+// so a child paying for its parent (CPFP) is not combined with the parent.
+func (m *Mirror) Top(maxWeight int64) []types.Transaction {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := []types.Transaction{}
+	weight := int64(0)
+	m.byFeerate.each(func(tx *types.Transaction) bool {
+		weight += int64(tx.Weight)
+		if weight > maxWeight {
+			return false
+		}
+		res = append(res, *tx)
+		return true
+	})
+	return res
+}
+
+// Summary returns the aggregates of the current mempool.
+// Only reads the feerate index, not the transactions.
+func (m *Mirror) Summary() *types.MempoolSummary {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := &types.MempoolSummary{
+		Time:         time.Now().UTC(),
+		Transactions: len(m.txs),
+		Weight:       m.weight,
+		Fees:         m.fees,
+		Feerates:     make([]types.FeerateBucket, 0, len(m.feerates)),
+	}
+	for _, bucket := range m.feerates {
+		res.Feerates = append(res.Feerates, *bucket)
+	}
+	types.SortFeerateBuckets(res.Feerates)
+	return res
+}
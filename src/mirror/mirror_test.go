@@ -0,0 +1,112 @@
+package mirror
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestMirror(t *testing.T) {
+	tx := func(id string, fee uint64, weight int) types.Transaction {
+		return types.Transaction{TxID: test.GenerateHash32(id), Fee: fee, Weight: weight}
+	}
+	txs := []types.Transaction{
+		tx("a", 1000, 400), // 10 sat/vB
+		tx("b", 1050, 400), // 10.5 sat/vB
+		tx("c", 200, 400),  // 2 sat/vB
+	}
+
+	m := New()
+	m.Add(txs...)
+	assert.Equal(t, 3, m.Len())
+
+	got, ok := m.Get(txs[1].TxID)
+	assert.True(t, ok)
+	assert.Equal(t, txs[1], got)
+
+	summary := m.Summary()
+	assert.Equal(t, types.NewMempoolSummary(summary.Time, txs), summary)
+	assert.Equal(t, []types.FeerateBucket{
+		{Feerate: 2, Transactions: 1, Weight: 400},
+		{Feerate: 10, Transactions: 2, Weight: 800},
+	}, summary.Feerates)
+
+	// replacing a transaction updates the index
+	m.Add(tx("c", 400, 400))
+	m.Remove(txs[0].TxID, test.GenerateHash32("unknown"))
+	summary = m.Summary()
+	assert.Equal(t, 2, summary.Transactions)
+	assert.Equal(t, uint64(1450), summary.Fees)
+	assert.Equal(t, []types.FeerateBucket{
+		{Feerate: 4, Transactions: 1, Weight: 400},
+		{Feerate: 10, Transactions: 1, Weight: 400},
+	}, summary.Feerates)
+
+	m.Reset(txs[:1])
+	assert.Equal(t, 1, m.Len())
+	assert.Len(t, m.Transactions(), 1)
+	assert.WithinDuration(t, time.Now(), m.Summary().Time, time.Minute)
+}
+
+func TestMirror_Top(t *testing.T) {
+	m := New()
+	var txs []types.Transaction
+	for i := 0; i < 500; i++ {
+		tx := types.Transaction{
+			TxID:   test.GenerateHash32(fmt.Sprintf("tx-%d", i)),
+			Fee:    uint64((i * 7919) % 1000),
+			Weight: 400 + (i%5)*100,
+		}
+		txs = append(txs, tx)
+	}
+	m.Add(txs...)
+
+	for _, maxWeight := range []int64{0, 1000, 50000, types.MaxBlockWeight} {
+		assert.Equal(t, types.TopByFeerate(txs, maxWeight), m.Top(maxWeight), "maxWeight=%d", maxWeight)
+	}
+
+	// removals and replacements keep the order
+	m.Remove(txs[0].TxID, txs[1].TxID)
+	replaced := txs[2]
+	replaced.Fee = 5000
+	m.Add(replaced)
+	expected := append([]types.Transaction{replaced}, txs[3:]...)
+	assert.Equal(t, types.TopByFeerate(expected, 50000), m.Top(50000))
+
+	m.Reset(nil)
+	assert.Empty(t, m.Top(types.MaxBlockWeight))
+}
+
+func TestMirror_Confirm(t *testing.T) {
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("a"), Fee: 1000, Weight: 400},
+		{TxID: test.GenerateHash32("b"), Fee: 2000, Weight: 400},
+		{TxID: test.GenerateHash32("c"), Fee: 3000, Weight: 400},
+	}
+	m := New()
+	m.Add(txs...)
+
+	m.Confirm(test.GenerateHash32("block-1"), 1, txs[0].TxID, test.GenerateHash32("unknown"))
+	m.Confirm(test.GenerateHash32("block-2"), 2, txs[1].TxID)
+	assert.Equal(t, 1, m.Len())
+	assert.Equal(t, 2, m.Unfinalized())
+
+	// a reorg disconnects block-2
+	assert.True(t, m.Disconnect(test.GenerateHash32("block-2")))
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, uint64(5000), m.Summary().Fees)
+	assert.False(t, m.Disconnect(test.GenerateHash32("block-2")))
+
+	m.Finalize(1)
+	assert.Equal(t, 0, m.Unfinalized())
+	assert.False(t, m.Disconnect(test.GenerateHash32("block-1")))
+
+	m.Confirm(test.GenerateHash32("block-2b"), 2, txs[2].TxID)
+	m.Reset(txs)
+	assert.Equal(t, 0, m.Unfinalized())
+}
@@ -0,0 +1,93 @@
+package mirror
+
+import (
+	"math/rand"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// maxLevel bounds the height of the skiplist, enough for 2^maxLevel transactions
+const maxLevel = 24
+
+type node struct {
+	tx   *types.Transaction
+	next []*node
+}
+
+// skiplist orders transactions by descending feerate (types.Transaction.HigherFeerate).
+// Insert and remove take O(log n), iterating the first k transactions takes O(k).
+type skiplist struct {
+	head  node
+	level int
+	rand  *rand.Rand
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  node{next: make([]*node, maxLevel)},
+		level: 1,
+		rand:  rand.New(rand.NewSource(1)),
+	}
+}
+
+func (s *skiplist) randomLevel() int {
+	level := 1
+	for level < maxLevel && s.rand.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// predecessors returns the last node before `tx` on every level
+func (s *skiplist) predecessors(tx *types.Transaction) (update [maxLevel]*node) {
+	n := &s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for n.next[i] != nil && n.next[i].tx.HigherFeerate(tx) {
+			n = n.next[i]
+		}
+		update[i] = n
+	}
+	return update
+}
+
+// insert adds `tx`, which must not be in the list
+func (s *skiplist) insert(tx *types.Transaction) {
+	update := s.predecessors(tx)
+	level := s.randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = &s.head
+		}
+		s.level = level
+	}
+
+	n := &node{tx: tx, next: make([]*node, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+}
+
+// remove removes the transaction with the txid and feerate of `tx`
+func (s *skiplist) remove(tx *types.Transaction) {
+	update := s.predecessors(tx)
+	n := update[0].next[0]
+	if n == nil || n.tx.TxID != tx.TxID {
+		return
+	}
+	for i := 0; i < len(n.next); i++ {
+		update[i].next[i] = n.next[i]
+	}
+	for s.level > 1 && s.head.next[s.level-1] == nil {
+		s.level--
+	}
+}
+
+// each calls `f` with the transactions in descending feerate order until `f` returns false
+func (s *skiplist) each(f func(tx *types.Transaction) bool) {
+	for n := s.head.next[0]; n != nil; n = n.next[0] {
+		if !f(n.tx) {
+			return
+		}
+	}
+}
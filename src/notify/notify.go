@@ -0,0 +1,122 @@
+// Package notify delivers notifications about watched transactions to external services.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event types of a Notification
+const (
+	// EventMempool is sent when a watched transaction enters the mempool
+	EventMempool = "mempool"
+	// EventConfirmed is sent when a watched transaction is included in a block
+	EventConfirmed = "confirmed"
+	// EventRebroadcast is sent when a watched transaction expired and is rebroadcast
+	EventRebroadcast = "rebroadcast"
+)
+
+// Notification describes an event of a watched transaction
+type Notification struct {
+	Time time.Time `json:"time"`
+	// Name of the watch list entry that matched
+	Watch string `json:"watch"`
+	Event string `json:"event"`
+	// Txid in RPC byte order, as shown by block explorers
+	TxID string `json:"txid"`
+	// Human-readable description of the event
+	Text string `json:"text"`
+}
+
+// Notifier delivers notifications to a sink
+type Notifier interface {
+	Notify(n *Notification) error
+}
+
+// Sink types of SinkConfig
+const (
+	SinkWebhook  = "webhook"
+	SinkTelegram = "telegram"
+	SinkMatrix   = "matrix"
+)
+
+// SinkConfig configures a Notifier
+type SinkConfig struct {
+	// One of webhook, telegram, matrix
+	Type string `json:"type"`
+	// Webhook URL, Matrix homeserver URL or Telegram Bot API URL (default https://api.telegram.org)
+	URL string `json:"url"`
+	// Telegram bot token or Matrix access token
+	Token string `json:"token"`
+	// Telegram chat
+	ChatID string `json:"chatId"`
+	// Matrix room id
+	Room string `json:"room"`
+}
+
+// New returns the Notifier for `config`
+func New(config SinkConfig) (Notifier, error) {
+	switch config.Type {
+	case SinkWebhook:
+		if config.URL == "" {
+			return nil, errors.New("webhook: url is required")
+		}
+		return &Webhook{URL: config.URL}, nil
+	case SinkTelegram:
+		if config.Token == "" || config.ChatID == "" {
+			return nil, errors.New("telegram: token and chatId are required")
+		}
+		apiURL := config.URL
+		if apiURL == "" {
+			apiURL = defaultTelegramURL
+		}
+		return &Telegram{APIURL: apiURL, Token: config.Token, ChatID: config.ChatID}, nil
+	case SinkMatrix:
+		if config.URL == "" || config.Token == "" || config.Room == "" {
+			return nil, errors.New("matrix: url, token and room are required")
+		}
+		return &Matrix{Homeserver: config.URL, Token: config.Token, Room: config.Room}, nil
+	default:
+		return nil, errors.Errorf("unknown sink type %q", config.Type)
+	}
+}
+
+// requestTimeout limits the time of a single delivery
+const requestTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// sendJSON sends `body` encoded as JSON and returns an error unless the response status is 2xx
+func sendJSON(method, url string, header http.Header, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("%s: status %d: %s", req.URL.Host, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
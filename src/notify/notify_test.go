@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	method string
+	path   string
+	auth   string
+	body   map[string]interface{}
+}
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests <- request{r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), body}
+		w.WriteHeader(status)
+	}))
+	return server, requests
+}
+
+func TestNotifiers(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusOK)
+	defer server.Close()
+
+	n := &Notification{
+		Time:  time.Unix(100, 0).UTC(),
+		Watch: "exchange",
+		Event: EventMempool,
+		TxID:  strings.Repeat("ab", 32),
+		Text:  "tx entered the mempool",
+	}
+
+	webhook, err := New(SinkConfig{Type: SinkWebhook, URL: server.URL + "/hook"})
+	require.NoError(t, err)
+	require.NoError(t, webhook.Notify(n))
+	req := <-requests
+	assert.Equal(t, http.MethodPost, req.method)
+	assert.Equal(t, "/hook", req.path)
+	assert.Equal(t, "exchange", req.body["watch"])
+	assert.Equal(t, n.TxID, req.body["txid"])
+
+	telegram, err := New(SinkConfig{Type: SinkTelegram, URL: server.URL, Token: "123:abc", ChatID: "42"})
+	require.NoError(t, err)
+	require.NoError(t, telegram.Notify(n))
+	req = <-requests
+	assert.Equal(t, "/bot123:abc/sendMessage", req.path)
+	assert.Equal(t, map[string]interface{}{"chat_id": "42", "text": n.Text}, req.body)
+
+	matrix, err := New(SinkConfig{Type: SinkMatrix, URL: server.URL, Token: "secret", Room: "!room:example.org"})
+	require.NoError(t, err)
+	require.NoError(t, matrix.Notify(n))
+	req = <-requests
+	assert.Equal(t, http.MethodPut, req.method)
+	assert.True(t, strings.HasPrefix(req.path, "/_matrix/client/r0/rooms/%21room:example.org/send/m.room.message/"), req.path)
+	assert.Equal(t, "Bearer secret", req.auth)
+	assert.Equal(t, map[string]interface{}{"msgtype": "m.text", "body": n.Text}, req.body)
+
+	_, err = New(SinkConfig{Type: SinkTelegram, Token: "123:abc"})
+	assert.Error(t, err)
+	_, err = New(SinkConfig{Type: "email"})
+	assert.Error(t, err)
+}
+
+func TestNotifiers_Error(t *testing.T) {
+	server, requests := newTestServer(t, http.StatusForbidden)
+	defer server.Close()
+
+	telegram, err := New(SinkConfig{Type: SinkTelegram, URL: server.URL, Token: "123:abc", ChatID: "42"})
+	require.NoError(t, err)
+	err = telegram.Notify(&Notification{Text: "x"})
+	<-requests
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.NotContains(t, err.Error(), "123:abc")
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Webhook posts the notification as JSON to URL
+type Webhook struct {
+	URL string
+}
+
+// Notify implements Notifier
+func (w *Webhook) Notify(n *Notification) error {
+	return sendJSON(http.MethodPost, w.URL, nil, n)
+}
+
+const defaultTelegramURL = "https://api.telegram.org"
+
+// Telegram sends the notification text to a chat via the Telegram Bot API
+type Telegram struct {
+	APIURL string
+	Token  string
+	ChatID string
+}
+
+// Notify implements Notifier
+func (t *Telegram) Notify(n *Notification) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(t.APIURL, "/"), t.Token)
+	err := sendJSON(http.MethodPost, endpoint, nil, map[string]string{
+		"chat_id": t.ChatID,
+		"text":    n.Text,
+	})
+	if err != nil {
+		// the URL contains the token
+		return errors.Errorf("telegram: %s", strings.Replace(err.Error(), t.Token, "<token>", -1))
+	}
+	return nil
+}
+
+// matrixTxnCounter makes Matrix transaction ids unique within the process
+var matrixTxnCounter uint64
+
+// Matrix sends the notification text to a room via the Matrix client-server API
+type Matrix struct {
+	Homeserver string
+	Token      string
+	Room       string
+}
+
+// Notify implements Notifier
+func (m *Matrix) Notify(n *Notification) error {
+	// the transaction id lets the homeserver deduplicate retried requests
+	txnID := fmt.Sprintf("bademeister-%d-%d", time.Now().UnixNano(), atomic.AddUint64(&matrixTxnCounter, 1))
+	endpoint := fmt.Sprintf(
+		"%s/_matrix/client/r0/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.Room), txnID,
+	)
+	header := http.Header{"Authorization": []string{"Bearer " + m.Token}}
+	return sendJSON(http.MethodPut, endpoint, header, map[string]string{
+		"msgtype": "m.text",
+		"body":    n.Text,
+	})
+}
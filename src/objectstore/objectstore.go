@@ -0,0 +1,211 @@
+// Package objectstore writes files to a local directory or an object storage bucket.
+package objectstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Object describes a stored object
+type Object struct {
+	// Name without the prefix of the store
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+// Store writes named objects
+type Store interface {
+	// Put writes `size` bytes read from `r` to the object `name`, replacing an existing object
+	Put(name string, r io.Reader, size int64) error
+	// List returns the objects whose name starts with `prefix`, sorted by name
+	List(prefix string) ([]Object, error)
+	// Delete removes the object `name`. Deleting a missing object is not an error.
+	Delete(name string) error
+}
+
+// Store types of Config
+const (
+	TypeDir = "dir"
+	TypeS3  = "s3"
+	TypeGCS = "gcs"
+)
+
+// gcsEndpoint is the XML API of Google Cloud Storage, which accepts S3 requests signed with HMAC keys
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// Config configures a Store
+type Config struct {
+	// One of dir, s3, gcs
+	Type string `json:"type"`
+	// Directory of a dir store
+	Dir string `json:"dir"`
+	// Bucket of an s3 or gcs store
+	Bucket string `json:"bucket"`
+	// Prepended to object names, e.g. "bademeister/"
+	Prefix string `json:"prefix"`
+	// Region of an s3 store (default us-east-1)
+	Region string `json:"region"`
+	// Endpoint of an S3-compatible service (default https://s3.<region>.amazonaws.com)
+	Endpoint string `json:"endpoint"`
+	// Credentials of an s3 store (default $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY),
+	// or the HMAC key of a gcs store
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+}
+
+// New returns the Store for `config`
+func New(config Config) (Store, error) {
+	switch config.Type {
+	case TypeDir:
+		if config.Dir == "" {
+			return nil, errors.New("dir: dir is required")
+		}
+		return &Dir{Path: config.Dir, Prefix: config.Prefix}, nil
+	case TypeS3:
+		if config.Bucket == "" {
+			return nil, errors.New("s3: bucket is required")
+		}
+		s := &S3{
+			Endpoint:  config.Endpoint,
+			Region:    config.Region,
+			Bucket:    config.Bucket,
+			Prefix:    config.Prefix,
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+		}
+		if s.Region == "" {
+			s.Region = defaultS3Region
+		}
+		if s.Endpoint == "" {
+			s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+		}
+		if s.AccessKey == "" && s.SecretKey == "" {
+			s.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			s.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("s3: accessKey and secretKey are required")
+		}
+		return s, nil
+	case TypeGCS:
+		if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+			return nil, errors.New("gcs: bucket, accessKey and secretKey are required")
+		}
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = gcsEndpoint
+		}
+		return &S3{
+			Endpoint:  endpoint,
+			Region:    "auto",
+			Bucket:    config.Bucket,
+			Prefix:    config.Prefix,
+			AccessKey: config.AccessKey,
+			SecretKey: config.SecretKey,
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown store type %q", config.Type)
+	}
+}
+
+// Dir writes objects as files below Path
+type Dir struct {
+	Path   string
+	Prefix string
+}
+
+// path returns the file of the object `name`
+func (d *Dir) path(name string) (string, error) {
+	path := filepath.Join(d.Path, filepath.FromSlash(d.Prefix+name))
+	if rel, err := filepath.Rel(d.Path, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", errors.Errorf("invalid object name %q", name)
+	}
+	return path, nil
+}
+
+// Put implements Store. The file is replaced atomically, readers never see a partial object.
+func (d *Dir) Put(name string, r io.Reader, size int64) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "could not create directory")
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "could not create file")
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, r)
+	if err == nil && n != size {
+		err = errors.Errorf("wrote %d of %d bytes", n, size)
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not write %s", path)
+	}
+
+	return errors.Wrapf(os.Rename(f.Name(), path), "could not write %s", path)
+}
+
+// List implements Store
+func (d *Dir) List(prefix string) ([]Object, error) {
+	res := []Object{}
+	err := filepath.Walk(d.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Path, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, d.Prefix+prefix) {
+			res = append(res, Object{
+				Name:     strings.TrimPrefix(name, d.Prefix),
+				Size:     info.Size(),
+				Modified: info.ModTime().UTC(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list %s", d.Path)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// Delete implements Store
+func (d *Dir) Delete(name string) error {
+	path, err := d.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not delete %s", path)
+	}
+	return nil
+}
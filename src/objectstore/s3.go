@@ -0,0 +1,357 @@
+package objectstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultS3Region = "us-east-1"
+
+// unsignedPayload is used as payload hash, the body is not hashed before sending
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// requestTimeout limits the time of a single request. Uploads of large files may take a while.
+const requestTimeout = time.Hour
+
+// S3 rejects single PUTs over 5 GB and multipart uploads of more than 10000 parts
+const (
+	defaultPartSize = 64 << 20
+	maxParts        = 10000
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// S3 writes objects to a bucket of an S3-compatible service.
+// Requests use path-style URLs (Endpoint/Bucket/Key) and are signed with AWS Signature Version 4.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Objects larger than PartSize are uploaded in parts of this size (default 64 MiB),
+	// or larger parts if the object would need more than 10000 parts
+	PartSize int64
+}
+
+// Put implements Store
+func (s *S3) Put(name string, r io.Reader, size int64) error {
+	partSize := s.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	if size > partSize {
+		if minSize := (size + maxParts - 1) / maxParts; partSize < minSize {
+			partSize = minSize
+		}
+		return s.putMultipart(name, r, size, partSize)
+	}
+
+	req, err := s.newRequest(http.MethodPut, s.Prefix+name, nil, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	setContentType(req, name)
+
+	_, _, err = s.do(req)
+	return err
+}
+
+// setContentType sets the content type of the object `name` by its extension, if known
+func setContentType(req *http.Request, name string) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+}
+
+// initiateMultipartUploadResult is the response of CreateMultipartUpload
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// completedPart is a part of the request of CompleteMultipartUpload
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// completeMultipartUpload is the request of CompleteMultipartUpload
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// putMultipart writes `size` bytes read from `r` to the object `name` in parts of `partSize` bytes
+// with a multipart upload. The upload is aborted on errors, so no parts are left behind.
+func (s *S3) putMultipart(name string, r io.Reader, size, partSize int64) error {
+	key := s.Prefix + name
+	req, err := s.newRequest(http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	setContentType(req, name)
+	body, _, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal(body, &initiated); err != nil || initiated.UploadID == "" {
+		return errors.Errorf("s3: could not parse the upload id of %s", key)
+	}
+
+	if err := s.uploadParts(key, initiated.UploadID, r, size, partSize); err != nil {
+		if req, errAbort := s.newRequest(
+			http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil,
+		); errAbort == nil {
+			_, _, _ = s.do(req)
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts uploads the parts of the multipart upload `uploadID` and completes it
+func (s *S3) uploadParts(key, uploadID string, r io.Reader, size, partSize int64) error {
+	var complete completeMultipartUpload
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+partSize, number+1 {
+		n := size - offset
+		if n > partSize {
+			n = partSize
+		}
+		query := url.Values{"partNumber": {fmt.Sprint(number)}, "uploadId": {uploadID}}
+		req, err := s.newRequest(http.MethodPut, key, query, io.LimitReader(r, n))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = n
+		_, header, err := s.do(req)
+		if err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	}
+
+	data, err := xml.Marshal(complete)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := s.newRequest(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	body, _, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	// errors during the completion are reported with status 200
+	if bytes.Contains(body, []byte("<Error>")) {
+		return errors.Errorf("s3: could not complete the upload of %s: %s", key, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// listBucketResult is the response of ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List implements Store
+func (s *S3) List(prefix string) ([]Object, error) {
+	res := []Object{}
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+	for {
+		req, err := s.newRequest(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, _, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, errors.Wrap(err, "s3: could not parse list response")
+		}
+		for _, c := range result.Contents {
+			res = append(res, Object{
+				Name:     strings.TrimPrefix(c.Key, s.Prefix),
+				Size:     c.Size,
+				Modified: c.LastModified.UTC(),
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// Delete implements Store
+func (s *S3) Delete(name string) error {
+	req, err := s.newRequest(http.MethodDelete, s.Prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.do(req)
+	return err
+}
+
+// newRequest returns a request for the object `key`, or the bucket if `key` is empty
+func (s *S3) newRequest(method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "s3: invalid endpoint")
+	}
+	u.Path += "/" + s.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	return http.NewRequest(method, u.String(), body)
+}
+
+// do signs and sends `req` and returns the response body and headers.
+// Returns an error unless the response status is 2xx.
+func (s *S3) do(req *http.Request) ([]byte, http.Header, error) {
+	s.sign(req, unsignedPayload, time.Now())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "s3")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "s3")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		return nil, nil, errors.Errorf("s3: %s %s: status %d: %s",
+			req.Method, req.URL.Path, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return body, resp.Header, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization header to `req`.
+// All headers set at this point are signed.
+func (s *S3) sign(req *http.Request, payloadHash string, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", amzDate[:8], s.Region)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n")
+	canonical.WriteString(uriEncode(req.URL.Path, false) + "\n")
+	canonical.WriteString(canonicalQuery(req.URL.Query()) + "\n")
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + payloadHash)
+
+	hash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), amzDate[:8])
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent-encodes all bytes except unreserved characters, and `/` unless `slash` is set
+func uriEncode(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery returns `query` encoded with sorted keys
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,91 @@
+// Package privacy implements the data minimization mode of the daemon.
+//
+// In this mode, txids are replaced by salted short hashes before they are stored
+// and inputs/outputs and serialized transactions are dropped. The database still supports mempool reconstruction
+// and aggregate statistics, but the stored transactions cannot be linked to the chain
+// without the salt.
+package privacy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ShortHashSize is the number of bytes of the salted hash that are kept.
+// The remaining bytes of the types.Hash32 are zero.
+const ShortHashSize = 8
+
+// SaltSize is the size of generated salts
+const SaltSize = 32
+
+// TxIDHasher replaces txids with salted short hashes
+type TxIDHasher struct {
+	salt []byte
+}
+
+// NewTxIDHasher returns a TxIDHasher using `salt`
+func NewTxIDHasher(salt []byte) *TxIDHasher {
+	return &TxIDHasher{salt: salt}
+}
+
+// LoadOrCreateSalt reads the salt from `path`.
+// If the file does not exist, a random salt is generated and written to `path`.
+func LoadOrCreateSalt(path string) ([]byte, error) {
+	salt, err := ioutil.ReadFile(path)
+	if err == nil {
+		if len(salt) == 0 {
+			return nil, errors.Errorf("empty salt file %s", path)
+		}
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "could not read salt file")
+	}
+
+	salt = make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "could not generate salt")
+	}
+	if err := ioutil.WriteFile(path, salt, 0600); err != nil {
+		return nil, errors.Wrap(err, "could not write salt file")
+	}
+	return salt, nil
+}
+
+// Hash returns the salted short hash of `txid`
+func (h *TxIDHasher) Hash(txid types.Hash32) types.Hash32 {
+	digest := sha256.New()
+	digest.Write(h.salt)
+	digest.Write(txid[:])
+
+	var res types.Hash32
+	copy(res[:ShortHashSize], digest.Sum(nil))
+	return res
+}
+
+// Transaction replaces the txid of `tx` and drops its inputs, outputs and serialization
+func (h *TxIDHasher) Transaction(tx *types.Transaction) {
+	tx.TxID = h.Hash(tx.TxID)
+	if tx.WTxID != nil {
+		// hashed as well, so witness replacements are still detected
+		wtxid := h.Hash(*tx.WTxID)
+		tx.WTxID = &wtxid
+	}
+	tx.Details = nil
+	tx.Raw = nil
+}
+
+// Block replaces the txids of the transactions in `block`
+func (h *TxIDHasher) Block(block *types.Block) {
+	txids := make([]types.Hash32, len(block.TxIDs))
+	for i, txid := range block.TxIDs {
+		txids[i] = h.Hash(txid)
+	}
+	block.TxIDs = txids
+}
@@ -0,0 +1,62 @@
+package privacy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestTxIDHasher(t *testing.T) {
+	txid := test.GenerateHash32("tx")
+	a := NewTxIDHasher([]byte("salt-a"))
+	b := NewTxIDHasher([]byte("salt-b"))
+
+	assert.Equal(t, a.Hash(txid), a.Hash(txid))
+	assert.NotEqual(t, a.Hash(txid), b.Hash(txid))
+
+	var zero [32 - ShortHashSize]byte
+	h := a.Hash(txid)
+	assert.Equal(t, zero[:], h[ShortHashSize:])
+
+	tx := types.Transaction{TxID: txid, Details: &types.TxDetails{}}
+	a.Transaction(&tx)
+	assert.Equal(t, h, tx.TxID)
+	assert.Nil(t, tx.Details)
+
+	block := types.Block{TxIDs: []types.Hash32{txid}}
+	a.Block(&block)
+	assert.Equal(t, []types.Hash32{h}, block.TxIDs)
+}
+
+func TestLoadOrCreateSalt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "privacy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "salt")
+	salt, err := LoadOrCreateSalt(path)
+	require.NoError(t, err)
+	assert.Len(t, salt, SaltSize)
+
+	loaded, err := LoadOrCreateSalt(path)
+	require.NoError(t, err)
+	assert.Equal(t, salt, loaded)
+}
+
+func TestNodePseudonymizer(t *testing.T) {
+	a := NewNodePseudonymizer([]byte("key-a"))
+	b := NewNodePseudonymizer([]byte("key-b"))
+
+	p := a.Pseudonym("203.0.113.5:8333")
+	assert.Equal(t, p, a.Pseudonym("203.0.113.5:8333"))
+	assert.NotEqual(t, p, a.Pseudonym("203.0.113.6:8333"))
+	assert.NotEqual(t, p, b.Pseudonym("203.0.113.5:8333"))
+	assert.Regexp(t, "^node-[0-9a-f]{16}$", p)
+}
@@ -0,0 +1,33 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// PseudonymPrefix starts the pseudonyms of NodePseudonymizer
+const PseudonymPrefix = "node-"
+
+// pseudonymSize is the number of bytes of the HMAC in a pseudonym
+const pseudonymSize = 8
+
+// NodePseudonymizer replaces node identifiers, e.g. addresses used as source labels, with stable
+// pseudonyms, so datasets of several nodes can be shared without exposing their addresses.
+// The pseudonym of an identifier is the same for all exports with the same key, and cannot be
+// reversed or recomputed from a known address without the key.
+type NodePseudonymizer struct {
+	key []byte
+}
+
+// NewNodePseudonymizer returns a NodePseudonymizer with the secret `key`, e.g. from LoadOrCreateSalt
+func NewNodePseudonymizer(key []byte) *NodePseudonymizer {
+	return &NodePseudonymizer{key: key}
+}
+
+// Pseudonym returns `node-` followed by the first bytes of the HMAC-SHA256 of `id` in hex
+func (p *NodePseudonymizer) Pseudonym(id string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(id))
+	return PseudonymPrefix + hex.EncodeToString(mac.Sum(nil)[:pseudonymSize])
+}
@@ -0,0 +1,326 @@
+// Package scripthook runs a user-provided Starlark script on incoming transactions and blocks, so
+// data collection can be customized without Go. Starlark is a dialect of Python, see
+// https://github.com/google/starlark-go/blob/master/doc/spec.md.
+//
+// The script defines one or both of the functions
+//
+//	def transaction(tx): ...
+//	def block(block): ...
+//
+// which get a transaction or block in the JSON format of the API as dict and return None or a
+// Verdict as dict, e.g. {"drop": True, "tags": ["exchange"], "values": {"score": 0.8}}.
+// The predeclared dict `state` keeps values between calls, e.g. for aggregates, since the globals
+// of the script are frozen once it is loaded. `print` writes to the log.
+package scripthook
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// AnalyzerName is the analyzer of the results of a hook, see types.AnalysisResult
+const AnalyzerName = "script"
+
+// tagPrefix is prepended to tags in the result keys
+const tagPrefix = "tag:"
+
+// DefaultTimeout is the time the script has to handle a batch of transactions or a block
+const DefaultTimeout = time.Second
+
+func init() {
+	// fee rates and values are floats
+	resolve.AllowFloat = true
+}
+
+// Verdict is the answer of the script for a transaction or block
+type Verdict struct {
+	// Drop skips storing the transaction. Ignored for blocks.
+	Drop bool `json:"drop"`
+	// Tags are stored as results with the key `tag:<tag>` and value 1
+	Tags []string `json:"tags"`
+	// Values are stored as results with their key
+	Values map[string]float64 `json:"values"`
+}
+
+// Results returns the analysis results of `v`
+func (v *Verdict) Results() []types.AnalysisResult {
+	var res []types.AnalysisResult
+	for _, tag := range v.Tags {
+		res = append(res, types.AnalysisResult{Analyzer: AnalyzerName, Key: tagPrefix + tag, Value: 1})
+	}
+	for key, value := range v.Values {
+		res = append(res, types.AnalysisResult{Analyzer: AnalyzerName, Key: key, Value: value})
+	}
+	return res
+}
+
+// Hook is a loaded script. It is not safe for concurrent use.
+// After a call timed out, the script may still be running and all later calls fail.
+type Hook struct {
+	thread *starlark.Thread
+	// the functions of the script, nil if not defined
+	transaction starlark.Callable
+	block       starlark.Callable
+	timeout     time.Duration
+	timedOut    bool
+}
+
+// Load runs the script at `path`, which must define `transaction`, `block` or both
+func Load(path string, timeout time.Duration) (*Hook, error) {
+	thread := &starlark.Thread{
+		Name: "script hook",
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Infof("Script hook: %s", msg)
+		},
+	}
+	predeclared := starlark.StringDict{"state": starlark.NewDict(0)}
+	globals, err := starlark.ExecFile(thread, path, nil, predeclared)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load script hook %s", path)
+	}
+
+	h := &Hook{thread: thread, timeout: timeout}
+	if h.transaction, err = function(globals, "transaction"); err != nil {
+		return nil, err
+	}
+	if h.block, err = function(globals, "block"); err != nil {
+		return nil, err
+	}
+	if h.transaction == nil && h.block == nil {
+		return nil, errors.Errorf("script hook %s defines neither transaction nor block", path)
+	}
+	return h, nil
+}
+
+// function returns the function `name` of a script, or nil if it is not defined
+func function(globals starlark.StringDict, name string) (starlark.Callable, error) {
+	value, ok := globals[name]
+	if !ok {
+		return nil, nil
+	}
+	fn, ok := value.(starlark.Callable)
+	if !ok {
+		return nil, errors.Errorf("script hook: %s is a %s, not a function", name, value.Type())
+	}
+	return fn, nil
+}
+
+// run runs `f` and fails if it takes longer than h.timeout. Starlark has no unbounded loops, so
+// `f` ends eventually, but it is not waited for.
+func (h *Hook) run(f func() error) error {
+	if h.timedOut {
+		return errors.New("script hook timed out before")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+
+	timeout := time.NewTimer(h.timeout)
+	defer timeout.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timeout.C:
+		h.timedOut = true
+		return errors.Errorf("script hook did not finish within %s", h.timeout)
+	}
+}
+
+// call calls `fn` with `arg` and returns its verdict
+func (h *Hook) call(fn starlark.Callable, arg starlark.Value) (Verdict, error) {
+	var verdict Verdict
+	res, err := starlark.Call(h.thread, fn, starlark.Tuple{arg}, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return verdict, errors.Errorf("script hook: %s", evalErr.Backtrace())
+		}
+		return verdict, errors.Wrap(err, "script hook")
+	}
+	if res == starlark.None {
+		return verdict, nil
+	}
+	if _, ok := res.(*starlark.Dict); !ok {
+		return verdict, errors.Errorf("script hook: %s returned a %s, expected a dict or None", fn.Name(), res.Type())
+	}
+	data, err := fromStarlark(res)
+	if err != nil {
+		return verdict, err
+	}
+	if err := json.Unmarshal(data, &verdict); err != nil {
+		return verdict, errors.Wrapf(err, "invalid verdict of script hook %s", res)
+	}
+	return verdict, nil
+}
+
+// Transactions returns the verdicts of the script for `txs`
+func (h *Hook) Transactions(txs []types.Transaction) ([]Verdict, error) {
+	verdicts := make([]Verdict, len(txs))
+	if h.transaction == nil {
+		return verdicts, nil
+	}
+	// converted before, a call that times out must not read `txs` later
+	args := make([]starlark.Value, len(txs))
+	for i := range txs {
+		var err error
+		if args[i], err = toStarlark(&txs[i]); err != nil {
+			return nil, err
+		}
+	}
+	err := h.run(func() error {
+		for i, arg := range args {
+			verdict, err := h.call(h.transaction, arg)
+			if err != nil {
+				return err
+			}
+			verdicts[i] = verdict
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return verdicts, nil
+}
+
+// Block returns the verdict of the script for `block`
+func (h *Hook) Block(block *types.Block) (Verdict, error) {
+	var verdict Verdict
+	if h.block == nil {
+		return verdict, nil
+	}
+	arg, err := toStarlark(block)
+	if err != nil {
+		return verdict, err
+	}
+	err = h.run(func() error {
+		var err error
+		verdict, err = h.call(h.block, arg)
+		return err
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+	return verdict, nil
+}
+
+// Close releases the script. Calls that timed out are not stopped.
+func (h *Hook) Close() error {
+	h.transaction = nil
+	h.block = nil
+	return nil
+}
+
+// toStarlark returns `v` in the JSON format as Starlark value
+func toStarlark(v interface{}) (starlark.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "script hook")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, errors.Wrap(err, "script hook")
+	}
+	return jsonToStarlark(decoded), nil
+}
+
+func jsonToStarlark(v interface{}) starlark.Value {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			// cannot fail for string keys
+			_ = dict.SetKey(starlark.String(key), jsonToStarlark(v[key]))
+		}
+		return dict
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			elems[i] = jsonToStarlark(elem)
+		}
+		return starlark.NewList(elems)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(i)
+		}
+		f, _ := v.Float64()
+		return starlark.Float(f)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	default:
+		return starlark.None
+	}
+}
+
+// fromStarlark returns the JSON encoding of the Starlark value `v`
+func fromStarlark(v starlark.Value) ([]byte, error) {
+	decoded, err := starlarkToJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+func starlarkToJSON(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, errors.Errorf("script hook: integer %s out of range", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.Dict:
+		res := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, errors.Errorf("script hook: dict key %s is not a string", item[0])
+			}
+			value, err := starlarkToJSON(item[1])
+			if err != nil {
+				return nil, err
+			}
+			res[string(key)] = value
+		}
+		return res, nil
+	case starlark.Indexable:
+		// lists and tuples
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			value, err := starlarkToJSON(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res[i] = value
+		}
+		return res, nil
+	default:
+		return nil, errors.Errorf("script hook: cannot convert a %s", v.Type())
+	}
+}
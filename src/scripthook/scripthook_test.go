@@ -0,0 +1,103 @@
+package scripthook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// testScript drops transactions with a fee below 1000, tags the others and counts them until a
+// block
+const testScript = `
+state["seen"] = 0
+
+def transaction(tx):
+    if tx["fee"] < 1000:
+        return {"drop": True}
+    state["seen"] += 1
+    return {"tags": ["high-fee"]}
+
+def block(block):
+    if block["height"] == 99:
+        # runs longer than the timeout of the test
+        n = 0
+        for i in range(10000000):
+            n += i
+    seen = state["seen"]
+    state["seen"] = 0
+    return {"values": {"seen": float(seen)}}
+`
+
+// writeScript writes `src` to a file in `dir` and returns its path
+func writeScript(t *testing.T, dir, src string) string {
+	path := filepath.Join(dir, "hook.star")
+	require.NoError(t, ioutil.WriteFile(path, []byte(src), 0600))
+	return path
+}
+
+func TestHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripthook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	h, err := Load(writeScript(t, dir, testScript), 5*time.Second)
+	require.NoError(t, err)
+	defer h.Close()
+
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("low"), Fee: 100},
+		{TxID: test.GenerateHash32("high"), Fee: 5000},
+	}
+	verdicts, err := h.Transactions(txs)
+	require.NoError(t, err)
+	require.Len(t, verdicts, 2)
+	assert.True(t, verdicts[0].Drop)
+	assert.False(t, verdicts[1].Drop)
+	assert.Equal(t, []types.AnalysisResult{{Analyzer: AnalyzerName, Key: "tag:high-fee", Value: 1}}, verdicts[1].Results())
+
+	verdict, err := h.Block(&types.Block{Height: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []types.AnalysisResult{{Analyzer: AnalyzerName, Key: "seen", Value: 1}}, verdict.Results())
+
+	h.timeout = 10 * time.Millisecond
+	_, err = h.Block(&types.Block{Height: 99})
+	assert.Error(t, err)
+	// the hook is not used after a timeout
+	_, err = h.Block(&types.Block{Height: 1})
+	assert.Error(t, err)
+}
+
+func TestHookErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scripthook")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Load(filepath.Join(dir, "nonexistent.star"), time.Second)
+	assert.Error(t, err)
+	_, err = Load(writeScript(t, dir, "x = 1\n"), time.Second)
+	assert.Error(t, err)
+	_, err = Load(writeScript(t, dir, "transaction = 1\n"), time.Second)
+	assert.Error(t, err)
+
+	// a script with only a block function keeps all transactions
+	h, err := Load(writeScript(t, dir, "def block(block):\n    return 1\n"), time.Second)
+	require.NoError(t, err)
+	verdicts, err := h.Transactions([]types.Transaction{{Fee: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, []Verdict{{}}, verdicts)
+	_, err = h.Block(&types.Block{Height: 1})
+	assert.Error(t, err)
+
+	h, err = Load(writeScript(t, dir, "def transaction(tx):\n    return tx['missing']\n"), time.Second)
+	require.NoError(t, err)
+	_, err = h.Transactions([]types.Transaction{{Fee: 1}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DataDirAuto selects the DefaultDataDir
+const DataDirAuto = "auto"
+
+// DefaultDataDir returns the data directory of the platform: `bademeister` in the directory returned by
+// os.UserConfigDir, e.g. ~/.config on Linux, ~/Library/Application Support on macOS and %AppData% on Windows
+func DefaultDataDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not determine the data directory")
+	}
+	return filepath.Join(dir, "bademeister"), nil
+}
+
+// DataDir resolves the `-datadir` setting `v` and creates the directory. Returns "" if `v` is empty,
+// the DefaultDataDir for DataDirAuto.
+func DataDir(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	dir := v
+	if v == DataDirAuto {
+		var err error
+		if dir, err = DefaultDataDir(); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "could not create the data directory")
+	}
+	return dir, nil
+}
+
+// ResolvePath returns `path` relative to the data directory `dir`. Absolute paths, `file:` URIs
+// and empty paths are returned unchanged, as are all paths if `dir` is empty.
+func ResolvePath(dir, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "file:") {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
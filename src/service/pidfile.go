@@ -0,0 +1,41 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// WritePidFile writes the pid of the process to `path` and returns a function that removes it.
+// Fails if the file belongs to a running process, a file left by a crashed process is replaced.
+func WritePidFile(path string) (func(), error) {
+	if data, err := ioutil.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			return nil, errors.Errorf("pidfile %s: process %d is running", path, pid)
+		}
+		log.Warnf("Replacing stale pidfile %s", path)
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "could not read pidfile")
+	}
+
+	// written to a temporary file and renamed, so readers never see a partial pid
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return nil, errors.Wrap(err, "could not write pidfile")
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, errors.Wrap(err, "could not write pidfile")
+	}
+
+	return func() {
+		if err := os.Remove(path); err != nil {
+			log.Errorf("Could not remove pidfile: %s", err)
+		}
+	}, nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package service
+
+import "syscall"
+
+// processRunning returns true if a process with `pid` exists
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	// EPERM: the process exists but belongs to another user
+	return err == nil || err == syscall.EPERM
+}
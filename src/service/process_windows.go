@@ -0,0 +1,17 @@
+package service
+
+import "os"
+
+// processRunning returns true if a process with `pid` exists
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// opens a handle to the process, fails if it does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,63 @@
+// Package service integrates the daemon with service managers: readiness and watchdog notifications
+// for systemd (sd_notify) and pidfiles.
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Notification states, see sd_notify(3)
+const (
+	// StateReady is sent when the daemon finished starting up
+	StateReady = "READY=1"
+	// StateStopping is sent when the daemon begins shutting down
+	StateStopping = "STOPPING=1"
+	// StateWatchdog keeps the watchdog from restarting the daemon
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Notify sends `state` to the service manager if it set $NOTIFY_SOCKET.
+// Returns false without error if notifications are not supported.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	addr := &net.UnixAddr{Name: socket, Net: "unixgram"}
+	if socket[0] == '@' {
+		// abstract namespace
+		addr.Name = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return false, errors.Wrap(err, "could not connect to $NOTIFY_SOCKET")
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, errors.Wrap(err, "could not write to $NOTIFY_SOCKET")
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval at which the service manager expects StateWatchdog,
+// half of $WATCHDOG_USEC as recommended by sd_watchdog_enabled(3). Zero if the watchdog is disabled
+// or meant for another process.
+func WatchdogInterval() (time.Duration, error) {
+	v := os.Getenv("WATCHDOG_USEC")
+	if v == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	usec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, errors.Errorf("invalid $WATCHDOG_USEC %q", v)
+	}
+	return time.Duration(usec) * time.Microsecond / 2, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Unsetenv("NOTIFY_SOCKET")
+	ok, err := Notify(StateReady)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	ok, err = Notify(StateReady)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, StateReady, string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	interval, err := WatchdogInterval()
+	require.NoError(t, err)
+	assert.Zero(t, interval)
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	interval, err = WatchdogInterval()
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Second, interval)
+
+	os.Setenv("WATCHDOG_PID", "1")
+	interval, err = WatchdogInterval()
+	require.NoError(t, err)
+	assert.Zero(t, interval)
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("WATCHDOG_USEC", "x")
+	_, err = WatchdogInterval()
+	assert.Error(t, err)
+}
+
+func TestWritePidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bademeister.pid")
+
+	remove, err := WritePidFile(path)
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(data))
+	remove()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// running process
+	require.NoError(t, ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0644))
+	_, err = WritePidFile(path)
+	assert.Error(t, err)
+
+	// stale
+	require.NoError(t, ioutil.WriteFile(path, []byte("garbage"), 0644))
+	remove, err = WritePidFile(path)
+	require.NoError(t, err)
+	remove()
+}
+
+func TestDataDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	resolved, err := DataDir("")
+	require.NoError(t, err)
+	assert.Equal(t, "", resolved)
+
+	resolved, err = DataDir(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	info, err := os.Stat(resolved)
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	assert.Equal(t, filepath.Join(resolved, "transactions.db"), ResolvePath(resolved, "transactions.db"))
+	assert.Equal(t, resolved, ResolvePath(resolved, "."))
+	for _, path := range []string{"", "file:replica.db?mode=ro", filepath.Join(dir, "x.db")} {
+		assert.Equal(t, path, ResolvePath(resolved, path))
+	}
+	assert.Equal(t, "transactions.db", ResolvePath("", "transactions.db"))
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"container/heap"
+	"math"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// rollingFeeUpdateInterval is the minimum interval between two decays of the minimum feerate
+const rollingFeeUpdateInterval = 10 * time.Second
+
+// feerateHeapSlack is the number of entries of removed transactions that evictionSim keeps
+// in addition to the number of transactions before it rebuilds the heap
+const feerateHeapSlack = 10000
+
+// feerateEntry is an element of feerateHeap
+type feerateEntry struct {
+	dbid    int64
+	feerate float64
+}
+
+// feerateHeap is a min-heap of transactions by feerate
+type feerateHeap []feerateEntry
+
+func (h feerateHeap) Len() int            { return len(h) }
+func (h feerateHeap) Less(i, j int) bool  { return h[i].feerate < h[j].feerate }
+func (h feerateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *feerateHeap) Push(x interface{}) { *h = append(*h, x.(feerateEntry)) }
+func (h *feerateHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// evictionSim is the mempool of a node with a size limit, fed with the events of a Mempool.
+// Like the node, it evicts the transactions with the lowest feerate when the limit is exceeded
+// and raises its minimum feerate, which decays after the next block. Unlike the node, it
+// evicts single transactions, since the reconstructed mempool has no ancestor packages.
+type evictionSim struct {
+	policy types.EvictionPolicy
+	txs    map[int64]types.StoredTransaction
+	// transactions by feerate, including removed ones that are skipped when they reach the top
+	byFeerate feerateHeap
+	usage     float64
+	// rolling minimum feerate in sat/vB, see minFeerate
+	rollingFeerate float64
+	lastUpdate     time.Time
+	blockSinceBump bool
+}
+
+func newEvictionSim(policy types.EvictionPolicy) *evictionSim {
+	return &evictionSim{policy: policy, txs: map[int64]types.StoredTransaction{}}
+}
+
+func (s *evictionSim) txUsage(tx *types.StoredTransaction) float64 {
+	return float64(tx.Weight) / 4 * s.policy.UsagePerVByte
+}
+
+// minFeerate returns the minimum feerate of new transactions at `t`
+func (s *evictionSim) minFeerate(t time.Time) float64 {
+	if !s.blockSinceBump || s.rollingFeerate == 0 {
+		return s.rollingFeerate
+	}
+	if t.Sub(s.lastUpdate) > rollingFeeUpdateInterval {
+		halfLife := s.policy.HalfLife
+		if s.usage < float64(s.policy.MaxMempool)/4 {
+			halfLife /= 4
+		} else if s.usage < float64(s.policy.MaxMempool)/2 {
+			halfLife /= 2
+		}
+		s.rollingFeerate /= math.Pow(2, float64(t.Sub(s.lastUpdate))/float64(halfLife))
+		s.lastUpdate = t
+		if s.rollingFeerate < s.policy.IncrementalFeerate/2 {
+			s.rollingFeerate = 0
+			return 0
+		}
+	}
+	return math.Max(s.rollingFeerate, s.policy.IncrementalFeerate)
+}
+
+func (s *evictionSim) add(tx types.StoredTransaction) {
+	if _, ok := s.txs[tx.DBID]; ok {
+		return
+	}
+	s.txs[tx.DBID] = tx
+	s.usage += s.txUsage(&tx)
+	heap.Push(&s.byFeerate, feerateEntry{dbid: tx.DBID, feerate: tx.Feerate()})
+
+	// drop the entries of confirmed and expired transactions
+	if s.byFeerate.Len() > 2*len(s.txs)+feerateHeapSlack {
+		s.byFeerate = s.byFeerate[:0]
+		for dbid, tx := range s.txs {
+			s.byFeerate = append(s.byFeerate, feerateEntry{dbid: dbid, feerate: tx.Feerate()})
+		}
+		heap.Init(&s.byFeerate)
+	}
+}
+
+func (s *evictionSim) remove(dbid int64) {
+	tx, ok := s.txs[dbid]
+	if !ok {
+		return
+	}
+	delete(s.txs, dbid)
+	s.usage -= s.txUsage(&tx)
+}
+
+// trim evicts the transactions with the lowest feerate until the usage is below the limit at `t`
+func (s *evictionSim) trim(t time.Time) {
+	for s.usage > float64(s.policy.MaxMempool) && s.byFeerate.Len() > 0 {
+		e := heap.Pop(&s.byFeerate).(feerateEntry)
+		if _, ok := s.txs[e.dbid]; !ok {
+			continue
+		}
+		s.remove(e.dbid)
+		if feerate := e.feerate + s.policy.IncrementalFeerate; feerate > s.rollingFeerate {
+			s.rollingFeerate = feerate
+			s.lastUpdate = t
+			s.blockSinceBump = false
+		}
+	}
+}
+
+// apply applies `e`. New transactions below the minimum feerate are not accepted,
+// transactions of disconnected blocks are.
+func (s *evictionSim) apply(e *Event) {
+	switch e.Type {
+	case EnterMempool:
+		for _, tx := range e.AddTransactions {
+			if tx.Feerate() >= s.minFeerate(e.Time) {
+				s.add(tx)
+			}
+		}
+	case BlockConfirmation, BlockReorg:
+		s.blockSinceBump = true
+		for _, tx := range e.AddTransactions {
+			s.add(tx)
+		}
+	}
+	for _, dbid := range e.RemoveTransactions {
+		s.remove(dbid)
+	}
+	s.trim(e.Time)
+}
+
+// SimulateEviction reconstructs the mempool at `to` of a node with the size limit of `policy`,
+// for comparison with the observed mempool, which is not limited. The node starts with the
+// observed mempool at `from`, trimmed to the limit, and receives the observed transactions and
+// blocks until `to`. Its minimum feerate depends on the evictions before, so `from` should be
+// a few hours before `to`.
+func SimulateEviction(st *Storage, from, to time.Time, policy types.EvictionPolicy) (*types.MempoolSnapshot, error) {
+	m, err := NewMempoolAtTime(st, from)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := newEvictionSim(policy)
+	for _, tx := range m.TransactionMap() {
+		sim.add(tx)
+	}
+	sim.trim(from)
+
+	for {
+		e, err := m.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil || e.Time.After(to) {
+			break
+		}
+		if err := m.ApplyEvent(e); err != nil {
+			return nil, err
+		}
+		sim.apply(e)
+	}
+
+	minFeerate := sim.minFeerate(to)
+	res := &types.MempoolSnapshot{
+		Time:         to,
+		Transactions: []types.Transaction{},
+		Evicted:      []types.Hash32{},
+		MinFeerate:   &minFeerate,
+	}
+	for dbid, tx := range m.TransactionMap() {
+		if _, ok := sim.txs[dbid]; ok {
+			res.Transactions = append(res.Transactions, tx.Transaction)
+		} else {
+			res.Evicted = append(res.Evicted, tx.TxID)
+		}
+	}
+	return res, nil
+}
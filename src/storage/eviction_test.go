@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// evictionTestPolicy fits 250 vbytes
+var evictionTestPolicy = types.EvictionPolicy{
+	MaxMempool:         250,
+	UsagePerVByte:      1,
+	IncrementalFeerate: 1,
+	HalfLife:           time.Hour,
+}
+
+func TestEvictionSim_MinFeerate(t *testing.T) {
+	sim := newEvictionSim(evictionTestPolicy)
+	start := time.Unix(1000, 0)
+	tx := func(dbid int64, feerate uint64) types.StoredTransaction {
+		return types.StoredTransaction{DBID: dbid, Transaction: types.Transaction{Fee: feerate * 100, Weight: 400}}
+	}
+
+	sim.apply(&Event{Time: start, Type: EnterMempool, AddTransactions: []types.StoredTransaction{tx(1, 10)}})
+	sim.apply(&Event{Time: start, Type: EnterMempool, AddTransactions: []types.StoredTransaction{tx(2, 5)}})
+	sim.apply(&Event{Time: start, Type: EnterMempool, AddTransactions: []types.StoredTransaction{tx(3, 20)}})
+	assert.Len(t, sim.txs, 2)
+	assert.NotContains(t, sim.txs, int64(2))
+	assert.Equal(t, 6.0, sim.minFeerate(start))
+
+	// the minimum feerate decays since the eviction, but only after a block
+	assert.Equal(t, 6.0, sim.minFeerate(start.Add(15*time.Minute)))
+	sim.apply(&Event{Time: start.Add(15 * time.Minute), Type: BlockConfirmation, RemoveTransactions: []int64{1, 3}})
+	// the mempool is less than a quarter full, a quarter of the half-life applies
+	assert.Equal(t, 3.0, sim.minFeerate(start.Add(15*time.Minute)))
+	assert.Equal(t, 0.0, sim.minFeerate(start.Add(time.Hour)))
+}
+
+func TestSimulateEviction(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx := func(id string, offset int, feerate uint64) types.Transaction {
+		return types.Transaction{TxID: test.GenerateHash32(id), FirstSeen: GetTime(offset), Fee: feerate * 100, Weight: 400}
+	}
+	// "low" is evicted by "high", "late" is below the minimum feerate of 6 sat/vB
+	_, err = st.InsertTransactions([]types.Transaction{
+		tx("first", 10, 10), tx("low", 20, 5), tx("high", 30, 20), tx("late", 40, 4),
+	})
+	require.NoError(t, err)
+
+	snapshot, err := SimulateEviction(st, GetTime(5), GetTime(50), evictionTestPolicy)
+	require.NoError(t, err)
+	assert.Len(t, snapshot.Transactions, 2)
+	assert.ElementsMatch(t, []types.Hash32{test.GenerateHash32("low"), test.GenerateHash32("late")}, snapshot.Evicted)
+	assert.Equal(t, 6.0, *snapshot.MinFeerate)
+}
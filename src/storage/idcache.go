@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DefaultIDCacheSize is the default number of txids in the ID cache, about 30 MB
+const DefaultIDCacheSize = 300000
+
+// IDCacheStats are counters of the cache of database ids by txid
+type IDCacheStats struct {
+	// Number of cached txids
+	Size     int `json:"size"`
+	Capacity int `json:"capacity"`
+	// Lookups of cached txids
+	Hits uint64 `json:"hits"`
+	// Lookups of txids that were not cached
+	Misses uint64 `json:"misses"`
+	// Hits / (Hits + Misses)
+	HitRate float64 `json:"hitRate"`
+}
+
+type idCacheEntry struct {
+	txid types.Hash32
+	id   int64
+}
+
+// idCache maps txids to database ids with least-recently-used eviction.
+// The methods of a nil *idCache do nothing.
+type idCache struct {
+	mu       sync.Mutex
+	capacity int
+	// values are *list.Element with an idCacheEntry, most recently used first
+	entries map[types.Hash32]*list.Element
+	order   *list.List
+	hits    uint64
+	misses  uint64
+}
+
+func newIDCache(capacity int) *idCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &idCache{
+		capacity: capacity,
+		entries:  map[types.Hash32]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// get returns the database ids of the cached `txids` and the txids that are not cached
+func (c *idCache) get(txids []types.Hash32) (map[types.Hash32]int64, []types.Hash32) {
+	res := map[types.Hash32]int64{}
+	if c == nil {
+		return res, txids
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var missing []types.Hash32
+	for _, txid := range txids {
+		if e, ok := c.entries[txid]; ok {
+			c.order.MoveToFront(e)
+			res[txid] = e.Value.(idCacheEntry).id
+			c.hits++
+		} else {
+			missing = append(missing, txid)
+			c.misses++
+		}
+	}
+	return res, missing
+}
+
+// add caches `ids` and evicts the least recently used txids above the capacity
+func (c *idCache) add(ids map[types.Hash32]int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for txid, id := range ids {
+		if e, ok := c.entries[txid]; ok {
+			e.Value = idCacheEntry{txid, id}
+			c.order.MoveToFront(e)
+			continue
+		}
+		c.entries[txid] = c.order.PushFront(idCacheEntry{txid, id})
+	}
+	for c.order.Len() > c.capacity {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(idCacheEntry).txid)
+	}
+}
+
+// purge removes all txids. Called after deleting transactions, whose ids can be reused.
+func (c *idCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[types.Hash32]*list.Element{}
+	c.order.Init()
+}
+
+func (c *idCache) stats() IDCacheStats {
+	if c == nil {
+		return IDCacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := IDCacheStats{
+		Size:     c.order.Len(),
+		Capacity: c.capacity,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+	if s.Hits+s.Misses > 0 {
+		s.HitRate = float64(s.Hits) / float64(s.Hits+s.Misses)
+	}
+	return s
+}
+
+// SetIDCacheSize replaces the cache of database ids by txid, which saves the lookups of the
+// transactions confirmed by a block, with an empty cache of `size` txids. Zero disables the cache.
+// Must be called before the storage is used concurrently.
+func (s *Storage) SetIDCacheSize(size int) {
+	s.ids = newIDCache(size)
+}
+
+// IDCacheStats returns the counters of the cache of database ids by txid
+func (s *Storage) IDCacheStats() IDCacheStats {
+	return s.ids.stats()
+}
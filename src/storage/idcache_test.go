@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestIDCache(t *testing.T) {
+	a, b, c := test.GenerateHash32("a"), test.GenerateHash32("b"), test.GenerateHash32("c")
+	cache := newIDCache(2)
+	cache.add(map[types.Hash32]int64{a: 1, b: 2})
+
+	// "a" is used more recently than "b", which is evicted
+	ids, missing := cache.get([]types.Hash32{a, c})
+	assert.Equal(t, map[types.Hash32]int64{a: 1}, ids)
+	assert.Equal(t, []types.Hash32{c}, missing)
+	cache.add(map[types.Hash32]int64{c: 3})
+	ids, missing = cache.get([]types.Hash32{a, b, c})
+	assert.Equal(t, map[types.Hash32]int64{a: 1, c: 3}, ids)
+	assert.Equal(t, []types.Hash32{b}, missing)
+	assert.Equal(t, IDCacheStats{Size: 2, Capacity: 2, Hits: 3, Misses: 2, HitRate: 0.6}, cache.stats())
+
+	cache.purge()
+	_, missing = cache.get([]types.Hash32{a})
+	assert.Equal(t, []types.Hash32{a}, missing)
+
+	// a nil cache caches nothing
+	var disabled *idCache
+	disabled.add(map[types.Hash32]int64{a: 1})
+	ids, missing = disabled.get([]types.Hash32{a})
+	assert.Empty(t, ids)
+	assert.Equal(t, []types.Hash32{a}, missing)
+	assert.Equal(t, IDCacheStats{}, disabled.stats())
+}
+
+func TestStorage_IDCache(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{*NewTxAtOffset(1), *NewTxAtOffset(2)}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	block := chainedBlocks(0, "", []string{"x"})[0]
+	block.IsBest = true
+	block.TxIDs = []types.Hash32{txs[0].TxID, txs[1].TxID, test.GenerateHash32("missing")}
+	blockID, err := st.InsertBlock(&block)
+	require.NoError(t, err)
+	assert.Equal(t, IDCacheStats{Size: 2, Capacity: DefaultIDCacheSize, Hits: 2, Misses: 1, HitRate: 2.0 / 3}, st.IDCacheStats())
+
+	confirmed, err := st.TransactionsInBlock(blockID)
+	require.NoError(t, err)
+	confirmedTxs, err := confirmed.Collect()
+	require.NoError(t, err)
+	assert.Len(t, confirmedTxs, 2)
+
+	// pruning purges the cache
+	_, err = st.PruneTransactions(GetTime(1000), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, st.IDCacheStats().Size)
+}
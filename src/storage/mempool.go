@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+	"sort"
 	"time"
 
 	"github.com/0xb10c/bademeister-go/src/types"
@@ -13,14 +15,16 @@ type EventType string
 const (
 	transactionBufferSize = 4096
 	blockBufferSize       = 4
+	expiryBufferSize      = 256
 	// EnterMempool is emitted when a single transaction enters the mempool
 	EnterMempool EventType = "EnterMempool"
 	// BlockConfirmation is emitted when a block is confirmed
 	BlockConfirmation EventType = "BlockConfirmation"
 	// BlockReorg is emitted when a block is confirmed and the parent is not the current best block
 	BlockReorg EventType = "BlockReorg"
+	// Expire is emitted when a transaction is dropped from the mempool due to expiry
+	Expire EventType = "Expire"
 	// replaced?
-	// expired?
 )
 
 // Event describes a change to the mempool
@@ -29,6 +33,7 @@ type Event struct {
 	Type               EventType
 	NewBlock           *types.StoredBlock
 	NewTransaction     *types.StoredTransaction
+	ExpiredTransaction *types.StoredTransaction
 	AddTransactions    []types.StoredTransaction
 	RemoveTransactions []int64
 }
@@ -56,22 +61,29 @@ type Mempool struct {
 	nextTransactions           []*types.StoredTransaction
 	nextTransactionsBufferSize int
 
+	lastExpiration            *types.StoredTransaction
+	nextExpirations           []*types.StoredTransaction
+	nextExpirationsBufferSize int
+
 	transactions map[int64]types.StoredTransaction
+
+	// transactions of blocks shared with other mempools, nil if not shared
+	blockTxs *blockTxCache
 }
 
 // Get all transactions that are potentially in mempool at given time.
 // Note that due to reorgs, this is a superset of transactions.
-func newMempoolWithoutBlock(st *Storage, t time.Time) (*Mempool, error) {
-	txIter, err := st.QueryTransactions(TransactionQueryByTime{
-		FirstSeenBeforeOrAt: &t,
-		LastRemovedAfter:    &t,
-	})
+func newMempoolWithoutBlock(ctx context.Context, st *Storage, t time.Time) (*Mempool, error) {
+	txIter, err := st.QueryTransactions(TransactionQuery{InMempoolAt: &t})
 
 	if err != nil {
 		return nil, err
 	}
 
-	txs := txIter.Collect()
+	txs, err := collectContext(ctx, txIter)
+	if err != nil {
+		return nil, err
+	}
 	var lastTransaction *types.StoredTransaction
 	for _, tx := range txs {
 		if lastTransaction == nil || tx.FirstSeen.After(lastTransaction.FirstSeen) {
@@ -80,7 +92,7 @@ func newMempoolWithoutBlock(st *Storage, t time.Time) (*Mempool, error) {
 		}
 	}
 
-	tm := time.Unix(0, 0)
+	tm := time.Unix(0, 0).UTC()
 	if lastTransaction != nil {
 		tm = lastTransaction.FirstSeen
 	}
@@ -98,14 +110,42 @@ func newMempoolWithoutBlock(st *Storage, t time.Time) (*Mempool, error) {
 		nextTransactions:           []*types.StoredTransaction{},
 		nextTransactionsBufferSize: transactionBufferSize,
 
+		lastExpiration:            nil,
+		nextExpirations:           []*types.StoredTransaction{},
+		nextExpirationsBufferSize: expiryBufferSize,
+
 		transactions: transactionsByDBID(txs),
 	}, nil
 }
 
+// cancelCheckInterval is the number of rows or events between two checks for cancellation
+const cancelCheckInterval = 1000
+
+// collectContext is txIter.Collect that returns ctx.Err() once `ctx` is canceled
+func collectContext(ctx context.Context, txIter *TxIterator) (res []types.StoredTransaction, err error) {
+	defer txIter.Close()
+	for tx := txIter.Next(); tx != nil; tx = txIter.Next() {
+		if len(res)%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		res = append(res, *tx)
+	}
+	return res, txIter.Err()
+}
+
 // NewMempoolAtBlock returns the Mempool that results with confirmation of _block_.
 // Block must be on the consensus chain.
 // Use `st.ReorgBase(block)` to find a suitable block that is on the consensus chain.
 func NewMempoolAtBlock(st *Storage, block *types.StoredBlock) (*Mempool, error) {
+	return newMempoolAtBlock(context.Background(), st, block, nil)
+}
+
+// newMempoolAtBlock is NewMempoolAtBlock with the block transactions of `blockTxs`, nil for none
+func newMempoolAtBlock(
+	ctx context.Context, st *Storage, block *types.StoredBlock, blockTxs *blockTxCache,
+) (*Mempool, error) {
 	reorgBase, err := st.ReorgBase(&block.Block)
 	if err != nil {
 		return nil, err
@@ -118,10 +158,11 @@ func NewMempoolAtBlock(st *Storage, block *types.StoredBlock) (*Mempool, error)
 		)
 	}
 
-	m, err := newMempoolWithoutBlock(st, block.FirstSeen)
+	m, err := newMempoolWithoutBlock(ctx, st, block.FirstSeen)
 	if err != nil {
 		return nil, err
 	}
+	m.blockTxs = blockTxs
 
 	blockEvent, err := m.newBlockEvent(block)
 	if err != nil {
@@ -136,11 +177,31 @@ func NewMempoolAtBlock(st *Storage, block *types.StoredBlock) (*Mempool, error)
 
 // NewMempoolAtTime returns the Mempool where the last event is before or at `time`
 func NewMempoolAtTime(st *Storage, time time.Time) (*Mempool, error) {
+	return newMempoolAtTime(context.Background(), st, time, nil, nil)
+}
+
+// NewMempoolAtTimeContext is NewMempoolAtTime for long reconstructions: it returns ctx.Err() soon
+// after `ctx` is canceled and reports with `progress`, if set, the fraction of the time from the
+// last final block before `time` to `time` whose events were applied. Loading the mempool at that
+// block comes first and is not part of the progress.
+func NewMempoolAtTimeContext(
+	ctx context.Context, st *Storage, time time.Time, progress func(done float64),
+) (*Mempool, error) {
+	return newMempoolAtTime(ctx, st, time, nil, progress)
+}
+
+// newMempoolAtTime is NewMempoolAtTimeContext with the block transactions of `blockTxs`, nil for none
+func newMempoolAtTime(
+	ctx context.Context, st *Storage, time time.Time, blockTxs *blockTxCache, progress func(float64),
+) (*Mempool, error) {
 	// In a reorg, some transactions that were previously confirmed come back into the mempool.
 	// For this reason, we cannot query transactions with `first_seen < t < last_removed`,
 	// since this would include transactions that are temporarily confirmed.
 	// In order to properly reflect the mempool state, we must start from a block that will
 	// not be reorged later and seek to the time.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	bestBlock, err := st.BestBlockAtTime(time)
 	if err != nil {
@@ -149,7 +210,12 @@ func NewMempoolAtTime(st *Storage, time time.Time) (*Mempool, error) {
 
 	// if we query the mempool before the first block record
 	if bestBlock == nil {
-		return newMempoolWithoutBlock(st, time)
+		m, err := newMempoolWithoutBlock(ctx, st, time)
+		if err != nil {
+			return nil, err
+		}
+		m.blockTxs = blockTxs
+		return m, nil
 	}
 
 	// If bestBlock is on the final consensus chain, bestBlock == reorgBase
@@ -158,11 +224,11 @@ func NewMempoolAtTime(st *Storage, time time.Time) (*Mempool, error) {
 		return nil, err
 	}
 
-	mempool, err := NewMempoolAtBlock(st, reorgBase)
+	mempool, err := newMempoolAtBlock(ctx, st, reorgBase, blockTxs)
 	if err != nil {
 		return nil, err
 	}
-	if err := mempool.Seek(time); err != nil {
+	if err := mempool.seek(ctx, time, nil, progress); err != nil {
 		return nil, err
 	}
 
@@ -180,6 +246,14 @@ func (m *Mempool) Clone() *Mempool {
 	return &newMempool
 }
 
+// Refresh clears the buffered next transactions, blocks and expirations.
+// Call this to pick up data that was inserted after the end of the buffers was reached.
+func (m *Mempool) Refresh() {
+	m.nextTransactions = nil
+	m.nextBlocks = nil
+	m.nextExpirations = nil
+}
+
 // NextTransaction returns the next Transaction that will enter the Mempool
 func (m *Mempool) NextTransaction() (*types.StoredTransaction, error) {
 	dbid := int64(0)
@@ -195,7 +269,11 @@ func (m *Mempool) NextTransaction() (*types.StoredTransaction, error) {
 			return nil, err
 		}
 
-		for i, tx := range txIter.Collect() {
+		txs, err := txIter.Collect()
+		if err != nil {
+			return nil, err
+		}
+		for i, tx := range txs {
 			tx := tx
 			m.nextTransactions[i] = &tx
 		}
@@ -232,7 +310,11 @@ func (m *Mempool) NextBlock() (*types.StoredBlock, error) {
 			return nil, err
 		}
 
-		for i, block := range blockIter.Collect() {
+		blocks, err := blockIter.Collect()
+		if err != nil {
+			return nil, err
+		}
+		for i, block := range blocks {
 			block := block
 			m.nextBlocks[i] = &block
 		}
@@ -254,6 +336,47 @@ func (m *Mempool) NextBlock() (*types.StoredBlock, error) {
 	return m.NextBlock()
 }
 
+// NextExpiration returns the next Transaction that will expire from the Mempool
+func (m *Mempool) NextExpiration() (*types.StoredTransaction, error) {
+	dbid := int64(0)
+	if m.lastExpiration != nil {
+		dbid = m.lastExpiration.DBID
+	}
+
+	if len(m.nextExpirations) == 0 {
+		// the buffer is empty, fill it up
+		m.nextExpirations = make([]*types.StoredTransaction, m.nextExpirationsBufferSize)
+		txIter, err := m.storage.NextExpiredTransactions(m.Time, dbid, len(m.nextExpirations))
+		if err != nil {
+			return nil, err
+		}
+
+		txs, err := txIter.Collect()
+		if err != nil {
+			return nil, err
+		}
+		for i, tx := range txs {
+			tx := tx
+			m.nextExpirations[i] = &tx
+		}
+	}
+
+	for _, tx := range m.nextExpirations {
+		// sentinel value `nil` means that we reached the end of the collection
+		if tx == nil {
+			return nil, nil
+		}
+
+		if tx.Expired.After(m.Time) || (*tx.Expired == m.Time && tx.DBID > dbid) {
+			return tx, nil
+		}
+	}
+
+	// reset and reload buffer
+	m.nextExpirations = nil
+	return m.NextExpiration()
+}
+
 // newBlockEvent creates and returns a new Block event.
 //
 // If the new best block has the current block as parent, returns BlockConfirmation event where
@@ -294,7 +417,11 @@ func (m *Mempool) newBlockEvent(newBest *types.StoredBlock) (*Event, error) {
 			if err != nil {
 				return err
 			}
-			event.AddTransactions = append(event.AddTransactions, txIter.Collect()...)
+			txs, err := txIter.Collect()
+			if err != nil {
+				return err
+			}
+			event.AddTransactions = append(event.AddTransactions, txs...)
 			return nil
 		})
 		if err != nil {
@@ -304,7 +431,7 @@ func (m *Mempool) newBlockEvent(newBest *types.StoredBlock) (*Event, error) {
 
 	// Unless there is a reorg, this will be called once with `newBest`.
 	err := m.storage.WalkBlocks(newBest, commonAncestor, func(b *types.StoredBlock) error {
-		dbids, err := m.storage.TransactionDBIDsInBlock(b.DBID)
+		dbids, err := m.transactionDBIDsInBlock(b.DBID)
 		if err != nil {
 			return err
 		}
@@ -318,6 +445,15 @@ func (m *Mempool) newBlockEvent(newBest *types.StoredBlock) (*Event, error) {
 	return &event, nil
 }
 
+// transactionDBIDsInBlock returns the database ids of the transactions of block `dbid`,
+// from the shared cache if set
+func (m *Mempool) transactionDBIDsInBlock(dbid int64) ([]int64, error) {
+	if m.blockTxs != nil {
+		return m.blockTxs.get(m.storage, dbid)
+	}
+	return m.storage.TransactionDBIDsInBlock(dbid)
+}
+
 func (m *Mempool) newTransactionEvent(newTransaction *types.StoredTransaction) (*Event, error) {
 	return &Event{
 		Time:               newTransaction.FirstSeen,
@@ -329,7 +465,20 @@ func (m *Mempool) newTransactionEvent(newTransaction *types.StoredTransaction) (
 	}, nil
 }
 
-// NextEvent returns the next event that will alter the mempool
+func (m *Mempool) newExpireEvent(expiredTransaction *types.StoredTransaction) (*Event, error) {
+	return &Event{
+		Time:               *expiredTransaction.Expired,
+		Type:               Expire,
+		NewBlock:           nil,
+		NewTransaction:     nil,
+		ExpiredTransaction: expiredTransaction,
+		AddTransactions:    []types.StoredTransaction{},
+		RemoveTransactions: []int64{expiredTransaction.DBID},
+	}, nil
+}
+
+// NextEvent returns the next event that will alter the mempool.
+// For events at the same time, transactions come before blocks and blocks before expirations.
 func (m *Mempool) NextEvent() (*Event, error) {
 	nextTx, err := m.NextTransaction()
 	if err != nil {
@@ -341,6 +490,17 @@ func (m *Mempool) NextEvent() (*Event, error) {
 		return nil, err
 	}
 
+	nextExpiration, err := m.NextExpiration()
+	if err != nil {
+		return nil, err
+	}
+
+	if nextExpiration != nil &&
+		(nextTx == nil || nextExpiration.Expired.Before(nextTx.FirstSeen)) &&
+		(nextBlock == nil || nextExpiration.Expired.Before(nextBlock.FirstSeen)) {
+		return m.newExpireEvent(nextExpiration)
+	}
+
 	if nextBlock == nil && nextTx == nil {
 		return nil, nil
 	}
@@ -358,6 +518,12 @@ func (m *Mempool) NextEvent() (*Event, error) {
 
 // ApplyEvent applies the provided Event
 func (m *Mempool) ApplyEvent(e *Event) error {
+	m.applyEvent(e, nil)
+	return nil
+}
+
+// applyEvent applies `e` and records the changed transactions in `delta` if set
+func (m *Mempool) applyEvent(e *Event, delta *deltaRecorder) {
 	m.Time = e.Time
 	if e.NewBlock != nil {
 		m.lastBlock = e.NewBlock
@@ -365,34 +531,118 @@ func (m *Mempool) ApplyEvent(e *Event) error {
 	if e.NewTransaction != nil {
 		m.lastTransaction = e.NewTransaction
 	}
+	if e.ExpiredTransaction != nil {
+		m.lastExpiration = e.ExpiredTransaction
+	}
 	for _, tx := range e.AddTransactions {
+		if _, ok := m.transactions[tx.DBID]; !ok && delta != nil {
+			delta.add(tx)
+		}
 		m.transactions[tx.DBID] = tx
 	}
 	for _, dbid := range e.RemoveTransactions {
+		if tx, ok := m.transactions[dbid]; ok && delta != nil {
+			delta.remove(tx)
+		}
 		delete(m.transactions, dbid)
 	}
-	return nil
 }
 
 // Seek applies all events that are before or at time `t` to the current mempool
 func (m *Mempool) Seek(t time.Time) error {
+	return m.seek(context.Background(), t, nil, nil)
+}
+
+// MempoolDelta is the net change of a Mempool between two times
+type MempoolDelta struct {
+	// Transactions that entered and did not leave again, ordered by database id
+	Added []types.StoredTransaction
+	// Transactions that left and did not enter again, ordered by database id
+	Removed []types.StoredTransaction
+}
+
+// SeekDelta is Seek and returns the net change of the mempool. Transactions that entered and
+// left between the current time and `t`, or left and entered again in a reorg, are not part of it.
+// Consumers that derive data from the mempool can apply the delta instead of reading all
+// transactions after each seek, see ReconstructSeriesIncremental.
+func (m *Mempool) SeekDelta(t time.Time) (*MempoolDelta, error) {
+	delta := deltaRecorder{
+		added:   map[int64]types.StoredTransaction{},
+		removed: map[int64]types.StoredTransaction{},
+	}
+	if err := m.seek(context.Background(), t, &delta, nil); err != nil {
+		return nil, err
+	}
+	return delta.result(), nil
+}
+
+// seek applies the events until `t`, records the changes in `delta` if set and reports the
+// progress with `progress` if set. Returns ctx.Err() once `ctx` is canceled.
+func (m *Mempool) seek(ctx context.Context, t time.Time, delta *deltaRecorder, progress func(float64)) error {
 	if t.Before(m.Time) {
 		return errors.Errorf("cannot seek backwards")
 	}
-	for {
+	start := m.Time
+	for n := 1; ; n++ {
 		nextEvent, err := m.NextEvent()
 		if err != nil {
 			return err
 		}
 
 		if nextEvent == nil || nextEvent.Time.After(t) {
+			if progress != nil {
+				progress(1)
+			}
 			return nil
 		}
 
-		if err := m.ApplyEvent(nextEvent); err != nil {
-			return err
+		m.applyEvent(nextEvent, delta)
+
+		if n%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if progress != nil && t.After(start) {
+				progress(float64(nextEvent.Time.Sub(start)) / float64(t.Sub(start)))
+			}
+		}
+	}
+}
+
+// deltaRecorder collects the net change of the transactions of a Mempool
+type deltaRecorder struct {
+	added   map[int64]types.StoredTransaction
+	removed map[int64]types.StoredTransaction
+}
+
+// add records that `tx` entered the mempool
+func (d *deltaRecorder) add(tx types.StoredTransaction) {
+	if _, ok := d.removed[tx.DBID]; ok {
+		delete(d.removed, tx.DBID)
+		return
+	}
+	d.added[tx.DBID] = tx
+}
+
+// remove records that `tx` left the mempool
+func (d *deltaRecorder) remove(tx types.StoredTransaction) {
+	if _, ok := d.added[tx.DBID]; ok {
+		delete(d.added, tx.DBID)
+		return
+	}
+	d.removed[tx.DBID] = tx
+}
+
+func (d *deltaRecorder) result() *MempoolDelta {
+	sorted := func(txs map[int64]types.StoredTransaction) []types.StoredTransaction {
+		res := make([]types.StoredTransaction, 0, len(txs))
+		for _, tx := range txs {
+			res = append(res, tx)
 		}
+		sort.Slice(res, func(i, j int) bool { return res[i].DBID < res[j].DBID })
+		return res
 	}
+	return &MempoolDelta{Added: sorted(d.added), Removed: sorted(d.removed)}
 }
 
 // TransactionMap returns a map `DBID -> StoredTransaction`
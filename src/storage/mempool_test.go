@@ -3,7 +3,9 @@ package storage
 import (
 	"github.com/0xb10c/bademeister-go/src/test"
 
+	"context"
 	"testing"
+	"time"
 
 	"github.com/0xb10c/bademeister-go/src/types"
 	"github.com/stretchr/testify/assert"
@@ -77,11 +79,15 @@ func testMempoolEvent(t *testing.T, mem *Mempool, testChain TestChain, event *Ev
 		addTxs := []types.StoredTransaction{}
 		block1Txs, err := mem.storage.TransactionsInBlock(storedBlocks[1].DBID)
 		require.NoError(t, err)
-		addTxs = append(addTxs, block1Txs.Collect()...)
+		txs, err := block1Txs.Collect()
+		require.NoError(t, err)
+		addTxs = append(addTxs, txs...)
 
 		block2Txs, err := mem.storage.TransactionsInBlock(storedBlocks[2].DBID)
 		require.NoError(t, err)
-		addTxs = append(addTxs, block2Txs.Collect()...)
+		txs, err = block2Txs.Collect()
+		require.NoError(t, err)
+		addTxs = append(addTxs, txs...)
 
 		assert.ElementsMatch(t, addTxs, event.AddTransactions)
 
@@ -134,9 +140,10 @@ func TestMempool(t *testing.T) {
 	testChain := NewTestChainReorg()
 	require.NoError(t, insertTestChain(st, &testChain))
 
-	txIter, err := st.QueryTransactions(StaticQuery{})
+	txIter, err := st.QueryTransactions(TransactionQuery{})
+	require.NoError(t, err)
+	txs, err := txIter.Collect()
 	require.NoError(t, err)
-	txs := txIter.Collect()
 	for _, tx := range txs {
 		r := tx.LastRemoved
 		switch tx.TxID {
@@ -181,3 +188,93 @@ func TestMempool(t *testing.T) {
 		}
 	}
 }
+
+func TestMempool_Expire(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	for _, offset := range []int{10, 20, 30} {
+		_, err := st.InsertTransaction(NewTxAtOffset(offset))
+		require.NoError(t, err)
+	}
+
+	window := 100 * time.Second
+	txIter, err := st.ExpiryCandidates(GetTime(125), window)
+	require.NoError(t, err)
+	candidates, err := txIter.Collect()
+	require.NoError(t, err)
+	require.Len(t, candidates, 2)
+	require.NoError(t, st.MarkExpired([]int64{candidates[0].DBID}, window))
+
+	tx, err := st.TransactionByID(test.GenerateHash32("tx-10"))
+	require.NoError(t, err)
+	require.NotNil(t, tx.Expired)
+	require.Equal(t, GetTime(110), *tx.Expired)
+
+	for _, c := range []struct {
+		offset   int
+		expected []types.Hash32
+	}{
+		{30, txidsFromStrings("tx-10", "tx-20", "tx-30")},
+		{109, txidsFromStrings("tx-10", "tx-20", "tx-30")},
+		{110, txidsFromStrings("tx-20", "tx-30")},
+		{200, txidsFromStrings("tx-20", "tx-30")},
+	} {
+		mem, err := NewMempoolAtTime(st, GetTime(0))
+		require.NoError(t, err)
+		require.NoError(t, mem.Seek(GetTime(c.offset)))
+		require.ElementsMatch(t, c.expected, transactionIdsFromTxs(mem.Transactions()))
+
+		mem, err = NewMempoolAtTime(st, GetTime(c.offset))
+		require.NoError(t, err)
+		require.ElementsMatch(t, c.expected, transactionIdsFromTxs(mem.Transactions()))
+	}
+
+	// transactions that left the node mempool at an unknown time
+	require.NoError(t, st.MarkDropped(txidsFromStrings("tx-20", "tx-30"), GetTime(140)))
+	tx, err = st.TransactionByID(test.GenerateHash32("tx-20"))
+	require.NoError(t, err)
+	require.NotNil(t, tx.Expired)
+	require.Equal(t, GetTime(140), *tx.Expired)
+	mem, err := NewMempoolAtTime(st, GetTime(140))
+	require.NoError(t, err)
+	require.Empty(t, mem.Transactions())
+
+	// seeing the transaction again clears the expiry
+	txAgain := NewTxAtOffset(10)
+	txAgain.FirstSeen = GetTime(150)
+	_, err = st.InsertTransaction(txAgain)
+	require.NoError(t, err)
+	tx, err = st.TransactionByID(test.GenerateHash32("tx-10"))
+	require.NoError(t, err)
+	require.Nil(t, tx.Expired)
+	require.Equal(t, GetTime(10), tx.FirstSeen)
+}
+
+func TestNewMempoolAtTimeContext(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	insertSeriesTestData(t, st)
+	expected, err := NewMempoolAtTime(st, GetTime(140))
+	require.NoError(t, err)
+
+	var progress []float64
+	m, err := NewMempoolAtTimeContext(context.Background(), st, GetTime(140), func(done float64) {
+		progress = append(progress, done)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sortedTxIDs(expected.Transactions()), sortedTxIDs(m.Transactions()))
+	assert.Equal(t, []float64{1}, progress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewMempoolAtTimeContext(ctx, st, GetTime(140), nil)
+	assert.Equal(t, context.Canceled, err)
+}
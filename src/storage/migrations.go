@@ -0,0 +1,826 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// baseVersion is the schema version created by `initialize`.
+// Later versions are reached by applying `migrations`.
+const baseVersion = 5
+
+// migration upgrades the schema from `version-1` to `version` by running `statements`.
+// The `down` statements revert the migration.
+type migration struct {
+	version    int
+	statements []string
+	down       []string
+}
+
+// migrations must be ordered by version.
+// The last entry determines `currentVersion`.
+var migrations = []migration{
+	{
+		// time at which a transaction was dropped from the mempool due to expiry
+		version: 6,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN expired INTEGER`,
+		},
+		down: rebuildTable("transaction", `
+			id             INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid           BLOB UNIQUE NOT NULL,
+			first_seen     INTEGER,
+			last_removed   INTEGER,
+			fee            INTEGER,
+			weight         INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight",
+		),
+	},
+	{
+		// history of `getmempoolinfo`, feerates in sat/kvB
+		version: 7,
+		statements: []string{
+			`CREATE TABLE "mempool_info" (
+				time             INTEGER NOT NULL,
+				size             INTEGER,
+				bytes            INTEGER,
+				usage            INTEGER,
+				max_mempool      INTEGER,
+				mempool_min_fee  INTEGER,
+				min_relay_tx_fee INTEGER
+			)`,
+			`CREATE INDEX mempool_info_time ON "mempool_info" (time)`,
+		},
+		down: []string{
+			`DROP TABLE "mempool_info"`,
+		},
+	},
+	{
+		// snapshots of node settings, a new row is added when a setting changes
+		version: 8,
+		statements: []string{
+			`CREATE TABLE "node_config" (
+				time             INTEGER NOT NULL,
+				version          INTEGER,
+				subversion       TEXT,
+				protocol_version INTEGER,
+				chain            TEXT,
+				max_mempool      INTEGER,
+				min_relay_tx_fee INTEGER,
+				incremental_fee  INTEGER,
+				local_relay      INTEGER
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "node_config"`,
+		},
+	},
+	{
+		// lookup of blocks by height
+		version: 9,
+		statements: []string{
+			`CREATE INDEX block_height ON "block" (height)`,
+		},
+		down: []string{
+			`DROP INDEX block_height`,
+		},
+	},
+	{
+		// inputs and outputs of transactions and their heuristic classification
+		version: 10,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN heuristics INTEGER`,
+			`CREATE TABLE "transaction_input" (
+				transaction_id INTEGER REFERENCES "transaction" (id) NOT NULL,
+				n              INTEGER NOT NULL,
+				prev_txid      BLOB NOT NULL,
+				prev_index     INTEGER NOT NULL,
+				sequence       INTEGER NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX transaction_input_id_n ON "transaction_input" (transaction_id, n)`,
+			`CREATE TABLE "transaction_output" (
+				transaction_id INTEGER REFERENCES "transaction" (id) NOT NULL,
+				n              INTEGER NOT NULL,
+				value          INTEGER NOT NULL,
+				script_type    TEXT NOT NULL,
+				script_size    INTEGER NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX transaction_output_id_n ON "transaction_output" (transaction_id, n)`,
+		},
+		down: append([]string{
+			`DROP TABLE "transaction_input"`,
+			`DROP TABLE "transaction_output"`,
+		}, rebuildTable("transaction", `
+			id             INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid           BLOB UNIQUE NOT NULL,
+			first_seen     INTEGER,
+			last_removed   INTEGER,
+			fee            INTEGER,
+			weight         INTEGER,
+			expired        INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired",
+		)...),
+	},
+	{
+		// OP_RETURN outputs and their payload size, NULL if unknown
+		version: 11,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN op_return_outputs INTEGER`,
+			`ALTER TABLE "transaction" ADD COLUMN op_return_size INTEGER`,
+		},
+		down: rebuildTable("transaction", `
+			id             INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid           BLOB UNIQUE NOT NULL,
+			first_seen     INTEGER,
+			last_removed   INTEGER,
+			fee            INTEGER,
+			weight         INTEGER,
+			expired        INTEGER,
+			heuristics     INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics",
+		),
+	},
+	{
+		// size of the witness data, NULL if unknown
+		version: 12,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN witness_size INTEGER`,
+		},
+		down: rebuildTable("transaction", `
+			id                INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid              BLOB UNIQUE NOT NULL,
+			first_seen        INTEGER,
+			last_removed      INTEGER,
+			fee               INTEGER,
+			weight            INTEGER,
+			expired           INTEGER,
+			heuristics        INTEGER,
+			op_return_outputs INTEGER,
+			op_return_size    INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics, op_return_outputs, op_return_size",
+		),
+	},
+	{
+		// block weight, transaction count, coinbase miner tag and near-empty flag
+		version: 13,
+		statements: []string{
+			`ALTER TABLE "block" ADD COLUMN weight INTEGER`,
+			`ALTER TABLE "block" ADD COLUMN tx_count INTEGER`,
+			`ALTER TABLE "block" ADD COLUMN miner TEXT`,
+			`ALTER TABLE "block" ADD COLUMN near_empty INTEGER`,
+		},
+		down: append(rebuildTable("block", `
+			id         INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			hash       BLOB (32) UNIQUE NOT NULL,
+			parent     BLOB (32),
+			first_seen INTEGER,
+			height     INTEGER,
+			is_best    INTEGER`,
+			"id, hash, parent, first_seen, height, is_best",
+		), `CREATE INDEX block_height ON "block" (height)`),
+	},
+	{
+		// compact difficulty target and timestamp from the block header
+		version: 14,
+		statements: []string{
+			`ALTER TABLE "block" ADD COLUMN bits INTEGER`,
+			`ALTER TABLE "block" ADD COLUMN encoded_time INTEGER`,
+		},
+		down: append(rebuildTable("block", `
+			id         INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			hash       BLOB (32) UNIQUE NOT NULL,
+			parent     BLOB (32),
+			first_seen INTEGER,
+			height     INTEGER,
+			is_best    INTEGER,
+			weight     INTEGER,
+			tx_count   INTEGER,
+			miner      TEXT,
+			near_empty INTEGER`,
+			"id, hash, parent, first_seen, height, is_best, weight, tx_count, miner, near_empty",
+		), `CREATE INDEX block_height ON "block" (height)`),
+	},
+	{
+		// first-seen times imported from external datasets, per source label
+		version: 15,
+		statements: []string{
+			`CREATE TABLE "external_first_seen" (
+				txid       BLOB NOT NULL,
+				source     TEXT NOT NULL,
+				first_seen INTEGER NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX external_first_seen_txid_source ON "external_first_seen" (txid, source)`,
+		},
+		down: []string{
+			`DROP TABLE "external_first_seen"`,
+		},
+	},
+	{
+		// lookup of transactions spending the same output
+		version: 16,
+		statements: []string{
+			`CREATE INDEX transaction_input_outpoint ON "transaction_input" (prev_txid, prev_index)`,
+		},
+		down: []string{
+			`DROP INDEX transaction_input_outpoint`,
+		},
+	},
+	{
+		// materialized aggregates of the transactions first seen per time bucket, see RefreshAggregates
+		version: 17,
+		statements: []string{
+			`CREATE INDEX transaction_first_seen ON "transaction" (first_seen)`,
+			`CREATE TABLE "tx_aggregate" (
+				resolution     INTEGER NOT NULL,
+				time           INTEGER NOT NULL,
+				transactions   INTEGER NOT NULL,
+				vsize          INTEGER NOT NULL,
+				fees           INTEGER NOT NULL,
+				median_feerate REAL,
+				PRIMARY KEY (resolution, time)
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "tx_aggregate"`,
+			`DROP INDEX transaction_first_seen`,
+		},
+	},
+	{
+		// seconds since the parent block by first-seen time and by header time
+		version: 18,
+		statements: []string{
+			`ALTER TABLE "block" ADD COLUMN first_seen_interval INTEGER`,
+			`ALTER TABLE "block" ADD COLUMN header_interval INTEGER`,
+			`UPDATE "block" SET
+				first_seen_interval = first_seen - (SELECT p.first_seen FROM "block" p WHERE p.hash = "block".parent),
+				header_interval = encoded_time - (SELECT p.encoded_time FROM "block" p WHERE p.hash = "block".parent)`,
+			`CREATE INDEX block_first_seen ON "block" (first_seen)`,
+		},
+		down: append(rebuildTable("block", `
+			id           INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			hash         BLOB (32) UNIQUE NOT NULL,
+			parent       BLOB (32),
+			first_seen   INTEGER,
+			height       INTEGER,
+			is_best      INTEGER,
+			weight       INTEGER,
+			tx_count     INTEGER,
+			miner        TEXT,
+			near_empty   INTEGER,
+			bits         INTEGER,
+			encoded_time INTEGER`,
+			"id, hash, parent, first_seen, height, is_best, weight, tx_count, miner, near_empty, bits, encoded_time",
+		), `CREATE INDEX block_height ON "block" (height)`),
+	},
+	{
+		// transactions removed from the tracked mempool by a block and entered since its parent
+		version: 19,
+		statements: []string{
+			`CREATE TABLE "block_drain" (
+				block_id             INTEGER PRIMARY KEY NOT NULL,
+				removed_transactions INTEGER NOT NULL,
+				removed_vsize        INTEGER NOT NULL,
+				removed_fees         INTEGER NOT NULL,
+				entered_transactions INTEGER NOT NULL,
+				entered_vsize        INTEGER NOT NULL,
+				entered_fees         INTEGER NOT NULL
+			)`,
+			insertBlockDrain,
+		},
+		down: []string{
+			`DROP TABLE "block_drain"`,
+		},
+	},
+	{
+		// version, nLockTime and RBF signaling of transactions and their adoption per aggregate bucket
+		version: 20,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN version INTEGER`,
+			`ALTER TABLE "transaction" ADD COLUMN locktime INTEGER`,
+			`ALTER TABLE "transaction" ADD COLUMN rbf INTEGER`,
+			`ALTER TABLE "tx_aggregate" ADD COLUMN signals_known INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE "tx_aggregate" ADD COLUMN version2 INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE "tx_aggregate" ADD COLUMN locktime INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE "tx_aggregate" ADD COLUMN rbf INTEGER NOT NULL DEFAULT 0`,
+		},
+		down: append(append(rebuildTable("transaction", `
+			id                INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid              BLOB UNIQUE NOT NULL,
+			first_seen        INTEGER,
+			last_removed      INTEGER,
+			fee               INTEGER,
+			weight            INTEGER,
+			expired           INTEGER,
+			heuristics        INTEGER,
+			op_return_outputs INTEGER,
+			op_return_size    INTEGER,
+			witness_size      INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics, op_return_outputs, op_return_size, witness_size",
+		), `CREATE INDEX transaction_first_seen ON "transaction" (first_seen)`),
+			rebuildTable("tx_aggregate", `
+			resolution     INTEGER NOT NULL,
+			time           INTEGER NOT NULL,
+			transactions   INTEGER NOT NULL,
+			vsize          INTEGER NOT NULL,
+			fees           INTEGER NOT NULL,
+			median_feerate REAL,
+			PRIMARY KEY (resolution, time)`,
+				"resolution, time, transactions, vsize, fees, median_feerate",
+			)...),
+	},
+	{
+		// child transactions that arrived before one of their parents, see PackageEvents
+		version: 21,
+		statements: []string{
+			`CREATE TABLE "package_event" (
+				child_txid        BLOB NOT NULL,
+				parent_txid       BLOB NOT NULL,
+				child_first_seen  INTEGER NOT NULL,
+				parent_first_seen INTEGER NOT NULL,
+				delay_ms          INTEGER NOT NULL,
+				PRIMARY KEY (child_txid, parent_txid)
+			)`,
+			`CREATE INDEX package_event_child_first_seen ON "package_event" (child_first_seen)`,
+		},
+		down: []string{
+			`DROP TABLE "package_event"`,
+		},
+	},
+	{
+		// transactions resolved by the orphan pool of the daemon
+		version: 22,
+		statements: []string{
+			`CREATE TABLE "orphan_resolution" (
+				txid       BLOB PRIMARY KEY NOT NULL,
+				first_seen INTEGER NOT NULL,
+				parents    INTEGER NOT NULL,
+				delay_ms   INTEGER NOT NULL
+			)`,
+			`CREATE INDEX orphan_resolution_first_seen ON "orphan_resolution" (first_seen)`,
+		},
+		down: []string{
+			`DROP TABLE "orphan_resolution"`,
+		},
+	},
+	{
+		// serialized transactions, see TransactionRaw
+		version: 23,
+		statements: []string{
+			`CREATE TABLE "transaction_raw" (
+				transaction_id INTEGER PRIMARY KEY REFERENCES "transaction" (id) NOT NULL,
+				raw            BLOB NOT NULL
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "transaction_raw"`,
+		},
+	},
+	{
+		// intervals of the network first-seen times, see InsertFirstSeenEstimates
+		version: 24,
+		statements: []string{
+			`CREATE TABLE "first_seen_estimate" (
+				transaction_id INTEGER PRIMARY KEY REFERENCES "transaction" (id) NOT NULL,
+				lower          INTEGER NOT NULL,
+				upper          INTEGER NOT NULL,
+				sources        INTEGER NOT NULL
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "first_seen_estimate"`,
+		},
+	},
+	{
+		// membership in the current best chain, see InsertBlock
+		version: 25,
+		statements: []string{
+			`ALTER TABLE "block" ADD COLUMN in_best_chain INTEGER NOT NULL DEFAULT 0`,
+			markBestChain,
+			`CREATE INDEX block_in_best_chain ON "block" (in_best_chain, height)`,
+		},
+		down: append(rebuildTable("block", `
+			id                  INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			hash                BLOB (32) UNIQUE NOT NULL,
+			parent              BLOB (32),
+			first_seen          INTEGER,
+			height              INTEGER,
+			is_best             INTEGER,
+			weight              INTEGER,
+			tx_count            INTEGER,
+			miner               TEXT,
+			near_empty          INTEGER,
+			bits                INTEGER,
+			encoded_time        INTEGER,
+			first_seen_interval INTEGER,
+			header_interval     INTEGER`,
+			"id, hash, parent, first_seen, height, is_best, weight, tx_count, miner, near_empty, bits, encoded_time, "+
+				"first_seen_interval, header_interval",
+		), `CREATE INDEX block_height ON "block" (height)`, `CREATE INDEX block_first_seen ON "block" (first_seen)`),
+	},
+	{
+		// number of inputs and outputs and the estimated sigop cost of transactions, NULL if unknown
+		version: 26,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN inputs INTEGER`,
+			`ALTER TABLE "transaction" ADD COLUMN outputs INTEGER`,
+			`ALTER TABLE "transaction" ADD COLUMN sigop_cost INTEGER`,
+		},
+		down: append(rebuildTable("transaction", `
+			id                INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid              BLOB UNIQUE NOT NULL,
+			first_seen        INTEGER,
+			last_removed      INTEGER,
+			fee               INTEGER,
+			weight            INTEGER,
+			expired           INTEGER,
+			heuristics        INTEGER,
+			op_return_outputs INTEGER,
+			op_return_size    INTEGER,
+			witness_size      INTEGER,
+			version           INTEGER,
+			locktime          INTEGER,
+			rbf               INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics, op_return_outputs, op_return_size, "+
+				"witness_size, version, locktime, rbf",
+		), `CREATE INDEX transaction_first_seen ON "transaction" (first_seen)`),
+	},
+	{
+		// dust outputs created by transactions per script type, see DustReport
+		version: 27,
+		statements: []string{
+			`CREATE TABLE "transaction_dust" (
+				transaction_id INTEGER REFERENCES "transaction" (id) NOT NULL,
+				script_type    TEXT NOT NULL,
+				outputs        INTEGER NOT NULL,
+				value          INTEGER NOT NULL,
+				PRIMARY KEY (transaction_id, script_type)
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "transaction_dust"`,
+		},
+	},
+	{
+		// change of the UTXO set per block, see UTXODeltas
+		version: 28,
+		statements: []string{
+			`CREATE TABLE "block_utxo_delta" (
+				block_id          INTEGER PRIMARY KEY NOT NULL,
+				created           INTEGER NOT NULL,
+				spent             INTEGER NOT NULL,
+				created_value     INTEGER NOT NULL,
+				spent_value       INTEGER NOT NULL,
+				unspendable_value INTEGER NOT NULL,
+				fees              INTEGER NOT NULL
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "block_utxo_delta"`,
+		},
+	},
+	{
+		// transactions above the whale threshold, see WhaleTransactions
+		version: 29,
+		statements: []string{
+			`CREATE TABLE "transaction_whale" (
+				transaction_id INTEGER PRIMARY KEY REFERENCES "transaction" (id) NOT NULL,
+				value          INTEGER NOT NULL
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "transaction_whale"`,
+		},
+	},
+	{
+		// total output value of transactions, NULL if unknown
+		version: 30,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN output_value INTEGER`,
+		},
+		down: append(rebuildTable("transaction", `
+			id                INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid              BLOB UNIQUE NOT NULL,
+			first_seen        INTEGER,
+			last_removed      INTEGER,
+			fee               INTEGER,
+			weight            INTEGER,
+			expired           INTEGER,
+			heuristics        INTEGER,
+			op_return_outputs INTEGER,
+			op_return_size    INTEGER,
+			witness_size      INTEGER,
+			version           INTEGER,
+			locktime          INTEGER,
+			rbf               INTEGER,
+			inputs            INTEGER,
+			outputs           INTEGER,
+			sigop_cost        INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics, op_return_outputs, op_return_size, "+
+				"witness_size, version, locktime, rbf, inputs, outputs, sigop_cost",
+		), `CREATE INDEX transaction_first_seen ON "transaction" (first_seen)`),
+	},
+	{
+		// results of the analyzers of transactions and blocks, see AnalysisResults
+		version: 31,
+		statements: []string{
+			`CREATE TABLE "analysis_result" (
+				id             INTEGER PRIMARY KEY NOT NULL,
+				analyzer       TEXT NOT NULL,
+				name           TEXT NOT NULL,
+				transaction_id INTEGER REFERENCES "transaction" (id),
+				block_id       INTEGER REFERENCES "block" (id),
+				time           INTEGER NOT NULL,
+				value          REAL NOT NULL,
+				data           TEXT
+			)`,
+			`CREATE INDEX analysis_result_analyzer_time ON "analysis_result" (analyzer, time)`,
+			// a value is stored once per transaction or block
+			`CREATE UNIQUE INDEX analysis_result_transaction ON "analysis_result" (transaction_id, analyzer, name)
+				WHERE transaction_id IS NOT NULL`,
+			`CREATE UNIQUE INDEX analysis_result_block ON "analysis_result" (block_id, analyzer, name)
+				WHERE block_id IS NOT NULL`,
+		},
+		down: []string{
+			`DROP TABLE "analysis_result"`,
+		},
+	},
+	{
+		// fraction of the transactions stored since a time, see SamplingRates
+		version: 32,
+		statements: []string{
+			`CREATE TABLE "sampling_rate" (
+				time INTEGER NOT NULL,
+				rate REAL NOT NULL
+			)`,
+		},
+		down: []string{
+			`DROP TABLE "sampling_rate"`,
+		},
+	},
+	{
+		// witness txid of transactions, NULL without witness, and the replacements of the witness,
+		// see WitnessReplacements
+		version: 33,
+		statements: []string{
+			`ALTER TABLE "transaction" ADD COLUMN wtxid BLOB`,
+			`CREATE TABLE "transaction_witness_replacement" (
+				transaction_id INTEGER REFERENCES "transaction" (id) NOT NULL,
+				time           INTEGER NOT NULL,
+				old_wtxid      BLOB NOT NULL,
+				new_wtxid      BLOB NOT NULL,
+				old_weight     INTEGER NOT NULL,
+				new_weight     INTEGER NOT NULL
+			)`,
+			`CREATE INDEX transaction_witness_replacement_time ON "transaction_witness_replacement" (time)`,
+			`CREATE INDEX transaction_witness_replacement_transaction
+				ON "transaction_witness_replacement" (transaction_id)`,
+		},
+		down: append(append([]string{`DROP TABLE "transaction_witness_replacement"`}, rebuildTable("transaction", `
+			id                INTEGER PRIMARY KEY UNIQUE NOT NULL,
+			txid              BLOB UNIQUE NOT NULL,
+			first_seen        INTEGER,
+			last_removed      INTEGER,
+			fee               INTEGER,
+			weight            INTEGER,
+			expired           INTEGER,
+			heuristics        INTEGER,
+			op_return_outputs INTEGER,
+			op_return_size    INTEGER,
+			witness_size      INTEGER,
+			version           INTEGER,
+			locktime          INTEGER,
+			rbf               INTEGER,
+			inputs            INTEGER,
+			outputs           INTEGER,
+			sigop_cost        INTEGER,
+			output_value      INTEGER`,
+			"id, txid, first_seen, last_removed, fee, weight, expired, heuristics, op_return_outputs, op_return_size, "+
+				"witness_size, version, locktime, rbf, inputs, outputs, sigop_cost, output_value",
+		)...), `CREATE INDEX transaction_first_seen ON "transaction" (first_seen)`),
+	},
+	{
+		// fee updates of transactions reported again, see InsertTransactions
+		version: 34,
+		statements: []string{
+			`CREATE TABLE "transaction_fee_update" (
+				transaction_id INTEGER REFERENCES "transaction" (id) NOT NULL,
+				time           INTEGER NOT NULL,
+				old_fee        INTEGER NOT NULL,
+				new_fee        INTEGER NOT NULL
+			)`,
+			`CREATE INDEX transaction_fee_update_time ON "transaction_fee_update" (time)`,
+			`CREATE INDEX transaction_fee_update_transaction ON "transaction_fee_update" (transaction_id)`,
+		},
+		down: []string{`DROP TABLE "transaction_fee_update"`},
+	},
+	{
+		// feerate histograms of the mempool imported from other collectors, see ImportedHistograms
+		version: 35,
+		statements: []string{
+			`CREATE TABLE "imported_histogram" (
+				source       TEXT NOT NULL,
+				time         INTEGER NOT NULL,
+				feerate      REAL NOT NULL,
+				transactions INTEGER NOT NULL,
+				vsize        INTEGER NOT NULL,
+				fees         INTEGER NOT NULL,
+				PRIMARY KEY (source, time, feerate)
+			)`,
+		},
+		down: []string{`DROP TABLE "imported_histogram"`},
+	},
+	{
+		// catalog of reorgs, see Reorgs
+		version: 36,
+		statements: []string{
+			`CREATE TABLE "reorg" (
+				id           INTEGER PRIMARY KEY NOT NULL,
+				time         INTEGER NOT NULL,
+				fork_block   INTEGER REFERENCES "block" (id) NOT NULL,
+				old_tip      INTEGER REFERENCES "block" (id) NOT NULL,
+				new_tip      INTEGER REFERENCES "block" (id) NOT NULL,
+				depth        INTEGER NOT NULL,
+				transactions INTEGER NOT NULL,
+				unconfirmed  INTEGER NOT NULL,
+				duration     INTEGER NOT NULL
+			)`,
+			`CREATE INDEX reorg_time ON "reorg" (time)`,
+			`CREATE TABLE "reorg_block" (
+				reorg_id INTEGER REFERENCES "reorg" (id) NOT NULL,
+				block_id INTEGER REFERENCES "block" (id) NOT NULL,
+				PRIMARY KEY (reorg_id, block_id)
+			)`,
+		},
+		down: []string{`DROP TABLE "reorg_block"`, `DROP TABLE "reorg"`},
+	},
+	{
+		// outcomes of conflicts, see DoubleSpends
+		version: 37,
+		statements: []string{
+			`CREATE TABLE "double_spend" (
+				transaction_id       INTEGER REFERENCES "transaction" (id) NOT NULL,
+				confirmed_txid       BLOB NOT NULL,
+				confirmed_first_seen INTEGER NOT NULL,
+				block_id             INTEGER REFERENCES "block" (id) NOT NULL,
+				time                 INTEGER NOT NULL,
+				conflict_time        INTEGER NOT NULL,
+				fee_difference       INTEGER,
+				PRIMARY KEY (transaction_id, confirmed_txid)
+			)`,
+			`CREATE INDEX double_spend_time ON "double_spend" (time)`,
+		},
+		down: []string{`DROP TABLE "double_spend"`},
+	},
+	{
+		// named mempool snapshots that are kept regardless of retention, see PinSnapshot
+		version: 38,
+		statements: []string{
+			`CREATE TABLE "pinned_snapshot" (
+				name         TEXT PRIMARY KEY NOT NULL,
+				time         INTEGER NOT NULL,
+				created      INTEGER NOT NULL,
+				transactions INTEGER NOT NULL,
+				checksum     TEXT NOT NULL,
+				data         BLOB NOT NULL
+			)`,
+		},
+		down: []string{`DROP TABLE "pinned_snapshot"`},
+	},
+	{
+		// txids received via `getrawmempool` were stored in the RPC byte order, those received via ZMQ
+		// in the internal byte order, so copies of a transaction from both sources were not merged.
+		// Nothing marks the source of a row reliably, so only pairs of a row and the reversed txid of
+		// a row received via ZMQ are merged. Other rows are kept as they are. The merged copies are not
+		// restored by the down migration, older releases read the remaining rows as well.
+		version:    39,
+		statements: mergeRPCTxIDs(),
+		down:       []string{`SELECT 1`},
+	},
+	{
+		// the API shows txids in the RPC byte order, searches by prefix compare them in that order
+		version:    40,
+		statements: []string{`CREATE INDEX "transaction_rpc_txid" ON "transaction" (` + rpcTxID + `)`},
+		down:       []string{`DROP INDEX "transaction_rpc_txid"`},
+	},
+	{
+		// why a transaction left the mempool without a block, if known. Only valid while `time` is
+		// the `expired` time of the transaction, see Storage.MarkDrops.
+		version: 41,
+		statements: []string{
+			`CREATE TABLE "transaction_drop" (
+				transaction_id INTEGER PRIMARY KEY REFERENCES "transaction" (id) NOT NULL,
+				time           INTEGER NOT NULL,
+				reason         TEXT NOT NULL
+			)`,
+		},
+		down: []string{`DROP TABLE "transaction_drop"`},
+	},
+}
+
+// rebuildTable returns statements that recreate `table` with the column definitions `schema`
+// and copy `columns` from the old table.
+// The bundled SQLite does not support DROP COLUMN, down migrations use this instead.
+func rebuildTable(table, schema, columns string) []string {
+	tmp := table + "_rebuild"
+	return []string{
+		fmt.Sprintf(`CREATE TABLE "%s" (%s)`, tmp, schema),
+		fmt.Sprintf(`INSERT INTO "%s" SELECT %s FROM "%s"`, tmp, columns, table),
+		fmt.Sprintf(`DROP TABLE "%s"`, table),
+		fmt.Sprintf(`ALTER TABLE "%s" RENAME TO "%s"`, tmp, table),
+	}
+}
+
+// zmqTransactions selects transactions that were received via ZMQ and have a txid in the internal
+// byte order: only ZMQ transactions are linked to blocks, have inputs, raw data or a witness size.
+// Older releases did not store these for all ZMQ transactions, unconfirmed ones may not match.
+const zmqTransactions = `witness_size IS NOT NULL
+	OR id IN (SELECT transaction_id FROM transaction_block)
+	OR id IN (SELECT transaction_id FROM transaction_input)
+	OR id IN (SELECT transaction_id FROM transaction_raw)`
+
+// referencingTablesV39 are the tables referencing "transaction" at version 39, see referencingTables
+var referencingTablesV39 = []string{
+	"transaction_block", "transaction_input", "transaction_output", "transaction_raw",
+	"first_seen_estimate", "transaction_dust", "transaction_whale", "analysis_result",
+	"transaction_witness_replacement", "transaction_fee_update", "double_spend",
+}
+
+// mergeRPCTxIDs returns statements that delete transactions whose reversed txid is the txid of one
+// of zmqTransactions, i.e. copies received via `getrawmempool`, keeping their first-seen time in the
+// ZMQ copy if it is earlier. Rows without such a pair are not changed.
+func mergeRPCTxIDs() []string {
+	statements := []string{
+		fmt.Sprintf(
+			`CREATE TEMP TABLE unmarked AS SELECT id, first_seen, %s AS reversed FROM "transaction" WHERE NOT (%s)`,
+			rpcTxID, zmqTransactions,
+		),
+		`CREATE TEMP TABLE rpc_copy AS
+			SELECT unmarked.id, unmarked.first_seen, zmq.txid FROM unmarked
+			JOIN "transaction" zmq ON zmq.txid = unmarked.reversed
+			WHERE zmq.id NOT IN (SELECT id FROM unmarked)`,
+		`DROP TABLE unmarked`,
+		`UPDATE "transaction" SET first_seen = MIN(first_seen, COALESCE(
+			(SELECT rpc_copy.first_seen FROM rpc_copy WHERE rpc_copy.txid = "transaction".txid), first_seen
+		)) WHERE txid IN (SELECT txid FROM rpc_copy)`,
+	}
+	for _, table := range referencingTablesV39 {
+		statements = append(statements, fmt.Sprintf(
+			`DELETE FROM "%s" WHERE transaction_id IN (SELECT id FROM rpc_copy)`, table,
+		))
+	}
+	return append(statements,
+		`DELETE FROM "transaction" WHERE id IN (SELECT id FROM rpc_copy)`,
+		`DROP TABLE rpc_copy`,
+	)
+}
+
+// applyMigration runs `statements` and sets the version to `version` in a single db transaction
+func (s *Storage) applyMigration(version int, statements []string) error {
+	log.Infof("Migrating database to version %d", version)
+
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		if _, err := dbTx.Exec(statement); err != nil {
+			_ = dbTx.Rollback()
+			return errors.Errorf("error in migration to version %d: %s", version, err)
+		}
+	}
+
+	if _, err := dbTx.Exec(`UPDATE config SET version = ?`, version); err != nil {
+		_ = dbTx.Rollback()
+		return errors.Errorf("could not update version to %d: %s", version, err)
+	}
+
+	return dbTx.Commit()
+}
+
+// Downgrade reverts migrations until the schema has version `toVersion`.
+// Data that is only stored in the newer schema versions is lost.
+// This allows running an older release on the database.
+func (s *Storage) Downgrade(toVersion int) error {
+	fromVersion, err := s.getVersion()
+	if err != nil {
+		return err
+	}
+	if toVersion < baseVersion || toVersion > fromVersion {
+		return errors.Errorf("cannot downgrade from version %d to %d", fromVersion, toVersion)
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version > fromVersion || m.version <= toVersion {
+			continue
+		}
+		if err := s.applyMigration(m.version-1, m.down); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
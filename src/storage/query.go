@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// Query is expected by `queryBlocks` and `QueryTransactions`.
+// It is implemented by TransactionQuery and BlockQuery.
+type Query interface {
+	// Where returns the WHERE portion of an SQL query with `?` placeholders, and their values
+	Where() (string, []interface{})
+	// Order returns the ORDER portion of an SQL query
+	Order() string
+	// Limit returns the LIMIT portion of an SQL query, 0 for no limit
+	Limit() int
+}
+
+// formatQuery returns a SELECT statement of `fields` from `table` filtered by `q`, and its parameters
+func formatQuery(fields []string, table string, q Query) (string, []interface{}) {
+	query := fmt.Sprintf(`SELECT %s FROM "%s"`, strings.Join(fields, ","), table)
+
+	where, args := q.Where()
+	if where != "" {
+		query = fmt.Sprintf("%s WHERE %s", query, where)
+	}
+
+	if q.Order() != "" {
+		query = fmt.Sprintf("%s ORDER BY %s", query, q.Order())
+	}
+
+	if q.Limit() > 0 {
+		query = fmt.Sprintf("%s LIMIT %d", query, q.Limit())
+	}
+
+	return query, args
+}
+
+// conditions collects the conditions of a WHERE clause, which must all hold
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add adds the condition `clause` with the values `args` of its placeholders
+func (c *conditions) add(clause string, args ...interface{}) {
+	c.clauses = append(c.clauses, "("+clause+")")
+	c.args = append(c.args, args...)
+}
+
+// addBool adds `clause` if `f` is FilterTrue and its negation if `f` is FilterFalse
+func (c *conditions) addBool(f BoolFilter, clause string) {
+	switch f {
+	case FilterTrue:
+		c.add(clause)
+	case FilterFalse:
+		c.add("NOT (" + clause + ")")
+	}
+}
+
+func (c *conditions) where() (string, []interface{}) {
+	return strings.Join(c.clauses, " AND "), c.args
+}
+
+// BoolFilter filters rows by a property that is either true or false
+type BoolFilter int
+
+const (
+	// FilterAny does not filter
+	FilterAny BoolFilter = iota
+	// FilterTrue selects rows with the property
+	FilterTrue
+	// FilterFalse selects rows without the property
+	FilterFalse
+)
+
+// Cursor is a position in results ordered by a time and the database id, for keyset pagination
+type Cursor struct {
+	Time time.Time
+	DBID int64
+}
+
+// rpcTxID reverses the bytes of the txid, which are stored in the internal byte order, to the RPC
+// byte order. Migration 40 indexes it for searches by prefix.
+const rpcTxID = `CAST(` +
+	`substr(txid, 32, 1) || substr(txid, 31, 1) || substr(txid, 30, 1) || substr(txid, 29, 1) || ` +
+	`substr(txid, 28, 1) || substr(txid, 27, 1) || substr(txid, 26, 1) || substr(txid, 25, 1) || ` +
+	`substr(txid, 24, 1) || substr(txid, 23, 1) || substr(txid, 22, 1) || substr(txid, 21, 1) || ` +
+	`substr(txid, 20, 1) || substr(txid, 19, 1) || substr(txid, 18, 1) || substr(txid, 17, 1) || ` +
+	`substr(txid, 16, 1) || substr(txid, 15, 1) || substr(txid, 14, 1) || substr(txid, 13, 1) || ` +
+	`substr(txid, 12, 1) || substr(txid, 11, 1) || substr(txid, 10, 1) || substr(txid, 9, 1) || ` +
+	`substr(txid, 8, 1) || substr(txid, 7, 1) || substr(txid, 6, 1) || substr(txid, 5, 1) || ` +
+	`substr(txid, 4, 1) || substr(txid, 3, 1) || substr(txid, 2, 1) || substr(txid, 1, 1) AS BLOB)`
+
+// TxOrder is the order of the results of a TransactionQuery
+type TxOrder string
+
+// Orders of transactions. Ties are broken by the database id, except for unique columns.
+const (
+	TxOrderNone        TxOrder = ""
+	TxOrderID          TxOrder = "id ASC"
+	TxOrderTxID        TxOrder = rpcTxID + " ASC"
+	TxOrderFirstSeen   TxOrder = "first_seen ASC, id ASC"
+	TxOrderExpired     TxOrder = "expired ASC, id ASC"
+	TxOrderExpiredDesc TxOrder = "expired DESC, id DESC"
+)
+
+// TransactionQuery selects transactions matching all set fields. Times are compared in seconds.
+type TransactionQuery struct {
+	TxID *types.Hash32
+	// Range [TxIDFrom, TxIDBefore) of txids in the RPC byte order, unbounded above if TxIDBefore is nil
+	TxIDFrom   []byte
+	TxIDBefore []byte
+
+	// Inclusive range of the first-seen time
+	FirstSeenFrom *time.Time
+	FirstSeenTo   *time.Time
+	// Transactions after the cursor in TxOrderFirstSeen
+	FirstSeenAfter *Cursor
+	// Transactions after the cursor in TxOrderExpired
+	ExpiredAfter *Cursor
+	// Transactions in the mempool at the time: first seen before or at it,
+	// neither removed nor expired until after it
+	InMempoolAt *time.Time
+
+	// Transactions with a removal time, i.e. that left the mempool in a block
+	Removed BoolFilter
+	// Transactions with an expiry time, see MarkExpired
+	Expired BoolFilter
+	// Transactions stored serialized, see TransactionRaw
+	HasRaw BoolFilter
+	// Transactions known to have OP_RETURN outputs, see types.Transaction.IsDataCarrier
+	DataCarrier BoolFilter
+
+	// Inclusive range of the feerate in sat/vB
+	MinFeerate *float64
+	MaxFeerate *float64
+
+	// Lower bounds of the number of inputs and outputs and the sigop cost,
+	// transactions without known counts do not match
+	MinInputs    *int
+	MinOutputs   *int
+	MinSigOpCost *int
+
+	// Transactions in a block of the best chain
+	Confirmed BoolFilter
+	// Inclusive range of the heights of best-chain blocks containing the transaction
+	MinHeight *uint32
+	MaxHeight *uint32
+
+	OrderBy TxOrder
+	// Maximum number of transactions, 0 for no limit
+	MaxResults int
+}
+
+// bestBlockOf matches best-chain blocks containing the transaction of the outer query
+const bestBlockOf = `SELECT 1 FROM "transaction_block" tb JOIN "block" b ON b.id = tb.block_id ` +
+	`WHERE tb.transaction_id = "transaction".id AND b.in_best_chain = 1`
+
+// Where implements the Query interface
+func (q TransactionQuery) Where() (string, []interface{}) {
+	var c conditions
+	if q.TxID != nil {
+		c.add("txid = ?", q.TxID[:])
+	}
+	if q.TxIDFrom != nil {
+		c.add(rpcTxID+" >= ?", q.TxIDFrom)
+	}
+	if q.TxIDBefore != nil {
+		c.add(rpcTxID+" < ?", q.TxIDBefore)
+	}
+
+	if q.FirstSeenFrom != nil {
+		c.add("first_seen >= ?", q.FirstSeenFrom.Unix())
+	}
+	if q.FirstSeenTo != nil {
+		c.add("first_seen <= ?", q.FirstSeenTo.Unix())
+	}
+	if q.FirstSeenAfter != nil {
+		t := q.FirstSeenAfter.Time.Unix()
+		c.add("(first_seen > ?) OR ((first_seen = ?) AND (id > ?))", t, t, q.FirstSeenAfter.DBID)
+	}
+	if q.ExpiredAfter != nil {
+		t := q.ExpiredAfter.Time.Unix()
+		c.add("(expired > ?) OR ((expired = ?) AND (id > ?))", t, t, q.ExpiredAfter.DBID)
+	}
+	if q.InMempoolAt != nil {
+		t := q.InMempoolAt.Unix()
+		c.add("first_seen <= ?", t)
+		c.add("(last_removed IS NULL) OR (last_removed > ?)", t)
+		c.add("(expired IS NULL) OR (expired > ?)", t)
+	}
+
+	c.addBool(q.Removed, "last_removed IS NOT NULL")
+	c.addBool(q.Expired, "expired IS NOT NULL")
+	c.addBool(q.HasRaw, `id IN (SELECT transaction_id FROM "transaction_raw")`)
+	c.addBool(q.DataCarrier, "COALESCE(op_return_outputs, 0) > 0")
+
+	const feerate = "(CASE WHEN weight > 0 THEN fee * 4.0 / weight ELSE 0 END)"
+	if q.MinFeerate != nil {
+		c.add(feerate+" >= ?", *q.MinFeerate)
+	}
+	if q.MaxFeerate != nil {
+		c.add(feerate+" <= ?", *q.MaxFeerate)
+	}
+	if q.MinInputs != nil {
+		c.add("inputs >= ?", *q.MinInputs)
+	}
+	if q.MinOutputs != nil {
+		c.add("outputs >= ?", *q.MinOutputs)
+	}
+	if q.MinSigOpCost != nil {
+		c.add("sigop_cost >= ?", *q.MinSigOpCost)
+	}
+
+	c.addBool(q.Confirmed, "EXISTS ("+bestBlockOf+")")
+	if q.MinHeight != nil || q.MaxHeight != nil {
+		var minHeight, maxHeight int64 = 0, 1<<32 - 1
+		if q.MinHeight != nil {
+			minHeight = int64(*q.MinHeight)
+		}
+		if q.MaxHeight != nil {
+			maxHeight = int64(*q.MaxHeight)
+		}
+		c.add("EXISTS ("+bestBlockOf+" AND b.height BETWEEN ? AND ?)", minHeight, maxHeight)
+	}
+
+	return c.where()
+}
+
+// Order implements the Query interface
+func (q TransactionQuery) Order() string {
+	return string(q.OrderBy)
+}
+
+// Limit implements the Query interface
+func (q TransactionQuery) Limit() int {
+	return q.MaxResults
+}
+
+// BlockOrder is the order of the results of a BlockQuery
+type BlockOrder string
+
+// Orders of blocks
+const (
+	BlockOrderNone          BlockOrder = ""
+	BlockOrderFirstSeen     BlockOrder = "first_seen ASC, id ASC"
+	BlockOrderFirstSeenDesc BlockOrder = "first_seen DESC, id DESC"
+	// highest blocks first, of blocks at the same height the one received last
+	BlockOrderHeightDesc BlockOrder = "height DESC, first_seen DESC, id DESC"
+	// blocks of the best chain first, then by first-seen time
+	BlockOrderBestFirst BlockOrder = "in_best_chain DESC, first_seen ASC, id ASC"
+)
+
+// BlockQuery selects blocks matching all set fields. Times are compared in seconds.
+type BlockQuery struct {
+	DBID *int64
+	Hash *types.Hash32
+
+	// Inclusive range of the height
+	MinHeight *uint32
+	MaxHeight *uint32
+
+	// Blocks that were the chain tip when they were received, see types.Block.IsBest
+	Best BoolFilter
+	// Blocks of the current best chain, see types.Block.InBestChain
+	BestChain BoolFilter
+
+	// Inclusive range of the first-seen time
+	FirstSeenFrom *time.Time
+	FirstSeenTo   *time.Time
+	// Blocks after the cursor in BlockOrderFirstSeen
+	FirstSeenAfter *Cursor
+
+	OrderBy BlockOrder
+	// Maximum number of blocks, 0 for no limit
+	MaxResults int
+}
+
+// Where implements the Query interface
+func (q BlockQuery) Where() (string, []interface{}) {
+	var c conditions
+	if q.DBID != nil {
+		c.add("id = ?", *q.DBID)
+	}
+	if q.Hash != nil {
+		c.add("hash = ?", q.Hash[:])
+	}
+	if q.MinHeight != nil {
+		c.add("height >= ?", *q.MinHeight)
+	}
+	if q.MaxHeight != nil {
+		c.add("height <= ?", *q.MaxHeight)
+	}
+	c.addBool(q.Best, "is_best = 1")
+	c.addBool(q.BestChain, "in_best_chain = 1")
+	if q.FirstSeenFrom != nil {
+		c.add("first_seen >= ?", q.FirstSeenFrom.Unix())
+	}
+	if q.FirstSeenTo != nil {
+		c.add("first_seen <= ?", q.FirstSeenTo.Unix())
+	}
+	if q.FirstSeenAfter != nil {
+		t := q.FirstSeenAfter.Time.Unix()
+		c.add("(first_seen > ?) OR ((first_seen = ?) AND (id > ?))", t, t, q.FirstSeenAfter.DBID)
+	}
+	return c.where()
+}
+
+// Order implements the Query interface
+func (q BlockQuery) Order() string {
+	return string(q.OrderBy)
+}
+
+// Limit implements the Query interface
+func (q BlockQuery) Limit() int {
+	return q.MaxResults
+}
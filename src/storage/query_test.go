@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestTransactionQuery_Where(t *testing.T) {
+	where, args := TransactionQuery{}.Where()
+	assert.Equal(t, "", where)
+	assert.Empty(t, args)
+
+	at := time.Unix(100, 0)
+	minFeerate := 2.5
+	where, args = TransactionQuery{
+		InMempoolAt: &at,
+		Expired:     FilterFalse,
+		MinFeerate:  &minFeerate,
+	}.Where()
+	assert.Equal(t,
+		"(first_seen <= ?) AND ((last_removed IS NULL) OR (last_removed > ?)) AND "+
+			"((expired IS NULL) OR (expired > ?)) AND (NOT (expired IS NOT NULL)) AND "+
+			"((CASE WHEN weight > 0 THEN fee * 4.0 / weight ELSE 0 END) >= ?)",
+		where,
+	)
+	assert.Equal(t, []interface{}{int64(100), int64(100), int64(100), 2.5}, args)
+
+	// values are never part of the statement
+	txid := test.GenerateHash32("tx")
+	where, args = TransactionQuery{TxID: &txid}.Where()
+	assert.Equal(t, "(txid = ?)", where)
+	assert.Equal(t, []interface{}{txid[:]}, args)
+}
+
+func TestStorage_TransactionQuery(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	// feerates 1, 2, 3 and 4 sat/vB
+	var txs []types.Transaction
+	for i := 1; i <= 4; i++ {
+		tx := *NewTxAtOffset(10 * i)
+		tx.Fee = uint64(100 * i)
+		tx.Weight = 400
+		// counts are unknown for the first transaction
+		if i > 1 {
+			tx.Counts = &types.TxCounts{Inputs: i, Outputs: 1, SigOpCost: 4 * i}
+		}
+		txs = append(txs, tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	blocks := []types.Block{
+		{Hash: test.GenerateHash32("b10"), FirstSeen: GetTime(100), TxIDs: []types.Hash32{txs[0].TxID}, IsBest: true, Height: 10},
+		{
+			Hash: test.GenerateHash32("b11"), Parent: test.GenerateHash32("b10"), FirstSeen: GetTime(110),
+			TxIDs: []types.Hash32{txs[1].TxID}, IsBest: true, Height: 11,
+		},
+	}
+	for i := range blocks {
+		_, err = st.InsertBlock(&blocks[i])
+		require.NoError(t, err)
+	}
+
+	query := func(q TransactionQuery) (res []types.Hash32) {
+		txIter, err := st.QueryTransactions(q)
+		require.NoError(t, err)
+		stored, err := txIter.Collect()
+		require.NoError(t, err)
+		for _, tx := range stored {
+			res = append(res, tx.TxID)
+		}
+		return res
+	}
+	txids := func(indexes ...int) (res []types.Hash32) {
+		for _, i := range indexes {
+			res = append(res, txs[i].TxID)
+		}
+		return res
+	}
+
+	minFeerate, maxFeerate := 1.5, 3.0
+	assert.Equal(t, txids(1, 2), query(TransactionQuery{MinFeerate: &minFeerate, MaxFeerate: &maxFeerate, OrderBy: TxOrderID}))
+	assert.Equal(t, txids(0, 1), query(TransactionQuery{Confirmed: FilterTrue, OrderBy: TxOrderID}))
+	assert.Equal(t, txids(2, 3), query(TransactionQuery{Confirmed: FilterFalse, OrderBy: TxOrderID}))
+
+	height := uint32(11)
+	assert.Equal(t, txids(1), query(TransactionQuery{MinHeight: &height}))
+	assert.Equal(t, txids(0, 1), query(TransactionQuery{MaxHeight: &height, OrderBy: TxOrderID}))
+
+	from, to := GetTime(20), GetTime(30)
+	assert.Equal(t, txids(1, 2), query(TransactionQuery{FirstSeenFrom: &from, FirstSeenTo: &to, OrderBy: TxOrderFirstSeen}))
+	assert.Equal(t, txids(3), query(TransactionQuery{FirstSeenAfter: &Cursor{Time: to, DBID: 3}}))
+	assert.Equal(t, txids(0), query(TransactionQuery{OrderBy: TxOrderFirstSeen, MaxResults: 1}))
+
+	minInputs, minSigOpCost := 2, 12
+	assert.Equal(t, txids(1, 2, 3), query(TransactionQuery{MinInputs: &minInputs, OrderBy: TxOrderID}))
+	assert.Equal(t, txids(2, 3), query(TransactionQuery{MinSigOpCost: &minSigOpCost, OrderBy: TxOrderID}))
+
+	stored, err := st.QueryTransactions(TransactionQuery{TxID: &txs[2].TxID})
+	require.NoError(t, err)
+	res, err := stored.Collect()
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, &types.TxCounts{Inputs: 3, Outputs: 1, SigOpCost: 12}, res[0].Counts)
+
+	// the block at height 11 left the mempool at 110
+	at := GetTime(105)
+	assert.Equal(t, txids(1, 2, 3), query(TransactionQuery{InMempoolAt: &at, OrderBy: TxOrderID}))
+}
+
+func TestStorage_BlockQuery(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	chain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &chain))
+
+	height := uint32(1)
+	blockIter, err := st.queryBlocks(BlockQuery{MinHeight: &height, MaxHeight: &height, OrderBy: BlockOrderFirstSeen})
+	require.NoError(t, err)
+	blocks, err := blockIter.Collect()
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, test.GenerateHash32("2"), blocks[0].Hash)
+	assert.Equal(t, test.GenerateHash32("1.1"), blocks[1].Hash)
+
+	blockIter, err = st.queryBlocks(BlockQuery{Best: FilterFalse})
+	require.NoError(t, err)
+	blocks, err = blockIter.Collect()
+	require.NoError(t, err)
+	require.NotEmpty(t, blocks)
+	for _, b := range blocks {
+		assert.False(t, b.IsBest)
+	}
+
+	to := GetTime(300)
+	block, err := st.queryBlock(BlockQuery{FirstSeenTo: &to, OrderBy: BlockOrderFirstSeenDesc, MaxResults: 1})
+	require.NoError(t, err)
+	assert.Equal(t, test.GenerateHash32("3"), block.Hash)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// blockTxCache holds the database ids of the transactions of blocks for mempools that
+// reconstruct overlapping ranges, so each block is read once
+type blockTxCache struct {
+	mu      sync.Mutex
+	entries map[int64]*blockTxEntry
+}
+
+type blockTxEntry struct {
+	once  sync.Once
+	dbids []int64
+	err   error
+}
+
+func newBlockTxCache() *blockTxCache {
+	return &blockTxCache{entries: map[int64]*blockTxEntry{}}
+}
+
+// get returns the transactions of block `dbid`, reading them from `st` on the first call.
+// Concurrent calls for the same block wait for the first one.
+func (c *blockTxCache) get(st *Storage, dbid int64) ([]int64, error) {
+	c.mu.Lock()
+	e, ok := c.entries[dbid]
+	if !ok {
+		e = &blockTxEntry{}
+		c.entries[dbid] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.dbids, e.err = st.TransactionDBIDsInBlock(dbid)
+	})
+	return e.dbids, e.err
+}
+
+// SampleFunc returns the data of interest of the mempool `m` at `t`, e.g. an aggregate.
+// It is called concurrently for different times and must not keep `m` or its transactions.
+type SampleFunc func(t time.Time, m *Mempool) (interface{}, error)
+
+// ReconstructSeries reconstructs the mempool at each of `times`, which must be ascending, with up
+// to `workers` goroutines and calls `emit` with the samples in the order of `times`.
+//
+// The times are split into one contiguous range per worker. Each worker reconstructs the mempool
+// at the start of its range with NewMempoolAtTime and seeks from there, so a series of many
+// closely spaced times costs `workers` full reconstructions. The transactions of the blocks are
+// read once and shared by the workers. Samples are kept until the ranges before them are done,
+// so they should be small. The first error stops all workers and is returned after they ended.
+func ReconstructSeries(
+	st *Storage, times []time.Time, workers int, sample SampleFunc, emit func(interface{}) error,
+) error {
+	run := func(m *Mempool, times []time.Time, samples []interface{}, stop *int32) error {
+		for i, t := range times {
+			if atomic.LoadInt32(stop) == 1 {
+				return nil
+			}
+			if err := m.Seek(t); err != nil {
+				return err
+			}
+			var err error
+			if samples[i], err = sample(t, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return reconstructSeries(st, times, workers, run, emit)
+}
+
+// Accumulator maintains data derived from the transactions of a mempool, e.g. the totals of
+// feerate buckets, by applying the changes between consecutive times.
+// The transactions passed to Add and Remove are only valid during the call.
+type Accumulator interface {
+	// Add adds a transaction that entered the mempool
+	Add(tx *types.Transaction)
+	// Remove removes a transaction that was added before
+	Remove(tx *types.Transaction)
+	// Sample returns the data at `t`. The result must not change with later calls of Add or Remove.
+	Sample(t time.Time) interface{}
+}
+
+// ReconstructSeriesIncremental is ReconstructSeries for data that can be updated incrementally.
+// Each worker creates an Accumulator with `newAccumulator`, adds the transactions of the mempool
+// at the start of its range and then only applies the MempoolDelta of each step, so a sample
+// costs time in proportion to the change of the mempool rather than to its size.
+func ReconstructSeriesIncremental(
+	st *Storage, times []time.Time, workers int, newAccumulator func() Accumulator, emit func(interface{}) error,
+) error {
+	run := func(m *Mempool, times []time.Time, samples []interface{}, stop *int32) error {
+		acc := newAccumulator()
+		for _, tx := range m.transactions {
+			acc.Add(&tx.Transaction)
+		}
+		for i, t := range times {
+			if atomic.LoadInt32(stop) == 1 {
+				return nil
+			}
+			delta, err := m.SeekDelta(t)
+			if err != nil {
+				return err
+			}
+			for j := range delta.Removed {
+				acc.Remove(&delta.Removed[j].Transaction)
+			}
+			for j := range delta.Added {
+				acc.Add(&delta.Added[j].Transaction)
+			}
+			samples[i] = acc.Sample(t)
+		}
+		return nil
+	}
+	return reconstructSeries(st, times, workers, run, emit)
+}
+
+// withoutDataCarriers is an Accumulator that ignores transactions known to have OP_RETURN outputs
+type withoutDataCarriers struct {
+	Accumulator
+}
+
+// WithoutDataCarriers returns an Accumulator that passes the transactions to `acc` except those
+// known to have OP_RETURN outputs, like types.WithoutDataCarriers
+func WithoutDataCarriers(acc Accumulator) Accumulator {
+	return withoutDataCarriers{acc}
+}
+
+// Add implements the Accumulator interface
+func (a withoutDataCarriers) Add(tx *types.Transaction) {
+	if !tx.IsDataCarrier() {
+		a.Accumulator.Add(tx)
+	}
+}
+
+// Remove implements the Accumulator interface
+func (a withoutDataCarriers) Remove(tx *types.Transaction) {
+	if !tx.IsDataCarrier() {
+		a.Accumulator.Remove(tx)
+	}
+}
+
+// rangeFunc sets `samples` to the samples of the mempool `m` at `times`, starting with `m` at
+// the first time. Returns early without error if `stop` is set.
+type rangeFunc func(m *Mempool, times []time.Time, samples []interface{}, stop *int32) error
+
+// reconstructSeries runs `run` for one range of `times` per worker and emits the samples in order
+func reconstructSeries(st *Storage, times []time.Time, workers int, run rangeFunc, emit func(interface{}) error) error {
+	for i := 1; i < len(times); i++ {
+		if times[i].Before(times[i-1]) {
+			return errors.Errorf("time %s is before %s", times[i], times[i-1])
+		}
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(times) {
+		workers = len(times)
+	}
+
+	blockTxs := newBlockTxCache()
+	samples := make([]interface{}, len(times))
+	var stop int32
+
+	type chunk struct {
+		from, to int
+		done     chan error
+	}
+	chunks := make([]chunk, workers)
+	for i := range chunks {
+		c := chunk{from: i * len(times) / workers, to: (i + 1) * len(times) / workers, done: make(chan error, 1)}
+		chunks[i] = c
+		go func() {
+			m, err := newMempoolAtTime(context.Background(), st, times[c.from], blockTxs, nil)
+			if err == nil {
+				err = run(m, times[c.from:c.to], samples[c.from:c.to], &stop)
+			}
+			c.done <- err
+		}()
+	}
+
+	var res error
+	for _, c := range chunks {
+		err := <-c.done
+		if res != nil {
+			continue
+		}
+		if err != nil {
+			res = err
+			atomic.StoreInt32(&stop, 1)
+			continue
+		}
+		for i := c.from; i < c.to; i++ {
+			if err := emit(samples[i]); err != nil {
+				res = err
+				atomic.StoreInt32(&stop, 1)
+				break
+			}
+			samples[i] = nil
+		}
+	}
+	return res
+}
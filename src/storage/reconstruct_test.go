@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertSeriesTestData stores transactions and blocks and returns times for a series
+func insertSeriesTestData(t *testing.T, st *Storage) []time.Time {
+	var txs []types.Transaction
+	for i := 1; i <= 20; i++ {
+		txs = append(txs, *NewTxAtOffset(10 * i))
+	}
+	_, err := st.InsertTransactions(txs)
+	require.NoError(t, err)
+	// each block confirms the transactions first seen in the 50 seconds before it
+	for height := 1; height <= 3; height++ {
+		block := types.Block{
+			Hash:      test.GenerateHash32(fmt.Sprintf("block-%d", height)),
+			FirstSeen: GetTime(50*height + 5),
+			Height:    uint32(height),
+			IsBest:    true,
+		}
+		if height > 1 {
+			block.Parent = test.GenerateHash32(fmt.Sprintf("block-%d", height-1))
+		}
+		for i := 5 * (height - 1); i < 5*height; i++ {
+			block.TxIDs = append(block.TxIDs, txs[i].TxID)
+		}
+		_, err = st.InsertBlock(&block)
+		require.NoError(t, err)
+	}
+
+	var times []time.Time
+	for offset := 0; offset <= 220; offset += 7 {
+		times = append(times, GetTime(offset))
+	}
+	return times
+}
+
+// sortedTxIDs returns the sorted txids of `txs`
+func sortedTxIDs(txs []types.Transaction) []string {
+	var ids []string
+	for _, tx := range txs {
+		ids = append(ids, tx.TxID.String())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// expectedSeries returns the sorted txids of the mempool at each of `times`
+func expectedSeries(t *testing.T, st *Storage, times []time.Time) []interface{} {
+	var res []interface{}
+	for _, at := range times {
+		m, err := NewMempoolAtTime(st, at)
+		require.NoError(t, err)
+		res = append(res, sortedTxIDs(m.Transactions()))
+	}
+	return res
+}
+
+func TestReconstructSeries(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	times := insertSeriesTestData(t, st)
+	expected := expectedSeries(t, st, times)
+	sample := func(t time.Time, m *Mempool) (interface{}, error) {
+		return sortedTxIDs(m.Transactions()), nil
+	}
+
+	for _, workers := range []int{1, 3, 8, 100} {
+		var res []interface{}
+		err := ReconstructSeries(st, times, workers, sample, func(s interface{}) error {
+			res = append(res, s)
+			return nil
+		})
+		require.NoError(t, err, workers)
+		assert.Equal(t, expected, res, workers)
+	}
+
+	emitErr := errors.New("disk full")
+	n := 0
+	err = ReconstructSeries(st, times, 4, sample, func(interface{}) error {
+		n++
+		if n == 3 {
+			return emitErr
+		}
+		return nil
+	})
+	assert.Equal(t, emitErr, err)
+	assert.Equal(t, 3, n)
+
+	assert.Error(t, ReconstructSeries(st, []time.Time{GetTime(10), GetTime(5)}, 2, sample, nil))
+}
+
+// txidAccumulator implements Accumulator with the set of txids
+type txidAccumulator map[types.Hash32]types.Transaction
+
+func (a txidAccumulator) Add(tx *types.Transaction) {
+	a[tx.TxID] = *tx
+}
+
+func (a txidAccumulator) Remove(tx *types.Transaction) {
+	delete(a, tx.TxID)
+}
+
+func (a txidAccumulator) Sample(t time.Time) interface{} {
+	var txs []types.Transaction
+	for _, tx := range a {
+		txs = append(txs, tx)
+	}
+	return sortedTxIDs(txs)
+}
+
+func TestReconstructSeriesIncremental(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	times := insertSeriesTestData(t, st)
+	expected := expectedSeries(t, st, times)
+	newAccumulator := func() Accumulator { return txidAccumulator{} }
+
+	for _, workers := range []int{1, 3, 100} {
+		var res []interface{}
+		err := ReconstructSeriesIncremental(st, times, workers, newAccumulator, func(s interface{}) error {
+			res = append(res, s)
+			return nil
+		})
+		require.NoError(t, err, workers)
+		assert.Equal(t, expected, res, workers)
+	}
+}
+
+func TestMempool_SeekDelta(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	insertSeriesTestData(t, st)
+	m, err := NewMempoolAtTime(st, GetTime(45))
+	require.NoError(t, err)
+
+	// tx-50 enters, the block at 55 confirms tx-10 to tx-50, tx-60 enters
+	delta, err := m.SeekDelta(GetTime(60))
+	require.NoError(t, err)
+	var added, removed []types.Transaction
+	for _, tx := range delta.Added {
+		added = append(added, tx.Transaction)
+	}
+	for _, tx := range delta.Removed {
+		removed = append(removed, tx.Transaction)
+	}
+	assert.Equal(t, []string{NewTxAtOffset(60).TxID.String()}, sortedTxIDs(added))
+	expectedRemoved := []types.Transaction{
+		*NewTxAtOffset(10), *NewTxAtOffset(20), *NewTxAtOffset(30), *NewTxAtOffset(40),
+	}
+	assert.Equal(t, sortedTxIDs(expectedRemoved), sortedTxIDs(removed))
+}
+
+func TestWithoutDataCarriers(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	var txs []types.Transaction
+	for i := 1; i <= 10; i++ {
+		tx := NewTxAtOffset(10 * i)
+		if i%3 == 0 {
+			tx.OpReturn = &types.OpReturnStats{Outputs: 1, PayloadSize: 40}
+		}
+		txs = append(txs, *tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	var times []time.Time
+	var expected []interface{}
+	for offset := 0; offset <= 120; offset += 15 {
+		times = append(times, GetTime(offset))
+		m, err := NewMempoolAtTime(st, GetTime(offset))
+		require.NoError(t, err)
+		expected = append(expected, sortedTxIDs(types.WithoutDataCarriers(m.Transactions())))
+	}
+
+	newAccumulator := func() Accumulator { return WithoutDataCarriers(txidAccumulator{}) }
+	var res []interface{}
+	err = ReconstructSeriesIncremental(st, times, 2, newAccumulator, func(s interface{}) error {
+		res = append(res, s)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+// slowQueryMaxLength is the length above which queries and parameters are shortened in the log.
+// Queries with txid lists can be hundreds of kilobytes long.
+const slowQueryMaxLength = 1000
+
+// slowQueryLog logs the statements that take longer than a threshold.
+// The methods of a nil *slowQueryLog do nothing.
+type slowQueryLog struct {
+	// in nanoseconds, zero disables the log. Accessed atomically.
+	threshold int64
+	// number of logged statements. Accessed atomically.
+	count uint64
+}
+
+// observe logs `query` if `d` reaches the threshold. In debug mode, the query plan is logged as well.
+func (l *slowQueryLog) observe(conn *sqlite3.SQLiteConn, query string, args []driver.NamedValue, d time.Duration) {
+	if l == nil {
+		return
+	}
+	threshold := time.Duration(atomic.LoadInt64(&l.threshold))
+	if threshold == 0 || d < threshold {
+		return
+	}
+
+	atomic.AddUint64(&l.count, 1)
+	log.Warnf("Slow query (%s): %s %s", d, shorten(strings.TrimSpace(query)), shorten(formatArgs(args)))
+	if !log.IsLevelEnabled(log.DebugLevel) {
+		return
+	}
+	plan, err := explainQueryPlan(conn, query, args)
+	if err != nil {
+		log.Debugf("Could not explain slow query: %s", err)
+		return
+	}
+	log.Debugf("Query plan:\n%s", plan)
+}
+
+// explainQueryPlan returns the output of EXPLAIN QUERY PLAN for `query`, one step per line
+func explainQueryPlan(conn *sqlite3.SQLiteConn, query string, args []driver.NamedValue) (string, error) {
+	rows, err := conn.QueryContext(context.Background(), "EXPLAIN QUERY PLAN "+query, args)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	// columns: id, parent, notused, detail
+	var lines []string
+	dest := make([]driver.Value, len(rows.Columns()))
+	for rows.Next(dest) == nil {
+		lines = append(lines, fmt.Sprintf("%v", dest[len(dest)-1]))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func formatArgs(args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return ""
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		if b, ok := arg.Value.([]byte); ok {
+			values[i] = fmt.Sprintf("x'%x'", b)
+		} else {
+			values[i] = fmt.Sprintf("%v", arg.Value)
+		}
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+func shorten(s string) string {
+	if len(s) <= slowQueryMaxLength {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes)", s[:slowQueryMaxLength], len(s))
+}
+
+// timedConnector opens SQLite connections that report their statements to a slowQueryLog
+type timedConnector struct {
+	dsn  string
+	slow *slowQueryLog
+	// 1 if new connections use WAL mode without automatic checkpoints, see EnableReplication.
+	// Accessed atomically.
+	replication int32
+}
+
+// Connect implements the driver.Connector interface
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	sqliteConn := conn.(*sqlite3.SQLiteConn)
+	if atomic.LoadInt32(&c.replication) == 1 {
+		for _, pragma := range replicationPragmas {
+			if _, err := sqliteConn.Exec(pragma, nil); err != nil {
+				_ = sqliteConn.Close()
+				return nil, err
+			}
+		}
+	}
+	return &timedConn{SQLiteConn: sqliteConn, slow: c.slow}, nil
+}
+
+// Driver implements the driver.Connector interface
+func (c *timedConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// timedConn measures the statements executed on a SQLite connection, including those in transactions
+type timedConn struct {
+	*sqlite3.SQLiteConn
+	slow *slowQueryLog
+}
+
+// ExecContext implements the driver.ExecerContext interface
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	res, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	c.slow.observe(c.SQLiteConn, query, args, time.Since(start))
+	return res, err
+}
+
+// QueryContext implements the driver.QueryerContext interface
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		c.slow.observe(c.SQLiteConn, query, args, time.Since(start))
+		return nil, err
+	}
+	return &timedRows{Rows: rows, conn: c, query: query, args: args, elapsed: time.Since(start)}, nil
+}
+
+// timedRows adds the time spent reading rows to the query time.
+// The time the caller spends between two rows is not counted.
+type timedRows struct {
+	driver.Rows
+	conn    *timedConn
+	query   string
+	args    []driver.NamedValue
+	elapsed time.Duration
+}
+
+// Next implements the driver.Rows interface
+func (r *timedRows) Next(dest []driver.Value) error {
+	start := time.Now()
+	err := r.Rows.Next(dest)
+	r.elapsed += time.Since(start)
+	return err
+}
+
+// Close implements the driver.Rows interface
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	r.conn.slow.observe(r.conn.SQLiteConn, r.query, r.args, r.elapsed)
+	return err
+}
+
+// SetSlowQueryThreshold logs statements that take at least `threshold` as warning,
+// with the query plan in debug mode. Zero disables the log.
+func (s *Storage) SetSlowQueryThreshold(threshold time.Duration) {
+	if s.slow != nil {
+		atomic.StoreInt64(&s.slow.threshold, int64(threshold))
+	}
+}
+
+// SlowQueries returns the number of logged slow statements
+func (s *Storage) SlowQueries() uint64 {
+	if s.slow == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&s.slow.count)
+}
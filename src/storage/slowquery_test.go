@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestFormatArgs(t *testing.T) {
+	assert.Equal(t, "", formatArgs(nil))
+	assert.Equal(t, "[1, x'0aff', abc]", formatArgs([]driver.NamedValue{
+		{Ordinal: 1, Value: int64(1)},
+		{Ordinal: 2, Value: []byte{0x0a, 0xff}},
+		{Ordinal: 3, Value: "abc"},
+	}))
+	assert.Equal(t, "abc", shorten("abc"))
+	assert.Equal(t, strings.Repeat("a", slowQueryMaxLength)+"... (1001 bytes)", shorten(strings.Repeat("a", 1001)))
+}
+
+func TestStorage_SlowQueries(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	hook := logtest.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	level := log.GetLevel()
+	log.SetLevel(log.DebugLevel)
+	defer log.SetLevel(level)
+
+	_, err = st.StoredTxIDs([]types.Hash32{test.GenerateHash32("a")})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), st.SlowQueries())
+
+	st.SetSlowQueryThreshold(time.Nanosecond)
+	_, err = st.StoredTxIDs([]types.Hash32{test.GenerateHash32("a")})
+	require.NoError(t, err)
+	st.SetSlowQueryThreshold(0)
+	assert.Equal(t, uint64(1), st.SlowQueries())
+
+	var messages []string
+	for _, entry := range hook.AllEntries() {
+		messages = append(messages, entry.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	assert.Contains(t, joined, `Slow query`)
+	assert.Contains(t, joined, `SELECT txid FROM "transaction"`)
+	// the txid index is used
+	assert.Contains(t, joined, "Query plan:\nSEARCH")
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// maxPooledStatementSize is the capacity above which statement buffers are not reused,
+// so that a single large batch (e.g. the initial mempool) does not stay in memory
+const maxPooledStatementSize = 1 << 20
+
+// statementBuffers are reused for the statements of InsertTransactions,
+// which are built for every incoming transaction
+var statementBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getStatementBuffer() *bytes.Buffer {
+	buf := statementBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putStatementBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledStatementSize {
+		statementBuffers.Put(buf)
+	}
+}
+
+// appendInt writes the decimal `v` to `buf` without allocating
+func appendInt(buf *bytes.Buffer, v int64) {
+	var scratch [20]byte
+	buf.Write(strconv.AppendInt(scratch[:0], v, 10))
+}
+
+// appendNullableInt writes the decimal `v`, or NULL if not `ok`
+func appendNullableInt(buf *bytes.Buffer, v int64, ok bool) {
+	if !ok {
+		buf.WriteString("NULL")
+		return
+	}
+	appendInt(buf, v)
+}
+
+// appendTransactionValues writes the row of `tx` for the insert statement of InsertTransactions:
+// (txid, first_seen, fee, weight, heuristics, op_return_outputs, op_return_size, witness_size, version, locktime, rbf,
+// inputs, outputs, sigop_cost, output_value, wtxid)
+func appendTransactionValues(buf *bytes.Buffer, tx *types.Transaction) {
+	var txid [2 * len(types.Hash32{})]byte
+	hex.Encode(txid[:], tx.TxID[:])
+	buf.WriteString("(x'")
+	buf.Write(txid[:])
+	buf.WriteString("', ")
+	appendInt(buf, tx.FirstSeen.UTC().Unix())
+	buf.WriteString(", ")
+	buf.Write(strconv.AppendUint(txid[:0], tx.Fee, 10))
+	buf.WriteString(", ")
+	appendInt(buf, int64(tx.Weight))
+	buf.WriteString(", ")
+
+	var heuristics int64
+	if tx.Heuristics != nil {
+		heuristics = int64(*tx.Heuristics)
+	}
+	appendNullableInt(buf, heuristics, tx.Heuristics != nil)
+	buf.WriteString(", ")
+
+	var opReturnOutputs, opReturnSize int64
+	if tx.OpReturn != nil {
+		opReturnOutputs, opReturnSize = int64(tx.OpReturn.Outputs), int64(tx.OpReturn.PayloadSize)
+	}
+	appendNullableInt(buf, opReturnOutputs, tx.OpReturn != nil)
+	buf.WriteString(", ")
+	appendNullableInt(buf, opReturnSize, tx.OpReturn != nil)
+	buf.WriteString(", ")
+
+	var witnessSize int64
+	if tx.WitnessSize != nil {
+		witnessSize = int64(*tx.WitnessSize)
+	}
+	appendNullableInt(buf, witnessSize, tx.WitnessSize != nil)
+	buf.WriteString(", ")
+
+	var version, lockTime, rbf int64
+	if tx.Signals != nil {
+		version, lockTime = int64(tx.Signals.Version), int64(tx.Signals.LockTime)
+		if tx.Signals.RBF {
+			rbf = 1
+		}
+	}
+	appendNullableInt(buf, version, tx.Signals != nil)
+	buf.WriteString(", ")
+	appendNullableInt(buf, lockTime, tx.Signals != nil)
+	buf.WriteString(", ")
+	appendNullableInt(buf, rbf, tx.Signals != nil)
+	buf.WriteString(", ")
+
+	var inputs, outputs, sigOpCost int64
+	if tx.Counts != nil {
+		inputs, outputs, sigOpCost = int64(tx.Counts.Inputs), int64(tx.Counts.Outputs), int64(tx.Counts.SigOpCost)
+	}
+	appendNullableInt(buf, inputs, tx.Counts != nil)
+	buf.WriteString(", ")
+	appendNullableInt(buf, outputs, tx.Counts != nil)
+	buf.WriteString(", ")
+	appendNullableInt(buf, sigOpCost, tx.Counts != nil)
+	buf.WriteString(", ")
+
+	var outputValue int64
+	if tx.OutputValue != nil {
+		outputValue = *tx.OutputValue
+	}
+	appendNullableInt(buf, outputValue, tx.OutputValue != nil)
+	buf.WriteString(", ")
+
+	if tx.WTxID == nil {
+		buf.WriteString("NULL)")
+		return
+	}
+	hex.Encode(txid[:], tx.WTxID[:])
+	buf.WriteString("x'")
+	buf.Write(txid[:])
+	buf.WriteString("')")
+}
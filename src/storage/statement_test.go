@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestAppendTransactionValues(t *testing.T) {
+	tx := NewTxAtOffset(10)
+	var buf bytes.Buffer
+	appendTransactionValues(&buf, tx)
+	assert.Equal(t, fmt.Sprintf("(x'%x', 10, 110, 110, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL)", tx.TxID[:]), buf.String())
+
+	heuristics := types.HeuristicFlags(5)
+	witnessSize := 108
+	tx.Heuristics = &heuristics
+	tx.OpReturn = &types.OpReturnStats{Outputs: 1, PayloadSize: 80}
+	tx.WitnessSize = &witnessSize
+	tx.Signals = &types.TxSignals{Version: 2, LockTime: 4294967295, RBF: true}
+	tx.Counts = &types.TxCounts{Inputs: 2, Outputs: 3, SigOpCost: 12}
+	outputValue := int64(150000)
+	tx.OutputValue = &outputValue
+	wtxid := test.GenerateHash32("wtxid")
+	tx.WTxID = &wtxid
+	buf.Reset()
+	appendTransactionValues(&buf, tx)
+	assert.Equal(t, fmt.Sprintf("(x'%x', 10, 110, 110, 5, 1, 80, 108, 2, 4294967295, 1, 2, 3, 12, 150000, x'%x')", tx.TxID[:], wtxid[:]), buf.String())
+}
+
+func BenchmarkAppendTransactionValues(b *testing.B) {
+	witnessSize := 108
+	tx := NewTxAtOffset(10)
+	tx.WitnessSize = &witnessSize
+	tx.Signals = &types.TxSignals{Version: 2, LockTime: 0, RBF: true}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := getStatementBuffer()
+		appendTransactionValues(buf, tx)
+		putStatementBuffer(buf)
+	}
+}
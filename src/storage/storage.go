@@ -4,18 +4,21 @@ import (
 	"database/sql"
 	"fmt"
 
-	// import sqlite adapter
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 
 	"os"
 	"strings"
+	"time"
 
 	"github.com/0xb10c/bademeister-go/src/types"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-const currentVersion = 5
+const currentVersion = 41
+
+// autoVacuumIncremental is the value of `PRAGMA auto_vacuum` for incremental vacuum
+const autoVacuumIncremental = 2
 
 // LogReorg logs reorg events in a standard format.
 // Reorgs happen either while building or reconstructing the mempool
@@ -29,56 +32,75 @@ func LogReorg(lastBest, newBest, commonAncestor *types.StoredBlock) {
 // Storage represents a SQL database.
 type Storage struct {
 	db *sql.DB
+	// database ids of recently inserted and looked up transactions, nil if disabled
+	ids *idCache
+	// nil if the connections are not measured
+	slow *slowQueryLog
+	// opens the connections of db
+	connector *timedConnector
+	// confirmations after which confirmed transactions can be pruned, see SetFinalityDepth
+	finalityDepth int64
 }
 
-// Query is expected by `queryBlock` and `QueryTransactions`
-type Query interface {
-	Where() string
-	Order() string
-	Limit() int
-}
-
-// StaticQuery is a helper implementing Query interface
-type StaticQuery struct {
-	where string
-	order string
-	limit int
-}
-
-// Where returns the WHERE portion of an SQL query
-func (q StaticQuery) Where() string {
-	return q.where
-}
-
-// Order returns the ORDER portion of an SQL query
-func (q StaticQuery) Order() string {
-	return q.order
+// dbError annotates the database error `err` with a message.
+// Errors due to a locked database are categorized as types.ErrStorageBusy,
+// errors due to a damaged database file are annotated with how to recover.
+func dbError(err error, format string, args ...interface{}) error {
+	if e, ok := errors.Cause(err).(sqlite3.Error); ok {
+		if e.Code == sqlite3.ErrBusy || e.Code == sqlite3.ErrLocked {
+			return errors.Wrapf(types.ErrStorageBusy, "%s: %s", fmt.Sprintf(format, args...), err)
+		}
+		if e.Code == sqlite3.ErrCorrupt || e.Code == sqlite3.ErrNotADB {
+			return errors.Wrapf(err, "%s (%s)", fmt.Sprintf(format, args...), corruptionHint)
+		}
+	}
+	return errors.Wrapf(err, format, args...)
 }
 
-// Limit returns the LIMIT portion of an SQL query
-func (q StaticQuery) Limit() int {
-	return q.limit
-}
+// corruptionHint tells how to recover a damaged database
+const corruptionHint = "the database file is damaged: restore it from a backup, " +
+	"or recover the readable rows into a new file with `sqlite3 <path> .recover | sqlite3 <new path>`"
 
-func formatQuery(fields []string, table string, q Query) string {
-	query := fmt.Sprintf(`SELECT %s FROM "%s"`, strings.Join(fields, ","), table)
+// hashColumn scans a BLOB column into a types.Hash32, e.g. `rows.Scan((*hashColumn)(&tx.TxID))`.
+// Unlike types.NewHashFromBytes, a value of the wrong length is an error instead of a panic.
+type hashColumn types.Hash32
 
-	if q.Where() != "" {
-		query = fmt.Sprintf("%s WHERE %s", query, q.Where())
+// Scan implements sql.Scanner
+func (h *hashColumn) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.Errorf("invalid hash of type %T", src)
 	}
-
-	if q.Order() != "" {
-		query = fmt.Sprintf("%s ORDER BY %s", query, q.Order())
+	if len(b) != len(h) {
+		return errors.Errorf("invalid hash length %d", len(b))
 	}
+	copy(h[:], b)
+	return nil
+}
 
-	if q.Limit() > 0 {
-		query = fmt.Sprintf("%s LIMIT %d", query, q.Limit())
-	}
+// FutureSchemaError is returned when opening a database with a newer schema version than
+// currentVersion, i.e. a database written by a newer release. Such a database cannot be migrated,
+// but OpenReadOnlyAllowNewer can open it for queries. Use errors.Cause to get the error:
+//
+//	if _, ok := errors.Cause(err).(*storage.FutureSchemaError); ok { ... }
+type FutureSchemaError struct {
+	Path    string
+	Version int
+	// the newest schema version of this release
+	Supported int
+}
 
-	return query
+func (e *FutureSchemaError) Error() string {
+	return fmt.Sprintf(
+		"database %s has schema version %d, but this release supports up to version %d: "+
+			"upgrade bademeister, or open the database read-only allowing a newer schema",
+		e.Path, e.Version, e.Supported,
+	)
 }
 
 // NewStorage returns a sqlite storage with required tables.
+// An existing database is migrated to currentVersion. It is not checked for corruption,
+// see QuickCheck.
 // reference: https://github.com/mattn/go-sqlite3/blob/master/_example/simple/simple.go
 func NewStorage(path string) (*Storage, error) {
 	_, err := os.Stat(path)
@@ -92,38 +114,109 @@ func NewStorage(path string) (*Storage, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite3", path)
-	if err != nil {
-		return nil, err
-	}
+	slow := &slowQueryLog{}
+	connector := &timedConnector{dsn: path, slow: slow}
+	db := sql.OpenDB(connector)
 
-	s := Storage{db}
+	s := Storage{db: db, ids: newIDCache(DefaultIDCacheSize), slow: slow, connector: connector}
 
+	version := baseVersion
 	if init {
-		if err := s.initialize(currentVersion); err != nil {
+		if err := s.initialize(baseVersion); err != nil {
+			_ = db.Close()
 			return nil, errors.Wrapf(err, "could not initialize the database at path %s", path)
 		}
 	} else {
-		if err := s.migrate(s.getVersion()); err != nil {
-			return nil, errors.Errorf("could not migrate the database: %s", err)
+		if version, err = s.getVersion(); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrapf(err, "could not open the database at path %s", path)
 		}
 	}
 
+	if version > currentVersion {
+		_ = db.Close()
+		return nil, &FutureSchemaError{Path: path, Version: version, Supported: currentVersion}
+	}
+
+	if err := s.migrate(version); err != nil {
+		_ = db.Close()
+		return nil, dbError(err, "could not migrate the database")
+	}
+
 	return &s, nil
 }
 
+// OpenReadOnly opens the database at `path` for reading only, e.g. for an API server with its own
+// connections that do not compete with the writer, or for a replica of the database.
+// `path` can be a `file:` URI with further SQLite parameters. The schema is not migrated,
+// an error is returned if the database has a different schema version than currentVersion,
+// a *FutureSchemaError if the version is newer.
+func OpenReadOnly(path string) (*Storage, error) {
+	return openReadOnly(path, false)
+}
+
+// OpenReadOnlyAllowNewer is OpenReadOnly, but also opens databases with a newer schema version for
+// query tools. Migrations mostly add tables and columns, so most queries work on newer schemas,
+// but a query can fail if a newer release changed what it reads.
+func OpenReadOnlyAllowNewer(path string) (*Storage, error) {
+	return openReadOnly(path, true)
+}
+
+func openReadOnly(path string, allowNewer bool) (*Storage, error) {
+	dsn := path
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	if strings.Contains(dsn, "?") {
+		dsn += "&mode=ro"
+	} else {
+		dsn += "?mode=ro"
+	}
+
+	slow := &slowQueryLog{}
+	connector := &timedConnector{dsn: dsn, slow: slow}
+	db := sql.OpenDB(connector)
+
+	var version int
+	if err := db.QueryRow(`SELECT version FROM config`).Scan(&version); err != nil {
+		_ = db.Close()
+		return nil, dbError(err, "could not read the schema version of %s", path)
+	}
+	switch {
+	case version > currentVersion && allowNewer:
+		log.Warnf(
+			"Database %s has schema version %d, newer than the supported version %d. Some queries may fail.",
+			path, version, currentVersion,
+		)
+	case version > currentVersion:
+		_ = db.Close()
+		return nil, &FutureSchemaError{Path: path, Version: version, Supported: currentVersion}
+	case version != currentVersion:
+		_ = db.Close()
+		return nil, errors.Errorf("database %s has schema version %d, expected %d", path, version, currentVersion)
+	}
+
+	// nothing is inserted, so the id cache is disabled
+	return &Storage{db: db, slow: slow, connector: connector}, nil
+}
+
 // initialize creates tables for a new database and fills in the configuration.
 // The caller must make sure that the database isn't initialized already.
 func (s *Storage) initialize(version int) error {
 	log.Debugf("Initializing a new database with version %d.\n", version)
 
+	// must be set before the first table is created, see IncrementalVacuum
+	if _, err := s.db.Exec(fmt.Sprintf(`PRAGMA auto_vacuum = %d`, autoVacuumIncremental)); err != nil {
+		return dbError(err, "could not set auto_vacuum mode")
+	}
+
 	const createConfigTable string = `
 		CREATE TABLE config (
 			version INTEGER
 		)`
 
 	if _, err := s.db.Exec(createConfigTable); err != nil {
-		return errors.Errorf("could not create the `config` table: %s", err)
+		return dbError(err, "could not create the `config` table")
 	}
 
 	const fillConfigTable string = `
@@ -131,7 +224,7 @@ func (s *Storage) initialize(version int) error {
 	`
 
 	if _, err := s.db.Exec(fillConfigTable, version); err != nil {
-		return errors.Errorf("could not fill the `config` table: %s", err)
+		return dbError(err, "could not fill the `config` table")
 	}
 
 	const createTransactionTable string = `
@@ -146,7 +239,7 @@ func (s *Storage) initialize(version int) error {
 	`
 
 	if _, err := s.db.Exec(createTransactionTable); err != nil {
-		return errors.Errorf("could not create the table `transaction`: %s", err)
+		return dbError(err, "could not create the table `transaction`")
 	}
 
 	const createBlockTable string = `
@@ -160,7 +253,7 @@ func (s *Storage) initialize(version int) error {
 		)
 	`
 	if _, err := s.db.Exec(createBlockTable); err != nil {
-		return errors.Errorf("could not create the table `block`: %s", err)
+		return dbError(err, "could not create the table `block`")
 	}
 
 	const createTransactionBlockTabe string = `
@@ -174,21 +267,62 @@ func (s *Storage) initialize(version int) error {
   		)
 	`
 	if _, err := s.db.Exec(createTransactionBlockTabe); err != nil {
-		return errors.Errorf("could not create the table `transaction_block`: %s", err)
+		return dbError(err, "could not create the table `transaction_block`")
 	}
 
 	return nil
 }
 
-func (s *Storage) getVersion() (version int) {
-	row := s.db.QueryRow(`SELECT version FROM config`)
-	if row == nil {
-		panic(errors.Errorf("could not query version"))
+// getVersion returns the schema version of the database
+func (s *Storage) getVersion() (version int, err error) {
+	if err := s.db.QueryRow(`SELECT version FROM config`).Scan(&version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("the table `config` is empty, the file is not a bademeister database")
+		}
+		return 0, dbError(err, "could not read the schema version, the file may not be a bademeister database")
+	}
+	return version, nil
+}
+
+// QuickCheck runs `PRAGMA quick_check` on the database at `path`, which detects most kinds of
+// corruption of the database file. It reads the whole file, which takes minutes for large databases,
+// so it is not part of opening a database. The database is opened read-only and not migrated.
+func QuickCheck(path string) error {
+	dsn := "file:" + path + "?mode=ro"
+	s := Storage{db: sql.OpenDB(&timedConnector{dsn: dsn, slow: &slowQueryLog{}})}
+	defer s.Close()
+	return errors.Wrapf(s.quickCheck(), "database at path %s", path)
+}
+
+// quickCheck runs `PRAGMA quick_check`, which detects most kinds of corruption of the database
+// file in time linear to its size. Unlike `PRAGMA integrity_check`, it does not verify that
+// indexes match the tables.
+func (s *Storage) quickCheck() error {
+	start := time.Now()
+	rows, err := s.db.Query(`PRAGMA quick_check`)
+	if err != nil {
+		return dbError(err, "could not check the database")
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return dbError(err, "could not check the database")
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
 	}
-	if err := row.Scan(&version); err != nil {
-		panic(err)
+	if err := rows.Err(); err != nil {
+		return dbError(err, "could not check the database")
 	}
-	return
+	if len(problems) > 0 {
+		return errors.Errorf("quick_check found %d problems, first: %s (%s)", len(problems), problems[0], corruptionHint)
+	}
+	log.Debugf("Database quick_check passed in %s", time.Since(start))
+	return nil
 }
 
 // TxCount returns the transaction count in DB
@@ -196,7 +330,7 @@ func (s *Storage) getVersion() (version int) {
 func (s *Storage) TxCount() (count int, err error) {
 	row := s.db.QueryRow(`SELECT COUNT(txid) FROM "transaction"`)
 	if err := row.Scan(&count); err != nil {
-		return 0, errors.Errorf("could not get count from table `transaction`: %s", err)
+		return 0, dbError(err, "could not get count from table `transaction`")
 	}
 	return
 }
@@ -207,9 +341,20 @@ func (s *Storage) migrate(fromVersion int) error {
 		return nil
 	}
 
-	// TODO: implement
+	if fromVersion < baseVersion || fromVersion > currentVersion {
+		return errors.Errorf("cannot migrate from version %d", fromVersion)
+	}
 
-	return errors.Errorf("cannot migrate from version %d", fromVersion)
+	for _, m := range migrations {
+		if m.version <= fromVersion {
+			continue
+		}
+		if err := s.applyMigration(m.version, m.statements); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Close underlying SQLite
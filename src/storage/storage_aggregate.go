@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// AggregateResolutions are the bucket lengths of the materialized aggregates
+var AggregateResolutions = []time.Duration{time.Minute, time.Hour, 24 * time.Hour}
+
+func aggregateSeconds(resolution time.Duration) (int64, error) {
+	for _, r := range AggregateResolutions {
+		if r == resolution {
+			return int64(resolution.Seconds()), nil
+		}
+	}
+	return 0, errors.Errorf("invalid aggregate resolution %s", resolution)
+}
+
+// FirstTransactionTime returns the earliest first-seen time of the stored transactions.
+// Returns nil if no transactions are stored.
+func (s *Storage) FirstTransactionTime() (*time.Time, error) {
+	var firstSeen sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MIN(first_seen) FROM "transaction"`).Scan(&firstSeen); err != nil {
+		return nil, dbError(err, "error querying first transaction")
+	}
+	if !firstSeen.Valid {
+		return nil, nil
+	}
+	t := time.Unix(firstSeen.Int64, 0).UTC()
+	return &t, nil
+}
+
+// LastAggregate returns the start of the most recent stored bucket of `resolution`.
+// Returns nil if no bucket is stored.
+func (s *Storage) LastAggregate(resolution time.Duration) (*time.Time, error) {
+	seconds, err := aggregateSeconds(resolution)
+	if err != nil {
+		return nil, err
+	}
+	var last sql.NullInt64
+	err = s.db.QueryRow(`SELECT MAX(time) FROM "tx_aggregate" WHERE resolution = ?`, seconds).Scan(&last)
+	if err != nil {
+		return nil, dbError(err, "error querying table `tx_aggregate`")
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	t := time.Unix(last.Int64, 0).UTC()
+	return &t, nil
+}
+
+// RefreshAggregates recomputes the buckets of `resolution` that start in `from <= time < to`
+// from the transactions first seen in the range. `from` and `to` are rounded down to the resolution.
+// Buckets without transactions are not stored, existing buckets for them are kept.
+func (s *Storage) RefreshAggregates(resolution time.Duration, from, to time.Time) error {
+	seconds, err := aggregateSeconds(resolution)
+	if err != nil {
+		return err
+	}
+	start := from.Unix() / seconds * seconds
+	end := to.Unix() / seconds * seconds
+	if end <= start {
+		return nil
+	}
+
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// the rows are ordered by bucket and feerate, so that the median is the middle row of a bucket
+	rows, err := dbTx.Query(`
+		SELECT
+			first_seen / ?1 * ?1 AS bucket,
+			weight,
+			fee,
+			version,
+			locktime,
+			rbf
+		FROM
+			"transaction"
+		WHERE
+			first_seen >= ?2 AND first_seen < ?3
+		ORDER BY
+			bucket ASC,
+			CASE WHEN weight > 0 THEN CAST(fee AS REAL) * 4 / weight END ASC
+		`, seconds, start, end,
+	)
+	if err != nil {
+		_ = dbTx.Rollback()
+		return dbError(err, "error querying transactions for aggregates")
+	}
+
+	var aggregates []types.TxAggregate
+	var feerates []float64
+	flush := func() {
+		if len(aggregates) == 0 {
+			return
+		}
+		if len(feerates) > 0 {
+			median := feerates[len(feerates)/2]
+			aggregates[len(aggregates)-1].MedianFeerate = &median
+		}
+		feerates = feerates[:0]
+	}
+	for rows.Next() {
+		var bucket int64
+		var weight int64
+		var fee uint64
+		var version, lockTime, rbf sql.NullInt64
+		if err := rows.Scan(&bucket, &weight, &fee, &version, &lockTime, &rbf); err != nil {
+			rows.Close()
+			_ = dbTx.Rollback()
+			return dbError(err, "error reading row")
+		}
+		t := time.Unix(bucket, 0).UTC()
+		if len(aggregates) == 0 || aggregates[len(aggregates)-1].Time != t {
+			flush()
+			aggregates = append(aggregates, types.TxAggregate{Time: t})
+		}
+		a := &aggregates[len(aggregates)-1]
+		a.Transactions++
+		a.VSize += (weight + 3) / 4
+		a.Fees += fee
+		if weight > 0 {
+			// NULL feerates are ordered first, so the known feerates stay sorted
+			feerates = append(feerates, float64(fee)*4/float64(weight))
+		}
+		if version.Valid && lockTime.Valid && rbf.Valid {
+			a.SignalsKnown++
+			if version.Int64 >= 2 {
+				a.Version2++
+			}
+			if lockTime.Int64 != 0 {
+				a.LockTime++
+			}
+			if rbf.Int64 != 0 {
+				a.RBF++
+			}
+		}
+	}
+	flush()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		_ = dbTx.Rollback()
+		return dbError(err, "error reading transactions for aggregates")
+	}
+
+	// buckets without transactions are not touched, so aggregates of pruned transactions are kept
+	for _, a := range aggregates {
+		_, err := dbTx.Exec(`
+			INSERT OR REPLACE INTO
+				"tx_aggregate" (
+					resolution, time, transactions, vsize, fees, median_feerate,
+					signals_known, version2, locktime, rbf
+				)
+			VALUES
+				(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, seconds, a.Time.Unix(), a.Transactions, a.VSize, a.Fees, a.MedianFeerate,
+			a.SignalsKnown, a.Version2, a.LockTime, a.RBF,
+		)
+		if err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, "could not insert into table `tx_aggregate`")
+		}
+	}
+
+	return dbTx.Commit()
+}
+
+// Aggregates returns the stored buckets of `resolution` that start in `from <= time < to`, ordered by time
+func (s *Storage) Aggregates(resolution time.Duration, from, to time.Time) ([]types.TxAggregate, error) {
+	seconds, err := aggregateSeconds(resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			time, transactions, vsize, fees, median_feerate,
+			signals_known, version2, locktime, rbf
+		FROM
+			"tx_aggregate"
+		WHERE
+			resolution = ? AND time >= ? AND time < ?
+		ORDER BY
+			time ASC
+		`, seconds, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying table `tx_aggregate`")
+	}
+	defer rows.Close()
+
+	res := []types.TxAggregate{}
+	for rows.Next() {
+		var t int64
+		var a types.TxAggregate
+		err := rows.Scan(
+			&t, &a.Transactions, &a.VSize, &a.Fees, &a.MedianFeerate,
+			&a.SignalsKnown, &a.Version2, &a.LockTime, &a.RBF,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		a.Time = time.Unix(t, 0).UTC()
+		if a.SignalsKnown > 0 {
+			share := float64(a.RBF) / float64(a.SignalsKnown)
+			a.RBFShare = &share
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
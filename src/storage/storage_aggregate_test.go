@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_RefreshAggregates(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	first, err := st.FirstTransactionTime()
+	require.NoError(t, err)
+	assert.Nil(t, first)
+
+	start := time.Unix(3600*1000, 0).UTC()
+	txs := []types.Transaction{
+		// 1, 2 and 10 sat/vB in the first minute, one in the second minute
+		{TxID: test.GenerateHash32("a"), FirstSeen: start, Fee: 100, Weight: 400,
+			Signals: &types.TxSignals{Version: 2, RBF: true}},
+		{TxID: test.GenerateHash32("b"), FirstSeen: start.Add(10 * time.Second), Fee: 1000, Weight: 400,
+			Signals: &types.TxSignals{Version: 1, LockTime: 600000}},
+		{TxID: test.GenerateHash32("c"), FirstSeen: start.Add(59 * time.Second), Fee: 201, Weight: 401},
+		{TxID: test.GenerateHash32("d"), FirstSeen: start.Add(90 * time.Second), Fee: 500, Weight: 0},
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	first, err = st.FirstTransactionTime()
+	require.NoError(t, err)
+	assert.Equal(t, start, *first)
+
+	require.NoError(t, st.RefreshAggregates(time.Minute, start, start.Add(time.Hour)))
+	require.NoError(t, st.RefreshAggregates(time.Hour, start, start.Add(time.Hour)))
+	assert.Error(t, st.RefreshAggregates(time.Second, start, start.Add(time.Hour)))
+
+	minutes, err := st.Aggregates(time.Minute, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, minutes, 2)
+	assert.Equal(t, start, minutes[0].Time)
+	assert.Equal(t, int64(3), minutes[0].Transactions)
+	assert.Equal(t, int64(301), minutes[0].VSize)
+	assert.Equal(t, uint64(1301), minutes[0].Fees)
+	assert.InDelta(t, 2.0, *minutes[0].MedianFeerate, 0.01)
+	assert.Equal(t, int64(2), minutes[0].SignalsKnown)
+	assert.Equal(t, int64(1), minutes[0].Version2)
+	assert.Equal(t, int64(1), minutes[0].LockTime)
+	assert.Equal(t, int64(1), minutes[0].RBF)
+	assert.Equal(t, 0.5, *minutes[0].RBFShare)
+	assert.Equal(t, int64(1), minutes[1].Transactions)
+	assert.Nil(t, minutes[1].MedianFeerate)
+	assert.Nil(t, minutes[1].RBFShare)
+
+	hours, err := st.Aggregates(time.Hour, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	assert.Equal(t, int64(4), hours[0].Transactions)
+
+	last, err := st.LastAggregate(time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute), *last)
+
+	// a refresh picks up late arrivals
+	_, err = st.InsertTransaction(&types.Transaction{
+		TxID: test.GenerateHash32("e"), FirstSeen: start.Add(100 * time.Second), Fee: 400, Weight: 400,
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.RefreshAggregates(time.Minute, *last, start.Add(time.Hour)))
+	minutes, err = st.Aggregates(time.Minute, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, minutes, 2)
+	assert.Equal(t, int64(2), minutes[1].Transactions)
+	assert.Equal(t, 4.0, *minutes[1].MedianFeerate)
+}
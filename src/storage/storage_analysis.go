@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// analysisValues appends the row of `r` for the transaction or block with the database id
+// `transactionID` or `blockID` to the values of an insert into "analysis_result"
+func analysisValues(values []string, args []interface{}, r *types.AnalysisResult, transactionID, blockID interface{}, t time.Time) ([]string, []interface{}) {
+	var data interface{}
+	if len(r.Data) > 0 {
+		data = string(r.Data)
+	}
+	values = append(values, "(?, ?, ?, ?, ?, ?, ?)")
+	args = append(args, r.Analyzer, r.Key, transactionID, blockID, t.Unix(), r.Value, data)
+	return values, args
+}
+
+// insertAnalysisRows inserts the rows of analysisValues. Values that are already stored are not changed.
+func (s *Storage) insertAnalysisRows(values []string, args []interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := s.db.Exec(fmt.Sprintf(`
+		INSERT OR IGNORE INTO
+			"analysis_result"
+			(analyzer, name, transaction_id, block_id, time, value, data)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return dbError(err, "could not insert into table `analysis_result`")
+	}
+	return nil
+}
+
+// insertTransactionAnalysis stores the analysis results of `txs`
+func (s *Storage) insertTransactionAnalysis(txs []types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	txids := make([]types.Hash32, len(txs))
+	for i, tx := range txs {
+		txids[i] = tx.TxID
+	}
+	dbids, err := s.transactionDBIDs(txids)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	for i := range txs {
+		dbid := (*dbids)[i]
+		if dbid < 0 {
+			continue
+		}
+		for j := range txs[i].Analysis {
+			values, args = analysisValues(values, args, &txs[i].Analysis[j], dbid, nil, txs[i].FirstSeen)
+		}
+	}
+	return s.insertAnalysisRows(values, args)
+}
+
+// insertBlockAnalysis stores the analysis results of the block `blockID` first seen at `t`
+func (s *Storage) insertBlockAnalysis(blockID int64, results []types.AnalysisResult, t time.Time) error {
+	values := []string{}
+	args := []interface{}{}
+	for i := range results {
+		values, args = analysisValues(values, args, &results[i], nil, blockID, t)
+	}
+	return s.insertAnalysisRows(values, args)
+}
+
+// AnalysisQuery selects analysis results matching all set fields
+type AnalysisQuery struct {
+	Analyzer string
+	Key      string
+	// Results of the transaction with the txid
+	TxID *types.Hash32
+	// Results of the block with the hash
+	Block *types.Hash32
+	// Inclusive range of the first-seen time of the transaction or block
+	From *time.Time
+	To   *time.Time
+	// Maximum number of results, 0 for no limit
+	MaxResults int
+}
+
+// AnalysisResults returns the analysis results matching `q`, most recent first
+func (s *Storage) AnalysisResults(q AnalysisQuery) ([]types.StoredAnalysisResult, error) {
+	var c conditions
+	if q.Analyzer != "" {
+		c.add("r.analyzer = ?", q.Analyzer)
+	}
+	if q.Key != "" {
+		c.add("r.name = ?", q.Key)
+	}
+	if q.TxID != nil {
+		c.add("t.txid = ?", q.TxID[:])
+	}
+	if q.Block != nil {
+		c.add("b.hash = ?", q.Block[:])
+	}
+	if q.From != nil {
+		c.add("r.time >= ?", q.From.Unix())
+	}
+	if q.To != nil {
+		c.add("r.time <= ?", q.To.Unix())
+	}
+
+	query := `
+		SELECT
+			r.analyzer, r.name, r.value, r.data, r.time, t.txid, b.hash
+		FROM
+			"analysis_result" r
+			LEFT JOIN "transaction" t ON t.id = r.transaction_id
+			LEFT JOIN "block" b ON b.id = r.block_id`
+	where, args := c.where()
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY r.time DESC, r.id DESC"
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying analysis results")
+	}
+	defer rows.Close()
+
+	res := []types.StoredAnalysisResult{}
+	for rows.Next() {
+		var t int64
+		var data *string
+		var txid, blockHash []byte
+		var r types.StoredAnalysisResult
+		if err := rows.Scan(&r.Analyzer, &r.Key, &r.Value, &data, &t, &txid, &blockHash); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		r.Time = time.Unix(t, 0).UTC()
+		if data != nil {
+			r.Data = []byte(*data)
+		}
+		if txid != nil {
+			r.TxID = new(types.Hash32)
+			if err := (*hashColumn)(r.TxID).Scan(txid); err != nil {
+				return nil, dbError(err, "error reading row")
+			}
+		}
+		if blockHash != nil {
+			r.Block = new(types.Hash32)
+			if err := (*hashColumn)(r.Block).Scan(blockHash); err != nil {
+				return nil, dbError(err, "error reading row")
+			}
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_AnalysisResults(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx := NewTxAtOffset(10)
+	tx.Analysis = []types.AnalysisResult{
+		{Analyzer: "a", Key: "x", Value: 1.5},
+		{Analyzer: "b", Key: "y", Value: 2, Data: json.RawMessage(`{"z":1}`)},
+	}
+	_, err = st.InsertTransactions([]types.Transaction{*tx})
+	require.NoError(t, err)
+
+	// stored values are not changed
+	again := *tx
+	again.Analysis = []types.AnalysisResult{{Analyzer: "a", Key: "x", Value: 3}}
+	_, err = st.InsertTransactions([]types.Transaction{again})
+	require.NoError(t, err)
+
+	block := chainedBlocks(1, "", []string{"x"})[0]
+	block.FirstSeen = GetTime(20)
+	block.Analysis = []types.AnalysisResult{{Analyzer: "a", Key: "x", Value: 4}}
+	require.NoError(t, insertBlocks(st, []types.Block{block}))
+
+	results, err := st.AnalysisResults(AnalysisQuery{Analyzer: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, []types.StoredAnalysisResult{
+		{
+			AnalysisResult: types.AnalysisResult{Analyzer: "a", Key: "x", Value: 4},
+			Time:           GetTime(20),
+			Block:          &block.Hash,
+		},
+		{
+			AnalysisResult: types.AnalysisResult{Analyzer: "a", Key: "x", Value: 1.5},
+			Time:           GetTime(10),
+			TxID:           &tx.TxID,
+		},
+	}, results)
+
+	results, err = st.AnalysisResults(AnalysisQuery{TxID: &tx.TxID, Key: "y"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.JSONEq(t, `{"z":1}`, string(results[0].Data))
+
+	to := GetTime(15)
+	results, err = st.AnalysisResults(AnalysisQuery{To: &to, MaxResults: 1})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// the results of pruned transactions are deleted, those of blocks are kept
+	_, err = st.db.Exec(`UPDATE "transaction" SET last_removed = ?`, GetTime(30).Unix())
+	require.NoError(t, err)
+	n, err := st.PruneTransactions(GetTime(31), 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	results, err = st.AnalysisResults(AnalysisQuery{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, &block.Hash, results[0].Block)
+}
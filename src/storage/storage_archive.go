@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// archiveTables are copied to the archive, with the columns identifying a row.
+// The ids of transactions and analysis results are renumbered in the archive, see ArchiveTransactions.
+var archiveTables = []struct {
+	name   string
+	unique string
+}{
+	{"block", "id"},
+	{"transaction", "id"},
+	{"transaction_block", "transaction_id, block_id"},
+	{"transaction_input", "transaction_id, n"},
+	{"transaction_output", "transaction_id, n"},
+	{"transaction_raw", "transaction_id"},
+	{"first_seen_estimate", "transaction_id"},
+	{"transaction_dust", "transaction_id, script_type"},
+	{"transaction_whale", "transaction_id"},
+	{"analysis_result", "id"},
+	{"transaction_witness_replacement", "transaction_id, time, new_wtxid"},
+	{"transaction_fee_update", "transaction_id, time, new_fee"},
+	{"double_spend", "transaction_id, confirmed_txid"},
+	{"transaction_drop", "transaction_id"},
+}
+
+// archiveSchema returns statements that create the archive tables with the columns of the main tables
+func archiveSchema() (statements []string) {
+	for _, t := range archiveTables {
+		statements = append(statements,
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS archive."%s" AS SELECT * FROM main."%s" WHERE 0`, t.name, t.name),
+			fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS archive."%s_unique" ON "%s" (%s)`, t.name, t.name, t.unique),
+		)
+	}
+	// transactions are identified by txid, their ids are renumbered. Not unique, older archives can
+	// contain a txid twice.
+	return append(statements, `CREATE INDEX IF NOT EXISTS archive."transaction_txid" ON "transaction" (txid)`)
+}
+
+// archiveIDs returns a statement that creates the temporary table `archive_ids` mapping the ids `ids`
+// of transactions that are not in the archive yet to unused ids in the archive
+func archiveIDs(ids string) string {
+	return fmt.Sprintf(`
+		CREATE TEMP TABLE archive_ids AS
+		SELECT
+			id,
+			id - (SELECT MIN(id) FROM main."transaction" WHERE id IN (%s)) + 1 +
+				(SELECT COALESCE(MAX(id), 0) FROM archive."transaction") AS archive_id
+		FROM
+			main."transaction"
+		WHERE
+			id IN (%s) AND txid NOT IN (SELECT txid FROM archive."transaction")
+		`, ids, ids,
+	)
+}
+
+// archiveRows returns statements that copy the rows of `table` whose column `column` is an id
+// in `archive_ids` to the archive, replacing it with the archive id
+func archiveRows(table, column string) []string {
+	statements := []string{
+		fmt.Sprintf(
+			`CREATE TEMP TABLE archive_rows AS SELECT * FROM main."%s" WHERE %s IN (SELECT id FROM archive_ids)`,
+			table, column,
+		),
+		fmt.Sprintf(
+			`UPDATE archive_rows SET %s = (SELECT archive_id FROM archive_ids WHERE archive_ids.id = archive_rows.%s)`,
+			column, column,
+		),
+	}
+	if table == "analysis_result" {
+		// the ids of deleted results are reused as well
+		statements = append(statements, `
+			UPDATE archive_rows SET id = id - (SELECT MIN(id) FROM archive_rows) + 1 +
+				(SELECT COALESCE(MAX(id), 0) FROM archive."analysis_result")`,
+		)
+	}
+	return append(statements,
+		fmt.Sprintf(`INSERT OR IGNORE INTO archive."%s" SELECT * FROM archive_rows`, table),
+		`DROP TABLE archive_rows`,
+	)
+}
+
+// ArchiveTransactions moves up to `limit` transactions (all if zero) that were removed from the mempool
+// before `before` and are final (see SetFinalityDepth) to the SQLite database at `archivePath`, including their inputs, outputs,
+// serialized transactions, block references and the referenced blocks. The archive is created if it does not exist.
+// Returns the number of moved transactions.
+//
+// Database ids of deleted transactions are reused, so the transactions are identified by their txid
+// in the archive and get new ids there. Transactions that are in the archive already are not copied again.
+//
+// Each call is a single write transaction, so archiving in small slices keeps live writes going.
+// The archive tables have the columns of the schema version that created them, use a new archive
+// file after a migration that adds columns.
+func (s *Storage) ArchiveTransactions(archivePath string, before time.Time, limit int) (int64, error) {
+	ctx := context.Background()
+
+	// ATTACH only applies to a single connection of the pool
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS archive`, archivePath); err != nil {
+		return 0, dbError(err, "could not attach archive %s", archivePath)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(ctx, `DETACH DATABASE archive`)
+	}()
+
+	for _, statement := range archiveSchema() {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return 0, dbError(err, "could not create archive tables")
+		}
+	}
+
+	dbTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := removedTransactionIDs(dbTx, before, limit, atomic.LoadInt64(&s.finalityDepth))
+	if err != nil || ids == "" {
+		_ = dbTx.Rollback()
+		return 0, err
+	}
+
+	copyStatements := []string{
+		fmt.Sprintf(`
+			INSERT OR IGNORE INTO archive."block"
+			SELECT * FROM main."block" WHERE id IN (
+				SELECT block_id FROM main."transaction_block" WHERE transaction_id IN (%s)
+			)`, ids,
+		),
+	}
+	copyStatements = append(copyStatements, archiveIDs(ids))
+	copyStatements = append(copyStatements, archiveRows("transaction", "id")...)
+	for _, table := range referencingTables {
+		copyStatements = append(copyStatements, archiveRows(table, "transaction_id")...)
+	}
+	copyStatements = append(copyStatements, `DROP TABLE archive_ids`)
+
+	for _, statement := range copyStatements {
+		if _, err := dbTx.Exec(statement); err != nil {
+			_ = dbTx.Rollback()
+			return 0, dbError(err, "could not copy rows to archive")
+		}
+	}
+
+	n, err := deleteTransactions(dbTx, ids)
+	if err != nil {
+		_ = dbTx.Rollback()
+		return 0, err
+	}
+
+	// ids of deleted transactions can be reused
+	defer s.ids.purge()
+	return n, dbTx.Commit()
+}
+
+// IncrementalVacuum returns up to `pages` free database pages to the file system
+// and returns the number of free pages left.
+// Unlike VACUUM, this only locks the database for a short time.
+// Pages are only released if incremental vacuum is enabled, see EnableIncrementalVacuum.
+func (s *Storage) IncrementalVacuum(pages int) (int64, error) {
+	// the pragma frees one page per step, the rows have to be read to complete it
+	rows, err := s.db.Query(fmt.Sprintf(`PRAGMA incremental_vacuum(%d)`, pages))
+	if err != nil {
+		return 0, dbError(err, "error in incremental vacuum")
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return 0, dbError(err, "error in incremental vacuum")
+	}
+	if err := rows.Close(); err != nil {
+		return 0, dbError(err, "error in incremental vacuum")
+	}
+
+	var free int64
+	if err := s.db.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil {
+		return 0, dbError(err, "could not get freelist count")
+	}
+	return free, nil
+}
+
+// IncrementalVacuumEnabled returns true if the database releases free pages with IncrementalVacuum
+func (s *Storage) IncrementalVacuumEnabled() (bool, error) {
+	var mode int
+	if err := s.db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return false, dbError(err, "could not get auto_vacuum mode")
+	}
+	return mode == autoVacuumIncremental, nil
+}
+
+// EnableIncrementalVacuum switches the database to incremental vacuum.
+// Databases created by this version have it enabled already. For older databases this
+// rewrites the whole file with VACUUM, which blocks writes and needs free disk space of the database size.
+func (s *Storage) EnableIncrementalVacuum() error {
+	enabled, err := s.IncrementalVacuumEnabled()
+	if err != nil || enabled {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// the new mode is applied by VACUUM on the same connection
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA auto_vacuum = %d`, autoVacuumIncremental)); err != nil {
+		return dbError(err, "could not set auto_vacuum mode")
+	}
+	if _, err := conn.ExecContext(ctx, `VACUUM`); err != nil {
+		return dbError(err, "could not vacuum database")
+	}
+
+	enabled, err = s.IncrementalVacuumEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("auto_vacuum mode unchanged")
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_ArchiveTransactions(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	archivePath := filepath.Join(filepath.Dir(StoragePath()), "archive.db")
+	require.NoError(t, os.RemoveAll(archivePath))
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	countBefore, err := st.TxCount()
+	require.NoError(t, err)
+
+	// transactions of the first block were removed at time 100, archive them in slices of one
+	archived := int64(0)
+	for {
+		n, err := st.ArchiveTransactions(archivePath, GetTime(101), 1)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+		assert.Equal(t, int64(1), n)
+		archived += n
+	}
+	assert.Equal(t, int64(len(testChain.blocks[0].TxIDs)), archived)
+
+	countAfter, err := st.TxCount()
+	require.NoError(t, err)
+	assert.Equal(t, countBefore-int(archived), countAfter)
+
+	for _, txid := range testChain.blocks[0].TxIDs {
+		tx, err := st.TransactionByID(txid)
+		require.NoError(t, err)
+		assert.Nil(t, tx)
+	}
+
+	archive, err := sql.Open("sqlite3", archivePath)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	var txCount, txBlockCount, blockCount int64
+	require.NoError(t, archive.QueryRow(`SELECT COUNT(*) FROM "transaction"`).Scan(&txCount))
+	require.NoError(t, archive.QueryRow(`SELECT COUNT(*) FROM "transaction_block"`).Scan(&txBlockCount))
+	require.NoError(t, archive.QueryRow(`SELECT COUNT(*) FROM "block"`).Scan(&blockCount))
+	assert.Equal(t, archived, txCount)
+	assert.Equal(t, archived, txBlockCount)
+	assert.Equal(t, int64(1), blockCount)
+
+	// compaction releases the pages of the moved rows
+	enabled, err := st.IncrementalVacuumEnabled()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	free, err := st.IncrementalVacuum(1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), free)
+}
+
+func TestStorage_ArchiveReusedIDs(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	archivePath := filepath.Join(filepath.Dir(StoragePath()), "archive.db")
+	require.NoError(t, os.RemoveAll(archivePath))
+
+	// the id of the deleted last transaction is reused by the next one
+	var ids []int64
+	var parent types.Hash32
+	for i, offset := range []int{10, 20} {
+		tx := NewTxAtOffset(offset)
+		id, err := st.InsertTransaction(tx)
+		require.NoError(t, err)
+		ids = append(ids, id)
+		hash := test.GenerateHash32(fmt.Sprintf("block-%d", i))
+		_, err = st.InsertBlock(&types.Block{
+			Parent:    parent,
+			Hash:      hash,
+			FirstSeen: GetTime(offset + 1),
+			TxIDs:     []types.Hash32{tx.TxID},
+			IsBest:    true,
+			Height:    uint32(i),
+		})
+		require.NoError(t, err)
+		parent = hash
+
+		n, err := st.ArchiveTransactions(archivePath, GetTime(offset+2), 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	}
+	assert.Equal(t, ids[0], ids[1])
+
+	archive, err := sql.Open("sqlite3", archivePath)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	rows, err := archive.Query(`
+		SELECT t.txid FROM "transaction" t JOIN "transaction_block" tb ON tb.transaction_id = t.id ORDER BY t.id`,
+	)
+	require.NoError(t, err)
+	defer rows.Close()
+	var txids []types.Hash32
+	for rows.Next() {
+		var txid []byte
+		require.NoError(t, rows.Scan(&txid))
+		txids = append(txids, types.NewHashFromBytes(txid))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []types.Hash32{NewTxAtOffset(10).TxID, NewTxAtOffset(20).TxID}, txids)
+}
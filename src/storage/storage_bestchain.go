@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"database/sql"
+	"math/big"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// BestChainChanges are the blocks whose best-chain membership was corrected by RecomputeBestChain
+type BestChainChanges struct {
+	Connected    []types.Hash32
+	Disconnected []types.Hash32
+}
+
+// chainNode is a block of the stored block tree
+type chainNode struct {
+	dbid        int64
+	hash        types.Hash32
+	height      uint32
+	firstSeen   int64
+	inBestChain bool
+	parent      *chainNode
+	// work of the block and its stored ancestors
+	chainWork *big.Int
+}
+
+// better returns true if the chain ending in `n` is preferred over the one ending in `other`:
+// more work, then the greater height, then the current best chain, then the block received first
+func (n *chainNode) better(other *chainNode) bool {
+	if c := n.chainWork.Cmp(other.chainWork); c != 0 {
+		return c > 0
+	}
+	if n.height != other.height {
+		return n.height > other.height
+	}
+	if n.inBestChain != other.inBestChain {
+		return n.inBestChain
+	}
+	return n.firstSeen < other.firstSeen
+}
+
+// RecomputeBestChain derives the best chain from the stored block tree and corrects the
+// in_best_chain flags and the removal times of the transactions in corrected blocks, e.g. after
+// missed block notifications. The best chain ends in the block with the most chain work counted
+// from the first stored blocks; blocks with unknown bits do not add work, so without bits the
+// highest chain wins; of equal chains, the current one is kept. The chain is read and corrected in
+// a single write transaction.
+func (s *Storage) RecomputeBestChain() (*BestChainChanges, error) {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return nil, dbError(err, "could not begin transaction")
+	}
+
+	changes, err := recomputeBestChain(dbTx)
+	if err != nil {
+		_ = dbTx.Rollback()
+		return nil, err
+	}
+	if err := dbTx.Commit(); err != nil {
+		return nil, dbError(err, "could not commit best chain")
+	}
+	return changes, nil
+}
+
+func recomputeBestChain(dbTx *sql.Tx) (*BestChainChanges, error) {
+	// parents have lower heights, so they are read before their children
+	rows, err := dbTx.Query(`
+		SELECT id, hash, parent, height, first_seen, in_best_chain, COALESCE(bits, 0)
+		FROM "block" ORDER BY height ASC, id ASC
+	`)
+	if err != nil {
+		return nil, dbError(err, "error querying blocks")
+	}
+	defer rows.Close()
+
+	var nodes []*chainNode
+	byHash := map[types.Hash32]*chainNode{}
+	var tip *chainNode
+	for rows.Next() {
+		var parent types.Hash32
+		var bits uint32
+		node := &chainNode{}
+		err := rows.Scan(
+			&node.dbid, (*hashColumn)(&node.hash), (*hashColumn)(&parent),
+			&node.height, &node.firstSeen, &node.inBestChain, &bits,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading block")
+		}
+		node.parent = byHash[parent]
+		node.chainWork = (&types.Block{Bits: bits}).Work()
+		if node.parent != nil {
+			node.chainWork.Add(node.chainWork, node.parent.chainWork)
+		}
+		nodes = append(nodes, node)
+		byHash[node.hash] = node
+		if tip == nil || node.better(tip) {
+			tip = node
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error reading blocks")
+	}
+	rows.Close()
+
+	changes := &BestChainChanges{}
+	if tip == nil {
+		return changes, nil
+	}
+
+	inBestChain := map[*chainNode]bool{}
+	for n := tip; n != nil; n = n.parent {
+		inBestChain[n] = true
+	}
+
+	// disconnect first, transactions can be in blocks of both chains
+	for _, n := range nodes {
+		if n.inBestChain && !inBestChain[n] {
+			if err := setBlockInBestChain(dbTx, n.dbid, false, nil); err != nil {
+				return nil, err
+			}
+			changes.Disconnected = append(changes.Disconnected, n.hash)
+		}
+	}
+	lastRemoved := time.Unix(tip.firstSeen, 0).UTC()
+	for _, n := range nodes {
+		if !n.inBestChain && inBestChain[n] {
+			if err := setBlockInBestChain(dbTx, n.dbid, true, &lastRemoved); err != nil {
+				return nil, err
+			}
+			changes.Connected = append(changes.Connected, n.hash)
+		}
+	}
+
+	if len(changes.Connected) > 0 || len(changes.Disconnected) > 0 {
+		log.Warnf(
+			"Best chain recomputed: tip %s height %d, %d blocks connected, %d disconnected",
+			tip.hash, tip.height, len(changes.Connected), len(changes.Disconnected),
+		)
+	}
+	return changes, nil
+}
+
+// setBlockInBestChain sets in_best_chain of the block with database id `dbid` and the removal
+// time of its transactions, which is cleared if `lastRemoved` is nil
+func setBlockInBestChain(dbTx *sql.Tx, dbid int64, inBestChain bool, lastRemoved *time.Time) error {
+	if _, err := dbTx.Exec(`UPDATE "block" SET in_best_chain = ? WHERE id = ?`, inBestChain, dbid); err != nil {
+		return dbError(err, "could not update in_best_chain")
+	}
+
+	var lastRemovedSeconds interface{}
+	if lastRemoved != nil {
+		lastRemovedSeconds = lastRemoved.Unix()
+	}
+	_, err := dbTx.Exec(`
+		UPDATE "transaction" SET last_removed = ?
+		WHERE id IN (SELECT transaction_id FROM "transaction_block" WHERE block_id = ?)
+		`, lastRemovedSeconds, dbid,
+	)
+	return dbError(err, "could not update last_removed")
+}
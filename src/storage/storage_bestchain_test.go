@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_RecomputeBestChain(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	changes, err := st.RecomputeBestChain()
+	require.NoError(t, err)
+	assert.Empty(t, changes.Connected)
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	// the chains ending in "3" and "1.2" have the same height, the current one is kept
+	changes, err = st.RecomputeBestChain()
+	require.NoError(t, err)
+	assert.Empty(t, changes.Connected)
+	assert.Empty(t, changes.Disconnected)
+
+	// inconsistent flags are corrected
+	flipped := txidsFromStrings("2", "1.1")
+	_, err = st.db.Exec(`UPDATE "block" SET in_best_chain = 1 - in_best_chain WHERE hash IN (?, ?)`,
+		flipped[0][:], flipped[1][:])
+	require.NoError(t, err)
+	changes, err = st.RecomputeBestChain()
+	require.NoError(t, err)
+	assert.Equal(t, txidsFromStrings("1.1"), changes.Connected)
+	assert.Equal(t, txidsFromStrings("2"), changes.Disconnected)
+
+	lastRemoved := func(name string) *int64 {
+		tx, err := st.TransactionByID(test.GenerateHash32(name))
+		require.NoError(t, err)
+		if tx.LastRemoved == nil {
+			return nil
+		}
+		seconds := tx.LastRemoved.Unix()
+		return &seconds
+	}
+	// tx-100 is only in "2", tx-20 in "2" and "1.1"
+	assert.Nil(t, lastRemoved("tx-100"))
+	require.NotNil(t, lastRemoved("tx-20"))
+	assert.Equal(t, GetTime(500).Unix(), *lastRemoved("tx-20"))
+
+	// a block with more work on "3" that was not received as chain tip
+	_, err = st.InsertBlock(&types.Block{
+		Parent:    test.GenerateHash32("3"),
+		Hash:      test.GenerateHash32("4"),
+		FirstSeen: GetTime(600),
+		Height:    3,
+		Bits:      0x1d00ffff,
+	})
+	require.NoError(t, err)
+	changes, err = st.RecomputeBestChain()
+	require.NoError(t, err)
+	assert.Equal(t, txidsFromStrings("2", "3", "4"), changes.Connected)
+	assert.Equal(t, txidsFromStrings("1.1", "1.2"), changes.Disconnected)
+
+	tip, err := st.GetBestBlock()
+	require.NoError(t, err)
+	assert.Equal(t, test.GenerateHash32("4"), tip.Hash)
+}
@@ -15,59 +15,96 @@ import (
 // BlockIterator helps fetching blocks row-by-row
 type BlockIterator struct {
 	rows *sql.Rows
+	err  error
 }
 
-// Next returns next block
+// Next returns next block, or nil after the last block or an error (see Err)
 func (i *BlockIterator) Next() *types.StoredBlock {
-	if !i.rows.Next() {
+	if i.err != nil || !i.rows.Next() {
 		return nil
 	}
 
-	var blockHashBytes []byte
-	var parentHashBytes []byte
 	var firstSeen int64
+	var encodedTime *int64
 	var block types.StoredBlock
 	err := i.rows.Scan(
 		&block.DBID,
-		&blockHashBytes,
-		&parentHashBytes,
+		(*hashColumn)(&block.Hash),
+		(*hashColumn)(&block.Parent),
 		&firstSeen,
 		&block.Height,
 		&block.IsBest,
+		&block.Weight,
+		&block.TxCount,
+		&block.Miner,
+		&block.NearEmpty,
+		&block.Bits,
+		&encodedTime,
+		&block.InBestChain,
 	)
 	if err != nil {
-		panic(err)
+		i.err = errors.Wrap(err, "could not scan block")
+		return nil
+	}
+	if encodedTime != nil {
+		block.EncodedTime = time.Unix(*encodedTime, 0).UTC()
 	}
-	block.Hash = types.NewHashFromBytes(blockHashBytes)
-	block.Parent = types.NewHashFromBytes(parentHashBytes)
 	block.FirstSeen = time.Unix(firstSeen, 0).UTC()
 	return &block
 }
 
+// Err returns the error that ended the iteration, if any
+func (i *BlockIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.rows.Err()
+}
+
 // Close underlying cursor
 func (i *BlockIterator) Close() error {
 	return i.rows.Close()
 }
 
 // Collect returns remaining blocks as list and closes cursor
-func (i *BlockIterator) Collect() (res []types.StoredBlock) {
+func (i *BlockIterator) Collect() (res []types.StoredBlock, err error) {
 	defer i.Close()
 	for b := i.Next(); b != nil; b = i.Next() {
 		res = append(res, *b)
 	}
-	return res
+	return res, i.Err()
+}
+
+// blockFields are the columns scanned by BlockIterator
+var blockFields = []string{
+	"id", "hash", "parent", "first_seen", "height", "is_best",
+	"COALESCE(weight, 0)", "COALESCE(tx_count, 0)", "COALESCE(miner, '')", "COALESCE(near_empty, 0)",
+	"COALESCE(bits, 0)", "encoded_time", "in_best_chain",
 }
 
+// markBestChain sets in_best_chain for the highest block that was received as chain tip and its ancestors
+const markBestChain = `
+	UPDATE "block" SET in_best_chain = 1 WHERE id IN (
+		WITH RECURSIVE chain(id, parent) AS (
+			SELECT id, parent FROM (
+				SELECT id, parent FROM "block" WHERE is_best = 1
+				ORDER BY height DESC, first_seen DESC, id DESC LIMIT 1
+			)
+			UNION ALL
+			SELECT b.id, b.parent FROM chain c JOIN "block" b ON b.hash = c.parent
+		)
+		SELECT id FROM chain
+	)`
+
 func (s *Storage) queryBlocks(q Query) (*BlockIterator, error) {
-	fields := []string{"id", "hash", "parent", "first_seen", "height", "is_best"}
-	table := "block"
-	rows, err := s.db.Query(formatQuery(fields, table, q))
+	query, args := formatQuery(blockFields, "block", q)
+	rows, err := s.db.Query(query, args...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &BlockIterator{rows}, nil
+	return &BlockIterator{rows: rows}, nil
 }
 
 func (s *Storage) queryBlock(q Query) (*types.StoredBlock, error) {
@@ -76,26 +113,44 @@ func (s *Storage) queryBlock(q Query) (*types.StoredBlock, error) {
 		return nil, err
 	}
 	defer blockIter.Close()
-	return blockIter.Next(), nil
+	block := blockIter.Next()
+	return block, blockIter.Err()
 }
 
 // BlockByHash returns the block with provided hash.
 // Returns nil if no such block exists.
 func (s *Storage) BlockByHash(h types.Hash32) (*types.StoredBlock, error) {
-	return s.queryBlock(StaticQuery{
-		where: fmt.Sprintf(`hash = x'%s'`, h),
-		order: "",
-		limit: 1,
-	})
+	return s.queryBlock(BlockQuery{Hash: &h, MaxResults: 1})
 }
 
 // BestBlockNow returns the most recent best block
 func (s *Storage) BestBlockNow() (*types.StoredBlock, error) {
-	return s.queryBlock(StaticQuery{
-		where: `is_best = 1`,
-		order: "first_seen DESC",
-		limit: 1,
+	return s.queryBlock(BlockQuery{Best: FilterTrue, OrderBy: BlockOrderFirstSeenDesc, MaxResults: 1})
+}
+
+// RecentBestBlocks returns the `limit` most recent blocks of the current best chain, newest first
+func (s *Storage) RecentBestBlocks(limit int) ([]types.StoredBlock, error) {
+	blocks, err := s.queryBlocks(BlockQuery{BestChain: FilterTrue, OrderBy: BlockOrderFirstSeenDesc, MaxResults: limit})
+	if err != nil {
+		return nil, dbError(err, "error querying blocks")
+	}
+	return blocks.Collect()
+}
+
+// BlocksFirstSeen returns at most `limit` blocks first seen between `from` and `to`, in order of their
+// first-seen time. `bestChain` selects blocks of the best chain, of stale chains or both.
+func (s *Storage) BlocksFirstSeen(from, to time.Time, bestChain BoolFilter, limit int) ([]types.StoredBlock, error) {
+	blocks, err := s.queryBlocks(BlockQuery{
+		FirstSeenFrom: &from,
+		FirstSeenTo:   &to,
+		BestChain:     bestChain,
+		OrderBy:       BlockOrderFirstSeen,
+		MaxResults:    limit,
 	})
+	if err != nil {
+		return nil, dbError(err, "error querying blocks")
+	}
+	return blocks.Collect()
 }
 
 // HasBlocks returns true if one or more blocks are stored
@@ -109,22 +164,22 @@ func (s *Storage) HasBlocks() (bool, error) {
 
 // BestBlockAtTime returns latest best block before or at provided time
 func (s *Storage) BestBlockAtTime(t time.Time) (*types.StoredBlock, error) {
-	return s.queryBlock(StaticQuery{
-		where: fmt.Sprintf(`(is_best = 1) AND (first_seen <= %d)`, t.Unix()),
-		order: "first_seen DESC",
-		limit: 1,
+	return s.queryBlock(BlockQuery{
+		Best:        FilterTrue,
+		FirstSeenTo: &t,
+		OrderBy:     BlockOrderFirstSeenDesc,
+		MaxResults:  1,
 	})
 }
 
 // NextBestBlocks returns best blocks after time `t`.
 // If multiple blocks at `t` exist, return block with higher `dbid`
 func (s *Storage) NextBestBlocks(t time.Time, dbid int64, limit int) (*BlockIterator, error) {
-	return s.queryBlocks(StaticQuery{
-		where: fmt.Sprintf(
-			`((first_seen > %d) OR ((first_seen == %d) AND (id > %d))) AND (is_best = 1)`,
-			t.Unix(), t.Unix(), dbid),
-		order: "first_seen ASC, id ASC",
-		limit: limit,
+	return s.queryBlocks(BlockQuery{
+		Best:           FilterTrue,
+		FirstSeenAfter: &Cursor{Time: t, DBID: dbid},
+		OrderBy:        BlockOrderFirstSeen,
+		MaxResults:     limit,
 	})
 }
 
@@ -150,10 +205,10 @@ func (s *Storage) CommonAncestor(a, b *types.StoredBlock) (*types.StoredBlock, e
 
 	aParent, err := s.BlockByHash(a.Parent)
 	if err != nil {
-		return nil, errors.Errorf("error retrieving parent: %s", err)
+		return nil, dbError(err, "error retrieving parent")
 	}
 	if aParent == nil {
-		return nil, errors.Errorf("parent not found: %s", a.Parent)
+		return nil, errors.Wrapf(types.ErrNotFound, "parent %s", a.Parent)
 	}
 
 	return s.CommonAncestor(aParent, b)
@@ -172,7 +227,7 @@ func (s *Storage) ReorgBase(block *types.Block) (*types.StoredBlock, error) {
 		return nil, err
 	}
 	if storedBlock == nil {
-		return nil, errors.Errorf("could not load block %s", block.Hash)
+		return nil, errors.Wrapf(types.ErrNotFound, "block %s", block.Hash)
 	}
 
 	lastBest := storedBlock
@@ -182,15 +237,16 @@ func (s *Storage) ReorgBase(block *types.Block) (*types.StoredBlock, error) {
 	// In the regular case where there is no minority chain, this terminates immediately
 	// and returns _block_
 	for height := block.Height; ; height++ {
-		blockIter, err := s.queryBlocks(StaticQuery{
-			where: fmt.Sprintf("height = %d", height),
-			order: "first_seen ASC",
-		})
+		height := height
+		blockIter, err := s.queryBlocks(BlockQuery{MinHeight: &height, MaxHeight: &height, OrderBy: BlockOrderFirstSeen})
 		if err != nil {
 			return nil, err
 		}
 
-		blocks := blockIter.Collect()
+		blocks, err := blockIter.Collect()
+		if err != nil {
+			return nil, err
+		}
 
 		for _, b := range blocks {
 			b := b
@@ -230,6 +286,98 @@ func (s *Storage) WalkBlocks(start, end *types.StoredBlock, f func(*types.Stored
 	return nil
 }
 
+// BlockTxIDs returns the txids of the stored transactions of the block with database id `dbid`
+// in block order
+func (s *Storage) BlockTxIDs(dbid int64) ([]types.Hash32, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.txid
+		FROM
+			"transaction_block" tb
+		JOIN
+			"transaction" t ON t.id = tb.transaction_id
+		WHERE
+			tb.block_id = ?
+		ORDER BY
+			tb.block_index ASC
+		`, dbid,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying transactions of block")
+	}
+	defer rows.Close()
+
+	var res []types.Hash32
+	for rows.Next() {
+		var txid types.Hash32
+		if err := rows.Scan((*hashColumn)(&txid)); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res = append(res, txid)
+	}
+	return res, rows.Err()
+}
+
+// GetBestBlock returns the tip of the best chain. Returns nil if no blocks are stored.
+func (s *Storage) GetBestBlock() (*types.StoredBlock, error) {
+	block, err := s.queryBlock(BlockQuery{BestChain: FilterTrue, OrderBy: BlockOrderHeightDesc, MaxResults: 1})
+	if err != nil {
+		return nil, dbError(err, "error querying best block")
+	}
+	return block, nil
+}
+
+// GetBlockByHeight returns the block at height `height` of the best chain.
+// Returns nil if there is no such block. See BlocksByHeight for all blocks at a height.
+func (s *Storage) GetBlockByHeight(height uint32) (*types.StoredBlock, error) {
+	block, err := s.queryBlock(BlockQuery{MinHeight: &height, MaxHeight: &height, BestChain: FilterTrue, MaxResults: 1})
+	if err != nil {
+		return nil, dbError(err, "error querying block at height %d", height)
+	}
+	return block, nil
+}
+
+// WalkChain returns the block with hash `from` and its ancestors, newest first, at most `n` blocks.
+// Fewer blocks are returned if the chain reaches the first stored block.
+// Returns types.ErrNotFound if the block is not stored.
+func (s *Storage) WalkChain(from types.Hash32, n int) ([]types.StoredBlock, error) {
+	block, err := s.BlockByHash(from)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.Wrapf(types.ErrNotFound, "block %s", from)
+	}
+	if n <= 0 {
+		return []types.StoredBlock{}, nil
+	}
+	return s.chain(block.DBID, "c.depth < ?", n)
+}
+
+// chain returns the block with database id `dbid` and its ancestors, newest first.
+// The walk continues to the parent of a block while `cond` holds; `cond` has the columns
+// `c.height` and `c.depth`, which is 1 for the first block, and the placeholder value `arg`.
+func (s *Storage) chain(dbid int64, cond string, arg interface{}) ([]types.StoredBlock, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`
+		WITH RECURSIVE chain(id, parent, height, depth) AS (
+			SELECT id, parent, height, 1 FROM "block" WHERE id = ?
+			UNION ALL
+			SELECT b.id, b.parent, b.height, c.depth + 1
+			FROM chain c JOIN "block" b ON b.hash = c.parent
+			WHERE %s
+		)
+		SELECT %s FROM "block" WHERE id IN (SELECT id FROM chain) ORDER BY height DESC
+		`, cond, strings.Join(blockFields, ",")), dbid, arg)
+	if err != nil {
+		return nil, dbError(err, "error walking the chain")
+	}
+	res, err := (&BlockIterator{rows: rows}).Collect()
+	if res == nil && err == nil {
+		res = []types.StoredBlock{}
+	}
+	return res, err
+}
+
 // Updates the last_removed timestamps of transactions.
 // In the default case, the new best block has current best block as parent,
 // and we set `last_removed` of the contained transactions to `newBest.FirstSeen`.
@@ -243,6 +391,9 @@ func (s *Storage) updateBestBlock(lastBest, newBest *types.StoredBlock) error {
 	// the first block is a special case
 	if lastBest == nil {
 		log.Warn("WARNING: lastBest=nil, assuming this is the first block")
+		if err := s.setInBestChain(newBest, true); err != nil {
+			return err
+		}
 		return s.updateLastRemoved(newBest, &newBest.FirstSeen)
 	}
 
@@ -264,8 +415,13 @@ func (s *Storage) updateBestBlock(lastBest, newBest *types.StoredBlock) error {
 	// Clear old `last_removed` values.
 	// In the default case, currentBest == CommonAncestor and the func is not called.
 	// In case of a reorg, this clears the values up to the common ancestor
+	var orphaned, attached []types.StoredBlock
 	err = s.WalkBlocks(lastBest, commonAncestor, func(block *types.StoredBlock) error {
 		log.Infof("REORG: clearing last_removed for block %s heigth %d", block.Hash, block.Height)
+		orphaned = append(orphaned, *block)
+		if err := s.setInBestChain(block, false); err != nil {
+			return err
+		}
 		return s.updateLastRemoved(block, nil)
 	})
 	if err != nil {
@@ -275,14 +431,31 @@ func (s *Storage) updateBestBlock(lastBest, newBest *types.StoredBlock) error {
 	// Set `last_removed` for the new best chain to the new value.
 	// In the default case, this only updates the values of the transactions contained
 	// in newBest.
-	return s.WalkBlocks(newBest, commonAncestor, func(block *types.StoredBlock) error {
+	err = s.WalkBlocks(newBest, commonAncestor, func(block *types.StoredBlock) error {
+		attached = append(attached, *block)
+		if err := s.setInBestChain(block, true); err != nil {
+			return err
+		}
 		return s.updateLastRemoved(block, &newBest.FirstSeen)
 	})
+	if err != nil {
+		return err
+	}
+
+	return s.insertReorg(commonAncestor, lastBest, newBest, orphaned, attached)
+}
+
+// setInBestChain sets whether `block` is on the best chain, see types.Block.InBestChain
+func (s *Storage) setInBestChain(block *types.StoredBlock, inBestChain bool) error {
+	_, err := s.db.Exec(`UPDATE "block" SET in_best_chain = ? WHERE id = ?`, inBestChain, block.DBID)
+	return dbError(err, "could not update in_best_chain of block %s", block.Hash)
 }
 
 // inserts new block with some basic sanity checks
 func (s *Storage) insertBlock(block *types.Block, firstBlock bool) (int64, error) {
 	var zeroHash types.Hash32
+	nearEmpty := false
+	var firstSeenInterval, headerInterval interface{}
 
 	// sanity check height and parent
 	if block.Parent != zeroHash && !firstBlock {
@@ -296,7 +469,9 @@ func (s *Storage) insertBlock(block *types.Block, firstBlock bool) (int64, error
 		}
 
 		if parentBlock == nil {
-			return 0, errors.Errorf("parentBlock==nil")
+			return 0, errors.Wrapf(
+				types.ErrReorgDetected, "parent %s of block %s not stored", block.Parent, block.Hash,
+			)
 		}
 
 		if block.Height != (parentBlock.Height + 1) {
@@ -305,16 +480,34 @@ func (s *Storage) insertBlock(block *types.Block, firstBlock bool) (int64, error
 				block.Height, block.Hash, parentBlock.Hash, parentBlock.Height,
 			)
 		}
+
+		nearEmpty = block.Weight > 0 &&
+			block.Utilization() < types.NearEmptyMaxUtilization &&
+			block.FirstSeen.Sub(parentBlock.FirstSeen) <= types.NearEmptyWindow
+
+		firstSeenInterval = block.FirstSeen.Unix() - parentBlock.FirstSeen.Unix()
+		if !block.EncodedTime.IsZero() && !parentBlock.EncodedTime.IsZero() {
+			headerInterval = block.EncodedTime.Unix() - parentBlock.EncodedTime.Unix()
+		}
+	}
+
+	var encodedTime interface{}
+	if !block.EncodedTime.IsZero() {
+		encodedTime = block.EncodedTime.Unix()
 	}
 
 	const insertBlock string = `
 	INSERT INTO
-	 	"block" (hash, first_seen, parent, height, is_best) 
+	 	"block" (
+			hash, first_seen, parent, height, is_best, weight, tx_count, miner, near_empty, bits, encoded_time,
+			first_seen_interval, header_interval
+		)
  	VALUES
- 		(?, ?, ?, ?, ?)
+ 		(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(hash) DO
 		UPDATE SET
-			first_seen = excluded.first_seen
+			first_seen = excluded.first_seen,
+			first_seen_interval = excluded.first_seen_interval
 		WHERE
 			first_seen > excluded.first_seen
 	`
@@ -325,12 +518,38 @@ func (s *Storage) insertBlock(block *types.Block, firstBlock bool) (int64, error
 		block.Parent[:],
 		block.Height,
 		block.IsBest,
+		block.Weight,
+		block.TxCount,
+		block.Miner,
+		nearEmpty,
+		block.Bits,
+		encodedTime,
+		firstSeenInterval,
+		headerInterval,
+	)
+	if err != nil {
+		return 0, dbError(err, "could not insert a block into table `block`")
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	// a lower first-seen time of a stored block changes the intervals of its children
+	_, err = s.db.Exec(`
+		UPDATE
+			"block"
+		SET
+			first_seen_interval = first_seen - (SELECT p.first_seen FROM "block" p WHERE p.hash = ?1)
+		WHERE
+			height = ?2 AND parent = ?1
+		`, block.Hash[:], block.Height+1,
 	)
 	if err != nil {
-		return 0, errors.Errorf("could not insert a block into table `block`: %s", err)
+		return 0, dbError(err, "could not update the first-seen intervals of the children of %s", block.Hash)
 	}
 
-	return res.LastInsertId()
+	return id, nil
 }
 
 func (s *Storage) insertTransactionBlock(blockID int64, dbids []int64) error {
@@ -358,7 +577,7 @@ func (s *Storage) insertTransactionBlock(blockID int64, dbids []int64) error {
 
 	_, err := s.db.Exec(insertTransactionBlock)
 	if err != nil {
-		return errors.Errorf(`error inserting to table "transaction_block": %s`, err)
+		return dbError(err, `error inserting to table "transaction_block"`)
 	}
 
 	return nil
@@ -403,22 +622,35 @@ func (s *Storage) InsertBlock(block *types.Block) (int64, error) {
 		if IsErrorMissingTransactions(err) {
 			return 0, err
 		}
-		return 0, errors.Errorf("error getting tx database ids: %s", err)
+		return 0, dbError(err, "error getting tx database ids")
 	}
 
 	currentBest, errBestBlock := s.BestBlockNow()
 	if errBestBlock != nil {
-		return 0, err
+		return 0, errBestBlock
 	}
 
 	blockID, err := s.insertBlock(block, currentBest == nil)
 	if err != nil {
-		return 0, errors.Errorf("error in insertBlock(): %s", err)
+		return 0, dbError(err, "error in insertBlock()")
 	}
 
 	err = s.insertTransactionBlock(blockID, *txDbIds)
 	if err != nil {
-		return 0, errors.Errorf("error in insertTransactionBlock(): %s", err)
+		return 0, dbError(err, "error in insertTransactionBlock()")
+	}
+
+	if err := s.insertDrain(blockID); err != nil {
+		return 0, err
+	}
+
+	if block.UTXODelta != nil {
+		if err := s.insertUTXODelta(blockID, block.UTXODelta); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.insertBlockAnalysis(blockID, block.Analysis, block.FirstSeen); err != nil {
+		return 0, err
 	}
 
 	if block.IsBest {
@@ -429,6 +661,9 @@ func (s *Storage) InsertBlock(block *types.Block) (int64, error) {
 		if err := s.updateBestBlock(currentBest, &storedBlock); err != nil {
 			return 0, err
 		}
+		if err := s.insertDoubleSpends(&storedBlock); err != nil {
+			return 0, err
+		}
 	}
 
 	return blockID, nil
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -65,11 +66,12 @@ func TestStorage_InsertBlock(t *testing.T) {
 		}
 
 		if n < 4 {
-			storedBlock, err := st.queryBlock(StaticQuery{where: fmt.Sprintf(`hash = x'%s'`, block.Hash)})
+			storedBlock, err := st.queryBlock(BlockQuery{Hash: &block.Hash})
 			require.NoError(t, err)
 			txIter, err := st.TransactionsInBlock(storedBlock.DBID)
 			require.NoError(t, err)
-			txsInBlock := txIter.Collect()
+			txsInBlock, err := txIter.Collect()
+			require.NoError(t, err)
 			require.Len(t, txsInBlock, 2)
 			testLastRemoved(blocks[1].TxIDs, blocks[1].FirstSeen)
 
@@ -199,6 +201,108 @@ func TestStorage_commonAncestor(t *testing.T) {
 	testCommonAncestor("3.2", "5", "3")
 }
 
+func TestStorage_ChainView(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tip, err := st.GetBestBlock()
+	require.NoError(t, err)
+	assert.Nil(t, tip)
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	hashes := func(blocks []types.StoredBlock) (res []types.Hash32) {
+		for _, b := range blocks {
+			res = append(res, b.Hash)
+		}
+		return res
+	}
+
+	// blocks "3" and "1.2" are best blocks at height 2, "1.2" was received last
+	tip, err = st.GetBestBlock()
+	require.NoError(t, err)
+	assert.Equal(t, test.GenerateHash32("1.2"), tip.Hash)
+
+	for height, expected := range []string{"1", "1.1", "1.2"} {
+		block, err := st.GetBlockByHeight(uint32(height))
+		require.NoError(t, err)
+		require.NotNil(t, block, "height %d", height)
+		assert.Equal(t, test.GenerateHash32(expected), block.Hash, "height %d", height)
+	}
+	block, err := st.GetBlockByHeight(3)
+	require.NoError(t, err)
+	assert.Nil(t, block)
+
+	chain, err := st.WalkChain(test.GenerateHash32("3"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, txidsFromStrings("3", "2", "1"), hashes(chain))
+
+	chain, err = st.WalkChain(test.GenerateHash32("1.2"), 2)
+	require.NoError(t, err)
+	assert.Equal(t, txidsFromStrings("1.2", "1.1"), hashes(chain))
+
+	_, err = st.WalkChain(test.GenerateHash32("unknown"), 10)
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+}
+
+func TestStorage_InBestChain(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	// the reorg to "1.2" disconnects "2" and "3" and connects "1.1"
+	requireBestChain := func(st *Storage) {
+		for name, expected := range map[string]bool{"1": true, "2": false, "3": false, "1.1": true, "1.2": true} {
+			block, err := st.BlockByHash(test.GenerateHash32(name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, block.InBestChain, "block %s", name)
+		}
+
+		blocks, err := st.BlocksByHeight(1)
+		require.NoError(t, err)
+		atHeight, err := blocks.Collect()
+		require.NoError(t, err)
+		require.Len(t, atHeight, 2)
+		assert.Equal(t, test.GenerateHash32("1.1"), atHeight[0].Hash)
+		// "2" was the chain tip when it was received
+		assert.True(t, atHeight[1].IsBest)
+	}
+	requireBestChain(st)
+
+	// the migration derives the best chain of existing databases
+	require.NoError(t, st.Downgrade(currentVersion-1))
+	require.NoError(t, st.Close())
+	st, err = NewStorage(StoragePath())
+	require.NoError(t, err)
+	defer st.Close()
+	requireBestChain(st)
+}
+
+func TestStorage_BlockTxIDs(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	block, err := st.BlockByHash(test.GenerateHash32("1.2"))
+	require.NoError(t, err)
+	txids, err := st.BlockTxIDs(block.DBID)
+	require.NoError(t, err)
+	assert.Equal(t, txidsFromStrings("tx-30", "tx-210"), txids)
+}
+
 func TestStorage_ReorgBase(t *testing.T) {
 	test.SkipIfShort(t)
 
@@ -259,3 +363,18 @@ func TestStorage_ReorgBase(t *testing.T) {
 		assert.Equal(t, block, reorgBase)
 	}
 }
+
+func TestStorage_InsertBlock_MissingParent(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	blocks := chainedBlocks(0, "", []string{"a"})
+	blocks[0].IsBest = true
+	require.NoError(t, insertBlocks(st, blocks))
+
+	_, err = st.InsertBlock(&chainedBlocks(2, "missing", []string{"c"})[0])
+	assert.Equal(t, types.ErrReorgDetected, errors.Cause(err))
+}
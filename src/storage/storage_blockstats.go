@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// MinerBlockStats aggregates the best-chain blocks first seen in `from <= first_seen < to`
+// per miner tag in buckets of length `interval`. Blocks without known weight are not counted.
+func (s *Storage) MinerBlockStats(from, to time.Time, interval time.Duration) ([]types.MinerBlockStats, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			(first_seen - ?1) / ?2 AS bucket,
+			COALESCE(miner, ''),
+			COUNT(*),
+			COALESCE(SUM(near_empty), 0),
+			AVG(weight) / ?4
+		FROM
+			"block"
+		WHERE
+			in_best_chain = 1 AND weight IS NOT NULL AND first_seen >= ?1 AND first_seen < ?3
+		GROUP BY
+			bucket, miner
+		ORDER BY
+			bucket ASC, miner ASC
+		`, from.Unix(), seconds, to.Unix(), float64(types.MaxBlockWeight),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying block stats")
+	}
+	defer rows.Close()
+
+	res := []types.MinerBlockStats{}
+	for rows.Next() {
+		var bucket int64
+		var m types.MinerBlockStats
+		if err := rows.Scan(&bucket, &m.Miner, &m.Blocks, &m.NearEmptyBlocks, &m.AvgUtilization); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		m.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+// OverpaymentStats aggregates the fees paid above the lowest feerate of the block for the transactions
+// of best-chain blocks first seen in `from <= first_seen < to`, in buckets of length `interval`.
+// Only transactions with known weight are considered.
+func (s *Storage) OverpaymentStats(from, to time.Time, interval time.Duration) ([]types.OverpaymentStats, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		WITH
+			confirmed AS (
+				SELECT
+					b.id AS block_id,
+					b.first_seen AS block_time,
+					t.fee AS fee,
+					t.weight AS weight,
+					CAST(t.fee AS REAL) * 4 / t.weight AS feerate
+				FROM
+					"block" b
+				JOIN
+					"transaction_block" tb ON tb.block_id = b.id
+				JOIN
+					"transaction" t ON t.id = tb.transaction_id
+				WHERE
+					b.in_best_chain = 1 AND b.first_seen >= ?1 AND b.first_seen < ?3 AND t.weight > 0
+			),
+			lowest AS (
+				SELECT block_id, MIN(feerate) AS feerate FROM confirmed GROUP BY block_id
+			)
+		SELECT
+			(c.block_time - ?1) / ?2 AS bucket,
+			COUNT(DISTINCT c.block_id),
+			COUNT(*),
+			SUM(c.fee),
+			SUM(MAX(c.fee - l.feerate * c.weight / 4, 0))
+		FROM
+			confirmed c
+		JOIN
+			lowest l ON l.block_id = c.block_id
+		GROUP BY
+			bucket
+		ORDER BY
+			bucket ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying overpayment stats")
+	}
+	defer rows.Close()
+
+	res := []types.OverpaymentStats{}
+	for rows.Next() {
+		var bucket int64
+		var overpaid float64
+		var o types.OverpaymentStats
+		if err := rows.Scan(&bucket, &o.Blocks, &o.Transactions, &o.Fees, &overpaid); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		o.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		o.Overpaid = uint64(overpaid + 0.5)
+		res = append(res, o)
+	}
+
+	return res, rows.Err()
+}
+
+// CPFPStats aggregates the child-pays-for-parent packages of best-chain blocks first seen in
+// `from <= first_seen < to`, in buckets of length `interval`. Only transactions with known weight
+// and stored inputs are considered, a child with several parents in the block is counted per parent.
+func (s *Storage) CPFPStats(from, to time.Time, interval time.Duration) ([]types.CPFPStats, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		WITH
+			confirmed AS (
+				SELECT
+					b.id AS block_id,
+					b.first_seen AS block_time,
+					t.id AS transaction_id,
+					t.first_seen AS first_seen,
+					t.fee AS fee,
+					t.weight AS weight
+				FROM
+					"block" b
+				JOIN
+					"transaction_block" tb ON tb.block_id = b.id
+				JOIN
+					"transaction" t ON t.id = tb.transaction_id
+				WHERE
+					b.in_best_chain = 1 AND b.first_seen >= ?1 AND b.first_seen < ?3 AND t.weight > 0
+			),
+			packages AS (
+				SELECT DISTINCT
+					c.block_time,
+					p.id AS parent_id,
+					c.transaction_id AS child_id,
+					p.fee AS parent_fee,
+					p.weight AS parent_weight,
+					c.fee AS child_fee,
+					c.weight AS child_weight,
+					p.first_seen AS parent_first_seen,
+					c.first_seen AS child_first_seen
+				FROM
+					confirmed c
+				JOIN
+					"transaction_input" i ON i.transaction_id = c.transaction_id
+				JOIN
+					"transaction" p ON p.txid = i.prev_txid
+				JOIN
+					"transaction_block" ptb ON ptb.transaction_id = p.id AND ptb.block_id = c.block_id
+				WHERE
+					p.weight > 0 AND p.fee * c.weight < c.fee * p.weight
+			),
+			totals AS (
+				SELECT
+					(block_time - ?1) / ?2 AS bucket,
+					COUNT(DISTINCT block_id) AS blocks,
+					COUNT(*) AS transactions
+				FROM
+					confirmed
+				GROUP BY
+					bucket
+			),
+			cpfp AS (
+				SELECT
+					(block_time - ?1) / ?2 AS bucket,
+					COUNT(*) AS packages,
+					AVG(CAST(parent_fee AS REAL) * 4 / parent_weight) AS parent_feerate,
+					AVG(CAST(parent_fee + child_fee AS REAL) * 4 / (parent_weight + child_weight)) AS package_feerate,
+					AVG(MAX(child_first_seen - parent_first_seen, 0)) AS wait,
+					AVG(MAX(block_time - child_first_seen, 0)) AS confirm
+				FROM
+					packages
+				GROUP BY
+					bucket
+			)
+		SELECT
+			t.bucket, t.blocks, t.transactions, COALESCE(c.packages, 0), COALESCE(c.parent_feerate, 0),
+			COALESCE(c.package_feerate, 0), COALESCE(c.wait, 0), COALESCE(c.confirm, 0)
+		FROM
+			totals t
+		LEFT JOIN
+			cpfp c ON c.bucket = t.bucket
+		ORDER BY
+			t.bucket ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying CPFP stats")
+	}
+	defer rows.Close()
+
+	res := []types.CPFPStats{}
+	for rows.Next() {
+		var bucket int64
+		var c types.CPFPStats
+		err := rows.Scan(
+			&bucket, &c.Blocks, &c.Transactions, &c.Packages, &c.ParentFeerate, &c.PackageFeerate,
+			&c.WaitSeconds, &c.ConfirmSeconds,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		c.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// BlockShareStats aggregates the share of block space and fees of the transactions with the
+// heuristic `flag` in the best-chain blocks first seen in `from <= first_seen < to`, in buckets of
+// length `interval`. Only transactions classified by the daemon have flags.
+func (s *Storage) BlockShareStats(flag types.HeuristicFlags, from, to time.Time, interval time.Duration) ([]types.BlockShareStats, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		WITH
+			blocks AS (
+				SELECT
+					id, first_seen, weight
+				FROM
+					"block"
+				WHERE
+					in_best_chain = 1 AND first_seen >= ?1 AND first_seen < ?3
+			),
+			totals AS (
+				SELECT
+					(first_seen - ?1) / ?2 AS bucket,
+					COUNT(*) AS blocks,
+					COALESCE(SUM(weight), 0) AS weight
+				FROM
+					blocks
+				GROUP BY
+					bucket
+			),
+			confirmed AS (
+				SELECT
+					(b.first_seen - ?1) / ?2 AS bucket,
+					COUNT(*) AS transactions,
+					COALESCE(SUM(t.fee), 0) AS fees,
+					SUM(CASE WHEN t.heuristics & ?4 THEN 1 ELSE 0 END) AS flagged,
+					SUM(CASE WHEN t.heuristics & ?4 THEN t.weight ELSE 0 END) AS flagged_weight,
+					SUM(CASE WHEN t.heuristics & ?4 THEN COALESCE(t.fee, 0) ELSE 0 END) AS flagged_fees
+				FROM
+					blocks b
+				JOIN
+					"transaction_block" tb ON tb.block_id = b.id
+				JOIN
+					"transaction" t ON t.id = tb.transaction_id
+				WHERE
+					t.weight > 0
+				GROUP BY
+					bucket
+			)
+		SELECT
+			t.bucket, t.blocks, t.weight, COALESCE(c.transactions, 0), COALESCE(c.fees, 0),
+			COALESCE(c.flagged, 0), COALESCE(c.flagged_weight, 0), COALESCE(c.flagged_fees, 0)
+		FROM
+			totals t
+		LEFT JOIN
+			confirmed c ON c.bucket = t.bucket
+		ORDER BY
+			t.bucket ASC
+		`, from.Unix(), seconds, to.Unix(), flag,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying block share stats")
+	}
+	defer rows.Close()
+
+	res := []types.BlockShareStats{}
+	for rows.Next() {
+		var bucket int64
+		var b types.BlockShareStats
+		err := rows.Scan(
+			&bucket, &b.Blocks, &b.BlockWeight, &b.Transactions, &b.Fees, &b.Flagged, &b.FlaggedWeight, &b.FlaggedFees,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		b.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		if b.BlockWeight > 0 {
+			b.WeightShare = float64(b.FlaggedWeight) / float64(b.BlockWeight)
+		}
+		if b.Fees > 0 {
+			b.FeeShare = float64(b.FlaggedFees) / float64(b.Fees)
+		}
+		res = append(res, b)
+	}
+
+	return res, rows.Err()
+}
+
+// BlockIntervals returns the distribution of the intervals to their parent of the best-chain blocks
+// first seen in `from <= first_seen < to`, and lists the blocks with a first-seen interval of at least `longGap`.
+// Blocks whose parent is not stored are not counted.
+func (s *Storage) BlockIntervals(from, to time.Time, longGap time.Duration) (*types.BlockIntervalStats, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			hash, height, first_seen, first_seen_interval, header_interval
+		FROM
+			"block"
+		WHERE
+			in_best_chain = 1 AND first_seen_interval IS NOT NULL AND first_seen >= ? AND first_seen < ?
+		ORDER BY
+			first_seen ASC, id ASC
+		`, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying block intervals")
+	}
+	defer rows.Close()
+
+	stats := &types.BlockIntervalStats{
+		From:     from.UTC(),
+		To:       to.UTC(),
+		LongGap:  int64(longGap.Seconds()),
+		LongGaps: []types.BlockInterval{},
+	}
+	var firstSeenIntervals, headerIntervals []int64
+	for rows.Next() {
+		var firstSeen int64
+		var b types.BlockInterval
+		if err := rows.Scan((*hashColumn)(&b.Hash), &b.Height, &firstSeen, &b.Interval, &b.HeaderInterval); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+
+		firstSeenIntervals = append(firstSeenIntervals, b.Interval)
+		if b.HeaderInterval != nil {
+			headerIntervals = append(headerIntervals, *b.HeaderInterval)
+		}
+		if b.Interval >= stats.LongGap {
+			b.FirstSeen = time.Unix(firstSeen, 0).UTC()
+			stats.LongGaps = append(stats.LongGaps, b)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error reading rows")
+	}
+
+	stats.FirstSeen = types.NewIntervalDistribution(firstSeenIntervals)
+	stats.Header = types.NewIntervalDistribution(headerIntervals)
+	return stats, nil
+}
@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_MinerBlockStats(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	blocks := chainedBlocks(0, "", []string{"a", "b", "c"})
+	for i, weight := range []int{3990000, 1000, 2000} {
+		blocks[i].IsBest = true
+		blocks[i].Weight = weight
+		blocks[i].Miner = "/pool/"
+	}
+	// mined long after its parent
+	blocks[2].FirstSeen = GetTime(400)
+	// near-empty competitor of "b" that was the chain tip when it was received, but is not
+	// in the best chain anymore
+	stale := chainedBlocks(1, "a", []string{"b-stale"})[0]
+	stale.IsBest = true
+	stale.Weight = 1000
+	stale.Miner = "/pool/"
+	stale.FirstSeen = GetTime(50)
+	require.NoError(t, insertBlocks(st, []types.Block{blocks[0], stale, blocks[1], blocks[2]}))
+
+	stored, err := st.BlockByHash(stale.Hash)
+	require.NoError(t, err)
+	require.True(t, stored.IsBest)
+	require.False(t, stored.InBestChain)
+
+	stored, err = st.BlockByHash(blocks[1].Hash)
+	require.NoError(t, err)
+	assert.True(t, stored.NearEmpty)
+	assert.Equal(t, "/pool/", stored.Miner)
+	assert.Equal(t, 1000, stored.Weight)
+
+	stored, err = st.BlockByHash(blocks[2].Hash)
+	require.NoError(t, err)
+	assert.False(t, stored.NearEmpty)
+
+	stats, err := st.MinerBlockStats(GetTime(0), GetTime(1000), time.Hour)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, types.MinerBlockStats{
+		Time:            GetTime(0),
+		Miner:           "/pool/",
+		Blocks:          3,
+		NearEmptyBlocks: 1,
+		AvgUtilization:  float64(3990000+1000+2000) / 3 / types.MaxBlockWeight,
+	}, stats[0])
+}
+
+func TestStorage_OverpaymentStats(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	// 2, 5 and 10 sat/vB in the first block, 3 sat/vB in the second block
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("a"), FirstSeen: GetTime(0), Fee: 200, Weight: 400},
+		{TxID: test.GenerateHash32("b"), FirstSeen: GetTime(0), Fee: 500, Weight: 400},
+		{TxID: test.GenerateHash32("c"), FirstSeen: GetTime(0), Fee: 2000, Weight: 800},
+		{TxID: test.GenerateHash32("d"), FirstSeen: GetTime(0), Fee: 300, Weight: 400},
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	blocks := chainedBlocks(0, "", []string{"x", "y"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[0].TxIDs = []types.Hash32{txs[0].TxID, txs[1].TxID, txs[2].TxID}
+	blocks[1].TxIDs = []types.Hash32{txs[3].TxID}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	stats, err := st.OverpaymentStats(GetTime(0), GetTime(1000), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OverpaymentStats{{
+		Time:         GetTime(0),
+		Blocks:       2,
+		Transactions: 4,
+		Fees:         3000,
+		// b paid 300 sat and c 1600 sat more than needed at 2 sat/vB
+		Overpaid: 1900,
+	}}, stats)
+}
+
+func TestStorage_CPFPStats(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	spend := func(prev string, prevIndex uint32) *types.TxDetails {
+		return &types.TxDetails{Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32(prev), PrevIndex: prevIndex}}}
+	}
+	// b pays for its parent a at 9 sat/vB, c spends a at a lower feerate than a
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("a"), FirstSeen: GetTime(-600), Fee: 100, Weight: 400, Details: spend("z", 0)},
+		{TxID: test.GenerateHash32("b"), FirstSeen: GetTime(-100), Fee: 900, Weight: 400, Details: spend("a", 0)},
+		{TxID: test.GenerateHash32("c"), FirstSeen: GetTime(-50), Fee: 50, Weight: 400, Details: spend("a", 1)},
+		{TxID: test.GenerateHash32("d"), FirstSeen: GetTime(0), Fee: 300, Weight: 400, Details: spend("b", 0)},
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	blocks := chainedBlocks(0, "", []string{"x", "y"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[0].TxIDs = []types.Hash32{txs[0].TxID, txs[1].TxID, txs[2].TxID}
+	// d spends b, but in a later block
+	blocks[1].TxIDs = []types.Hash32{txs[3].TxID}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	stats, err := st.CPFPStats(GetTime(0), GetTime(1000), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []types.CPFPStats{{
+		Time:           GetTime(0),
+		Blocks:         2,
+		Transactions:   4,
+		Packages:       1,
+		ParentFeerate:  1,
+		PackageFeerate: 5,
+		WaitSeconds:    500,
+		ConfirmSeconds: 100,
+	}}, stats)
+}
+
+func TestStorage_BlockShareStats(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	batch := types.HeuristicBatch | types.HeuristicDust
+	consolidation := types.HeuristicConsolidation
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("a"), FirstSeen: GetTime(0), Fee: 3000, Weight: 2000, Heuristics: &batch},
+		{TxID: test.GenerateHash32("b"), FirstSeen: GetTime(0), Fee: 1000, Weight: 1000, Heuristics: &consolidation},
+		{TxID: test.GenerateHash32("c"), FirstSeen: GetTime(0), Fee: 1000, Weight: 1000},
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	blocks := chainedBlocks(0, "", []string{"x", "y"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+		blocks[i].Weight = 4000
+	}
+	blocks[0].TxIDs = []types.Hash32{txs[0].TxID, txs[1].TxID, txs[2].TxID}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	stats, err := st.BlockShareStats(types.HeuristicBatch, GetTime(0), GetTime(1000), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []types.BlockShareStats{{
+		Time:          GetTime(0),
+		Blocks:        2,
+		BlockWeight:   8000,
+		Transactions:  3,
+		Fees:          5000,
+		Flagged:       1,
+		FlaggedWeight: 2000,
+		FlaggedFees:   3000,
+		WeightShare:   0.25,
+		FeeShare:      0.6,
+	}}, stats)
+}
+
+func TestStorage_BlockIntervals(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	blocks := chainedBlocks(0, "", []string{"x", "y", "z"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[0].EncodedTime = GetTime(-10)
+	blocks[1].EncodedTime = GetTime(-20)
+	blocks[2].FirstSeen = GetTime(4000)
+	require.NoError(t, insertBlocks(st, blocks))
+
+	stats, err := st.BlockIntervals(GetTime(0), GetTime(5000), time.Hour)
+	require.NoError(t, err)
+	// the first block has no stored parent
+	assert.Equal(t, 2, stats.FirstSeen.Count)
+	assert.Equal(t, int64(100), stats.FirstSeen.Min)
+	assert.Equal(t, int64(3900), stats.FirstSeen.Max)
+	assert.Equal(t, 2000.0, stats.FirstSeen.Mean)
+	assert.Equal(t, 1, stats.FirstSeen.Buckets[1].Count)
+	assert.Equal(t, 1, stats.FirstSeen.Buckets[6].Count)
+	// header times are not monotonic
+	assert.Equal(t, 1, stats.Header.Count)
+	assert.Equal(t, int64(-10), stats.Header.Min)
+	assert.Equal(t, 1, stats.Header.Buckets[0].Count)
+
+	require.Len(t, stats.LongGaps, 1)
+	assert.Equal(t, blocks[2].Hash, stats.LongGaps[0].Hash)
+	assert.Equal(t, int64(3900), stats.LongGaps[0].Interval)
+	assert.Nil(t, stats.LongGaps[0].HeaderInterval)
+
+	// an earlier first-seen time of a parent updates the interval of its child
+	blocks[1].FirstSeen = GetTime(50)
+	blocks[1].IsBest = false
+	require.NoError(t, insertBlocks(st, blocks[1:2]))
+	stats, err = st.BlockIntervals(GetTime(0), GetTime(5000), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), stats.FirstSeen.Min)
+	assert.Equal(t, int64(3950), stats.FirstSeen.Max)
+	require.Len(t, stats.LongGaps, 1)
+	assert.Equal(t, int64(3950), stats.LongGaps[0].Interval)
+}
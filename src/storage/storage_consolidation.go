@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ConsolidationReport relates the transactions classified as consolidations and first seen in
+// `from <= first_seen < to` to the median feerates of the hourly aggregates, see types.NewConsolidationReport
+func (s *Storage) ConsolidationReport(from, to time.Time) (*types.ConsolidationReport, error) {
+	hours, err := s.Aggregates(time.Hour, from.Truncate(time.Hour), to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			first_seen, COALESCE(fee, 0), COALESCE(weight, 0)
+		FROM
+			"transaction"
+		WHERE
+			heuristics & ? AND first_seen >= ? AND first_seen < ?
+		`, types.HeuristicConsolidation, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying consolidations")
+	}
+	defer rows.Close()
+
+	var consolidations []types.Transaction
+	for rows.Next() {
+		var firstSeen int64
+		var tx types.Transaction
+		if err := rows.Scan(&firstSeen, &tx.Fee, &tx.Weight); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		tx.FirstSeen = time.Unix(firstSeen, 0).UTC()
+		consolidations = append(consolidations, tx)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error reading consolidations")
+	}
+
+	report := types.NewConsolidationReport(hours, consolidations)
+	return &report, nil
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_ConsolidationReport(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	consolidation := types.HeuristicConsolidation
+	// 1 sat/vB and 4 sat/vB in the first hour, a consolidation at 2 sat/vB in the second hour
+	_, err = st.InsertTransactions([]types.Transaction{
+		{TxID: test.GenerateHash32("a"), FirstSeen: GetTime(0), Fee: 100, Weight: 400, Heuristics: &consolidation},
+		{TxID: test.GenerateHash32("b"), FirstSeen: GetTime(60), Fee: 400, Weight: 400},
+		{TxID: test.GenerateHash32("c"), FirstSeen: GetTime(3600), Fee: 200, Weight: 400, Heuristics: &consolidation},
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.RefreshAggregates(time.Hour, GetTime(0), GetTime(2*3600)))
+
+	report, err := st.ConsolidationReport(GetTime(0), GetTime(2*3600))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Consolidations)
+	assert.Equal(t, 0, report.Unknown)
+	// median of 1 and 4 sat/vB
+	assert.Equal(t, 1, report.Levels[3].Hours)
+	assert.Equal(t, 1, report.Levels[3].Consolidations)
+	assert.Equal(t, 1.0, report.Levels[3].MeanFeerate)
+	assert.Equal(t, 1, report.Levels[2].Consolidations)
+	assert.Equal(t, 2.0, report.Levels[2].MeanFeerate)
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertTransactionDetails stores inputs and outputs of `txs`.
+// Details of transactions that are already stored are not changed.
+func (s *Storage) insertTransactionDetails(txs []types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	txids := make([]types.Hash32, len(txs))
+	for i, tx := range txs {
+		txids[i] = tx.TxID
+	}
+	dbids, err := s.transactionDBIDs(txids)
+	if err != nil {
+		return err
+	}
+
+	inputs := []string{}
+	outputs := []string{}
+	for i, tx := range txs {
+		dbid := (*dbids)[i]
+		if dbid < 0 {
+			continue
+		}
+		for n, in := range tx.Details.Inputs {
+			inputs = append(inputs, fmt.Sprintf(
+				`(%d, %d, x'%x', %d, %d)`,
+				dbid, n, in.PrevTxID[:], in.PrevIndex, in.Sequence,
+			))
+		}
+		for n, out := range tx.Details.Outputs {
+			outputs = append(outputs, fmt.Sprintf(
+				`(%d, %d, %d, '%s', %d)`,
+				dbid, n, out.Value, out.ScriptType, out.ScriptSize,
+			))
+		}
+	}
+
+	if len(inputs) > 0 {
+		_, err := s.db.Exec(fmt.Sprintf(`
+			INSERT OR IGNORE INTO
+				"transaction_input"
+				(transaction_id, n, prev_txid, prev_index, sequence)
+			VALUES
+				%s
+			`, strings.Join(inputs, ","),
+		))
+		if err != nil {
+			return dbError(err, "could not insert into table `transaction_input`")
+		}
+	}
+
+	if len(outputs) > 0 {
+		_, err := s.db.Exec(fmt.Sprintf(`
+			INSERT OR IGNORE INTO
+				"transaction_output"
+				(transaction_id, n, value, script_type, script_size)
+			VALUES
+				%s
+			`, strings.Join(outputs, ","),
+		))
+		if err != nil {
+			return dbError(err, "could not insert into table `transaction_output`")
+		}
+	}
+
+	return nil
+}
+
+// TransactionDetails returns the stored inputs and outputs of the transaction with database id `dbid`.
+// Returns nil if no details are stored.
+func (s *Storage) TransactionDetails(dbid int64) (*types.TxDetails, error) {
+	details := types.TxDetails{
+		Inputs:  []types.TxInput{},
+		Outputs: []types.TxOutput{},
+	}
+
+	inRows, err := s.db.Query(`
+		SELECT
+			prev_txid, prev_index, sequence
+		FROM
+			"transaction_input"
+		WHERE
+			transaction_id = ?
+		ORDER BY
+			n ASC
+		`, dbid,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying transaction inputs")
+	}
+	defer inRows.Close()
+
+	for inRows.Next() {
+		var in types.TxInput
+		if err := inRows.Scan((*hashColumn)(&in.PrevTxID), &in.PrevIndex, &in.Sequence); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		details.Inputs = append(details.Inputs, in)
+	}
+	if err := inRows.Err(); err != nil {
+		return nil, dbError(err, "error reading transaction inputs")
+	}
+
+	outRows, err := s.db.Query(`
+		SELECT
+			value, script_type, script_size
+		FROM
+			"transaction_output"
+		WHERE
+			transaction_id = ?
+		ORDER BY
+			n ASC
+		`, dbid,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying transaction outputs")
+	}
+	defer outRows.Close()
+
+	for outRows.Next() {
+		var out types.TxOutput
+		if err := outRows.Scan(&out.Value, &out.ScriptType, &out.ScriptSize); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		details.Outputs = append(details.Outputs, out)
+	}
+	if err := outRows.Err(); err != nil {
+		return nil, dbError(err, "error reading transaction outputs")
+	}
+
+	if len(details.Inputs) == 0 && len(details.Outputs) == 0 {
+		return nil, nil
+	}
+
+	return &details, nil
+}
+
+// ReplacedBy returns the txid of the first transaction that spends an output also spent by
+// the transaction with database id `dbid` and was first seen at the same time or later.
+// Returns nil if there is no such transaction. Only finds transactions with stored details.
+func (s *Storage) ReplacedBy(dbid int64) (*types.Hash32, error) {
+	var txid types.Hash32
+	err := s.db.QueryRow(`
+		SELECT
+			t.txid
+		FROM
+			"transaction_input" a
+		JOIN
+			"transaction_input" b ON b.prev_txid = a.prev_txid AND b.prev_index = a.prev_index
+		JOIN
+			"transaction" t ON t.id = b.transaction_id
+		WHERE
+			a.transaction_id = ?1 AND b.transaction_id != ?1 AND
+			t.first_seen >= (SELECT first_seen FROM "transaction" WHERE id = ?1)
+		ORDER BY
+			t.first_seen ASC, t.id ASC
+		LIMIT 1
+		`, dbid,
+	).Scan((*hashColumn)(&txid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err, "error querying conflicting transactions")
+	}
+	return &txid, nil
+}
+
+// HeuristicStats counts the classified transactions first seen in `from <= first_seen <= to` per heuristic flag
+func (s *Storage) HeuristicStats(from, to time.Time) (*types.HeuristicStats, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			heuristics, COUNT(*)
+		FROM
+			"transaction"
+		WHERE
+			heuristics IS NOT NULL AND first_seen >= ? AND first_seen <= ?
+		GROUP BY
+			heuristics
+		`, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying heuristics")
+	}
+	defer rows.Close()
+
+	stats := types.HeuristicStats{Counts: map[string]int{}}
+	for _, name := range types.HeuristicNames {
+		stats.Counts[name] = 0
+	}
+
+	for rows.Next() {
+		var flags types.HeuristicFlags
+		var count int
+		if err := rows.Scan(&flags, &count); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		stats.Classified += count
+		for flag, name := range types.HeuristicNames {
+			if flags.Has(flag) {
+				stats.Counts[name] += count
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error reading heuristics")
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_TransactionDetails(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	details := &types.TxDetails{
+		Inputs: []types.TxInput{
+			{PrevTxID: test.GenerateHash32("prev"), PrevIndex: 1, Sequence: 0xfffffffd},
+		},
+		Outputs: []types.TxOutput{
+			{Value: 300, ScriptType: "witness_v0_keyhash", ScriptSize: 22},
+			{Value: 100000, ScriptType: "scripthash", ScriptSize: 23},
+		},
+	}
+	flags := types.HeuristicDust
+	noFlags := types.HeuristicFlags(0)
+
+	tx := NewTxAtOffset(1)
+	tx.Details = details
+	tx.Heuristics = &flags
+	tx.Signals = &types.TxSignals{Version: 2, LockTime: 600000, RBF: true}
+	_, err = st.InsertTransactions([]types.Transaction{*tx, *NewTxAtOffset(2)})
+	require.NoError(t, err)
+
+	// classification of a known transaction is added once
+	tx3 := NewTxAtOffset(3)
+	_, err = st.InsertTransaction(tx3)
+	require.NoError(t, err)
+	tx3.Heuristics = &noFlags
+	_, err = st.InsertTransaction(tx3)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.Heuristics)
+	assert.Equal(t, flags, *stored.Heuristics)
+	assert.Equal(t, tx.Signals, stored.Signals)
+
+	storedDetails, err := st.TransactionDetails(stored.DBID)
+	require.NoError(t, err)
+	assert.Equal(t, details, storedDetails)
+
+	stored2, err := st.TransactionByID(NewTxAtOffset(2).TxID)
+	require.NoError(t, err)
+	assert.Nil(t, stored2.Heuristics)
+	assert.Nil(t, stored2.Signals)
+	storedDetails, err = st.TransactionDetails(stored2.DBID)
+	require.NoError(t, err)
+	assert.Nil(t, storedDetails)
+
+	stats, err := st.HeuristicStats(GetTime(0), GetTime(1000))
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Classified)
+	assert.Equal(t, 1, stats.Counts["dust"])
+	assert.Equal(t, 0, stats.Counts["batch"])
+}
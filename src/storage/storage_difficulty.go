@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// DifficultyEpochs returns statistics of the latest `limit` difficulty epochs of the best chain,
+// newest first. Only blocks with known header data are considered.
+func (s *Storage) DifficultyEpochs(limit int) ([]types.DifficultyEpoch, error) {
+	// The header timestamps are not strictly monotonic, MIN and MAX approximate the
+	// timestamps of the first and last block of the epoch.
+	// On testnet, blocks with minimum difficulty can occur within an epoch, the
+	// smallest target is the epoch difficulty.
+	rows, err := s.db.Query(`
+		SELECT
+			MIN(height), MAX(height), COUNT(*), MIN(encoded_time), MAX(encoded_time), MIN(bits)
+		FROM
+			"block"
+		WHERE
+			in_best_chain = 1 AND bits IS NOT NULL AND bits > 0 AND encoded_time IS NOT NULL
+		GROUP BY
+			height / ?
+		ORDER BY
+			height / ? DESC
+		LIMIT ?
+		`, types.RetargetInterval, types.RetargetInterval, limit,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying difficulty epochs")
+	}
+	defer rows.Close()
+
+	res := []types.DifficultyEpoch{}
+	for rows.Next() {
+		var startHeight, endHeight, bits uint32
+		var blocks int
+		var startTime, endTime int64
+		if err := rows.Scan(&startHeight, &endHeight, &blocks, &startTime, &endTime, &bits); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res = append(res, types.NewDifficultyEpoch(
+			startHeight, endHeight, blocks, time.Unix(startTime, 0), time.Unix(endTime, 0), bits,
+		))
+	}
+
+	return res, nil
+}
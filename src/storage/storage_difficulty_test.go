@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_DifficultyEpochs(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	blocks := chainedBlocks(2014, "", []string{"a", "b", "c", "d"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+		blocks[i].Bits = 0x1d00ffff
+		blocks[i].EncodedTime = GetTime(i * 300)
+	}
+	blocks[2].Bits = 0x1c7fff80
+	require.NoError(t, insertBlocks(st, blocks))
+
+	stored, err := st.BlockByHash(blocks[1].Hash)
+	require.NoError(t, err)
+	assert.Equal(t, blocks[1].EncodedTime, stored.EncodedTime)
+	assert.Equal(t, 1.0, stored.Difficulty())
+
+	epochs, err := st.DifficultyEpochs(10)
+	require.NoError(t, err)
+	require.Len(t, epochs, 2)
+
+	assert.Equal(t, uint32(1), epochs[0].Epoch)
+	assert.Equal(t, 2, epochs[0].Blocks)
+	assert.InDelta(t, 2.0, epochs[0].Difficulty, 1e-3)
+	assert.Equal(t, 300.0, epochs[0].AvgBlockInterval)
+
+	assert.Equal(t, uint32(0), epochs[1].Epoch)
+	assert.Equal(t, GetTime(0), epochs[1].StartTime)
+	assert.Equal(t, GetTime(300), epochs[1].EndTime)
+	assert.Equal(t, types.NewDifficultyEpoch(2014, 2015, 2, GetTime(0), GetTime(300), 0x1d00ffff), epochs[1])
+	assert.Equal(t, 2.0, epochs[1].AdjustmentEstimate)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertDoubleSpends records the outcomes of the conflicts resolved by the best block `block`:
+// stored transactions that spend an output also spent by a transaction of the block and are not
+// in the block themselves. Only direct conflicts with stored inputs are found, not their descendants.
+// A conflict that is resolved again after a reorg keeps its first outcome.
+func (s *Storage) insertDoubleSpends(block *types.StoredBlock) error {
+	_, err := s.db.Exec(`
+		INSERT OR IGNORE INTO
+			"double_spend"
+			(transaction_id, confirmed_txid, confirmed_first_seen, block_id, time, conflict_time, fee_difference)
+		SELECT DISTINCT
+			l.id, c.txid, c.first_seen, :block_id, :time, MAX(c.first_seen, l.first_seen), c.fee - l.fee
+		FROM
+			"transaction_block" tb
+			JOIN "transaction_input" a ON a.transaction_id = tb.transaction_id
+			JOIN "transaction_input" b ON
+				b.prev_txid = a.prev_txid AND b.prev_index = a.prev_index AND b.transaction_id != a.transaction_id
+			JOIN "transaction" c ON c.id = tb.transaction_id
+			JOIN "transaction" l ON l.id = b.transaction_id
+		WHERE
+			tb.block_id = :block_id AND
+			c.first_seen IS NOT NULL AND l.first_seen IS NOT NULL AND
+			b.transaction_id NOT IN (SELECT transaction_id FROM "transaction_block" WHERE block_id = :block_id)
+		`,
+		sql.Named("block_id", block.DBID),
+		sql.Named("time", block.FirstSeen.Unix()),
+	)
+	return dbError(err, "could not insert into table `double_spend`")
+}
+
+// DoubleSpendQuery selects double-spend outcomes matching all set fields
+type DoubleSpendQuery struct {
+	// Inclusive range of the first-seen time of the block that resolved the conflict
+	From *time.Time
+	To   *time.Time
+	// Maximum number of results, 0 for no limit
+	MaxResults int
+}
+
+// where returns the conditions of `q`
+func (q *DoubleSpendQuery) where() (string, []interface{}) {
+	var c conditions
+	if q.From != nil {
+		c.add("d.time >= ?", q.From.Unix())
+	}
+	if q.To != nil {
+		c.add("d.time <= ?", q.To.Unix())
+	}
+	where, args := c.where()
+	if where != "" {
+		where = " WHERE " + where
+	}
+	return where, args
+}
+
+// DoubleSpends returns the double-spend outcomes matching `q`, most recent first
+func (s *Storage) DoubleSpends(q DoubleSpendQuery) ([]types.DoubleSpend, error) {
+	where, args := q.where()
+	query := `
+		SELECT
+			d.time, b.hash, d.confirmed_txid, t.txid, d.confirmed_first_seen, t.first_seen,
+			d.conflict_time, d.fee_difference
+		FROM
+			"double_spend" d
+			JOIN "transaction" t ON t.id = d.transaction_id
+			JOIN "block" b ON b.id = d.block_id` + where + `
+		ORDER BY d.time DESC, d.rowid DESC`
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying double spends")
+	}
+	defer rows.Close()
+
+	res := []types.DoubleSpend{}
+	for rows.Next() {
+		var t, confirmedFirstSeen, conflictingFirstSeen, conflictTime int64
+		var d types.DoubleSpend
+		err := rows.Scan(
+			&t, (*hashColumn)(&d.Block), (*hashColumn)(&d.Confirmed), (*hashColumn)(&d.Conflicting),
+			&confirmedFirstSeen, &conflictingFirstSeen, &conflictTime, &d.FeeDifference,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		d.Time = time.Unix(t, 0).UTC()
+		d.ConfirmedFirstSeen = time.Unix(confirmedFirstSeen, 0).UTC()
+		d.ConflictingFirstSeen = time.Unix(conflictingFirstSeen, 0).UTC()
+		d.FirstSeenConfirmed = confirmedFirstSeen <= conflictingFirstSeen
+		if t > conflictTime {
+			d.DurationSeconds = t - conflictTime
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// DoubleSpendReport summarizes the double-spend outcomes matching the range of `q` and
+// lists the outcomes of DoubleSpends
+func (s *Storage) DoubleSpendReport(q DoubleSpendQuery) (*types.DoubleSpendReport, error) {
+	where, args := q.where()
+	var report types.DoubleSpendReport
+	var mean sql.NullFloat64
+	err := s.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(d.confirmed_first_seen <= t.first_seen), 0),
+			COALESCE(SUM(d.fee_difference > 0), 0),
+			AVG(MAX(d.time - d.conflict_time, 0))
+		FROM
+			"double_spend" d
+			JOIN "transaction" t ON t.id = d.transaction_id`+where, args...,
+	).Scan(&report.Count, &report.FirstSeenConfirmed, &report.HigherFeeConfirmed, &mean)
+	if err != nil {
+		return nil, dbError(err, "error querying double spends")
+	}
+	report.MeanDurationSeconds = mean.Float64
+
+	report.DoubleSpends, err = s.DoubleSpends(q)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_DoubleSpends(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	spend := func(tx *types.Transaction, prevIndex uint32) types.Transaction {
+		tx.Details = &types.TxDetails{
+			Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32("prev"), PrevIndex: prevIndex}},
+		}
+		return *tx
+	}
+	// tx-10 and tx-40 conflict, tx-20 and tx-30 conflict, tx-50 has no conflict
+	txs := []types.Transaction{
+		spend(NewTxAtOffset(10), 0),
+		spend(NewTxAtOffset(20), 1),
+		spend(NewTxAtOffset(30), 1),
+		spend(NewTxAtOffset(40), 0),
+		spend(NewTxAtOffset(50), 2),
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	block := types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: GetTime(100),
+		Height:    1,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{txs[3].TxID, txs[1].TxID, txs[4].TxID},
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	feeDifference := func(d int64) *int64 { return &d }
+	expected := []types.DoubleSpend{{
+		Time:                 GetTime(100),
+		Block:                block.Hash,
+		Confirmed:            txs[3].TxID,
+		Conflicting:          txs[0].TxID,
+		ConfirmedFirstSeen:   GetTime(40),
+		ConflictingFirstSeen: GetTime(10),
+		FirstSeenConfirmed:   false,
+		DurationSeconds:      60,
+		FeeDifference:        feeDifference(30),
+	}, {
+		Time:                 GetTime(100),
+		Block:                block.Hash,
+		Confirmed:            txs[1].TxID,
+		Conflicting:          txs[2].TxID,
+		ConfirmedFirstSeen:   GetTime(20),
+		ConflictingFirstSeen: GetTime(30),
+		FirstSeenConfirmed:   true,
+		DurationSeconds:      70,
+		FeeDifference:        feeDifference(-10),
+	}}
+	doubleSpends, err := st.DoubleSpends(DoubleSpendQuery{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected, doubleSpends)
+
+	report, err := st.DoubleSpendReport(DoubleSpendQuery{MaxResults: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count)
+	assert.Equal(t, 1, report.FirstSeenConfirmed)
+	assert.Equal(t, 1, report.HigherFeeConfirmed)
+	assert.Equal(t, 65.0, report.MeanDurationSeconds)
+	assert.Len(t, report.DoubleSpends, 1)
+
+	from := GetTime(101)
+	report, err = st.DoubleSpendReport(DoubleSpendQuery{From: &from})
+	require.NoError(t, err)
+	assert.Equal(t, &types.DoubleSpendReport{DoubleSpends: []types.DoubleSpend{}}, report)
+}
+
+func TestStorage_DropCauses(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	spend := func(tx *types.Transaction, prevIndex uint32) types.Transaction {
+		tx.Details = &types.TxDetails{
+			Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32("prev"), PrevIndex: prevIndex}},
+		}
+		return *tx
+	}
+	// tx-10 is replaced by tx-40, tx-30 conflicts with tx-20 that was seen earlier
+	txs := []types.Transaction{
+		spend(NewTxAtOffset(10), 0),
+		spend(NewTxAtOffset(20), 1),
+		spend(NewTxAtOffset(30), 1),
+		spend(NewTxAtOffset(40), 0),
+		spend(NewTxAtOffset(50), 2),
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+	dbids := make([]int64, len(txs))
+	for i, tx := range txs {
+		stored, err := st.TransactionByID(tx.TxID)
+		require.NoError(t, err)
+		dbids[i] = stored.DBID
+	}
+	_, err = st.InsertBlock(&types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: GetTime(100),
+		Height:    1,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{txs[3].TxID, txs[1].TxID},
+	})
+	require.NoError(t, err)
+
+	drops, err := st.DropCauses([]int64{dbids[0], dbids[2], dbids[4]})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]types.TxDrop{
+		dbids[0]: {Time: GetTime(40), Reason: types.DropReplaced},
+		dbids[2]: {Time: GetTime(100), Reason: types.DropConflicted},
+	}, drops)
+
+	drops[dbids[4]] = types.TxDrop{Time: GetTime(110), Reason: types.DropUnknown}
+	require.NoError(t, st.MarkDrops(drops))
+	for i, reason := range map[int]types.DropReason{
+		0: types.DropReplaced, 2: types.DropConflicted, 4: types.DropUnknown,
+	} {
+		actual, err := st.DropReason(dbids[i])
+		require.NoError(t, err)
+		assert.Equal(t, reason, actual, "tx %d", i)
+	}
+	tx, err := st.TransactionByID(txs[2].TxID)
+	require.NoError(t, err)
+	assert.Equal(t, GetTime(100), *tx.Expired)
+
+	// the reason is replaced with a later drop
+	require.NoError(t, st.MarkExpired([]int64{dbids[0], dbids[4]}, time.Hour))
+	for _, i := range []int{0, 4} {
+		actual, err := st.DropReason(dbids[i])
+		require.NoError(t, err)
+		assert.Equal(t, types.DropExpiry, actual)
+	}
+	require.NoError(t, st.MarkDrops(map[int64]types.TxDrop{dbids[4]: {Time: GetTime(120)}}))
+	actual, err := st.DropReason(dbids[4])
+	require.NoError(t, err)
+	assert.Equal(t, types.DropUnknown, actual)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertBlockDrain records the drain of all blocks with a stored parent.
+// Used by the migration, insertDrain restricts it to a single block.
+const insertBlockDrain = `
+	INSERT OR REPLACE INTO "block_drain"
+	SELECT
+		b.id,
+		(SELECT COUNT(*) FROM "transaction_block" tb WHERE tb.block_id = b.id),
+		(SELECT COALESCE(SUM((t.weight + 3) / 4), 0) FROM "transaction_block" tb
+			JOIN "transaction" t ON t.id = tb.transaction_id WHERE tb.block_id = b.id),
+		(SELECT COALESCE(SUM(t.fee), 0) FROM "transaction_block" tb
+			JOIN "transaction" t ON t.id = tb.transaction_id WHERE tb.block_id = b.id),
+		(SELECT COUNT(*) FROM "transaction" t
+			WHERE t.first_seen > p.first_seen AND t.first_seen <= b.first_seen),
+		(SELECT COALESCE(SUM((t.weight + 3) / 4), 0) FROM "transaction" t
+			WHERE t.first_seen > p.first_seen AND t.first_seen <= b.first_seen),
+		(SELECT COALESCE(SUM(t.fee), 0) FROM "transaction" t
+			WHERE t.first_seen > p.first_seen AND t.first_seen <= b.first_seen)
+	FROM
+		"block" b
+		JOIN "block" p ON p.hash = b.parent
+`
+
+// insertDrain records the transactions removed from the tracked mempool by the block `blockID`
+// and those first seen since its parent. Does nothing if the parent is not stored.
+func (s *Storage) insertDrain(blockID int64) error {
+	if _, err := s.db.Exec(insertBlockDrain+` WHERE b.id = ?`, blockID); err != nil {
+		return dbError(err, `error inserting to table "block_drain"`)
+	}
+	return nil
+}
+
+// BlockDrains returns the drain of the best-chain blocks first seen in `from <= first_seen < to`,
+// oldest first. Blocks whose parent is not stored are not included.
+func (s *Storage) BlockDrains(from, to time.Time) ([]types.BlockDrain, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			b.hash, b.height, b.first_seen,
+			d.removed_transactions, d.removed_vsize, d.removed_fees,
+			d.entered_transactions, d.entered_vsize, d.entered_fees
+		FROM
+			"block_drain" d
+			JOIN "block" b ON b.id = d.block_id
+		WHERE
+			b.in_best_chain = 1 AND b.first_seen >= ? AND b.first_seen < ?
+		ORDER BY
+			b.first_seen ASC, b.id ASC
+		`, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying block drain")
+	}
+	defer rows.Close()
+
+	res := []types.BlockDrain{}
+	for rows.Next() {
+		var firstSeen int64
+		var d types.BlockDrain
+		err := rows.Scan(
+			(*hashColumn)(&d.Hash), &d.Height, &firstSeen,
+			&d.Removed.Transactions, &d.Removed.VSize, &d.Removed.Fees,
+			&d.Entered.Transactions, &d.Entered.VSize, &d.Entered.Fees,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		d.FirstSeen = time.Unix(firstSeen, 0).UTC()
+		d.NetVSize = d.Removed.VSize - d.Entered.VSize
+		d.NetFees = int64(d.Removed.Fees) - int64(d.Entered.Fees)
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
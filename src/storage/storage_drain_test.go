@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_BlockDrains(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	// blocks at 0 and 100
+	txs := []types.Transaction{
+		{TxID: test.GenerateHash32("a"), FirstSeen: GetTime(-10), Fee: 1000, Weight: 400},
+		{TxID: test.GenerateHash32("b"), FirstSeen: GetTime(50), Fee: 2000, Weight: 800},
+		{TxID: test.GenerateHash32("c"), FirstSeen: GetTime(60), Fee: 500, Weight: 401},
+		{TxID: test.GenerateHash32("d"), FirstSeen: GetTime(150), Fee: 500, Weight: 400},
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	blocks := chainedBlocks(0, "", []string{"x", "y"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[1].TxIDs = []types.Hash32{txs[0].TxID, txs[1].TxID}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	drains, err := st.BlockDrains(GetTime(0), GetTime(1000))
+	require.NoError(t, err)
+	// the first block has no stored parent
+	require.Len(t, drains, 1)
+	assert.Equal(t, types.BlockDrain{
+		Height:    1,
+		Hash:      blocks[1].Hash,
+		FirstSeen: GetTime(100),
+		Removed:   types.DrainTotals{Transactions: 2, VSize: 300, Fees: 3000},
+		Entered:   types.DrainTotals{Transactions: 2, VSize: 301, Fees: 2500},
+		NetVSize:  -1,
+		NetFees:   500,
+	}, drains[0])
+}
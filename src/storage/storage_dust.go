@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertTransactionDust stores the dust outputs of `txs`.
+// Dust outputs of transactions that are already stored are not changed.
+func (s *Storage) insertTransactionDust(txs []types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	txids := make([]types.Hash32, len(txs))
+	for i, tx := range txs {
+		txids[i] = tx.TxID
+	}
+	dbids, err := s.transactionDBIDs(txids)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	for i, tx := range txs {
+		dbid := (*dbids)[i]
+		if dbid < 0 {
+			continue
+		}
+		for _, d := range tx.Dust {
+			values = append(values, "(?, ?, ?, ?)")
+			args = append(args, dbid, d.ScriptType, d.Outputs, d.Value)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`
+		INSERT OR IGNORE INTO
+			"transaction_dust"
+			(transaction_id, script_type, outputs, value)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return dbError(err, "could not insert into table `transaction_dust`")
+	}
+	return nil
+}
+
+// DustReport aggregates the dust outputs created by transactions first seen in `from <= first_seen < to`
+// per script type in buckets of length `interval`, ordered by time and script type.
+// Only transactions received while dust was recorded are counted.
+func (s *Storage) DustReport(from, to time.Time, interval time.Duration) ([]types.DustBucket, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			(t.first_seen - ?1) / ?2 AS bucket,
+			d.script_type,
+			COUNT(*),
+			SUM(d.outputs),
+			SUM(d.value)
+		FROM
+			"transaction_dust" d
+			JOIN "transaction" t ON t.id = d.transaction_id
+		WHERE
+			t.first_seen >= ?1 AND t.first_seen < ?3
+		GROUP BY
+			bucket, d.script_type
+		ORDER BY
+			bucket ASC, d.script_type ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying dust report")
+	}
+	defer rows.Close()
+
+	res := []types.DustBucket{}
+	for rows.Next() {
+		var bucket int64
+		var b types.DustBucket
+		if err := rows.Scan(&bucket, &b.ScriptType, &b.Transactions, &b.Outputs, &b.Value); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		b.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		res = append(res, b)
+	}
+
+	return res, rows.Err()
+}
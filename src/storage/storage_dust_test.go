@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_DustReport(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{}
+	for i, dust := range [][]types.DustOutputs{
+		{{ScriptType: "pubkeyhash", Outputs: 2, Value: 600}, {ScriptType: "scripthash", Outputs: 1, Value: 1}},
+		{{ScriptType: "pubkeyhash", Outputs: 1, Value: 100}},
+		nil,
+		{{ScriptType: "pubkeyhash", Outputs: 3, Value: 30}},
+	} {
+		tx := NewTxAtOffset(10 + i*40)
+		tx.Dust = dust
+		txs = append(txs, *tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	// dust of stored transactions is not changed
+	again := txs[1]
+	again.Dust = []types.DustOutputs{{ScriptType: "pubkeyhash", Outputs: 5, Value: 5}}
+	_, err = st.InsertTransactions([]types.Transaction{again})
+	require.NoError(t, err)
+
+	report, err := st.DustReport(GetTime(0), GetTime(1000), 100*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []types.DustBucket{
+		{Time: GetTime(0), ScriptType: "pubkeyhash", Transactions: 2, Outputs: 3, Value: 700},
+		{Time: GetTime(0), ScriptType: "scripthash", Transactions: 1, Outputs: 1, Value: 1},
+		{Time: GetTime(100), ScriptType: "pubkeyhash", Transactions: 1, Outputs: 3, Value: 30},
+	}, report)
+
+	report, err = st.DustReport(GetTime(100), GetTime(1000), 100*time.Second)
+	require.NoError(t, err)
+	assert.Len(t, report, 1)
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// InsertExternalFirstSeen stores first-seen times of external datasets.
+// If a txid already exists for the source, the earlier time is kept.
+// Returns the number of inserted or updated rows.
+func (s *Storage) InsertExternalFirstSeen(records []types.ExternalFirstSeen) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	values := make([]string, len(records))
+	args := make([]interface{}, len(records))
+	for i, r := range records {
+		values[i] = fmt.Sprintf("(x'%x', ?, %d)", r.TxID[:], r.FirstSeen.UTC().Unix())
+		args[i] = r.Source
+	}
+
+	res, err := s.db.Exec(fmt.Sprintf(`
+		INSERT INTO
+			"external_first_seen" (txid, source, first_seen)
+		VALUES
+			%s
+		ON CONFLICT(txid, source) DO
+			UPDATE SET first_seen = excluded.first_seen
+			WHERE first_seen > excluded.first_seen
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return 0, dbError(err, "could not insert into table `external_first_seen`")
+	}
+	return res.RowsAffected()
+}
+
+// ExternalFirstSeen returns the first-seen times reported by `source` for the subset of `txids` it contains
+func (s *Storage) ExternalFirstSeen(source string, txids []types.Hash32) (map[types.Hash32]time.Time, error) {
+	res := map[types.Hash32]time.Time{}
+	if len(txids) == 0 {
+		return res, nil
+	}
+
+	values := make([]string, len(txids))
+	for i, txid := range txids {
+		values[i] = fmt.Sprintf("x'%x'", txid[:])
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(
+		`SELECT txid, first_seen FROM "external_first_seen" WHERE source = ? AND txid IN (%s)`,
+		strings.Join(values, ","),
+	), source)
+	if err != nil {
+		return nil, dbError(err, "error querying table `external_first_seen`")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txid types.Hash32
+		var firstSeen int64
+		if err := rows.Scan((*hashColumn)(&txid), &firstSeen); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res[txid] = time.Unix(firstSeen, 0).UTC()
+	}
+	return res, rows.Err()
+}
+
+// ExternalSources returns the number of stored first-seen times per source label
+func (s *Storage) ExternalSources() (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT source, COUNT(*) FROM "external_first_seen" GROUP BY source`)
+	if err != nil {
+		return nil, dbError(err, "error querying table `external_first_seen`")
+	}
+	defer rows.Close()
+
+	res := map[string]int64{}
+	for rows.Next() {
+		var source string
+		var count int64
+		if err := rows.Scan(&source, &count); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res[source] = count
+	}
+	return res, rows.Err()
+}
+
+// firstSeenEstimateBatchSize is the number of estimates inserted per statement
+const firstSeenEstimateBatchSize = 1000
+
+// FirstSeenObservations returns the first-seen times of the transactions first seen by the collector
+// in [from, to), by database id. The own first-seen time has the source types.LocalSource.
+func (s *Storage) FirstSeenObservations(from, to time.Time) (map[int64][]types.ExternalFirstSeen, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			t.id, t.txid, t.first_seen, e.source, e.first_seen
+		FROM
+			"transaction" t
+			LEFT JOIN "external_first_seen" e ON e.txid = t.txid
+		WHERE
+			t.first_seen >= ? AND t.first_seen < ?
+		`, from.UTC().Unix(), to.UTC().Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying first-seen times")
+	}
+	defer rows.Close()
+
+	res := map[int64][]types.ExternalFirstSeen{}
+	for rows.Next() {
+		var dbid, firstSeen int64
+		var hash types.Hash32
+		var source *string
+		var externalFirstSeen *int64
+		if err := rows.Scan(&dbid, (*hashColumn)(&hash), &firstSeen, &source, &externalFirstSeen); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		if _, ok := res[dbid]; !ok {
+			res[dbid] = []types.ExternalFirstSeen{
+				{TxID: hash, FirstSeen: time.Unix(firstSeen, 0).UTC(), Source: types.LocalSource},
+			}
+		}
+		if source != nil && externalFirstSeen != nil {
+			res[dbid] = append(res[dbid], types.ExternalFirstSeen{
+				TxID: hash, FirstSeen: time.Unix(*externalFirstSeen, 0).UTC(), Source: *source,
+			})
+		}
+	}
+	return res, rows.Err()
+}
+
+// InsertFirstSeenEstimates stores the intervals of the network first-seen times of the
+// transactions with the database ids of `estimates`, replacing earlier estimates.
+// Returns the number of stored estimates.
+func (s *Storage) InsertFirstSeenEstimates(estimates map[int64]types.FirstSeenEstimate) (int64, error) {
+	values := make([]string, 0, len(estimates))
+	for dbid, e := range estimates {
+		values = append(values, fmt.Sprintf(
+			"(%d, %d, %d, %d)", dbid, e.Lower.UTC().Unix(), e.Upper.UTC().Unix(), e.Sources,
+		))
+	}
+
+	var n int64
+	for len(values) > 0 {
+		batch := values
+		if len(batch) > firstSeenEstimateBatchSize {
+			batch = batch[:firstSeenEstimateBatchSize]
+		}
+		values = values[len(batch):]
+
+		res, err := s.db.Exec(fmt.Sprintf(`
+			INSERT OR REPLACE INTO
+				"first_seen_estimate" (transaction_id, lower, upper, sources)
+			VALUES
+				%s
+			`, strings.Join(batch, ","),
+		))
+		if err != nil {
+			return n, dbError(err, "could not insert into table `first_seen_estimate`")
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return n, err
+		}
+		n += affected
+	}
+	return n, nil
+}
+
+// FirstSeenEstimate returns the interval of the network first-seen time of the transaction
+// with database id `dbid`. Returns nil if it was not estimated.
+func (s *Storage) FirstSeenEstimate(dbid int64) (*types.FirstSeenEstimate, error) {
+	var lower, upper int64
+	var sources int
+	err := s.db.QueryRow(
+		`SELECT lower, upper, sources FROM "first_seen_estimate" WHERE transaction_id = ?`, dbid,
+	).Scan(&lower, &upper, &sources)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err, "error querying the first-seen estimate")
+	}
+	return &types.FirstSeenEstimate{
+		Lower:   time.Unix(lower, 0).UTC(),
+		Upper:   time.Unix(upper, 0).UTC(),
+		Sources: sources,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_ExternalFirstSeen(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	a, b := test.GenerateHash32("a"), test.GenerateHash32("b")
+	n, err := st.InsertExternalFirstSeen([]types.ExternalFirstSeen{
+		{TxID: a, FirstSeen: GetTime(10), Source: "x"},
+		{TxID: b, FirstSeen: GetTime(20), Source: "x"},
+		{TxID: a, FirstSeen: GetTime(30), Source: "y"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	// the earlier time is kept per source
+	n, err = st.InsertExternalFirstSeen([]types.ExternalFirstSeen{
+		{TxID: a, FirstSeen: GetTime(5), Source: "x"},
+		{TxID: b, FirstSeen: GetTime(25), Source: "x"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	firstSeen, err := st.ExternalFirstSeen("x", []types.Hash32{a, b, test.GenerateHash32("c")})
+	require.NoError(t, err)
+	assert.Equal(t, map[types.Hash32]time.Time{a: GetTime(5), b: GetTime(20)}, firstSeen)
+
+	sources, err := st.ExternalSources()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"x": 2, "y": 1}, sources)
+}
+
+func TestStorage_FirstSeenEstimate(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	a, b, c := NewTxAtOffset(10), NewTxAtOffset(20), NewTxAtOffset(30)
+	_, err = st.InsertTransactions([]types.Transaction{*a, *b, *c})
+	require.NoError(t, err)
+	_, err = st.InsertExternalFirstSeen([]types.ExternalFirstSeen{
+		{TxID: a.TxID, FirstSeen: GetTime(8), Source: "x"},
+		{TxID: a.TxID, FirstSeen: GetTime(12), Source: "y"},
+		{TxID: b.TxID, FirstSeen: GetTime(21), Source: "x"},
+	})
+	require.NoError(t, err)
+
+	// c is outside of the range
+	observations, err := st.FirstSeenObservations(GetTime(5), GetTime(30))
+	require.NoError(t, err)
+	require.Len(t, observations, 2)
+
+	storedA, err := st.TransactionByID(a.TxID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []types.ExternalFirstSeen{
+		{TxID: a.TxID, FirstSeen: GetTime(10), Source: types.LocalSource},
+		{TxID: a.TxID, FirstSeen: GetTime(8), Source: "x"},
+		{TxID: a.TxID, FirstSeen: GetTime(12), Source: "y"},
+	}, observations[storedA.DBID])
+
+	estimate, err := st.FirstSeenEstimate(storedA.DBID)
+	require.NoError(t, err)
+	assert.Nil(t, estimate)
+
+	want := types.FirstSeenEstimate{Lower: GetTime(6), Upper: GetTime(8), Sources: 3}
+	n, err := st.InsertFirstSeenEstimates(map[int64]types.FirstSeenEstimate{storedA.DBID: want})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// earlier estimates are replaced
+	want.Lower = GetTime(7)
+	_, err = st.InsertFirstSeenEstimates(map[int64]types.FirstSeenEstimate{storedA.DBID: want})
+	require.NoError(t, err)
+
+	estimate, err = st.FirstSeenEstimate(storedA.DBID)
+	require.NoError(t, err)
+	require.NotNil(t, estimate)
+	assert.Equal(t, want, *estimate)
+}
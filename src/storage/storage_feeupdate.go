@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertFeeUpdates records the transactions of `txs` whose fee differs from the `stored` one
+func (s *Storage) insertFeeUpdates(txs []types.Transaction, stored map[types.Hash32]storedVersion) error {
+	values := []string{}
+	args := []interface{}{}
+	for _, tx := range txs {
+		old, ok := stored[tx.TxID]
+		if !ok || old.fee == nil || tx.Fee == *old.fee {
+			continue
+		}
+		values = append(values, "(?, ?, ?, ?)")
+		args = append(args, old.dbid, tx.FirstSeen.Unix(), *old.fee, tx.Fee)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(`
+		INSERT INTO
+			"transaction_fee_update"
+			(transaction_id, time, old_fee, new_fee)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return dbError(err, "could not insert into table `transaction_fee_update`")
+	}
+	return nil
+}
+
+// FeeUpdateQuery selects fee updates matching all set fields
+type FeeUpdateQuery struct {
+	// Updates of the transaction with the txid
+	TxID *types.Hash32
+	// Inclusive range of the first-seen time of the report with the new fee
+	From *time.Time
+	To   *time.Time
+	// Maximum number of results, 0 for no limit
+	MaxResults int
+}
+
+// FeeUpdates returns the fee updates matching `q`, most recent first
+func (s *Storage) FeeUpdates(q FeeUpdateQuery) ([]types.FeeUpdate, error) {
+	var c conditions
+	if q.TxID != nil {
+		c.add("t.txid = ?", q.TxID[:])
+	}
+	if q.From != nil {
+		c.add("u.time >= ?", q.From.Unix())
+	}
+	if q.To != nil {
+		c.add("u.time <= ?", q.To.Unix())
+	}
+
+	query := `
+		SELECT
+			t.txid, u.time, u.old_fee, u.new_fee
+		FROM
+			"transaction_fee_update" u
+			JOIN "transaction" t ON t.id = u.transaction_id`
+	where, args := c.where()
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY u.time DESC, u.rowid DESC"
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying fee updates")
+	}
+	defer rows.Close()
+
+	res := []types.FeeUpdate{}
+	for rows.Next() {
+		var t int64
+		var u types.FeeUpdate
+		if err := rows.Scan((*hashColumn)(&u.TxID), &t, &u.OldFee, &u.NewFee); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		u.Time = time.Unix(t, 0).UTC()
+		res = append(res, u)
+	}
+	return res, rows.Err()
+}
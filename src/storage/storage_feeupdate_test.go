@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_FeeUpdates(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx := NewTxAtOffset(0)
+	_, err = st.InsertTransaction(tx)
+	require.NoError(t, err)
+
+	// the same fee again
+	again := *tx
+	again.FirstSeen = GetTime(10)
+	_, err = st.InsertTransaction(&again)
+	require.NoError(t, err)
+	updates, err := st.FeeUpdates(FeeUpdateQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+
+	updated := *tx
+	updated.FirstSeen = GetTime(20)
+	updated.Fee = tx.Fee + 100
+	_, err = st.InsertTransaction(&updated)
+	require.NoError(t, err)
+	other := NewTxAtOffset(1)
+	_, err = st.InsertTransaction(other)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Fee, stored.Fee)
+
+	updates, err = st.FeeUpdates(FeeUpdateQuery{TxID: &tx.TxID})
+	require.NoError(t, err)
+	assert.Equal(t, []types.FeeUpdate{{
+		TxID:   tx.TxID,
+		Time:   GetTime(20).UTC(),
+		OldFee: tx.Fee,
+		NewFee: updated.Fee,
+	}}, updates)
+
+	from := GetTime(30)
+	updates, err = st.FeeUpdates(FeeUpdateQuery{From: &from})
+	require.NoError(t, err)
+	assert.Empty(t, updates)
+}
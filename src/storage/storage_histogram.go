@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// InsertImportedHistograms stores imported feerate histograms. Buckets without transactions are
+// omitted, a histogram that is already stored for the source and time is replaced.
+func (s *Storage) InsertImportedHistograms(histograms []types.ImportedHistogram) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	for _, h := range histograms {
+		if len(h.Counts) != len(h.Feerates) || len(h.VSizes) != len(h.Feerates) || len(h.Fees) != len(h.Feerates) {
+			return errors.Errorf("histogram of %s at %s has buckets of different lengths", h.Source, h.Time)
+		}
+		_, err := dbTx.Exec(
+			`DELETE FROM "imported_histogram" WHERE source = ? AND time = ?`, h.Source, h.Time.Unix(),
+		)
+		if err != nil {
+			return dbError(err, "could not delete from table `imported_histogram`")
+		}
+
+		values := []string{}
+		args := []interface{}{}
+		for i := range h.Feerates {
+			if h.Counts[i] == 0 {
+				continue
+			}
+			values = append(values, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, h.Source, h.Time.Unix(), h.Feerates[i], h.Counts[i], h.VSizes[i], h.Fees[i])
+		}
+		if len(values) == 0 {
+			continue
+		}
+		_, err = dbTx.Exec(fmt.Sprintf(`
+			INSERT INTO
+				"imported_histogram" (source, time, feerate, transactions, vsize, fees)
+			VALUES
+				%s
+			`, strings.Join(values, ","),
+		), args...)
+		if err != nil {
+			return dbError(err, "could not insert into table `imported_histogram`")
+		}
+	}
+	return dbTx.Commit()
+}
+
+// ImportedHistograms returns the imported feerate histograms of `source` in [`from`, `to`] ordered by time.
+// Histograms of an empty mempool are not stored and therefore not returned.
+func (s *Storage) ImportedHistograms(source string, from, to time.Time) ([]types.ImportedHistogram, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			time, feerate, transactions, vsize, fees
+		FROM
+			"imported_histogram"
+		WHERE
+			source = ? AND time >= ? AND time <= ?
+		ORDER BY
+			time, feerate
+		`, source, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying table `imported_histogram`")
+	}
+	defer rows.Close()
+
+	res := []types.ImportedHistogram{}
+	for rows.Next() {
+		var t, count, vsize, fees int64
+		var feerate float64
+		if err := rows.Scan(&t, &feerate, &count, &vsize, &fees); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		if len(res) == 0 || res[len(res)-1].Time.Unix() != t {
+			res = append(res, types.ImportedHistogram{Source: source, Time: time.Unix(t, 0).UTC()})
+		}
+		h := &res[len(res)-1]
+		h.Feerates = append(h.Feerates, feerate)
+		h.Counts = append(h.Counts, count)
+		h.VSizes = append(h.VSizes, vsize)
+		h.Fees = append(h.Fees, fees)
+	}
+	return res, rows.Err()
+}
+
+// ImportedHistogramSources returns the labels of the imported histograms
+func (s *Storage) ImportedHistogramSources() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT source FROM "imported_histogram" ORDER BY source`)
+	if err != nil {
+		return nil, dbError(err, "error querying table `imported_histogram`")
+	}
+	defer rows.Close()
+
+	res := []string{}
+	for rows.Next() {
+		var source string
+		if err := rows.Scan(&source); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res = append(res, source)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_ImportedHistograms(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	histogram := func(t time.Time, count int64) types.ImportedHistogram {
+		return types.ImportedHistogram{
+			Source:   "johoe",
+			Time:     t,
+			Feerates: []float64{0, 1, 2},
+			Counts:   []int64{0, count, 1},
+			VSizes:   []int64{0, 200 * count, 300},
+			Fees:     []int64{0, 300 * count, 700},
+		}
+	}
+	require.NoError(t, st.InsertImportedHistograms([]types.ImportedHistogram{
+		histogram(GetTime(0), 1), histogram(GetTime(60), 2), histogram(GetTime(120), 3),
+	}))
+	// replaced
+	require.NoError(t, st.InsertImportedHistograms([]types.ImportedHistogram{histogram(GetTime(60), 4)}))
+
+	assert.Error(t, st.InsertImportedHistograms([]types.ImportedHistogram{{Feerates: []float64{1}}}))
+
+	res, err := st.ImportedHistograms("johoe", GetTime(30), GetTime(120))
+	require.NoError(t, err)
+	assert.Equal(t, []types.ImportedHistogram{
+		{Source: "johoe", Time: GetTime(60).UTC(), Feerates: []float64{1, 2}, Counts: []int64{4, 1},
+			VSizes: []int64{800, 300}, Fees: []int64{1200, 700}},
+		{Source: "johoe", Time: GetTime(120).UTC(), Feerates: []float64{1, 2}, Counts: []int64{3, 1},
+			VSizes: []int64{600, 300}, Fees: []int64{900, 700}},
+	}, res)
+
+	res, err = st.ImportedHistograms("other", GetTime(0), GetTime(120))
+	require.NoError(t, err)
+	assert.Empty(t, res)
+
+	sources, err := st.ImportedHistogramSources()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"johoe"}, sources)
+}
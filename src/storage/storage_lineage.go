@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// maxLineageLength limits the number of versions walked by RBFLineage
+const maxLineageLength = 1000
+
+// lineageTx is a transaction of a chain of replacements
+type lineageTx struct {
+	dbid      int64
+	txid      types.Hash32
+	firstSeen int64
+	fee       uint64
+	weight    int
+}
+
+// adjacentConflict returns the stored transaction that spends an output also spent by `tx` and
+// was first seen directly after it, or directly before it if `before` is set. Transactions with
+// the same first-seen time are ordered by database id. Returns nil if there is no such transaction.
+func (s *Storage) adjacentConflict(tx *lineageTx, before bool) (*lineageTx, error) {
+	order := "(t.first_seen > :first_seen OR (t.first_seen = :first_seen AND t.id > :id)) ORDER BY t.first_seen ASC, t.id ASC"
+	if before {
+		order = "(t.first_seen < :first_seen OR (t.first_seen = :first_seen AND t.id < :id)) ORDER BY t.first_seen DESC, t.id DESC"
+	}
+	var res lineageTx
+	err := s.db.QueryRow(`
+		SELECT
+			t.id, t.txid, t.first_seen, COALESCE(t.fee, 0), COALESCE(t.weight, 0)
+		FROM
+			"transaction_input" a
+		JOIN
+			"transaction_input" b ON b.prev_txid = a.prev_txid AND b.prev_index = a.prev_index
+		JOIN
+			"transaction" t ON t.id = b.transaction_id
+		WHERE
+			a.transaction_id = :id AND b.transaction_id != :id AND t.first_seen IS NOT NULL AND `+order+`
+		LIMIT 1
+		`,
+		sql.Named("id", tx.dbid),
+		sql.Named("first_seen", tx.firstSeen),
+	).Scan(&res.dbid, (*hashColumn)(&res.txid), &res.firstSeen, &res.fee, &res.weight)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err, "error querying conflicting transactions")
+	}
+	return &res, nil
+}
+
+// RBFLineage returns the chain of replacements of the stored transaction `tx`: the conflicting
+// transactions first seen before it, back to the original, and the ones that replaced it, see ReplacedBy.
+// Only finds transactions with stored details. A transaction without replacements is a lineage of one.
+func (s *Storage) RBFLineage(tx *types.StoredTransaction) (*types.RBFLineage, error) {
+	start := lineageTx{
+		dbid:      tx.DBID,
+		txid:      tx.TxID,
+		firstSeen: tx.FirstSeen.Unix(),
+		fee:       tx.Fee,
+		weight:    tx.Weight,
+	}
+
+	var earlier []lineageTx
+	for cur := &start; len(earlier) < maxLineageLength; {
+		prev, err := s.adjacentConflict(cur, true)
+		if err != nil {
+			return nil, err
+		}
+		if prev == nil {
+			break
+		}
+		earlier = append(earlier, *prev)
+		cur = prev
+	}
+
+	chain := make([]lineageTx, 0, len(earlier)+1)
+	for i := len(earlier) - 1; i >= 0; i-- {
+		chain = append(chain, earlier[i])
+	}
+	chain = append(chain, start)
+	for cur := &start; len(chain) < 2*maxLineageLength; {
+		next, err := s.adjacentConflict(cur, false)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			break
+		}
+		chain = append(chain, *next)
+		cur = next
+	}
+
+	lineage := types.RBFLineage{Transactions: make([]types.FeeBump, len(chain))}
+	for i, c := range chain {
+		version := types.Transaction{Fee: c.fee, Weight: c.weight}
+		bump := types.FeeBump{
+			TxID:                  c.txid,
+			FirstSeen:             time.Unix(c.firstSeen, 0).UTC(),
+			Fee:                   c.fee,
+			Feerate:               version.Feerate(),
+			CumulativeFeeIncrease: int64(c.fee) - int64(chain[0].fee),
+		}
+		if i > 0 {
+			bump.FeeIncrease = int64(c.fee) - int64(chain[i-1].fee)
+			bump.SecondsSincePrevious = c.firstSeen - chain[i-1].firstSeen
+		}
+		lineage.Transactions[i] = bump
+	}
+	last := chain[len(chain)-1]
+	lineage.Bumps = len(chain) - 1
+	lineage.TotalFeeIncrease = int64(last.fee) - int64(chain[0].fee)
+	lineage.DurationSeconds = last.firstSeen - chain[0].firstSeen
+	return &lineage, nil
+}
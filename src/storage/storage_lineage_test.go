@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_RBFLineage(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	spend := func(tx *types.Transaction, prevIndex uint32) types.Transaction {
+		tx.Details = &types.TxDetails{
+			Inputs: []types.TxInput{{PrevTxID: test.GenerateHash32("prev"), PrevIndex: prevIndex}},
+		}
+		return *tx
+	}
+	// tx-10 is replaced by tx-30, which is replaced by tx-60; tx-20 spends another output
+	_, err = st.InsertTransactions([]types.Transaction{
+		spend(NewTxAtOffset(10), 0),
+		spend(NewTxAtOffset(20), 1),
+		spend(NewTxAtOffset(30), 0),
+		spend(NewTxAtOffset(60), 0),
+	})
+	require.NoError(t, err)
+
+	expected := &types.RBFLineage{
+		Transactions: []types.FeeBump{{
+			TxID:      test.GenerateHash32("tx-10"),
+			FirstSeen: GetTime(10),
+			Fee:       110,
+			Feerate:   110 / 27.5,
+		}, {
+			TxID:                  test.GenerateHash32("tx-30"),
+			FirstSeen:             GetTime(30),
+			Fee:                   130,
+			Feerate:               130 / 32.5,
+			FeeIncrease:           20,
+			CumulativeFeeIncrease: 20,
+			SecondsSincePrevious:  20,
+		}, {
+			TxID:                  test.GenerateHash32("tx-60"),
+			FirstSeen:             GetTime(60),
+			Fee:                   160,
+			Feerate:               160 / 40.0,
+			FeeIncrease:           30,
+			CumulativeFeeIncrease: 50,
+			SecondsSincePrevious:  30,
+		}},
+		Bumps:            2,
+		TotalFeeIncrease: 50,
+		DurationSeconds:  50,
+	}
+	for _, id := range []string{"tx-10", "tx-30", "tx-60"} {
+		tx, err := st.TransactionByID(test.GenerateHash32(id))
+		require.NoError(t, err)
+		lineage, err := st.RBFLineage(tx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, lineage, id)
+	}
+
+	tx, err := st.TransactionByID(test.GenerateHash32("tx-20"))
+	require.NoError(t, err)
+	lineage, err := st.RBFLineage(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, lineage.Bumps)
+	assert.Len(t, lineage.Transactions, 1)
+}
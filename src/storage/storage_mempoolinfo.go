@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// InsertMempoolInfo stores a snapshot of the node mempool state
+func (s *Storage) InsertMempoolInfo(info *types.MempoolInfo) error {
+	_, err := s.db.Exec(`
+		INSERT INTO
+			"mempool_info"
+			(time, size, bytes, usage, max_mempool, mempool_min_fee, min_relay_tx_fee)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?)
+		`,
+		info.Time.UTC().Unix(),
+		info.Size,
+		info.Bytes,
+		info.Usage,
+		info.MaxMempool,
+		info.MempoolMinFee,
+		info.MinRelayTxFee,
+	)
+	if err != nil {
+		return dbError(err, "could not insert into table `mempool_info`")
+	}
+	return nil
+}
+
+// MempoolInfoRange returns the mempool info snapshots with `from <= time <= to`, ordered by time
+func (s *Storage) MempoolInfoRange(from, to time.Time) (res []types.MempoolInfo, err error) {
+	rows, err := s.db.Query(`
+		SELECT
+			time, size, bytes, usage, max_mempool, mempool_min_fee, min_relay_tx_fee
+		FROM
+			"mempool_info"
+		WHERE
+			time >= ? AND time <= ?
+		ORDER BY
+			time ASC
+		`, from.Unix(), to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying mempool info")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t int64
+		var info types.MempoolInfo
+		err := rows.Scan(
+			&t,
+			&info.Size,
+			&info.Bytes,
+			&info.Usage,
+			&info.MaxMempool,
+			&info.MempoolMinFee,
+			&info.MinRelayTxFee,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		info.Time = time.Unix(t, 0).UTC()
+		res = append(res, info)
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_MempoolInfo(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	for i := 0; i < 5; i++ {
+		err := st.InsertMempoolInfo(&types.MempoolInfo{
+			Time:          GetTime(i * 60),
+			Size:          int64(100 * i),
+			MempoolMinFee: int64(1000 + i),
+			MinRelayTxFee: 1000,
+		})
+		require.NoError(t, err)
+	}
+
+	infos, err := st.MempoolInfoRange(GetTime(60), GetTime(180))
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	assert.Equal(t, GetTime(60), infos[0].Time)
+	assert.Equal(t, int64(1001), infos[0].MempoolMinFee)
+	assert.Equal(t, int64(300), infos[2].Size)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// InsertNodeConfig stores a snapshot of the node settings
+func (s *Storage) InsertNodeConfig(c *types.NodeConfig) error {
+	_, err := s.db.Exec(`
+		INSERT INTO
+			"node_config"
+			(time, version, subversion, protocol_version, chain,
+			 max_mempool, min_relay_tx_fee, incremental_fee, local_relay)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+		c.Time.UTC().Unix(),
+		c.Version,
+		c.SubVersion,
+		c.ProtocolVersion,
+		c.Chain,
+		c.MaxMempool,
+		c.MinRelayTxFee,
+		c.IncrementalFee,
+		c.LocalRelay,
+	)
+	if err != nil {
+		return dbError(err, "could not insert into table `node_config`")
+	}
+	return nil
+}
+
+// queryNodeConfigs returns up to `limit` node configs in the order `order`, all if `limit` is negative
+func (s *Storage) queryNodeConfigs(order string, limit int) (res []types.NodeConfig, err error) {
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			time, version, subversion, protocol_version, chain,
+			max_mempool, min_relay_tx_fee, incremental_fee, local_relay
+		FROM
+			"node_config"
+		ORDER BY
+			%s
+		LIMIT ?
+		`, order), limit,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying node config")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t int64
+		var c types.NodeConfig
+		err := rows.Scan(
+			&t,
+			&c.Version,
+			&c.SubVersion,
+			&c.ProtocolVersion,
+			&c.Chain,
+			&c.MaxMempool,
+			&c.MinRelayTxFee,
+			&c.IncrementalFee,
+			&c.LocalRelay,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		c.Time = time.Unix(t, 0).UTC()
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// NodeConfigs returns all node config snapshots ordered by time
+func (s *Storage) NodeConfigs() ([]types.NodeConfig, error) {
+	return s.queryNodeConfigs("time ASC, rowid ASC", -1)
+}
+
+// LatestNodeConfig returns the most recent node config snapshot.
+// Returns nil if no snapshot exists.
+func (s *Storage) LatestNodeConfig() (*types.NodeConfig, error) {
+	configs, err := s.queryNodeConfigs("time DESC, rowid DESC", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	return &configs[0], nil
+}
+
+// InsertNodeConfigIfChanged stores `c` if the settings differ from the latest snapshot.
+// Returns true if `c` was stored.
+func (s *Storage) InsertNodeConfigIfChanged(c *types.NodeConfig) (bool, error) {
+	latest, err := s.LatestNodeConfig()
+	if err != nil {
+		return false, err
+	}
+	if latest != nil && latest.SameSettings(*c) {
+		return false, nil
+	}
+	return true, s.InsertNodeConfig(c)
+}
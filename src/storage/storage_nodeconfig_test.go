@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_NodeConfig(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	latest, err := st.LatestNodeConfig()
+	require.NoError(t, err)
+	require.Nil(t, latest)
+
+	config := types.NodeConfig{
+		Time:          GetTime(0),
+		Version:       190000,
+		SubVersion:    "/Satoshi:0.19.0/",
+		Chain:         "regtest",
+		MaxMempool:    300000000,
+		MinRelayTxFee: 1000,
+		LocalRelay:    true,
+	}
+
+	changed, err := st.InsertNodeConfigIfChanged(&config)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// same settings at a later time are not stored
+	config.Time = GetTime(10)
+	changed, err = st.InsertNodeConfigIfChanged(&config)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	config.Time = GetTime(20)
+	config.Version = 190100
+	changed, err = st.InsertNodeConfigIfChanged(&config)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	configs, err := st.NodeConfigs()
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, GetTime(0), configs[0].Time)
+	assert.Equal(t, config, configs[1])
+
+	latest, err = st.LatestNodeConfig()
+	require.NoError(t, err)
+	assert.Equal(t, config, *latest)
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// OpReturnTrend aggregates the OP_RETURN usage of transactions first seen in `from <= first_seen < to`
+// in buckets of length `interval`. Transactions without OP_RETURN stats are not counted.
+// Buckets without transactions are omitted.
+func (s *Storage) OpReturnTrend(from, to time.Time, interval time.Duration) ([]types.OpReturnBucket, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			(first_seen - ?1) / ?2 AS bucket,
+			COUNT(*),
+			SUM(op_return_outputs > 0),
+			SUM(op_return_size)
+		FROM
+			"transaction"
+		WHERE
+			op_return_outputs IS NOT NULL AND first_seen >= ?1 AND first_seen < ?3
+		GROUP BY
+			bucket
+		ORDER BY
+			bucket ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying op_return trend")
+	}
+	defer rows.Close()
+
+	res := []types.OpReturnBucket{}
+	for rows.Next() {
+		var bucket int64
+		var b types.OpReturnBucket
+		if err := rows.Scan(&bucket, &b.Transactions, &b.OpReturnTransactions, &b.PayloadSize); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		b.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		res = append(res, b)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_OpReturnTrend(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{}
+	for i, opReturn := range []*types.OpReturnStats{
+		{Outputs: 1, PayloadSize: 80},
+		{},
+		nil,
+		{Outputs: 2, PayloadSize: 10},
+	} {
+		tx := NewTxAtOffset(i * 40)
+		tx.OpReturn = opReturn
+		txs = append(txs, *tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(txs[0].TxID)
+	require.NoError(t, err)
+	assert.Equal(t, txs[0].OpReturn, stored.OpReturn)
+
+	trend, err := st.OpReturnTrend(GetTime(0), GetTime(1000), 100*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []types.OpReturnBucket{
+		{Time: GetTime(0), Transactions: 2, OpReturnTransactions: 1, PayloadSize: 80},
+		{Time: GetTime(100), Transactions: 1, OpReturnTransactions: 1, PayloadSize: 10},
+	}, trend)
+
+	// transactions without OP_RETURN stats are not data carriers
+	for filter, expected := range map[BoolFilter][]types.Hash32{
+		FilterTrue:  {txs[0].TxID, txs[3].TxID},
+		FilterFalse: {txs[1].TxID, txs[2].TxID},
+	} {
+		txIter, err := st.QueryTransactions(TransactionQuery{DataCarrier: filter, OrderBy: TxOrderFirstSeen})
+		require.NoError(t, err)
+		stored, err := txIter.Collect()
+		require.NoError(t, err)
+		var txids []types.Hash32
+		for _, tx := range stored {
+			txids = append(txids, tx.TxID)
+		}
+		assert.Equal(t, expected, txids, filter)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// InsertPackageEvents stores `events`. An event of the same child and parent is replaced.
+func (s *Storage) InsertPackageEvents(events []types.PackageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		_, err := dbTx.Exec(`
+			INSERT OR REPLACE INTO
+				"package_event" (child_txid, parent_txid, child_first_seen, parent_first_seen, delay_ms)
+			VALUES
+				(?, ?, ?, ?, ?)
+			`, e.Child[:], e.Parent[:], e.ChildFirstSeen.Unix(), e.ParentFirstSeen.Unix(), e.DelayMs,
+		)
+		if err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, `could not insert into table "package_event"`)
+		}
+	}
+	return dbTx.Commit()
+}
+
+// PackageEvents returns up to `limit` events with a child first seen in `from <= first_seen < to`,
+// oldest first
+func (s *Storage) PackageEvents(from, to time.Time, limit int) ([]types.PackageEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			child_txid, parent_txid, child_first_seen, parent_first_seen, delay_ms
+		FROM
+			"package_event"
+		WHERE
+			child_first_seen >= ? AND child_first_seen < ?
+		ORDER BY
+			child_first_seen ASC, child_txid ASC, parent_txid ASC
+		LIMIT ?
+		`, from.Unix(), to.Unix(), limit,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying package events")
+	}
+	defer rows.Close()
+
+	res := []types.PackageEvent{}
+	for rows.Next() {
+		var childFirstSeen, parentFirstSeen int64
+		var e types.PackageEvent
+		if err := rows.Scan((*hashColumn)(&e.Child), (*hashColumn)(&e.Parent), &childFirstSeen, &parentFirstSeen, &e.DelayMs); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		e.ChildFirstSeen = time.Unix(childFirstSeen, 0).UTC()
+		e.ParentFirstSeen = time.Unix(parentFirstSeen, 0).UTC()
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+// InsertOrphanResolutions stores `resolutions`. A resolution of the same transaction is replaced.
+func (s *Storage) InsertOrphanResolutions(resolutions []types.OrphanResolution) error {
+	if len(resolutions) == 0 {
+		return nil
+	}
+
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, r := range resolutions {
+		_, err := dbTx.Exec(`
+			INSERT OR REPLACE INTO
+				"orphan_resolution" (txid, first_seen, parents, delay_ms)
+			VALUES
+				(?, ?, ?, ?)
+			`, r.TxID[:], r.FirstSeen.Unix(), r.Parents, r.DelayMs,
+		)
+		if err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, `could not insert into table "orphan_resolution"`)
+		}
+	}
+	return dbTx.Commit()
+}
+
+// OrphanResolutions returns up to `limit` resolutions of transactions first seen in `from <= first_seen < to`,
+// oldest first
+func (s *Storage) OrphanResolutions(from, to time.Time, limit int) ([]types.OrphanResolution, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			txid, first_seen, parents, delay_ms
+		FROM
+			"orphan_resolution"
+		WHERE
+			first_seen >= ? AND first_seen < ?
+		ORDER BY
+			first_seen ASC, txid ASC
+		LIMIT ?
+		`, from.Unix(), to.Unix(), limit,
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying orphan resolutions")
+	}
+	defer rows.Close()
+
+	res := []types.OrphanResolution{}
+	for rows.Next() {
+		var firstSeen int64
+		var r types.OrphanResolution
+		if err := rows.Scan((*hashColumn)(&r.TxID), &firstSeen, &r.Parents, &r.DelayMs); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		r.FirstSeen = time.Unix(firstSeen, 0).UTC()
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
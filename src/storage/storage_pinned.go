@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// validSnapshotName matches the names of pinned snapshots, which appear in URLs and file names
+var validSnapshotName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ValidSnapshotName returns true if `name` can name a pinned snapshot
+func ValidSnapshotName(name string) bool {
+	return validSnapshotName.MatchString(name)
+}
+
+// snapshotChecksum returns the hex-encoded SHA-256 of `data`
+func snapshotChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PinSnapshot reconstructs the mempool at `at` and stores it as JSON under `name`.
+// Transactions are ordered by first-seen time and txid, so pinning the same mempool twice
+// gives the same checksum. Pinned snapshots do not reference the stored transactions
+// and are kept when these are pruned or archived. Fails if `name` is taken.
+func (s *Storage) PinSnapshot(name string, at time.Time) (*types.PinnedSnapshot, error) {
+	if !ValidSnapshotName(name) {
+		return nil, errors.Wrapf(types.ErrParse, "invalid snapshot name %q", name)
+	}
+
+	mempool, err := NewMempoolAtTime(s, at)
+	if err != nil {
+		return nil, err
+	}
+	txs := mempool.Transactions()
+	if txs == nil {
+		txs = []types.Transaction{}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if !txs[i].FirstSeen.Equal(txs[j].FirstSeen) {
+			return txs[i].FirstSeen.Before(txs[j].FirstSeen)
+		}
+		return bytes.Compare(txs[i].TxID[:], txs[j].TxID[:]) < 0
+	})
+
+	at = at.UTC().Truncate(time.Second)
+	data, err := json.Marshal(types.MempoolSnapshot{Time: at, Transactions: txs})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode snapshot")
+	}
+
+	pinned := types.PinnedSnapshot{
+		Name:         name,
+		Time:         at,
+		Created:      time.Now().UTC().Truncate(time.Second),
+		Transactions: len(txs),
+		Checksum:     snapshotChecksum(data),
+	}
+	res, err := s.db.Exec(`
+		INSERT OR IGNORE INTO "pinned_snapshot" (name, time, created, transactions, checksum, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		name, pinned.Time.Unix(), pinned.Created.Unix(), pinned.Transactions, pinned.Checksum, data,
+	)
+	if err != nil {
+		return nil, dbError(err, "could not insert into table `pinned_snapshot`")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, dbError(err, "could not insert into table `pinned_snapshot`")
+	} else if n == 0 {
+		return nil, errors.Errorf("snapshot %q already exists", name)
+	}
+	return &pinned, nil
+}
+
+// PinnedSnapshots returns the pinned snapshots ordered by name, without their data
+func (s *Storage) PinnedSnapshots() ([]types.PinnedSnapshot, error) {
+	rows, err := s.db.Query(`
+		SELECT name, time, created, transactions, checksum
+		FROM "pinned_snapshot"
+		ORDER BY name`)
+	if err != nil {
+		return nil, dbError(err, "error querying pinned snapshots")
+	}
+	defer rows.Close()
+
+	res := []types.PinnedSnapshot{}
+	for rows.Next() {
+		var p types.PinnedSnapshot
+		var t, created int64
+		if err := rows.Scan(&p.Name, &t, &created, &p.Transactions, &p.Checksum); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		p.Time = time.Unix(t, 0).UTC()
+		p.Created = time.Unix(created, 0).UTC()
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// PinnedSnapshot returns the pinned snapshot `name` and its MempoolSnapshot JSON.
+// Returns types.ErrNotFound if there is no such snapshot and an error if the data does not
+// match the checksum.
+func (s *Storage) PinnedSnapshot(name string) (*types.PinnedSnapshot, []byte, error) {
+	p := types.PinnedSnapshot{Name: name}
+	var t, created int64
+	var data []byte
+	err := s.db.QueryRow(`
+		SELECT time, created, transactions, checksum, data
+		FROM "pinned_snapshot"
+		WHERE name = ?`, name,
+	).Scan(&t, &created, &p.Transactions, &p.Checksum, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil, errors.Wrapf(types.ErrNotFound, "snapshot %q", name)
+	}
+	if err != nil {
+		return nil, nil, dbError(err, "error querying pinned snapshot")
+	}
+	p.Time = time.Unix(t, 0).UTC()
+	p.Created = time.Unix(created, 0).UTC()
+
+	if sum := snapshotChecksum(data); sum != p.Checksum {
+		return nil, nil, errors.Errorf("snapshot %q is corrupt: checksum %s, expected %s", name, sum, p.Checksum)
+	}
+	return &p, data, nil
+}
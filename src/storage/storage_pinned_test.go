@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_PinSnapshot(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{*NewTxAtOffset(20), *NewTxAtOffset(10), *NewTxAtOffset(40)}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	pinned, err := st.PinSnapshot("paper-2020", GetTime(30))
+	require.NoError(t, err)
+	assert.Equal(t, "paper-2020", pinned.Name)
+	assert.Equal(t, GetTime(30), pinned.Time)
+	assert.Equal(t, 2, pinned.Transactions)
+
+	_, err = st.PinSnapshot("paper-2020", GetTime(50))
+	assert.Error(t, err)
+	_, err = st.PinSnapshot("../paper", GetTime(50))
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+
+	// the snapshot is kept when its transactions are pruned
+	_, err = st.InsertBlock(&types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: GetTime(100),
+		Height:    1,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{txs[0].TxID, txs[1].TxID, txs[2].TxID},
+	})
+	require.NoError(t, err)
+	pruned, err := st.PruneTransactions(GetTime(1000), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+
+	list, err := st.PinnedSnapshots()
+	require.NoError(t, err)
+	assert.Equal(t, []types.PinnedSnapshot{*pinned}, list)
+
+	stored, data, err := st.PinnedSnapshot("paper-2020")
+	require.NoError(t, err)
+	assert.Equal(t, pinned, stored)
+	assert.Equal(t, pinned.Checksum, snapshotChecksum(data))
+	var snapshot types.MempoolSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	require.Len(t, snapshot.Transactions, 2)
+	assert.Equal(t, txs[1].TxID, snapshot.Transactions[0].TxID)
+	assert.Equal(t, txs[0].TxID, snapshot.Transactions[1].TxID)
+
+	_, _, err = st.PinnedSnapshot("missing")
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+
+	_, err = st.db.Exec(`UPDATE "pinned_snapshot" SET data = '{}'`)
+	require.NoError(t, err)
+	_, _, err = st.PinnedSnapshot("paper-2020")
+	assert.Error(t, err)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// querier is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// SetFinalityDepth sets the number of confirmations after which confirmed transactions are final
+// and can be pruned or archived. Zero or one makes them final with the first confirmation.
+func (s *Storage) SetFinalityDepth(depth int) {
+	atomic.StoreInt64(&s.finalityDepth, int64(depth))
+}
+
+// removedTransactionIDs returns the database ids of up to `limit` transactions that were removed
+// from the mempool (confirmed or expired) before `before`, as comma-separated list.
+// Transactions in best-chain blocks with fewer than `finalityDepth` confirmations are not final and skipped.
+// A `limit` of zero returns all of them.
+func removedTransactionIDs(q querier, before time.Time, limit int, finalityDepth int64) (string, error) {
+	query := `
+		SELECT
+			id
+		FROM
+			"transaction" t
+		WHERE
+			COALESCE(last_removed, expired) < ?1 AND
+			NOT EXISTS (
+				SELECT
+					1
+				FROM
+					"transaction_block" tb
+					JOIN "block" b ON b.id = tb.block_id
+				WHERE
+					tb.transaction_id = t.id AND b.in_best_chain = 1 AND
+					b.height > (SELECT MAX(height) FROM "block" WHERE in_best_chain = 1) + 1 - ?2
+			)
+		ORDER BY
+			id
+		`
+	if limit > 0 {
+		query += fmt.Sprintf("LIMIT %d", limit)
+	}
+
+	rows, err := q.Query(query, before.Unix(), finalityDepth)
+	if err != nil {
+		return "", dbError(err, "error querying removed transactions")
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return "", dbError(err, "error reading row")
+		}
+		ids = append(ids, fmt.Sprintf("%d", id))
+	}
+	return strings.Join(ids, ","), rows.Err()
+}
+
+// PruneTransactions deletes up to `limit` transactions (all if zero) that were removed from the mempool
+// (confirmed or expired) before `before` and are final (see SetFinalityDepth), including their inputs, outputs and block references.
+// Returns the number of deleted transactions. Smaller slices hold the write lock for a shorter time.
+// Mempool reconstruction before `before` is incomplete afterwards.
+func (s *Storage) PruneTransactions(before time.Time, limit int) (int64, error) {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	ids, err := removedTransactionIDs(dbTx, before, limit, atomic.LoadInt64(&s.finalityDepth))
+	if err != nil || ids == "" {
+		_ = dbTx.Rollback()
+		return 0, err
+	}
+
+	n, err := deleteTransactions(dbTx, ids)
+	if err != nil {
+		_ = dbTx.Rollback()
+		return 0, err
+	}
+
+	// ids of deleted transactions can be reused
+	defer s.ids.purge()
+	return n, dbTx.Commit()
+}
+
+// referencingTables are the tables with rows of transactions, referencing them by `transaction_id`
+var referencingTables = []string{
+	"transaction_block", "transaction_input", "transaction_output", "transaction_raw",
+	"first_seen_estimate", "transaction_dust", "transaction_whale", "analysis_result",
+	"transaction_witness_replacement", "transaction_fee_update", "double_spend", "transaction_drop",
+}
+
+// deleteTransactions deletes the transactions with the database ids `ids` and the rows referencing them
+func deleteTransactions(dbTx *sql.Tx, ids string) (int64, error) {
+	for _, table := range referencingTables {
+		_, err := dbTx.Exec(fmt.Sprintf(
+			`DELETE FROM "%s" WHERE transaction_id IN (%s)`, table, ids,
+		))
+		if err != nil {
+			return 0, dbError(err, "could not prune table `%s`", table)
+		}
+	}
+
+	res, err := dbTx.Exec(fmt.Sprintf(`DELETE FROM "transaction" WHERE id IN (%s)`, ids))
+	if err != nil {
+		return 0, dbError(err, "could not prune table `transaction`")
+	}
+
+	return res.RowsAffected()
+}
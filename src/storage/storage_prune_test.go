@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestStorage_PruneTransactions(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	countBefore, err := st.TxCount()
+	require.NoError(t, err)
+
+	// the first block has 3 confirmations, its transactions are not final with a depth of 4
+	st.SetFinalityDepth(4)
+	n, err := st.PruneTransactions(GetTime(101), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	st.SetFinalityDepth(3)
+
+	// transactions of the first block were removed at time 100
+	n, err = st.PruneTransactions(GetTime(101), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(testChain.blocks[0].TxIDs)), n)
+
+	countAfter, err := st.TxCount()
+	require.NoError(t, err)
+	assert.Equal(t, countBefore-int(n), countAfter)
+
+	for _, txid := range testChain.blocks[0].TxIDs {
+		tx, err := st.TransactionByID(txid)
+		require.NoError(t, err)
+		assert.Nil(t, tx)
+	}
+}
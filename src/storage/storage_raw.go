@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertTransactionRaw stores the serialized `txs`.
+// Serialized transactions that are already stored are not changed.
+func (s *Storage) insertTransactionRaw(txs []types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	txids := make([]types.Hash32, len(txs))
+	for i, tx := range txs {
+		txids[i] = tx.TxID
+	}
+	dbids, err := s.transactionDBIDs(txids)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	for i, tx := range txs {
+		dbid := (*dbids)[i]
+		if dbid < 0 {
+			continue
+		}
+		values = append(values, fmt.Sprintf(`(%d, x'%x')`, dbid, tx.Raw))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`
+		INSERT OR IGNORE INTO
+			"transaction_raw"
+			(transaction_id, raw)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	))
+	if err != nil {
+		return dbError(err, "could not insert into table `transaction_raw`")
+	}
+	return nil
+}
+
+// TransactionRaw returns the stored serialized transaction with database id `dbid`.
+// Returns nil if it is not stored.
+func (s *Storage) TransactionRaw(dbid int64) ([]byte, error) {
+	var raw []byte
+	err := s.db.QueryRow(`SELECT raw FROM "transaction_raw" WHERE transaction_id = ?`, dbid).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, dbError(err, "error querying the raw transaction")
+	}
+	return raw, nil
+}
+
+// DroppedTransactions returns up to `limit` transactions that expired without leaving the mempool
+// in a block and that are stored serialized, most recently expired first.
+// Replaced transactions are included, see ReplacedBy.
+func (s *Storage) DroppedTransactions(limit int) (*TxIterator, error) {
+	return s.QueryTransactions(TransactionQuery{
+		Expired:    FilterTrue,
+		Removed:    FilterFalse,
+		HasRaw:     FilterTrue,
+		OrderBy:    TxOrderExpiredDesc,
+		MaxResults: limit,
+	})
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_TransactionRaw(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx := NewTxAtOffset(1)
+	tx.Raw = []byte{0x02, 0x00, 0x00, 0x00, 0x01}
+	_, err = st.InsertTransactions([]types.Transaction{*NewTxAtOffset(2), *tx})
+	require.NoError(t, err)
+
+	// the first serialization is kept
+	again := *tx
+	again.Raw = []byte{0x01}
+	_, err = st.InsertTransaction(&again)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	raw, err := st.TransactionRaw(stored.DBID)
+	require.NoError(t, err)
+	assert.Equal(t, tx.Raw, raw)
+
+	withoutRaw, err := st.TransactionByID(NewTxAtOffset(2).TxID)
+	require.NoError(t, err)
+	raw, err = st.TransactionRaw(withoutRaw.DBID)
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertReorg records the reorg from `oldTip` to `newTip` with the last common block `fork`.
+// `orphaned` are the blocks removed from the best chain and `attached` the blocks added to it, highest first.
+func (s *Storage) insertReorg(fork, oldTip, newTip *types.StoredBlock, orphaned, attached []types.StoredBlock) error {
+	if len(orphaned) == 0 {
+		return nil
+	}
+
+	orphanedIDs := make([]string, len(orphaned))
+	for i, b := range orphaned {
+		orphanedIDs[i] = fmt.Sprint(b.DBID)
+	}
+	attachedIDs := make([]string, len(attached))
+	for i, b := range attached {
+		attachedIDs[i] = fmt.Sprint(b.DBID)
+	}
+
+	var transactions, unconfirmed int
+	err := s.db.QueryRow(fmt.Sprintf(`
+		SELECT
+			COUNT(DISTINCT transaction_id),
+			COUNT(DISTINCT CASE WHEN transaction_id NOT IN (
+				SELECT transaction_id FROM "transaction_block" WHERE block_id IN (%s)
+			) THEN transaction_id END)
+		FROM
+			"transaction_block"
+		WHERE
+			block_id IN (%s)
+		`, strings.Join(attachedIDs, ","), strings.Join(orphanedIDs, ","),
+	)).Scan(&transactions, &unconfirmed)
+	if err != nil {
+		return dbError(err, "could not count the transactions of orphaned blocks")
+	}
+
+	// the first blocks after the fork are the last ones of both lists
+	start := orphaned[len(orphaned)-1].FirstSeen
+	if len(attached) > 0 && attached[len(attached)-1].FirstSeen.Before(start) {
+		start = attached[len(attached)-1].FirstSeen
+	}
+
+	res, err := s.db.Exec(`
+		INSERT INTO
+			"reorg" (time, fork_block, old_tip, new_tip, depth, transactions, unconfirmed, duration)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?)
+		`, newTip.FirstSeen.Unix(), fork.DBID, oldTip.DBID, newTip.DBID, len(orphaned),
+		transactions, unconfirmed, newTip.FirstSeen.Unix()-start.Unix(),
+	)
+	if err != nil {
+		return dbError(err, "could not insert into table `reorg`")
+	}
+	reorgID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	values := make([]string, len(orphaned))
+	for i, b := range orphaned {
+		values[i] = fmt.Sprintf("(%d, %d)", reorgID, b.DBID)
+	}
+	_, err = s.db.Exec(fmt.Sprintf(
+		`INSERT INTO "reorg_block" (reorg_id, block_id) VALUES %s`, strings.Join(values, ","),
+	))
+	return dbError(err, "could not insert into table `reorg_block`")
+}
+
+// ReorgQuery selects reorgs matching all set fields
+type ReorgQuery struct {
+	// Inclusive range of the time of the reorg
+	From *time.Time
+	To   *time.Time
+	// Minimum number of orphaned blocks
+	MinDepth int
+	// Maximum number of results, 0 for no limit
+	MaxResults int
+}
+
+// Reorgs returns the reorgs matching `q`, most recent first
+func (s *Storage) Reorgs(q ReorgQuery) ([]types.Reorg, error) {
+	var c conditions
+	if q.From != nil {
+		c.add("r.time >= ?", q.From.Unix())
+	}
+	if q.To != nil {
+		c.add("r.time <= ?", q.To.Unix())
+	}
+	if q.MinDepth > 0 {
+		c.add("r.depth >= ?", q.MinDepth)
+	}
+
+	query := `
+		SELECT
+			r.id, r.time, f.hash, f.height, o.hash, n.hash, r.depth, r.transactions, r.unconfirmed, r.duration
+		FROM
+			"reorg" r
+			JOIN "block" f ON f.id = r.fork_block
+			JOIN "block" o ON o.id = r.old_tip
+			JOIN "block" n ON n.id = r.new_tip`
+	where, args := c.where()
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY r.time DESC, r.id DESC"
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying reorgs")
+	}
+	defer rows.Close()
+
+	res := []types.Reorg{}
+	ids := []string{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var id, t int64
+		var r types.Reorg
+		err := rows.Scan(
+			&id, &t, (*hashColumn)(&r.ForkHash), &r.ForkHeight, (*hashColumn)(&r.OldTip), (*hashColumn)(&r.NewTip),
+			&r.Depth, &r.Transactions, &r.Unconfirmed, &r.DurationSeconds,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		r.Time = time.Unix(t, 0).UTC()
+		r.Orphaned = []types.Hash32{}
+		index[id] = len(res)
+		ids = append(ids, fmt.Sprint(id))
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError(err, "error reading rows")
+	}
+	if len(res) == 0 {
+		return res, nil
+	}
+
+	blocks, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			rb.reorg_id, b.hash
+		FROM
+			"reorg_block" rb
+			JOIN "block" b ON b.id = rb.block_id
+		WHERE
+			rb.reorg_id IN (%s)
+		ORDER BY
+			b.height DESC
+		`, strings.Join(ids, ","),
+	))
+	if err != nil {
+		return nil, dbError(err, "error querying orphaned blocks")
+	}
+	defer blocks.Close()
+	for blocks.Next() {
+		var id int64
+		var hash types.Hash32
+		if err := blocks.Scan(&id, (*hashColumn)(&hash)); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		r := &res[index[id]]
+		r.Orphaned = append(r.Orphaned, hash)
+	}
+	return res, blocks.Err()
+}
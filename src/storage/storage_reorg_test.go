@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_Reorgs(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	testChain := NewTestChainReorg()
+	require.NoError(t, insertTestChain(st, &testChain))
+
+	// the reorg to "1.2" orphans "3" and "2"
+	reorgs, err := st.Reorgs(ReorgQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []types.Reorg{{
+		Time:       GetTime(500).UTC(),
+		ForkHash:   test.GenerateHash32("1"),
+		ForkHeight: 0,
+		OldTip:     test.GenerateHash32("3"),
+		NewTip:     test.GenerateHash32("1.2"),
+		Depth:      2,
+		Orphaned:   txidsFromStrings("3", "2"),
+		// tx-20, tx-30, tx-100 and tx-110, of which tx-100 and tx-110 are not in "1.1" or "1.2"
+		Transactions: 4,
+		Unconfirmed:  2,
+		// from "2" to "1.2"
+		DurationSeconds: 300,
+	}}, reorgs)
+
+	from := GetTime(501)
+	reorgs, err = st.Reorgs(ReorgQuery{From: &from})
+	require.NoError(t, err)
+	assert.Empty(t, reorgs)
+	reorgs, err = st.Reorgs(ReorgQuery{MinDepth: 3})
+	require.NoError(t, err)
+	assert.Empty(t, reorgs)
+}
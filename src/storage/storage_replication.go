@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// CheckpointMode is the mode of a WAL checkpoint, see https://www.sqlite.org/pragma.html#pragma_wal_checkpoint
+type CheckpointMode string
+
+const (
+	// CheckpointPassive copies as many frames as possible without waiting for readers or writers
+	CheckpointPassive CheckpointMode = "PASSIVE"
+	// CheckpointFull waits for writers and copies all frames
+	CheckpointFull CheckpointMode = "FULL"
+	// CheckpointRestart is CheckpointFull and waits for readers, so the next writer restarts the WAL
+	CheckpointRestart CheckpointMode = "RESTART"
+	// CheckpointTruncate is CheckpointRestart and truncates the WAL file to zero bytes
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// CheckpointResult is the outcome of a WAL checkpoint
+type CheckpointResult struct {
+	// Busy is true if the checkpoint could not complete because of other connections
+	Busy bool `json:"busy"`
+	// Frames in the WAL
+	LogFrames int `json:"logFrames"`
+	// Frames copied to the database file
+	CheckpointedFrames int `json:"checkpointedFrames"`
+}
+
+// replicationPragmas are executed on new connections if replication is enabled.
+// The WAL mode is persistent only after the first write, so it is set on every connection.
+var replicationPragmas = []string{`PRAGMA journal_mode = WAL`, `PRAGMA wal_autocheckpoint = 0`}
+
+// defaultMaxIdleConns is the default of sql.DB.SetMaxIdleConns
+const defaultMaxIdleConns = 2
+
+// EnableReplication prepares the database for continuous replication with tools like Litestream,
+// which copy the WAL before it is checkpointed into the database file. Switches all connections to
+// WAL mode, which is kept in the file, and disables their automatic checkpoints, so that
+// checkpoints only happen at consistent points chosen by the caller (see Checkpoint) or by the
+// replication tool. Must be called before the storage is used concurrently.
+func (s *Storage) EnableReplication() error {
+	if s.connector == nil {
+		return errors.New("could not enable replication: unknown connector")
+	}
+	atomic.StoreInt32(&s.connector.replication, 1)
+	// close the idle connections, the new ones are opened in WAL mode without automatic checkpoints
+	s.db.SetMaxIdleConns(0)
+	s.db.SetMaxIdleConns(defaultMaxIdleConns)
+
+	var mode string
+	if err := s.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		return dbError(err, "could not get the journal mode")
+	}
+	if strings.ToLower(mode) != "wal" {
+		return errors.Errorf("could not enable WAL mode, journal mode is %s", mode)
+	}
+	return nil
+}
+
+// Checkpoint copies the frames of the WAL into the database file, see CheckpointMode.
+// Replication tools capture the writes that were committed before the checkpoint.
+// Requires WAL mode, see EnableReplication.
+func (s *Storage) Checkpoint(mode CheckpointMode) (CheckpointResult, error) {
+	switch mode {
+	case CheckpointPassive, CheckpointFull, CheckpointRestart, CheckpointTruncate:
+	default:
+		return CheckpointResult{}, errors.Errorf("invalid checkpoint mode %q", mode)
+	}
+
+	var busy int
+	var res CheckpointResult
+	err := s.db.QueryRow(fmt.Sprintf(`PRAGMA wal_checkpoint(%s)`, mode)).Scan(
+		&busy, &res.LogFrames, &res.CheckpointedFrames,
+	)
+	if err != nil {
+		return res, dbError(err, "could not checkpoint the WAL")
+	}
+	res.Busy = busy != 0
+	if res.LogFrames < 0 {
+		return res, errors.New("could not checkpoint the WAL: database is not in WAL mode")
+	}
+	return res, nil
+}
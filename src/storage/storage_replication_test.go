@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestStorage_Checkpoint(t *testing.T) {
+	test.SkipIfShort(t)
+
+	path := os.Getenv("TEST_INTEGRATION_DIR") + "/replicated.db"
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		_ = os.Remove(path + suffix)
+		defer os.Remove(path + suffix)
+	}
+	st, err := NewStorage(path)
+	require.NoError(t, err)
+	defer st.Close()
+
+	// requires WAL mode
+	_, err = st.Checkpoint(CheckpointPassive)
+	assert.Error(t, err)
+
+	require.NoError(t, st.EnableReplication())
+	var autoCheckpoint int
+	require.NoError(t, st.db.QueryRow(`PRAGMA wal_autocheckpoint`).Scan(&autoCheckpoint))
+	assert.Equal(t, 0, autoCheckpoint)
+
+	_, err = st.InsertTransaction(NewTxAtOffset(1))
+	require.NoError(t, err)
+
+	// without automatic checkpoints, the WAL keeps the frames until Checkpoint
+	res, err := st.Checkpoint(CheckpointPassive)
+	require.NoError(t, err)
+	assert.False(t, res.Busy)
+	assert.True(t, res.LogFrames > 0)
+	assert.Equal(t, res.LogFrames, res.CheckpointedFrames)
+
+	res, err = st.Checkpoint(CheckpointTruncate)
+	require.NoError(t, err)
+	assert.Equal(t, CheckpointResult{}, res)
+
+	_, err = st.Checkpoint("NONE")
+	assert.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// RecordSamplingRate records that the fraction `rate` of the transactions is stored from `t` on.
+// Nothing is recorded if the rate did not change, no record means that all transactions are stored.
+func (s *Storage) RecordSamplingRate(t time.Time, rate float64) error {
+	last := 1.0
+	err := s.db.QueryRow(`SELECT rate FROM "sampling_rate" ORDER BY time DESC, rowid DESC LIMIT 1`).Scan(&last)
+	if err != nil && err != sql.ErrNoRows {
+		return dbError(err, "could not read the sampling rate")
+	}
+	if rate == last {
+		return nil
+	}
+	if _, err := s.db.Exec(`INSERT INTO "sampling_rate" (time, rate) VALUES (?, ?)`, t.Unix(), rate); err != nil {
+		return dbError(err, "could not insert into table `sampling_rate`")
+	}
+	return nil
+}
+
+// SamplingRates returns the periods with a recorded sampling rate ordered by time, empty if all
+// transactions were stored. Transactions first seen before the first period were all stored.
+func (s *Storage) SamplingRates() ([]types.SamplingPeriod, error) {
+	rows, err := s.db.Query(`SELECT time, rate FROM "sampling_rate" ORDER BY time ASC, rowid ASC`)
+	if err != nil {
+		return nil, dbError(err, "error querying sampling rates")
+	}
+	defer rows.Close()
+
+	res := []types.SamplingPeriod{}
+	for rows.Next() {
+		var t int64
+		var p types.SamplingPeriod
+		if err := rows.Scan(&t, &p.Rate); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		p.From = time.Unix(t, 0).UTC()
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
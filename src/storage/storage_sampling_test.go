@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_SamplingRates(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	// all transactions stored
+	require.NoError(t, st.RecordSamplingRate(GetTime(0), 1))
+	require.NoError(t, st.RecordSamplingRate(GetTime(100), 0.1))
+	require.NoError(t, st.RecordSamplingRate(GetTime(200), 0.1))
+	require.NoError(t, st.RecordSamplingRate(GetTime(300), 1))
+
+	periods, err := st.SamplingRates()
+	require.NoError(t, err)
+	assert.Equal(t, []types.SamplingPeriod{
+		{From: GetTime(100).UTC(), Rate: 0.1},
+		{From: GetTime(300).UTC(), Rate: 1},
+	}, periods)
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// hexPrefixRange returns the blob range [lower, upper) of all values starting with the
+// hex prefix `prefix`. `upper` is empty if the range is unbounded.
+func hexPrefixRange(prefix string) (lower, upper string, err error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) == 0 || len(prefix) > 64 {
+		return "", "", errors.Wrapf(types.ErrParse, "invalid prefix length %d", len(prefix))
+	}
+
+	nibbles := []byte(prefix)
+	for _, c := range nibbles {
+		if !strings.ContainsRune("0123456789abcdef", rune(c)) {
+			return "", "", errors.Wrapf(types.ErrParse, "invalid hex prefix %q", prefix)
+		}
+	}
+
+	lower = prefix
+	if len(lower)%2 == 1 {
+		lower += "0"
+	}
+
+	// increment the prefix as a base-16 number, dropping trailing `f`
+	for i := len(nibbles) - 1; i >= 0; i-- {
+		if nibbles[i] == 'f' {
+			nibbles = nibbles[:i]
+			continue
+		}
+		if nibbles[i] == '9' {
+			nibbles[i] = 'a'
+		} else {
+			nibbles[i]++
+		}
+		upper = string(nibbles)
+		if len(upper)%2 == 1 {
+			upper += "0"
+		}
+		break
+	}
+
+	if _, err := hex.DecodeString(lower); err != nil {
+		return "", "", err
+	}
+
+	return lower, upper, nil
+}
+
+// TransactionsByTxIDPrefix returns up to `limit` transactions whose txid starts with
+// the hex string `prefix`, ordered by txid.
+// The range comparison allows SQLite to use the index on txid.
+func (s *Storage) TransactionsByTxIDPrefix(prefix string, limit int) (*TxIterator, error) {
+	lower, upper, err := hexPrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	q := TransactionQuery{OrderBy: TxOrderTxID, MaxResults: limit}
+	if q.TxIDFrom, err = hex.DecodeString(lower); err != nil {
+		return nil, err
+	}
+	if upper != "" {
+		if q.TxIDBefore, err = hex.DecodeString(upper); err != nil {
+			return nil, err
+		}
+	}
+	return s.QueryTransactions(q)
+}
+
+// BlocksByHeight returns all blocks at `height`, including blocks of competing chains.
+func (s *Storage) BlocksByHeight(height uint32) (*BlockIterator, error) {
+	return s.queryBlocks(BlockQuery{MinHeight: &height, MaxHeight: &height, OrderBy: BlockOrderBestFirst})
+}
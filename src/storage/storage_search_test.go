@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestHexPrefixRange(t *testing.T) {
+	for _, c := range []struct {
+		prefix, lower, upper string
+	}{
+		{"ab", "ab", "ac"},
+		{"a", "a0", "b0"},
+		{"AB1", "ab10", "ab20"},
+		{"19", "19", "1a"},
+		{"0f", "0f", "10"},
+		{"ff", "ff", ""},
+		{"aff", "aff0", "b0"},
+	} {
+		lower, upper, err := hexPrefixRange(c.prefix)
+		require.NoError(t, err)
+		assert.Equal(t, c.lower, lower, c.prefix)
+		assert.Equal(t, c.upper, upper, c.prefix)
+	}
+
+	for _, invalid := range []string{"", "xy", strings.Repeat("a", 65)} {
+		_, _, err := hexPrefixRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestStorage_TransactionsByTxIDPrefix(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{}
+	for i := 0; i < 64; i++ {
+		tx := NewTxAtOffset(i)
+		txs = append(txs, *tx)
+		_, err := st.InsertTransaction(tx)
+		require.NoError(t, err)
+	}
+
+	for _, tx := range txs {
+		for _, n := range []int{1, 3, 8, 64} {
+			prefix := tx.TxID.String()[:n]
+			res, err := st.TransactionsByTxIDPrefix(prefix, 0)
+			require.NoError(t, err)
+
+			expected := []types.Hash32{}
+			for _, other := range txs {
+				if strings.HasPrefix(other.TxID.String(), prefix) {
+					expected = append(expected, other.TxID)
+				}
+			}
+			txs, err := res.Collect()
+			require.NoError(t, err)
+			require.ElementsMatch(
+				t, expected, transactionIdsFromTxs(storedToTransactions(txs)),
+				fmt.Sprintf("prefix=%s", prefix),
+			)
+		}
+	}
+}
+
+func storedToTransactions(stored []types.StoredTransaction) (res []types.Transaction) {
+	for _, tx := range stored {
+		res = append(res, tx.Transaction)
+	}
+	return
+}
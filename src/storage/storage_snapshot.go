@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot writes a consistent copy of the database to `path` while writes continue.
+// Fails if `path` already exists.
+func (s *Storage) Snapshot(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("snapshot %s already exists", path)
+	}
+
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return errors.Errorf("could not write snapshot to %s: %s", path, err)
+	}
+	return nil
+}
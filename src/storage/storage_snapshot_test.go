@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+)
+
+func TestStorage_Snapshot(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx := NewTxAtOffset(10)
+	_, err = st.InsertTransaction(tx)
+	require.NoError(t, err)
+
+	path := StoragePath() + ".snapshot"
+	_ = os.Remove(path)
+	defer os.Remove(path)
+
+	require.NoError(t, st.Snapshot(path))
+	assert.Error(t, st.Snapshot(path))
+
+	snapshot, err := NewStorage(path)
+	require.NoError(t, err)
+	defer snapshot.Close()
+
+	stored, err := snapshot.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, *tx, stored.Transaction)
+}
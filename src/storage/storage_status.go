@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ingestRateWindows are the windows of IngestRates
+var ingestRateWindows = []time.Duration{10 * time.Minute, time.Hour, 24 * time.Hour}
+
+// SchemaVersion returns the schema version of the database
+func (s *Storage) SchemaVersion() (int, error) {
+	return s.getVersion()
+}
+
+// IngestRates returns the number of transactions and blocks first seen in windows of
+// 10 minutes, an hour and a day before `now`
+func (s *Storage) IngestRates(now time.Time) ([]types.IngestRate, error) {
+	res := make([]types.IngestRate, 0, len(ingestRateWindows))
+	for _, window := range ingestRateWindows {
+		from := now.Add(-window).Unix()
+		r := types.IngestRate{Window: window.String()}
+		err := s.db.QueryRow(`
+			SELECT
+				(SELECT COUNT(*) FROM "transaction" WHERE first_seen > ? AND first_seen <= ?),
+				(SELECT COUNT(*) FROM "block" WHERE first_seen > ? AND first_seen <= ?)
+		`, from, now.Unix(), from, now.Unix()).Scan(&r.Transactions, &r.Blocks)
+		if err != nil {
+			return nil, dbError(err, "could not count recent transactions")
+		}
+		r.TransactionsPerSecond = float64(r.Transactions) / window.Seconds()
+		res = append(res, r)
+	}
+	return res, nil
+}
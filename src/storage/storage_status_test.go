@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_IngestRates(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	version, err := st.SchemaVersion()
+	require.NoError(t, err)
+	assert.Equal(t, currentVersion, version)
+
+	// blocks at 0, 100 and 200
+	require.NoError(t, insertBlocks(st, chainedBlocks(0, "", []string{"x", "y", "z"})))
+	_, err = st.InsertTransactions([]types.Transaction{
+		*NewTxAtOffset(-7200), *NewTxAtOffset(0), *NewTxAtOffset(300), *NewTxAtOffset(600),
+	})
+	require.NoError(t, err)
+
+	rates, err := st.IngestRates(GetTime(600))
+	require.NoError(t, err)
+	assert.Equal(t, []types.IngestRate{
+		{Window: "10m0s", Transactions: 2, TransactionsPerSecond: 2.0 / 600, Blocks: 2},
+		{Window: "1h0m0s", Transactions: 3, TransactionsPerSecond: 3.0 / 3600, Blocks: 3},
+		{Window: "24h0m0s", Transactions: 4, TransactionsPerSecond: 4.0 / 86400, Blocks: 3},
+	}, rates)
+}
@@ -1,10 +1,16 @@
 package storage
 
 import (
+	"bytes"
+	"database/sql"
 	"fmt"
 	"os"
+	"testing"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
 	"github.com/0xb10c/bademeister-go/src/test"
 	"github.com/0xb10c/bademeister-go/src/types"
 )
@@ -196,3 +202,258 @@ func NewTestChainReorg() TestChain {
 		expectedMempools: mempoolAtTime,
 	}
 }
+
+func requireVersion(t *testing.T, st *Storage, expected int) {
+	version, err := st.getVersion()
+	require.NoError(t, err)
+	require.Equal(t, expected, version)
+}
+
+func TestStorage_Migrate(t *testing.T) {
+	test.SkipIfShort(t)
+
+	if err := os.Remove(StoragePath()); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", StoragePath())
+	require.NoError(t, err)
+	st := &Storage{db: db}
+	require.NoError(t, st.initialize(baseVersion))
+	requireVersion(t, st, baseVersion)
+	require.NoError(t, st.Close())
+
+	st, err = NewStorage(StoragePath())
+	require.NoError(t, err)
+	defer st.Close()
+	requireVersion(t, st, currentVersion)
+	require.Equal(t, currentVersion, migrations[len(migrations)-1].version)
+}
+
+func TestStorage_Downgrade(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+
+	tx := NewTxAtOffset(0)
+	_, err = st.InsertTransaction(tx)
+	require.NoError(t, err)
+
+	for _, m := range migrations {
+		require.NotEmpty(t, m.down, "migration to version %d has no down statements", m.version)
+	}
+
+	require.Error(t, st.Downgrade(baseVersion-1))
+	require.Error(t, st.Downgrade(currentVersion+1))
+
+	require.NoError(t, st.Downgrade(baseVersion))
+	requireVersion(t, st, baseVersion)
+
+	var count int
+	require.NoError(t, st.db.QueryRow(`SELECT COUNT(*) FROM "transaction"`).Scan(&count))
+	require.Equal(t, 1, count)
+	require.NoError(t, st.Close())
+
+	// upgrading again restores the current schema
+	st, err = NewStorage(StoragePath())
+	require.NoError(t, err)
+	defer st.Close()
+	requireVersion(t, st, currentVersion)
+
+	storedTx, err := st.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, storedTx)
+	require.Equal(t, *tx, storedTx.Transaction)
+}
+
+func TestStorage_MigrateRPCTxIDs(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+
+	// stored via RPC by an older release: only via RPC, and both via RPC (earlier) and ZMQ
+	witnessSize := 0
+	rpcOnly := NewTxAtOffset(1)
+	rpcOnly.TxID = rpcOnly.TxID.Reversed()
+	zmq := NewTxAtOffset(2)
+	zmq.WitnessSize = &witnessSize
+	rpcCopy := NewTxAtOffset(0)
+	rpcCopy.TxID = zmq.TxID.Reversed()
+	for _, tx := range []*types.Transaction{rpcOnly, zmq, rpcCopy} {
+		_, err = st.InsertTransaction(tx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, st.Downgrade(38))
+	require.NoError(t, st.Close())
+
+	st, err = NewStorage(StoragePath())
+	require.NoError(t, err)
+	defer st.Close()
+	count, err := st.TxCount()
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	// the source of rows without pair is unknown, they are kept as they are
+	stored, err := st.TransactionByID(rpcOnly.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, rpcOnly.FirstSeen, stored.FirstSeen)
+	stored, err = st.TransactionByID(zmq.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, rpcCopy.FirstSeen, stored.FirstSeen)
+}
+
+func TestStorage_MigrateRPCTxIDsFromBaseVersion(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	require.NoError(t, st.Downgrade(baseVersion))
+
+	insert := func(txid types.Hash32, firstSeen int64) int64 {
+		res, err := st.db.Exec(
+			`INSERT INTO "transaction" (txid, first_seen, fee, weight) VALUES (?, ?, 1000, 400)`, txid[:], firstSeen,
+		)
+		require.NoError(t, err)
+		id, err := res.LastInsertId()
+		require.NoError(t, err)
+		return id
+	}
+	hash, parent := test.GenerateHash32("block"), test.GenerateHash32("parent")
+	res, err := st.db.Exec(
+		`INSERT INTO "block" (hash, parent, first_seen, height, is_best) VALUES (?, ?, 300, 1, 1)`, hash[:], parent[:],
+	)
+	require.NoError(t, err)
+	blockID, err := res.LastInsertId()
+	require.NoError(t, err)
+	confirm := func(id int64) {
+		_, err := st.db.Exec(
+			`INSERT INTO transaction_block (transaction_id, block_id, block_index) VALUES (?, ?, 0)`, id, blockID,
+		)
+		require.NoError(t, err)
+	}
+
+	// received via ZMQ: in the mempool, confirmed, and confirmed with an earlier copy via RPC
+	zmqMempool := test.GenerateHash32("zmq-mempool")
+	insert(zmqMempool, 100)
+	zmqConfirmed := test.GenerateHash32("zmq-confirmed")
+	confirm(insert(zmqConfirmed, 100))
+	paired := test.GenerateHash32("paired")
+	confirm(insert(paired, 200))
+	insert(paired.Reversed(), 150)
+	// only via RPC, and an unconfirmed pair whose ZMQ copy cannot be told apart
+	rpcOnly := test.GenerateHash32("rpc-only").Reversed()
+	insert(rpcOnly, 100)
+	unmarked := test.GenerateHash32("unmarked")
+	insert(unmarked, 100)
+	insert(unmarked.Reversed(), 90)
+	require.NoError(t, st.Close())
+
+	st, err = NewStorage(StoragePath())
+	require.NoError(t, err)
+	defer st.Close()
+	requireVersion(t, st, currentVersion)
+
+	count, err := st.TxCount()
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+	for _, txid := range []types.Hash32{zmqMempool, zmqConfirmed, rpcOnly, unmarked, unmarked.Reversed()} {
+		stored, err := st.TransactionByID(txid)
+		require.NoError(t, err)
+		require.NotNil(t, stored, txid.String())
+	}
+	stored, err := st.TransactionByID(paired)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, time.Unix(150, 0).UTC(), stored.FirstSeen)
+	stored, err = st.TransactionByID(paired.Reversed())
+	require.NoError(t, err)
+	require.Nil(t, stored)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+	_, err = st.InsertTransaction(NewTxAtOffset(1))
+	require.NoError(t, err)
+
+	ro, err := OpenReadOnly(StoragePath())
+	require.NoError(t, err)
+	defer ro.Close()
+	count, err := ro.TxCount()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	_, err = ro.InsertTransaction(NewTxAtOffset(2))
+	require.Error(t, err)
+
+	// a file: URI with parameters
+	uri, err := OpenReadOnly("file:" + StoragePath() + "?cache=private")
+	require.NoError(t, err)
+	require.NoError(t, uri.Close())
+
+	_, err = st.db.Exec(`UPDATE config SET version = ?`, currentVersion-1)
+	require.NoError(t, err)
+	_, err = OpenReadOnly(StoragePath())
+	require.Error(t, err)
+
+	_, err = OpenReadOnly(os.Getenv("TEST_INTEGRATION_DIR") + "/missing.db")
+	require.Error(t, err)
+}
+
+func TestStorage_Corrupt(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		_, err = st.InsertTransaction(NewTxAtOffset(10 + i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, st.Close())
+	require.NoError(t, QuickCheck(StoragePath()))
+
+	// overwrite all pages after the first one
+	f, err := os.OpenFile(StoragePath(), os.O_RDWR, 0)
+	require.NoError(t, err)
+	info, err := f.Stat()
+	require.NoError(t, err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xff}, int(info.Size())-4096), 4096)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	err = QuickCheck(StoragePath())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "damaged")
+
+	require.Error(t, QuickCheck(os.Getenv("TEST_INTEGRATION_DIR")+"/missing.db"))
+}
+
+func TestStorage_FutureSchema(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	_, err = st.InsertTransaction(NewTxAtOffset(10))
+	require.NoError(t, err)
+	_, err = st.db.Exec(`UPDATE config SET version = ?`, currentVersion+1)
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	expected := &FutureSchemaError{Path: StoragePath(), Version: currentVersion + 1, Supported: currentVersion}
+	_, err = NewStorage(StoragePath())
+	require.Equal(t, expected, errors.Cause(err))
+	_, err = OpenReadOnly(StoragePath())
+	require.Equal(t, expected, errors.Cause(err))
+
+	ro, err := OpenReadOnlyAllowNewer(StoragePath())
+	require.NoError(t, err)
+	defer ro.Close()
+	count, err := ro.TxCount()
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
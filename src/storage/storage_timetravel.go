@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeTravelView is a view of the state at a point in time
+type timeTravelView struct {
+	name string
+	// SELECT statement with `%[1]d` for the time in unix seconds
+	query string
+}
+
+// sql returns the query of the view for `at`
+func (v timeTravelView) sql(at time.Time) string {
+	return strings.TrimSpace(fmt.Sprintf(v.query, at.Unix()))
+}
+
+// timeTravelViews are the views created by TimeTravelViews, in dependency order.
+// The best chain is the one known today, like in NewMempoolAtTime: blocks that were best at the
+// time but were later orphaned are not part of it.
+var timeTravelViews = []timeTravelView{{
+	name:  "block_as_of",
+	query: `SELECT * FROM main."block" WHERE first_seen <= %[1]d`,
+}, {
+	name:  "best_block_as_of",
+	query: `SELECT * FROM main."block" WHERE is_best = 1 AND first_seen <= %[1]d`,
+}, {
+	name:  "transaction_as_of",
+	query: `SELECT * FROM main."transaction" WHERE first_seen <= %[1]d`,
+}, {
+	name: "mempool_as_of",
+	query: `
+		SELECT * FROM main."transaction" t
+		WHERE
+			t.first_seen <= %[1]d
+			AND (t.expired IS NULL OR t.expired > %[1]d)
+			AND NOT EXISTS (
+				SELECT 1 FROM main."transaction_block" tb JOIN main."block" b ON b.id = tb.block_id
+				WHERE tb.transaction_id = t.id AND b.is_best = 1 AND b.first_seen <= %[1]d
+			)`,
+}}
+
+// TimeTravelViews returns statements that (re)create temporary views of the state at `at`:
+//
+//	block_as_of:       blocks first seen until `at`
+//	best_block_as_of:  the best-chain blocks of block_as_of
+//	transaction_as_of: transactions first seen until `at`
+//	mempool_as_of:     transactions of transaction_as_of that were neither confirmed by
+//	                   best_block_as_of nor expired at `at`
+//
+// The views select whole rows, so columns written later (e.g. `last_removed`) can describe
+// events after `at`. Temporary views belong to a single connection, e.g. run the statements in
+// the `sqlite3` shell before arbitrary queries. See WithTimeTravelViews.
+func TimeTravelViews(at time.Time) []string {
+	var res []string
+	for _, v := range timeTravelViews {
+		res = append(res,
+			fmt.Sprintf(`DROP VIEW IF EXISTS temp."%s"`, v.name),
+			fmt.Sprintf(`CREATE TEMP VIEW "%s" AS %s`, v.name, v.sql(at)),
+		)
+	}
+	return res
+}
+
+// TimeTravelCTE returns a WITH clause that defines the views of TimeTravelViews as common table
+// expressions, for prefixing a single query where temporary views cannot be created
+func TimeTravelCTE(at time.Time) string {
+	var ctes []string
+	for _, v := range timeTravelViews {
+		ctes = append(ctes, fmt.Sprintf(`"%s" AS (%s)`, v.name, v.sql(at)))
+	}
+	return "WITH " + strings.Join(ctes, ",\n")
+}
+
+// WithTimeTravelViews creates the views of TimeTravelViews for `at` on a single connection of
+// the pool and calls `f` with it. The views are dropped when `f` returns.
+// `f` must only use `conn` and not keep it.
+func (s *Storage) WithTimeTravelViews(at time.Time, f func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	// temporary views only apply to a single connection of the pool
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, statement := range TimeTravelViews(at) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return dbError(err, "could not create time-travel views")
+		}
+	}
+	defer func() {
+		for i := len(timeTravelViews) - 1; i >= 0; i-- {
+			_, _ = conn.ExecContext(ctx, fmt.Sprintf(`DROP VIEW IF EXISTS temp."%s"`, timeTravelViews[i].name))
+		}
+	}()
+
+	return f(conn)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_WithTimeTravelViews(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{*NewTxAtOffset(10), *NewTxAtOffset(20), *NewTxAtOffset(30), *NewTxAtOffset(40)}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+	_, err = st.InsertBlock(&types.Block{
+		Hash:      test.GenerateHash32("block"),
+		FirstSeen: GetTime(35),
+		Height:    1,
+		IsBest:    true,
+		TxIDs:     []types.Hash32{txs[0].TxID, txs[1].TxID},
+	})
+	require.NoError(t, err)
+
+	sorted := func(ids []types.Hash32) []types.Hash32 {
+		sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+		return ids
+	}
+	queryTxIDs := func(conn *sql.Conn, query string) []types.Hash32 {
+		rows, err := conn.QueryContext(context.Background(), query)
+		require.NoError(t, err)
+		defer rows.Close()
+		var res []types.Hash32
+		for rows.Next() {
+			var h types.Hash32
+			require.NoError(t, rows.Scan((*hashColumn)(&h)))
+			res = append(res, h)
+		}
+		require.NoError(t, rows.Err())
+		return sorted(res)
+	}
+
+	for _, offset := range []int{5, 25, 35, 45} {
+		mempool, err := NewMempoolAtTime(st, GetTime(offset))
+		require.NoError(t, err)
+		var expected []types.Hash32
+		for _, tx := range mempool.Transactions() {
+			expected = append(expected, tx.TxID)
+		}
+		expected = sorted(expected)
+
+		err = st.WithTimeTravelViews(GetTime(offset), func(conn *sql.Conn) error {
+			assert.Equal(t, expected, queryTxIDs(conn, `SELECT txid FROM mempool_as_of`), offset)
+			return nil
+		})
+		require.NoError(t, err)
+
+		// the CTE defines the same views for a single query
+		conn, err := st.db.Conn(context.Background())
+		require.NoError(t, err)
+		cte := TimeTravelCTE(GetTime(offset)) + ` SELECT txid FROM mempool_as_of`
+		assert.Equal(t, expected, queryTxIDs(conn, cte), offset)
+		require.NoError(t, conn.Close())
+	}
+
+	err = st.WithTimeTravelViews(GetTime(36), func(conn *sql.Conn) error {
+		var blocks, best, known int
+		err := conn.QueryRowContext(context.Background(), `
+			SELECT
+				(SELECT COUNT(*) FROM block_as_of),
+				(SELECT COUNT(*) FROM best_block_as_of),
+				(SELECT COUNT(*) FROM transaction_as_of)`,
+		).Scan(&blocks, &best, &known)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1, 1, 3}, []int{blocks, best, known})
+		return nil
+	})
+	require.NoError(t, err)
+
+	// the views are dropped afterwards
+	_, err = st.db.Exec(`SELECT * FROM mempool_as_of`)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,91 +30,132 @@ func IsErrorMissingTransactions(err error) bool {
 	return ok
 }
 
-// TransactionQueryByTime implements the Query interface.
-type TransactionQueryByTime struct {
-	// Upper limit (inclusive) of transaction first_seen time
-	FirstSeenBeforeOrAt *time.Time
-	// Lower limit (exclusive) for last_removed time
-	LastRemovedAfter *time.Time
-}
-
-// Where implements the Query interface
-func (q TransactionQueryByTime) Where() string {
-	clause := []string{}
-	if q.FirstSeenBeforeOrAt != nil {
-		clause = append(clause, fmt.Sprintf(
-			"(first_seen <= %d)", q.FirstSeenBeforeOrAt.Unix(),
-		))
-	}
-	if q.LastRemovedAfter != nil {
-		clause = append(clause, fmt.Sprintf(
-			"((last_removed IS NULL) OR (last_removed > %d))",
-			q.LastRemovedAfter.Unix(),
-		))
-	}
-	return strings.Join(clause, " AND ")
-}
-
-// Order implements the Query interface. Empty by default.
-func (q TransactionQueryByTime) Order() string {
-	return ""
-}
-
-// Limit implements the Query interface. Empty by default.
-func (q TransactionQueryByTime) Limit() int {
-	return 0
+// transactionFields are the columns scanned by TxIterator
+var transactionFields = []string{
+	"id", "txid", "first_seen", "last_removed", "fee", "weight", "expired",
+	"heuristics", "op_return_outputs", "op_return_size", "witness_size",
+	"version", "locktime", "rbf", "inputs", "outputs", "sigop_cost", "output_value", "wtxid",
 }
 
 // TxIterator helps fetching transactions row-by-row.
 type TxIterator struct {
 	rows *sql.Rows
+	err  error
+	// destinations of columns selected after transactionFields
+	extra []interface{}
 }
 
-// Next returns the next transaction or nil.
+// Next returns the next transaction, or nil after the last transaction or an error (see Err).
 func (i *TxIterator) Next() *types.StoredTransaction {
-	if !i.rows.Next() {
+	if i.err != nil || !i.rows.Next() {
 		return nil
 	}
 
-	var txidBytes []byte
 	var firstSeenSeconds int64
 	var lastRemovedSeconds *int64
+	var expiredSeconds *int64
+	var heuristics *uint32
+	var opReturnOutputs, opReturnSize *int
+	var witnessSize *int
+	var version *int32
+	var lockTime *uint32
+	var rbf *bool
+	var inputs, outputs, sigOpCost *int
+	var outputValue *int64
+	var wtxid []byte
 	var tx types.StoredTransaction
-	err := i.rows.Scan(
+	dest := []interface{}{
 		&tx.DBID,
-		&txidBytes,
+		(*hashColumn)(&tx.TxID),
 		&firstSeenSeconds,
 		&lastRemovedSeconds,
 		&tx.Fee,
 		&tx.Weight,
-	)
+		&expiredSeconds,
+		&heuristics,
+		&opReturnOutputs,
+		&opReturnSize,
+		&witnessSize,
+		&version,
+		&lockTime,
+		&rbf,
+		&inputs,
+		&outputs,
+		&sigOpCost,
+		&outputValue,
+		&wtxid,
+	}
+	if err := i.rows.Scan(append(dest, i.extra...)...); err != nil {
+		i.err = errors.Wrap(err, "could not scan transaction")
+		return nil
+	}
 
-	tx.TxID = types.NewHashFromBytes(txidBytes)
 	tx.FirstSeen = time.Unix(firstSeenSeconds, 0).UTC()
 	if lastRemovedSeconds != nil {
 		lastRemoved := time.Unix(*lastRemovedSeconds, 0).UTC()
 		tx.LastRemoved = &lastRemoved
 	}
-
-	if err != nil {
-		panic(err)
+	if expiredSeconds != nil {
+		expired := time.Unix(*expiredSeconds, 0).UTC()
+		tx.Expired = &expired
+	}
+	if heuristics != nil {
+		flags := types.HeuristicFlags(*heuristics)
+		tx.Heuristics = &flags
+	}
+	if opReturnOutputs != nil && opReturnSize != nil {
+		tx.OpReturn = &types.OpReturnStats{
+			Outputs:     *opReturnOutputs,
+			PayloadSize: *opReturnSize,
+		}
+	}
+	tx.WitnessSize = witnessSize
+	if version != nil && lockTime != nil && rbf != nil {
+		tx.Signals = &types.TxSignals{
+			Version:  *version,
+			LockTime: *lockTime,
+			RBF:      *rbf,
+		}
+	}
+	if inputs != nil && outputs != nil && sigOpCost != nil {
+		tx.Counts = &types.TxCounts{
+			Inputs:    *inputs,
+			Outputs:   *outputs,
+			SigOpCost: *sigOpCost,
+		}
+	}
+	tx.OutputValue = outputValue
+	if wtxid != nil {
+		tx.WTxID = new(types.Hash32)
+		if err := (*hashColumn)(tx.WTxID).Scan(wtxid); err != nil {
+			i.err = errors.Wrap(err, "could not scan transaction")
+			return nil
+		}
 	}
 
 	return &tx
 }
 
+// Err returns the error that ended the iteration, if any
+func (i *TxIterator) Err() error {
+	if i.err != nil {
+		return i.err
+	}
+	return i.rows.Err()
+}
+
 // Close underlying cursor
 func (i *TxIterator) Close() error {
 	return i.rows.Close()
 }
 
 // Collect returns remaining transactions as list and closes cursor
-func (i TxIterator) Collect() (res []types.StoredTransaction) {
+func (i *TxIterator) Collect() (res []types.StoredTransaction, err error) {
 	defer i.Close()
 	for tx := i.Next(); tx != nil; tx = i.Next() {
 		res = append(res, *tx)
 	}
-	return res
+	return res, i.Err()
 }
 
 // InsertTransactions inserts transactions into storage.
@@ -122,49 +164,145 @@ func (s *Storage) InsertTransactions(txs []types.Transaction) (int64, error) {
 	// The firstSeen timestamp might not be to be monotonic, since transactions
 	// can be inserted from multiple sources (ZMQ and getrawmempool RPC).
 	// https://www.sqlite.org/lang_UPSERT.html
-	const insertTransaction string = `
+	// A transaction that is seen again after it expired has re-entered the mempool,
+	// so the `expired` mark is cleared.
+	// Values derived from the raw transaction (heuristics, OP_RETURN stats, witness size,
+	// signals, counts, output value) are kept once set.
+	// If the transaction arrives with a different witness (same txid, different wtxid), the weight,
+	// witness size and wtxid of the new version replace the stored ones, since it is the version
+	// relayed by the node now, and the replacement is recorded (see WitnessReplacements).
+	// The fee is the same for all versions, the txid commits to the inputs and outputs. If a source
+	// reports a different fee anyway, the first stored fee is kept and the report is recorded
+	// (see FeeUpdates).
+	const insertTransactionHead string = `
 	INSERT INTO
 	 	"transaction" 
-	 	(txid, first_seen, fee, weight) 
+	 	(txid, first_seen, fee, weight, heuristics, op_return_outputs, op_return_size, witness_size, version, locktime, rbf, inputs, outputs, sigop_cost, output_value, wtxid) 
 	VALUES
-		%s
+	`
+	const insertTransactionTail string = `
 	ON CONFLICT(txid) DO
 		UPDATE SET
-			first_seen = excluded.first_seen
+			first_seen = MIN(first_seen, excluded.first_seen),
+			expired = CASE WHEN excluded.first_seen >= expired THEN NULL ELSE expired END,
+			heuristics = COALESCE(heuristics, excluded.heuristics),
+			op_return_outputs = COALESCE(op_return_outputs, excluded.op_return_outputs),
+			op_return_size = COALESCE(op_return_size, excluded.op_return_size),
+			weight = CASE WHEN excluded.wtxid != wtxid THEN excluded.weight ELSE weight END,
+			witness_size = CASE WHEN excluded.wtxid != wtxid
+				THEN COALESCE(excluded.witness_size, witness_size)
+				ELSE COALESCE(witness_size, excluded.witness_size) END,
+			wtxid = COALESCE(excluded.wtxid, wtxid),
+			version = COALESCE(version, excluded.version),
+			locktime = COALESCE(locktime, excluded.locktime),
+			rbf = COALESCE(rbf, excluded.rbf),
+			inputs = COALESCE(inputs, excluded.inputs),
+			outputs = COALESCE(outputs, excluded.outputs),
+			sigop_cost = COALESCE(sigop_cost, excluded.sigop_cost),
+			output_value = COALESCE(output_value, excluded.output_value)
 		WHERE
-			first_seen > excluded.first_seen
+			(first_seen > excluded.first_seen) OR (excluded.first_seen >= expired) OR
+			(heuristics IS NULL AND excluded.heuristics IS NOT NULL) OR
+			(op_return_outputs IS NULL AND excluded.op_return_outputs IS NOT NULL) OR
+			(witness_size IS NULL AND excluded.witness_size IS NOT NULL) OR
+			(version IS NULL AND excluded.version IS NOT NULL) OR
+			(inputs IS NULL AND excluded.inputs IS NOT NULL) OR
+			(output_value IS NULL AND excluded.output_value IS NOT NULL) OR
+			(excluded.wtxid IS NOT NULL AND (wtxid IS NULL OR wtxid != excluded.wtxid))
 	`
 
-	values := []string{}
-	for _, tx := range txs {
-		values = append(values, fmt.Sprintf(
-			`(x'%s', %d, %d, %d)`,
-			tx.TxID, tx.FirstSeen.UTC().Unix(), tx.Fee, tx.Weight,
-		))
+	// the statement is built for every incoming transaction, so the buffer is reused
+	buf := getStatementBuffer()
+	defer putStatementBuffer(buf)
+	buf.WriteString(insertTransactionHead)
+
+	var withDetails, withRaw, withDust, whales, analyzed []types.Transaction
+	for i := range txs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		appendTransactionValues(buf, &txs[i])
+		if txs[i].Details != nil {
+			withDetails = append(withDetails, txs[i])
+		}
+		if txs[i].Raw != nil {
+			withRaw = append(withRaw, txs[i])
+		}
+		if txs[i].Dust != nil {
+			withDust = append(withDust, txs[i])
+		}
+		if txs[i].WhaleValue > 0 {
+			whales = append(whales, txs[i])
+		}
+		if txs[i].Analysis != nil {
+			analyzed = append(analyzed, txs[i])
+		}
 	}
+	buf.WriteString(insertTransactionTail)
 
-	smt := fmt.Sprintf(insertTransaction, strings.Join(values, ","))
-	res, err := s.db.Exec(smt)
+	// read before the upsert replaces them
+	stored, err := s.storedVersions(txs)
 	if err != nil {
-		return 0, errors.Errorf("could not insert transactions into table `transaction`: %s", err)
+		return 0, err
+	}
+
+	res, err := s.db.Exec(buf.String())
+	if err != nil {
+		return 0, dbError(err, "could not insert transactions into table `transaction`")
 	}
 	id, err := res.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
+
+	// the ids of new transactions are cached for the block that confirms them
+	if s.ids != nil {
+		txids := make([]types.Hash32, len(txs))
+		for i, tx := range txs {
+			txids[i] = tx.TxID
+		}
+		queried, err := s.queryTransactionDBIDs(txids)
+		if err != nil {
+			return 0, err
+		}
+		s.ids.add(queried)
+	}
+
+	if err := s.insertWitnessReplacements(txs, stored); err != nil {
+		return 0, err
+	}
+	if err := s.insertFeeUpdates(txs, stored); err != nil {
+		return 0, err
+	}
+	if err := s.insertTransactionDetails(withDetails); err != nil {
+		return 0, err
+	}
+	if err := s.insertTransactionRaw(withRaw); err != nil {
+		return 0, err
+	}
+	if err := s.insertTransactionDust(withDust); err != nil {
+		return 0, err
+	}
+	if err := s.insertTransactionWhales(whales); err != nil {
+		return 0, err
+	}
+	if err := s.insertTransactionAnalysis(analyzed); err != nil {
+		return 0, err
+	}
+
 	return id, nil
 }
 
-// InsertTransaction inserts a single transaction.
-// See InsertTransactions for more info.
-func (s *Storage) InsertTransaction(tx *types.Transaction) (int64, error) {
-	return s.InsertTransactions([]types.Transaction{*tx})
-}
+// queryTransactionDBIDs returns the database ids of the stored transactions of `txids`
+func (s *Storage) queryTransactionDBIDs(txids []types.Hash32) (map[types.Hash32]int64, error) {
+	dbidByTXID := map[types.Hash32]int64{}
+	if len(txids) == 0 {
+		return dbidByTXID, nil
+	}
 
-func (s *Storage) transactionDBIDs(txids []types.Hash32) (*[]int64, error) {
 	inClause := []string{}
 	for _, txid := range txids {
-		inClause = append(inClause, fmt.Sprintf("x'%s'", txid))
+		inClause = append(inClause, fmt.Sprintf("x'%x'", txid[:]))
 	}
 
 	selectTransactionIds := fmt.Sprintf(`
@@ -179,21 +317,42 @@ func (s *Storage) transactionDBIDs(txids []types.Hash32) (*[]int64, error) {
 
 	rows, err := s.db.Query(selectTransactionIds)
 	if err != nil {
-		return nil, errors.Errorf("error getting database ids from transactions: %s", err)
+		return nil, dbError(err, "error getting database ids from transactions")
 	}
-
-	dbidByTXID := map[types.Hash32]int64{}
+	defer rows.Close()
 
 	for rows.Next() {
 		var dbid int64
-		var txidBytes []byte
+		var txid types.Hash32
 
-		err := rows.Scan(&dbid, &txidBytes)
+		err := rows.Scan(&dbid, (*hashColumn)(&txid))
 		if err != nil {
-			return nil, errors.Errorf("error reading row: %s", err)
+			return nil, dbError(err, "error reading row")
 		}
 
-		dbidByTXID[types.NewHashFromBytes(txidBytes)] = dbid
+		dbidByTXID[txid] = dbid
+	}
+
+	return dbidByTXID, rows.Err()
+}
+
+// InsertTransaction inserts a single transaction.
+// See InsertTransactions for more info.
+func (s *Storage) InsertTransaction(tx *types.Transaction) (int64, error) {
+	return s.InsertTransactions([]types.Transaction{*tx})
+}
+
+// transactionDBIDs returns the database ids of `txids` in the same order, -1 for missing transactions.
+// Cached ids are not looked up.
+func (s *Storage) transactionDBIDs(txids []types.Hash32) (*[]int64, error) {
+	dbidByTXID, uncached := s.ids.get(txids)
+	queried, err := s.queryTransactionDBIDs(uncached)
+	if err != nil {
+		return nil, err
+	}
+	s.ids.add(queried)
+	for txid, dbid := range queried {
+		dbidByTXID[txid] = dbid
 	}
 
 	missing := 0
@@ -216,9 +375,9 @@ func (s *Storage) transactionDBIDs(txids []types.Hash32) (*[]int64, error) {
 
 // TransactionsInBlock returns transactions that are confirmed in block specified by `blockId`
 func (s *Storage) TransactionsInBlock(blockID int64) (*TxIterator, error) {
-	rows, err := s.db.Query(`
+	rows, err := s.db.Query(fmt.Sprintf(`
 		SELECT
-			id, txid, first_seen, last_removed, fee, weight
+			%s
 		FROM
 			"transaction"
 		WHERE
@@ -229,12 +388,57 @@ func (s *Storage) TransactionsInBlock(blockID int64) (*TxIterator, error) {
 					"transaction_block"
 				WHERE
 					block_id = ?
-			)`, blockID)
+			)`, strings.Join(transactionFields, ",")), blockID)
 	if err != nil {
-		return nil, errors.Errorf("error querying transactions: %s", err)
+		return nil, dbError(err, "error querying transactions")
 	}
 
-	return &TxIterator{rows}, nil
+	return &TxIterator{rows: rows}, nil
+}
+
+// GetBlockTransactions returns the stored transactions of the block with `hash` in block order,
+// starting at position `fromIndex`, at most `limit` (0 for no limit). BlockHeight and IndexInBlock
+// are set. Transactions that were not stored, like the coinbase, are skipped, so indexes can have gaps.
+// Returns types.ErrNotFound if the block is not stored.
+func (s *Storage) GetBlockTransactions(hash types.Hash32, fromIndex, limit int) ([]types.StoredTransaction, error) {
+	block, err := s.BlockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.Wrapf(types.ErrNotFound, "block %s", hash)
+	}
+
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			%s, tb.block_index
+		FROM
+			"transaction_block" tb
+		JOIN
+			"transaction" ON "transaction".id = tb.transaction_id
+		WHERE
+			tb.block_id = ? AND tb.block_index >= ?
+		ORDER BY
+			tb.block_index
+		LIMIT ?
+		`, strings.Join(transactionFields, ",")), block.DBID, fromIndex, limit)
+	if err != nil {
+		return nil, dbError(err, "error querying transactions of block")
+	}
+
+	var index int32
+	txIter := &TxIterator{rows: rows, extra: []interface{}{&index}}
+	defer txIter.Close()
+	res := []types.StoredTransaction{}
+	for tx := txIter.Next(); tx != nil; tx = txIter.Next() {
+		tx.BlockHeight = int32(block.Height)
+		tx.IndexInBlock = index
+		res = append(res, *tx)
+	}
+	return res, txIter.Err()
 }
 
 // TransactionDBIDsInBlock returns the transaction database ids of the transactions confirmed in block
@@ -264,45 +468,357 @@ func (s Storage) TransactionDBIDsInBlock(blockID int64) (res []int64, err error)
 
 // QueryTransactions returns transactions satisfying query
 func (s *Storage) QueryTransactions(q Query) (*TxIterator, error) {
-	var rows *sql.Rows
-	var err error
-
-	rows, err = s.db.Query(formatQuery(
-		[]string{"id", "txid", "first_seen", "last_removed", "fee", "weight"},
-		"transaction",
-		q,
-	))
-
+	query, args := formatQuery(transactionFields, "transaction", q)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error in transaction query %v", q)
 	}
 
-	return &TxIterator{rows}, nil
+	return &TxIterator{rows: rows}, nil
 }
 
 // TransactionByID returns the transaction with `txid`
 func (s *Storage) TransactionByID(txid types.Hash32) (*types.StoredTransaction, error) {
-	txIter, err := s.QueryTransactions(StaticQuery{
-		where: fmt.Sprintf("txid = x'%s'", txid),
-		limit: 1,
-	})
+	txIter, err := s.QueryTransactions(TransactionQuery{TxID: &txid, MaxResults: 1})
 	if err != nil {
 		return nil, err
 	}
 	defer txIter.Close()
-	return txIter.Next(), nil
+	tx := txIter.Next()
+	return tx, txIter.Err()
+}
+
+// TransactionBlock returns the most recent best-chain block that contains the transaction with
+// database id `dbid` and the index of the transaction in the block.
+// Returns nil if no such block is stored.
+func (s *Storage) TransactionBlock(dbid int64) (*types.StoredBlock, int, error) {
+	var blockID int64
+	var index int
+	err := s.db.QueryRow(`
+		SELECT
+			b.id, tb.block_index
+		FROM
+			"transaction_block" tb
+		JOIN
+			"block" b ON b.id = tb.block_id
+		WHERE
+			tb.transaction_id = ? AND b.in_best_chain = 1
+		ORDER BY
+			b.first_seen DESC
+		LIMIT 1
+		`, dbid,
+	).Scan(&blockID, &index)
+	if err == sql.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, dbError(err, "error querying block of transaction")
+	}
+
+	block, err := s.queryBlock(BlockQuery{DBID: &blockID, MaxResults: 1})
+	return block, index, err
+}
+
+// BestChainHeights returns the heights of the best-chain blocks containing the transactions with
+// the database ids `dbids`. Unconfirmed transactions are missing in the result.
+func (s *Storage) BestChainHeights(dbids []int64) (map[int64]uint32, error) {
+	res := map[int64]uint32{}
+	if len(dbids) == 0 {
+		return res, nil
+	}
+
+	values := make([]string, len(dbids))
+	for i, dbid := range dbids {
+		values[i] = strconv.FormatInt(dbid, 10)
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			tb.transaction_id, b.height
+		FROM
+			"transaction_block" tb
+		JOIN
+			"block" b ON b.id = tb.block_id
+		WHERE
+			tb.transaction_id IN (%s) AND b.in_best_chain = 1
+		`, strings.Join(values, ",")),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying block heights of transactions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dbid int64
+		var height uint32
+		if err := rows.Scan(&dbid, &height); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res[dbid] = height
+	}
+	return res, rows.Err()
+}
+
+// StoredTxIDs returns the subset of `txids` that is stored
+func (s *Storage) StoredTxIDs(txids []types.Hash32) (map[types.Hash32]bool, error) {
+	res := map[types.Hash32]bool{}
+	if len(txids) == 0 {
+		return res, nil
+	}
+
+	values := make([]string, len(txids))
+	for i, txid := range txids {
+		values[i] = fmt.Sprintf("x'%x'", txid[:])
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(
+		`SELECT txid FROM "transaction" WHERE txid IN (%s)`, strings.Join(values, ","),
+	))
+	if err != nil {
+		return nil, dbError(err, "error querying transactions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txid types.Hash32
+		if err := rows.Scan((*hashColumn)(&txid)); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		res[txid] = true
+	}
+	return res, rows.Err()
 }
 
 // NextTransactions returns transactions after `t`.
 // If multiple transactions exist for `t`, return transaction with higher `dbid`.
 func (s *Storage) NextTransactions(t time.Time, dbid int64, limit int) (*TxIterator, error) {
-	return s.QueryTransactions(StaticQuery{
+	return s.QueryTransactions(TransactionQuery{
 		// since there can be multiple txs with the same timestamp, we must use the dbid to query as well
-		where: fmt.Sprintf(
-			"(first_seen > %d) OR ((first_seen == %d) AND (id > %d))",
-			t.Unix(), t.Unix(), dbid,
-		),
-		order: "first_seen ASC, id ASC",
-		limit: limit,
+		FirstSeenAfter: &Cursor{Time: t, DBID: dbid},
+		OrderBy:        TxOrderFirstSeen,
+		MaxResults:     limit,
+	})
+}
+
+// NextExpiredTransactions returns transactions that expired after `t`, ordered by expiry time.
+// If multiple transactions expired at `t`, return transactions with higher `dbid`.
+func (s *Storage) NextExpiredTransactions(t time.Time, dbid int64, limit int) (*TxIterator, error) {
+	return s.QueryTransactions(TransactionQuery{
+		ExpiredAfter: &Cursor{Time: t, DBID: dbid},
+		OrderBy:      TxOrderExpired,
+		MaxResults:   limit,
+	})
+}
+
+// ExpiryCandidates returns transactions that are neither removed nor expired
+// and were first seen at least `window` before `now`.
+func (s *Storage) ExpiryCandidates(now time.Time, window time.Duration) (*TxIterator, error) {
+	firstSeenTo := now.Add(-window)
+	return s.QueryTransactions(TransactionQuery{
+		FirstSeenTo: &firstSeenTo,
+		Removed:     FilterFalse,
+		Expired:     FilterFalse,
+		OrderBy:     TxOrderFirstSeen,
 	})
 }
+
+// MarkExpired sets the `expired` time of the transactions with database ids `dbids`
+// to `first_seen + window`, which is when the node dropped them from its mempool, and records
+// types.DropExpiry as the reason.
+func (s *Storage) MarkExpired(dbids []int64, window time.Duration) error {
+	if len(dbids) == 0 {
+		return nil
+	}
+
+	inClause := []string{}
+	for _, dbid := range dbids {
+		inClause = append(inClause, fmt.Sprintf("%d", dbid))
+	}
+
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		fmt.Sprintf(`
+			UPDATE
+				"transaction"
+			SET
+				expired = first_seen + %d
+			WHERE
+				id IN (%s)
+			`, int64(window.Seconds()), strings.Join(inClause, ","),
+		),
+		fmt.Sprintf(`
+			INSERT OR REPLACE INTO
+				"transaction_drop" (transaction_id, time, reason)
+			SELECT
+				id, expired, '%s'
+			FROM
+				"transaction"
+			WHERE
+				id IN (%s)
+			`, types.DropExpiry, strings.Join(inClause, ","),
+		),
+	}
+	for _, statement := range statements {
+		if _, err := dbTx.Exec(statement); err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, "could not mark transactions as expired")
+		}
+	}
+	return dbTx.Commit()
+}
+
+// MarkDrops sets the `expired` time of the transactions with the database ids of `drops`
+// and records the reasons, see DropCauses
+func (s *Storage) MarkDrops(drops map[int64]types.TxDrop) error {
+	dbTx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	for dbid, drop := range drops {
+		if _, err := dbTx.Exec(`UPDATE "transaction" SET expired = ? WHERE id = ?`, drop.Time.Unix(), dbid); err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, "could not mark transaction as dropped")
+		}
+		if drop.Reason == types.DropUnknown {
+			_, err = dbTx.Exec(`DELETE FROM "transaction_drop" WHERE transaction_id = ?`, dbid)
+		} else {
+			_, err = dbTx.Exec(
+				`INSERT OR REPLACE INTO "transaction_drop" (transaction_id, time, reason) VALUES (?, ?, ?)`,
+				dbid, drop.Time.Unix(), drop.Reason,
+			)
+		}
+		if err != nil {
+			_ = dbTx.Rollback()
+			return dbError(err, "could not record drop reason")
+		}
+	}
+	return dbTx.Commit()
+}
+
+// DropCauses returns the transactions of `dbids` that left the mempool because of a conflicting
+// transaction, with the first-seen time of the earliest later conflicting transaction (types.DropReplaced)
+// or else the time of the earliest block with a conflicting transaction (types.DropConflicted).
+// Replacements are only found for transactions with stored details.
+func (s *Storage) DropCauses(dbids []int64) (map[int64]types.TxDrop, error) {
+	res := map[int64]types.TxDrop{}
+	if len(dbids) == 0 {
+		return res, nil
+	}
+
+	inClause := make([]string, len(dbids))
+	for i, dbid := range dbids {
+		inClause[i] = fmt.Sprintf("%d", dbid)
+	}
+
+	queries := []struct {
+		reason types.DropReason
+		query  string
+	}{
+		{types.DropReplaced, `
+			SELECT
+				a.transaction_id, MIN(t.first_seen)
+			FROM
+				"transaction_input" a
+			JOIN
+				"transaction_input" b ON b.prev_txid = a.prev_txid AND b.prev_index = a.prev_index
+			JOIN
+				"transaction" t ON t.id = b.transaction_id
+			JOIN
+				"transaction" o ON o.id = a.transaction_id
+			WHERE
+				a.transaction_id IN (%s) AND b.transaction_id != a.transaction_id AND t.first_seen >= o.first_seen
+			GROUP BY
+				a.transaction_id
+			`,
+		},
+		{types.DropConflicted, `
+			SELECT
+				transaction_id, MIN(time)
+			FROM
+				"double_spend"
+			WHERE
+				transaction_id IN (%s)
+			GROUP BY
+				transaction_id
+			`,
+		},
+	}
+	for _, q := range queries {
+		rows, err := s.db.Query(fmt.Sprintf(q.query, strings.Join(inClause, ",")))
+		if err != nil {
+			return nil, dbError(err, "error querying conflicting transactions")
+		}
+		for rows.Next() {
+			var dbid, seconds int64
+			if err := rows.Scan(&dbid, &seconds); err != nil {
+				_ = rows.Close()
+				return nil, dbError(err, "error reading row")
+			}
+			if _, ok := res[dbid]; !ok {
+				res[dbid] = types.TxDrop{Time: time.Unix(seconds, 0).UTC(), Reason: q.reason}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			_ = rows.Close()
+			return nil, dbError(err, "error reading rows")
+		}
+		_ = rows.Close()
+	}
+	return res, nil
+}
+
+// DropReason returns why the transaction with database id `dbid` left the mempool without a block,
+// types.DropUnknown if it is not recorded or the transaction was not dropped
+func (s *Storage) DropReason(dbid int64) (types.DropReason, error) {
+	var reason types.DropReason
+	err := s.db.QueryRow(`
+		SELECT
+			d.reason
+		FROM
+			"transaction_drop" d
+		JOIN
+			"transaction" t ON t.id = d.transaction_id AND t.expired = d.time
+		WHERE
+			d.transaction_id = ?
+		`, dbid,
+	).Scan(&reason)
+	if err == sql.ErrNoRows {
+		return types.DropUnknown, nil
+	}
+	if err != nil {
+		return types.DropUnknown, dbError(err, "error querying drop reason")
+	}
+	return reason, nil
+}
+
+// MarkDropped sets the `expired` time of the transactions `txids` that are neither removed nor
+// expired to `t`, for transactions that left the mempool of the node at an unknown time before `t`,
+// e.g. while the daemon was not running.
+func (s *Storage) MarkDropped(txids []types.Hash32, t time.Time) error {
+	if len(txids) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(txids))
+	for i, txid := range txids {
+		values[i] = fmt.Sprintf("x'%x'", txid[:])
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(`
+		UPDATE
+			"transaction"
+		SET
+			expired = ?
+		WHERE
+			txid IN (%s) AND last_removed IS NULL AND expired IS NULL
+		`, strings.Join(values, ","),
+	), t.Unix())
+	if err != nil {
+		return dbError(err, "could not mark transactions as dropped")
+	}
+
+	return nil
+}
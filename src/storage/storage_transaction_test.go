@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -14,7 +15,7 @@ import (
 
 func testQueryTransactions(t *testing.T, st *Storage, txs []types.Transaction) {
 	// test query all txs
-	txIter, err := st.QueryTransactions(StaticQuery{})
+	txIter, err := st.QueryTransactions(TransactionQuery{})
 	require.NoError(t, err)
 	defer txIter.Close()
 
@@ -28,6 +29,33 @@ func testQueryTransactions(t *testing.T, st *Storage, txs []types.Transaction) {
 
 	recoverTx = txIter.Next()
 	assert.Nil(t, recoverTx)
+	assert.NoError(t, txIter.Err())
+}
+
+func TestTxIterator_InvalidRow(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	_, err = st.InsertTransaction(NewTxAtOffset(10))
+	require.NoError(t, err)
+	_, err = st.db.Exec(`INSERT INTO "transaction" (txid, first_seen, fee, weight) VALUES (x'0011', 20, 1, 400)`)
+	require.NoError(t, err)
+
+	txIter, err := st.QueryTransactions(TransactionQuery{OrderBy: TxOrderID})
+	require.NoError(t, err)
+	require.NotNil(t, txIter.Next())
+	require.Nil(t, txIter.Next())
+	require.Nil(t, txIter.Next())
+	require.Error(t, txIter.Err())
+	require.NoError(t, txIter.Close())
+
+	txIter, err = st.QueryTransactions(TransactionQuery{})
+	require.NoError(t, err)
+	_, err = txIter.Collect()
+	require.Error(t, err)
 }
 
 func TestStorage_InsertTransaction(t *testing.T) {
@@ -47,6 +75,11 @@ func TestStorage_InsertTransaction(t *testing.T) {
 	}
 
 	testQueryTransactions(t, st, txs)
+
+	stored, err := st.StoredTxIDs([]types.Hash32{txs[0].TxID, test.GenerateHash32("unknown")})
+	require.NoError(t, err)
+	assert.Equal(t, map[types.Hash32]bool{txs[0].TxID: true}, stored)
+
 	err = st.Close()
 	require.NoError(t, err)
 
@@ -122,3 +155,48 @@ func TestStorage_NextTransactions(t *testing.T) {
 		}
 	}
 }
+
+func TestStorage_GetBlockTransactions(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txids := txidsFromStrings("tx-10", "tx-20", "tx-30", "tx-40")
+	// the coinbase and tx-40 are not stored
+	for _, o := range []int{10, 20, 30} {
+		_, err := st.InsertTransaction(NewTxAtOffset(o))
+		require.NoError(t, err)
+	}
+	block := types.Block{
+		Hash:      test.GenerateHash32("1"),
+		FirstSeen: GetTime(100),
+		TxIDs:     append([]types.Hash32{test.GenerateHash32("coinbase")}, txids...),
+		IsBest:    true,
+		Height:    7,
+	}
+	_, err = st.InsertBlock(&block)
+	require.NoError(t, err)
+
+	txs, err := st.GetBlockTransactions(block.Hash, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+	for i, tx := range txs {
+		assert.Equal(t, txids[i], tx.TxID)
+		assert.Equal(t, int32(i+1), tx.IndexInBlock)
+		assert.Equal(t, int32(7), tx.BlockHeight)
+	}
+
+	txs, err = st.GetBlockTransactions(block.Hash, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, txids[1], txs[0].TxID)
+
+	txs, err = st.GetBlockTransactions(block.Hash, 4, 0)
+	require.NoError(t, err)
+	assert.Empty(t, txs)
+
+	_, err = st.GetBlockTransactions(test.GenerateHash32("unknown"), 0, 0)
+	assert.Equal(t, types.ErrNotFound, errors.Cause(err))
+}
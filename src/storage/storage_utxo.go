@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertUTXODelta records the change of the UTXO set by the block `blockID`
+func (s *Storage) insertUTXODelta(blockID int64, d *types.UTXODelta) error {
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO "block_utxo_delta"
+			(block_id, created, spent, created_value, spent_value, unspendable_value, fees)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?)
+		`, blockID, d.Created, d.Spent, d.CreatedValue, d.SpentValue, d.UnspendableValue, d.Fees,
+	)
+	if err != nil {
+		return dbError(err, `error inserting to table "block_utxo_delta"`)
+	}
+	return nil
+}
+
+// UTXODeltas aggregates the UTXO set deltas of the best-chain blocks first seen in `from <= first_seen < to`
+// in buckets of length `interval`. Buckets without blocks with a known delta are omitted.
+func (s *Storage) UTXODeltas(from, to time.Time, interval time.Duration) ([]types.UTXOBucket, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			(b.first_seen - ?1) / ?2 AS bucket,
+			COUNT(*),
+			SUM(d.created),
+			SUM(d.spent),
+			SUM(d.created_value),
+			SUM(d.spent_value),
+			SUM(d.unspendable_value),
+			SUM(d.fees)
+		FROM
+			"block_utxo_delta" d
+			JOIN "block" b ON b.id = d.block_id
+		WHERE
+			b.in_best_chain = 1 AND b.first_seen >= ?1 AND b.first_seen < ?3
+		GROUP BY
+			bucket
+		ORDER BY
+			bucket ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying utxo deltas")
+	}
+	defer rows.Close()
+
+	res := []types.UTXOBucket{}
+	for rows.Next() {
+		var bucket int64
+		var u types.UTXOBucket
+		err := rows.Scan(
+			&bucket, &u.Blocks, &u.Created, &u.Spent,
+			&u.CreatedValue, &u.SpentValue, &u.UnspendableValue, &u.Fees,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		u.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		u.Net = u.UTXODelta.Net()
+		u.NetValue = u.UTXODelta.NetValue()
+		res = append(res, u)
+	}
+	return res, rows.Err()
+}
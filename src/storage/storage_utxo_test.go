@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_UTXODeltas(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	// blocks at 0, 100 and 200, the first without a known delta
+	blocks := chainedBlocks(0, "", []string{"x", "y", "z"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[1].UTXODelta = &types.UTXODelta{
+		Created: 10, Spent: 4, CreatedValue: 5000, SpentValue: 3000, UnspendableValue: 100, Fees: 200,
+	}
+	blocks[2].UTXODelta = &types.UTXODelta{
+		Created: 1, Spent: 3, CreatedValue: 1000, SpentValue: 1500, Fees: 10,
+	}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	buckets, err := st.UTXODeltas(GetTime(0), GetTime(1000), 150*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []types.UTXOBucket{
+		{
+			Time:      GetTime(0),
+			Blocks:    1,
+			UTXODelta: *blocks[1].UTXODelta,
+			Net:       6,
+			NetValue:  2000,
+		},
+		{
+			Time:      GetTime(150),
+			Blocks:    1,
+			UTXODelta: *blocks[2].UTXODelta,
+			Net:       -2,
+			NetValue:  -500,
+		},
+	}, buckets)
+
+	buckets, err = st.UTXODeltas(GetTime(0), GetTime(1000), time.Hour)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, 2, buckets[0].Blocks)
+	assert.Equal(t, 4, buckets[0].Net)
+	assert.Equal(t, int64(1500), buckets[0].NetValue)
+
+	_, err = st.UTXODeltas(GetTime(0), GetTime(1000), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// ValueFlow aggregates the output value of the transactions first seen in `from <= first_seen < to`
+// and of the transactions confirmed by best-chain blocks first seen in the range, in buckets of length
+// `interval`. The confirmed value is derived from the UTXO set delta of the blocks, so it includes
+// transactions that were never seen in the mempool. Buckets without either are omitted.
+func (s *Storage) ValueFlow(from, to time.Time, interval time.Duration) ([]types.ValueFlowBucket, error) {
+	seconds := int64(interval.Seconds())
+	if seconds < 1 {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			bucket, SUM(entered), SUM(entered_value), SUM(confirmed), SUM(confirmed_value)
+		FROM (
+			SELECT
+				(first_seen - ?1) / ?2 AS bucket,
+				COUNT(*) AS entered,
+				SUM(output_value) AS entered_value,
+				0 AS confirmed,
+				0 AS confirmed_value
+			FROM
+				"transaction"
+			WHERE
+				output_value IS NOT NULL AND first_seen >= ?1 AND first_seen < ?3
+			GROUP BY
+				bucket
+			UNION ALL
+			SELECT
+				(b.first_seen - ?1) / ?2 AS bucket,
+				0, 0,
+				SUM(MAX(COALESCE(b.tx_count, 1) - 1, 0)),
+				-- the spent value is the output value of the non-coinbase transactions plus the fees
+				SUM(d.spent_value - d.fees)
+			FROM
+				"block_utxo_delta" d
+				JOIN "block" b ON b.id = d.block_id
+			WHERE
+				b.in_best_chain = 1 AND b.first_seen >= ?1 AND b.first_seen < ?3
+			GROUP BY
+				bucket
+		)
+		GROUP BY
+			bucket
+		ORDER BY
+			bucket ASC
+		`, from.Unix(), seconds, to.Unix(),
+	)
+	if err != nil {
+		return nil, dbError(err, "error querying value flow")
+	}
+	defer rows.Close()
+
+	res := []types.ValueFlowBucket{}
+	for rows.Next() {
+		var bucket int64
+		var v types.ValueFlowBucket
+		err := rows.Scan(&bucket, &v.Entered.Transactions, &v.Entered.Value, &v.Confirmed.Transactions, &v.Confirmed.Value)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		v.Time = time.Unix(from.Unix()+bucket*seconds, 0).UTC()
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
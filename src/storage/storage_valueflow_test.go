@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_ValueFlow(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{}
+	for i, value := range []int64{1000, 2000, -1, 5000} {
+		tx := NewTxAtOffset(10 + i*60)
+		if value >= 0 {
+			outputValue := value
+			tx.OutputValue = &outputValue
+		}
+		txs = append(txs, *tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(txs[1].TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.OutputValue)
+	assert.Equal(t, int64(2000), *stored.OutputValue)
+
+	// blocks at 0 and 100, the first without a known delta
+	blocks := chainedBlocks(0, "", []string{"x", "y"})
+	for i := range blocks {
+		blocks[i].IsBest = true
+	}
+	blocks[1].TxCount = 3
+	blocks[1].UTXODelta = &types.UTXODelta{SpentValue: 9000, Fees: 500}
+	require.NoError(t, insertBlocks(st, blocks))
+
+	flow, err := st.ValueFlow(GetTime(0), GetTime(1000), 100*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []types.ValueFlowBucket{
+		{Time: GetTime(0), Entered: types.ValueFlowTotals{Transactions: 2, Value: 3000}},
+		{
+			Time:      GetTime(100),
+			Entered:   types.ValueFlowTotals{Transactions: 1, Value: 5000},
+			Confirmed: types.ValueFlowTotals{Transactions: 2, Value: 8500},
+		},
+	}, flow)
+
+	_, err = st.ValueFlow(GetTime(0), GetTime(1000), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// insertTransactionWhales stores the output values of `txs` above the whale threshold.
+// Values of transactions that are already stored are not changed.
+func (s *Storage) insertTransactionWhales(txs []types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	txids := make([]types.Hash32, len(txs))
+	for i, tx := range txs {
+		txids[i] = tx.TxID
+	}
+	dbids, err := s.transactionDBIDs(txids)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	for i, tx := range txs {
+		dbid := (*dbids)[i]
+		if dbid < 0 {
+			continue
+		}
+		values = append(values, "(?, ?)")
+		args = append(args, dbid, tx.WhaleValue)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`
+		INSERT OR IGNORE INTO
+			"transaction_whale"
+			(transaction_id, value)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return dbError(err, "could not insert into table `transaction_whale`")
+	}
+	return nil
+}
+
+// WhaleQuery selects transactions above the whale threshold matching all set fields
+type WhaleQuery struct {
+	// Inclusive range of the first-seen time
+	FirstSeenFrom *time.Time
+	FirstSeenTo   *time.Time
+	// Minimum total output value in satoshis
+	MinValue int64
+	// Transactions in a block of the best chain
+	Confirmed BoolFilter
+	// Maximum number of transactions, 0 for no limit
+	MaxResults int
+}
+
+// WhaleTransactions returns the transactions above the whale threshold matching `q`, most recent first
+func (s *Storage) WhaleTransactions(q WhaleQuery) ([]types.WhaleTransaction, error) {
+	var c conditions
+	if q.FirstSeenFrom != nil {
+		c.add("t.first_seen >= ?", q.FirstSeenFrom.Unix())
+	}
+	if q.FirstSeenTo != nil {
+		c.add("t.first_seen <= ?", q.FirstSeenTo.Unix())
+	}
+	if q.MinValue > 0 {
+		c.add("w.value >= ?", q.MinValue)
+	}
+	c.addBool(q.Confirmed, "cb.height IS NOT NULL")
+
+	query := `
+		SELECT
+			t.txid, t.first_seen, w.value, t.fee, t.weight,
+			cb.hash, cb.height, cb.first_seen
+		FROM
+			"transaction_whale" w
+			JOIN "transaction" t ON t.id = w.transaction_id
+			LEFT JOIN (
+				SELECT tb.transaction_id, b.hash, b.height, b.first_seen
+				FROM "transaction_block" tb JOIN "block" b ON b.id = tb.block_id
+				WHERE b.in_best_chain = 1
+			) cb ON cb.transaction_id = t.id`
+	where, args := c.where()
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY t.first_seen DESC, t.id DESC"
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying whale transactions")
+	}
+	defer rows.Close()
+
+	res := []types.WhaleTransaction{}
+	for rows.Next() {
+		var firstSeen int64
+		var blockHash []byte
+		var blockHeight *uint32
+		var blockFirstSeen *int64
+		var w types.WhaleTransaction
+		err := rows.Scan(
+			(*hashColumn)(&w.TxID), &firstSeen, &w.Value, &w.Fee, &w.Weight,
+			&blockHash, &blockHeight, &blockFirstSeen,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		w.FirstSeen = time.Unix(firstSeen, 0).UTC()
+		if blockHeight != nil && blockFirstSeen != nil {
+			w.Block = &types.WhaleConfirmation{
+				Height:    *blockHeight,
+				FirstSeen: time.Unix(*blockFirstSeen, 0).UTC(),
+			}
+			if err := (*hashColumn)(&w.Block.Hash).Scan(blockHash); err != nil {
+				return nil, dbError(err, "error reading row")
+			}
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_WhaleTransactions(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	txs := []types.Transaction{}
+	for i, value := range []int64{200e8, 0, 1000e8, 500e8} {
+		tx := NewTxAtOffset(10 + i*10)
+		tx.WhaleValue = value
+		txs = append(txs, *tx)
+	}
+	_, err = st.InsertTransactions(txs)
+	require.NoError(t, err)
+
+	block := chainedBlocks(7, "", []string{"x"})[0]
+	block.FirstSeen = GetTime(100)
+	block.IsBest = true
+	block.TxIDs = []types.Hash32{txs[0].TxID, txs[2].TxID}
+	require.NoError(t, insertBlocks(st, []types.Block{block}))
+
+	whales, err := st.WhaleTransactions(WhaleQuery{})
+	require.NoError(t, err)
+	require.Len(t, whales, 3)
+	assert.Equal(t, txs[3].TxID, whales[0].TxID)
+	assert.Nil(t, whales[0].Block)
+	assert.Equal(t, types.WhaleTransaction{
+		TxID:      txs[2].TxID,
+		FirstSeen: txs[2].FirstSeen,
+		Value:     1000e8,
+		Fee:       txs[2].Fee,
+		Weight:    txs[2].Weight,
+		Block:     &types.WhaleConfirmation{Hash: block.Hash, Height: 7, FirstSeen: GetTime(100)},
+	}, whales[1])
+	assert.Equal(t, txs[0].TxID, whales[2].TxID)
+
+	whales, err = st.WhaleTransactions(WhaleQuery{MinValue: 500e8, Confirmed: FilterTrue})
+	require.NoError(t, err)
+	require.Len(t, whales, 1)
+	assert.Equal(t, txs[2].TxID, whales[0].TxID)
+
+	from, to := GetTime(10), GetTime(30)
+	whales, err = st.WhaleTransactions(WhaleQuery{FirstSeenFrom: &from, FirstSeenTo: &to, Confirmed: FilterFalse})
+	require.NoError(t, err)
+	assert.Len(t, whales, 0)
+
+	whales, err = st.WhaleTransactions(WhaleQuery{MaxResults: 1})
+	require.NoError(t, err)
+	assert.Len(t, whales, 1)
+}
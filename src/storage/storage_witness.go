@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// MaxSeriesPoints limits the number of points of time series computed from the mempool
+const MaxSeriesPoints = 1000
+
+// WitnessHeavySeries returns the share of witness-heavy transactions (see types.Transaction.IsWitnessHeavy)
+// in the mempool at the times `from, from+interval, ..., to`.
+func (s *Storage) WitnessHeavySeries(from, to time.Time, interval time.Duration) ([]types.WitnessHeavyPoint, error) {
+	if interval < time.Second {
+		return nil, errors.Errorf("invalid interval %s", interval)
+	}
+	if n := int(to.Sub(from)/interval) + 1; n > MaxSeriesPoints {
+		return nil, errors.Errorf("too many points (%d > %d)", n, MaxSeriesPoints)
+	}
+
+	const query = `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM((weight + 3) / 4), 0),
+			COALESCE(SUM(witness_size > weight * %[1]f), 0),
+			COALESCE(SUM(CASE WHEN witness_size > weight * %[1]f THEN (weight + 3) / 4 ELSE 0 END), 0)
+		FROM
+			"transaction"
+		WHERE
+			%[2]s
+	`
+
+	res := []types.WitnessHeavyPoint{}
+	for t := from; !t.After(to); t = t.Add(interval) {
+		t := t
+		where, args := TransactionQuery{InMempoolAt: &t}.Where()
+
+		p := types.WitnessHeavyPoint{Time: t.UTC()}
+		err := s.db.QueryRow(fmt.Sprintf(query, types.WitnessHeavyShare, where), args...).Scan(
+			&p.Transactions,
+			&p.VSize,
+			&p.WitnessHeavyTransactions,
+			&p.WitnessHeavyVSize,
+		)
+		if err != nil {
+			return nil, dbError(err, "error querying witness-heavy share")
+		}
+		res = append(res, p)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_WitnessHeavySeries(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	small, large := 10, 1000
+	regular := NewTxAtOffset(0)
+	regular.Weight = 800
+	regular.WitnessSize = &small
+	heavy := NewTxAtOffset(100)
+	heavy.Weight = 1080
+	heavy.WitnessSize = &large
+	assert.False(t, regular.IsWitnessHeavy())
+	assert.True(t, heavy.IsWitnessHeavy())
+
+	_, err = st.InsertTransactions([]types.Transaction{*regular, *heavy})
+	require.NoError(t, err)
+
+	series, err := st.WitnessHeavySeries(GetTime(50), GetTime(150), 100*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []types.WitnessHeavyPoint{
+		{Time: GetTime(50), Transactions: 1, VSize: 200},
+		{
+			Time:                     GetTime(150),
+			Transactions:             2,
+			VSize:                    470,
+			WitnessHeavyTransactions: 1,
+			WitnessHeavyVSize:        270,
+		},
+	}, series)
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+// storedVersion is the version of a stored transaction that later versions are compared to
+type storedVersion struct {
+	dbid int64
+	// nil if the stored version has no witness
+	wtxid  *types.Hash32
+	weight int
+	// nil if the fee is not stored
+	fee *uint64
+}
+
+// storedVersions returns the stored versions of the transactions of `txs` by txid.
+// Transactions that are not stored are omitted.
+func (s *Storage) storedVersions(txs []types.Transaction) (map[types.Hash32]storedVersion, error) {
+	res := map[types.Hash32]storedVersion{}
+	if len(txs) == 0 {
+		return res, nil
+	}
+	inClause := make([]string, len(txs))
+	for i, tx := range txs {
+		inClause[i] = fmt.Sprintf("x'%x'", tx.TxID[:])
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT
+			id, txid, wtxid, COALESCE(weight, 0), fee
+		FROM
+			"transaction"
+		WHERE
+			txid IN (%s)
+		`, strings.Join(inClause, ","),
+	))
+	if err != nil {
+		return nil, dbError(err, "error querying stored transactions")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var txid types.Hash32
+		var wtxid []byte
+		var v storedVersion
+		if err := rows.Scan(&v.dbid, (*hashColumn)(&txid), &wtxid, &v.weight, &v.fee); err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		if wtxid != nil {
+			v.wtxid = new(types.Hash32)
+			if err := (*hashColumn)(v.wtxid).Scan(wtxid); err != nil {
+				return nil, dbError(err, "error reading row")
+			}
+		}
+		res[txid] = v
+	}
+	return res, rows.Err()
+}
+
+// insertWitnessReplacements records the transactions of `txs` whose wtxid differs from the `stored` one
+func (s *Storage) insertWitnessReplacements(txs []types.Transaction, stored map[types.Hash32]storedVersion) error {
+	values := []string{}
+	args := []interface{}{}
+	for _, tx := range txs {
+		old, ok := stored[tx.TxID]
+		if !ok || tx.WTxID == nil || old.wtxid == nil || *tx.WTxID == *old.wtxid {
+			continue
+		}
+		values = append(values, "(?, ?, ?, ?, ?, ?)")
+		args = append(args, old.dbid, tx.FirstSeen.Unix(), old.wtxid[:], tx.WTxID[:], old.weight, tx.Weight)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	_, err := s.db.Exec(fmt.Sprintf(`
+		INSERT INTO
+			"transaction_witness_replacement"
+			(transaction_id, time, old_wtxid, new_wtxid, old_weight, new_weight)
+		VALUES
+			%s
+		`, strings.Join(values, ","),
+	), args...)
+	if err != nil {
+		return dbError(err, "could not insert into table `transaction_witness_replacement`")
+	}
+	return nil
+}
+
+// WitnessReplacementQuery selects witness replacements matching all set fields
+type WitnessReplacementQuery struct {
+	// Replacements of the transaction with the txid
+	TxID *types.Hash32
+	// Inclusive range of the first-seen time of the new version
+	From *time.Time
+	To   *time.Time
+	// Maximum number of results, 0 for no limit
+	MaxResults int
+}
+
+// WitnessReplacements returns the witness replacements matching `q`, most recent first
+func (s *Storage) WitnessReplacements(q WitnessReplacementQuery) ([]types.WitnessReplacement, error) {
+	var c conditions
+	if q.TxID != nil {
+		c.add("t.txid = ?", q.TxID[:])
+	}
+	if q.From != nil {
+		c.add("r.time >= ?", q.From.Unix())
+	}
+	if q.To != nil {
+		c.add("r.time <= ?", q.To.Unix())
+	}
+
+	query := `
+		SELECT
+			t.txid, r.time, r.old_wtxid, r.new_wtxid, r.old_weight, r.new_weight
+		FROM
+			"transaction_witness_replacement" r
+			JOIN "transaction" t ON t.id = r.transaction_id`
+	where, args := c.where()
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY r.time DESC, r.rowid DESC"
+	if q.MaxResults > 0 {
+		query += fmt.Sprintf(" LIMIT %d", q.MaxResults)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, dbError(err, "error querying witness replacements")
+	}
+	defer rows.Close()
+
+	res := []types.WitnessReplacement{}
+	for rows.Next() {
+		var t int64
+		var r types.WitnessReplacement
+		err := rows.Scan(
+			(*hashColumn)(&r.TxID), &t, (*hashColumn)(&r.OldWTxID), (*hashColumn)(&r.NewWTxID), &r.OldWeight, &r.NewWeight,
+		)
+		if err != nil {
+			return nil, dbError(err, "error reading row")
+		}
+		r.Time = time.Unix(t, 0).UTC()
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
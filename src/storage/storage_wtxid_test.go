@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/0xb10c/bademeister-go/src/test"
+	"github.com/0xb10c/bademeister-go/src/types"
+)
+
+func TestStorage_WitnessReplacements(t *testing.T) {
+	test.SkipIfShort(t)
+
+	st, err := NewTestStorage()
+	require.NoError(t, err)
+	defer st.Close()
+
+	first, second := test.GenerateHash32("witness-1"), test.GenerateHash32("witness-2")
+	witnessSize := 100
+	tx := NewTxAtOffset(0)
+	tx.WTxID = &first
+	tx.WitnessSize = &witnessSize
+	_, err = st.InsertTransaction(tx)
+	require.NoError(t, err)
+
+	// the same version again, and a version with unknown witness
+	again := *tx
+	again.FirstSeen = GetTime(10)
+	_, err = st.InsertTransaction(&again)
+	require.NoError(t, err)
+	unknown := *tx
+	unknown.WTxID = nil
+	unknown.Weight = 1
+	_, err = st.InsertTransaction(&unknown)
+	require.NoError(t, err)
+
+	replacements, err := st.WitnessReplacements(WitnessReplacementQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, replacements)
+
+	// a smaller witness
+	smallerSize := 50
+	replaced := *tx
+	replaced.FirstSeen = GetTime(20)
+	replaced.WTxID = &second
+	replaced.Weight = tx.Weight - 50
+	replaced.WitnessSize = &smallerSize
+	_, err = st.InsertTransaction(&replaced)
+	require.NoError(t, err)
+
+	stored, err := st.TransactionByID(tx.TxID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.WTxID)
+	assert.Equal(t, second, *stored.WTxID)
+	assert.Equal(t, replaced.Weight, stored.Weight)
+	assert.Equal(t, smallerSize, *stored.WitnessSize)
+	assert.Equal(t, tx.Fee, stored.Fee)
+	assert.Equal(t, GetTime(0).UTC(), stored.FirstSeen)
+
+	replacements, err = st.WitnessReplacements(WitnessReplacementQuery{TxID: &tx.TxID})
+	require.NoError(t, err)
+	assert.Equal(t, []types.WitnessReplacement{{
+		TxID:      tx.TxID,
+		Time:      GetTime(20).UTC(),
+		OldWTxID:  first,
+		NewWTxID:  second,
+		OldWeight: tx.Weight,
+		NewWeight: replaced.Weight,
+	}}, replacements)
+
+	// before the range
+	from := GetTime(30)
+	replacements, err = st.WitnessReplacements(WitnessReplacementQuery{From: &from})
+	require.NoError(t, err)
+	assert.Empty(t, replacements)
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AnalysisResult is a value computed by an analyzer (see package analysis) for a transaction or block
+type AnalysisResult struct {
+	// Name of the analyzer, set by analysis.Runner
+	Analyzer string `json:"analyzer"`
+	// Name of the value, an analyzer can compute several
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+	// Further data as JSON document, optional
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// StoredAnalysisResult is an AnalysisResult with the transaction or block it was computed for
+type StoredAnalysisResult struct {
+	AnalysisResult
+	// First-seen time of the transaction or block
+	Time time.Time `json:"time"`
+	// Txid of the transaction, nil for results of blocks
+	TxID *Hash32 `json:"txid,omitempty"`
+	// Hash of the block, nil for results of transactions
+	Block *Hash32 `json:"block,omitempty"`
+}
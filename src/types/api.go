@@ -0,0 +1,284 @@
+package types
+
+import (
+	"sort"
+	"time"
+)
+
+// MempoolSnapshot is the reconstructed mempool at a point in time
+type MempoolSnapshot struct {
+	Time         time.Time     `json:"time"`
+	Transactions []Transaction `json:"transactions"`
+	// Set for the mempool of a node with an EvictionPolicy: the observed transactions
+	// that the node evicted or did not accept, and its minimum feerate in sat/vB
+	Evicted    []Hash32 `json:"evicted,omitempty"`
+	MinFeerate *float64 `json:"minFeerate,omitempty"`
+}
+
+// MempoolEvent describes a change of the mempool
+type MempoolEvent struct {
+	Time time.Time `json:"time"`
+	// One of the storage.EventType values
+	Type string `json:"type"`
+	// Set for block events
+	Block              *Block        `json:"block,omitempty"`
+	AddTransactions    []Transaction `json:"addTransactions"`
+	RemoveTransactions []Hash32      `json:"removeTransactions"`
+}
+
+// SearchResult is returned by the search endpoint
+type SearchResult struct {
+	Transactions []Transaction `json:"transactions"`
+	Blocks       []Block       `json:"blocks"`
+}
+
+// TransactionList is a page of transactions ordered by first-seen time
+type TransactionList struct {
+	Transactions []Transaction `json:"transactions"`
+	// Value of the `after` parameter for the next page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// FeerateBucket aggregates the transactions with a feerate of at least Feerate
+// and less than Feerate+1 sat/vB
+type FeerateBucket struct {
+	Feerate      int   `json:"feerate"`
+	Transactions int   `json:"transactions"`
+	Weight       int64 `json:"weight"`
+}
+
+// MempoolSummary aggregates the mempool at a point in time
+type MempoolSummary struct {
+	Time         time.Time `json:"time"`
+	Transactions int       `json:"transactions"`
+	Weight       int64     `json:"weight"`
+	Fees         uint64    `json:"fees"`
+	// Buckets with transactions, ordered by feerate
+	Feerates []FeerateBucket `json:"feerates"`
+}
+
+// NewMempoolSummary aggregates the mempool `txs` at time `t`
+func NewMempoolSummary(t time.Time, txs []Transaction) *MempoolSummary {
+	buckets := map[int]*FeerateBucket{}
+	res := &MempoolSummary{Time: t, Feerates: []FeerateBucket{}}
+	for i := range txs {
+		tx := &txs[i]
+		res.Transactions++
+		res.Weight += int64(tx.Weight)
+		res.Fees += tx.Fee
+
+		feerate := tx.FeerateBucket()
+		bucket, ok := buckets[feerate]
+		if !ok {
+			bucket = &FeerateBucket{Feerate: feerate}
+			buckets[feerate] = bucket
+		}
+		bucket.Transactions++
+		bucket.Weight += int64(tx.Weight)
+	}
+	for _, bucket := range buckets {
+		res.Feerates = append(res.Feerates, *bucket)
+	}
+	SortFeerateBuckets(res.Feerates)
+	return res
+}
+
+// SortFeerateBuckets sorts `buckets` by feerate
+func SortFeerateBuckets(buckets []FeerateBucket) {
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Feerate < buckets[j].Feerate
+	})
+}
+
+// FeerateHistogram is a compact form of MempoolSummary.
+// The buckets are given as arrays of equal length instead of objects.
+type FeerateHistogram struct {
+	Time         time.Time `json:"time"`
+	Transactions int       `json:"transactions"`
+	Weight       int64     `json:"weight"`
+	Fees         uint64    `json:"fees"`
+	// Lower bound of the bucket in sat/vB, the number of transactions and their weight
+	Feerates []int   `json:"feerates"`
+	Counts   []int   `json:"counts"`
+	Weights  []int64 `json:"weights"`
+}
+
+// Histogram returns the compact form of the summary
+func (s *MempoolSummary) Histogram() *FeerateHistogram {
+	res := &FeerateHistogram{
+		Time:         s.Time,
+		Transactions: s.Transactions,
+		Weight:       s.Weight,
+		Fees:         s.Fees,
+		Feerates:     make([]int, len(s.Feerates)),
+		Counts:       make([]int, len(s.Feerates)),
+		Weights:      make([]int64, len(s.Feerates)),
+	}
+	for i, bucket := range s.Feerates {
+		res.Feerates[i] = bucket.Feerate
+		res.Counts[i] = bucket.Transactions
+		res.Weights[i] = bucket.Weight
+	}
+	return res
+}
+
+// TxStatusType is the state of a transaction in TxStatus
+type TxStatusType string
+
+// Transaction states
+const (
+	// TxUnseen is a transaction that was not recorded
+	TxUnseen TxStatusType = "unseen"
+	// TxInMempool is a transaction that is in the mempool
+	TxInMempool TxStatusType = "mempool"
+	// TxConfirmed is a transaction in a best-chain block
+	TxConfirmed TxStatusType = "confirmed"
+	// TxReplaced is a transaction with a conflicting transaction that was seen later
+	TxReplaced TxStatusType = "replaced"
+	// TxRemoved is a transaction that left the mempool for another reason, see TxStatus.Reason
+	TxRemoved TxStatusType = "removed"
+)
+
+// TxStatus is the current state of a transaction.
+// Only the fields that apply to the state are set.
+type TxStatus struct {
+	TxID      Hash32       `json:"txid"`
+	Status    TxStatusType `json:"status"`
+	FirstSeen *time.Time   `json:"firstSeen,omitempty"`
+	// Feerate in sat/vB
+	Feerate     *float64 `json:"feerate,omitempty"`
+	BlockHash   *Hash32  `json:"blockHash,omitempty"`
+	BlockHeight *int     `json:"blockHeight,omitempty"`
+	BlockIndex  *int     `json:"blockIndex,omitempty"`
+	// Number of best-chain blocks from BlockHash to the tip
+	Confirmations *int       `json:"confirmations,omitempty"`
+	ReplacedBy    *Hash32    `json:"replacedBy,omitempty"`
+	Removed       *time.Time `json:"removed,omitempty"`
+	// Why a removed transaction left the mempool, empty if unknown
+	Reason DropReason `json:"reason,omitempty"`
+}
+
+// TxAggregate aggregates the transactions first seen in a time bucket
+type TxAggregate struct {
+	// Start of the bucket
+	Time         time.Time `json:"time"`
+	Transactions int64     `json:"transactions"`
+	// Sum of the virtual sizes in vB
+	VSize int64 `json:"vsize"`
+	// Sum of the fees in sat
+	Fees uint64 `json:"fees"`
+	// Median feerate in sat/vB, nil if no transaction has a known weight
+	MedianFeerate *float64 `json:"medianFeerate"`
+	// Number of transactions with known TxSignals, the following counts only include these
+	SignalsKnown int64 `json:"signalsKnown"`
+	// Number of transactions with version 2 or higher
+	Version2 int64 `json:"version2"`
+	// Number of transactions with a non-zero nLockTime
+	LockTime int64 `json:"lockTime"`
+	// Number of transactions explicitly signaling replaceability (BIP125)
+	RBF int64 `json:"rbf"`
+	// RBF / SignalsKnown, nil if no transaction has known signals
+	RBFShare *float64 `json:"rbfShare"`
+}
+
+// TxAggregateSeries is a time series of TxAggregate with its resolution
+type TxAggregateSeries struct {
+	// Length of the buckets in seconds
+	Resolution int64         `json:"resolution"`
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	Points     []TxAggregate `json:"points"`
+}
+
+// StuckAgeBounds are the lower bounds of the age buckets of StuckReport above the minimum age
+var StuckAgeBounds = []time.Duration{
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// StuckAgeBucket aggregates the stuck transactions with an age of at least MinAge
+// and less than the MinAge of the next bucket
+type StuckAgeBucket struct {
+	// Lower bound of the age in seconds
+	MinAge       int64  `json:"minAge"`
+	Transactions int    `json:"transactions"`
+	Weight       int64  `json:"weight"`
+	Fees         uint64 `json:"fees"`
+}
+
+// StuckReport lists the transactions that are older than MinAge and have a feerate
+// below the lowest feerate of the next block
+type StuckReport struct {
+	Time time.Time `json:"time"`
+	// Minimum age in seconds
+	MinAge int64 `json:"minAge"`
+	// Lowest feerate in sat/vB of the transactions with the highest feerates that fill a block,
+	// zero if the whole mempool fits into a block, or the transaction with the highest feerate does not
+	Threshold float64 `json:"threshold"`
+	// Totals by age, see StuckAgeBounds
+	Buckets []StuckAgeBucket `json:"buckets"`
+	// Stuck transactions, oldest first, at most `limit`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// NewStuckReport returns the stuck transactions of the mempool `txs` at time `t` with an age
+// of at least `minAge`. At most `limit` transactions are listed, the buckets count all.
+func NewStuckReport(t time.Time, txs []Transaction, minAge time.Duration, limit int) *StuckReport {
+	res := &StuckReport{
+		Time:         t,
+		MinAge:       int64(minAge.Seconds()),
+		Buckets:      []StuckAgeBucket{},
+		Transactions: []Transaction{},
+	}
+
+	next := TopByFeerate(txs, MaxBlockWeight)
+	if len(next) == len(txs) || len(next) == 0 {
+		// nothing competes for block space, or the transaction with the highest feerate is
+		// larger than a block
+		return res
+	}
+	lowest := next[len(next)-1]
+	res.Threshold = lowest.Feerate()
+
+	// the first bucket starts at minAge
+	res.Buckets = append(res.Buckets, StuckAgeBucket{MinAge: res.MinAge})
+	for _, bound := range StuckAgeBounds {
+		if bound > minAge {
+			res.Buckets = append(res.Buckets, StuckAgeBucket{MinAge: int64(bound.Seconds())})
+		}
+	}
+
+	var stuck []Transaction
+	for i := range txs {
+		tx := &txs[i]
+		age := t.Sub(tx.FirstSeen)
+		// compare fee/weight without floating point, transactions with the feerate of the
+		// lowest are not stuck
+		if age < minAge || lowest.Fee*uint64(tx.Weight) <= tx.Fee*uint64(lowest.Weight) {
+			continue
+		}
+		stuck = append(stuck, *tx)
+
+		j := len(res.Buckets) - 1
+		for age < time.Duration(res.Buckets[j].MinAge)*time.Second {
+			j--
+		}
+		res.Buckets[j].Transactions++
+		res.Buckets[j].Weight += int64(tx.Weight)
+		res.Buckets[j].Fees += tx.Fee
+	}
+
+	sort.Slice(stuck, func(i, j int) bool {
+		return stuck[i].FirstSeen.Before(stuck[j].FirstSeen)
+	})
+	if len(stuck) > limit {
+		stuck = stuck[:limit]
+	}
+	if stuck != nil {
+		res.Transactions = stuck
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStuckReport(t *testing.T) {
+	now := time.Unix(1000000, 0).UTC()
+	tx := func(id byte, age time.Duration, feerate uint64) Transaction {
+		return Transaction{
+			TxID:      Hash32{id},
+			FirstSeen: now.Add(-age),
+			Fee:       feerate * 1000,
+			Weight:    4000,
+		}
+	}
+
+	// one block of 10 sat/vB, followed by lower feerates
+	var txs []Transaction
+	for i := 0; i < MaxBlockWeight/4000; i++ {
+		txs = append(txs, tx(0, time.Minute, 10))
+		txs[i].TxID[1], txs[i].TxID[2] = byte(i), byte(i>>8)
+	}
+	txs = append(txs,
+		tx(1, 2*time.Hour, 1),
+		tx(2, 30*time.Hour, 2),
+		tx(3, 8*24*time.Hour, 1),
+		// young
+		tx(4, time.Minute, 1),
+	)
+
+	report := NewStuckReport(now, txs, time.Hour, 2)
+	assert.Equal(t, 10.0, report.Threshold)
+	assert.Equal(t, []StuckAgeBucket{
+		{MinAge: 3600, Transactions: 1, Weight: 4000, Fees: 1000},
+		{MinAge: 6 * 3600},
+		{MinAge: 24 * 3600, Transactions: 1, Weight: 4000, Fees: 2000},
+		{MinAge: 72 * 3600},
+		{MinAge: 7 * 24 * 3600, Transactions: 1, Weight: 4000, Fees: 1000},
+	}, report.Buckets)
+	// oldest first
+	assert.Equal(t, []Transaction{txs[len(txs)-2], txs[len(txs)-3]}, report.Transactions)
+
+	report = NewStuckReport(now, txs, 48*time.Hour, 10)
+	assert.Equal(t, []int64{48 * 3600, 72 * 3600, 7 * 24 * 3600}, []int64{
+		report.Buckets[0].MinAge, report.Buckets[1].MinAge, report.Buckets[2].MinAge,
+	})
+	assert.Len(t, report.Transactions, 1)
+
+	// transactions with the feerate of the last transaction of the block are not stuck
+	tie := tx(0xff, 2*time.Hour, 10)
+	report = NewStuckReport(now, append(txs[:len(txs):len(txs)], tie), time.Hour, 10)
+	assert.Equal(t, 10.0, report.Threshold)
+	assert.Len(t, report.Transactions, 3)
+
+	// no transaction fits into a block
+	report = NewStuckReport(now, []Transaction{{Fee: 1000, Weight: MaxBlockWeight + 4}}, time.Hour, 10)
+	assert.Equal(t, 0.0, report.Threshold)
+	assert.Len(t, report.Transactions, 0)
+
+	// the whole mempool fits into a block
+	report = NewStuckReport(now, txs[len(txs)-4:], time.Hour, 10)
+	assert.Equal(t, 0.0, report.Threshold)
+	assert.Len(t, report.Transactions, 0)
+}
@@ -3,11 +3,15 @@ package types
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/pkg/errors"
 )
 
 // Block contains the block data required for mempool reconstruction
@@ -19,7 +23,117 @@ type Block struct {
 	IsBest      bool      `json:"isBest"`
 	TxIDs       []Hash32  `json:"txids"`
 	EncodedTime time.Time `json:"encodedTime"` // TODO: find a better name for this?
-	// TODO: Size ?
+	// Compact difficulty target from the header, 0 if unknown
+	Bits uint32 `json:"bits"`
+	// Block weight, 0 if unknown
+	Weight int `json:"weight"`
+	// Number of transactions including the coinbase, 0 if unknown
+	TxCount int `json:"txCount"`
+	// Miner tag from the coinbase scriptSig, empty if unknown
+	Miner string `json:"miner"`
+	// Change of the UTXO set, nil if unknown. Not read by storage queries, see Storage.UTXODeltas.
+	UTXODelta *UTXODelta `json:"utxoDelta,omitempty"`
+	// Results of the analyzers, only set at ingest, see StoredAnalysisResult
+	Analysis []AnalysisResult `json:"-"`
+	// NearEmpty is set for blocks with a weight utilization below NearEmptyMaxUtilization
+	// that were first seen within NearEmptyWindow after their parent.
+	// Set by storage on insert.
+	NearEmpty bool `json:"nearEmpty"`
+	// InBestChain is set for blocks of the current best chain. Unlike IsBest, which records
+	// whether the block was the chain tip when it was received, it changes on reorgs.
+	// Set by storage.
+	InBestChain bool `json:"inBestChain"`
+	// Number of best-chain blocks from this one to the tip, -1 if the block is not on the best chain.
+	// Set by the API.
+	Confirmations *int `json:"confirmations,omitempty"`
+}
+
+// MaxBlockWeight is the consensus limit of the block weight
+const MaxBlockWeight = 4000000
+
+// NearEmptyMaxUtilization is the weight utilization below which a block is considered near-empty
+const NearEmptyMaxUtilization = 0.01
+
+// NearEmptyWindow is the maximum time between parent and block for NearEmpty.
+// Empty blocks are typically mined while the miner is still validating the parent.
+const NearEmptyWindow = 2 * time.Minute
+
+// Utilization returns the share of MaxBlockWeight used by the block
+func (b *Block) Utilization() float64 {
+	return float64(b.Weight) / MaxBlockWeight
+}
+
+// Difficulty returns the difficulty of the block target relative to the difficulty 1 target.
+// Returns 0 if Bits is unknown.
+func (b *Block) Difficulty() float64 {
+	if b.Bits == 0 {
+		return 0
+	}
+	return BitsToDifficulty(b.Bits)
+}
+
+// difficultyOneBits is the compact target of difficulty 1
+const difficultyOneBits = 0x1d00ffff
+
+// BitsToDifficulty converts a compact target to difficulty
+func BitsToDifficulty(bits uint32) float64 {
+	target := new(big.Float).SetInt(blockchain.CompactToBig(bits))
+	max := new(big.Float).SetInt(blockchain.CompactToBig(difficultyOneBits))
+	difficulty, _ := new(big.Float).Quo(max, target).Float64()
+	return difficulty
+}
+
+// Work returns the expected number of hashes to find the block, 0 if Bits is unknown
+func (b *Block) Work() *big.Int {
+	if b.Bits == 0 {
+		return new(big.Int)
+	}
+	return blockchain.CalcWork(b.Bits)
+}
+
+// maxMinerTagLength limits the length of the miner tag
+const maxMinerTagLength = 64
+
+// trimToSlashes strips characters before the first and after the last '/' of `run`.
+// Tags like "/ViaBTC/" can be adjacent to other printable bytes, e.g. from the extranonce.
+func trimToSlashes(run string) string {
+	first, last := strings.Index(run, "/"), strings.LastIndex(run, "/")
+	if first < 0 || first == last {
+		return run
+	}
+	return run[first : last+1]
+}
+
+// parseMinerTag returns the printable ASCII runs (at least 3 characters) of the coinbase
+// scriptSig after the height push, joined by spaces.
+// Pools commonly put their name there, e.g. "/ViaBTC/".
+func parseMinerTag(txin wire.TxIn) string {
+	script := txin.SignatureScript
+	if len(script) == 0 || int(script[0])+1 > len(script) {
+		return ""
+	}
+	script = script[script[0]+1:]
+
+	runs := []string{}
+	start := -1
+	for i := 0; i <= len(script); i++ {
+		printable := i < len(script) && script[i] >= 0x20 && script[i] < 0x7f
+		if printable && start < 0 {
+			start = i
+		}
+		if !printable && start >= 0 {
+			if run := trimToSlashes(string(script[start:i])); len(run) >= 3 {
+				runs = append(runs, run)
+			}
+			start = -1
+		}
+	}
+
+	tag := strings.TrimSpace(strings.Join(runs, " "))
+	if len(tag) > maxMinerTagLength {
+		tag = tag[:maxMinerTagLength]
+	}
+	return tag
 }
 
 // https://bitcoin.org/en/developer-reference#coinbase
@@ -54,7 +168,7 @@ func NewBlockFromBytes(firstSeen time.Time, rawblock []byte) (*Block, error) {
 	var wireBlock wire.MsgBlock
 	err := wireBlock.BtcDecode(reader, 0, wire.LatestEncoding)
 	if err != nil {
-		return nil, fmt.Errorf("error during BtcDecode: %s", err)
+		return nil, errors.Wrapf(ErrParse, "error during BtcDecode: %s", err)
 	}
 	return NewBlockFromWireBlock(firstSeen, &wireBlock)
 }
@@ -62,17 +176,19 @@ func NewBlockFromBytes(firstSeen time.Time, rawblock []byte) (*Block, error) {
 // NewBlockFromWireBlock creates a new Block from wire.MsgBlock
 func NewBlockFromWireBlock(firstSeen time.Time, wireBlock *wire.MsgBlock) (*Block, error) {
 	height := -1
+	miner := ""
 	txHashes := []Hash32{}
 
 	for _, t := range wireBlock.Transactions {
 		if blockchain.IsCoinBaseTx(t) {
 			height = parseHeight(*t.TxIn[0])
+			miner = parseMinerTag(*t.TxIn[0])
 		}
 		txHashes = append(txHashes, NewHashFromArray(t.TxHash()))
 	}
 
 	if height < 0 {
-		return nil, fmt.Errorf("height not found")
+		return nil, errors.Wrap(ErrParse, "height not found")
 	}
 
 	// FIXME: the default zmq rawblock only provides the current best block.
@@ -87,6 +203,11 @@ func NewBlockFromWireBlock(firstSeen time.Time, wireBlock *wire.MsgBlock) (*Bloc
 		TxIDs:       txHashes,
 		Height:      uint32(height),
 		IsBest:      isBest,
+		Bits:        wireBlock.Header.Bits,
+		Weight:      wireBlock.SerializeSizeStripped()*3 + wireBlock.SerializeSize(),
+		TxCount:     len(wireBlock.Transactions),
+		Miner:       miner,
+		UTXODelta:   NewUTXODelta(wireBlock, uint32(height)),
 	}, nil
 }
 
@@ -96,3 +217,207 @@ type StoredBlock struct {
 	DBID int64
 	Block
 }
+
+// MinerBlockStats aggregates the best-chain blocks of a miner first seen in an interval
+type MinerBlockStats struct {
+	// Start of the interval
+	Time  time.Time `json:"time"`
+	Miner string    `json:"miner"`
+	// Number of blocks with known weight
+	Blocks int `json:"blocks"`
+	// Number of near-empty blocks, see Block.NearEmpty
+	NearEmptyBlocks int `json:"nearEmptyBlocks"`
+	// Average share of MaxBlockWeight used
+	AvgUtilization float64 `json:"avgUtilization"`
+}
+
+// RetargetInterval is the number of blocks of a difficulty epoch
+const RetargetInterval = 2016
+
+// TargetBlockInterval is the block interval the difficulty adjustment aims for
+const TargetBlockInterval = 10 * time.Minute
+
+// DifficultyEpoch describes the best-chain blocks of a difficulty epoch
+type DifficultyEpoch struct {
+	// Epoch number, height / RetargetInterval
+	Epoch       uint32 `json:"epoch"`
+	StartHeight uint32 `json:"startHeight"`
+	EndHeight   uint32 `json:"endHeight"`
+	// Number of stored blocks with known difficulty
+	Blocks int `json:"blocks"`
+	// Header timestamps of the first and last stored block
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Difficulty float64   `json:"difficulty"`
+	// Average seconds between the stored blocks, 0 if less than two blocks are stored
+	AvgBlockInterval float64 `json:"avgBlockInterval"`
+	// Hashrate estimate in hashes per second, 0 if unknown
+	Hashrate float64 `json:"hashrate"`
+	// Expected factor of the next difficulty adjustment (clamped to [0.25, 4]), 0 if unknown
+	AdjustmentEstimate float64 `json:"adjustmentEstimate"`
+}
+
+// NewDifficultyEpoch computes the epoch statistics from the stored blocks
+// at heights `startHeight` to `endHeight` with header times `startTime` to `endTime`
+func NewDifficultyEpoch(
+	startHeight, endHeight uint32, blocks int, startTime, endTime time.Time, bits uint32,
+) DifficultyEpoch {
+	e := DifficultyEpoch{
+		Epoch:       startHeight / RetargetInterval,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		Blocks:      blocks,
+		StartTime:   startTime.UTC(),
+		EndTime:     endTime.UTC(),
+		Difficulty:  BitsToDifficulty(bits),
+	}
+
+	if endHeight > startHeight && endTime.After(startTime) {
+		e.AvgBlockInterval = endTime.Sub(startTime).Seconds() / float64(endHeight-startHeight)
+		// on average, difficulty * 2^32 hashes are needed to find a block
+		e.Hashrate = e.Difficulty * math.Pow(2, 32) / e.AvgBlockInterval
+		e.AdjustmentEstimate = math.Max(0.25, math.Min(4, TargetBlockInterval.Seconds()/e.AvgBlockInterval))
+	}
+
+	return e
+}
+
+// OverpaymentStats aggregates the fees that the transactions of best-chain blocks paid above the
+// lowest feerate included in their block, in an interval
+type OverpaymentStats struct {
+	// Start of the interval
+	Time   time.Time `json:"time"`
+	Blocks int       `json:"blocks"`
+	// Number of transactions with known fee and weight
+	Transactions int `json:"transactions"`
+	// Sum of the fees in sat
+	Fees uint64 `json:"fees"`
+	// Sum of `fee - lowest feerate in block * vsize` in sat, the fees that were not needed
+	// to be included in the same block
+	Overpaid uint64 `json:"overpaid"`
+}
+
+// CPFPStats aggregates the child-pays-for-parent packages of best-chain blocks in an interval:
+// a transaction and a child spending one of its outputs, confirmed in the same block, where the
+// child has the higher feerate
+type CPFPStats struct {
+	// Start of the interval
+	Time   time.Time `json:"time"`
+	Blocks int       `json:"blocks"`
+	// Number of transactions with known fee and weight
+	Transactions int `json:"transactions"`
+	// Number of parent-child pairs
+	Packages int `json:"packages"`
+	// Mean feerates in sat/vB of the parents and of the pairs as a package
+	ParentFeerate  float64 `json:"parentFeerate"`
+	PackageFeerate float64 `json:"packageFeerate"`
+	// Mean seconds from the parent to the child, the time until the fee bump
+	WaitSeconds float64 `json:"waitSeconds"`
+	// Mean seconds from the child to the block, the time to confirmation after the fee bump
+	ConfirmSeconds float64 `json:"confirmSeconds"`
+}
+
+// BlockIntervalBounds are the lower bounds in seconds of the buckets of an IntervalDistribution
+var BlockIntervalBounds = []int64{0, 60, 300, 600, 1200, 1800, 3600, 7200}
+
+// IntervalBucket counts the intervals from Min up to the Min of the next bucket
+type IntervalBucket struct {
+	// Lower bound in seconds
+	Min   int64 `json:"min"`
+	Count int   `json:"count"`
+}
+
+// IntervalDistribution describes a set of inter-block intervals in seconds
+type IntervalDistribution struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	Min    int64   `json:"min"`
+	Median int64   `json:"median"`
+	P90    int64   `json:"p90"`
+	P99    int64   `json:"p99"`
+	Max    int64   `json:"max"`
+	// Counts by BlockIntervalBounds. Negative header intervals are counted in the first bucket.
+	Buckets []IntervalBucket `json:"buckets"`
+}
+
+// NewIntervalDistribution computes the distribution of `intervals`. The slice is sorted in place.
+func NewIntervalDistribution(intervals []int64) IntervalDistribution {
+	d := IntervalDistribution{Count: len(intervals), Buckets: make([]IntervalBucket, len(BlockIntervalBounds))}
+	for i, bound := range BlockIntervalBounds {
+		d.Buckets[i].Min = bound
+	}
+	if len(intervals) == 0 {
+		return d
+	}
+
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	// nearest-rank percentile
+	percentile := func(p float64) int64 {
+		return intervals[int(math.Ceil(p*float64(len(intervals))))-1]
+	}
+
+	sum := int64(0)
+	for _, v := range intervals {
+		sum += v
+		i := sort.Search(len(BlockIntervalBounds), func(i int) bool { return BlockIntervalBounds[i] > v }) - 1
+		if i < 0 {
+			i = 0
+		}
+		d.Buckets[i].Count++
+	}
+	d.Mean = float64(sum) / float64(len(intervals))
+	d.Min = intervals[0]
+	d.Median = percentile(0.5)
+	d.P90 = percentile(0.9)
+	d.P99 = percentile(0.99)
+	d.Max = intervals[len(intervals)-1]
+	return d
+}
+
+// BlockInterval is the time between a block and its parent
+type BlockInterval struct {
+	Height    uint32    `json:"height"`
+	Hash      Hash32    `json:"hash"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Seconds since the parent was first seen
+	Interval int64 `json:"interval"`
+	// Seconds between the header times, nil if unknown
+	HeaderInterval *int64 `json:"headerInterval"`
+}
+
+// BlockIntervalStats describes the intervals of the best-chain blocks first seen in a range
+type BlockIntervalStats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Intervals by first-seen time
+	FirstSeen IntervalDistribution `json:"firstSeen"`
+	// Intervals by header time
+	Header IntervalDistribution `json:"header"`
+	// Minimum first-seen interval of a long gap in seconds
+	LongGap int64 `json:"longGap"`
+	// Blocks with a first-seen interval of at least LongGap
+	LongGaps []BlockInterval `json:"longGaps"`
+}
+
+// DrainTotals sums a set of transactions
+type DrainTotals struct {
+	Transactions int64  `json:"transactions"`
+	VSize        int64  `json:"vsize"`
+	Fees         uint64 `json:"fees"`
+}
+
+// BlockDrain compares the transactions a block removed from the tracked mempool
+// with the transactions that entered it since the parent block
+type BlockDrain struct {
+	Height    uint32    `json:"height"`
+	Hash      Hash32    `json:"hash"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Recorded transactions included in the block
+	Removed DrainTotals `json:"removed"`
+	// Transactions first seen after the parent and up to the block
+	Entered DrainTotals `json:"entered"`
+	// Removed minus entered vsize, positive if the block shrank the mempool
+	NetVSize int64 `json:"netVsize"`
+	// Removed minus entered fees
+	NetFees int64 `json:"netFees"`
+}
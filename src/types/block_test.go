@@ -3,6 +3,7 @@ package types
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/require"
@@ -38,3 +39,57 @@ func TestParseHeight(t *testing.T) {
 	height := parseHeight(*tx.TxIn[0])
 	require.Equal(t, height, 605453)
 }
+
+func TestParseMinerTag(t *testing.T) {
+	var tx wire.MsgTx
+	buf := bytes.NewBuffer(coinbaseTx[:])
+	err := tx.Deserialize(buf)
+	require.NoError(t, err)
+
+	require.Equal(t, "/NovaBlock/", parseMinerTag(*tx.TxIn[0]))
+}
+
+func TestBitsToDifficulty(t *testing.T) {
+	require.Equal(t, 1.0, BitsToDifficulty(0x1d00ffff))
+	// example from https://en.bitcoin.it/wiki/Difficulty
+	require.InDelta(t, 16307.420938523983, BitsToDifficulty(0x1b0404cb), 1e-6)
+}
+
+func TestBlock_Work(t *testing.T) {
+	require.Equal(t, int64(0), (&Block{}).Work().Int64())
+	// about 2^32 hashes at difficulty 1
+	require.Equal(t, int64(4295032833), (&Block{Bits: 0x1d00ffff}).Work().Int64())
+}
+
+func TestNewDifficultyEpoch(t *testing.T) {
+	start := time.Unix(1500000000, 0).UTC()
+	e := NewDifficultyEpoch(4032, 4042, 11, start, start.Add(10*5*time.Minute), 0x1d00ffff)
+	require.Equal(t, uint32(2), e.Epoch)
+	require.Equal(t, 300.0, e.AvgBlockInterval)
+	require.Equal(t, 2.0, e.AdjustmentEstimate)
+	require.InDelta(t, 4294967296.0/300, e.Hashrate, 1e-6)
+}
+
+func TestNewIntervalDistribution(t *testing.T) {
+	d := NewIntervalDistribution(nil)
+	require.Equal(t, 0, d.Count)
+	require.Len(t, d.Buckets, len(BlockIntervalBounds))
+
+	var intervals []int64
+	for i := int64(100); i > 0; i-- {
+		intervals = append(intervals, i*60)
+	}
+	d = NewIntervalDistribution(intervals)
+	require.Equal(t, 100, d.Count)
+	require.Equal(t, int64(60), d.Min)
+	require.Equal(t, int64(50*60), d.Median)
+	require.Equal(t, int64(90*60), d.P90)
+	require.Equal(t, int64(99*60), d.P99)
+	require.Equal(t, int64(100*60), d.Max)
+	require.Equal(t, 50.5*60, d.Mean)
+	// 1-4, 5-9, 10-19, 20-29, 30-59 and 60-100 minutes
+	require.Equal(t, []int{0, 4, 5, 10, 10, 30, 41, 0}, []int{
+		d.Buckets[0].Count, d.Buckets[1].Count, d.Buckets[2].Count, d.Buckets[3].Count,
+		d.Buckets[4].Count, d.Buckets[5].Count, d.Buckets[6].Count, d.Buckets[7].Count,
+	})
+}
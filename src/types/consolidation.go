@@ -0,0 +1,115 @@
+package types
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ConsolidationFeerateBounds are the lower bounds in sat/vB of the market feerate levels of ConsolidationReport
+var ConsolidationFeerateBounds = []float64{0, 1, 2, 3, 5, 10, 20, 50, 100}
+
+// ConsolidationLevel aggregates the hours with a median feerate of at least MinFeerate and less than
+// the MinFeerate of the next level, and the consolidations first seen in them
+type ConsolidationLevel struct {
+	// Lower bound of the median feerate of the hour in sat/vB
+	MinFeerate float64 `json:"minFeerate"`
+	// Number of hours at the level and of the transactions first seen in them
+	Hours        int   `json:"hours"`
+	Transactions int64 `json:"transactions"`
+	// Number of consolidations first seen in the hours
+	Consolidations int `json:"consolidations"`
+	// Consolidations / Transactions, zero without transactions
+	Share float64 `json:"share"`
+	// Share of all consolidations with a known level that were first seen at this level or below
+	CumulativeShare float64 `json:"cumulativeShare"`
+	// Mean feerate of the consolidations in sat/vB, zero without consolidations
+	MeanFeerate float64 `json:"meanFeerate"`
+}
+
+// ConsolidationReport relates the consolidations first seen in a time range to the feerate level
+// of the market: the median feerate of the transactions first seen in the same hour
+type ConsolidationReport struct {
+	// Number of consolidations first seen in the range
+	Consolidations int `json:"consolidations"`
+	// Number of consolidations first seen in hours without aggregate, which have no level
+	Unknown int                  `json:"unknown"`
+	Levels  []ConsolidationLevel `json:"levels"`
+	// Median feerates of the hours below which half and 90% of the consolidations with a level
+	// were first seen, nil without consolidations
+	MedianMarketFeerate *float64 `json:"medianMarketFeerate"`
+	P90MarketFeerate    *float64 `json:"p90MarketFeerate"`
+}
+
+// levelIndex returns the index of the level of ConsolidationFeerateBounds containing `feerate`
+func levelIndex(feerate float64) int {
+	i := sort.Search(len(ConsolidationFeerateBounds), func(i int) bool { return ConsolidationFeerateBounds[i] > feerate }) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// NewConsolidationReport relates the `consolidations` to the median feerates of the hourly
+// aggregates `hours`
+func NewConsolidationReport(hours []TxAggregate, consolidations []Transaction) ConsolidationReport {
+	r := ConsolidationReport{
+		Consolidations: len(consolidations),
+		Levels:         make([]ConsolidationLevel, len(ConsolidationFeerateBounds)),
+	}
+	for i, bound := range ConsolidationFeerateBounds {
+		r.Levels[i].MinFeerate = bound
+	}
+
+	market := map[int64]float64{}
+	for _, h := range hours {
+		if h.MedianFeerate == nil {
+			continue
+		}
+		market[h.Time.Unix()] = *h.MedianFeerate
+		level := &r.Levels[levelIndex(*h.MedianFeerate)]
+		level.Hours++
+		level.Transactions += h.Transactions
+	}
+
+	feerateSums := make([]float64, len(r.Levels))
+	var marketFeerates []float64
+	for i := range consolidations {
+		m, ok := market[consolidations[i].FirstSeen.Truncate(time.Hour).Unix()]
+		if !ok {
+			r.Unknown++
+			continue
+		}
+		marketFeerates = append(marketFeerates, m)
+		l := levelIndex(m)
+		r.Levels[l].Consolidations++
+		feerateSums[l] += consolidations[i].Feerate()
+	}
+
+	cumulative := 0
+	for i := range r.Levels {
+		level := &r.Levels[i]
+		if level.Transactions > 0 {
+			level.Share = float64(level.Consolidations) / float64(level.Transactions)
+		}
+		if level.Consolidations > 0 {
+			level.MeanFeerate = feerateSums[i] / float64(level.Consolidations)
+		}
+		cumulative += level.Consolidations
+		if len(marketFeerates) > 0 {
+			level.CumulativeShare = float64(cumulative) / float64(len(marketFeerates))
+		}
+	}
+
+	if len(marketFeerates) > 0 {
+		sort.Float64s(marketFeerates)
+		// nearest-rank percentile
+		percentile := func(p float64) *float64 {
+			v := marketFeerates[int(math.Ceil(p*float64(len(marketFeerates))))-1]
+			return &v
+		}
+		r.MedianMarketFeerate = percentile(0.5)
+		r.P90MarketFeerate = percentile(0.9)
+	}
+	return r
+}
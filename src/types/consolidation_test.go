@@ -0,0 +1,60 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConsolidationReport(t *testing.T) {
+	feerate := func(v float64) *float64 { return &v }
+	hour := func(h int64) time.Time { return time.Unix(h*3600, 0).UTC() }
+	hours := []TxAggregate{
+		{Time: hour(0), Transactions: 100, MedianFeerate: feerate(1.5)},
+		{Time: hour(1), Transactions: 300, MedianFeerate: feerate(1.2)},
+		{Time: hour(2), Transactions: 200, MedianFeerate: feerate(30)},
+		{Time: hour(3), Transactions: 0},
+	}
+	consolidation := func(firstSeen time.Time, fee uint64) Transaction {
+		return Transaction{FirstSeen: firstSeen, Fee: fee, Weight: 400}
+	}
+	report := NewConsolidationReport(hours, []Transaction{
+		consolidation(hour(0).Add(time.Minute), 100),
+		consolidation(hour(1), 300),
+		consolidation(hour(1).Add(59*time.Minute), 200),
+		consolidation(hour(2).Add(time.Minute), 3000),
+		// no aggregate
+		consolidation(hour(3), 100),
+	})
+
+	assert.Equal(t, 5, report.Consolidations)
+	assert.Equal(t, 1, report.Unknown)
+	require.Len(t, report.Levels, len(ConsolidationFeerateBounds))
+	assert.Equal(t, ConsolidationLevel{
+		MinFeerate:      1,
+		Hours:           2,
+		Transactions:    400,
+		Consolidations:  3,
+		Share:           3.0 / 400,
+		CumulativeShare: 0.75,
+		MeanFeerate:     2,
+	}, report.Levels[1])
+	assert.Equal(t, ConsolidationLevel{
+		MinFeerate:      20,
+		Hours:           1,
+		Transactions:    200,
+		Consolidations:  1,
+		Share:           1.0 / 200,
+		CumulativeShare: 1,
+		MeanFeerate:     30,
+	}, report.Levels[6])
+	assert.Equal(t, 0.0, report.Levels[0].CumulativeShare)
+	assert.Equal(t, 1.2, *report.MedianMarketFeerate)
+	assert.Equal(t, 30.0, *report.P90MarketFeerate)
+
+	empty := NewConsolidationReport(nil, nil)
+	assert.Nil(t, empty.MedianMarketFeerate)
+	assert.Equal(t, 0, empty.Levels[1].Hours)
+}
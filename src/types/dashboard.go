@@ -0,0 +1,26 @@
+package types
+
+import "time"
+
+// DashboardBlock contains the public statistics of a block
+type DashboardBlock struct {
+	Height    uint32    `json:"height"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Number of transactions
+	Transactions int    `json:"transactions"`
+	Weight       int    `json:"weight"`
+	Miner        string `json:"miner"`
+	NearEmpty    bool   `json:"nearEmpty"`
+}
+
+// Dashboard is the bundle of aggregates exported for a public dashboard.
+// It contains no txids or other data identifying single transactions.
+type Dashboard struct {
+	Time time.Time `json:"time"`
+	// Size and feerate histogram of the current mempool
+	Mempool *FeerateHistogram `json:"mempool"`
+	// Most recent best blocks, newest first
+	Blocks []DashboardBlock `json:"blocks"`
+	// Hourly transaction aggregates of the last day
+	Hourly []TxAggregate `json:"hourly"`
+}
@@ -0,0 +1,36 @@
+package types
+
+import "time"
+
+// DoubleSpend is the outcome of a conflict: a block confirmed a transaction that spends an output
+// also spent by another stored transaction
+type DoubleSpend struct {
+	// First-seen time of the block that resolved the conflict
+	Time  time.Time `json:"time"`
+	Block Hash32    `json:"block"`
+	// The confirmed transaction and the conflicting one that can no longer confirm
+	Confirmed            Hash32    `json:"confirmed"`
+	Conflicting          Hash32    `json:"conflicting"`
+	ConfirmedFirstSeen   time.Time `json:"confirmedFirstSeen"`
+	ConflictingFirstSeen time.Time `json:"conflictingFirstSeen"`
+	// Whether the confirmed transaction was seen first, i.e. a first-seen rule would have predicted the outcome
+	FirstSeenConfirmed bool `json:"firstSeenConfirmed"`
+	// Seconds from the first-seen time of the later transaction, when both were known, to the block
+	DurationSeconds int64 `json:"durationSeconds"`
+	// Fee of the confirmed transaction minus the fee of the conflicting one, nil if a fee is unknown
+	FeeDifference *int64 `json:"feeDifference"`
+}
+
+// DoubleSpendReport summarizes the double-spend outcomes of a time range
+type DoubleSpendReport struct {
+	// Number of conflicts resolved by a block in the range
+	Count int `json:"count"`
+	// Number of conflicts where the transaction seen first confirmed
+	FirstSeenConfirmed int `json:"firstSeenConfirmed"`
+	// Number of conflicts where the transaction with the higher fee confirmed
+	HigherFeeConfirmed int `json:"higherFeeConfirmed"`
+	// Mean DurationSeconds, 0 without conflicts
+	MeanDurationSeconds float64 `json:"meanDurationSeconds"`
+	// The most recent outcomes
+	DoubleSpends []DoubleSpend `json:"doubleSpends"`
+}
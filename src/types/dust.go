@@ -0,0 +1,111 @@
+package types
+
+import (
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DefaultDustRelayFeerate is the feerate in sat/vB that Bitcoin Core uses to compute dust limits (-dustrelayfee)
+const DefaultDustRelayFeerate = 3.0
+
+// DustThresholds determines the value in satoshis below which an output is dust
+type DustThresholds struct {
+	// Feerate in sat/vB at which spending an output costs more than its value, see DustThreshold.
+	// DefaultDustRelayFeerate if zero.
+	RelayFeerate float64 `json:"relayFeerate"`
+	// Fixed thresholds by script type (see TxOutput.ScriptType), e.g. {"pubkeyhash": 1000},
+	// replacing the threshold computed from RelayFeerate
+	ScriptTypes map[string]int64 `json:"scriptTypes"`
+}
+
+// witnessScriptTypes are the script types of witness programs, the script of unknown witness
+// versions is needed to recognize them
+var witnessScriptTypes = map[string]bool{
+	txscript.WitnessV0PubKeyHashTy.String(): true,
+	txscript.WitnessV0ScriptHashTy.String(): true,
+}
+
+// Threshold returns the dust threshold of `out`, 0 for OP_RETURN outputs which are never dust
+func (d DustThresholds) Threshold(out *TxOutput) int64 {
+	if out.ScriptType == txscript.NullDataTy.String() ||
+		(len(out.Script) > 0 && out.Script[0] == txscript.OP_RETURN) {
+		return 0
+	}
+	if threshold, ok := d.ScriptTypes[out.ScriptType]; ok {
+		return threshold
+	}
+	feerate := d.RelayFeerate
+	if feerate == 0 {
+		feerate = DefaultDustRelayFeerate
+	}
+	witness := witnessScriptTypes[out.ScriptType] || txscript.IsWitnessProgram(out.Script)
+	return DustThreshold(out.ScriptSize, witness, feerate)
+}
+
+// DustThreshold returns the value in satoshis below which an output with a script of `scriptSize`
+// bytes is dust at `feerate` (sat/vB), as computed by Bitcoin Core: the fee for the size of the
+// output and of a typical input spending it
+func DustThreshold(scriptSize int, witness bool, feerate float64) int64 {
+	size := 8 + wire.VarIntSerializeSize(uint64(scriptSize)) + scriptSize
+	if witness {
+		// outpoint, empty scriptSig, nSequence and the discounted witness of a signature and a public key
+		size += 32 + 4 + 1 + 107/4 + 4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return int64(float64(size) * feerate)
+}
+
+// DustOutputs are the dust outputs of a transaction with a script type
+type DustOutputs struct {
+	ScriptType string `json:"scriptType"`
+	Outputs    int    `json:"outputs"`
+	// Sum of the values in satoshis
+	Value int64 `json:"value"`
+}
+
+// FindDust returns the dust outputs of a transaction per script type, ordered by script type.
+// Returns nil if the transaction creates no dust.
+func FindDust(details *TxDetails, thresholds DustThresholds) []DustOutputs {
+	var byType map[string]*DustOutputs
+	for i := range details.Outputs {
+		out := &details.Outputs[i]
+		if out.Value >= thresholds.Threshold(out) {
+			continue
+		}
+		if byType == nil {
+			byType = map[string]*DustOutputs{}
+		}
+		d, ok := byType[out.ScriptType]
+		if !ok {
+			d = &DustOutputs{ScriptType: out.ScriptType}
+			byType[out.ScriptType] = d
+		}
+		d.Outputs++
+		d.Value += out.Value
+	}
+	if byType == nil {
+		return nil
+	}
+
+	res := make([]DustOutputs, 0, len(byType))
+	for _, d := range byType {
+		res = append(res, *d)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ScriptType < res[j].ScriptType })
+	return res
+}
+
+// DustBucket aggregates the dust outputs of a script type created by transactions first seen in an interval
+type DustBucket struct {
+	// Start of the interval
+	Time       time.Time `json:"time"`
+	ScriptType string    `json:"scriptType"`
+	// Number of transactions creating dust outputs of the script type
+	Transactions int   `json:"transactions"`
+	Outputs      int   `json:"outputs"`
+	Value        int64 `json:"value"`
+}
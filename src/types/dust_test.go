@@ -0,0 +1,42 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDustThreshold(t *testing.T) {
+	// the limits of Bitcoin Core at the default -dustrelayfee
+	assert.Equal(t, int64(546), DustThreshold(25, false, DefaultDustRelayFeerate))
+	assert.Equal(t, int64(540), DustThreshold(23, false, DefaultDustRelayFeerate))
+	assert.Equal(t, int64(294), DustThreshold(22, true, DefaultDustRelayFeerate))
+	assert.Equal(t, int64(330), DustThreshold(34, true, DefaultDustRelayFeerate))
+}
+
+func TestFindDust(t *testing.T) {
+	details := &TxDetails{Outputs: []TxOutput{
+		{Value: 545, ScriptType: "pubkeyhash", ScriptSize: 25},
+		{Value: 546, ScriptType: "pubkeyhash", ScriptSize: 25},
+		{Value: 100, ScriptType: "pubkeyhash", ScriptSize: 25},
+		{Value: 293, ScriptType: "witness_v0_keyhash", ScriptSize: 22},
+		{Value: 0, ScriptType: "nulldata", ScriptSize: 10},
+		// taproot outputs are nonstandard in btcd, the script identifies the witness program
+		{Value: 329, ScriptType: "nonstandard", ScriptSize: 34, Script: append([]byte{0x51, 0x20}, make([]byte, 32)...)},
+	}}
+
+	assert.Equal(t, []DustOutputs{
+		{ScriptType: "nonstandard", Outputs: 1, Value: 329},
+		{ScriptType: "pubkeyhash", Outputs: 2, Value: 645},
+		{ScriptType: "witness_v0_keyhash", Outputs: 1, Value: 293},
+	}, FindDust(details, DustThresholds{}))
+
+	// a higher relay feerate and a fixed threshold
+	assert.Equal(t, []DustOutputs{
+		{ScriptType: "nonstandard", Outputs: 1, Value: 329},
+		{ScriptType: "pubkeyhash", Outputs: 1, Value: 100},
+		{ScriptType: "witness_v0_keyhash", Outputs: 1, Value: 293},
+	}, FindDust(details, DustThresholds{RelayFeerate: 10, ScriptTypes: map[string]int64{"pubkeyhash": 200}}))
+
+	assert.Nil(t, FindDust(&TxDetails{Outputs: []TxOutput{{Value: 1000, ScriptType: "pubkeyhash", ScriptSize: 25}}}, DustThresholds{}))
+}
@@ -0,0 +1,20 @@
+package types
+
+import "github.com/pkg/errors"
+
+// Error categories shared across packages. Errors are annotated with
+// github.com/pkg/errors, use errors.Cause to get the category:
+//
+//	if errors.Cause(err) == types.ErrNotFound { ... }
+var (
+	// ErrNotFound is returned if a requested block or transaction does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrParse is returned for malformed input, e.g. hashes, ZMQ messages or serialized blocks
+	ErrParse = errors.New("parse error")
+	// ErrStorageBusy is returned if the database is locked by another connection.
+	// The operation can be retried.
+	ErrStorageBusy = errors.New("storage busy")
+	// ErrReorgDetected is returned if a block does not connect to the stored chain,
+	// either due to a reorg or due to missed blocks.
+	ErrReorgDetected = errors.New("reorg detected")
+)
@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// EvictionPolicy describes the mempool limit of a simulated node
+type EvictionPolicy struct {
+	// MaxMempool is the limit of the memory usage in bytes, the `-maxmempool` setting of the node
+	MaxMempool int64
+	// UsagePerVByte is the estimated memory usage of a transaction per virtual byte.
+	// The node counts the memory of its data structures, a multiple of the serialized size;
+	// the ratio of `usage` and `bytes` in `getmempoolinfo` is the value of a node.
+	UsagePerVByte float64
+	// IncrementalFeerate is added to the feerate of evicted transactions for the minimum feerate
+	// of new transactions, in sat/vB (`-incrementalrelayfee`)
+	IncrementalFeerate float64
+	// HalfLife is the time in which the minimum feerate halves after a block, shorter if the
+	// mempool is less than half full
+	HalfLife time.Duration
+}
+
+// DefaultEvictionPolicy is the policy of a node with the default settings
+var DefaultEvictionPolicy = EvictionPolicy{
+	MaxMempool:         300000000,
+	UsagePerVByte:      3,
+	IncrementalFeerate: 1,
+	HalfLife:           12 * time.Hour,
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// ExternalFirstSeen is the first-seen time of a transaction reported by an external dataset
+type ExternalFirstSeen struct {
+	TxID      Hash32    `json:"txid"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Label of the dataset, e.g. the name of the public collector
+	Source string `json:"source"`
+}
+
+// LocalSource is the source label of the first-seen times recorded by the collector itself
+const LocalSource = "local"
+
+// FirstSeenEstimate is an interval that contains the time a transaction was first seen in the
+// network with high confidence, estimated from the first-seen times of several sources
+type FirstSeenEstimate struct {
+	Lower time.Time `json:"lower"`
+	Upper time.Time `json:"upper"`
+	// Number of sources that saw the transaction, including the collector
+	Sources int `json:"sources"`
+}
+
+// ImportedHistogram is the mempool at a point in time in feerate buckets, as reported by the
+// statistics of another collector (see `bademeister import -format johoe`). It is never derived
+// from the own observations.
+type ImportedHistogram struct {
+	// Label of the dataset
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	// Buckets with transactions: the lower bound in sat/vB, the number of transactions, their size
+	// in vbytes and their fees in satoshis
+	Feerates []float64 `json:"feerates"`
+	Counts   []int64   `json:"counts"`
+	VSizes   []int64   `json:"vsizes"`
+	Fees     []int64   `json:"fees"`
+}
@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// FeeUpdate records a transaction that arrived again with a different fee. The fee is fixed by
+// the txid, so a different one is a quirk of the source; the first stored fee is kept.
+type FeeUpdate struct {
+	TxID Hash32 `json:"txid"`
+	// First-seen time of the report with the new fee
+	Time   time.Time `json:"time"`
+	OldFee uint64    `json:"oldFee"`
+	NewFee uint64    `json:"newFee"`
+}
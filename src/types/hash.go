@@ -2,15 +2,21 @@ package types
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+
+	"github.com/pkg/errors"
 )
 
 // Hash32 is a 32 byte / 256 bit hash.
 // This hash is used for block header hashes and transaction IDs.
+// The bytes are in the internal byte order of the wire protocol, as stored in the database.
 type Hash32 [32]byte
 
+// String returns the hash as hex string in the RPC byte order shown by Bitcoin Core and block explorers
 func (h Hash32) String() string {
-	return hex.EncodeToString(h[:])
+	r := h.Reversed()
+	return hex.EncodeToString(r[:])
 }
 
 // NewHashFromBytes returns a new Hash32 from a 32-length byte slice.
@@ -24,6 +30,39 @@ func NewHashFromBytes(bytes []byte) (res Hash32) {
 	return
 }
 
+// NewHashFromString returns a new Hash32 from a 64 character hex string
+// in the RPC byte order, as produced by String().
+func NewHashFromString(s string) (res Hash32, err error) {
+	bytes, err := hex.DecodeString(s)
+	if err != nil {
+		return res, errors.Wrapf(ErrParse, "invalid hash %q: %s", s, err)
+	}
+	if len(bytes) != 32 {
+		return res, errors.Wrapf(ErrParse, "invalid hash length %d", len(bytes))
+	}
+	copy(res[:], bytes)
+	return res.Reversed(), nil
+}
+
+// MarshalJSON encodes the hash as hex string in the RPC byte order
+func (h Hash32) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+// UnmarshalJSON decodes a hash from a hex string in the RPC byte order
+func (h *Hash32) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	res, err := NewHashFromString(s)
+	if err != nil {
+		return err
+	}
+	*h = res
+	return nil
+}
+
 // NewHashFromArray returns a new Hash32 from a 32-length byte array.
 func NewHashFromArray(bytes [32]byte) Hash32 {
 	return NewHashFromBytes(bytes[:])
@@ -31,10 +70,9 @@ func NewHashFromArray(bytes [32]byte) Hash32 {
 
 // Reversed returns a Hash with the byte sequence in reverse order.
 //
-// Some parts of the btcd api, for instance the SendSimpleTransaction call,
-// return byte arrays that have the internal TxID byte order
-// (but show the reversed rpc byte order in String()).
-// This method helps converting between both representations.
+// Hash32 and chainhash.Hash keep the internal byte order and show the
+// reversed RPC byte order in String(). This method converts raw bytes
+// in the RPC byte order, e.g. decoded from RPC results, to the internal order.
 //
 // More info: https://bitcoin.stackexchange.com/a/32767/3811
 func (h Hash32) Reversed() (res Hash32) {
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash32_JSON(t *testing.T) {
+	const hexHash = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	h, err := NewHashFromString(hexHash)
+	require.NoError(t, err)
+	assert.Equal(t, hexHash, h.String())
+	// the bytes are in the internal byte order, like those of chainhash
+	assert.Equal(t, byte(0x1f), h[0])
+	assert.Equal(t, hexHash, chainhash.Hash(h).String())
+
+	data, err := json.Marshal(h)
+	require.NoError(t, err)
+	assert.Equal(t, `"`+hexHash+`"`, string(data))
+
+	var decoded Hash32
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, h, decoded)
+
+	_, err = NewHashFromString("0001")
+	assert.Equal(t, ErrParse, errors.Cause(err))
+	_, err = NewHashFromString("xx")
+	assert.Equal(t, ErrParse, errors.Cause(err))
+}
@@ -0,0 +1,69 @@
+package types
+
+import "time"
+
+// HeuristicFlags is a bitmask of transaction classifications.
+// The classifications are heuristics and can be wrong for individual transactions.
+type HeuristicFlags uint32
+
+const (
+	// HeuristicBatch marks transactions with many outputs (likely batch withdrawals)
+	HeuristicBatch HeuristicFlags = 1 << iota
+	// HeuristicConsolidation marks transactions with many inputs and a single output
+	HeuristicConsolidation
+	// HeuristicCoinjoinLike marks transactions with multiple outputs of equal value
+	HeuristicCoinjoinLike
+	// HeuristicDust marks transactions that create outputs below the dust limit
+	HeuristicDust
+)
+
+// HeuristicNames maps each flag to a name for statistics
+var HeuristicNames = map[HeuristicFlags]string{
+	HeuristicBatch:         "batch",
+	HeuristicConsolidation: "consolidation",
+	HeuristicCoinjoinLike:  "coinjoinLike",
+	HeuristicDust:          "dust",
+}
+
+// Has returns true if all bits of `flag` are set
+func (f HeuristicFlags) Has(flag HeuristicFlags) bool {
+	return f&flag == flag
+}
+
+// HeuristicStats counts the classified transactions per flag
+type HeuristicStats struct {
+	// Number of classified transactions
+	Classified int `json:"classified"`
+	// Number of transactions per flag name
+	Counts map[string]int `json:"counts"`
+}
+
+// HeuristicFlagByName returns the flag with the name `name` of HeuristicNames
+func HeuristicFlagByName(name string) (HeuristicFlags, bool) {
+	for flag, n := range HeuristicNames {
+		if n == name {
+			return flag, true
+		}
+	}
+	return 0, false
+}
+
+// BlockShareStats is the share of the transactions with a heuristic flag in the best-chain blocks
+// of an interval
+type BlockShareStats struct {
+	// Start of the interval
+	Time   time.Time `json:"time"`
+	Blocks int       `json:"blocks"`
+	// Sum of the weights of the blocks
+	BlockWeight int64 `json:"blockWeight"`
+	// Number and fees in sat of the transactions with known fee and weight
+	Transactions int    `json:"transactions"`
+	Fees         uint64 `json:"fees"`
+	// Number, weight and fees in sat of the transactions with the flag
+	Flagged       int    `json:"flagged"`
+	FlaggedWeight int64  `json:"flaggedWeight"`
+	FlaggedFees   uint64 `json:"flaggedFees"`
+	// FlaggedWeight / BlockWeight and FlaggedFees / Fees, zero if the divisor is zero
+	WeightShare float64 `json:"weightShare"`
+	FeeShare    float64 `json:"feeShare"`
+}
@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// FeeBump is a version of a transaction in a chain of replacements
+type FeeBump struct {
+	TxID      Hash32    `json:"txid"`
+	FirstSeen time.Time `json:"firstSeen"`
+	Fee       uint64    `json:"fee"`
+	// Feerate in sat/vB, zero if the weight is unknown
+	Feerate float64 `json:"feerate"`
+	// Fee minus the fee of the previous version, zero for the first version
+	FeeIncrease int64 `json:"feeIncrease"`
+	// Fee minus the fee of the first version
+	CumulativeFeeIncrease int64 `json:"cumulativeFeeIncrease"`
+	// Seconds since the previous version was first seen, zero for the first version
+	SecondsSincePrevious int64 `json:"secondsSincePrevious"`
+}
+
+// RBFLineage is a chain of replacements: each transaction spends an output also spent by the
+// previous one and was first seen after it
+type RBFLineage struct {
+	// The versions in the order they were first seen, the first is the original transaction
+	Transactions []FeeBump `json:"transactions"`
+	// Number of replacements
+	Bumps int `json:"bumps"`
+	// Fee of the last version minus the fee of the first
+	TotalFeeIncrease int64 `json:"totalFeeIncrease"`
+	// Seconds from the first to the last version
+	DurationSeconds int64 `json:"durationSeconds"`
+}
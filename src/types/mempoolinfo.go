@@ -0,0 +1,20 @@
+package types
+
+import "time"
+
+// MempoolInfo is a snapshot of the node's mempool state and fee limits (`getmempoolinfo`)
+type MempoolInfo struct {
+	Time time.Time `json:"time"`
+	// Number of transactions in the node mempool
+	Size int64 `json:"size"`
+	// Sum of the virtual transaction sizes
+	Bytes int64 `json:"bytes"`
+	// Memory usage of the node mempool
+	Usage int64 `json:"usage"`
+	// Maximum memory usage of the node mempool
+	MaxMempool int64 `json:"maxMempool"`
+	// Minimum feerate for a transaction to be accepted, in sat/kvB
+	MempoolMinFee int64 `json:"mempoolMinFee"`
+	// Minimum relay feerate, in sat/kvB
+	MinRelayTxFee int64 `json:"minRelayTxFee"`
+}
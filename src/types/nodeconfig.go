@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// NodeConfig describes the settings of the node that affect the recorded data
+type NodeConfig struct {
+	// Time at which the settings were first observed
+	Time            time.Time `json:"time"`
+	Version         int32     `json:"version"`
+	SubVersion      string    `json:"subVersion"`
+	ProtocolVersion int32     `json:"protocolVersion"`
+	Chain           string    `json:"chain"`
+	// Maximum memory usage of the node mempool in bytes (`-maxmempool`)
+	MaxMempool int64 `json:"maxMempool"`
+	// Minimum relay feerate in sat/kvB (`-minrelaytxfee`)
+	MinRelayTxFee int64 `json:"minRelayTxFee"`
+	// Incremental relay feerate in sat/kvB (`-incrementalrelayfee`)
+	IncrementalFee int64 `json:"incrementalFee"`
+	// False if the node runs in `-blocksonly` mode
+	LocalRelay bool `json:"localRelay"`
+}
+
+// SameSettings returns true iff both configs only differ in `Time`
+func (c NodeConfig) SameSettings(other NodeConfig) bool {
+	c.Time = other.Time
+	return c == other
+}
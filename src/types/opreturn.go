@@ -0,0 +1,65 @@
+package types
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// OpReturnStats describes the OP_RETURN (data carrier) outputs of a transaction
+type OpReturnStats struct {
+	// Number of OP_RETURN outputs
+	Outputs int `json:"outputs"`
+	// Sum of the data pushed by the OP_RETURN outputs in bytes
+	PayloadSize int `json:"payloadSize"`
+}
+
+// NewOpReturnStatsFromWireTx returns the OpReturnStats of a wire.MsgTx
+func NewOpReturnStatsFromWireTx(wireTx *wire.MsgTx) *OpReturnStats {
+	stats := OpReturnStats{}
+	for _, txOut := range wireTx.TxOut {
+		// not txscript.NullDataTy, which only matches a single data push
+		if len(txOut.PkScript) == 0 || txOut.PkScript[0] != txscript.OP_RETURN {
+			continue
+		}
+		stats.Outputs++
+		pushes, err := txscript.PushedData(txOut.PkScript)
+		if err != nil {
+			continue
+		}
+		for _, data := range pushes {
+			stats.PayloadSize += len(data)
+		}
+	}
+	return &stats
+}
+
+// OpReturnBucket aggregates the OP_RETURN usage of transactions first seen in an interval
+type OpReturnBucket struct {
+	// Start of the interval
+	Time time.Time `json:"time"`
+	// Number of transactions with known OP_RETURN stats
+	Transactions int `json:"transactions"`
+	// Number of transactions with at least one OP_RETURN output
+	OpReturnTransactions int `json:"opReturnTransactions"`
+	// Sum of the OP_RETURN payload sizes in bytes
+	PayloadSize int64 `json:"payloadSize"`
+}
+
+// IsDataCarrier returns true if the transaction is known to have OP_RETURN outputs
+func (tx *Transaction) IsDataCarrier() bool {
+	return tx.OpReturn != nil && tx.OpReturn.Outputs > 0
+}
+
+// WithoutDataCarriers returns the transactions of `txs` that are not known to have OP_RETURN outputs,
+// e.g. to keep data-embedding waves out of fee analysis
+func WithoutDataCarriers(txs []Transaction) []Transaction {
+	res := []Transaction{}
+	for i := range txs {
+		if !txs[i].IsDataCarrier() {
+			res = append(res, txs[i])
+		}
+	}
+	return res
+}
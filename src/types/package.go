@@ -0,0 +1,26 @@
+package types
+
+import "time"
+
+// PackageEvent is a child transaction that arrived before one of its unconfirmed parents.
+// This is the arrival order of package relay and orphan resolution, where a node requests
+// the unknown parents of a child it received.
+type PackageEvent struct {
+	Child           Hash32    `json:"child"`
+	Parent          Hash32    `json:"parent"`
+	ChildFirstSeen  time.Time `json:"childFirstSeen"`
+	ParentFirstSeen time.Time `json:"parentFirstSeen"`
+	// Time between the arrival of the child and the parent in milliseconds
+	DelayMs int64 `json:"delayMs"`
+}
+
+// OrphanResolution is a transaction that arrived before some of its parents and was resolved
+// when the last of them arrived
+type OrphanResolution struct {
+	TxID      Hash32    `json:"txid"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Number of parents that were unknown when the transaction arrived
+	Parents int `json:"parents"`
+	// Time between the arrival of the transaction and its last unknown parent in milliseconds
+	DelayMs int64 `json:"delayMs"`
+}
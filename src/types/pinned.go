@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// PinnedSnapshot describes a reconstructed mempool stored under a name, see storage.PinSnapshot
+type PinnedSnapshot struct {
+	Name string `json:"name"`
+	// Time of the reconstructed mempool
+	Time time.Time `json:"time"`
+	// Time the snapshot was pinned
+	Created      time.Time `json:"created"`
+	Transactions int       `json:"transactions"`
+	// Hex-encoded SHA-256 of the stored MempoolSnapshot JSON
+	Checksum string `json:"checksum"`
+}
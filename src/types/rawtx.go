@@ -0,0 +1,188 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// RawTxSummary is what is known about a serialized transaction without deserializing it
+type RawTxSummary struct {
+	TxID Hash32
+	// Hash including the witness data (BIP141), equals TxID for transactions without witness
+	WTxID Hash32
+	TxSizes
+}
+
+// rawTxReader reads the fields of a serialized transaction without copying them
+type rawTxReader struct {
+	raw []byte
+	pos int
+}
+
+func (r *rawTxReader) skip(n uint64) error {
+	if n > uint64(len(r.raw)-r.pos) {
+		return errors.Wrap(ErrParse, "unexpected end of transaction")
+	}
+	r.pos += int(n)
+	return nil
+}
+
+// varInt reads a CompactSize integer
+func (r *rawTxReader) varInt() (uint64, error) {
+	if r.pos >= len(r.raw) {
+		return 0, errors.Wrap(ErrParse, "unexpected end of transaction")
+	}
+	prefix := r.raw[r.pos]
+	r.pos++
+
+	var n int
+	switch prefix {
+	case 0xfd:
+		n = 2
+	case 0xfe:
+		n = 4
+	case 0xff:
+		n = 8
+	default:
+		return uint64(prefix), nil
+	}
+	if len(r.raw)-r.pos < n {
+		return 0, errors.Wrap(ErrParse, "unexpected end of transaction")
+	}
+	b := r.raw[r.pos : r.pos+n]
+	r.pos += n
+	switch n {
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	default:
+		return binary.LittleEndian.Uint64(b), nil
+	}
+}
+
+// skipVarBytes skips a CompactSize length followed by that many bytes
+func (r *rawTxReader) skipVarBytes() error {
+	n, err := r.varInt()
+	if err != nil {
+		return err
+	}
+	return r.skip(n)
+}
+
+// NewRawTxSummary returns the txid, wtxid and sizes of the serialized transaction `raw` in a
+// single pass over the bytes. Unlike deserializing into a wire.MsgTx, which allocates every script
+// and witness item, the hashes are computed over byte ranges of `raw` without copying it.
+// Returns ErrParse if `raw` is not a complete transaction.
+func NewRawTxSummary(raw []byte) (*RawTxSummary, error) {
+	r := &rawTxReader{raw: raw}
+	if err := r.skip(4); err != nil {
+		return nil, err
+	}
+
+	// BIP144: marker 0x00 (an empty input list otherwise) and flag 0x01
+	segwit := len(raw) > 6 && raw[4] == 0x00 && raw[5] == 0x01
+	if segwit {
+		r.pos += 2
+	}
+
+	inputs, err := r.varInt()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < inputs; i++ {
+		// outpoint, scriptSig, sequence
+		if err := r.skip(36); err != nil {
+			return nil, err
+		}
+		if err := r.skipVarBytes(); err != nil {
+			return nil, err
+		}
+		if err := r.skip(4); err != nil {
+			return nil, err
+		}
+	}
+
+	outputs, err := r.varInt()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < outputs; i++ {
+		// value, scriptPubKey
+		if err := r.skip(8); err != nil {
+			return nil, err
+		}
+		if err := r.skipVarBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	witnessStart := r.pos
+	if segwit {
+		for i := uint64(0); i < inputs; i++ {
+			items, err := r.varInt()
+			if err != nil {
+				return nil, err
+			}
+			for j := uint64(0); j < items; j++ {
+				if err := r.skipVarBytes(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	witnessEnd := r.pos
+
+	if err := r.skip(4); err != nil {
+		return nil, err
+	}
+	if r.pos != len(raw) {
+		return nil, errors.Wrapf(ErrParse, "%d unexpected bytes after the transaction", len(raw)-r.pos)
+	}
+
+	res := &RawTxSummary{
+		WTxID:   doubleSHA256(raw),
+		TxSizes: TxSizes{Size: len(raw), StrippedSize: len(raw)},
+	}
+	if !segwit {
+		res.TxID = res.WTxID
+		return res, nil
+	}
+
+	// the txid skips marker, flag and witness data
+	res.StrippedSize -= 2 + witnessEnd - witnessStart
+	res.TxID = doubleSHA256(raw[:4], raw[6:witnessStart], raw[witnessEnd:])
+	return res, nil
+}
+
+// SegwitWTxID returns the WTxID of a transaction with witness, nil for transactions without,
+// in the form of Transaction.WTxID
+func (s *RawTxSummary) SegwitWTxID() *Hash32 {
+	if s.WTxID == s.TxID {
+		return nil
+	}
+	wtxid := s.WTxID
+	return &wtxid
+}
+
+// NewWTxID returns the WTxID of the serialized transaction `raw` in the form of Transaction.WTxID:
+// nil if the transaction has no witness
+func NewWTxID(raw []byte, hasWitness bool) *Hash32 {
+	if !hasWitness {
+		return nil
+	}
+	wtxid := doubleSHA256(raw)
+	return &wtxid
+}
+
+// doubleSHA256 returns SHA256(SHA256(parts...)) in internal byte order, like chainhash.DoubleHashH
+func doubleSHA256(parts ...[]byte) Hash32 {
+	h := sha256.New()
+	for _, p := range parts {
+		_, _ = h.Write(p)
+	}
+	var first [sha256.Size]byte
+	return Hash32(sha256.Sum256(h.Sum(first[:0])))
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRawTxSummary(t *testing.T) {
+	for _, segwit := range []bool{false, true} {
+		wireTx := newTestWireTx(3, segwit)
+		summary, err := NewRawTxSummary(serializeTx(t, wireTx))
+		require.NoError(t, err)
+		assert.Equal(t, NewHashFromArray(wireTx.TxHash()), summary.TxID)
+		assert.Equal(t, NewHashFromArray(wireTx.WitnessHash()), summary.WTxID)
+		assert.Equal(t, wireTx.SerializeSize(), summary.Size)
+		assert.Equal(t, wireTx.SerializeSizeStripped(), summary.StrippedSize)
+
+		raw := serializeTx(t, wireTx)
+		assert.Equal(t, summary.SegwitWTxID(), NewWTxID(raw, wireTx.HasWitness()))
+		if segwit {
+			require.NotNil(t, summary.SegwitWTxID())
+			assert.Equal(t, summary.WTxID, *summary.SegwitWTxID())
+		} else {
+			assert.Nil(t, summary.SegwitWTxID())
+		}
+	}
+
+	raw := serializeTx(t, newTestWireTx(1, true))
+	_, err := NewRawTxSummary(raw[:len(raw)-1])
+	assert.Equal(t, ErrParse, errors.Cause(err))
+	_, err = NewRawTxSummary(append(raw, 0x00))
+	assert.Equal(t, ErrParse, errors.Cause(err))
+	_, err = NewRawTxSummary(nil)
+	assert.Equal(t, ErrParse, errors.Cause(err))
+}
+
+// BenchmarkTxHash_Wire deserializes the transaction for its txid and sizes
+func BenchmarkTxHash_Wire(b *testing.B) {
+	raw := serializeTx(b, newTestWireTx(10, true))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wireTx := wire.NewMsgTx(wire.TxVersion)
+		if err := wireTx.Deserialize(bytes.NewReader(raw)); err != nil {
+			b.Fatal(err)
+		}
+		_ = wireTx.TxHash()
+		_ = NewTxSizes(wireTx, raw)
+	}
+}
+
+func BenchmarkNewRawTxSummary(b *testing.B) {
+	raw := serializeTx(b, newTestWireTx(10, true))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewRawTxSummary(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
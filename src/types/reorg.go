@@ -0,0 +1,26 @@
+package types
+
+import "time"
+
+// Reorg is a change of the best chain to a block that does not extend the previous tip
+type Reorg struct {
+	// First-seen time of the new tip, when the reorg was detected
+	Time time.Time `json:"time"`
+	// Last block of both chains
+	ForkHash   Hash32 `json:"forkHash"`
+	ForkHeight uint32 `json:"forkHeight"`
+	OldTip     Hash32 `json:"oldTip"`
+	NewTip     Hash32 `json:"newTip"`
+	// Number of blocks removed from the best chain
+	Depth int `json:"depth"`
+	// Blocks removed from the best chain, highest first
+	Orphaned []Hash32 `json:"orphaned"`
+	// Number of transactions in the orphaned blocks
+	Transactions int `json:"transactions"`
+	// Number of transactions in the orphaned blocks that are not in the new blocks,
+	// i.e. returned to the mempool
+	Unconfirmed int `json:"unconfirmed"`
+	// Seconds from the first-seen time of the first block after the fork, of either chain,
+	// to the new tip: how long the competing chains existed until the reorg resolved them
+	DurationSeconds int64 `json:"durationSeconds"`
+}
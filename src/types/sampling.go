@@ -0,0 +1,25 @@
+package types
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// SamplingPeriod is the fraction of transactions stored from a time on, until the next period.
+// Blocks are always stored.
+type SamplingPeriod struct {
+	From time.Time `json:"from"`
+	// Between 0 (exclusive) and 1, 1 if all transactions are stored
+	Rate float64 `json:"rate"`
+}
+
+// Sampled returns true if the transaction `txid` is in the sample of `rate`. The decision only depends
+// on the txid, so all collectors with the same rate store the same transactions.
+func Sampled(txid Hash32, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	// txids are uniformly distributed
+	return float64(binary.LittleEndian.Uint64(txid[:8])) < rate*math.MaxUint64
+}
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampled(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		txid := Hash32(sha256.Sum256([]byte(fmt.Sprintf("tx-%d", i))))
+		assert.True(t, Sampled(txid, 1))
+		assert.False(t, Sampled(txid, 0))
+		if Sampled(txid, 0.1) {
+			sampled++
+			// a sample includes the samples of lower rates
+			assert.True(t, Sampled(txid, 0.5))
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 100)
+}
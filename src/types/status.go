@@ -0,0 +1,63 @@
+package types
+
+import "time"
+
+// Status describes a deployment, see `/v1/status`
+type Status struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Height of the best block, nil if there are no blocks
+	BestBlockHeight *uint32 `json:"bestBlockHeight"`
+	// Recently stored transactions and blocks, see IngestRate
+	IngestRates []IngestRate `json:"ingestRates"`
+	// Changes of the fraction of stored transactions, empty if all were stored
+	SamplingRates []SamplingPeriod `json:"samplingRates"`
+	// Runtime state of the daemon, nil if the API server runs without a daemon
+	Daemon *DaemonStatus `json:"daemon"`
+}
+
+// IngestRate counts the transactions and blocks first seen in a window before the current time
+type IngestRate struct {
+	Window                string  `json:"window"`
+	Transactions          int     `json:"transactions"`
+	TransactionsPerSecond float64 `json:"transactionsPerSecond"`
+	Blocks                int     `json:"blocks"`
+}
+
+// DaemonStatus describes the enabled features and runtime state of a daemon
+type DaemonStatus struct {
+	Started       time.Time `json:"started"`
+	UptimeSeconds int64     `json:"uptimeSeconds"`
+	Paused        bool      `json:"paused"`
+	// Sources of transactions and blocks: `zmq`, `poll` (polling the node mempool), `rpc`, `rest`
+	Sources   []string        `json:"sources"`
+	Features  DaemonFeatures  `json:"features"`
+	Retention RetentionStatus `json:"retention"`
+}
+
+// DaemonFeatures are the optional features enabled by the flags of the daemon
+type DaemonFeatures struct {
+	// Inputs and outputs of transactions are stored (`-store-details`)
+	FullTransactions   bool     `json:"fullTransactions"`
+	StoreRaw           bool     `json:"storeRaw"`
+	Heuristics         bool     `json:"heuristics"`
+	Dust               bool     `json:"dust"`
+	PrivacyMode        bool     `json:"privacyMode"`
+	Mirror             bool     `json:"mirror"`
+	DuplicateFilter    bool     `json:"duplicateFilter"`
+	PackageObservation bool     `json:"packageObservation"`
+	OrphanPool         bool     `json:"orphanPool"`
+	HeaderFastPath     bool     `json:"headerFastPath"`
+	Replication        bool     `json:"replication"`
+	Analyzers          []string `json:"analyzers"`
+	ScriptHook         bool     `json:"scriptHook"`
+	// Fraction of the transactions that is stored, see Sampled
+	SampleRate float64 `json:"sampleRate"`
+}
+
+// RetentionStatus are the pruning settings of the daemon
+type RetentionStatus struct {
+	// Transactions older than the window are pruned, empty if disabled
+	Window string `json:"window"`
+	// Pruned transactions are archived
+	Archive bool `json:"archive"`
+}
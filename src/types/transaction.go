@@ -1,18 +1,133 @@
 package types
 
 import (
+	"bytes"
+	"sort"
 	"time"
 )
 
 // Transaction represents a Bitcoin transaction
 type Transaction struct {
-	TxID         Hash32     `json:"txid"`
+	TxID Hash32 `json:"txid"`
+	// Hash of the transaction including the witness, nil if the transaction has no witness or it is unknown.
+	// The most recently seen witness is stored, see WitnessReplacement.
+	WTxID        *Hash32    `json:"wtxid,omitempty"`
 	FirstSeen    time.Time  `json:"firstSeen"`
 	LastRemoved  *time.Time `json:"lastRemoved"`
+	Expired      *time.Time `json:"expired"`
 	Fee          uint64     `json:"fee"`
 	Weight       int        `json:"weight"`
 	BlockHeight  int32      `json:"blockHeight"`
 	IndexInBlock int32      `json:"indexInBlock"`
+	// Inputs and outputs, nil unless parsed from the raw transaction
+	Details *TxDetails `json:"details,omitempty"`
+	// Heuristic classification, nil if the transaction was not classified
+	Heuristics *HeuristicFlags `json:"heuristics,omitempty"`
+	// OP_RETURN outputs, nil unless parsed from the raw transaction
+	OpReturn *OpReturnStats `json:"opReturn,omitempty"`
+	// Size of the witness data (including marker and flag) in bytes, nil unless parsed from the raw transaction
+	WitnessSize *int `json:"witnessSize,omitempty"`
+	// Version, nLockTime and RBF signaling, nil unless parsed from the raw transaction
+	Signals *TxSignals `json:"signals,omitempty"`
+	// Number of inputs and outputs and the sigop cost, nil unless parsed from the raw transaction
+	Counts *TxCounts `json:"counts,omitempty"`
+	// Total value of the outputs in satoshis, nil unless parsed from the raw transaction
+	OutputValue *int64 `json:"outputValue,omitempty"`
+	// Dust outputs per script type, only set at ingest (see FindDust) and not read from the storage
+	Dust []DustOutputs `json:"-"`
+	// Total output value in satoshis if it exceeds the whale threshold of the daemon, otherwise 0.
+	// Only set at ingest and not read from the storage, see WhaleTransaction.
+	WhaleValue int64 `json:"-"`
+	// Results of the analyzers, only set at ingest, see StoredAnalysisResult
+	Analysis []AnalysisResult `json:"-"`
+	// Interval of the network first-seen time, nil unless estimated (see `bademeister estimate`)
+	FirstSeenEstimate *FirstSeenEstimate `json:"firstSeenEstimate,omitempty"`
+	// Number of best-chain blocks from the one with the transaction to the tip, 0 if unconfirmed.
+	// Set by the API.
+	Confirmations *int `json:"confirmations,omitempty"`
+	// Serialized transaction, nil unless received raw
+	Raw []byte `json:"-"`
+	// Time the transaction was parsed and queued for storing, zero unless received via ZMQ
+	Queued time.Time `json:"-"`
+}
+
+// DropReason is why a transaction left the mempool without a block
+type DropReason string
+
+// Drop reasons
+const (
+	// DropUnknown is a transaction that left the mempool for an unknown reason, e.g. evicted
+	// when the mempool was full or while the daemon was not running
+	DropUnknown DropReason = ""
+	// DropExpiry is a transaction that expired, see `-mempoolexpiry` of the node
+	DropExpiry DropReason = "expiry"
+	// DropReplaced is a transaction with a conflicting transaction that was seen later
+	DropReplaced DropReason = "replaced"
+	// DropConflicted is a transaction with a conflicting transaction in a block
+	DropConflicted DropReason = "conflicted"
+)
+
+// TxDrop is when and why a transaction left the mempool without a block
+type TxDrop struct {
+	Time   time.Time
+	Reason DropReason
+}
+
+// WitnessHeavyShare is the share of the weight above which witness data dominates a transaction
+const WitnessHeavyShare = 0.9
+
+// IsWitnessHeavy returns true if the witness data makes up more than WitnessHeavyShare of the weight,
+// which is typical for data-embedding protocols (inscriptions).
+// Returns false if the witness size is unknown.
+func (tx *Transaction) IsWitnessHeavy() bool {
+	if tx.WitnessSize == nil || tx.Weight == 0 {
+		return false
+	}
+	return float64(*tx.WitnessSize) > WitnessHeavyShare*float64(tx.Weight)
+}
+
+// Feerate returns the feerate in sat/vB, zero if the weight is unknown
+func (tx *Transaction) Feerate() float64 {
+	if tx.Weight == 0 {
+		return 0
+	}
+	return float64(tx.Fee) / (float64(tx.Weight) / 4)
+}
+
+// FeerateBucket returns the feerate in sat/vB rounded down, see FeerateBucket
+func (tx *Transaction) FeerateBucket() int {
+	return int(tx.Feerate())
+}
+
+// HigherFeerate returns true if `tx` has a higher feerate than `other`.
+// Transactions with equal feerates are ordered by txid, so that the order is total.
+func (tx *Transaction) HigherFeerate(other *Transaction) bool {
+	// compare fee/weight without floating point: fee1*weight2 > fee2*weight1
+	a := tx.Fee * uint64(other.Weight)
+	b := other.Fee * uint64(tx.Weight)
+	if a != b {
+		return a > b
+	}
+	return bytes.Compare(tx.TxID[:], other.TxID[:]) < 0
+}
+
+// TopByFeerate returns the transactions with the highest feerate that fit into `maxWeight`,
+// in descending feerate order. Stops at the first transaction that does not fit.
+func TopByFeerate(txs []Transaction, maxWeight int64) []Transaction {
+	sorted := make([]Transaction, len(txs))
+	copy(sorted, txs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].HigherFeerate(&sorted[j])
+	})
+
+	weight := int64(0)
+	for i, tx := range sorted {
+		weight += int64(tx.Weight)
+		if weight > maxWeight {
+			return sorted[:i]
+		}
+	}
+	return sorted
 }
 
 // StoredTransaction extends Transaction with  Database ID
@@ -21,3 +136,14 @@ type StoredTransaction struct {
 	DBID int64
 	Transaction
 }
+
+// WitnessHeavyPoint is the share of witness-heavy transactions in the mempool at a point in time
+type WitnessHeavyPoint struct {
+	Time time.Time `json:"time"`
+	// Number of transactions and their virtual size in the mempool
+	Transactions int   `json:"transactions"`
+	VSize        int64 `json:"vsize"`
+	// Number of witness-heavy transactions and their virtual size in the mempool
+	WitnessHeavyTransactions int   `json:"witnessHeavyTransactions"`
+	WitnessHeavyVSize        int64 `json:"witnessHeavyVsize"`
+}
@@ -0,0 +1,121 @@
+package types
+
+import (
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// MaxBlockSigOpCost is the maximum signature operation cost of a block (BIP141)
+const MaxBlockSigOpCost = blockchain.MaxBlockSigOpsCost
+
+// TxCounts are the number of inputs and outputs and the signature operation cost of a transaction
+type TxCounts struct {
+	Inputs  int `json:"inputs"`
+	Outputs int `json:"outputs"`
+	// Estimated signature operation cost (BIP141), see EstimateSigOpCost
+	SigOpCost int `json:"sigopCost"`
+}
+
+// NewTxCountsFromWireTx returns the TxCounts of a wire.MsgTx
+func NewTxCountsFromWireTx(wireTx *wire.MsgTx) *TxCounts {
+	return &TxCounts{
+		Inputs:    len(wireTx.TxIn),
+		Outputs:   len(wireTx.TxOut),
+		SigOpCost: EstimateSigOpCost(wireTx),
+	}
+}
+
+// EstimateSigOpCost returns the signature operation cost of `wireTx` as counted against the block limit
+// by Bitcoin Core. The previous outputs are not known, their P2SH and witness program types are inferred
+// from the spending inputs instead. The estimate is exact for the standard input types.
+func EstimateSigOpCost(wireTx *wire.MsgTx) int {
+	cost := 0
+	for _, txIn := range wireTx.TxIn {
+		cost += txscript.GetSigOpCount(txIn.SignatureScript) * blockchain.WitnessScaleFactor
+	}
+	for _, txOut := range wireTx.TxOut {
+		cost += txscript.GetSigOpCount(txOut.PkScript) * blockchain.WitnessScaleFactor
+	}
+	if blockchain.IsCoinBaseTx(wireTx) {
+		return cost
+	}
+
+	for _, txIn := range wireTx.TxIn {
+		pkScript := inferPrevPkScript(txIn)
+		if pkScript == nil {
+			continue
+		}
+		if txscript.IsPayToScriptHash(pkScript) {
+			cost += txscript.GetPreciseSigOpCount(txIn.SignatureScript, pkScript, true) * blockchain.WitnessScaleFactor
+		}
+		cost += txscript.GetWitnessSigOpCount(txIn.SignatureScript, pkScript, txIn.Witness)
+	}
+	return cost
+}
+
+// inferPrevPkScript returns a script of the type of the output spent by `txIn` whose sigops depend
+// on the input: P2SH, P2WPKH or P2WSH, possibly nested in P2SH. Returns nil for other types.
+func inferPrevPkScript(txIn *wire.TxIn) []byte {
+	if len(txIn.SignatureScript) == 0 {
+		if len(txIn.Witness) == 0 || isTaprootWitness(txIn.Witness) {
+			return nil
+		}
+		last := txIn.Witness[len(txIn.Witness)-1]
+		if len(txIn.Witness) == 2 && isPubKey(last) {
+			return witnessV0PkScript(btcutil.Hash160(last))
+		}
+		witnessScriptHash := sha256.Sum256(last)
+		return witnessV0PkScript(witnessScriptHash[:])
+	}
+
+	if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
+		return nil
+	}
+	pushes, err := txscript.PushedData(txIn.SignatureScript)
+	if err != nil || len(pushes) == 0 {
+		return nil
+	}
+	last := pushes[len(pushes)-1]
+	// the last push of P2PKH and P2PK inputs is a public key or a signature, not a redeem script
+	if len(txIn.Witness) == 0 && (len(last) == 0 || isPubKey(last) || isSignature(last)) {
+		return nil
+	}
+	return p2shPkScript(btcutil.Hash160(last))
+}
+
+// isTaprootWitness returns true if `witness` spends a taproot output (BIP341) via key or script path
+func isTaprootWitness(witness wire.TxWitness) bool {
+	// an annex starts with 0x50
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == 0x50 {
+		witness = witness[:len(witness)-1]
+	}
+	last := witness[len(witness)-1]
+	if len(witness) == 1 {
+		// a single Schnorr signature
+		return len(last) == 64 || len(last) == 65
+	}
+	// the control block of a script path spend
+	return len(last) >= 33 && (len(last)-33)%32 == 0 && last[0]&0xfe == 0xc0
+}
+
+func isPubKey(data []byte) bool {
+	return (len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03)) || (len(data) == 65 && data[0] == 0x04)
+}
+
+// isSignature returns true if `data` is a DER-encoded signature followed by a sighash type
+func isSignature(data []byte) bool {
+	return len(data) >= 9 && len(data) <= 73 && data[0] == 0x30 && int(data[1]) == len(data)-3
+}
+
+func witnessV0PkScript(program []byte) []byte {
+	return append([]byte{txscript.OP_0, byte(len(program))}, program...)
+}
+
+func p2shPkScript(scriptHash []byte) []byte {
+	script := append([]byte{txscript.OP_HASH160, txscript.OP_DATA_20}, scriptHash...)
+	return append(script, txscript.OP_EQUAL)
+}
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateSigOpCost(t *testing.T) {
+	pubKey := append([]byte{0x02}, bytes.Repeat([]byte{0x11}, 32)...)
+	// a DER signature with sighash type
+	sig := append([]byte{0x30, 0x44}, bytes.Repeat([]byte{0x22}, 0x45)...)
+	// OP_2 <pubkey> <pubkey> <pubkey> OP_3 OP_CHECKMULTISIG
+	multisig, err := txscript.NewScriptBuilder().AddOp(txscript.OP_2).
+		AddData(pubKey).AddData(pubKey).AddData(pubKey).
+		AddOp(txscript.OP_3).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	require.NoError(t, err)
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, make([]byte, 20)...), 0x88, 0xac)
+
+	newTx := func(scriptSig []byte, witness wire.TxWitness) *wire.MsgTx {
+		wireTx := wire.NewMsgTx(wire.TxVersion)
+		wireTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: chainhash.DoubleHashH([]byte("prev"))},
+			SignatureScript:  scriptSig,
+			Witness:          witness,
+		})
+		// the output counts 1 legacy sigop
+		wireTx.AddTxOut(wire.NewTxOut(1000, p2pkh))
+		return wireTx
+	}
+	pushes := func(data ...[]byte) []byte {
+		b := txscript.NewScriptBuilder()
+		for _, d := range data {
+			b.AddData(d)
+		}
+		script, err := b.Script()
+		require.NoError(t, err)
+		return script
+	}
+
+	for name, tc := range map[string]struct {
+		tx       *wire.MsgTx
+		expected int
+	}{
+		"p2pkh":  {newTx(pushes(sig, pubKey), nil), 4},
+		"p2sh":   {newTx(append([]byte{txscript.OP_0}, pushes(sig, sig, multisig)...), nil), 4 + 3*4},
+		"p2wpkh": {newTx(nil, wire.TxWitness{sig, pubKey}), 4 + 1},
+		"p2wsh":  {newTx(nil, wire.TxWitness{{}, sig, sig, multisig}), 4 + 3},
+		"p2sh-p2wpkh": {
+			newTx(pushes(witnessV0PkScript(make([]byte, 20))), wire.TxWitness{sig, pubKey}), 4 + 1,
+		},
+		"p2sh-p2wsh": {
+			newTx(pushes(witnessV0PkScript(make([]byte, 32))), wire.TxWitness{{}, sig, sig, multisig}), 4 + 3,
+		},
+		"taproot key path":    {newTx(nil, wire.TxWitness{bytes.Repeat([]byte{1}, 64)}), 4},
+		"taproot script path": {newTx(nil, wire.TxWitness{sig, multisig, append([]byte{0xc0}, make([]byte, 32)...)}), 4},
+	} {
+		assert.Equal(t, tc.expected, EstimateSigOpCost(tc.tx), name)
+	}
+
+	counts := NewTxCountsFromWireTx(newTx(pushes(sig, pubKey), nil))
+	assert.Equal(t, &TxCounts{Inputs: 1, Outputs: 1, SigOpCost: 4}, counts)
+}
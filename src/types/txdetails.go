@@ -0,0 +1,66 @@
+package types
+
+import (
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TxInput is an input of a transaction
+type TxInput struct {
+	PrevTxID  Hash32 `json:"prevTxid"`
+	PrevIndex uint32 `json:"prevIndex"`
+	Sequence  uint32 `json:"sequence"`
+}
+
+// TxOutput is an output of a transaction
+type TxOutput struct {
+	// Value in satoshis
+	Value int64 `json:"value"`
+	// Script class as returned by txscript.ScriptClass.String(), e.g. "pubkeyhash"
+	ScriptType string `json:"scriptType"`
+	ScriptSize int    `json:"scriptSize"`
+	// Output script, only set for transactions parsed from their serialization and not stored
+	Script []byte `json:"-"`
+}
+
+// TxDetails contains the inputs and outputs of a transaction.
+// Only available for transactions that were parsed from their serialization.
+type TxDetails struct {
+	Inputs  []TxInput  `json:"inputs"`
+	Outputs []TxOutput `json:"outputs"`
+}
+
+// NewTxDetailsFromWireTx returns the TxDetails of a wire.MsgTx
+func NewTxDetailsFromWireTx(wireTx *wire.MsgTx) *TxDetails {
+	details := TxDetails{
+		Inputs:  make([]TxInput, len(wireTx.TxIn)),
+		Outputs: make([]TxOutput, len(wireTx.TxOut)),
+	}
+
+	for i, txIn := range wireTx.TxIn {
+		details.Inputs[i] = TxInput{
+			PrevTxID:  NewHashFromArray(txIn.PreviousOutPoint.Hash),
+			PrevIndex: txIn.PreviousOutPoint.Index,
+			Sequence:  txIn.Sequence,
+		}
+	}
+
+	for i, txOut := range wireTx.TxOut {
+		details.Outputs[i] = TxOutput{
+			Value:      txOut.Value,
+			ScriptType: txscript.GetScriptClass(txOut.PkScript).String(),
+			ScriptSize: len(txOut.PkScript),
+			Script:     txOut.PkScript,
+		}
+	}
+
+	return &details
+}
+
+// OutputValue returns the total value of the outputs in satoshis
+func (d *TxDetails) OutputValue() (value int64) {
+	for _, out := range d.Outputs {
+		value += out.Value
+	}
+	return value
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTxDetailsFromWireTx(t *testing.T) {
+	prev := chainhash.DoubleHashH([]byte("prev"))
+
+	wireTx := wire.NewMsgTx(wire.TxVersion)
+	wireTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: prev, Index: 3},
+		Sequence:         0xfffffffd,
+	})
+	// OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, make([]byte, 20)...), 0x88, 0xac)
+	wireTx.AddTxOut(wire.NewTxOut(1000, p2pkh))
+	// OP_RETURN <4 bytes>
+	wireTx.AddTxOut(wire.NewTxOut(0, []byte{0x6a, 0x04, 0x01, 0x02, 0x03, 0x04}))
+
+	details := NewTxDetailsFromWireTx(wireTx)
+	assert.Equal(t, []TxInput{{
+		PrevTxID:  NewHashFromArray(prev),
+		PrevIndex: 3,
+		Sequence:  0xfffffffd,
+	}}, details.Inputs)
+	assert.Equal(t, []TxOutput{
+		{Value: 1000, ScriptType: "pubkeyhash", ScriptSize: 25, Script: p2pkh},
+		{Value: 0, ScriptType: "nulldata", ScriptSize: 6, Script: []byte{0x6a, 0x04, 0x01, 0x02, 0x03, 0x04}},
+	}, details.Outputs)
+}
+
+func TestNewOpReturnStatsFromWireTx(t *testing.T) {
+	wireTx := wire.NewMsgTx(wire.TxVersion)
+	wireTx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	assert.Equal(t, &OpReturnStats{}, NewOpReturnStatsFromWireTx(wireTx))
+
+	// OP_RETURN <4 bytes> <2 bytes>
+	wireTx.AddTxOut(wire.NewTxOut(0, []byte{0x6a, 0x04, 0x01, 0x02, 0x03, 0x04, 0x02, 0x05, 0x06}))
+	assert.Equal(t, &OpReturnStats{Outputs: 1, PayloadSize: 6}, NewOpReturnStatsFromWireTx(wireTx))
+}
+
+func TestWithoutDataCarriers(t *testing.T) {
+	txs := []Transaction{
+		{Fee: 1, OpReturn: &OpReturnStats{Outputs: 1, PayloadSize: 80}},
+		{Fee: 2, OpReturn: &OpReturnStats{}},
+		{Fee: 3},
+	}
+	assert.Equal(t, []Transaction{txs[1], txs[2]}, WithoutDataCarriers(txs))
+	assert.Equal(t, []Transaction{}, WithoutDataCarriers(nil))
+}
+
+func TestNewTxSignalsFromWireTx(t *testing.T) {
+	wireTx := wire.NewMsgTx(2)
+	wireTx.LockTime = 600000
+	wireTx.AddTxIn(&wire.TxIn{Sequence: wire.MaxTxInSequenceNum})
+	wireTx.AddTxIn(&wire.TxIn{Sequence: wire.MaxTxInSequenceNum - 1})
+	assert.Equal(t, &TxSignals{Version: 2, LockTime: 600000}, NewTxSignalsFromWireTx(wireTx))
+
+	wireTx.AddTxIn(&wire.TxIn{Sequence: MaxRBFSequence})
+	assert.Equal(t, &TxSignals{Version: 2, LockTime: 600000, RBF: true}, NewTxSignalsFromWireTx(wireTx))
+}
@@ -0,0 +1,31 @@
+package types
+
+import (
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MaxRBFSequence is the highest nSequence that signals replaceability (BIP125)
+const MaxRBFSequence = 0xfffffffd
+
+// TxSignals describes the version and the nLockTime and nSequence usage of a transaction
+type TxSignals struct {
+	Version  int32  `json:"version"`
+	LockTime uint32 `json:"lockTime"`
+	// True if at least one input has a nSequence of at most MaxRBFSequence
+	RBF bool `json:"rbf"`
+}
+
+// NewTxSignalsFromWireTx returns the TxSignals of a wire.MsgTx
+func NewTxSignalsFromWireTx(wireTx *wire.MsgTx) *TxSignals {
+	signals := TxSignals{
+		Version:  wireTx.Version,
+		LockTime: wireTx.LockTime,
+	}
+	for _, txIn := range wireTx.TxIn {
+		if txIn.Sequence <= MaxRBFSequence {
+			signals.RBF = true
+			break
+		}
+	}
+	return &signals
+}
@@ -0,0 +1,27 @@
+package types
+
+import "github.com/btcsuite/btcd/wire"
+
+// TxSizes are the sizes of a serialized transaction in bytes
+type TxSizes struct {
+	// Size with witness data
+	Size int
+	// Size without marker, flag and witness data
+	StrippedSize int
+}
+
+// NewTxSizes returns the sizes of `wireTx`, which was deserialized from `raw`.
+// The size is the known length of `raw`, so only the stripped size is computed from wireTx.
+func NewTxSizes(wireTx *wire.MsgTx, raw []byte) TxSizes {
+	return TxSizes{Size: len(raw), StrippedSize: wireTx.SerializeSizeStripped()}
+}
+
+// Weight returns the weight of the transaction (BIP141)
+func (s TxSizes) Weight() int {
+	return s.StrippedSize*3 + s.Size
+}
+
+// WitnessSize returns the size of the witness data including marker and flag
+func (s TxSizes) WitnessSize() int {
+	return s.Size - s.StrippedSize
+}
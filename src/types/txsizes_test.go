@@ -0,0 +1,72 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWireTx returns a transaction with `inputs` inputs and two outputs, with a witness if `segwit`
+func newTestWireTx(inputs int, segwit bool) *wire.MsgTx {
+	wireTx := wire.NewMsgTx(wire.TxVersion)
+	for i := 0; i < inputs; i++ {
+		in := &wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: chainhash.DoubleHashH([]byte{byte(i)}), Index: uint32(i)},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}
+		if segwit {
+			in.Witness = wire.TxWitness{make([]byte, 72), make([]byte, 33)}
+		} else {
+			in.SignatureScript = make([]byte, 107)
+		}
+		wireTx.AddTxIn(in)
+	}
+	wireTx.AddTxOut(wire.NewTxOut(1000, make([]byte, 22)))
+	// a script longer than 252 bytes has a 3 byte length prefix
+	wireTx.AddTxOut(wire.NewTxOut(0, make([]byte, 300)))
+	return wireTx
+}
+
+func serializeTx(t testing.TB, wireTx *wire.MsgTx) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, wireTx.Serialize(&buf))
+	return buf.Bytes()
+}
+
+func TestNewTxSizes(t *testing.T) {
+	for _, segwit := range []bool{false, true} {
+		wireTx := newTestWireTx(3, segwit)
+		sizes := NewTxSizes(wireTx, serializeTx(t, wireTx))
+		assert.Equal(t, wireTx.SerializeSize(), sizes.Size)
+		assert.Equal(t, wireTx.SerializeSizeStripped(), sizes.StrippedSize)
+		assert.Equal(t, wireTx.SerializeSizeStripped()*3+wireTx.SerializeSize(), sizes.Weight())
+		assert.Equal(t, wireTx.SerializeSize()-wireTx.SerializeSizeStripped(), sizes.WitnessSize())
+	}
+}
+
+// BenchmarkTxSizes_Wire is the former computation in zmqsubscriber.parseTransaction,
+// which walks all scripts and witnesses four times
+func BenchmarkTxSizes_Wire(b *testing.B) {
+	wireTx := newTestWireTx(10, true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = wireTx.SerializeSizeStripped()*3 + wireTx.SerializeSize()
+		_ = wireTx.SerializeSize() - wireTx.SerializeSizeStripped()
+	}
+}
+
+func BenchmarkTxSizes(b *testing.B) {
+	wireTx := newTestWireTx(10, true)
+	raw := serializeTx(b, wireTx)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sizes := NewTxSizes(wireTx, raw)
+		_, _ = sizes.Weight(), sizes.WitnessSize()
+	}
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// UTXODelta is the change of the UTXO set by a block
+type UTXODelta struct {
+	// Outputs added to the UTXO set, all outputs except provably unspendable ones
+	Created int `json:"created"`
+	// Outputs removed from the UTXO set, the inputs of all transactions except the coinbase
+	Spent int `json:"spent"`
+	// Value in satoshis of the created outputs
+	CreatedValue int64 `json:"createdValue"`
+	// Value of the spent outputs: the outputs of all transactions except the coinbase plus the fees
+	SpentValue int64 `json:"spentValue"`
+	// Value sent to provably unspendable outputs (OP_RETURN), which never enter the UTXO set
+	UnspendableValue int64 `json:"unspendableValue"`
+	// Fees claimed by the coinbase: its output value minus the block subsidy
+	Fees int64 `json:"fees"`
+}
+
+// isUnspendable returns true if an output with `pkScript` is never added to the UTXO set,
+// like in Bitcoin Core: scripts starting with OP_RETURN and scripts above the size limit.
+// Unlike txscript.IsUnspendable, scripts that fail to parse are added.
+func isUnspendable(pkScript []byte) bool {
+	return len(pkScript) > txscript.MaxScriptSize || (len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN)
+}
+
+// NewUTXODelta returns the UTXO set delta of `wireBlock` at `height`.
+// The values of the spent outputs are not part of the block, their sum is derived from the fees,
+// which assumes the subsidy schedule of mainnet (also used by testnet and signet).
+func NewUTXODelta(wireBlock *wire.MsgBlock, height uint32) *UTXODelta {
+	var d UTXODelta
+	var coinbaseValue, outputValue int64
+	for _, tx := range wireBlock.Transactions {
+		coinbase := blockchain.IsCoinBaseTx(tx)
+		if !coinbase {
+			d.Spent += len(tx.TxIn)
+		}
+		for _, out := range tx.TxOut {
+			if coinbase {
+				coinbaseValue += out.Value
+			} else {
+				outputValue += out.Value
+			}
+			if isUnspendable(out.PkScript) {
+				d.UnspendableValue += out.Value
+				continue
+			}
+			d.Created++
+			d.CreatedValue += out.Value
+		}
+	}
+
+	d.Fees = coinbaseValue - blockchain.CalcBlockSubsidy(int32(height), &chaincfg.MainNetParams)
+	if d.Fees < 0 {
+		// the subsidy was not fully claimed or the network has another schedule
+		d.Fees = 0
+	}
+	d.SpentValue = outputValue + d.Fees
+	return &d
+}
+
+// Net returns the change of the number of unspent outputs
+func (d *UTXODelta) Net() int {
+	return d.Created - d.Spent
+}
+
+// NetValue returns the change of the value of the UTXO set
+func (d *UTXODelta) NetValue() int64 {
+	return d.CreatedValue - d.SpentValue
+}
+
+// UTXOBucket aggregates the UTXO set deltas of the best-chain blocks first seen in an interval
+type UTXOBucket struct {
+	// Start of the interval
+	Time   time.Time `json:"time"`
+	Blocks int       `json:"blocks"`
+	UTXODelta
+	// Created minus spent outputs
+	Net int `json:"net"`
+	// Created minus spent value
+	NetValue int64 `json:"netValue"`
+}
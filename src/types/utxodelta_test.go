@@ -0,0 +1,40 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUTXODelta(t *testing.T) {
+	var coinbase wire.MsgTx
+	require.NoError(t, coinbase.Deserialize(bytes.NewReader(coinbaseTx)))
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 0}, nil, nil))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(70000, append([]byte{txscript.OP_0, txscript.OP_DATA_20}, make([]byte, 20)...)))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_RETURN}))
+
+	block := wire.MsgBlock{Transactions: []*wire.MsgTx{&coinbase, tx}}
+	// the coinbase claims 12.5 BTC subsidy and fees, its two OP_RETURN outputs have no value
+	d := NewUTXODelta(&block, 605453)
+	assert.Equal(t, UTXODelta{
+		Created:          2,
+		Spent:            2,
+		CreatedValue:     1293605051 + 70000,
+		SpentValue:       71000 + 43605051,
+		UnspendableValue: 1000,
+		Fees:             43605051,
+	}, *d)
+	assert.Equal(t, 0, d.Net())
+	assert.Equal(t, int64(1250000000-1000), d.NetValue())
+
+	// the subsidy halves every 210000 blocks
+	d = NewUTXODelta(&block, 840000)
+	assert.Equal(t, int64(1293605051-312500000), d.Fees)
+}
@@ -0,0 +1,23 @@
+package types
+
+import (
+	"time"
+)
+
+// ValueFlowTotals are the number and total output value of transactions
+type ValueFlowTotals struct {
+	Transactions int `json:"transactions"`
+	// Sum of the output values in satoshis
+	Value int64 `json:"value"`
+}
+
+// ValueFlowBucket is the output value entering the mempool and being confirmed in an interval
+type ValueFlowBucket struct {
+	// Start of the interval
+	Time time.Time `json:"time"`
+	// Transactions first seen in the interval whose output value is known
+	Entered ValueFlowTotals `json:"entered"`
+	// Transactions except the coinbase of the best-chain blocks first seen in the interval
+	// whose UTXO set delta is known
+	Confirmed ValueFlowTotals `json:"confirmed"`
+}
@@ -0,0 +1,24 @@
+package types
+
+import (
+	"time"
+)
+
+// WhaleTransaction is a transaction whose outputs exceeded the whale threshold when it was received
+type WhaleTransaction struct {
+	TxID      Hash32    `json:"txid"`
+	FirstSeen time.Time `json:"firstSeen"`
+	// Total output value in satoshis
+	Value  int64  `json:"value"`
+	Fee    uint64 `json:"fee"`
+	Weight int    `json:"weight"`
+	// Best-chain block with the transaction, nil while unconfirmed
+	Block *WhaleConfirmation `json:"block"`
+}
+
+// WhaleConfirmation is the block that confirmed a WhaleTransaction
+type WhaleConfirmation struct {
+	Hash      Hash32    `json:"hash"`
+	Height    uint32    `json:"height"`
+	FirstSeen time.Time `json:"firstSeen"`
+}
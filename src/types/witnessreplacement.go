@@ -0,0 +1,15 @@
+package types
+
+import "time"
+
+// WitnessReplacement records a transaction that arrived again with a different witness:
+// same txid, different wtxid and possibly weight. The new version replaces the stored one.
+type WitnessReplacement struct {
+	TxID Hash32 `json:"txid"`
+	// First-seen time of the new version
+	Time      time.Time `json:"time"`
+	OldWTxID  Hash32    `json:"oldWtxid"`
+	NewWTxID  Hash32    `json:"newWtxid"`
+	OldWeight int       `json:"oldWeight"`
+	NewWeight int       `json:"newWeight"`
+}
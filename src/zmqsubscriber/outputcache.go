@@ -0,0 +1,56 @@
+package zmqsubscriber
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// outputCache keeps the output values of recently received transactions, which are the likely
+// parents of the next ones in the mempool, so that their prevouts need no requests to the node.
+// It holds between `size` and 2*`size` transactions: once the current generation is full, it
+// replaces the previous one.
+type outputCache struct {
+	mu       sync.Mutex
+	size     int
+	current  map[chainhash.Hash][]int64
+	previous map[chainhash.Hash][]int64
+}
+
+func newOutputCache(size int) *outputCache {
+	return &outputCache{
+		size:    size,
+		current: make(map[chainhash.Hash][]int64, size),
+	}
+}
+
+// add caches the values of `outputs` of the transaction `txid`
+func (c *outputCache) add(txid chainhash.Hash, outputs []*wire.TxOut) {
+	values := make([]int64, len(outputs))
+	for i, out := range outputs {
+		values[i] = out.Value
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.current) >= c.size {
+		c.previous = c.current
+		c.current = make(map[chainhash.Hash][]int64, c.size)
+	}
+	c.current[txid] = values
+}
+
+// value returns the value of the output `outpoint`, or false if its transaction is not cached
+func (c *outputCache) value(outpoint wire.OutPoint) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values, ok := c.current[outpoint.Hash]
+	if !ok {
+		values, ok = c.previous[outpoint.Hash]
+	}
+	if !ok || int(outpoint.Index) >= len(values) {
+		return 0, false
+	}
+	return values[outpoint.Index], true
+}
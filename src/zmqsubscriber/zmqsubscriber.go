@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/pkg/errors"
 
+	"github.com/0xb10c/bademeister-go/src/metrics"
 	"github.com/0xb10c/bademeister-go/src/types"
 
 	"github.com/pebbe/zmq4"
@@ -23,13 +26,98 @@ type ZMQSubscriber struct {
 	IncomingTx chan types.Transaction
 	// Deserialized blocks
 	IncomingBlocks chan types.Block
-	topics         []string
-	socket         *zmq4.Socket
-	cancel         bool
+	// Hashes of announced blocks, see WithBlockHashes
+	IncomingBlockHashes chan BlockHash
+	// Time from receiving a transaction message until it is parsed
+	ReceiveLatency *metrics.Histogram
+	// Time spent parsing transactions
+	ParseLatency *metrics.Histogram
+	topics       []string
+	endpoints    []string
+	socket       *zmq4.Socket
+	cancel       bool
+	// see WithHashOnly
+	hashOnly bool
+	// see WithStandardTopics
+	prevouts PrevoutFetcher
+	outputs  *outputCache
 }
 
-const topicRawTxWithFee = "rawtxwithfee"
-const topicRawBlock = "rawblock"
+// BlockHash is a block announced via `hashblock`
+type BlockHash struct {
+	Hash      types.Hash32
+	FirstSeen time.Time
+}
+
+// Topics published by Bitcoin Core. `rawtxwithfee` requires a patched node.
+const (
+	TopicRawTx        = "rawtx"
+	TopicRawTxWithFee = "rawtxwithfee"
+	TopicRawBlock     = "rawblock"
+	TopicHashBlock    = "hashblock"
+)
+
+// Option configures a ZMQSubscriber
+type Option func(z *ZMQSubscriber)
+
+// WithBlocksOnly subscribes to `rawblock` only, for nodes that do not publish `rawtxwithfee`.
+// Transactions have to be fetched otherwise, IncomingTx stays empty.
+func WithBlocksOnly() Option {
+	return func(z *ZMQSubscriber) {
+		z.topics = []string{TopicRawBlock}
+	}
+}
+
+// WithBlockHashes subscribes to `hashblock` instead of `rawblock`. Blocks are announced on
+// IncomingBlockHashes as soon as they arrive, without waiting for the block to be deserialized,
+// and have to be fetched otherwise. IncomingBlocks stays empty.
+func WithBlockHashes() Option {
+	return func(z *ZMQSubscriber) {
+		for i, topic := range z.topics {
+			if topic == TopicRawBlock {
+				z.topics[i] = TopicHashBlock
+			}
+		}
+	}
+}
+
+// WithHashOnly skips deserializing transactions: only the txid, fee and sizes are read from the
+// raw bytes (see types.NewRawTxSummary), which takes a fraction of the time and allocations.
+// Details, OpReturn, Signals and Counts of the received transactions are nil.
+func WithHashOnly() Option {
+	return func(z *ZMQSubscriber) {
+		z.hashOnly = true
+	}
+}
+
+// PrevoutFetcher reads transactions from the mempool of the node, e.g.
+// bitcoinrpcclient.BitcoinRPCClient
+type PrevoutFetcher interface {
+	// InMempool returns true if the transaction `txid` is in the mempool
+	InMempool(txid chainhash.Hash) (bool, error)
+	// PrevoutValues returns the values in satoshis of the outputs spent by a transaction in the
+	// mempool
+	PrevoutValues(outpoints []wire.OutPoint) ([]int64, error)
+}
+
+// WithStandardTopics subscribes to `rawtx` instead of `rawtxwithfee`, for an unpatched node.
+// The fee of a transaction is the value of its prevouts minus the value of its outputs. Prevouts
+// created by recently received transactions are cached, the others are read with `prevouts`, which
+// costs requests to the node per transaction, so at most rawTxWorkers messages are parsed at once.
+// The node also publishes the transactions of connected blocks and their coinbase via `rawtx`:
+// transactions that are not in the mempool of the node are skipped.
+// WithHashOnly does not apply, since the inputs have to be deserialized.
+func WithStandardTopics(prevouts PrevoutFetcher) Option {
+	return func(z *ZMQSubscriber) {
+		for i, topic := range z.topics {
+			if topic == TopicRawTxWithFee {
+				z.topics[i] = TopicRawTx
+			}
+		}
+		z.prevouts = prevouts
+		z.outputs = newOutputCache(outputCacheSize)
+	}
+}
 
 // In order to allow non-blocking writes to channels, initialize them
 // with a certain capacity. During long-running synchronous calls (GetRawMempoolVerbose()),
@@ -37,50 +125,79 @@ const topicRawBlock = "rawblock"
 const channelSizeTx = 256
 const channelSizeBlock = 256
 
+// rawTxWorkers bounds the `rawtx` messages parsed at once, see WithStandardTopics
+const rawTxWorkers = 8
+
+// outputCacheSize is the number of recently received transactions whose outputs are cached in the
+// `rawtx` mode, see outputCache
+const outputCacheSize = 50000
+
+// readers are reused for deserializing transactions, which arrive hundreds per second in floods
+var readers = sync.Pool{
+	New: func() interface{} { return new(bytes.Reader) },
+}
+
 // ErrChannelCapacityExceeded is returned when channel write is blocked
 type ErrChannelCapacityExceeded string
 
 func (e ErrChannelCapacityExceeded) Error() string {
-	return fmt.Sprintf("channel capacity exceeded (%s)", e)
+	return fmt.Sprintf("channel capacity exceeded (%s)", string(e))
+}
+
+// WithEndpoints connects to `addresses` in addition to the address passed to NewZMQSubscriber,
+// for nodes that publish the topics on different endpoints
+func WithEndpoints(addresses ...string) Option {
+	return func(z *ZMQSubscriber) {
+		z.endpoints = append(z.endpoints, addresses...)
+	}
 }
 
 // NewZMQSubscriber creates and returns a new ZMQSubscriber,
 // which subscribes and connect to a Bitcoin Core ZMQ interface.
-func NewZMQSubscriber(zmqAddress string) (*ZMQSubscriber, error) {
+// By default, the topics `rawtxwithfee` and `rawblock` are subscribed, see WithStandardTopics for
+// an unpatched node.
+func NewZMQSubscriber(zmqAddress string, options ...Option) (*ZMQSubscriber, error) {
+	z := &ZMQSubscriber{
+		topics:         []string{TopicRawTxWithFee, TopicRawBlock},
+		endpoints:      []string{zmqAddress},
+		IncomingTx:     make(chan types.Transaction, channelSizeTx),
+		IncomingBlocks: make(chan types.Block, channelSizeBlock),
+
+		IncomingBlockHashes: make(chan BlockHash, channelSizeBlock),
+		ReceiveLatency:      metrics.NewHistogram(),
+		ParseLatency:        metrics.NewHistogram(),
+	}
+	for _, option := range options {
+		option(z)
+	}
+
 	socket, err := zmq4.NewSocket(zmq4.SUB)
 	if err != nil {
 		return nil, err
 	}
 
-	topics := []string{topicRawTxWithFee, topicRawBlock}
-	for _, topic := range topics {
+	for _, topic := range z.topics {
 		err := socket.SetSubscribe(topic)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if err = socket.Connect(zmqAddress); err != nil {
-		return nil, errors.Errorf("could not connect ZMQ subscriber to '%s': %s", zmqAddress, err)
+	for _, endpoint := range z.endpoints {
+		if err = socket.Connect(endpoint); err != nil {
+			return nil, errors.Errorf("could not connect ZMQ subscriber to '%s': %s", endpoint, err)
+		}
 	}
 
-	log.Infof("ZMQ subscriber successfully connected to %s", zmqAddress)
-
-	incomingTx := make(chan types.Transaction, channelSizeTx)
-	incomingBlocks := make(chan types.Block, channelSizeBlock)
+	log.Infof("ZMQ subscriber successfully connected to %v (topics %v)", z.endpoints, z.topics)
 
-	return &ZMQSubscriber{
-		topics:         topics,
-		IncomingTx:     incomingTx,
-		IncomingBlocks: incomingBlocks,
-		socket:         socket,
-		cancel:         false,
-	}, nil
+	z.socket = socket
+	return z, nil
 }
 
 // Run starts receiving new ZMQ messages. These messages are parsed according to
 // their topic and passed as native data types into the corresponding channels
-// (`IncomingTx` or `IncomingBlocks`). Run returns an error if an error occurs
+// (`IncomingTx`, `IncomingBlocks` or `IncomingBlockHashes`). Run returns an error if an error occurs
 // while parsing. On normal stops with `Stop()` `nil` is returned.
 func (z *ZMQSubscriber) Run() error {
 	defer func() {
@@ -90,9 +207,10 @@ func (z *ZMQSubscriber) Run() error {
 	}()
 
 	parseErrors := make(chan error)
+	rawTxWorkerSlots := make(chan struct{}, rawTxWorkers)
 
 	if err := z.socket.SetRcvtimeo(time.Second); err != nil {
-		return fmt.Errorf("could not set a receive timeout: %s", err)
+		return errors.Errorf("could not set a receive timeout: %s", err)
 	}
 
 	// FIXME(#11):
@@ -102,7 +220,11 @@ func (z *ZMQSubscriber) Run() error {
 	for !z.cancel {
 		select {
 		case err := <-parseErrors:
-			return err
+			if errors.Cause(err) != types.ErrParse {
+				return err
+			}
+			// a malformed message does not affect later messages
+			log.Errorf("Could not parse ZMQ message (skipped): %s", err)
 		default:
 		}
 
@@ -114,16 +236,25 @@ func (z *ZMQSubscriber) Run() error {
 			} else if err == zmq4.Errno(syscall.EINTR) {
 				continue
 			}
-			return fmt.Errorf("could not receive ZMQ message: %s", err)
+			return errors.Errorf("could not receive ZMQ message: %s", err)
 		}
 
+		received := time.Now()
 		topic, payload := string(msg[0]), msg[1:]
 		log.Debugf("ZMQ subscriber received topic %s", topic)
 
 		// received messages are processed asynchronously so that the queue does not
-		// stall while parsing
+		// stall while parsing. `rawtx` messages wait for a free worker, since each one costs
+		// requests to the node.
+		rawTx := topic == TopicRawTx
+		if rawTx {
+			rawTxWorkerSlots <- struct{}{}
+		}
 		go func() {
-			if err := z.processMessage(topic, payload); err != nil {
+			if rawTx {
+				defer func() { <-rawTxWorkerSlots }()
+			}
+			if err := z.processMessage(topic, payload, received); err != nil {
 				parseErrors <- err
 			}
 		}()
@@ -132,16 +263,28 @@ func (z *ZMQSubscriber) Run() error {
 	return nil
 }
 
-func (z *ZMQSubscriber) processMessage(topic string, payload [][]byte) error {
+func (z *ZMQSubscriber) processMessage(topic string, payload [][]byte, received time.Time) error {
 	// TODO: use GetTime() and allow other time sources (eg NTP-corrected)
 	firstSeen := time.Now().UTC()
 
 	switch topic {
-	case topicRawTxWithFee:
-		tx, err := parseTransaction(firstSeen, payload)
+	case TopicRawTxWithFee, TopicRawTx:
+		z.ReceiveLatency.Observe(firstSeen.Sub(received))
+		parse := parseTransaction
+		if topic == TopicRawTx {
+			parse = z.parseRawTx
+		} else if z.hashOnly {
+			parse = parseTransactionHashOnly
+		}
+		tx, err := parse(firstSeen, payload)
+		if err == errSkipped {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+		tx.Queued = time.Now()
+		z.ParseLatency.Observe(tx.Queued.Sub(firstSeen))
 
 		if len(z.IncomingTx) > (channelSizeTx / 2) {
 			log.Warnf("chan IncomingTx at %d/%d", len(z.IncomingTx), channelSizeTx)
@@ -152,7 +295,7 @@ func (z *ZMQSubscriber) processMessage(topic string, payload [][]byte) error {
 		default:
 			return ErrChannelCapacityExceeded("IncomingTx")
 		}
-	case topicRawBlock:
+	case TopicRawBlock:
 		block, err := parseBlock(firstSeen, payload)
 		if err != nil {
 			return err
@@ -167,8 +310,19 @@ func (z *ZMQSubscriber) processMessage(topic string, payload [][]byte) error {
 		default:
 			return ErrChannelCapacityExceeded("IncomingBlocks")
 		}
+	case TopicHashBlock:
+		hash, err := parseBlockHash(payload)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case z.IncomingBlockHashes <- BlockHash{Hash: hash, FirstSeen: firstSeen}:
+		default:
+			return ErrChannelCapacityExceeded("IncomingBlockHashes")
+		}
 	default:
-		return fmt.Errorf("unknown topic %s", topic)
+		return errors.Wrapf(types.ErrParse, "unknown topic %s", topic)
 	}
 
 	return nil
@@ -180,9 +334,10 @@ func (z *ZMQSubscriber) Stop() {
 	z.cancel = true
 }
 
-func parseTransaction(firstSeen time.Time, payload [][]byte) (*types.Transaction, error) {
+// splitRawTxWithFee returns the raw transaction and the fee of a `rawtxwithfee` message
+func splitRawTxWithFee(payload [][]byte) ([]byte, uint64, error) {
 	if len(payload) != 2 {
-		return nil, fmt.Errorf("unexpected payload length: expected len(tx hash, sequence) == 2 but got len(payload) == %d", len(payload))
+		return nil, 0, errors.Wrapf(types.ErrParse, "unexpected payload length: expected len(tx hash, sequence) == 2 but got len(payload) == %d", len(payload))
 	}
 
 	// payload[1] contains a 16bit LE sequence number provided by Bitcoin Core,
@@ -194,30 +349,184 @@ func parseTransaction(firstSeen time.Time, payload [][]byte) (*types.Transaction
 
 	length := len(rawtxwithfee)
 	if length <= 8 {
-		return nil, errors.New("unexpected rawtxwithfee length")
+		return nil, 0, errors.Wrap(types.ErrParse, "unexpected rawtxwithfee length")
 	}
-	rawtx, feeBytes := rawtxwithfee[:length-8], rawtxwithfee[length-8:]
+	return rawtxwithfee[:length-8], binary.LittleEndian.Uint64(rawtxwithfee[length-8:]), nil
+}
+
+// errSkipped is returned by parseRawTx for transactions that are not in the mempool of the node or
+// whose fee could not be computed
+var errSkipped = errors.New("transaction skipped")
 
+// parseRawTx parses a `rawtx` message and computes the fee from the prevouts, see
+// WithStandardTopics
+func (z *ZMQSubscriber) parseRawTx(firstSeen time.Time, payload [][]byte) (*types.Transaction, error) {
+	if len(payload) != 2 {
+		return nil, errors.Wrapf(types.ErrParse, "unexpected payload length: expected len(tx, sequence) == 2 but got len(payload) == %d", len(payload))
+	}
+	rawtx := payload[0]
+	wireTx, err := deserializeTransaction(rawtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if isCoinbase(wireTx) {
+		return nil, errSkipped
+	}
+	txid := wireTx.TxHash()
+	inMempool, err := z.prevouts.InMempool(txid)
+	if err != nil {
+		log.Debugf("Could not look up %s in the mempool (skipped): %s", txid, err)
+		return nil, errSkipped
+	}
+	if !inMempool {
+		// published for a connected block, or already confirmed or replaced
+		log.Debugf("Transaction %s is not in the mempool (skipped)", txid)
+		return nil, errSkipped
+	}
+	values, err := z.prevoutValues(wireTx)
+	if err != nil {
+		log.Debugf("Could not read the prevouts of %s (skipped): %s", txid, err)
+		return nil, errSkipped
+	}
+	fee := int64(0)
+	for _, value := range values {
+		fee += value
+	}
+	for _, out := range wireTx.TxOut {
+		fee -= out.Value
+	}
+	if fee < 0 {
+		return nil, errors.Wrapf(types.ErrParse, "transaction %s spends more than its inputs", txid)
+	}
+	z.outputs.add(txid, wireTx.TxOut)
+	return newTransaction(firstSeen, rawtx, wireTx, uint64(fee)), nil
+}
+
+// prevoutValues returns the values of the prevouts of `tx`. Those created by recently received
+// transactions are read from z.outputs, the others with z.prevouts.
+func (z *ZMQSubscriber) prevoutValues(tx *wire.MsgTx) ([]int64, error) {
+	values := make([]int64, len(tx.TxIn))
+	var missing []wire.OutPoint
+	var missingInputs []int
+	for i, in := range tx.TxIn {
+		if value, ok := z.outputs.value(in.PreviousOutPoint); ok {
+			values[i] = value
+			continue
+		}
+		missing = append(missing, in.PreviousOutPoint)
+		missingInputs = append(missingInputs, i)
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	fetched, err := z.prevouts.PrevoutValues(missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missingInputs {
+		values[i] = fetched[j]
+	}
+	return values, nil
+}
+
+// isCoinbase returns true for the coinbase transaction of a block, which has no prevout
+func isCoinbase(tx *wire.MsgTx) bool {
+	return len(tx.TxIn) == 1 && tx.TxIn[0].PreviousOutPoint.Index == wire.MaxPrevOutIndex &&
+		tx.TxIn[0].PreviousOutPoint.Hash == chainhash.Hash{}
+}
+
+func parseTransaction(firstSeen time.Time, payload [][]byte) (*types.Transaction, error) {
+	rawtx, fee, err := splitRawTxWithFee(payload)
+	if err != nil {
+		return nil, err
+	}
+	wireTx, err := deserializeTransaction(rawtx)
+	if err != nil {
+		return nil, err
+	}
+	return newTransaction(firstSeen, rawtx, wireTx, fee), nil
+}
+
+// deserializeTransaction deserializes `rawtx` with a pooled reader
+func deserializeTransaction(rawtx []byte) (*wire.MsgTx, error) {
+	r := readers.Get().(*bytes.Reader)
+	r.Reset(rawtx)
 	wireTx := wire.NewMsgTx(wire.TxVersion)
-	if err := wireTx.Deserialize(bytes.NewReader(rawtx)); err != nil {
-		return nil, fmt.Errorf("could not deserialize the rawtx as wire.MsgTx: %s", err)
+	err := wireTx.Deserialize(r)
+	// the pooled reader must not keep the message alive
+	r.Reset(nil)
+	readers.Put(r)
+	if err != nil {
+		return nil, errors.Wrapf(types.ErrParse, "could not deserialize the rawtx as wire.MsgTx: %s", err)
 	}
+	return wireTx, nil
+}
 
+// newTransaction returns the transaction `wireTx` with the serialization `rawtx` and `fee`
+func newTransaction(firstSeen time.Time, rawtx []byte, wireTx *wire.MsgTx, fee uint64) *types.Transaction {
 	txid := types.NewHashFromArray(wireTx.TxHash())
 
-	fee := binary.LittleEndian.Uint64(feeBytes)
-	weight := wireTx.SerializeSizeStripped()*3 + wireTx.SerializeSize()
+	sizes := types.NewTxSizes(wireTx, rawtx)
+	witnessSize := sizes.WitnessSize()
+	details := types.NewTxDetailsFromWireTx(wireTx)
+	outputValue := details.OutputValue()
+
+	return &types.Transaction{
+		FirstSeen:   firstSeen,
+		TxID:        txid,
+		WTxID:       types.NewWTxID(rawtx, wireTx.HasWitness()),
+		Fee:         fee,
+		Weight:      sizes.Weight(),
+		Details:     details,
+		OpReturn:    types.NewOpReturnStatsFromWireTx(wireTx),
+		WitnessSize: &witnessSize,
+		Signals:     types.NewTxSignalsFromWireTx(wireTx),
+		Counts:      types.NewTxCountsFromWireTx(wireTx),
+		OutputValue: &outputValue,
+		Raw:         rawtx,
+	}
+}
+
+func parseTransactionHashOnly(firstSeen time.Time, payload [][]byte) (*types.Transaction, error) {
+	rawtx, fee, err := splitRawTxWithFee(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := types.NewRawTxSummary(rawtx)
+	if err != nil {
+		return nil, err
+	}
+	witnessSize := summary.WitnessSize()
 
 	return &types.Transaction{
-		FirstSeen: firstSeen,
-		TxID:      txid,
-		Fee:       fee,
-		Weight:    weight,
+		FirstSeen:   firstSeen,
+		TxID:        summary.TxID,
+		WTxID:       summary.SegwitWTxID(),
+		Fee:         fee,
+		Weight:      summary.Weight(),
+		WitnessSize: &witnessSize,
+		Raw:         rawtx,
 	}, nil
 }
 
 func parseBlock(firstSeen time.Time, msg [][]byte) (*types.Block, error) {
+	if len(msg) != 2 {
+		return nil, errors.Wrapf(types.ErrParse, "unexpected payload length: expected len(block, sequence) == 2 but got len(payload) == %d", len(msg))
+	}
 	rawblock, ctr := msg[0], msg[1]
 	_ = ctr
 	return types.NewBlockFromBytes(firstSeen, rawblock)
 }
+
+func parseBlockHash(msg [][]byte) (types.Hash32, error) {
+	if len(msg) != 2 {
+		return types.Hash32{}, errors.Wrapf(types.ErrParse, "unexpected payload length: expected len(hash, sequence) == 2 but got len(payload) == %d", len(msg))
+	}
+	if len(msg[0]) != 32 {
+		return types.Hash32{}, errors.Wrapf(types.ErrParse, "unexpected block hash length %d", len(msg[0]))
+	}
+	// published in RPC byte order
+	return types.NewHashFromBytes(msg[0]).Reversed(), nil
+}
@@ -1,10 +1,14 @@
 package zmqsubscriber
 
 import (
+	"bytes"
+	"encoding/binary"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -53,7 +57,7 @@ func setupAndRunZMQSubscriber(t *testing.T, zmqAddress string) (*ZMQSubscriber,
 
 	go func() {
 		if err := z.Run(); err != nil {
-			t.Fatalf("ZMQSubscriber exited with error: %s", err)
+			t.Errorf("ZMQSubscriber exited with error: %s", err)
 		}
 	}()
 
@@ -154,3 +158,136 @@ func TestZMQSubscriber(t *testing.T) {
 
 	}
 }
+
+// newRawTxWithFee returns a `rawtxwithfee` payload of a segwit transaction with `inputs` inputs
+func newRawTxWithFee(t testing.TB, inputs int) [][]byte {
+	wireTx := wire.NewMsgTx(wire.TxVersion)
+	for i := 0; i < inputs; i++ {
+		wireTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: chainhash.DoubleHashH([]byte{byte(i)}), Index: uint32(i)},
+			Witness:          wire.TxWitness{make([]byte, 72), make([]byte, 33)},
+			Sequence:         wire.MaxTxInSequenceNum - 2,
+		})
+	}
+	wireTx.AddTxOut(wire.NewTxOut(1000, make([]byte, 22)))
+	wireTx.AddTxOut(wire.NewTxOut(0, []byte{0x6a, 0x02, 0x01, 0x02}))
+
+	var buf bytes.Buffer
+	require.NoError(t, wireTx.Serialize(&buf))
+	fee := make([]byte, 8)
+	binary.LittleEndian.PutUint64(fee, 1234)
+	return [][]byte{append(buf.Bytes(), fee...), {0, 0, 0, 0}}
+}
+
+func TestParseTransaction(t *testing.T) {
+	payload := newRawTxWithFee(t, 2)
+	firstSeen := time.Unix(1600000000, 0).UTC()
+
+	tx, err := parseTransaction(firstSeen, payload)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1234), tx.Fee)
+	assert.NotNil(t, tx.Details)
+	assert.True(t, tx.Signals.RBF)
+
+	// the hash-only mode reads the same txid and sizes
+	hashOnly, err := parseTransactionHashOnly(firstSeen, payload)
+	require.NoError(t, err)
+	assert.Equal(t, tx.TxID, hashOnly.TxID)
+	assert.Equal(t, tx.Fee, hashOnly.Fee)
+	assert.Equal(t, tx.Weight, hashOnly.Weight)
+	assert.Equal(t, tx.WitnessSize, hashOnly.WitnessSize)
+	assert.Nil(t, hashOnly.Details)
+
+	_, err = parseTransaction(firstSeen, [][]byte{payload[0][:20], payload[1]})
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+	_, err = parseTransactionHashOnly(firstSeen, [][]byte{payload[0][:20], payload[1]})
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+}
+
+// prevoutValues implements PrevoutFetcher with the same value for each prevout
+type prevoutValues struct {
+	value     int64
+	err       error
+	notInPool bool
+	requested int
+}
+
+func (p *prevoutValues) InMempool(txid chainhash.Hash) (bool, error) {
+	return !p.notInPool, nil
+}
+
+func (p *prevoutValues) PrevoutValues(outpoints []wire.OutPoint) ([]int64, error) {
+	p.requested += len(outpoints)
+	values := make([]int64, len(outpoints))
+	for i := range values {
+		values[i] = p.value
+	}
+	return values, p.err
+}
+
+func TestParseRawTx(t *testing.T) {
+	withFee := newRawTxWithFee(t, 2)
+	payload := [][]byte{withFee[0][:len(withFee[0])-8], withFee[1]}
+	firstSeen := time.Unix(1600000000, 0).UTC()
+
+	expected, err := parseTransaction(firstSeen, withFee)
+	require.NoError(t, err)
+
+	// the inputs of 1117 and the outputs of 1000 leave the fee of 1234
+	z := &ZMQSubscriber{prevouts: &prevoutValues{value: 1117}, outputs: newOutputCache(10)}
+	tx, err := z.parseRawTx(firstSeen, payload)
+	require.NoError(t, err)
+	assert.Equal(t, expected, tx)
+
+	z.prevouts = &prevoutValues{value: 400}
+	_, err = z.parseRawTx(firstSeen, payload)
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+
+	z.prevouts = &prevoutValues{err: errors.New("missing")}
+	_, err = z.parseRawTx(firstSeen, payload)
+	assert.Equal(t, errSkipped, err)
+
+	// transactions of connected blocks are not in the mempool
+	z.prevouts = &prevoutValues{value: 1117, notInPool: true}
+	_, err = z.parseRawTx(firstSeen, payload)
+	assert.Equal(t, errSkipped, err)
+
+	// the outputs of the parsed transaction are cached for its children
+	child := wire.NewMsgTx(wire.TxVersion)
+	child.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash(tx.TxID), Index: 0}})
+	child.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Hash: chainhash.DoubleHashH([]byte{9}), Index: 0}})
+	child.AddTxOut(wire.NewTxOut(1500, make([]byte, 22)))
+	var buf bytes.Buffer
+	require.NoError(t, child.Serialize(&buf))
+	prevouts := &prevoutValues{value: 600}
+	z.prevouts = prevouts
+	tx, err = z.parseRawTx(firstSeen, [][]byte{buf.Bytes(), {0, 0, 0, 0}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(100), tx.Fee)
+	assert.Equal(t, 1, prevouts.requested)
+
+	_, err = z.parseRawTx(firstSeen, payload[:1])
+	assert.Equal(t, types.ErrParse, errors.Cause(err))
+}
+
+func BenchmarkParseTransaction(b *testing.B) {
+	payload := newRawTxWithFee(b, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseTransaction(time.Time{}, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseTransactionHashOnly(b *testing.B) {
+	payload := newRawTxWithFee(b, 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseTransactionHashOnly(time.Time{}, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}